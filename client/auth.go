@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/oauth"
+)
+
+// WithTokenSource authenticates each call with a token of `source`, instead
+// of a static JWT which expires during long streams. The source is asked for
+// a token on every call, a Stream reconnecting after an `Unauthenticated`
+// error gets a fresh one. Wrap it with oauth2.ReuseTokenSource to cache the
+// token until it expires.
+func WithTokenSource(source oauth2.TokenSource) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.tokenSource = source
+	}
+}
+
+// WithTokenFunc is WithTokenSource for a function returning the token, called
+// with the context of the call.
+func WithTokenFunc(f func(ctx context.Context) (string, error)) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.tokenFunc = f
+	}
+}
+
+// refreshableToken returns whether the token of the calls can change from one
+// call to the next.
+func (c *SubstreamsClientConfig) refreshableToken() bool {
+	return c.tokenSource != nil || c.tokenFunc != nil
+}
+
+// perRPCCredentials returns the credentials authenticating the calls, nil
+// when they are not.
+func (c *SubstreamsClientConfig) perRPCCredentials() (credentials.PerRPCCredentials, error) {
+	configured := 0
+	for _, set := range []bool{c.jwt != "", c.tokenSource != nil, c.tokenFunc != nil} {
+		if set {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return nil, fmt.Errorf("a JWT, a token source and a token function are mutually exclusive, only one can be specified")
+	}
+
+	switch {
+	case c.jwt != "":
+		return oauth.NewOauthAccess(&oauth2.Token{AccessToken: c.jwt, TokenType: "Bearer"}), nil
+	case c.tokenSource != nil:
+		return oauth.TokenSource{TokenSource: c.tokenSource}, nil
+	case c.tokenFunc != nil:
+		return tokenFuncCredentials(c.tokenFunc), nil
+	}
+	return nil, nil
+}
+
+// tokenFuncCredentials authenticates each call with a bearer token returned
+// by the function.
+type tokenFuncCredentials func(ctx context.Context) (string, error)
+
+func (f tokenFuncCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := f(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting token: %w", err)
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (f tokenFuncCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// plaintextCredentials authenticates each call with the authorization
+// header value it returns, over a plaintext connection, which the grpc
+// credentials refuse, see WithUnsafePlaintextAuth.
+type plaintextCredentials func(ctx context.Context) (string, error)
+
+func (f plaintextCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	authorization, err := f(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting token: %w", err)
+	}
+	return map[string]string{"authorization": authorization}, nil
+}
+
+func (f plaintextCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// plaintextCredentials returns the credentials of the calls over a plaintext
+// connection, nil when none is configured.
+func (c *SubstreamsClientConfig) plaintextCredentials() credentials.PerRPCCredentials {
+	switch {
+	case c.jwt != "":
+		return plaintextCredentials(func(context.Context) (string, error) {
+			return "Bearer " + c.jwt, nil
+		})
+	case c.tokenSource != nil:
+		return plaintextCredentials(func(context.Context) (string, error) {
+			token, err := c.tokenSource.Token()
+			if err != nil {
+				return "", err
+			}
+			return token.Type() + " " + token.AccessToken, nil
+		})
+	case c.tokenFunc != nil:
+		return plaintextCredentials(func(ctx context.Context) (string, error) {
+			token, err := c.tokenFunc(ctx)
+			if err != nil {
+				return "", err
+			}
+			return "Bearer " + token, nil
+		})
+	}
+	return nil
+}
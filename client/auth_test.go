@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// rotatingTokens returns a new token on each call.
+type rotatingTokens struct {
+	count int
+}
+
+func (r *rotatingTokens) next() string {
+	r.count++
+	return fmt.Sprintf("token-%d", r.count)
+}
+
+func (r *rotatingTokens) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: r.next(), TokenType: "Bearer"}, nil
+}
+
+func TestNew_TokenRotation(t *testing.T) {
+	pki := newTestPKI(t)
+	endpoint := startMTLSServer(t, pki, headersServer{header: "authorization"})
+
+	tests := []struct {
+		name string
+		opt  func(tokens *rotatingTokens) Option
+	}{
+		{"token source", func(tokens *rotatingTokens) Option { return WithTokenSource(tokens) }},
+		{"token function", func(tokens *rotatingTokens) Option {
+			return WithTokenFunc(func(ctx context.Context) (string, error) { return tokens.next(), nil })
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := &rotatingTokens{}
+			cli, closeFunc, callOpts, err := New(endpoint,
+				WithCACertPool(pki.pool),
+				WithServerName("substreams.internal"),
+				WithClientCertificate(pki.clientCertFile, pki.clientKey),
+				test.opt(tokens),
+			)
+			require.NoError(t, err)
+			defer closeFunc()
+
+			for _, expected := range []string{"Bearer token-1", "Bearer token-2"} {
+				stream, err := cli.Blocks(context.Background(), &pbsubstreams.Request{}, callOpts...)
+				require.NoError(t, err)
+				resp, err := stream.Recv()
+				require.NoError(t, err)
+				assert.Equal(t, expected, resp.GetData().GetCursor())
+			}
+		})
+	}
+}
+
+func TestNew_ConflictingCredentials(t *testing.T) {
+	_, _, _, err := New("localhost:9000", WithJWT("jwt"), WithTokenSource(&rotatingTokens{}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a JWT, a token source and a token function are mutually exclusive")
+}
+
+func TestNew_PlaintextAuth(t *testing.T) {
+	pki := newTestPKI(t)
+	tlsEndpoint := startMTLSServer(t, pki, headersServer{header: "authorization"})
+	plaintextEndpoint := startServer(t, headersServer{header: "authorization"})
+	tlsOpts := []Option{WithCACertPool(pki.pool), WithServerName("substreams.internal"), WithClientCertificate(pki.clientCertFile, pki.clientKey)}
+
+	tests := []struct {
+		name                string
+		plaintext           bool
+		jwt                 string
+		unsafePlaintextAuth bool
+		expected            string
+	}{
+		{"tls without jwt", false, "", false, ""},
+		{"tls with jwt", false, "jwt", false, "Bearer jwt"},
+		{"plaintext without jwt", true, "", false, ""},
+		{"plaintext with jwt", true, "jwt", false, ""},
+		{"unsafe plaintext without jwt", true, "", true, ""},
+		{"unsafe plaintext with jwt", true, "jwt", true, "Bearer jwt"},
+		{"unsafe plaintext over tls", false, "jwt", true, "Bearer jwt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint, opts := tlsEndpoint, tlsOpts
+			if test.plaintext {
+				endpoint, opts = plaintextEndpoint, []Option{WithPlaintext(true)}
+			}
+			opts = append(opts, WithJWT(test.jwt), WithUnsafePlaintextAuth(test.unsafePlaintextAuth))
+
+			cli, closeFunc, callOpts, err := New(endpoint, opts...)
+			require.NoError(t, err)
+			defer closeFunc()
+
+			stream, err := cli.Blocks(context.Background(), &pbsubstreams.Request{}, callOpts...)
+			require.NoError(t, err)
+			resp, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, resp.GetData().GetCursor())
+		})
+	}
+}
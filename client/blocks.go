@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ModuleHandler receives the output of a module for a block: the decoded
+// message of a map module, the `*pbsubstreams.StoreDeltas` of a store one.
+type ModuleHandler func(clock *pbsubstreams.Clock, output proto.Message) error
+
+// BlockHandlers are the callbacks of StreamBlocks. All of them are optional,
+// the first error returned by one of them ends the stream.
+type BlockHandlers struct {
+	// Modules receive the outputs of the modules, by module name. The blocks
+	// for which a module produced no output are skipped.
+	Modules map[string]ModuleHandler
+
+	// Types resolves the message types of the map outputs. Defaults to the
+	// types linked in the program, PackageTypes resolves the ones of a
+	// package.
+	Types protoregistry.MessageTypeResolver
+
+	OnProgress func(progress *pbsubstreams.ModulesProgress) error
+
+	// OnUndo is called when the blocks after the last valid block of
+	// `signal` are forked out, their outputs must be reverted.
+	OnUndo func(signal *pbsubstreams.BlockUndoSignal) error
+
+	// OnCursor is called once a block, or an undo signal, was handled, with
+	// the cursor to persist to resume the stream after it.
+	OnCursor func(cursor string) error
+}
+
+// StreamBlocks streams the blocks of `request` from the server of `config`,
+// handing the decoded outputs of the modules to `handlers`. The stream
+// reconnects and resumes on transient failures like the ones of NewStream.
+// It returns nil once the stream is over, or the first error of the stream
+// or of a handler, the stream being cancelled.
+//
+// With WithCursorStore, the stream starts from the stored cursor, and the
+// cursor of a block is saved once all its handlers returned.
+func StreamBlocks(ctx context.Context, config *SubstreamsClientConfig, request *pbsubstreams.Request, handlers *BlockHandlers, opts ...StreamOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream := NewStream(ctx, config, request, opts...)
+	err := streamBlocks(stream, request, handlers)
+	if closeErr := stream.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func streamBlocks(stream *Stream, request *pbsubstreams.Request, handlers *BlockHandlers) error {
+	requested := map[string]bool{}
+	for _, module := range request.OutputModules {
+		requested[module] = true
+	}
+	for module := range handlers.Modules {
+		if !requested[module] {
+			return fmt.Errorf("handler of module %q which is not an output module of the request", module)
+		}
+	}
+
+	types := handlers.Types
+	if types == nil {
+		types = protoregistry.GlobalTypes
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch msg := resp.Message.(type) {
+		case *pbsubstreams.Response_Data:
+			if err := handleBlock(msg.Data, handlers.Modules, types); err != nil {
+				return err
+			}
+			if handlers.OnCursor != nil {
+				if err := handlers.OnCursor(msg.Data.Cursor); err != nil {
+					return fmt.Errorf("handling cursor of block %d: %w", msg.Data.Clock.GetNumber(), err)
+				}
+			}
+
+		case *pbsubstreams.Response_UndoSignal:
+			if handlers.OnUndo != nil {
+				if err := handlers.OnUndo(msg.UndoSignal); err != nil {
+					return fmt.Errorf("handling undo signal to block %d: %w", msg.UndoSignal.LastValidBlock.GetNumber(), err)
+				}
+			}
+			if handlers.OnCursor != nil {
+				if err := handlers.OnCursor(msg.UndoSignal.LastValidCursor); err != nil {
+					return fmt.Errorf("handling cursor of undo signal to block %d: %w", msg.UndoSignal.LastValidBlock.GetNumber(), err)
+				}
+			}
+
+		case *pbsubstreams.Response_Progress:
+			if handlers.OnProgress != nil {
+				if err := handlers.OnProgress(msg.Progress); err != nil {
+					return fmt.Errorf("handling progress: %w", err)
+				}
+			}
+		}
+	}
+}
+
+func handleBlock(data *pbsubstreams.BlockScopedData, modules map[string]ModuleHandler, types protoregistry.MessageTypeResolver) error {
+	for _, output := range data.Outputs {
+		handler, found := modules[output.Name]
+		if !found {
+			continue
+		}
+
+		var decoded proto.Message
+		switch out := output.Data.(type) {
+		case *pbsubstreams.ModuleOutput_MapOutput:
+			if len(out.MapOutput.GetValue()) == 0 {
+				continue
+			}
+			msgType, err := types.FindMessageByURL(out.MapOutput.TypeUrl)
+			if err != nil {
+				return fmt.Errorf("resolving output type %q of module %q: %w", out.MapOutput.TypeUrl, output.Name, err)
+			}
+			decoded = msgType.New().Interface()
+			if err := proto.Unmarshal(out.MapOutput.Value, decoded); err != nil {
+				return fmt.Errorf("decoding output of module %q at block %d: %w", output.Name, data.Clock.GetNumber(), err)
+			}
+		case *pbsubstreams.ModuleOutput_StoreDeltas:
+			if len(out.StoreDeltas.GetDeltas()) == 0 {
+				continue
+			}
+			decoded = out.StoreDeltas
+		default:
+			continue
+		}
+
+		if err := handler(data.Clock, decoded); err != nil {
+			return fmt.Errorf("handling output of module %q at block %d: %w", output.Name, data.Clock.GetNumber(), err)
+		}
+	}
+	return nil
+}
+
+// PackageTypes returns the message types of the protobuf files of `pkg`, to
+// decode the outputs of its modules without linking their Go types.
+func PackageTypes(pkg *pbsubstreams.Package) (*protoregistry.Types, error) {
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: pkg.ProtoFiles})
+	if err != nil {
+		return nil, fmt.Errorf("loading protobuf files of package: %w", err)
+	}
+
+	types := &protoregistry.Types{}
+	var registerErr error
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		registerErr = registerMessages(types, file.Messages())
+		return registerErr == nil
+	})
+	if registerErr != nil {
+		return nil, registerErr
+	}
+	return types, nil
+}
+
+func registerMessages(types *protoregistry.Types, messages protoreflect.MessageDescriptors) error {
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+		if message.IsMapEntry() {
+			continue
+		}
+		if err := types.RegisterMessage(dynamicpb.NewMessageType(message)); err != nil {
+			return fmt.Errorf("registering message %s: %w", message.FullName(), err)
+		}
+		if err := registerMessages(types, message.Messages()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func blockData(number uint64, cursor string, outputs ...*pbsubstreams.ModuleOutput) *pbsubstreams.Response {
+	return &pbsubstreams.Response{Message: &pbsubstreams.Response_Data{Data: &pbsubstreams.BlockScopedData{
+		Clock:   &pbsubstreams.Clock{Number: number},
+		Cursor:  cursor,
+		Outputs: outputs,
+	}}}
+}
+
+func mapOutput(t *testing.T, module string, msg proto.Message) *pbsubstreams.ModuleOutput {
+	value, err := anypb.New(msg)
+	require.NoError(t, err)
+	return &pbsubstreams.ModuleOutput{Name: module, Data: &pbsubstreams.ModuleOutput_MapOutput{MapOutput: value}}
+}
+
+func TestStreamBlocks(t *testing.T) {
+	deltas := &pbsubstreams.StoreDeltas{Deltas: []*pbsubstreams.StoreDelta{{Key: "k"}}}
+	server := &fakeServer{sessions: []fakeSession{
+		{
+			responses: []*pbsubstreams.Response{
+				{Message: &pbsubstreams.Response_Progress{Progress: &pbsubstreams.ModulesProgress{}}},
+				blockData(1, "c1",
+					mapOutput(t, "map_clock", &pbsubstreams.Clock{Id: "block1"}),
+					&pbsubstreams.ModuleOutput{Name: "store_keys", Data: &pbsubstreams.ModuleOutput_StoreDeltas{StoreDeltas: deltas}},
+					mapOutput(t, "unhandled", &pbsubstreams.Clock{Number: 1}),
+				),
+				{Message: &pbsubstreams.Response_UndoSignal{UndoSignal: &pbsubstreams.BlockUndoSignal{LastValidBlock: &pbsubstreams.BlockRef{Number: 0}, LastValidCursor: "c0"}}},
+			},
+			err: status.Error(codes.Unavailable, "connection reset"),
+		},
+		{
+			responses: []*pbsubstreams.Response{
+				blockData(1, "c1b", &pbsubstreams.ModuleOutput{Name: "map_clock", Data: &pbsubstreams.ModuleOutput_MapOutput{MapOutput: &anypb.Any{}}}),
+			},
+			err: io.EOF,
+		},
+	}}
+
+	var calls []string
+	handlers := &BlockHandlers{
+		Modules: map[string]ModuleHandler{
+			"map_clock": func(clock *pbsubstreams.Clock, output proto.Message) error {
+				calls = append(calls, fmt.Sprintf("map_clock %d %s", clock.Number, output.(*pbsubstreams.Clock).Id))
+				return nil
+			},
+			"store_keys": func(clock *pbsubstreams.Clock, output proto.Message) error {
+				calls = append(calls, fmt.Sprintf("store_keys %d %s", clock.Number, output.(*pbsubstreams.StoreDeltas).Deltas[0].Key))
+				return nil
+			},
+		},
+		OnProgress: func(*pbsubstreams.ModulesProgress) error { calls = append(calls, "progress"); return nil },
+		OnUndo: func(signal *pbsubstreams.BlockUndoSignal) error {
+			calls = append(calls, fmt.Sprintf("undo %d", signal.LastValidBlock.Number))
+			return nil
+		},
+		OnCursor: func(cursor string) error { calls = append(calls, "cursor "+cursor); return nil },
+	}
+
+	request := &pbsubstreams.Request{OutputModules: []string{"map_clock", "store_keys", "unhandled"}}
+	stream := newStream(context.Background(), server.dial, request, WithReconnectBackoff(0, 0))
+	require.NoError(t, streamBlocks(stream, request, handlers))
+
+	assert.Equal(t, []string{
+		"progress",
+		"map_clock 1 block1",
+		"store_keys 1 k",
+		"cursor c1",
+		"undo 0",
+		"cursor c0",
+		"cursor c1b", // empty output skipped
+	}, calls)
+	require.Len(t, server.requests, 2)
+	assert.Equal(t, "c0", server.requests[1].StartCursor, "resumed after the undo signal")
+}
+
+func TestStreamBlocks_HandlerError(t *testing.T) {
+	server := &fakeServer{sessions: []fakeSession{
+		{responses: []*pbsubstreams.Response{
+			blockData(1, "c1", mapOutput(t, "map_clock", &pbsubstreams.Clock{Number: 1})),
+			blockData(2, "c2", mapOutput(t, "map_clock", &pbsubstreams.Clock{Number: 2})),
+		}},
+	}}
+	failure := errors.New("database down")
+	var cursors []string
+	handlers := &BlockHandlers{
+		Modules: map[string]ModuleHandler{
+			"map_clock": func(*pbsubstreams.Clock, proto.Message) error { return failure },
+		},
+		OnCursor: func(cursor string) error { cursors = append(cursors, cursor); return nil },
+	}
+
+	request := &pbsubstreams.Request{OutputModules: []string{"map_clock"}}
+	err := streamBlocks(newStream(context.Background(), server.dial, request), request, handlers)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, failure)
+	assert.Contains(t, err.Error(), `handling output of module "map_clock" at block 1`)
+	assert.Empty(t, cursors, "cursor of a failed block not handed out")
+}
+
+func TestStreamBlocks_InvalidHandlers(t *testing.T) {
+	request := &pbsubstreams.Request{OutputModules: []string{"map_clock"}}
+	handlers := &BlockHandlers{Modules: map[string]ModuleHandler{
+		"map_transfers": func(*pbsubstreams.Clock, proto.Message) error { return nil },
+	}}
+	err := streamBlocks(newStream(context.Background(), (&fakeServer{}).dial, request), request, handlers)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `handler of module "map_transfers" which is not an output module of the request`)
+}
+
+func TestPackageTypes(t *testing.T) {
+	pkg := &pbsubstreams.Package{ProtoFiles: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("transfers.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Transfer"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("from"),
+				Number:   proto.Int32(1),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				JsonName: proto.String("from"),
+			}},
+		}},
+	}}}
+
+	types, err := PackageTypes(pkg)
+	require.NoError(t, err)
+
+	// `from` set to "alice"
+	output := &pbsubstreams.ModuleOutput{Name: "map_transfers", Data: &pbsubstreams.ModuleOutput_MapOutput{MapOutput: &anypb.Any{
+		TypeUrl: "type.googleapis.com/test.Transfer",
+		Value:   []byte{0x0a, 0x05, 'a', 'l', 'i', 'c', 'e'},
+	}}}
+	var from string
+	err = handleBlock(&pbsubstreams.BlockScopedData{Outputs: []*pbsubstreams.ModuleOutput{output}}, map[string]ModuleHandler{
+		"map_transfers": func(_ *pbsubstreams.Clock, msg proto.Message) error {
+			from = msg.ProtoReflect().Get(msg.ProtoReflect().Descriptor().Fields().ByName("from")).String()
+			return nil
+		},
+	}, types)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", from)
+
+	err = handleBlock(&pbsubstreams.BlockScopedData{Outputs: []*pbsubstreams.ModuleOutput{mapOutput(t, "map_transfers", &pbsubstreams.Clock{Number: 1})}}, map[string]ModuleHandler{
+		"map_transfers": func(*pbsubstreams.Clock, proto.Message) error { return nil },
+	}, types)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `resolving output type "type.googleapis.com/sf.substreams.v1.Clock" of module "map_transfers"`)
+}
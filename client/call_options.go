@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// DefaultMaxRecvMsgSize fits the largest blocks, much more than the 4 MiB
+	// gRPC defaults to.
+	DefaultMaxRecvMsgSize = 1024 * 1024 * 1024
+	DefaultMaxSendMsgSize = math.MaxInt32
+)
+
+// maxRetryAttempts is the maximum number of attempts gRPC makes for a call,
+// higher values being lowered to it.
+const maxRetryAttempts = 5
+
+// RetryPolicy is how gRPC retries a call failing before the server answered
+// anything, the request being sent again as is. The substreams calls only
+// read, so it is safe to retry them.
+type RetryPolicy struct {
+	// MaxAttempts counts the original attempt, between 2 and 5.
+	MaxAttempts          int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	BackoffMultiplier    float64
+	RetryableStatusCodes []codes.Code
+}
+
+// DefaultRetryPolicy retries calls failing because the server is unavailable.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:          4,
+	InitialBackoff:       500 * time.Millisecond,
+	MaxBackoff:           5 * time.Second,
+	BackoffMultiplier:    2,
+	RetryableStatusCodes: []codes.Code{codes.Unavailable},
+}
+
+// WithMaxRecvMsgSize sets the size of the largest message the client
+// receives, DefaultMaxRecvMsgSize by default.
+func WithMaxRecvMsgSize(bytes int) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.maxRecvMsgSize = bytes
+	}
+}
+
+// WithMaxSendMsgSize sets the size of the largest message the client sends,
+// DefaultMaxSendMsgSize by default.
+func WithMaxSendMsgSize(bytes int) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.maxSendMsgSize = bytes
+	}
+}
+
+// WithUnaryTimeout bounds the duration of the unary calls made without a
+// deadline. Streams, like Blocks, are not bounded. No timeout by default.
+func WithUnaryTimeout(timeout time.Duration) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.unaryTimeout = timeout
+	}
+}
+
+// WithRetryPolicy sets how the calls failing before the server answered are
+// retried, DefaultRetryPolicy by default. nil disables the retries.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.retryPolicy = policy
+	}
+}
+
+func (c *SubstreamsClientConfig) validateCallOptions() error {
+	if c.maxRecvMsgSize <= 0 {
+		return fmt.Errorf("invalid max receive message size %d, must be positive", c.maxRecvMsgSize)
+	}
+	if c.maxSendMsgSize <= 0 {
+		return fmt.Errorf("invalid max send message size %d, must be positive", c.maxSendMsgSize)
+	}
+	if c.unaryTimeout < 0 {
+		return fmt.Errorf("invalid unary timeout %s, must be positive", c.unaryTimeout)
+	}
+	if policy := c.retryPolicy; policy != nil {
+		switch {
+		case policy.MaxAttempts < 2 || policy.MaxAttempts > maxRetryAttempts:
+			return fmt.Errorf("invalid retry policy: max attempts %d, must be between 2 and %d", policy.MaxAttempts, maxRetryAttempts)
+		case policy.InitialBackoff <= 0 || policy.MaxBackoff <= 0:
+			return fmt.Errorf("invalid retry policy: backoffs %s and %s, both must be positive", policy.InitialBackoff, policy.MaxBackoff)
+		case policy.BackoffMultiplier <= 0:
+			return fmt.Errorf("invalid retry policy: backoff multiplier %g, must be positive", policy.BackoffMultiplier)
+		case len(policy.RetryableStatusCodes) == 0:
+			return fmt.Errorf("invalid retry policy: no retryable status code")
+		}
+	}
+	return nil
+}
+
+// serviceConfig returns the default service config of the connection,
+// balancing the calls across the addresses of the endpoint and retrying them
+// according to the retry policy.
+func (c *SubstreamsClientConfig) serviceConfig() string {
+	type retryPolicy struct {
+		MaxAttempts          int          `json:"maxAttempts"`
+		InitialBackoff       string       `json:"initialBackoff"`
+		MaxBackoff           string       `json:"maxBackoff"`
+		BackoffMultiplier    float64      `json:"backoffMultiplier"`
+		RetryableStatusCodes []codes.Code `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []map[string]string `json:"name"`
+		RetryPolicy retryPolicy         `json:"retryPolicy"`
+	}
+	config := struct {
+		LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig"`
+		MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+	}{
+		LoadBalancingConfig: []map[string]struct{}{{"round_robin": {}}},
+	}
+	if policy := c.retryPolicy; policy != nil {
+		config.MethodConfig = []methodConfig{{
+			Name: []map[string]string{{"service": "sf.substreams.v1.Stream"}},
+			RetryPolicy: retryPolicy{
+				MaxAttempts:          policy.MaxAttempts,
+				InitialBackoff:       serviceConfigDuration(policy.InitialBackoff),
+				MaxBackoff:           serviceConfigDuration(policy.MaxBackoff),
+				BackoffMultiplier:    policy.BackoffMultiplier,
+				RetryableStatusCodes: policy.RetryableStatusCodes,
+			},
+		}}
+	}
+	out, err := json.Marshal(config)
+	if err != nil {
+		panic(fmt.Errorf("marshalling service config: %w", err))
+	}
+	return string(out)
+}
+
+func serviceConfigDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// unaryTimeoutInterceptor bounds the unary calls made without a deadline to
+// `timeout`.
+func unaryTimeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, found := ctx.Deadline(); !found {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// uncommittedStreamInterceptor keeps the streams retryable until they receive
+// their first response. The tracing interceptor calls the Context method of
+// the stream as soon as the request is sent, which commits the call to its
+// first attempt and disables the retry policy: the streams it opens answer
+// it with the context of the call instead.
+func uncommittedStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &uncommittedStream{ClientStream: stream, ctx: ctx}, nil
+}
+
+type uncommittedStream struct {
+	grpc.ClientStream
+	ctx context.Context
+}
+
+func (s *uncommittedStream) Context() context.Context {
+	return s.ctx
+}
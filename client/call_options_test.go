@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyServer fails the first `failures` Blocks calls as unavailable, before
+// answering anything, then echoes the cursor of the request.
+type flakyServer struct {
+	echoServer
+
+	lock     sync.Mutex
+	failures int
+	calls    int
+}
+
+func (f *flakyServer) Blocks(req *pbsubstreams.Request, stream pbsubstreams.Stream_BlocksServer) error {
+	f.lock.Lock()
+	f.calls++
+	fail := f.calls <= f.failures
+	f.lock.Unlock()
+	if fail {
+		return status.Error(codes.Unavailable, "overloaded")
+	}
+	return f.echoServer.Blocks(req, stream)
+}
+
+func startServer(t *testing.T, srv pbsubstreams.StreamServer) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(16 * 1024 * 1024))
+	pbsubstreams.RegisterStreamServer(server, srv)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func firstCursor(t *testing.T, endpoint string, cursor string, opts ...Option) (string, error) {
+	t.Helper()
+
+	cli, closeFunc, callOpts, err := New(endpoint, append([]Option{WithPlaintext(true)}, opts...)...)
+	require.NoError(t, err)
+	defer closeFunc()
+
+	stream, err := cli.Blocks(context.Background(), &pbsubstreams.Request{StartCursor: cursor}, callOpts...)
+	if err != nil {
+		return "", err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return "", err
+	}
+	return resp.GetData().GetCursor(), nil
+}
+
+func TestNew_MaxRecvMsgSize(t *testing.T) {
+	endpoint := startServer(t, echoServer{})
+	cursor := strings.Repeat("c", 8*1024*1024)
+
+	received, err := firstCursor(t, endpoint, cursor, WithMaxSendMsgSize(16*1024*1024))
+	require.NoError(t, err)
+	assert.Equal(t, cursor, received)
+
+	_, err = firstCursor(t, endpoint, cursor, WithMaxRecvMsgSize(1024*1024))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = firstCursor(t, endpoint, cursor, WithMaxSendMsgSize(1024*1024))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestNew_RetryPolicy(t *testing.T) {
+	server := &flakyServer{failures: 2}
+	received, err := firstCursor(t, startServer(t, server), "c1", WithRetryPolicy(&RetryPolicy{
+		MaxAttempts:          3,
+		InitialBackoff:       time.Millisecond,
+		MaxBackoff:           time.Millisecond,
+		BackoffMultiplier:    1,
+		RetryableStatusCodes: []codes.Code{codes.Unavailable},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "c1", received)
+	assert.Equal(t, 3, server.calls)
+
+	server = &flakyServer{failures: 1}
+	_, err = firstCursor(t, startServer(t, server), "c1", WithRetryPolicy(nil))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, server.calls)
+}
+
+func TestNew_InvalidCallOptions(t *testing.T) {
+	validPolicy := func(f func(policy *RetryPolicy)) Option {
+		policy := DefaultRetryPolicy
+		f(&policy)
+		return WithRetryPolicy(&policy)
+	}
+
+	tests := []struct {
+		name          string
+		opt           Option
+		expectedError string
+	}{
+		{"zero receive size", WithMaxRecvMsgSize(0), "invalid max receive message size 0"},
+		{"negative send size", WithMaxSendMsgSize(-1), "invalid max send message size -1"},
+		{"negative unary timeout", WithUnaryTimeout(-time.Second), "invalid unary timeout -1s"},
+		{"single attempt", validPolicy(func(p *RetryPolicy) { p.MaxAttempts = 1 }), "max attempts 1, must be between 2 and 5"},
+		{"too many attempts", validPolicy(func(p *RetryPolicy) { p.MaxAttempts = 6 }), "max attempts 6, must be between 2 and 5"},
+		{"zero backoff", validPolicy(func(p *RetryPolicy) { p.InitialBackoff = 0 }), "backoffs 0s and 5s, both must be positive"},
+		{"zero multiplier", validPolicy(func(p *RetryPolicy) { p.BackoffMultiplier = 0 }), "backoff multiplier 0, must be positive"},
+		{"no status code", validPolicy(func(p *RetryPolicy) { p.RetryableStatusCodes = nil }), "no retryable status code"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, _, err := New("localhost:9000", WithPlaintext(true), test.opt)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestUnaryTimeoutInterceptor(t *testing.T) {
+	interceptor := unaryTimeoutInterceptor(time.Minute)
+	deadlineOf := func(ctx context.Context) (deadline time.Time) {
+		_ = interceptor(ctx, "/method", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			deadline, _ = ctx.Deadline()
+			return nil
+		})
+		return deadline
+	}
+
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadlineOf(context.Background()), time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	expected, _ := ctx.Deadline()
+	assert.Equal(t, expected, deadlineOf(ctx))
+}
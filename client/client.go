@@ -1,40 +1,207 @@
 package client
 
 import (
-	"crypto/tls"
+	"context"
+	"crypto/x509"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/streamingfast/dgrpc"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/credentials/oauth"
+	"google.golang.org/grpc/credentials/local"
 	xdscreds "google.golang.org/grpc/credentials/xds"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	_ "google.golang.org/grpc/xds"
 )
 
+// Connection defaults tuned for long-lived streams: a connection left idle
+// between two sparse blocks is pinged often enough for the load balancers
+// in between not to drop it, and a dropped one is noticed within
+// DefaultKeepaliveTime + DefaultKeepaliveTimeout.
+const (
+	DefaultKeepaliveTime    = 30 * time.Second
+	DefaultKeepaliveTimeout = 10 * time.Second
+	DefaultDialTimeout      = 20 * time.Second
+)
+
+// SubstreamsClientConfig holds how to connect to a substreams endpoint. It is
+// built by NewConfig from an endpoint and options.
 type SubstreamsClientConfig struct {
-	endpoint  string
-	jwt       string
-	insecure  bool
-	plaintext bool
+	endpoint            string
+	jwt                 string
+	tokenSource         oauth2.TokenSource
+	tokenFunc           func(ctx context.Context) (string, error)
+	insecure            bool
+	plaintext           bool
+	unsafePlaintextAuth bool
+	compression         string
+	keepalive           keepalive.ClientParameters
+	dialTimeout         time.Duration
+	waitForReady        bool
+	headers             metadata.MD
+
+	maxRecvMsgSize int
+	maxSendMsgSize int
+	unaryTimeout   time.Duration
+	retryPolicy    *RetryPolicy
+
+	poolSize        int
+	poolIdleTimeout time.Duration
+
+	caCertPool     *x509.CertPool
+	caCertFile     string
+	clientCertFile string
+	clientKeyFile  string
+	serverName     string
+
+	proxyURL         string
+	dnsLoadBalancing bool
+
+	metricsRegisterer prometheus.Registerer
 }
 
-func NewSubstreamsClientConfig(endpoint string, jwt string, insecure bool, plaintext bool) *SubstreamsClientConfig {
-	return &SubstreamsClientConfig{
-		endpoint:  endpoint,
-		jwt:       jwt,
-		insecure:  insecure,
-		plaintext: plaintext,
+type Option func(c *SubstreamsClientConfig)
+
+// WithJWT authenticates the calls with `jwt` as a bearer token. Plaintext
+// connections are not authenticated, unless WithUnsafePlaintextAuth is set.
+func WithJWT(jwt string) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.jwt = jwt
+	}
+}
+
+// WithPlaintext connects to the server without TLS.
+func WithPlaintext(plaintext bool) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.plaintext = plaintext
+	}
+}
+
+// WithUnsafePlaintextAuth sends the credentials even over a plaintext
+// connection, where they are never sent otherwise, to test authentication
+// against a local development server. Anyone on the network can read them:
+// never use it against a remote server.
+func WithUnsafePlaintextAuth(enabled bool) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.unsafePlaintextAuth = enabled
+	}
+}
+
+// WithInsecureTLS connects to the server with TLS, without validating its
+// certificate.
+func WithInsecureTLS(insecure bool) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.insecure = insecure
+	}
+}
+
+// WithCompression compresses the requests with the `compressor` registered in
+// the gRPC encoding registry, CompressionGzip or CompressionZstd, and asks the
+// server to compress its responses the same way. Compression is off by
+// default.
+//
+// The message size limits still apply to the decompressed messages: a
+// response over the maximum receive size of the client is refused even when
+// it travels compressed.
+func WithCompression(compressor string) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.compression = compressor
+	}
+}
+
+// WithKeepalive pings the server after `time` without activity on the
+// connection, and closes it when the ping is not acknowledged within
+// `timeout`. With `permitWithoutStream`, the connection is also pinged while
+// no stream is open. Servers refuse pings more frequent than their
+// enforcement policy allows, 15 seconds for the StreamingFast ones, closing
+// the connection with a `too_many_pings` error.
+func WithKeepalive(time, timeout time.Duration, permitWithoutStream bool) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.keepalive = keepalive.ClientParameters{Time: time, Timeout: timeout, PermitWithoutStream: permitWithoutStream}
+	}
+}
+
+// WithDialTimeout bounds each attempt to establish the connection to the
+// server.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.dialTimeout = timeout
 	}
 }
 
+// WithWaitForReady sets whether the calls wait for the connection to the
+// server to be established, the default, or fail right away with an
+// `Unavailable` error when it is not.
+func WithWaitForReady(waitForReady bool) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.waitForReady = waitForReady
+	}
+}
+
+// WithHeaders sends `headers` as metadata with every call, like the
+// `x-api-key` of the providers not authenticating with a JWT, on top of the
+// authentication. It can be repeated, the headers add up. Only the names of
+// the headers are logged, never their values.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.headers = metadata.Join(c.headers, metadata.New(headers))
+	}
+}
+
+// NewConfig returns the configuration of a client of `endpoint`, a TLS
+// connection by default.
+func NewConfig(endpoint string, opts ...Option) *SubstreamsClientConfig {
+	c := &SubstreamsClientConfig{
+		endpoint: endpoint,
+		keepalive: keepalive.ClientParameters{
+			Time:                DefaultKeepaliveTime,
+			Timeout:             DefaultKeepaliveTimeout,
+			PermitWithoutStream: true,
+		},
+		dialTimeout:    DefaultDialTimeout,
+		waitForReady:   true,
+		maxRecvMsgSize: DefaultMaxRecvMsgSize,
+		maxSendMsgSize: DefaultMaxSendMsgSize,
+
+		poolSize:        1,
+		poolIdleTimeout: DefaultPoolIdleTimeout,
+	}
+	policy := DefaultRetryPolicy
+	c.retryPolicy = &policy
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// New connects to `endpoint`, see NewSubstreamsClient.
+func New(endpoint string, opts ...Option) (cli pbsubstreams.StreamClient, closeFunc func() error, callOpts []grpc.CallOption, err error) {
+	return NewSubstreamsClient(NewConfig(endpoint, opts...))
+}
+
+// Deprecated: use NewConfig with WithJWT, WithInsecureTLS and WithPlaintext.
+func NewSubstreamsClientConfig(endpoint string, jwt string, insecure bool, plaintext bool) *SubstreamsClientConfig {
+	return NewConfig(endpoint, WithJWT(jwt), WithInsecureTLS(insecure), WithPlaintext(plaintext))
+}
+
+// NewSubstreamsClient connects to the server of `config`. The returned call
+// options carry the authentication, compression, wait for ready behavior and
+// message size limits of `config`, they must be passed to every call of the
+// client.
 func NewSubstreamsClient(config *SubstreamsClientConfig) (cli pbsubstreams.StreamClient, closeFunc func() error, callOpts []grpc.CallOption, err error) {
 	if config == nil {
 		panic("substreams client config not set")
@@ -44,68 +211,243 @@ func NewSubstreamsClient(config *SubstreamsClientConfig) (cli pbsubstreams.Strea
 	usePlainTextConnection := config.plaintext
 	useInsecureTLSConnection := config.insecure
 
-	zlog.Info("creating new client", zap.String("endpoint", endpoint), zap.Bool("jwt_present", jwt != ""), zap.Bool("plaintext", usePlainTextConnection), zap.Bool("insecure", useInsecureTLSConnection))
+	zlog.Info("creating new client", zap.String("endpoint", endpoint), zap.Bool("jwt_present", jwt != ""), zap.Bool("token_source", config.refreshableToken()), zap.Bool("plaintext", usePlainTextConnection), zap.Bool("insecure", useInsecureTLSConnection), zap.String("compression", config.compression), zap.Strings("headers", config.headerNames()))
+
+	if err := config.validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	defaultPort := "443"
+	if usePlainTextConnection {
+		defaultPort = ""
+	}
+	endpoint, unixSocket, err := normalizeEndpoint(endpoint, defaultPort)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if config.dnsLoadBalancing {
+		switch {
+		case !unixSocket && !strings.Contains(endpoint, "://"):
+			endpoint = "dns:///" + endpoint
+		case !strings.HasPrefix(endpoint, "dns://"):
+			return nil, nil, nil, fmt.Errorf("DNS load balancing only applies to host:port and dns:/// endpoints, not %q", config.endpoint)
+		}
+		zlog.Debug("load balancing over the addresses of the endpoint host", zap.String("target", endpoint))
+	}
 
 	bootStrapFilename := os.Getenv("GRPC_XDS_BOOTSTRAP")
 	zlog.Info("looked for GRPC_XDS_BOOTSTRAP", zap.String("filename", bootStrapFilename))
 
 	var dialOptions []grpc.DialOption
-	skipAuth := jwt == "" || usePlainTextConnection
-	if bootStrapFilename != "" {
+	switch {
+	case unixSocket:
+		if useInsecureTLSConnection || config.customTLS() {
+			return nil, nil, nil, fmt.Errorf("a unix socket connection does not use TLS, it cannot use a CA certificate, a client certificate, a server name or --insecure")
+		}
+		if config.proxyURL != "" {
+			return nil, nil, nil, fmt.Errorf("a unix socket connection cannot go through a proxy")
+		}
+		zlog.Debug("setting unix socket option")
+		// Local credentials let the calls be authenticated without TLS
+		dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(local.NewCredentials())}
+
+	case bootStrapFilename != "":
 		log.Println("Using xDS credentials...")
 		creds, err := xdscreds.NewClientCredentials(xdscreds.ClientOptions{FallbackCreds: insecure.NewCredentials()})
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("failed to create xDS credentials: %v", err)
 		}
 		dialOptions = append(dialOptions, grpc.WithTransportCredentials(creds))
-	} else {
 
-		bootStrapFilename := os.Getenv("GRPC_XDS_BOOTSTRAP")
-		zlog.Info("looked for GRPC_XDS_BOOTSTRAP", zap.String("filename", bootStrapFilename))
+	default:
+		if useInsecureTLSConnection && usePlainTextConnection {
+			return nil, nil, nil, fmt.Errorf("option --insecure and --plaintext are mutually exclusive, they cannot be both specified at the same time")
+		}
+		switch {
+		case usePlainTextConnection:
+			if config.customTLS() {
+				return nil, nil, nil, fmt.Errorf("a plaintext connection cannot use a CA certificate, a client certificate or a server name")
+			}
+			zlog.Debug("setting plain text option")
 
-		var dialOptions []grpc.DialOption
-		if bootStrapFilename != "" {
-			log.Println("Using xDS credentials...")
-			creds, err := xdscreds.NewClientCredentials(xdscreds.ClientOptions{FallbackCreds: insecure.NewCredentials()})
+			dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+
+		case config.customTLS():
+			zlog.Debug("setting custom tls connection option", zap.Bool("insecure", useInsecureTLSConnection), zap.String("server_name", config.serverName), zap.Bool("client_certificate", config.clientCertFile != ""))
+			tlsConfig, err := config.tlsConfig()
 			if err != nil {
-				return nil, nil, nil, fmt.Errorf("failed to create xDS credentials: %v", err)
-			}
-			dialOptions = append(dialOptions, grpc.WithTransportCredentials(creds))
-		} else {
-			if useInsecureTLSConnection && usePlainTextConnection {
-				return nil, nil, nil, fmt.Errorf("option --insecure and --plaintext are mutually exclusive, they cannot be both specified at the same time")
+				return nil, nil, nil, err
 			}
-			switch {
-			case usePlainTextConnection:
-				zlog.Debug("setting plain text option")
-
-				dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+			dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+		}
 
-			case useInsecureTLSConnection:
-				zlog.Debug("setting insecure tls connection option")
-				dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}))}
+		proxyDialer, err := config.proxyDialer()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if proxyDialer != nil {
+			zlog.Debug("setting proxy dialer option", zap.Bool("explicit_proxy", config.proxyURL != ""))
+			dialOptions = append(dialOptions, grpc.WithContextDialer(proxyDialer))
+			if !strings.Contains(endpoint, "://") {
+				// The proxy resolves the endpoint host, and NO_PROXY matches it
+				endpoint = "passthrough:///" + endpoint
 			}
 		}
 	}
 
-	dialOptions = append(dialOptions, grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()))
-	dialOptions = append(dialOptions, grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()))
+	dialOptions = append(dialOptions, grpc.WithKeepaliveParams(config.keepalive))
+	dialOptions = append(dialOptions, grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: config.dialTimeout}))
+	dialOptions = append(dialOptions, grpc.WithDefaultServiceConfig(config.serviceConfig()))
+	unaryInterceptors, streamInterceptors, err := config.interceptors()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dialOptions = append(dialOptions, grpc.WithChainUnaryInterceptor(unaryInterceptors...))
+	dialOptions = append(dialOptions, grpc.WithChainStreamInterceptor(streamInterceptors...))
 
-	zlog.Debug("getting connection", zap.String("endpoint", endpoint))
-	conn, err := dgrpc.NewExternalClient(endpoint, dialOptions...)
+	callOpts, err = config.callOptions()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("unable to create external gRPC client: %w", err)
+		return nil, nil, nil, err
 	}
-	closeFunc = conn.Close
 
-	if !skipAuth {
-		zlog.Debug("creating oauth access", zap.String("endpoint", endpoint))
-		creds := oauth.NewOauthAccess(&oauth2.Token{AccessToken: jwt, TokenType: "Bearer"})
-		callOpts = append(callOpts, grpc.PerRPCCredentials(creds))
+	if config.poolSize > 1 {
+		zlog.Debug("creating connection pool", zap.String("endpoint", endpoint), zap.Int("size", config.poolSize))
+		pool := newConnPool(config.poolSize, config.poolIdleTimeout, func() (*grpc.ClientConn, error) {
+			return dgrpc.NewExternalClient(endpoint, dialOptions...)
+		})
+		cli, closeFunc = pool, pool.Close
+	} else {
+		zlog.Debug("getting connection", zap.String("endpoint", endpoint))
+		conn, err := dgrpc.NewExternalClient(endpoint, dialOptions...)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to create external gRPC client: %w", err)
+		}
+		cli, closeFunc = pbsubstreams.NewStreamClient(conn), conn.Close
 	}
 
-	zlog.Debug("creating new client", zap.String("endpoint", endpoint))
-	cli = pbsubstreams.NewStreamClient(conn)
-	zlog.Debug("client created")
+	zlog.Debug("client created", zap.String("endpoint", endpoint))
 	return
 }
+
+// NewFromConn returns a client calling the server over `conn`, a connection
+// dialed by the caller, like an in-memory one in tests. Only the options
+// applied to the calls are used: the authentication, the compression, wait
+// for ready and the message size limits. The returned function closes `conn`.
+func NewFromConn(conn *grpc.ClientConn, opts ...Option) (cli pbsubstreams.StreamClient, closeFunc func() error, callOpts []grpc.CallOption, err error) {
+	config := NewConfig(conn.Target(), opts...)
+	if err := config.validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	callOpts, err = config.callOptions()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return pbsubstreams.NewStreamClient(conn), conn.Close, callOpts, nil
+}
+
+func (c *SubstreamsClientConfig) validate() error {
+	if c.compression != "" && encoding.GetCompressor(c.compression) == nil {
+		return fmt.Errorf("unknown compression %q, expected %q or %q", c.compression, CompressionGzip, CompressionZstd)
+	}
+	if c.keepalive.Time <= 0 || c.keepalive.Timeout <= 0 {
+		return fmt.Errorf("invalid keepalive time %s and timeout %s, both must be positive", c.keepalive.Time, c.keepalive.Timeout)
+	}
+	if c.dialTimeout <= 0 {
+		return fmt.Errorf("invalid dial timeout %s, must be positive", c.dialTimeout)
+	}
+	if err := c.validateCallOptions(); err != nil {
+		return err
+	}
+	if c.poolSize < 1 {
+		return fmt.Errorf("invalid pool size %d, must be at least 1", c.poolSize)
+	}
+	if c.poolIdleTimeout <= 0 {
+		return fmt.Errorf("invalid pool idle timeout %s, must be positive", c.poolIdleTimeout)
+	}
+	return nil
+}
+
+// callOptions returns the options of every call of the client. The
+// credentials are sent over TLS, and over plaintext only when explicitly
+// allowed by WithUnsafePlaintextAuth.
+func (c *SubstreamsClientConfig) callOptions() (callOpts []grpc.CallOption, err error) {
+	perRPCCredentials, err := c.perRPCCredentials()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case perRPCCredentials == nil:
+	case !c.plaintext:
+		zlog.Debug("creating oauth access", zap.String("endpoint", c.endpoint))
+		callOpts = append(callOpts, grpc.PerRPCCredentials(perRPCCredentials))
+	case c.unsafePlaintextAuth:
+		zlog.Warn("SENDING CREDENTIALS OVER A PLAINTEXT CONNECTION, anyone on the network can read them, only do this against a local development server", zap.String("endpoint", c.endpoint))
+		callOpts = append(callOpts, grpc.PerRPCCredentials(c.plaintextCredentials()))
+	default:
+		zlog.Debug("not sending credentials over a plaintext connection", zap.String("endpoint", c.endpoint))
+	}
+
+	if c.compression != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(c.compression))
+	}
+	callOpts = append(callOpts, grpc.WaitForReady(c.waitForReady))
+	callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(c.maxRecvMsgSize), grpc.MaxCallSendMsgSize(c.maxSendMsgSize))
+	return callOpts, nil
+}
+
+// interceptors returns the interceptors of the calls, outermost first: the
+// tracing ones, then the metrics ones, then the ones altering the calls.
+func (c *SubstreamsClientConfig) interceptors() (unary []grpc.UnaryClientInterceptor, stream []grpc.StreamClientInterceptor, err error) {
+	unary = append(unary, otelgrpc.UnaryClientInterceptor())
+	stream = append(stream, otelgrpc.StreamClientInterceptor())
+
+	if c.metricsRegisterer != nil {
+		metrics, err := newClientMetrics(c.metricsRegisterer, c.endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		unary = append(unary, metrics.unaryInterceptor)
+		stream = append(stream, metrics.streamInterceptor)
+	}
+
+	if c.unaryTimeout != 0 {
+		unary = append(unary, unaryTimeoutInterceptor(c.unaryTimeout))
+	}
+	if c.retryPolicy != nil {
+		stream = append(stream, uncommittedStreamInterceptor)
+	}
+	if len(c.headers) != 0 {
+		unary = append(unary, headersUnaryInterceptor(c.headers))
+		stream = append(stream, headersInterceptor(c.headers))
+	}
+	return unary, stream, nil
+}
+
+// headersInterceptor adds `headers` to the metadata of the streams opened.
+func headersInterceptor(headers metadata.MD) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withHeaders(ctx, headers), desc, cc, method, opts...)
+	}
+}
+
+// headersUnaryInterceptor adds `headers` to the metadata of the unary calls.
+func headersUnaryInterceptor(headers metadata.MD) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withHeaders(ctx, headers), method, req, reply, cc, opts...)
+	}
+}
+
+func withHeaders(ctx context.Context, headers metadata.MD) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, metadata.Join(md, headers))
+}
+
+// headerNames returns the sorted names of the headers, the only part of them
+// which is logged.
+func (c *SubstreamsClientConfig) headerNames() []string {
+	names := make([]string, 0, len(c.headers))
+	for name := range c.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// unreachableEndpoint returns the address of a port nothing listens on.
+func unreachableEndpoint(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	endpoint := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return endpoint
+}
+
+func TestNewSubstreamsClient_WaitForReady(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		expectedCode codes.Code
+	}{
+		{"waits by default", nil, codes.DeadlineExceeded},
+		{"fails fast", []Option{WithWaitForReady(false), WithRetryPolicy(nil)}, codes.Unavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cli, closeFunc, callOpts, err := New(unreachableEndpoint(t), append([]Option{WithPlaintext(true)}, test.opts...)...)
+			require.NoError(t, err)
+			defer closeFunc()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			stream, err := cli.Blocks(ctx, &pbsubstreams.Request{}, callOpts...)
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			require.Error(t, err)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestNewSubstreamsClient_InvalidConnectionOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		opt           Option
+		expectedError string
+	}{
+		{"keepalive time", WithKeepalive(0, time.Second, true), "invalid keepalive time 0s and timeout 1s"},
+		{"keepalive timeout", WithKeepalive(time.Minute, -time.Second, false), "invalid keepalive time 1m0s and timeout -1s"},
+		{"dial timeout", WithDialTimeout(0), "invalid dial timeout 0s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, _, err := New("localhost:9000", WithPlaintext(true), test.opt)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+// headersServer answers a Blocks call with a single response whose cursor
+// lists the values of the `header` of the call.
+type headersServer struct {
+	pbsubstreams.UnimplementedStreamServer
+	header string
+}
+
+func (s headersServer) Blocks(req *pbsubstreams.Request, stream pbsubstreams.Stream_BlocksServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	return stream.Send(&pbsubstreams.Response{Message: &pbsubstreams.Response_Data{Data: &pbsubstreams.BlockScopedData{Cursor: strings.Join(md.Get(s.header), ",")}}})
+}
+
+func TestNew_Headers(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pbsubstreams.RegisterStreamServer(server, headersServer{header: "x-tenant"})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	cli, closeFunc, callOpts, err := New(listener.Addr().String(),
+		WithPlaintext(true),
+		WithHeaders(map[string]string{"x-tenant": "acme"}),
+		WithHeaders(map[string]string{"x-tenant": "globex"}),
+	)
+	require.NoError(t, err)
+	defer closeFunc()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "initech")
+	stream, err := cli.Blocks(ctx, &pbsubstreams.Request{}, callOpts...)
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "initech,acme,globex", resp.GetData().GetCursor())
+}
+
+// breakingHeadersServer is a headersServer whose first stream breaks after
+// its response.
+type breakingHeadersServer struct {
+	headersServer
+	calls int32
+}
+
+func (s *breakingHeadersServer) Blocks(req *pbsubstreams.Request, stream pbsubstreams.Stream_BlocksServer) error {
+	if err := s.headersServer.Blocks(req, stream); err != nil {
+		return err
+	}
+	if atomic.AddInt32(&s.calls, 1) == 1 {
+		return status.Error(codes.Unavailable, "connection reset")
+	}
+	return nil
+}
+
+func TestNewStream_HeadersAfterReconnect(t *testing.T) {
+	endpoint := startServer(t, &breakingHeadersServer{headersServer: headersServer{header: "x-api-key"}})
+	config := NewConfig(endpoint, WithPlaintext(true), WithHeaders(map[string]string{"x-api-key": "secret"}))
+	stream := NewStream(context.Background(), config, &pbsubstreams.Request{}, WithReconnectBackoff(time.Millisecond, time.Millisecond))
+	defer stream.Close()
+
+	cursors, err := receiveAll(stream)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []string{"secret", "secret"}, cursors)
+}
+
+func TestHeadersUnaryInterceptor(t *testing.T) {
+	interceptor := headersUnaryInterceptor(metadata.Pairs("x-api-key", "secret"))
+
+	var md metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "acme")
+	err := interceptor(ctx, "/method", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"secret"}, md.Get("x-api-key"))
+	assert.Equal(t, []string{"acme"}, md.Get("x-tenant"))
+}
+
+func TestSubstreamsClientConfig_HeaderNames(t *testing.T) {
+	config := NewConfig("localhost:9000", WithHeaders(map[string]string{"X-Api-Key": "secret", "x-tenant": "acme"}))
+	assert.Equal(t, []string{"x-api-key", "x-tenant"}, config.headerNames())
+}
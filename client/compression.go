@@ -0,0 +1,78 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
+)
+
+// Compressors selectable with WithCompression. Both are registered in the
+// gRPC encoding registry when this package is imported, so a server importing
+// it decompresses the requests and compresses its responses the same way.
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// maxZstdDecodedSize bounds the memory a single zstd message can decode to,
+// whatever the message size limit of the connection.
+const maxZstdDecodedSize = 1024 * 1024 * 1024
+
+func init() {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		panic(fmt.Errorf("creating zstd encoder: %w", err))
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxZstdDecodedSize))
+	if err != nil {
+		panic(fmt.Errorf("creating zstd decoder: %w", err))
+	}
+	encoding.RegisterCompressor(&zstdCompressor{encoder: encoder, decoder: decoder})
+}
+
+// zstdCompressor compresses whole messages with a single encoder and decoder,
+// whose EncodeAll and DecodeAll are safe for concurrent use.
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (c *zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &zstdWriter{encoder: c.encoder, out: w}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := c.decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decoded), nil
+}
+
+// zstdWriter buffers a message, compressing it on Close.
+type zstdWriter struct {
+	encoder *zstd.Encoder
+	out     io.Writer
+	buf     bytes.Buffer
+}
+
+func (w *zstdWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *zstdWriter) Close() error {
+	_, err := w.out.Write(w.encoder.EncodeAll(w.buf.Bytes(), nil))
+	return err
+}
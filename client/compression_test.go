@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// echoServer answers a Blocks call with a single response carrying the
+// cursor of the request.
+type echoServer struct {
+	pbsubstreams.UnimplementedStreamServer
+}
+
+func (echoServer) Blocks(req *pbsubstreams.Request, stream pbsubstreams.Stream_BlocksServer) error {
+	return stream.Send(&pbsubstreams.Response{Message: &pbsubstreams.Response_Data{Data: &pbsubstreams.BlockScopedData{Cursor: req.StartCursor}}})
+}
+
+// wireStats records the compressor requested by the client and the sizes of
+// the responses sent by the server.
+type wireStats struct {
+	lock        sync.Mutex
+	compression string
+	length      int
+	wireLength  int
+}
+
+func (s *wireStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context   { return ctx }
+func (s *wireStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context { return ctx }
+func (s *wireStats) HandleConn(context.Context, stats.ConnStats)                       {}
+
+func (s *wireStats) HandleRPC(_ context.Context, rs stats.RPCStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch rs := rs.(type) {
+	case *stats.InHeader:
+		s.compression = rs.Compression
+	case *stats.OutPayload:
+		s.length += rs.Length
+		s.wireLength += rs.WireLength
+	}
+}
+
+func startEchoServer(t *testing.T) (string, *wireStats) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ws := &wireStats{}
+	server := grpc.NewServer(grpc.StatsHandler(ws))
+	pbsubstreams.RegisterStreamServer(server, echoServer{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), ws
+}
+
+func TestNewSubstreamsClient_Compression(t *testing.T) {
+	cursor := strings.Repeat("highly compressible block scoped data ", 10_000)
+
+	tests := []struct {
+		name        string
+		compression string
+		compressed  bool
+	}{
+		{"off by default", "", false},
+		{"gzip", CompressionGzip, true},
+		{"zstd", CompressionZstd, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint, ws := startEchoServer(t)
+			cli, closeFunc, callOpts, err := New(endpoint, WithPlaintext(true), WithCompression(test.compression))
+			require.NoError(t, err)
+			defer closeFunc()
+
+			stream, err := cli.Blocks(context.Background(), &pbsubstreams.Request{StartCursor: cursor}, callOpts...)
+			require.NoError(t, err)
+			resp, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, cursor, resp.GetData().GetCursor())
+			_, err = stream.Recv()
+			assert.Equal(t, io.EOF, err)
+
+			ws.lock.Lock()
+			defer ws.lock.Unlock()
+			assert.Equal(t, test.compression, ws.compression)
+			if test.compressed {
+				assert.Less(t, ws.wireLength, ws.length/10, "response sent compressed")
+			} else {
+				assert.Greater(t, ws.wireLength, ws.length, "response sent uncompressed")
+			}
+		})
+	}
+}
+
+func TestNewSubstreamsClient_UnknownCompression(t *testing.T) {
+	_, _, _, err := New("localhost:9000", WithPlaintext(true), WithCompression("brotli"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown compression "brotli"`)
+}
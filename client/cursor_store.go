@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CursorStore persists the cursor of a stream, so a consumer resumes where
+// it left off after a restart. Sinks writing to a database implement it on
+// top of that database, saving the cursor in the same transaction as the
+// outputs of the block to never process a block twice.
+type CursorStore interface {
+	// Load returns the cursor saved last, empty when none was.
+	Load(ctx context.Context) (string, error)
+	Save(ctx context.Context, cursor string) error
+}
+
+// FileCursorStore keeps the cursor in a file, replaced as a whole on each
+// save so a crash never leaves a partially written cursor behind.
+type FileCursorStore struct {
+	path string
+	lock sync.Mutex
+}
+
+func NewFileCursorStore(path string) *FileCursorStore {
+	return &FileCursorStore{path: path}
+}
+
+func (s *FileCursorStore) Load(ctx context.Context) (string, error) {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("reading cursor file: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Save writes `cursor` to a temporary file next to the cursor file, then
+// renames it over the cursor file.
+func (s *FileCursorStore) Save(ctx context.Context, cursor string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary cursor file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(cursor); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temporary cursor file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing temporary cursor file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary cursor file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("replacing cursor file: %w", err)
+	}
+	return nil
+}
+
+// MemoryCursorStore keeps the cursor in memory, for tests.
+type MemoryCursorStore struct {
+	lock   sync.Mutex
+	cursor string
+	saves  int
+}
+
+func NewMemoryCursorStore(cursor string) *MemoryCursorStore {
+	return &MemoryCursorStore{cursor: cursor}
+}
+
+func (s *MemoryCursorStore) Load(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cursor, nil
+}
+
+func (s *MemoryCursorStore) Save(ctx context.Context, cursor string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cursor = cursor
+	s.saves++
+	return nil
+}
+
+// Saves returns the number of saves.
+func (s *MemoryCursorStore) Saves() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.saves
+}
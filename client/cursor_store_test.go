@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCursorStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewFileCursorStore(filepath.Join(dir, "cursor"))
+
+	cursor, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "", cursor, "no cursor saved yet")
+
+	require.NoError(t, store.Save(ctx, "c1"))
+	cursor, err = store.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "c1", cursor)
+
+	saved := map[string]bool{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		saved[fmt.Sprintf("c%d", i)] = true
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, store.Save(ctx, fmt.Sprintf("c%d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	cursor, err = store.Load(ctx)
+	require.NoError(t, err)
+	assert.True(t, saved[cursor], "one of the cursors saved concurrently, got %q", cursor)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary files removed")
+	assert.Equal(t, "cursor", entries[0].Name())
+}
+
+func TestFileCursorStore_SaveError(t *testing.T) {
+	store := NewFileCursorStore(filepath.Join(t.TempDir(), "missing", "cursor"))
+	err := store.Save(context.Background(), "c1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "creating temporary cursor file")
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// endpointSchemes are the gRPC resolvers an endpoint can name.
+var endpointSchemes = []string{"dns", "passthrough", "unix", "xds"}
+
+// WithDNSLoadBalancing resolves the endpoint with the `dns:///` resolver, so
+// the streams are spread over all the addresses of its host instead of
+// going to the first one. The resolution is done again when a connection
+// fails. Only applies to `host:port` and `dns:///` endpoints.
+func WithDNSLoadBalancing(enabled bool) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.dnsLoadBalancing = enabled
+	}
+}
+
+// normalizeEndpoint checks `endpoint` and returns the target to dial, and
+// whether it is a unix socket, as `unix:///absolute/path` or
+// `unix:relative/path`. The endpoint is either a target of one of the
+// endpointSchemes, or a `host:port` address, which can be written as an
+// `https://` or `http://` URL, and whose port defaults to `defaultPort`,
+// when not empty.
+func normalizeEndpoint(endpoint string, defaultPort string) (target string, unixSocket bool, err error) {
+	if strings.ContainsAny(endpoint, " \t\r\n") {
+		return "", false, fmt.Errorf("invalid endpoint %q: contains spaces", endpoint)
+	}
+
+	scheme, rest, hasScheme := strings.Cut(endpoint, "://")
+	if !hasScheme {
+		if path := strings.TrimPrefix(endpoint, "unix:"); path != endpoint {
+			if path == "" {
+				return "", false, fmt.Errorf("invalid endpoint %q: empty unix socket path", endpoint)
+			}
+			return endpoint, true, nil
+		}
+		target, err := normalizeHostPort(endpoint, endpoint, defaultPort)
+		return target, false, err
+	}
+
+	switch scheme {
+	case "unix":
+		if rest == "" || rest == "/" {
+			return "", false, fmt.Errorf("invalid endpoint %q: empty unix socket path", endpoint)
+		}
+		return endpoint, true, nil
+	case "dns", "passthrough", "xds":
+		if strings.Trim(rest, "/") == "" {
+			return "", false, invalidEndpointError(endpoint)
+		}
+		return endpoint, false, nil
+	case "https", "http":
+		hostPort := strings.TrimSuffix(rest, "/")
+		if strings.Contains(hostPort, "/") {
+			return "", false, fmt.Errorf("invalid endpoint %q: a gRPC endpoint has no path", endpoint)
+		}
+		target, err := normalizeHostPort(endpoint, hostPort, defaultPort)
+		return target, false, err
+	}
+	return "", false, invalidEndpointError(endpoint)
+}
+
+func normalizeHostPort(endpoint, hostPort, defaultPort string) (string, error) {
+	if !hasPort(hostPort) {
+		if defaultPort == "" {
+			return "", fmt.Errorf("invalid endpoint %q: missing port, a plaintext endpoint has no default one", endpoint)
+		}
+		hostPort = net.JoinHostPort(strings.Trim(hostPort, "[]"), defaultPort)
+	}
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || host == "" {
+		return "", invalidEndpointError(endpoint)
+	}
+	if number, err := strconv.ParseUint(port, 10, 16); err != nil || number == 0 {
+		return "", fmt.Errorf("invalid endpoint %q: invalid port %q", endpoint, port)
+	}
+	return hostPort, nil
+}
+
+// hasPort tells if `hostPort` ends with a port, IPv6 hosts being bracketed.
+func hasPort(hostPort string) bool {
+	afterHost := hostPort[strings.LastIndex(hostPort, "]")+1:]
+	return strings.Contains(afterHost, ":")
+}
+
+func invalidEndpointError(endpoint string) error {
+	return fmt.Errorf("invalid endpoint %q, expected host:port or a target with one of the %s schemes, like unix:///var/run/substreams.sock", endpoint, strings.Join(endpointSchemes, ", "))
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint       string
+		defaultPort    string
+		expectedTarget string
+		expectedUnix   bool
+		expectedError  string
+	}{
+		{"api.streamingfast.io:443", "443", "api.streamingfast.io:443", false, ""},
+		{"127.0.0.1:9000", "", "127.0.0.1:9000", false, ""},
+		{"[::1]:9000", "", "[::1]:9000", false, ""},
+		{"api.streamingfast.io", "443", "api.streamingfast.io:443", false, ""},
+		{"[::1]", "443", "[::1]:443", false, ""},
+		{"https://api.streamingfast.io", "443", "api.streamingfast.io:443", false, ""},
+		{"https://api.streamingfast.io:9000/", "443", "api.streamingfast.io:9000", false, ""},
+		{"http://localhost:9000", "", "localhost:9000", false, ""},
+		{"dns:///api.streamingfast.io:443", "443", "dns:///api.streamingfast.io:443", false, ""},
+		{"passthrough:///10.0.0.1:9000", "443", "passthrough:///10.0.0.1:9000", false, ""},
+		{"xds:///substreams", "443", "xds:///substreams", false, ""},
+		{"unix:///var/run/substreams.sock", "", "unix:///var/run/substreams.sock", true, ""},
+		{"unix:substreams.sock", "", "unix:substreams.sock", true, ""},
+		{"api.streamingfast.io", "", "", false, `invalid endpoint "api.streamingfast.io": missing port, a plaintext endpoint has no default one`},
+		{"api.streamingfast.io :443", "443", "", false, "contains spaces"},
+		{"api.streamingfast.io:https", "443", "", false, `invalid port "https"`},
+		{"api.streamingfast.io:0", "443", "", false, `invalid port "0"`},
+		{"https://api.streamingfast.io/v1", "443", "", false, "a gRPC endpoint has no path"},
+		{":9000", "443", "", false, "expected host:port"},
+		{"", "443", "", false, "expected host:port"},
+		{"grpcs://api.streamingfast.io:443", "443", "", false, "expected host:port or a target with one of the dns, passthrough, unix, xds schemes"},
+		{"dns:///", "443", "", false, "expected host:port"},
+		{"unix://", "", "", false, "empty unix socket path"},
+		{"unix:", "", "", false, "empty unix socket path"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			target, unixSocket, err := normalizeEndpoint(test.endpoint, test.defaultPort)
+			if test.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedTarget, target)
+			assert.Equal(t, test.expectedUnix, unixSocket)
+		})
+	}
+}
+
+func TestNew_DNSLoadBalancing(t *testing.T) {
+	endpoint := startServer(t, echoServer{})
+	_, port, err := net.SplitHostPort(endpoint)
+	require.NoError(t, err)
+
+	cursor, err := firstCursor(t, "localhost:"+port, "c1", WithDNSLoadBalancing(true))
+	require.NoError(t, err)
+	assert.Equal(t, "c1", cursor)
+
+	_, _, _, err = New("unix:///var/run/substreams.sock", WithDNSLoadBalancing(true))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `DNS load balancing only applies to host:port and dns:/// endpoints, not "unix:///var/run/substreams.sock"`)
+}
+
+func TestNew_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "substreams.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pbsubstreams.RegisterStreamServer(server, headersServer{header: "authorization"})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	for _, endpoint := range []string{"unix://" + path, "unix:" + path} {
+		cli, closeFunc, callOpts, err := New(endpoint, WithJWT("jwt"))
+		require.NoError(t, err)
+
+		stream, err := cli.Blocks(context.Background(), &pbsubstreams.Request{}, callOpts...)
+		require.NoError(t, err)
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer jwt", resp.GetData().GetCursor(), "authenticated without TLS")
+		require.NoError(t, closeFunc())
+	}
+
+	_, _, _, err = New("unix://"+path, WithInsecureTLS(true))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a unix socket connection does not use TLS")
+}
+
+func TestNewFromConn(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	pbsubstreams.RegisterStreamServer(server, echoServer{})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	cli, closeFunc, callOpts, err := NewFromConn(conn, WithCompression(CompressionZstd))
+	require.NoError(t, err)
+	defer closeFunc()
+
+	stream, err := cli.Blocks(context.Background(), &pbsubstreams.Request{StartCursor: "c1"}, callOpts...)
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "c1", resp.GetData().GetCursor())
+
+	_, _, _, err = NewFromConn(conn, WithCompression("brotli"))
+	require.Error(t, err)
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"google.golang.org/grpc/status"
+)
+
+// PanicDetail returns the details of the panic of the module that failed the
+// stream with `err`, as received from the server. It returns false when the
+// stream did not fail because a module panicked.
+func PanicDetail(err error) (*pbsubstreams.PanicDetail, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+	for _, detail := range st.Details() {
+		if panicDetail, ok := detail.(*pbsubstreams.PanicDetail); ok {
+			return panicDetail, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithMetrics records the messages received by the client, their bytes, the
+// time to the first block and the duration of the streams as Prometheus
+// metrics labeled by endpoint, registered to `registerer`. Clients sharing a
+// registerer share the metrics.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.metricsRegisterer = registerer
+	}
+}
+
+type clientMetrics struct {
+	messagesReceived *prometheus.CounterVec
+	bytesReceived    *prometheus.CounterVec
+	timeToFirstBlock *prometheus.HistogramVec
+	streamDuration   *prometheus.HistogramVec
+
+	endpoint string
+}
+
+func newClientMetrics(registerer prometheus.Registerer, endpoint string) (*clientMetrics, error) {
+	m := &clientMetrics{endpoint: endpoint}
+
+	messagesReceived, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "substreams_client_messages_received",
+		Help: "Messages received by the client, streamed or unary replies, per endpoint",
+	}, []string{"endpoint"}))
+	if err != nil {
+		return nil, err
+	}
+	m.messagesReceived = messagesReceived.(*prometheus.CounterVec)
+
+	bytesReceived, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "substreams_client_bytes_received",
+		Help: "Bytes of the messages received by the client, once decompressed, per endpoint",
+	}, []string{"endpoint"}))
+	if err != nil {
+		return nil, err
+	}
+	m.bytesReceived = bytesReceived.(*prometheus.CounterVec)
+
+	timeToFirstBlock, err := registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "substreams_client_time_to_first_block_seconds",
+		Help:    "Time between the opening of a stream and its first block, per endpoint",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"endpoint"}))
+	if err != nil {
+		return nil, err
+	}
+	m.timeToFirstBlock = timeToFirstBlock.(*prometheus.HistogramVec)
+
+	streamDuration, err := registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "substreams_client_stream_duration_seconds",
+		Help:    "Time between the opening of a stream and its end, failed or not, per endpoint",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 16),
+	}, []string{"endpoint"}))
+	if err != nil {
+		return nil, err
+	}
+	m.streamDuration = streamDuration.(*prometheus.HistogramVec)
+
+	return m, nil
+}
+
+// registerCollector registers `collector`, returning the one already
+// registered under the same name instead, if any.
+func registerCollector(registerer prometheus.Registerer, collector prometheus.Collector) (prometheus.Collector, error) {
+	if err := registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			return alreadyRegistered.ExistingCollector, nil
+		}
+		return nil, fmt.Errorf("registering client metrics: %w", err)
+	}
+	return collector, nil
+}
+
+func (m *clientMetrics) observeMessage(msg interface{}) {
+	m.messagesReceived.WithLabelValues(m.endpoint).Inc()
+	if protoMsg, ok := msg.(proto.Message); ok {
+		m.bytesReceived.WithLabelValues(m.endpoint).Add(float64(proto.Size(protoMsg)))
+	}
+}
+
+func (m *clientMetrics) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+		return err
+	}
+	m.observeMessage(reply)
+	return nil
+}
+
+func (m *clientMetrics) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		m.streamDuration.WithLabelValues(m.endpoint).Observe(time.Since(start).Seconds())
+		return nil, err
+	}
+	return &measuredStream{ClientStream: stream, metrics: m, start: start}, nil
+}
+
+// measuredStream records the metrics of the messages it receives, and its
+// duration once it fails or ends.
+type measuredStream struct {
+	grpc.ClientStream
+	metrics *clientMetrics
+	start   time.Time
+
+	firstBlock bool
+	endOnce    sync.Once
+}
+
+func (s *measuredStream) RecvMsg(msg interface{}) error {
+	if err := s.ClientStream.RecvMsg(msg); err != nil {
+		s.endOnce.Do(func() {
+			s.metrics.streamDuration.WithLabelValues(s.metrics.endpoint).Observe(time.Since(s.start).Seconds())
+		})
+		return err
+	}
+
+	s.metrics.observeMessage(msg)
+	if resp, ok := msg.(*pbsubstreams.Response); ok && !s.firstBlock && resp.GetData() != nil {
+		s.firstBlock = true
+		s.metrics.timeToFirstBlock.WithLabelValues(s.metrics.endpoint).Observe(time.Since(s.start).Seconds())
+	}
+	return nil
+}
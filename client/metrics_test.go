@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func sampleCount(t *testing.T, histogram *prometheus.HistogramVec, endpoint string) uint64 {
+	metric := &dto.Metric{}
+	require.NoError(t, histogram.WithLabelValues(endpoint).(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestNew_Metrics(t *testing.T) {
+	endpoint, _ := startEchoServer(t)
+	registry := prometheus.NewRegistry()
+
+	for i := 0; i < 2; i++ {
+		cli, closeFunc, callOpts, err := New(endpoint, WithPlaintext(true), WithMetrics(registry))
+		require.NoError(t, err)
+		defer closeFunc()
+
+		stream, err := cli.Blocks(context.Background(), &pbsubstreams.Request{StartCursor: "c1"}, callOpts...)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, io.EOF, err)
+	}
+
+	metrics, err := newClientMetrics(registry, endpoint)
+	require.NoError(t, err, "registered metrics reused")
+
+	response := &pbsubstreams.Response{Message: &pbsubstreams.Response_Data{Data: &pbsubstreams.BlockScopedData{Cursor: "c1"}}}
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.messagesReceived.WithLabelValues(endpoint)))
+	assert.Equal(t, float64(2*proto.Size(response)), testutil.ToFloat64(metrics.bytesReceived.WithLabelValues(endpoint)))
+	assert.Equal(t, uint64(2), sampleCount(t, metrics.timeToFirstBlock, endpoint))
+	assert.Equal(t, uint64(2), sampleCount(t, metrics.streamDuration, endpoint))
+}
+
+func funcName(f interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func TestSubstreamsClientConfig_Interceptors(t *testing.T) {
+	config := NewConfig("localhost:9000",
+		WithMetrics(prometheus.NewRegistry()),
+		WithUnaryTimeout(time.Second),
+		WithHeaders(map[string]string{"x-trace": "1"}),
+	)
+
+	unary, stream, err := config.interceptors()
+	require.NoError(t, err)
+
+	var unaryNames, streamNames []string
+	for _, interceptor := range unary {
+		unaryNames = append(unaryNames, funcName(interceptor))
+	}
+	for _, interceptor := range stream {
+		streamNames = append(streamNames, funcName(interceptor))
+	}
+	assert.Equal(t, []string{
+		"otelgrpc.UnaryClientInterceptor.func1",
+		"client.(*clientMetrics).unaryInterceptor-fm",
+		"client.unaryTimeoutInterceptor.func1",
+		"client.headersUnaryInterceptor.func1",
+	}, unaryNames)
+	assert.Equal(t, []string{
+		"otelgrpc.StreamClientInterceptor.func1",
+		"client.(*clientMetrics).streamInterceptor-fm",
+		"client.uncommittedStreamInterceptor",
+		"client.headersInterceptor.func1",
+	}, streamNames)
+}
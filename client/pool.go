@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// DefaultPoolIdleTimeout is how long a pooled connection without any stream
+// is kept open.
+const DefaultPoolIdleTimeout = 5 * time.Minute
+
+// WithPoolSize spreads the streams of the client over up to `size`
+// connections to the server, instead of multiplexing them all over a single
+// one, bounded by the concurrent streams limit of HTTP/2 and blocked by a
+// single slow TCP connection. Each stream goes to the healthy connection
+// serving the least streams. Defaults to 1, a single connection.
+func WithPoolSize(size int) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.poolSize = size
+	}
+}
+
+// WithPoolIdleTimeout closes the pooled connections left without any stream
+// for `timeout`, they are dialed again when needed. Defaults to
+// DefaultPoolIdleTimeout.
+func WithPoolIdleTimeout(timeout time.Duration) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.poolIdleTimeout = timeout
+	}
+}
+
+// connPool is a StreamClient opening each stream on the least busy of its
+// connections, dialed lazily.
+type connPool struct {
+	dial        func() (*grpc.ClientConn, error)
+	idleTimeout time.Duration
+
+	lock   sync.Mutex
+	conns  []*pooledConn
+	next   int // first connection considered, rotated to break ties
+	closed bool
+
+	stopReaper chan struct{}
+}
+
+type pooledConn struct {
+	conn     *grpc.ClientConn // nil until dialed, and once reaped
+	streams  int
+	lastUsed time.Time
+}
+
+func newConnPool(size int, idleTimeout time.Duration, dial func() (*grpc.ClientConn, error)) *connPool {
+	p := &connPool{
+		dial:        dial,
+		idleTimeout: idleTimeout,
+		conns:       make([]*pooledConn, size),
+		stopReaper:  make(chan struct{}),
+	}
+	for i := range p.conns {
+		p.conns[i] = &pooledConn{}
+	}
+	go p.reapIdle()
+	return p
+}
+
+func (p *connPool) Blocks(ctx context.Context, in *pbsubstreams.Request, opts ...grpc.CallOption) (pbsubstreams.Stream_BlocksClient, error) {
+	pc, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := pbsubstreams.NewStreamClient(pc.conn).Blocks(ctx, in, opts...)
+	if err != nil {
+		p.release(pc)
+		return nil, err
+	}
+
+	s := &pooledStream{Stream_BlocksClient: stream, done: make(chan struct{})}
+	s.release = func() {
+		s.releaseOnce.Do(func() {
+			close(s.done)
+			p.release(pc)
+		})
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.release()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// acquire returns the connection serving the next stream, dialing it if
+// needed: the healthy one serving the least streams.
+func (p *connPool) acquire() (*pooledConn, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil, fmt.Errorf("connection pool closed")
+	}
+
+	var best *pooledConn
+	bestHealthy := false
+	for i := range p.conns {
+		pc := p.conns[(p.next+i)%len(p.conns)]
+		healthy := pc.healthy()
+		if best == nil || healthy && !bestHealthy || healthy == bestHealthy && pc.streams < best.streams {
+			best, bestHealthy = pc, healthy
+		}
+	}
+	p.next = (p.next + 1) % len(p.conns)
+
+	if best.conn == nil {
+		conn, err := p.dial()
+		if err != nil {
+			return nil, fmt.Errorf("dialing pooled connection: %w", err)
+		}
+		best.conn = conn
+	}
+	best.streams++
+	best.lastUsed = time.Now()
+	return best, nil
+}
+
+func (p *connPool) release(pc *pooledConn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	pc.streams--
+	pc.lastUsed = time.Now()
+}
+
+// healthy returns whether the connection can serve a stream: not dialed yet,
+// or not failing to reach the server.
+func (pc *pooledConn) healthy() bool {
+	if pc.conn == nil {
+		return true
+	}
+	state := pc.conn.GetState()
+	return state != connectivity.TransientFailure && state != connectivity.Shutdown
+}
+
+func (p *connPool) reapIdle() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopReaper:
+			return
+		case <-ticker.C:
+			p.closeIdle(time.Now().Add(-p.idleTimeout))
+		}
+	}
+}
+
+// closeIdle closes the connections without any stream since `before`.
+func (p *connPool) closeIdle(before time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, pc := range p.conns {
+		if pc.conn == nil || pc.streams != 0 || pc.lastUsed.After(before) {
+			continue
+		}
+		zlog.Debug("closing idle pooled connection", zap.String("target", pc.conn.Target()))
+		if err := pc.conn.Close(); err != nil {
+			zlog.Debug("closing idle pooled connection", zap.Error(err))
+		}
+		pc.conn = nil
+	}
+}
+
+// Close closes all the connections of the pool.
+func (p *connPool) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.stopReaper)
+
+	var firstErr error
+	for _, pc := range p.conns {
+		if pc.conn == nil {
+			continue
+		}
+		if err := pc.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		pc.conn = nil
+	}
+	return firstErr
+}
+
+// pooledStream gives its connection back to the pool once over: when it
+// fails or ends, or when its context is done.
+type pooledStream struct {
+	pbsubstreams.Stream_BlocksClient
+
+	done        chan struct{}
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (s *pooledStream) Recv() (*pbsubstreams.Response, error) {
+	resp, err := s.Stream_BlocksClient.Recv()
+	if err != nil {
+		s.release()
+	}
+	return resp, err
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/peer"
+)
+
+// peerServer answers a Blocks call with a response whose cursor is the
+// address of the client connection, then holds the stream until the client
+// is done with it.
+type peerServer struct {
+	pbsubstreams.UnimplementedStreamServer
+}
+
+func (peerServer) Blocks(req *pbsubstreams.Request, stream pbsubstreams.Stream_BlocksServer) error {
+	p, _ := peer.FromContext(stream.Context())
+	if err := stream.Send(&pbsubstreams.Response{Message: &pbsubstreams.Response_Data{Data: &pbsubstreams.BlockScopedData{Cursor: p.Addr.String()}}}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+// openStream opens a stream, returning the address of the connection it was
+// opened on, and the function ending it.
+func openStream(t *testing.T, cli pbsubstreams.StreamClient) (string, context.CancelFunc) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := cli.Blocks(ctx, &pbsubstreams.Request{})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	return resp.GetData().GetCursor(), cancel
+}
+
+func connCount(pool *connPool) (count int) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for _, pc := range pool.conns {
+		if pc.conn != nil {
+			count++
+		}
+	}
+	return count
+}
+
+func streamCount(pool *connPool) (count int) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for _, pc := range pool.conns {
+		count += pc.streams
+	}
+	return count
+}
+
+func TestNew_PoolSpreadsStreams(t *testing.T) {
+	endpoint := startServer(t, peerServer{})
+	cli, closeFunc, _, err := New(endpoint, WithPlaintext(true), WithPoolSize(3))
+	require.NoError(t, err)
+	defer closeFunc()
+	pool := cli.(*connPool)
+
+	addrs := map[string]context.CancelFunc{}
+	for i := 0; i < 3; i++ {
+		addr, cancel := openStream(t, cli)
+		addrs[addr] = cancel
+	}
+	assert.Len(t, addrs, 3, "one stream per connection")
+	assert.Equal(t, 3, connCount(pool))
+
+	// The next stream goes to the connection freed
+	var freed string
+	for addr, cancel := range addrs {
+		freed = addr
+		cancel()
+		break
+	}
+	require.Eventually(t, func() bool { return streamCount(pool) == 2 }, time.Second, time.Millisecond)
+	addr, cancel := openStream(t, cli)
+	defer cancel()
+	assert.Equal(t, freed, addr)
+
+	for _, cancel := range addrs {
+		cancel()
+	}
+}
+
+func TestNew_PoolSingleConnectionByDefault(t *testing.T) {
+	endpoint := startServer(t, peerServer{})
+	cli, closeFunc, _, err := New(endpoint, WithPlaintext(true))
+	require.NoError(t, err)
+	defer closeFunc()
+
+	_, pooled := cli.(*connPool)
+	assert.False(t, pooled)
+
+	first, cancelFirst := openStream(t, cli)
+	defer cancelFirst()
+	second, cancelSecond := openStream(t, cli)
+	defer cancelSecond()
+	assert.Equal(t, first, second)
+}
+
+func TestNew_PoolReapsIdleConnections(t *testing.T) {
+	endpoint := startServer(t, peerServer{})
+	cli, closeFunc, _, err := New(endpoint, WithPlaintext(true), WithPoolSize(2), WithPoolIdleTimeout(20*time.Millisecond))
+	require.NoError(t, err)
+	defer closeFunc()
+	pool := cli.(*connPool)
+
+	_, cancelIdle := openStream(t, cli)
+	_, cancelActive := openStream(t, cli)
+	defer cancelActive()
+	assert.Equal(t, 2, connCount(pool))
+
+	cancelIdle()
+	require.Eventually(t, func() bool { return connCount(pool) == 1 }, time.Second, 5*time.Millisecond, "idle connection reaped")
+
+	// Dialed again when needed
+	_, cancel := openStream(t, cli)
+	defer cancel()
+	assert.Equal(t, 2, connCount(pool))
+}
+
+func TestNew_PoolClosed(t *testing.T) {
+	endpoint := startServer(t, peerServer{})
+	cli, closeFunc, _, err := New(endpoint, WithPlaintext(true), WithPoolSize(2))
+	require.NoError(t, err)
+	require.NoError(t, closeFunc())
+
+	_, err = cli.Blocks(context.Background(), &pbsubstreams.Request{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection pool closed")
+}
+
+func TestNew_InvalidPoolOptions(t *testing.T) {
+	_, _, _, err := New("localhost:9000", WithPlaintext(true), WithPoolSize(0))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid pool size 0")
+
+	_, _, _, err = New("localhost:9000", WithPlaintext(true), WithPoolIdleTimeout(0))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid pool idle timeout 0s")
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectProxy is a minimal HTTP CONNECT proxy tunneling every connection to
+// `backend`, whatever the requested target, and recording the targets.
+type connectProxy struct {
+	backend       string
+	authorization string // Proxy-Authorization required, if set
+
+	lock    sync.Mutex
+	targets []string
+}
+
+func startConnectProxy(t *testing.T, backend string, authorization string) (string, *connectProxy) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	p := &connectProxy{backend: backend, authorization: authorization}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return listener.Addr().String(), p
+}
+
+func (p *connectProxy) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil || req.Method != http.MethodConnect {
+		return
+	}
+	if p.authorization != "" && req.Header.Get("Proxy-Authorization") != p.authorization {
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return
+	}
+	p.lock.Lock()
+	p.targets = append(p.targets, req.Host)
+	p.lock.Unlock()
+
+	backend, err := net.Dial("tcp", p.backend)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer backend.Close()
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	go io.Copy(backend, reader)
+	io.Copy(conn, backend)
+}
+
+func (p *connectProxy) tunneled() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+func clearProxyEnv(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestNew_ProxyURL(t *testing.T) {
+	clearProxyEnv(t)
+	backend, _ := startEchoServer(t)
+	authorization := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	proxyAddr, proxy := startConnectProxy(t, backend, authorization)
+
+	cursor, err := firstCursor(t, "substreams.test:443", "c1", WithProxyURL("http://user:secret@"+proxyAddr))
+	require.NoError(t, err)
+	assert.Equal(t, "c1", cursor)
+	assert.Equal(t, []string{"substreams.test:443"}, proxy.tunneled(), "endpoint resolved by the proxy")
+
+	_, err = firstCursor(t, "substreams.test:443", "c1", WithProxyURL("http://user:wrong@"+proxyAddr), WithWaitForReady(false), WithRetryPolicy(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "407 Proxy Authentication Required")
+}
+
+func TestNew_ProxyFromEnvironment(t *testing.T) {
+	clearProxyEnv(t)
+	backend, _ := startEchoServer(t)
+	proxyAddr, proxy := startConnectProxy(t, backend, "")
+	t.Setenv("HTTPS_PROXY", "http://"+proxyAddr)
+
+	cursor, err := firstCursor(t, "substreams.test:443", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, "c1", cursor)
+	assert.Equal(t, []string{"substreams.test:443"}, proxy.tunneled())
+
+	// Local endpoints are never proxied
+	cursor, err = firstCursor(t, backend, "c2")
+	require.NoError(t, err)
+	assert.Equal(t, "c2", cursor)
+	assert.Len(t, proxy.tunneled(), 1)
+}
+
+func TestNew_InvalidProxyOptions(t *testing.T) {
+	clearProxyEnv(t)
+
+	_, _, _, err := New("substreams.test:443", WithProxyURL("socks5://proxy.corp:1080"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid proxy URL "socks5://proxy.corp:1080", expected http://host:port or https://host:port`)
+
+	_, _, _, err = New("unix:///var/run/substreams.sock", WithProxyURL("http://proxy.corp:3128"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a unix socket connection cannot go through a proxy")
+}
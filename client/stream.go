@@ -0,0 +1,313 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultMaxReconnectAttempts = 5
+	defaultInitialBackoff       = 500 * time.Millisecond
+	defaultMaxBackoff           = 30 * time.Second
+)
+
+// Stream is a Blocks stream which survives transient failures: when the
+// connection to the server breaks, it dials it again and resumes the stream
+// from the cursor of the last block received, so no block is lost or
+// received twice. Other failures, like an invalid request or a module
+// failing deterministically, are returned right away. When the client
+// authenticates with a token source, an `Unauthenticated` error also makes it
+// reconnect, the new call getting a fresh token.
+//
+// With a cursor store, the stream starts from the cursor stored, and saves
+// the cursor of each response once handled, which is when Recv is called
+// again.
+type Stream struct {
+	ctx     context.Context
+	dial    func() (pbsubstreams.StreamClient, func() error, []grpc.CallOption, error)
+	request *pbsubstreams.Request
+
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	onReconnect    func(attempt int, cursor string, err error)
+
+	// retryUnauthenticated reconnects on `Unauthenticated` errors, the
+	// token being fetched again for the new call.
+	retryUnauthenticated bool
+
+	cursorStore   CursorStore
+	saveInterval  time.Duration
+	cursorLoaded  bool
+	handledCursor string // cursor of the last response handled
+	savedCursor   string
+	lastSave      time.Time
+
+	closeConn func() error // nil when not connected
+	stream    pbsubstreams.Stream_BlocksClient
+	cursor    string
+	attempts  int // reconnections since the last response received
+}
+
+type StreamOption func(s *Stream)
+
+// WithMaxReconnectAttempts sets the number of reconnections attempted in a
+// row, without receiving anything in between, before giving up. Defaults to 5.
+func WithMaxReconnectAttempts(attempts int) StreamOption {
+	return func(s *Stream) {
+		s.maxAttempts = attempts
+	}
+}
+
+// WithReconnectBackoff sets the delay before the first reconnection attempt,
+// doubled on each attempt up to `max`. Defaults to 500ms and 30s.
+func WithReconnectBackoff(initial, max time.Duration) StreamOption {
+	return func(s *Stream) {
+		s.initialBackoff = initial
+		s.maxBackoff = max
+	}
+}
+
+// WithOnReconnect calls `f` before each reconnection attempt, with the error
+// which broke the stream and the cursor it resumes from, empty when no block
+// was received yet.
+func WithOnReconnect(f func(attempt int, cursor string, err error)) StreamOption {
+	return func(s *Stream) {
+		s.onReconnect = f
+	}
+}
+
+// WithCursorStore starts the stream from the cursor of `store`, instead of
+// the one of the request when a cursor is stored, and saves the cursor of
+// the responses handled to it.
+func WithCursorStore(store CursorStore) StreamOption {
+	return func(s *Stream) {
+		s.cursorStore = store
+	}
+}
+
+// WithCursorSaveInterval saves the cursor to the cursor store at most once
+// per `interval`, instead of after each response handled. The last cursor
+// handled is always saved when the stream ends or is closed.
+func WithCursorSaveInterval(interval time.Duration) StreamOption {
+	return func(s *Stream) {
+		s.saveInterval = interval
+	}
+}
+
+// NewStream returns a stream of the responses to `request`, connected to the
+// server of `config`. Nothing is sent until the first call to Recv. The stream
+// owns its connection, Close releases it.
+func NewStream(ctx context.Context, config *SubstreamsClientConfig, request *pbsubstreams.Request, opts ...StreamOption) *Stream {
+	dial := func() (pbsubstreams.StreamClient, func() error, []grpc.CallOption, error) {
+		return NewSubstreamsClient(config)
+	}
+	s := newStream(ctx, dial, request, opts...)
+	s.retryUnauthenticated = config.refreshableToken()
+	return s
+}
+
+func newStream(ctx context.Context, dial func() (pbsubstreams.StreamClient, func() error, []grpc.CallOption, error), request *pbsubstreams.Request, opts ...StreamOption) *Stream {
+	s := &Stream{
+		ctx:            ctx,
+		dial:           dial,
+		request:        request,
+		maxAttempts:    defaultMaxReconnectAttempts,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+		cursor:         request.StartCursor,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Recv returns the next response of the stream, reconnecting when needed. It
+// returns io.EOF once the stream is over. Calling it marks the previous
+// response as handled.
+func (s *Stream) Recv() (*pbsubstreams.Response, error) {
+	if err := s.loadCursor(); err != nil {
+		return nil, err
+	}
+	s.handledCursor = s.cursor
+	if err := s.saveCursor(false); err != nil {
+		return nil, err
+	}
+
+	for {
+		err := s.connect()
+		if err == nil {
+			var resp *pbsubstreams.Response
+			if resp, err = s.stream.Recv(); err == nil {
+				s.attempts = 0
+				s.trackCursor(resp)
+				return resp, nil
+			}
+		}
+		if err == io.EOF {
+			if saveErr := s.saveCursor(true); saveErr != nil {
+				return nil, saveErr
+			}
+			return nil, io.EOF
+		}
+		if !(IsRetryable(err) || s.retryUnauthenticated && status.Code(err) == codes.Unauthenticated) {
+			return nil, err
+		}
+		if err := s.backOff(err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Cursor returns the cursor of the last block received, the one the stream
+// resumes from when it reconnects.
+func (s *Stream) Cursor() string {
+	return s.cursor
+}
+
+// Close closes the connection of the stream, saving the cursor of the last
+// response handled to the cursor store.
+func (s *Stream) Close() error {
+	saveErr := s.saveCursor(true)
+	if err := s.closeConnection(); err != nil {
+		return err
+	}
+	return saveErr
+}
+
+func (s *Stream) closeConnection() error {
+	s.stream = nil
+	if s.closeConn == nil {
+		return nil
+	}
+	closeConn := s.closeConn
+	s.closeConn = nil
+	return closeConn()
+}
+
+func (s *Stream) loadCursor() error {
+	if s.cursorStore == nil || s.cursorLoaded {
+		return nil
+	}
+	cursor, err := s.cursorStore.Load(s.ctx)
+	if err != nil {
+		return fmt.Errorf("loading cursor: %w", err)
+	}
+	s.cursorLoaded = true
+	if cursor != "" {
+		zlog.Info("starting from stored cursor", zap.String("cursor", cursor))
+		s.cursor = cursor
+	}
+	s.handledCursor = s.cursor
+	s.savedCursor = s.cursor
+	s.lastSave = time.Now()
+	return nil
+}
+
+// saveCursor saves the cursor of the last response handled if it changed,
+// unless the last save is more recent than the save interval and `force` is
+// not set.
+func (s *Stream) saveCursor(force bool) error {
+	if s.cursorStore == nil || !s.cursorLoaded || s.handledCursor == s.savedCursor {
+		return nil
+	}
+	if !force && s.saveInterval > 0 && time.Since(s.lastSave) < s.saveInterval {
+		return nil
+	}
+	if err := s.cursorStore.Save(s.ctx, s.handledCursor); err != nil {
+		return fmt.Errorf("saving cursor: %w", err)
+	}
+	s.savedCursor = s.handledCursor
+	s.lastSave = time.Now()
+	return nil
+}
+
+func (s *Stream) connect() error {
+	if s.stream != nil {
+		return nil
+	}
+	cli, closeConn, callOpts, err := s.dial()
+	if err != nil {
+		return fmt.Errorf("dialing: %w", err)
+	}
+	s.closeConn = closeConn
+
+	request := proto.Clone(s.request).(*pbsubstreams.Request)
+	request.StartCursor = s.cursor
+	stream, err := cli.Blocks(s.ctx, request, callOpts...)
+	if err != nil {
+		return err
+	}
+	s.stream = stream
+	return nil
+}
+
+// backOff drops the broken connection, then waits before the next attempt,
+// failing with `cause` once the attempts are exhausted.
+func (s *Stream) backOff(cause error) error {
+	if err := s.closeConnection(); err != nil {
+		zlog.Debug("closing broken connection", zap.Error(err))
+	}
+	if s.attempts >= s.maxAttempts {
+		return fmt.Errorf("giving up after %d reconnection attempts: %w", s.attempts, cause)
+	}
+	s.attempts++
+
+	backoff := s.initialBackoff << (s.attempts - 1)
+	if backoff > s.maxBackoff || backoff <= 0 {
+		backoff = s.maxBackoff
+	}
+	zlog.Info("stream broken, reconnecting", zap.Int("attempt", s.attempts), zap.String("cursor", s.cursor), zap.Duration("backoff", backoff), zap.Error(cause))
+	if s.onReconnect != nil {
+		s.onReconnect(s.attempts, s.cursor, cause)
+	}
+
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-time.After(backoff):
+		return nil
+	}
+}
+
+func (s *Stream) trackCursor(resp *pbsubstreams.Response) {
+	switch msg := resp.Message.(type) {
+	case *pbsubstreams.Response_Data:
+		s.cursor = msg.Data.Cursor
+	case *pbsubstreams.Response_UndoSignal:
+		s.cursor = msg.UndoSignal.LastValidCursor
+	}
+}
+
+// IsRetryable tells if the stream failed with `err` because of the transport,
+// in which case it can be resumed from its last cursor. The failures of the
+// request itself, like an invalid argument or a module failing
+// deterministically, are not.
+func IsRetryable(err error) bool {
+	if _, found := PanicDetail(err); found {
+		return false
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable:
+		return true
+	case codes.Internal, codes.Unknown:
+		message := st.Message()
+		return strings.Contains(message, "connection reset") || strings.Contains(message, "GOAWAY") || strings.Contains(message, "RST_STREAM")
+	}
+	return false
+}
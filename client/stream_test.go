@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServer answers each Blocks call with the next of its scripted
+// sessions: the responses sent, then the error ending the session.
+type fakeServer struct {
+	sessions []fakeSession
+	requests []*pbsubstreams.Request
+	dials    int
+	closes   int
+}
+
+type fakeSession struct {
+	responses []*pbsubstreams.Response
+	err       error
+}
+
+func (f *fakeServer) dial() (pbsubstreams.StreamClient, func() error, []grpc.CallOption, error) {
+	f.dials++
+	return f, func() error { f.closes++; return nil }, nil, nil
+}
+
+func (f *fakeServer) Blocks(ctx context.Context, in *pbsubstreams.Request, opts ...grpc.CallOption) (pbsubstreams.Stream_BlocksClient, error) {
+	f.requests = append(f.requests, in)
+	session := f.sessions[0]
+	f.sessions = f.sessions[1:]
+	return &fakeBlocksClient{session: session}, nil
+}
+
+type fakeBlocksClient struct {
+	grpc.ClientStream
+	session fakeSession
+}
+
+func (c *fakeBlocksClient) Recv() (*pbsubstreams.Response, error) {
+	if len(c.session.responses) == 0 {
+		return nil, c.session.err
+	}
+	resp := c.session.responses[0]
+	c.session.responses = c.session.responses[1:]
+	return resp, nil
+}
+
+func data(cursor string) *pbsubstreams.Response {
+	return &pbsubstreams.Response{Message: &pbsubstreams.Response_Data{Data: &pbsubstreams.BlockScopedData{Cursor: cursor}}}
+}
+
+func receiveAll(s *Stream) (cursors []string, err error) {
+	for {
+		resp, err := s.Recv()
+		if err != nil {
+			return cursors, err
+		}
+		cursors = append(cursors, resp.GetData().GetCursor())
+	}
+}
+
+func TestStream_Reconnects(t *testing.T) {
+	server := &fakeServer{sessions: []fakeSession{
+		{responses: []*pbsubstreams.Response{data("c1"), data("c2")}, err: status.Error(codes.Unavailable, "transport is closing")},
+		{err: status.Error(codes.Internal, "stream terminated by RST_STREAM with error code: NO_ERROR")},
+		{responses: []*pbsubstreams.Response{data("c3")}, err: io.EOF},
+	}}
+	var reconnects []string
+	stream := newStream(context.Background(), server.dial, &pbsubstreams.Request{StartBlockNum: 10, OutputModules: []string{"map_a"}},
+		WithReconnectBackoff(time.Millisecond, time.Millisecond),
+		WithOnReconnect(func(attempt int, cursor string, err error) {
+			reconnects = append(reconnects, cursor)
+		}),
+	)
+
+	cursors, err := receiveAll(stream)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []string{"c1", "c2", "c3"}, cursors, "no block lost or received twice")
+	assert.Equal(t, []string{"c2", "c2"}, reconnects)
+	assert.Equal(t, "c3", stream.Cursor())
+
+	require.Len(t, server.requests, 3)
+	assert.Equal(t, "", server.requests[0].StartCursor)
+	assert.Equal(t, "c2", server.requests[1].StartCursor)
+	assert.Equal(t, "c2", server.requests[2].StartCursor)
+	assert.Equal(t, []string{"map_a"}, server.requests[2].OutputModules)
+	assert.Equal(t, 3, server.dials, "redials on each reconnection")
+	assert.Equal(t, 2, server.closes)
+}
+
+func TestStream_NonRetryableErrors(t *testing.T) {
+	panicked, err := status.New(codes.Internal, "module panicked: connection reset by the module").WithDetails(&pbsubstreams.PanicDetail{Module: "map_a"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"invalid argument", status.Error(codes.InvalidArgument, "validate request: invalid start block")},
+		{"module failure", panicked.Err()},
+		{"not a status", io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &fakeServer{sessions: []fakeSession{{responses: []*pbsubstreams.Response{data("c1")}, err: test.err}}}
+			stream := newStream(context.Background(), server.dial, &pbsubstreams.Request{}, WithReconnectBackoff(time.Millisecond, time.Millisecond))
+
+			cursors, err := receiveAll(stream)
+			assert.Equal(t, []string{"c1"}, cursors)
+			assert.Equal(t, test.err.Error(), err.Error())
+			assert.Equal(t, 1, server.dials)
+		})
+	}
+}
+
+func TestStream_MaxReconnectAttempts(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	server := &fakeServer{sessions: []fakeSession{
+		{responses: []*pbsubstreams.Response{data("c1")}, err: unavailable},
+		{err: unavailable},
+		{err: unavailable},
+	}}
+	stream := newStream(context.Background(), server.dial, &pbsubstreams.Request{}, WithMaxReconnectAttempts(2), WithReconnectBackoff(time.Millisecond, time.Millisecond))
+
+	cursors, err := receiveAll(stream)
+	assert.Equal(t, []string{"c1"}, cursors)
+	require.Error(t, err)
+	assert.Equal(t, "giving up after 2 reconnection attempts: rpc error: code = Unavailable desc = connection refused", err.Error())
+}
+
+func TestStream_UndoSignalCursor(t *testing.T) {
+	undo := &pbsubstreams.Response{Message: &pbsubstreams.Response_UndoSignal{UndoSignal: &pbsubstreams.BlockUndoSignal{LastValidCursor: "c1"}}}
+	server := &fakeServer{sessions: []fakeSession{
+		{responses: []*pbsubstreams.Response{data("c1"), data("c2"), undo}, err: status.Error(codes.Unavailable, "GOAWAY")},
+		{err: io.EOF},
+	}}
+	stream := newStream(context.Background(), server.dial, &pbsubstreams.Request{}, WithReconnectBackoff(time.Millisecond, time.Millisecond))
+
+	_, err := receiveAll(stream)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "c1", server.requests[1].StartCursor)
+}
+
+func TestStream_RetriesUnauthenticatedWithRefreshableToken(t *testing.T) {
+	for _, refreshable := range []bool{false, true} {
+		server := &fakeServer{sessions: []fakeSession{
+			{responses: []*pbsubstreams.Response{data("c1")}, err: status.Error(codes.Unauthenticated, "token expired")},
+			{responses: []*pbsubstreams.Response{data("c2")}, err: io.EOF},
+		}}
+		stream := newStream(context.Background(), server.dial, &pbsubstreams.Request{}, WithReconnectBackoff(time.Millisecond, time.Millisecond))
+		stream.retryUnauthenticated = refreshable
+
+		cursors, err := receiveAll(stream)
+		if refreshable {
+			assert.Equal(t, io.EOF, err)
+			assert.Equal(t, []string{"c1", "c2"}, cursors)
+			assert.Equal(t, "c1", server.requests[1].StartCursor)
+		} else {
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			assert.Equal(t, []string{"c1"}, cursors)
+		}
+	}
+}
+
+func TestStream_CursorStore(t *testing.T) {
+	server := &fakeServer{sessions: []fakeSession{
+		{responses: []*pbsubstreams.Response{data("c6"), data("c7")}, err: io.EOF},
+	}}
+	store := NewMemoryCursorStore("c5")
+	stream := newStream(context.Background(), server.dial, &pbsubstreams.Request{StartCursor: "c1"}, WithCursorStore(store))
+
+	_, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "c5", server.requests[0].StartCursor, "started from the stored cursor")
+
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	cursor, _ := store.Load(context.Background())
+	assert.Equal(t, "c6", cursor, "saved once handled")
+
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+	cursor, _ = store.Load(context.Background())
+	assert.Equal(t, "c7", cursor)
+	assert.Equal(t, 2, store.Saves())
+}
+
+func TestStream_CursorSaveInterval(t *testing.T) {
+	server := &fakeServer{sessions: []fakeSession{
+		{responses: []*pbsubstreams.Response{data("c1"), data("c2"), data("c3")}, err: io.EOF},
+	}}
+	store := NewMemoryCursorStore("")
+	stream := newStream(context.Background(), server.dial, &pbsubstreams.Request{}, WithCursorStore(store), WithCursorSaveInterval(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		_, err := stream.Recv()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, store.Saves(), "within the save interval")
+
+	// The handling of c3 failed, the stream is closed without receiving again
+	require.NoError(t, stream.Close())
+	cursor, _ := store.Load(context.Background())
+	assert.Equal(t, "c2", cursor, "last handled cursor saved on close")
+	assert.Equal(t, 1, store.Saves())
+}
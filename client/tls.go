@@ -0,0 +1,86 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// WithCACertPool validates the certificate of the server against the
+// certificate authorities of `pool` instead of the system ones.
+func WithCACertPool(pool *x509.CertPool) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.caCertPool = pool
+	}
+}
+
+// WithCACertFile validates the certificate of the server against the PEM
+// encoded certificate authorities of the file at `path` instead of the system
+// ones.
+func WithCACertFile(path string) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.caCertFile = path
+	}
+}
+
+// WithClientCertificate authenticates the client with the PEM encoded
+// certificate and key of the files at `certFile` and `keyFile`, for servers
+// requiring mutual TLS.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.clientCertFile = certFile
+		c.clientKeyFile = keyFile
+	}
+}
+
+// WithServerName validates the certificate of the server against
+// `serverName` instead of the host of the endpoint, when dialing the server
+// through an IP address or a load balancer.
+func WithServerName(serverName string) Option {
+	return func(c *SubstreamsClientConfig) {
+		c.serverName = serverName
+	}
+}
+
+// customTLS returns whether the TLS configuration differs from the default
+// one, validating the certificate of the server against the system
+// certificate authorities.
+func (c *SubstreamsClientConfig) customTLS() bool {
+	return c.insecure || c.caCertPool != nil || c.caCertFile != "" || c.clientCertFile != "" || c.serverName != ""
+}
+
+// tlsConfig builds the TLS configuration of the connection, loading the
+// certificate files.
+func (c *SubstreamsClientConfig) tlsConfig() (*tls.Config, error) {
+	if c.caCertPool != nil && c.caCertFile != "" {
+		return nil, fmt.Errorf("a CA certificate pool and a CA certificate file cannot be both specified")
+	}
+	if c.insecure && (c.caCertPool != nil || c.caCertFile != "") {
+		return nil, fmt.Errorf("an insecure TLS connection does not validate the server certificate, it cannot use a CA certificate")
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: c.insecure,
+		RootCAs:            c.caCertPool,
+		ServerName:         c.serverName,
+	}
+	if c.caCertFile != "" {
+		pem, err := os.ReadFile(c.caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM encoded certificate found in CA certificate file %q", c.caCertFile)
+		}
+	}
+	if c.clientCertFile != "" || c.clientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.clientCertFile, c.clientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// testPKI is a certificate authority with a server and a client certificate
+// it signed, written as PEM files in a temporary directory.
+type testPKI struct {
+	pool                      *x509.CertPool
+	caFile                    string
+	serverCert                tls.Certificate
+	clientCertFile, clientKey string
+	otherKeyFile              string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage, dnsNames []string) ([]byte, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			DNSNames:     dnsNames,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		return der, key
+	}
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+		return path
+	}
+	keyDER := func(key *ecdsa.PrivateKey) []byte {
+		der, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return der
+	}
+
+	pki := &testPKI{pool: x509.NewCertPool()}
+	pki.pool.AddCert(caCert)
+	pki.caFile = writePEM("ca.pem", "CERTIFICATE", caDER)
+
+	serverDER, serverKey := issue(2, x509.ExtKeyUsageServerAuth, []string{"substreams.internal"})
+	pki.serverCert, err = tls.X509KeyPair(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER(serverKey)}),
+	)
+	require.NoError(t, err)
+
+	clientDER, clientKey := issue(3, x509.ExtKeyUsageClientAuth, nil)
+	pki.clientCertFile = writePEM("client.pem", "CERTIFICATE", clientDER)
+	pki.clientKey = writePEM("client-key.pem", "EC PRIVATE KEY", keyDER(clientKey))
+	_, otherKey := issue(4, x509.ExtKeyUsageClientAuth, nil)
+	pki.otherKeyFile = writePEM("other-key.pem", "EC PRIVATE KEY", keyDER(otherKey))
+	return pki
+}
+
+// startMTLSServer starts `srv` requiring a client certificate signed by the
+// authority of `pki`.
+func startMTLSServer(t *testing.T, pki *testPKI, srv pbsubstreams.StreamServer) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.pool,
+	})))
+	pbsubstreams.RegisterStreamServer(server, srv)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestNew_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	endpoint := startMTLSServer(t, pki, echoServer{})
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		expectError bool
+	}{
+		{"ca file", []Option{WithCACertFile(pki.caFile), WithServerName("substreams.internal"), WithClientCertificate(pki.clientCertFile, pki.clientKey)}, false},
+		{"ca pool", []Option{WithCACertPool(pki.pool), WithServerName("substreams.internal"), WithClientCertificate(pki.clientCertFile, pki.clientKey)}, false},
+		{"insecure", []Option{WithInsecureTLS(true), WithClientCertificate(pki.clientCertFile, pki.clientKey)}, false},
+		{"system roots", []Option{WithServerName("substreams.internal"), WithClientCertificate(pki.clientCertFile, pki.clientKey)}, true},
+		{"no server name", []Option{WithCACertFile(pki.caFile), WithClientCertificate(pki.clientCertFile, pki.clientKey)}, true},
+		{"no client certificate", []Option{WithCACertFile(pki.caFile), WithServerName("substreams.internal")}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cli, closeFunc, callOpts, err := New(endpoint, append(test.opts, WithWaitForReady(false))...)
+			require.NoError(t, err)
+			defer closeFunc()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			stream, err := cli.Blocks(ctx, &pbsubstreams.Request{StartCursor: "c1"}, callOpts...)
+			var resp *pbsubstreams.Response
+			if err == nil {
+				resp, err = stream.Recv()
+			}
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "c1", resp.GetData().GetCursor())
+		})
+	}
+}
+
+func TestNew_InvalidTLSOptions(t *testing.T) {
+	pki := newTestPKI(t)
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		expectedError string
+	}{
+		{"unreadable ca file", []Option{WithCACertFile(filepath.Join(t.TempDir(), "missing.pem"))}, "reading CA certificate"},
+		{"ca file without certificate", []Option{WithCACertFile(pki.clientKey)}, "no PEM encoded certificate found in CA certificate file"},
+		{"mismatched client key", []Option{WithClientCertificate(pki.clientCertFile, pki.otherKeyFile)}, "loading client certificate: tls: private key does not match public key"},
+		{"missing client key", []Option{WithClientCertificate(pki.clientCertFile, "")}, "loading client certificate"},
+		{"ca pool and file", []Option{WithCACertPool(pki.pool), WithCACertFile(pki.caFile)}, "a CA certificate pool and a CA certificate file cannot be both specified"},
+		{"insecure with ca", []Option{WithInsecureTLS(true), WithCACertFile(pki.caFile)}, "an insecure TLS connection does not validate the server certificate"},
+		{"plaintext with server name", []Option{WithPlaintext(true), WithServerName("substreams.internal")}, "a plaintext connection cannot use a CA certificate"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, _, err := New("localhost:9000", test.opts...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
@@ -49,7 +49,11 @@ func init() {
 
 func runDecodeOutput(cmd *cobra.Command, args []string) error {
 	manifestPath := args[0]
-	manifestReader := manifest.NewReader(manifestPath)
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	manifestReader := manifest.NewReader(manifestPath, readerOptions...)
 	moduleName := args[1]
 	storeUrl := args[2]
 	blockNumber, err := strconv.ParseUint(args[3], 10, 64)
@@ -58,7 +62,6 @@ func runDecodeOutput(cmd *cobra.Command, args []string) error {
 	}
 
 	saveInterval := mustGetUint64(cmd, "save-interval")
-	startBlock := outputs.ComputeStartBlock(blockNumber, saveInterval)
 
 	store, _, err := dstore.NewStoreFromURL(storeUrl)
 	if err != nil {
@@ -101,13 +104,13 @@ func runDecodeOutput(cmd *cobra.Command, args []string) error {
 	}
 
 	outputCache := outputs.NewOutputCache(moduleName, moduleStore, saveInterval, zlog)
-	found, err := outputCache.LoadAtBlock(cmd.Context(), startBlock)
+	found, err := outputCache.LoadAtBlock(cmd.Context(), blockNumber)
 	if err != nil {
 		return fmt.Errorf("loading cache: %w", err)
 	}
 
 	if !found {
-		return fmt.Errorf("can't find cache at block %q storeUrl %q", startBlock, moduleStore.BaseURL().String())
+		return fmt.Errorf("can't find cache at block %d storeUrl %q", blockNumber, moduleStore.BaseURL().String())
 	}
 
 	outputBytes, found := outputCache.GetAtBlock(blockNumber)
@@ -140,7 +143,11 @@ func runDecodeOutput(cmd *cobra.Command, args []string) error {
 
 func runDecodeStore(cmd *cobra.Command, args []string) error {
 	manifestPath := args[0]
-	manifestReader := manifest.NewReader(manifestPath)
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	manifestReader := manifest.NewReader(manifestPath, readerOptions...)
 	moduleName := args[1]
 	storeUrl := args[2]
 	blockNumber, err := strconv.ParseUint(args[3], 10, 64)
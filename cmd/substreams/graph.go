@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
 	"github.com/streamingfast/substreams/manifest"
+	"github.com/streamingfast/substreams/tools"
 )
 
 var graphCmd = &cobra.Command{
@@ -15,13 +18,38 @@ var graphCmd = &cobra.Command{
 	SilenceUsage: true,
 }
 
+var toolsGraphCmd = &cobra.Command{
+	Use:   "graph <package>",
+	Short: "Render the modules graph of a package as GraphViz DOT or a Mermaid flowchart",
+	Long: cli.Dedent(`
+		Render the modules graph of a package: modules shaped by kind, edges
+		labeled by the kind of input, initial blocks annotated. The modules
+		given with --highlight, like the outputs of a request, stand out.
+	`),
+	Example: tools.ExamplePrefixed("substreams tools graph", `
+		substreams.yaml --format dot | dot -Tsvg > graph.svg
+		substreams.yaml --highlight map_pools,store_volumes
+	`),
+	RunE:         runToolsGraph,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+}
+
 func init() {
 	rootCmd.AddCommand(graphCmd)
+
+	toolsGraphCmd.Flags().String("format", "mermaid", "Format of the graph, one of: dot, mermaid")
+	toolsGraphCmd.Flags().StringSlice("highlight", nil, "Comma-separated modules to highlight, like the output modules of a request")
+	tools.Cmd.AddCommand(toolsGraphCmd)
 }
 
 func runManifestGraph(cmd *cobra.Command, args []string) error {
 	manifestPath := args[0]
-	manifestReader := manifest.NewReader(manifestPath)
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	manifestReader := manifest.NewReader(manifestPath, readerOptions...)
 	pkg, err := manifestReader.Read()
 	if err != nil {
 		return fmt.Errorf("read manifest %q: %w", manifestPath, err)
@@ -31,3 +59,35 @@ func runManifestGraph(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runToolsGraph(cmd *cobra.Command, args []string) error {
+	write := manifest.WriteMermaid
+	switch format := mustGetString(cmd, "format"); format {
+	case "mermaid":
+	case "dot":
+		write = manifest.WriteDOT
+	default:
+		return fmt.Errorf("invalid format %q, expected one of: dot, mermaid", format)
+	}
+
+	manifestPath := args[0]
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	pkg, err := manifest.NewReader(manifestPath, append(readerOptions, manifest.SkipSourceCodeReader())...).Read()
+	if err != nil {
+		return fmt.Errorf("read manifest %q: %w", manifestPath, err)
+	}
+
+	// Resolves the initial blocks the modules inherit from their inputs
+	if _, err := manifest.NewModuleGraph(pkg.Modules.Modules); err != nil {
+		return fmt.Errorf("creating module graph: %w", err)
+	}
+
+	highlighted, err := cmd.Flags().GetStringSlice("highlight")
+	if err != nil {
+		return fmt.Errorf("highlight: %w", err)
+	}
+	return write(os.Stdout, pkg.Modules, highlighted...)
+}
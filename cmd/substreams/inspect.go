@@ -27,7 +27,11 @@ func init() {
 func runInspect(cmd *cobra.Command, args []string) error {
 	manifestPath := args[0]
 
-	manifestReader := manifest.NewReader(manifestPath)
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	manifestReader := manifest.NewReader(manifestPath, readerOptions...)
 	pkg, err := manifestReader.Read()
 	if err != nil {
 		return fmt.Errorf("reading manifest %q: %w", manifestPath, err)
@@ -27,7 +27,11 @@ func init() {
 
 func runInfo(cmd *cobra.Command, args []string) error {
 	manifestPath := args[0]
-	manifestReader := manifest.NewReader(manifestPath)
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	manifestReader := manifest.NewReader(manifestPath, readerOptions...)
 	pkg, err := manifestReader.Read()
 	if err != nil {
 		return fmt.Errorf("read manifest %q: %w", manifestPath, err)
@@ -40,13 +44,23 @@ func runInfo(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("Package name:", pkg.PackageMeta[0].Name)
 	fmt.Println("Version:", pkg.PackageMeta[0].Version)
-	if doc := pkg.PackageMeta[0].Doc; doc != "" {
+	if doc := manifest.PackageDoc(pkg); doc != "" {
 		fmt.Println("Doc: " + strings.Replace(doc, "\n", "\n  ", -1))
 	}
+	if pkg.Network != "" {
+		fmt.Println("Network:", pkg.Network)
+	}
+	if pkg.SubstreamsVersion != "" {
+		fmt.Println("Built with substreams:", pkg.SubstreamsVersion)
+	}
+	if pkg.SinkModule != "" {
+		fmt.Println("Sink module:", pkg.SinkModule)
+		fmt.Println("Sink config type:", pkg.SinkConfig.GetTypeUrl())
+	}
 
 	fmt.Println("Modules:")
 	fmt.Println("----")
-	for modIdx, module := range pkg.Modules.Modules {
+	for _, module := range pkg.Modules.Modules {
 		fmt.Println("Name:", module.Name)
 		fmt.Println("Initial block:", module.InitialBlock)
 		kind := module.GetKind()
@@ -58,13 +72,27 @@ func runInfo(cmd *cobra.Command, args []string) error {
 			fmt.Println("Kind: store")
 			fmt.Println("Value Type:", v.KindStore.ValueType)
 			fmt.Println("Update Policy:", v.KindStore.UpdatePolicy)
+		case *pbsubstreams.Module_KindBlockIndex_:
+			fmt.Println("Kind: blockIndex")
 		default:
 			fmt.Println("Kind: Unknown")
 		}
+		if filter := module.BlockFilter; filter != nil {
+			fmt.Printf("Block Filter: %s on %s\n", filter.Query, filter.Module)
+		}
+		if module.Params != "" {
+			fmt.Println("Params:", module.Params)
+		}
 		fmt.Println("Hash:", manifest.HashModuleAsString(pkg.Modules, graph, module))
-		moduleMeta := pkg.ModuleMeta[modIdx]
-		if moduleMeta != nil && moduleMeta.Doc != "" {
-			fmt.Println("Doc: " + strings.Replace(moduleMeta.Doc, "\n", "\n  ", -1))
+		doc, declaredIn, err := manifest.ModuleDoc(pkg, module.Name)
+		if err != nil {
+			return fmt.Errorf("module %q documentation: %w", module.Name, err)
+		}
+		if declaredIn != pkg.PackageMeta[0] {
+			fmt.Printf("Imported from: %s %s\n", declaredIn.Name, declaredIn.Version)
+		}
+		if doc != "" {
+			fmt.Println("Doc: " + strings.Replace(doc, "\n", "\n  ", -1))
 		}
 		fmt.Println("")
 	}
@@ -12,20 +12,26 @@ import (
 
 var packCmd = &cobra.Command{
 	Use:          "pack <package>",
-	Short:        "Build an .spkg out of a .yaml manifest",
+	Short:        "Build an .spkg out of a .yaml manifest, or re-pack an .spkg",
 	RunE:         runPack,
 	Args:         cobra.ExactArgs(1),
 	SilenceUsage: true,
 }
 
 func init() {
+	packCmd.Flags().String("bump", "", "Bump the version of the package before packing it, one of: patch, minor, major")
+
 	rootCmd.AddCommand(packCmd)
 }
 
 func runPack(cmd *cobra.Command, args []string) error {
 	manifestPath := args[0]
 
-	manifestReader := manifest.NewReader(manifestPath)
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	manifestReader := manifest.NewReader(manifestPath, readerOptions...)
 	pkg, err := manifestReader.Read()
 	if err != nil {
 		return fmt.Errorf("reading manifest %q: %w", manifestPath, err)
@@ -35,7 +41,22 @@ func runPack(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("processing module graph %w", err)
 	}
 
-	defaultFilename := fmt.Sprintf("%s-%s.spkg", strings.Replace(pkg.PackageMeta[0].Name, "_", "-", -1), pkg.PackageMeta[0].Version)
+	pkgMeta := pkg.PackageMeta[0]
+	if part := mustGetString(cmd, "bump"); part != "" {
+		version, err := manifest.BumpVersion(pkgMeta.Version, part)
+		if err != nil {
+			return fmt.Errorf("bumping package version: %w", err)
+		}
+		fmt.Printf("Bumped package version from %s to %s\n", pkgMeta.Version, version)
+		pkgMeta.Version = version
+	}
+	// Packages with an invalid version are read with a warning, to
+	// support the older ones, but never packed again
+	if err := manifest.ValidateVersion(pkgMeta.Version); err != nil {
+		return fmt.Errorf("package %q: %w", pkgMeta.Name, err)
+	}
+
+	defaultFilename := fmt.Sprintf("%s-%s.spkg", strings.Replace(pkgMeta.Name, "_", "-", -1), pkgMeta.Version)
 
 	cnt, err := proto.Marshal(pkg)
 	if err != nil {
@@ -32,7 +32,11 @@ func runProtogen(cmd *cobra.Command, args []string) error {
 	outputPath := mustGetString(cmd, "output-path")
 	excludePaths := mustGetStringArray(cmd, "exclude-paths")
 	manifestPath := args[0]
-	manifestReader := manifest.NewReader(manifestPath, manifest.SkipSourceCodeReader())
+	readerOptions, err := manifestReaderOptions(cmd)
+	if err != nil {
+		return err
+	}
+	manifestReader := manifest.NewReader(manifestPath, append(readerOptions, manifest.SkipSourceCodeReader())...)
 	pkg, err := manifestReader.Read()
 	if err != nil {
 		return fmt.Errorf("reading manifest %q: %w", manifestPath, err)
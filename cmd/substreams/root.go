@@ -1,7 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/spf13/cobra"
+	"github.com/streamingfast/substreams/manifest"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -13,4 +19,41 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringArray("set", nil, "Variable substituted in the manifests read, as '<name>=<value>', taking precedence over the environment variable of the same name. Can be repeated.")
+	rootCmd.PersistentFlags().Bool("strict-integrity", false, "Require a sha256 digest for the binaries and imports of the manifests fetched from a URL")
+	rootCmd.PersistentFlags().String("download-cache-dir", defaultDownloadCacheDir(), "Directory keeping the binaries and imports fetched from a URL, keyed by their sha256 digest, empty to disable")
+}
+
+func defaultDownloadCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "substreams", "downloads")
+}
+
+// manifestReaderOptions returns the options of the manifest readers: the
+// variables set with --set, and how binaries and imports are fetched.
+func manifestReaderOptions(cmd *cobra.Command) ([]manifest.Options, error) {
+	values, err := cmd.Flags().GetStringArray("set")
+	if err != nil {
+		return nil, fmt.Errorf("set: %w", err)
+	}
+
+	vars := map[string]string{}
+	for _, value := range values {
+		name, varValue, found := strings.Cut(value, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid variable %q, expected <name>=<value>", value)
+		}
+		vars[name] = varValue
+	}
+	opts := []manifest.Options{
+		manifest.WithVariables(vars),
+		manifest.WithDownloadCache(mustGetString(cmd, "download-cache-dir")),
+	}
+	if mustGetBool(cmd, "strict-integrity") {
+		opts = append(opts, manifest.StrictIntegrity())
+	}
+	return opts, nil
 }
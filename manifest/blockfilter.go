@@ -0,0 +1,46 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BlockFilterQuery is a parsed block filter query, a list of alternatives
+// each listing the keys that must all be present in the index of a block.
+type BlockFilterQuery [][]string
+
+// ParseBlockFilterQuery parses a query made of index keys separated by `||`
+// when any of them must match and by `&&` when all of them must, `&&` binding
+// tighter than `||`.
+func ParseBlockFilterQuery(query string) (BlockFilterQuery, error) {
+	var out BlockFilterQuery
+	for _, alternative := range strings.Split(query, "||") {
+		var keys []string
+		for _, key := range strings.Split(alternative, "&&") {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return nil, fmt.Errorf("invalid block filter query %q: empty key", query)
+			}
+			keys = append(keys, key)
+		}
+		out = append(out, keys)
+	}
+	return out, nil
+}
+
+// Matches returns true when the index keys of a block satisfy the query.
+func (q BlockFilterQuery) Matches(keys map[string]bool) bool {
+	for _, alternative := range q {
+		matched := true
+		for _, key := range alternative {
+			if !keys[key] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockFilterQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		keys        []string
+		expectMatch bool
+		expectError bool
+	}{
+		{name: "single key", query: "transfer", keys: []string{"transfer"}, expectMatch: true},
+		{name: "single key missing", query: "transfer", keys: []string{"approval"}},
+		{name: "any key", query: "transfer || approval", keys: []string{"approval"}, expectMatch: true},
+		{name: "all keys", query: "transfer && usdc", keys: []string{"transfer", "usdc"}, expectMatch: true},
+		{name: "all keys missing one", query: "transfer && usdc", keys: []string{"transfer"}},
+		{name: "and binds tighter", query: "transfer && usdc || approval", keys: []string{"approval"}, expectMatch: true},
+		{name: "and binds tighter missing", query: "transfer && usdc || approval", keys: []string{"usdc"}},
+		{name: "no keys", query: "transfer", keys: nil},
+		{name: "empty query", query: "", expectError: true},
+		{name: "empty key", query: "transfer || ", expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := ParseBlockFilterQuery(test.query)
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			keys := map[string]bool{}
+			for _, key := range test.keys {
+				keys[key] = true
+			}
+			assert.Equal(t, test.expectMatch, query.Matches(keys))
+		})
+	}
+}
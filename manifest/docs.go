@@ -0,0 +1,47 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// PackageDoc returns the documentation of `pkg`, as written in the `doc`
+// field of the `package` section of its manifest.
+func PackageDoc(pkg *pbsubstreams.Package) string {
+	if len(pkg.PackageMeta) == 0 {
+		return ""
+	}
+	return pkg.PackageMeta[0].Doc
+}
+
+// ModuleDoc returns the documentation of the module `name` of `pkg`, as
+// written in the `doc` field of the module in its manifest, along with the
+// metadata of the package declaring the module: `pkg` itself, or the
+// package the module was imported from.
+func ModuleDoc(pkg *pbsubstreams.Package, name string) (string, *pbsubstreams.PackageMetadata, error) {
+	for idx, mod := range pkg.Modules.Modules {
+		if mod.Name != name {
+			continue
+		}
+		if idx >= len(pkg.ModuleMeta) {
+			return "", nil, fmt.Errorf("module %q: no metadata in package", name)
+		}
+		meta := pkg.ModuleMeta[idx]
+		if meta.PackageIndex >= uint64(len(pkg.PackageMeta)) {
+			return "", nil, fmt.Errorf("module %q: package index %d out of range, the package has %d package metadata", name, meta.PackageIndex, len(pkg.PackageMeta))
+		}
+		return meta.Doc, pkg.PackageMeta[meta.PackageIndex], nil
+	}
+	return "", nil, fmt.Errorf("module %q not found", name)
+}
+
+// DocSummary returns the first line of `doc`, its short description.
+func DocSummary(doc string) string {
+	doc = strings.TrimSpace(doc)
+	if idx := strings.IndexByte(doc, '\n'); idx != -1 {
+		doc = doc[:idx]
+	}
+	return strings.TrimSpace(doc)
+}
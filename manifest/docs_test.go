@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReader_Docs(t *testing.T) {
+	pkg, err := NewReader("testdata/docs/prices.yaml", SkipSourceCodeReader()).Read()
+	require.NoError(t, err)
+
+	// Packed and read back, the docs are kept verbatim
+	cnt, err := proto.Marshal(pkg)
+	require.NoError(t, err)
+	spkgPath := filepath.Join(t.TempDir(), "prices.spkg")
+	require.NoError(t, os.WriteFile(spkgPath, cnt, 0644))
+	readBack, err := NewReader(spkgPath).Read()
+	require.NoError(t, err)
+
+	for _, pkg := range []*pbsubstreams.Package{pkg, readBack} {
+		assert.Equal(t, "Prices of the tokens of the pools.\n\nComputed from the reserves of the pools, in USD.", PackageDoc(pkg))
+
+		doc, declaredIn, err := ModuleDoc(pkg, "store_prices")
+		require.NoError(t, err)
+		assert.Equal(t, "Price of each token in USD, keyed by token address, set from the reserves of the last pool updated in the block holding the token with a known stable coin, which is a fairly long sentence to pack.", doc)
+		assert.Equal(t, "prices", declaredIn.Name)
+
+		doc, declaredIn, err = ModuleDoc(pkg, "pools:map_pools_created")
+		require.NoError(t, err)
+		assert.Equal(t, "Pools created in the block, in the order of their creation.\n\nEach pool carries its two tokens:\n  * token0, the lowest address\n  * token1, the highest address\n\nPools created by a factory unknown to the package are left out, even\nwhen their tokens are known.\n", doc)
+		assert.Equal(t, "pools", declaredIn.Name)
+		assert.Equal(t, "v1.0.0", declaredIn.Version)
+
+		doc, _, err = ModuleDoc(pkg, "map_undocumented")
+		require.NoError(t, err)
+		assert.Empty(t, doc)
+
+		_, _, err = ModuleDoc(pkg, "map_missing")
+		assert.EqualError(t, err, `module "map_missing" not found`)
+	}
+}
+
+func TestDocSummary(t *testing.T) {
+	tests := []struct {
+		doc    string
+		expect string
+	}{
+		{"", ""},
+		{"Pools created in the block", "Pools created in the block"},
+		{"\n  Pools created in the block.\n\nEach pool carries its tokens.\n", "Pools created in the block."},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expect, DocSummary(test.doc))
+	}
+}
@@ -0,0 +1,197 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+// fetchTimeout bounds the download of a remote binary or import.
+const fetchTimeout = 5 * time.Minute
+
+// digestSeparator separates the reference of an import from its optional
+// digest, ex: `https://example.com/dep-v1.0.0.spkg@sha256:<hex>`.
+const digestSeparator = "@sha256:"
+
+var sha256DigestRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// StrictIntegrity makes the reader require a sha256 digest for the binaries
+// and imports fetched from a URL, refusing the ones without.
+func StrictIntegrity() Options {
+	return func(r *Reader) *Reader {
+		r.strictIntegrity = true
+		return r
+	}
+}
+
+// WithDownloadCache keeps the binaries and imports fetched from a URL in
+// `dir`, keyed by their sha256 digest, so references giving a digest are
+// downloaded once.
+func WithDownloadCache(dir string) Options {
+	return func(r *Reader) *Reader {
+		r.downloadCacheDir = dir
+		return r
+	}
+}
+
+// isRemoteReference tells if `ref` is a URL to fetch, over http(s) or from a
+// store like `gs://` or `s3://`, rather than a local path.
+func isRemoteReference(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	// A single letter is the drive of a Windows path
+	return len(u.Scheme) > 1 && u.Scheme != "file"
+}
+
+// splitDigest separates the reference of an import from its digest.
+func splitDigest(ref string) (string, string) {
+	if idx := strings.LastIndex(ref, digestSeparator); idx != -1 {
+		return ref[:idx], ref[idx+len(digestSeparator):]
+	}
+	return ref, ""
+}
+
+// readReference returns the content of `ref`, fetched when it is a URL, and
+// checks it against `digest`, the hex-encoded sha256 expected, when given.
+// `field` is the field of the manifest giving `ref`, named by the errors.
+func (r *Reader) readReference(field, ref, digest string) ([]byte, error) {
+	if digest != "" && !sha256DigestRegexp.MatchString(digest) {
+		return nil, fmt.Errorf("%s: invalid sha256 digest %q, expected 64 lowercase hexadecimal characters", field, digest)
+	}
+
+	if !isRemoteReference(ref) {
+		content, err := os.ReadFile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		if err := checkDigest(content, digest); err != nil {
+			return nil, fmt.Errorf("%s: %q: %w", field, ref, err)
+		}
+		return content, nil
+	}
+
+	if digest == "" && r.strictIntegrity {
+		return nil, fmt.Errorf("%s: %q: a sha256 digest is required for the files fetched from a URL", field, ref)
+	}
+	if content := r.cachedDownload(digest); content != nil {
+		return content, nil
+	}
+
+	content, err := download(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s: downloading %q: %w", field, ref, err)
+	}
+	if err := checkDigest(content, digest); err != nil {
+		return nil, fmt.Errorf("%s: %q: %w", field, ref, err)
+	}
+	r.cacheDownload(content)
+	return content, nil
+}
+
+func download(ref string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	var body io.ReadCloser
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		body = resp.Body
+	} else {
+		reader, _, _, err := dstore.OpenObject(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		body = reader
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+func checkDigest(content []byte, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	if actual := sha256Hex(content); actual != digest {
+		return fmt.Errorf("sha256 digest %s does not match the expected %s", actual, digest)
+	}
+	return nil
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Reader) cachePath(digest string) string {
+	return filepath.Join(r.downloadCacheDir, "sha256", digest)
+}
+
+// cachedDownload returns the content of `digest` from the download cache, or
+// nil when it doesn't hold it intact.
+func (r *Reader) cachedDownload(digest string) []byte {
+	if r.downloadCacheDir == "" || digest == "" {
+		return nil
+	}
+	content, err := os.ReadFile(r.cachePath(digest))
+	if err != nil || sha256Hex(content) != digest {
+		return nil
+	}
+	return content
+}
+
+// cacheDownload keeps `content` in the download cache. Failing to is only
+// logged, the download having succeeded.
+func (r *Reader) cacheDownload(content []byte) {
+	if r.downloadCacheDir == "" {
+		return
+	}
+	cachePath := r.cachePath(sha256Hex(content))
+	if err := writeFileAtomic(cachePath, content); err != nil {
+		zlog.Warn("unable to keep download in cache", zap.String("path", cachePath), zap.Error(err))
+	}
+}
+
+func writeFileAtomic(filename string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
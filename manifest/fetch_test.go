@@ -0,0 +1,179 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIsRemoteReference(t *testing.T) {
+	tests := []struct {
+		ref    string
+		expect bool
+	}{
+		{"./target/module.wasm", false},
+		{"/abs/module.wasm", false},
+		{`C:\target\module.wasm`, false},
+		{"file:///abs/module.wasm", false},
+		{"https://example.com/module.wasm", true},
+		{"gs://bucket/module.wasm", true},
+		{"s3://bucket/module.wasm", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expect, isRemoteReference(test.ref), test.ref)
+	}
+}
+
+func TestSplitDigest(t *testing.T) {
+	ref, digest := splitDigest("https://example.com/dep.spkg@sha256:abcd")
+	assert.Equal(t, "https://example.com/dep.spkg", ref)
+	assert.Equal(t, "abcd", digest)
+
+	ref, digest = splitDigest("./dep.yaml")
+	assert.Equal(t, "./dep.yaml", ref)
+	assert.Equal(t, "", digest)
+}
+
+func TestReader_RemoteReferences(t *testing.T) {
+	wasm := []byte("wasm code")
+	dep := &pbsubstreams.Package{
+		Version:     1,
+		PackageMeta: []*pbsubstreams.PackageMetadata{{Name: "dep", Version: "v0.0.0"}},
+		ModuleMeta:  []*pbsubstreams.ModuleMetadata{{}},
+		ProtoFiles:  readSystemProtoDescriptors(t),
+		Modules: &pbsubstreams.Modules{
+			Modules: []*pbsubstreams.Module{{
+				Name:   "map_clock",
+				Kind:   &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:sf.substreams.v1.Clock"}},
+				Inputs: []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.substreams.v1.Clock"}}}},
+			}},
+			Binaries: []*pbsubstreams.Binary{{Type: "wasm/rust-v1", Content: []byte("dep code")}},
+		},
+	}
+	spkg, err := proto.Marshal(dep)
+	require.NoError(t, err)
+
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		switch r.URL.Path {
+		case "/module.wasm":
+			w.Write(wasm)
+		case "/dep.spkg":
+			w.Write(spkg)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dep.spkg"), spkg, 0644))
+	writeManifest := func(binary, binaryDigest, importRef string) string {
+		manifestPath := filepath.Join(dir, "substreams.yaml")
+		require.NoError(t, os.WriteFile(manifestPath, []byte(`specVersion: v0.1.0
+package:
+  name: test
+  version: v0.0.0
+imports:
+  dep: `+importRef+`
+binaries:
+  default:
+    type: wasm/rust-v1
+    file: `+binary+`
+    sha256: "`+binaryDigest+`"
+modules:
+  - name: map_transfers
+    kind: map
+    inputs:
+      - map: dep:map_clock
+    output:
+      type: proto:sf.substreams.v1.Clock
+`), 0644))
+		return manifestPath
+	}
+
+	wasmURL, wasmDigest := server.URL+"/module.wasm", sha256Hex(wasm)
+	spkgURL, spkgDigest := server.URL+"/dep.spkg", sha256Hex(spkg)
+	wrongDigest := strings.Repeat("0", 64)
+
+	t.Run("fetched and cached", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		manifestPath := writeManifest(wasmURL, wasmDigest, spkgURL+"@sha256:"+spkgDigest)
+
+		atomic.StoreInt64(&requests, 0)
+		for i := 0; i < 2; i++ {
+			pkg, err := NewReader(manifestPath, StrictIntegrity(), WithDownloadCache(cacheDir)).Read()
+			require.NoError(t, err)
+			assert.Equal(t, wasm, pkg.Modules.Binaries[0].Content)
+			assert.Equal(t, "dep:map_clock", pkg.Modules.Modules[1].Name)
+		}
+		assert.Equal(t, int64(2), atomic.LoadInt64(&requests), "second read served from the cache")
+		assert.FileExists(t, filepath.Join(cacheDir, "sha256", wasmDigest))
+	})
+
+	tests := []struct {
+		name        string
+		binary      string
+		digest      string
+		importRef   string
+		opts        []Options
+		expectError string
+	}{
+		{name: "without digest", binary: wasmURL, importRef: spkgURL},
+		{name: "local import digest", binary: wasmURL, importRef: "./dep.spkg@sha256:" + spkgDigest},
+		{
+			name: "strict without digest", binary: wasmURL, importRef: spkgURL + "@sha256:" + spkgDigest, opts: []Options{StrictIntegrity()},
+			expectError: `binaries.default.file: "` + wasmURL + `": a sha256 digest is required for the files fetched from a URL`,
+		},
+		{
+			name: "strict import without digest", binary: wasmURL, digest: wasmDigest, importRef: spkgURL, opts: []Options{StrictIntegrity()},
+			expectError: `imports.dep: "` + spkgURL + `": a sha256 digest is required for the files fetched from a URL`,
+		},
+		{
+			name: "binary digest mismatch", binary: wasmURL, digest: wrongDigest, importRef: spkgURL,
+			expectError: `binaries.default.file: "` + wasmURL + `": sha256 digest ` + wasmDigest + ` does not match the expected ` + wrongDigest,
+		},
+		{
+			name: "import digest mismatch", binary: wasmURL, importRef: spkgURL + "@sha256:" + wrongDigest,
+			expectError: `imports.dep: "` + spkgURL + `": sha256 digest ` + spkgDigest + ` does not match the expected ` + wrongDigest,
+		},
+		{
+			name: "local import digest mismatch", binary: wasmURL, importRef: "./dep.spkg@sha256:" + wrongDigest,
+			expectError: `imports.dep: "` + filepath.Join(dir, "dep.spkg") + `": sha256 digest ` + spkgDigest + ` does not match`,
+		},
+		{
+			name: "invalid digest", binary: wasmURL, digest: "abcd", importRef: spkgURL,
+			expectError: `binaries.default.file: invalid sha256 digest "abcd"`,
+		},
+		{
+			name: "not found", binary: server.URL + "/missing.wasm", importRef: spkgURL,
+			expectError: `binaries.default.file: downloading "` + server.URL + `/missing.wasm": unexpected status 404 Not Found`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifestPath := writeManifest(test.binary, test.digest, test.importRef)
+
+			pkg, err := NewReader(manifestPath, test.opts...).Read()
+			if test.expectError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, wasm, pkg.Modules.Binaries[0].Content)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 
 	"go.uber.org/zap"
 
@@ -18,9 +19,30 @@ type ModuleGraph struct {
 	modules     []*pbsubstreams.Module
 	moduleIndex map[string]int
 	indexIndex  map[int]*pbsubstreams.Module
+
+	// topologicalOrder is computed once, modules come before their inputs
+	topologicalOrder []int
 }
 
 func NewModuleGraph(modules []*pbsubstreams.Module) (*ModuleGraph, error) {
+	g := newModuleGraph(modules)
+
+	order, ok := graph.TopSort(g)
+	if !ok {
+		return nil, fmt.Errorf("modules graph has a cycle: %s", strings.Join(g.findCycle(), " -> "))
+	}
+	g.topologicalOrder = order
+
+	if err := computeInitialBlock(modules, g); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// newModuleGraph links the modules to their inputs and to the index
+// consulted by their block filter, without checking the graph is acyclic.
+func newModuleGraph(modules []*pbsubstreams.Module) *ModuleGraph {
 	g := &ModuleGraph{
 		Mutable:     graph.New(len(modules)),
 		modules:     modules,
@@ -49,17 +71,17 @@ func NewModuleGraph(modules []*pbsubstreams.Module) (*ModuleGraph, error) {
 				g.AddCost(i, j, 1)
 			}
 		}
-	}
-
-	if !graph.Acyclic(g) {
-		return nil, fmt.Errorf("modules graph has a cycle")
-	}
 
-	if err := computeInitialBlock(modules, g); err != nil {
-		return nil, err
+		// The index consulted by a block filter is needed before the module
+		// executes, exactly like an input.
+		if filter := module.BlockFilter; filter != nil {
+			if j, found := g.moduleIndex[filter.Module]; found {
+				g.AddCost(i, j, 1)
+			}
+		}
 	}
 
-	return g, nil
+	return g
 }
 
 func (g *ModuleGraph) GetSources() []string {
@@ -128,18 +150,65 @@ func startBlockForModule(moduleIndex int, g *ModuleGraph) (out uint64, err error
 	return uint64(parentsInitialBlock), nil
 }
 
-func (g *ModuleGraph) TopologicalSort() ([]*pbsubstreams.Module, bool) {
-	order, ok := graph.TopSort(g)
-	if !ok {
-		return nil, ok
+// findCycle returns the names of the modules forming a cycle, each module
+// followed by one of its inputs and the first module repeated at the end.
+func (g *ModuleGraph) findCycle() []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make([]int, g.Order())
+	var path []int
+
+	var visit func(v int) []int
+	visit = func(v int) (cycle []int) {
+		state[v] = inProgress
+		path = append(path, v)
+		g.Visit(v, func(w int, _ int64) bool {
+			switch state[w] {
+			case inProgress:
+				for i, u := range path {
+					if u == w {
+						cycle = append(append([]int{}, path[i:]...), w)
+						return true
+					}
+				}
+			case unvisited:
+				cycle = visit(w)
+				return cycle != nil
+			}
+			return false
+		})
+		path = path[:len(path)-1]
+		state[v] = done
+		return cycle
 	}
 
+	for v := range state {
+		if state[v] != unvisited {
+			continue
+		}
+		if cycle := visit(v); cycle != nil {
+			var names []string
+			for _, i := range cycle {
+				names = append(names, g.indexIndex[i].Name)
+			}
+			return names
+		}
+	}
+	return nil
+}
+
+// TopologicalSort returns the modules ordered so that each module comes
+// before its inputs. The order is computed once, when building the graph.
+func (g *ModuleGraph) TopologicalSort() ([]*pbsubstreams.Module, bool) {
 	var res []*pbsubstreams.Module
-	for _, i := range order {
+	for _, i := range g.topologicalOrder {
 		res = append(res, g.indexIndex[i])
 	}
 
-	return res, ok
+	return res, true
 }
 
 func (g *ModuleGraph) AncestorsOf(moduleName string) ([]*pbsubstreams.Module, error) {
@@ -159,6 +228,25 @@ func (g *ModuleGraph) AncestorsOf(moduleName string) ([]*pbsubstreams.Module, er
 	return res, nil
 }
 
+// DescendantsOf returns the modules depending, directly or not, on the given
+// module.
+func (g *ModuleGraph) DescendantsOf(moduleName string) ([]*pbsubstreams.Module, error) {
+	if _, found := g.moduleIndex[moduleName]; !found {
+		return nil, fmt.Errorf("could not find module %s in graph", moduleName)
+	}
+
+	_, distances := graph.ShortestPaths(graph.Transpose(g), g.moduleIndex[moduleName])
+
+	var res []*pbsubstreams.Module
+	for i, d := range distances {
+		if d >= 1 {
+			res = append(res, g.indexIndex[i])
+		}
+	}
+
+	return res, nil
+}
+
 func (g *ModuleGraph) AncestorStoresOf(moduleName string) ([]*pbsubstreams.Module, error) {
 	ancestors, err := g.AncestorsOf(moduleName)
 	if err != nil {
@@ -47,6 +47,23 @@ func TestModuleGraph_AncestorsOf(t *testing.T) {
 	assert.Equal(t, []string{"A", "B", "C", "D", "E"}, res)
 }
 
+func TestModuleGraph_DescendantsOf(t *testing.T) {
+	g, err := NewModuleGraph(testModules)
+	assert.NoError(t, err)
+
+	descendants, err := g.DescendantsOf("C")
+	assert.NoError(t, err)
+
+	var res []string
+	for _, d := range descendants {
+		res = append(res, d.Name)
+	}
+
+	sort.Strings(res)
+
+	assert.Equal(t, []string{"E", "F", "G", "K"}, res)
+}
+
 func TestModuleGraph_AncestorStoresOf(t *testing.T) {
 	g, err := NewModuleGraph(testModules)
 	assert.NoError(t, err)
@@ -91,6 +108,65 @@ func TestModuleGraph_ModulesDownTo(t *testing.T) {
 	assert.Equal(t, []string{"A", "B", "C", "D", "E", "G"}, res)
 }
 
+func TestNewModuleGraph_Cycle(t *testing.T) {
+	mapInput := func(name string) []*pbsubstreams.Module_Input {
+		return []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: name}}}}
+	}
+	modules := []*pbsubstreams.Module{
+		{Name: "A"},
+		{Name: "B", Inputs: mapInput("D")},
+		{Name: "C", Inputs: mapInput("B")},
+		{Name: "D", Inputs: mapInput("C")},
+	}
+
+	_, err := NewModuleGraph(modules)
+	require.EqualError(t, err, "modules graph has a cycle: B -> D -> C -> B")
+}
+
+func TestModuleGraph_ModulesDownTo_ExecutionOrder(t *testing.T) {
+	g, err := NewModuleGraph(testModules)
+	require.NoError(t, err)
+
+	mods, err := g.ModulesDownTo([]string{"G"})
+	require.NoError(t, err)
+
+	executed := map[string]bool{}
+	for _, module := range mods {
+		parents, err := g.ParentsOf(module.Name)
+		require.NoError(t, err)
+		for _, parent := range parents {
+			assert.True(t, executed[parent.Name], "%s must come before %s", parent.Name, module.Name)
+		}
+		executed[module.Name] = true
+	}
+}
+
+func TestModuleGraph_ModulesDownTo_MultipleOutputs(t *testing.T) {
+	g, err := NewModuleGraph(testModules)
+	assert.NoError(t, err)
+
+	mods, err := g.ModulesDownTo([]string{"G", "F", "G"})
+	assert.NoError(t, err)
+
+	position := map[string]int{}
+	var res []string
+	for i, p := range mods {
+		res = append(res, p.Name)
+		position[p.Name] = i
+	}
+
+	sort.Strings(res)
+
+	assert.Equal(t, []string{"A", "B", "C", "D", "E", "F", "G"}, res)
+	for _, mod := range mods {
+		parents, err := g.ParentsOf(mod.Name)
+		require.NoError(t, err)
+		for _, parent := range parents {
+			assert.Less(t, position[parent.Name], position[mod.Name], "%s must be executed before %s", parent.Name, mod.Name)
+		}
+	}
+}
+
 func TestModuleGraph_StoresDownTo(t *testing.T) {
 	g, err := NewModuleGraph(testModules)
 	assert.NoError(t, err)
@@ -0,0 +1,59 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// ApplyInitialBlockOverrides sets the initial block of the modules of
+// `graph` named in `overrides` to the block given for them, once checked that
+// none is lower than the declared initial block of the module or of any of
+// its ancestors, which would run a module on blocks its inputs are not
+// produced for. The initial block being part of the module hash, the modules
+// overridden and the ones depending on them get other hashes, so they don't
+// share caches with the declared initial blocks. It returns the overrides
+// applied, sorted by module name.
+func ApplyInitialBlockOverrides(graph *ModuleGraph, overrides map[string]uint64) ([]*pbsubstreams.InitialBlockOverride, error) {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// All checked against the declared initial blocks before any is applied
+	var out []*pbsubstreams.InitialBlockOverride
+	for _, name := range names {
+		initialBlock := overrides[name]
+		module, err := graph.Module(name)
+		if err != nil {
+			return nil, fmt.Errorf("initial block override of module %q: module not found", name)
+		}
+		if initialBlock < module.InitialBlock {
+			return nil, fmt.Errorf("initial block override of module %q: block %d is lower than its declared initial block %d", name, initialBlock, module.InitialBlock)
+		}
+
+		ancestors, err := graph.AncestorsOf(name)
+		if err != nil {
+			return nil, fmt.Errorf("initial block override of module %q: %w", name, err)
+		}
+		for _, ancestor := range ancestors {
+			if initialBlock < ancestor.InitialBlock {
+				return nil, fmt.Errorf("initial block override of module %q: block %d is lower than the declared initial block %d of its ancestor %q", name, initialBlock, ancestor.InitialBlock, ancestor.Name)
+			}
+		}
+
+		out = append(out, &pbsubstreams.InitialBlockOverride{
+			ModuleName:           name,
+			DeclaredInitialBlock: module.InitialBlock,
+			InitialBlock:         initialBlock,
+		})
+	}
+
+	for _, override := range out {
+		module, _ := graph.Module(override.ModuleName)
+		module.InitialBlock = override.InitialBlock
+	}
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package manifest
+
+import (
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyInitialBlockOverrides(t *testing.T) {
+	tests := []struct {
+		name                string
+		overrides           map[string]uint64
+		expectInitialBlocks map[string]uint64
+		expectError         string
+	}{
+		{name: "none", expectInitialBlocks: map[string]uint64{"map_transfers": 10, "store_balances": 20, "map_holders": 20}},
+		{
+			name:                "leaf",
+			overrides:           map[string]uint64{"map_holders": 15_000_000},
+			expectInitialBlocks: map[string]uint64{"map_transfers": 10, "store_balances": 20, "map_holders": 15_000_000},
+		},
+		{
+			name:                "ancestor and leaf",
+			overrides:           map[string]uint64{"store_balances": 30, "map_holders": 20},
+			expectInitialBlocks: map[string]uint64{"map_transfers": 10, "store_balances": 30, "map_holders": 20},
+		},
+		{name: "unknown module", overrides: map[string]uint64{"map_approvals": 30}, expectError: `initial block override of module "map_approvals": module not found`},
+		{name: "lower than declared", overrides: map[string]uint64{"store_balances": 15}, expectError: `initial block override of module "store_balances": block 15 is lower than its declared initial block 20`},
+		{name: "lower than ancestor", overrides: map[string]uint64{"map_holders": 5}, expectError: `initial block override of module "map_holders": block 5 is lower than its declared initial block 20`},
+		{name: "lower than overridden ancestor", overrides: map[string]uint64{"store_balances": 30, "map_holders": 25}, expectInitialBlocks: map[string]uint64{"map_transfers": 10, "store_balances": 30, "map_holders": 25}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modules := []*pbsubstreams.Module{
+				{Name: "map_transfers", InitialBlock: 10, Kind: &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}}},
+				{
+					Name:         "store_balances",
+					InitialBlock: 20,
+					Kind:         &pbsubstreams.Module_KindStore_{KindStore: &pbsubstreams.Module_KindStore{}},
+					Inputs:       []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: "map_transfers"}}}},
+				},
+				{
+					Name:         "map_holders",
+					InitialBlock: 20,
+					Kind:         &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}},
+					Inputs:       []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Store_{Store: &pbsubstreams.Module_Input_Store{ModuleName: "store_balances"}}}},
+				},
+			}
+			graph, err := NewModuleGraph(modules)
+			require.NoError(t, err)
+
+			applied, err := ApplyInitialBlockOverrides(graph, test.overrides)
+			if test.expectError != "" {
+				require.EqualError(t, err, test.expectError)
+				assert.Equal(t, uint64(20), modules[1].InitialBlock, "nothing applied")
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, applied, len(test.overrides))
+
+			initialBlocks := map[string]uint64{}
+			for _, module := range modules {
+				initialBlocks[module.Name] = module.InitialBlock
+			}
+			assert.Equal(t, test.expectInitialBlocks, initialBlocks)
+		})
+	}
+}
+
+func TestApplyInitialBlockOverrides_AncestorError(t *testing.T) {
+	modules := []*pbsubstreams.Module{
+		{Name: "map_transfers", InitialBlock: 100, Kind: &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}}},
+		{
+			Name:         "map_holders",
+			InitialBlock: 10,
+			Kind:         &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}},
+			Inputs:       []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: "map_transfers"}}}},
+		},
+	}
+	graph, err := NewModuleGraph(modules)
+	require.NoError(t, err)
+
+	_, err = ApplyInitialBlockOverrides(graph, map[string]uint64{"map_holders": 50})
+	require.EqualError(t, err, `initial block override of module "map_holders": block 50 is lower than the declared initial block 100 of its ancestor "map_transfers"`)
+}
@@ -2,13 +2,11 @@ package manifest
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
 	"path/filepath"
 	"regexp"
-	"strings"
 
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"gopkg.in/yaml.v3"
@@ -23,28 +21,45 @@ func init() {
 }
 
 const (
-	ModuleKindStore = "store"
-	ModuleKindMap   = "map"
+	ModuleKindStore      = "store"
+	ModuleKindMap        = "map"
+	ModuleKindBlockIndex = "blockIndex"
 )
 
+// BinaryTypes lists the types of the binaries modules can be compiled to:
+// wasm for Rust modules, and wasm importing WASI functions.
+var BinaryTypes = []string{"wasm/rust-v1", "wasm/rust-v1+wasi"}
+
+func isBinaryType(binaryType string) bool {
+	for _, t := range BinaryTypes {
+		if t == binaryType {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockIndexKeysType is the output type of block index modules.
+const BlockIndexKeysType = "proto:sf.substreams.v1.BlockIndexKeys"
+
 // Manifest is a YAML structure used to create a Package and its list
 // of Modules. The notion of a manifest does not live in protobuf definitions.
 type Manifest struct {
 	SpecVersion string            `yaml:"specVersion"` // check that it equals v0.1.0
 	Package     PackageMeta       `yaml:"package"`
+	Network     string            `yaml:"network"`
 	Protobuf    Protobuf          `yaml:"protobuf"`
 	Imports     mapSlice          `yaml:"imports"`
 	Binaries    map[string]Binary `yaml:"binaries"`
 	Modules     []*Module         `yaml:"modules"`
+	Sink        *Sink             `yaml:"sink"`
 
 	Graph   *ModuleGraph `yaml:"-"`
 	Workdir string       `yaml:"-"`
 }
 
-var httpSchemePrefixRegex = regexp.MustCompile("^https?://")
-
 func (m *Manifest) resolvePath(path string) string {
-	if m.Workdir == "" || filepath.IsAbs(path) || httpSchemePrefixRegex.MatchString(path) {
+	if m.Workdir == "" || filepath.IsAbs(path) || isRemoteReference(path) {
 		return path
 	}
 
@@ -59,8 +74,10 @@ type PackageMeta struct {
 }
 
 type Protobuf struct {
-	Files       []string `yaml:"files"`
-	ImportPaths []string `yaml:"importPaths"`
+	Files        []string `yaml:"files"`
+	ImportPaths  []string `yaml:"importPaths"`
+	ExcludePaths []string `yaml:"excludePaths"`
+	IncludeOnly  []string `yaml:"includeOnly"`
 }
 
 type Module struct {
@@ -75,6 +92,14 @@ type Module struct {
 	//Code         Code         `yaml:"code"`
 	Inputs []*Input     `yaml:"inputs"`
 	Output StreamOutput `yaml:"output"`
+
+	BlockFilter *BlockFilter `yaml:"blockFilter"`
+	Params      string       `yaml:"params"`
+}
+
+type BlockFilter struct {
+	Module string `yaml:"module"`
+	Query  string `yaml:"query"`
 }
 
 type Input struct {
@@ -92,6 +117,9 @@ type Binary struct {
 	Native     string `yaml:"native"`
 	Content    []byte `yaml:"-"`
 	Entrypoint string `yaml:"entrypoint"`
+	// Sha256 is the hex-encoded sha256 digest `File` must have, required
+	// for the files fetched from a URL in strict mode.
+	Sha256 string `yaml:"sha256"`
 }
 
 type StreamOutput struct {
@@ -99,12 +127,19 @@ type StreamOutput struct {
 	Type string `yaml:"type"`
 }
 
-func decodeYamlManifestFromFile(yamlFilePath string) (out *Manifest, err error) {
+func decodeYamlManifestFromFile(yamlFilePath string, vars map[string]string) (out *Manifest, err error) {
 	cnt, err := ioutil.ReadFile(yamlFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("reading substreams manifest %q: %w", yamlFilePath, err)
 	}
-	if err := yaml.NewDecoder(bytes.NewReader(cnt)).Decode(&out); err != nil {
+	var node yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(cnt)).Decode(&node); err != nil {
+		return nil, fmt.Errorf("decoding manifest content: %w", err)
+	}
+	if err := substituteVariables(&node, vars); err != nil {
+		return nil, fmt.Errorf("substituting variables: %w", err)
+	}
+	if err := node.Decode(&out); err != nil {
 		return nil, fmt.Errorf("decoding manifest content: %w", err)
 	}
 	return
@@ -126,10 +161,7 @@ func (i *Input) parse() error {
 	if i.isStore() {
 		i.Name = fmt.Sprintf("store:%s", i.Store)
 		if i.Mode == "" {
-			i.Mode = "get"
-		}
-		if i.Mode != "get" && i.Mode != "deltas" {
-			return fmt.Errorf("input %q: 'mode' parameter must be one of: 'get', 'deltas'", i.Name)
+			i.Mode = StoreModeGet
 		}
 		return nil
 	}
@@ -140,54 +172,56 @@ func (i *Input) parse() error {
 	return fmt.Errorf("input has an unknown type. Expect one, and only one of 'map', 'store' or 'source'")
 }
 
-func validateStoreBuilder(module *Module) error {
-	if module.UpdatePolicy == "" {
-		return errors.New("missing 'output.updatePolicy' for kind 'store'")
-	}
-	if module.ValueType == "" {
-		return errors.New("missing 'output.valueType' for kind 'store'")
-	}
-
-	combinations := []string{
-		"max:bigint",
-		"max:int64",
-		"max:bigfloat",
-		"max:float64",
-		"min:bigint",
-		"min:int64",
-		"min:bigfloat",
-		"min:float64",
-		"add:bigint",
-		"add:int64",
-		"add:bigfloat",
-		"add:float64",
-		"set:bytes",
-		"set:string",
-		"set:proto",
-		"set_if_not_exists:bytes",
-		"set_if_not_exists:string",
-		"set_if_not_exists:proto",
-		"append:bytes",
-		"append:string",
-	}
-	found := false
-	var lastCombination string
-	for _, comb := range combinations {
-		valType := module.ValueType
-		if strings.HasPrefix(valType, "proto:") {
-			valType = "proto"
+// validate adds to `problems` what is wrong with the module declared at
+// `field` of the manifest.
+func (m *Module) validate(field string, problems *ValidationErrors) {
+	switch m.Kind {
+	case ModuleKindMap:
+		if m.Output.Type == "" {
+			problems.add(field+".output.type", "module %q: missing 'output.type' for kind 'map'", m.Name)
+		}
+	case ModuleKindStore:
+		if m.UpdatePolicy == "" {
+			problems.add(field+".updatePolicy", "module %q: missing 'output.updatePolicy' for kind 'store'", m.Name)
 		}
-		lastCombination = fmt.Sprintf("%s:%s", module.UpdatePolicy, valType)
-		if lastCombination == comb {
-			found = true
+		if m.ValueType == "" {
+			problems.add(field+".valueType", "module %q: missing 'output.valueType' for kind 'store'", m.Name)
 		}
+		if m.UpdatePolicy != "" && m.ValueType != "" {
+			if err := validateStoreCombination(m.UpdatePolicy, m.ValueType); err != nil {
+				problems.add(field+".updatePolicy", "module %q: %s", m.Name, err)
+			}
+		}
+	case ModuleKindBlockIndex:
+		if m.Output.Type == "" {
+			m.Output.Type = BlockIndexKeysType
+		}
+		if m.Output.Type != BlockIndexKeysType {
+			problems.add(field+".output.type", "module %q: 'output.type' must be %q for kind 'blockIndex'", m.Name, BlockIndexKeysType)
+		}
+	default:
+		problems.add(field+".kind", "module %q: invalid kind %q", m.Name, m.Kind)
 	}
 
-	if !found {
-		return fmt.Errorf("invalid 'output.updatePolicy' and 'output.valueType' combination, found %q use one of: %s", lastCombination, combinations)
+	for idx, input := range m.Inputs {
+		inputField := fmt.Sprintf("%s.inputs[%d]", field, idx)
+		if err := input.parse(); err != nil {
+			problems.add(inputField, "module %q: %s", m.Name, err)
+			continue
+		}
+		if !input.isStore() {
+			if input.Mode != "" {
+				problems.add(inputField+".mode", "module %q: input %q: 'mode' only applies to store inputs", m.Name, input.Name)
+			}
+			continue
+		}
+		if _, found := storeModes[input.Mode]; !found {
+			problems.add(inputField+".mode", "module %q: input %q: unknown mode %q, expected one of: %s, %s", m.Name, input.Name, input.Mode, StoreModeGet, StoreModeDeltas)
+		}
+		if input.Store == m.Name {
+			problems.add(inputField+".store", "module %q: a module can't take its own store as input, found in %q mode", m.Name, input.Mode)
+		}
 	}
-
-	return nil
 }
 
 func (m *Module) String() string {
@@ -199,6 +233,7 @@ func (m *Module) ToProtoWASM(codeIndex uint32) (*pbsubstreams.Module, error) {
 		Name:             m.Name,
 		BinaryIndex:      codeIndex,
 		BinaryEntrypoint: m.Name,
+		Params:           m.Params,
 	}
 
 	out.InitialBlock = UNSET
@@ -208,6 +243,12 @@ func (m *Module) ToProtoWASM(codeIndex uint32) (*pbsubstreams.Module, error) {
 
 	m.setOutputToProto(out)
 	m.setKindToProto(out)
+	if m.BlockFilter != nil {
+		out.BlockFilter = &pbsubstreams.Module_BlockFilter{
+			Module: m.BlockFilter.Module,
+			Query:  m.BlockFilter.Query,
+		}
+	}
 	err := m.setInputsToProto(out)
 	if err != nil {
 		return nil, fmt.Errorf("setting input for module, %s: %w", m.Name, err)
@@ -242,17 +283,13 @@ func (m *Module) setInputsToProto(pbModule *pbsubstreams.Module) error {
 		}
 		if input.Store != "" {
 
-			var mode pbsubstreams.Module_Input_Store_Mode
-
-			switch input.Mode {
-			case "":
-				mode = pbsubstreams.Module_Input_Store_UNSET
-			case "get":
-				mode = pbsubstreams.Module_Input_Store_GET
-			case "deltas":
-				mode = pbsubstreams.Module_Input_Store_DELTAS
-			default:
-				panic(fmt.Sprintf("invalid input mode %s", input.Mode))
+			inputMode := input.Mode
+			if inputMode == "" {
+				inputMode = StoreModeGet
+			}
+			mode, found := storeModes[inputMode]
+			if !found {
+				return fmt.Errorf("store input %q: unknown mode %q", input.Store, input.Mode)
 			}
 
 			pbInput := &pbsubstreams.Module_Input{
@@ -273,6 +310,19 @@ func (m *Module) setInputsToProto(pbModule *pbsubstreams.Module) error {
 	return nil
 }
 
+// The modes of the store inputs of a module: in `get` mode, the default, the
+// module reads the store through the store functions, in `deltas` mode it
+// receives the changes made to the store by the current block.
+const (
+	StoreModeGet    = "get"
+	StoreModeDeltas = "deltas"
+)
+
+var storeModes = map[string]pbsubstreams.Module_Input_Store_Mode{
+	StoreModeGet:    pbsubstreams.Module_Input_Store_GET,
+	StoreModeDeltas: pbsubstreams.Module_Input_Store_DELTAS,
+}
+
 const (
 	UpdatePolicySet            = "set"
 	UpdatePolicySetIfNotExists = "set_if_not_exists"
@@ -290,6 +340,10 @@ func (m *Module) setKindToProto(pbModule *pbsubstreams.Module) {
 				OutputType: m.Output.Type,
 			},
 		}
+	case ModuleKindBlockIndex:
+		pbModule.Kind = &pbsubstreams.Module_KindBlockIndex_{
+			KindBlockIndex: &pbsubstreams.Module_KindBlockIndex{},
+		}
 	case ModuleKindStore:
 		var updatePolicy pbsubstreams.Module_KindStore_UpdatePolicy
 		switch m.UpdatePolicy {
@@ -11,7 +11,7 @@ import (
 )
 
 func TestManifest_YamlUnmarshal(t *testing.T) {
-	manifest, err := decodeYamlManifestFromFile("./test/test_manifest.yaml")
+	manifest, err := decodeYamlManifestFromFile("./test/test_manifest.yaml", nil)
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, len(manifest.Modules), 1)
 }
@@ -121,6 +121,7 @@ func TestManifest_ToProto(t *testing.T) {
 	require.Equal(t, 2, len(module.Inputs))
 	require.Equal(t, "sf.ethereum.type.v1.Block", module.Inputs[0].GetSource().Type)
 	require.Equal(t, "build_pairs_state", module.Inputs[1].GetStore().ModuleName)
+	require.Equal(t, pbsubstreams.Module_Input_Store_GET, module.Inputs[1].GetStore().Mode)
 	require.Equal(t, "proto:pcs.types.v1.Reserves", module.Output.Type)
 
 	module = pbManifest.Modules[3]
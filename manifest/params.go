@@ -0,0 +1,40 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// ApplyParams overrides the params of `modules`, declared with the `params`
+// field of their manifest module, with the ones of `params`, each one
+// formatted as `<module_name>=<value>`. Params being part of the module
+// hash, the modules depending on an overridden one get another hash too, so
+// their caches are kept apart.
+func ApplyParams(modules *pbsubstreams.Modules, params []string) error {
+	seen := map[string]bool{}
+	for _, param := range params {
+		moduleName, value, found := strings.Cut(param, "=")
+		if !found {
+			return fmt.Errorf("invalid param %q, expected <module_name>=<value>", param)
+		}
+		if seen[moduleName] {
+			return fmt.Errorf("param %q: module %q given params more than once", param, moduleName)
+		}
+		seen[moduleName] = true
+
+		var module *pbsubstreams.Module
+		for _, mod := range modules.Modules {
+			if mod.Name == moduleName {
+				module = mod
+				break
+			}
+		}
+		if module == nil {
+			return fmt.Errorf("param %q: module %q not found", param, moduleName)
+		}
+		module.Params = value
+	}
+	return nil
+}
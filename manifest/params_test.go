@@ -0,0 +1,48 @@
+package manifest
+
+import (
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       []string
+		expectParams map[string]string
+		expectError  bool
+	}{
+		{name: "defaults", expectParams: map[string]string{"map_transfers": "0xabc", "store_balances": ""}},
+		{name: "override", params: []string{"map_transfers=0xdef"}, expectParams: map[string]string{"map_transfers": "0xdef", "store_balances": ""}},
+		{name: "set undeclared", params: []string{"store_balances=usdc"}, expectParams: map[string]string{"map_transfers": "0xabc", "store_balances": "usdc"}},
+		{name: "value with equal sign", params: []string{"map_transfers=a=b"}, expectParams: map[string]string{"map_transfers": "a=b", "store_balances": ""}},
+		{name: "empty value", params: []string{"map_transfers="}, expectParams: map[string]string{"map_transfers": "", "store_balances": ""}},
+		{name: "unknown module", params: []string{"map_approvals=0xdef"}, expectError: true},
+		{name: "missing value", params: []string{"map_transfers"}, expectError: true},
+		{name: "given twice", params: []string{"map_transfers=a", "map_transfers=b"}, expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modules := &pbsubstreams.Modules{Modules: []*pbsubstreams.Module{
+				{Name: "map_transfers", Params: "0xabc"},
+				{Name: "store_balances"},
+			}}
+			err := ApplyParams(modules, test.params)
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			params := map[string]string{}
+			for _, module := range modules.Modules {
+				params[module.Name] = module.Params
+			}
+			assert.Equal(t, test.expectParams, params)
+		})
+	}
+}
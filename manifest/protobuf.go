@@ -2,7 +2,15 @@ package manifest
 
 import (
 	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/pb/system"
@@ -16,15 +24,15 @@ func loadProtobufs(pkg *pbsubstreams.Package, manif *Manifest) error {
 	if err != nil {
 		return err
 	}
-	seen := map[string]bool{}
+	seen := map[string]*descriptorpb.FileDescriptorProto{}
 	for _, file := range systemFiles.File {
 		pkg.ProtoFiles = append(pkg.ProtoFiles, file)
-		seen[*file.Name] = true
+		seen[*file.Name] = file
 	}
 
-	var importPaths []string
-	for _, imp := range manif.Protobuf.ImportPaths {
-		importPaths = append(importPaths, manif.resolvePath(imp))
+	importPaths, err := manif.resolveProtoImportPaths()
+	if err != nil {
+		return err
 	}
 	// User-specified protos
 	parser := protoparse.Parser{
@@ -33,21 +41,378 @@ func loadProtobufs(pkg *pbsubstreams.Package, manif *Manifest) error {
 	}
 
 	for _, file := range manif.Protobuf.Files {
-		if seen[file] {
+		if seen[file] != nil {
 			return fmt.Errorf("WARNING: proto file %s already exists in system protobufs, do not include in your manifest", file)
 		}
 	}
-	customFiles, err := parser.ParseFiles(manif.Protobuf.Files...)
+	files, err := resolveProtoFiles(manif.Protobuf.Files, importPaths, seen)
+	if err != nil {
+		return err
+	}
+	customFiles, err := parser.ParseFiles(files...)
 	if err != nil {
-		return fmt.Errorf("error parsing proto files %q (import paths: %q): %w", manif.Protobuf.Files, importPaths, err)
+		return fmt.Errorf("error parsing proto files %q (import paths: %q): %w", files, importPaths, err)
+	}
+
+	// The files listed are packed along with the files they import, the
+	// imported ones first, unless the manifest leaves them out. The system
+	// files imported are the ones already packed.
+	var visit func(fd *desc.FileDescriptor)
+	visit = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] != nil {
+			return
+		}
+		file := fd.AsFileDescriptorProto()
+		seen[fd.GetName()] = file
+		for _, dep := range fd.GetDependencies() {
+			visit(dep)
+		}
+		if manif.Protobuf.packs(fd.GetName()) {
+			pkg.ProtoFiles = append(pkg.ProtoFiles, file)
+		}
 	}
 	for _, fd := range customFiles {
-		pkg.ProtoFiles = append(pkg.ProtoFiles, fd.AsFileDescriptorProto())
+		visit(fd)
 	}
 
 	return nil
 }
 
+// resolveProtoImportPaths returns the import paths of the manifest, relative
+// to it, the glob patterns replaced by the directories they match, sorted.
+func (m *Manifest) resolveProtoImportPaths() ([]string, error) {
+	var importPaths []string
+	for idx, imp := range m.Protobuf.ImportPaths {
+		importPath := m.resolvePath(imp)
+		if !isGlobPattern(imp) {
+			importPaths = append(importPaths, importPath)
+			continue
+		}
+
+		matches, err := filepath.Glob(importPath)
+		if err != nil {
+			return nil, fmt.Errorf("protobuf.importPaths[%d]: invalid pattern %q: %w", idx, imp, err)
+		}
+		var dirs []string
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				dirs = append(dirs, match)
+			}
+		}
+		if len(dirs) == 0 {
+			return nil, fmt.Errorf("protobuf.importPaths[%d]: pattern %q matches no directory", idx, imp)
+		}
+		importPaths = append(importPaths, dirs...)
+	}
+	return importPaths, nil
+}
+
+// resolveProtoFiles returns the protobuf files to parse, as imported from
+// `importPaths`, replacing the directories and the glob patterns of `files`
+// by the `.proto` files they match. The files matched are sorted, for the
+// package to be reproducible, and the ones found in more than one import
+// path are taken from the first, like the parser does. The patterns support
+// `**` to match any number of directories, ex: `sf/**/*.proto`, and the
+// system files they match are left out, being already packed.
+func resolveProtoFiles(files []string, importPaths []string, system map[string]*descriptorpb.FileDescriptorProto) ([]string, error) {
+	roots := importPaths
+	if len(roots) == 0 {
+		// The parser then imports from the working directory
+		roots = []string{"."}
+	}
+
+	var resolved []string
+	seen := map[string]bool{}
+	for idx, file := range files {
+		pattern := path.Clean(filepath.ToSlash(file))
+		if !isGlobPattern(file) {
+			if !isProtoDirectory(roots, pattern) {
+				if !seen[file] {
+					seen[file] = true
+					resolved = append(resolved, file)
+				}
+				continue
+			}
+			pattern += "/**/*.proto"
+		}
+
+		matches, err := matchProtoFiles(roots, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("protobuf.files[%d]: %w", idx, err)
+		}
+		var found bool
+		for _, match := range matches {
+			if system[match] != nil {
+				continue
+			}
+			found = true
+			if !seen[match] {
+				seen[match] = true
+				resolved = append(resolved, match)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("protobuf.files[%d]: %q matches no .proto file in the import paths %q", idx, file, roots)
+		}
+	}
+	return resolved, nil
+}
+
+func isGlobPattern(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+func isProtoDirectory(roots []string, name string) bool {
+	for _, root := range roots {
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err == nil {
+			return info.IsDir()
+		}
+	}
+	return false
+}
+
+// matchProtoFiles returns the names, relative to their import path, of the
+// `.proto` files of `roots` matching `pattern`, sorted.
+func matchProtoFiles(roots []string, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	seen := map[string]bool{}
+	var matches []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || filepath.Ext(filePath) != ".proto" {
+				return nil
+			}
+			rel, err := filepath.Rel(root, filePath)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if !seen[name] && matchGlob(strings.Split(pattern, "/"), strings.Split(name, "/")) {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking import path %q: %w", root, err)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchGlob matches the segments of a path against the ones of a pattern,
+// `**` matching any number of them.
+func matchGlob(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlob(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+	return matchGlob(pattern[1:], segments[1:])
+}
+
+// packs tells if the protobuf file `name`, as imported, goes in the package
+// according to the `includeOnly` and `excludePaths` of the manifest. Each of
+// them is a file, a directory or a glob pattern relative to the import paths.
+func (p Protobuf) packs(name string) bool {
+	if len(p.IncludeOnly) != 0 && !matchesProtoPath(p.IncludeOnly, name) {
+		return false
+	}
+	return !matchesProtoPath(p.ExcludePaths, name)
+}
+
+func matchesProtoPath(paths []string, name string) bool {
+	for _, protoPath := range paths {
+		protoPath = strings.TrimSuffix(protoPath, "/")
+		if name == protoPath || strings.HasPrefix(name, protoPath+"/") {
+			return true
+		}
+		if matched, _ := path.Match(protoPath, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSameProtoFile errors when two protobuf files of the same name don't
+// define the same things, their comments and file options aside.
+func checkSameProtoFile(file, other *descriptorpb.FileDescriptorProto) error {
+	if proto.Equal(protoFileContent(file), protoFileContent(other)) {
+		return nil
+	}
+	return fmt.Errorf("protobuf file %q defined twice with different contents", file.GetName())
+}
+
+func protoFileContent(file *descriptorpb.FileDescriptorProto) *descriptorpb.FileDescriptorProto {
+	content := proto.Clone(file).(*descriptorpb.FileDescriptorProto)
+	content.SourceCodeInfo = nil
+	content.Options = nil
+	return content
+}
+
+// protoTypes indexes the messages and enums defined by protobuf files, by
+// full name.
+type protoTypes struct {
+	messages map[string]*descriptorpb.DescriptorProto
+	enums    map[string]bool
+}
+
+func newProtoTypes(files []*descriptorpb.FileDescriptorProto) *protoTypes {
+	types := &protoTypes{
+		messages: map[string]*descriptorpb.DescriptorProto{},
+		enums:    map[string]bool{},
+	}
+	var addMessages func(prefix string, messages []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto)
+	addMessages = func(prefix string, messages []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto) {
+		fullName := func(name string) string {
+			if prefix == "" {
+				return name
+			}
+			return prefix + "." + name
+		}
+		for _, enum := range enums {
+			types.enums[fullName(enum.GetName())] = true
+		}
+		for _, message := range messages {
+			name := fullName(message.GetName())
+			types.messages[name] = message
+			addMessages(name, message.NestedType, message.EnumType)
+		}
+	}
+	for _, file := range files {
+		addMessages(file.GetPackage(), file.MessageType, file.EnumType)
+	}
+	return types
+}
+
+// unresolved returns the types used by the fields of message `name`, or by
+// the messages it uses in turn, which are not defined.
+func (t *protoTypes) unresolved(name string) (missing []string) {
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, field := range t.messages[name].Field {
+			typeName := strings.TrimPrefix(field.GetTypeName(), ".")
+			switch {
+			case typeName == "", t.enums[typeName]:
+			case t.messages[typeName] != nil:
+				visit(typeName)
+			default:
+				missing = append(missing, typeName)
+				visited[typeName] = true
+			}
+		}
+	}
+	visit(name)
+	return missing
+}
+
+// maxTypeSuggestions bounds the names suggested for a type not found.
+const maxTypeSuggestions = 3
+
+// closest returns the names of the messages closest to `name`, a message not
+// found, to suggest in place of it: the ones a few edits away, like `Pool`
+// for `Pools`, and the ones with the same name in another package.
+func (t *protoTypes) closest(name string) []string {
+	shortName := strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	// Bounded by the length of the message name alone, so that a long
+	// package name doesn't make distant names look close
+	maxDistance := len(shortName) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	distances := map[string]int{}
+	var candidates []string
+	for candidate := range t.messages {
+		distance := editDistance(name, candidate)
+		if distance > maxDistance && strings.ToLower(candidate[strings.LastIndex(candidate, ".")+1:]) != shortName {
+			continue
+		}
+		distances[candidate] = distance
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if distances[candidates[i]] != distances[candidates[j]] {
+			return distances[candidates[i]] < distances[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > maxTypeSuggestions {
+		candidates = candidates[:maxTypeSuggestions]
+	}
+	return candidates
+}
+
+// notFound describes message `name` as not found in the protobuf files of a
+// package, suggesting the closest messages found instead.
+func (t *protoTypes) notFound(name string) string {
+	msg := fmt.Sprintf("protobuf type %q not found in the protobuf definitions of the package", name)
+	if closest := t.closest(name); len(closest) != 0 {
+		quoted := make([]string, len(closest))
+		for i, candidate := range closest {
+			quoted[i] = strconv.Quote(candidate)
+		}
+		msg += fmt.Sprintf(", did you mean %s?", strings.Join(quoted, " or "))
+	}
+	return msg
+}
+
+// editDistance is the Levenshtein distance between `a` and `b`, the number
+// of characters to insert, delete or substitute to turn one into the other.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
 func readSystemProtobufs() (*descriptorpb.FileDescriptorSet, error) {
 	fds := &descriptorpb.FileDescriptorSet{}
 	err := proto.Unmarshal(system.ProtobufDescriptors, fds)
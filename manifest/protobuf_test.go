@@ -0,0 +1,168 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func userProtoFileNames(t *testing.T, pkg *pbsubstreams.Package) (names []string) {
+	t.Helper()
+
+	system := map[string]bool{}
+	for _, file := range readSystemProtoDescriptors(t) {
+		system[file.GetName()] = true
+	}
+	for _, file := range pkg.ProtoFiles {
+		if !system[file.GetName()] {
+			names = append(names, file.GetName())
+		}
+	}
+	return names
+}
+
+// writeProtopackManifest writes a variant of the `transfers.yaml` manifest
+// next to it, for its paths to resolve.
+func writeProtopackManifest(t *testing.T, name string, replacements ...string) string {
+	t.Helper()
+
+	cnt, err := os.ReadFile("testdata/protopack/transfers.yaml")
+	require.NoError(t, err)
+	manifest := strings.NewReplacer(replacements...).Replace(string(cnt))
+
+	manifestPath := filepath.Join("testdata/protopack", strings.ReplaceAll(name, " ", "_")+".yaml")
+	require.NoError(t, os.WriteFile(manifestPath, []byte(manifest), 0644))
+	t.Cleanup(func() { os.Remove(manifestPath) })
+	return manifestPath
+}
+
+func TestReader_ProtobufPacking(t *testing.T) {
+	pkg, err := NewReader("testdata/protopack/transfers.yaml", SkipSourceCodeReader()).Read()
+	require.NoError(t, err)
+
+	// The file imported from both proto roots is packed once, the vendored
+	// one is left out.
+	assert.Equal(t, []string{"common/v1/common.proto", "transfers/v1/transfers.proto", "balances/v1/balances.proto"}, userProtoFileNames(t, pkg))
+
+	cnt, err := proto.Marshal(pkg)
+	require.NoError(t, err)
+	spkgPath := filepath.Join(t.TempDir(), "transfers.spkg")
+	require.NoError(t, os.WriteFile(spkgPath, cnt, 0644))
+	readBack, err := NewReader(spkgPath).Read()
+	require.NoError(t, err)
+	assertProtoEqual(t, pkg, readBack)
+
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: readBack.ProtoFiles})
+	require.NoError(t, err)
+	for _, name := range []string{"transfers.v1.Transfers", "balances.v1.Balances", "common.v1.Amount"} {
+		_, err := files.FindDescriptorByName(protoreflect.FullName(name))
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestReader_ProtobufIncludeOnly(t *testing.T) {
+	manifestPath := writeProtopackManifest(t, "include only",
+		"  excludePaths:\n    - vendor/\n", "  includeOnly:\n    - transfers/\n    - common/v1/common.proto\n",
+	)
+
+	pkg, err := NewReader(manifestPath, SkipSourceCodeReader()).Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"common/v1/common.proto", "transfers/v1/transfers.proto", "balances/v1/balances.proto"}, userProtoFileNames(t, pkg))
+}
+
+func TestReader_ProtobufPatterns(t *testing.T) {
+	listedFiles := "    - transfers/v1/transfers.proto\n    - vendor/unrelated/v1/unrelated.proto\n"
+
+	tests := []struct {
+		name         string
+		replacements []string
+	}{
+		{name: "directory", replacements: []string{listedFiles, "    - transfers\n    - vendor/\n"}},
+		{name: "glob", replacements: []string{listedFiles, "    - \"**/*.proto\"\n"}},
+		{name: "glob in directory", replacements: []string{listedFiles, "    - \"transfers/*/*.proto\"\n    - vendor/**\n"}},
+		{name: "glob import paths", replacements: []string{listedFiles, "    - \"**/transfers.proto\"\n", "    - ./root1\n", "    - ./root[1]\n"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifestPath := writeProtopackManifest(t, test.name, test.replacements...)
+
+			pkg, err := NewReader(manifestPath, SkipSourceCodeReader()).Read()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"common/v1/common.proto", "transfers/v1/transfers.proto", "balances/v1/balances.proto"}, userProtoFileNames(t, pkg))
+		})
+	}
+}
+
+func TestResolveProtoFiles(t *testing.T) {
+	roots := []string{"testdata/protopack/root1", "testdata/protopack/root2"}
+	system := map[string]*descriptorpb.FileDescriptorProto{"common/v1/common.proto": {}}
+
+	files, err := resolveProtoFiles([]string{"**/*.proto", "balances", "transfers/v1/transfers.proto"}, roots, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"balances/v1/balances.proto",
+		"common/v1/common.proto",
+		"transfers/v1/transfers.proto",
+		"vendor/unrelated/v1/unrelated.proto",
+	}, files)
+
+	files, err = resolveProtoFiles([]string{"*/v1/*.proto"}, roots, system)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"balances/v1/balances.proto", "transfers/v1/transfers.proto"}, files)
+}
+
+func TestReader_ProtobufPackingErrors(t *testing.T) {
+	withoutImports := []string{"imports:\n  balances: ./balances.yaml\n", ""}
+
+	tests := []struct {
+		name         string
+		replacements []string
+		expectError  string
+	}{
+		{
+			name:         "conflicting import",
+			replacements: []string{"  balances: ./balances.yaml\n", "  balances: ./balances.yaml\n  conflicting: ./conflicting.yaml\n"},
+			expectError:  `import "conflicting": protobuf file "common/v1/common.proto" defined twice with different contents`,
+		},
+		{
+			name:         "excluded dependency",
+			replacements: append([]string{"    - vendor/\n", "    - vendor/\n    - common/\n"}, withoutImports...),
+			expectError:  `modules[0].output.type: module "map_transfers": protobuf type "transfers.v1.Transfers" uses type "common.v1.Amount", not found in the protobuf definitions of the package`,
+		},
+		{
+			name:         "pattern matching nothing",
+			replacements: []string{"    - transfers/v1/transfers.proto\n", "    - \"missing/**/*.proto\"\n"},
+			expectError:  `protobuf.files[0]: "missing/**/*.proto" matches no .proto file in the import paths`,
+		},
+		{
+			name:         "import paths matching nothing",
+			replacements: []string{"    - ./root1\n", "    - ./missing*\n"},
+			expectError:  `protobuf.importPaths[0]: pattern "./missing*" matches no directory`,
+		},
+		{
+			name:         "dependency not included",
+			replacements: append([]string{"  excludePaths:\n    - vendor/\n", "  includeOnly:\n    - transfers/*/*.proto\n"}, withoutImports...),
+			expectError:  `protobuf type "transfers.v1.Transfers" uses type "common.v1.Amount"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifestPath := writeProtopackManifest(t, test.name, test.replacements...)
+
+			_, err := NewReader(manifestPath, SkipSourceCodeReader()).Read()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectError)
+		})
+	}
+}
@@ -5,15 +5,14 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"go.uber.org/zap"
-	"golang.org/x/mod/semver"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 type Options func(r *Reader) *Reader
@@ -30,6 +29,9 @@ type Reader struct {
 
 	//options
 	skipSourceCodeImportValidation bool
+	variables                      map[string]string
+	strictIntegrity                bool
+	downloadCacheDir               string
 }
 
 func NewReader(input string, opts ...Options) *Reader {
@@ -75,7 +77,7 @@ func (r *Reader) newPkgFromURL(fileURL string) (pkg *pbsubstreams.Package, err e
 }
 
 func (r *Reader) newPkgFromManifest(inputPath string) (pkg *pbsubstreams.Package, err error) {
-	manif, err := loadManifestFile(inputPath)
+	manif, err := loadManifestFile(inputPath, r.variables)
 	if err != nil {
 		return nil, err
 	}
@@ -106,14 +108,11 @@ func (r *Reader) fromContents(contents []byte) (pkg *pbsubstreams.Package, err e
 }
 
 func (r *Reader) validate(pkg *pbsubstreams.Package) error {
-	if err := r.validatePackage(pkg); err != nil {
-		return fmt.Errorf("package validation failed: %w", err)
+	problems := r.validatePackage(pkg)
+	if pkg.Modules != nil {
+		problems = append(problems, validateModules(pkg.Modules)...)
 	}
-
-	if err := ValidateModules(pkg.Modules); err != nil {
-		return fmt.Errorf("module validation failed: %w", err)
-	}
-	return nil
+	return problems.err()
 }
 
 // validatePackage validates a package just produced or just read from
@@ -124,121 +123,185 @@ func (r *Reader) validate(pkg *pbsubstreams.Package) error {
 //
 // WARN: put ANY MODULES validation that need to be applied by the
 // server in `ValidateModules`.
-func (r *Reader) validatePackage(pkg *pbsubstreams.Package) error {
-	if len(pkg.ModuleMeta) != len(pkg.Modules.Modules) {
-		return fmt.Errorf("inconsistent package, metadata for modules not same length as modules list")
-	}
+func (r *Reader) validatePackage(pkg *pbsubstreams.Package) (problems ValidationErrors) {
 	if pkg.Version < 1 {
-		return fmt.Errorf("unrecognized package version: %d (are you sure this is a substreams package?)", pkg.Version)
+		problems.add("", "unrecognized package version: %d (are you sure this is a substreams package?)", pkg.Version)
 	}
 	if len(pkg.PackageMeta) == 0 {
-		return fmt.Errorf("no package metadata present in package (are you sure this is a substreams package?)")
+		problems.add("", "no package metadata present in package (are you sure this is a substreams package?)")
+	}
+	if pkg.Modules == nil {
+		problems.add("modules", "no modules present in package")
+	}
+	if len(problems) != 0 {
+		return problems
+	}
+
+	if len(pkg.ModuleMeta) != len(pkg.Modules.Modules) {
+		problems.add("", "inconsistent package, metadata for modules not same length as modules list")
 	}
 
 	for _, spkg := range pkg.PackageMeta {
 		if !moduleNameRegexp.MatchString(spkg.Name) {
-			return fmt.Errorf("package %q: invalid name: must match %s", spkg.Name, moduleNameRegexp.String())
+			problems.add("package.name", "package %q: invalid name: must match %s", spkg.Name, moduleNameRegexp.String())
 		}
-		if !semver.IsValid(spkg.Version) {
-			return fmt.Errorf("package %q: version %q should match Semver", spkg.Name, spkg.Version)
+		// Enforced when packing, older packages are still read
+		if err := ValidateVersion(spkg.Version); err != nil {
+			zlog.Warn("package version is not valid", zap.String("package", spkg.Name), zap.Error(err))
 		}
 	}
 
-	for _, mod := range pkg.Modules.Modules {
-		switch i := mod.Kind.(type) {
-		case *pbsubstreams.Module_KindStore_:
-			valueType := i.KindStore.ValueType
-			if strings.HasPrefix(valueType, "proto:") {
-
-			} else if !validValueTypes[valueType] {
-				return fmt.Errorf("module %q: invalid valueType %q", mod.Name, valueType)
+	// The types of the outputs must be defined by the protobuf definitions
+	// shipped with the package, for clients to decode them, and so must the
+	// types of the sources, for the modules to decode their inputs.
+	types := newProtoTypes(pkg.ProtoFiles)
+	for idx, mod := range pkg.Modules.Modules {
+		field := fmt.Sprintf("modules[%d]", idx)
+		for inputIdx, input := range mod.Inputs {
+			if source := input.GetSource(); source != nil && types.messages[source.Type] == nil {
+				problems.add(fmt.Sprintf("%s.inputs[%d].source", field, inputIdx), "module %q: source %s", mod.Name, types.notFound(source.Type))
 			}
 		}
 
-		for _, in := range mod.Inputs {
-			_ = in
-			// TODO: do more proto ref checking for those inputs listed
+		var typeName string
+		switch i := mod.Kind.(type) {
+		case *pbsubstreams.Module_KindMap_:
+			field, typeName = field+".output.type", i.KindMap.OutputType
+		case *pbsubstreams.Module_KindStore_:
+			field, typeName = field+".valueType", i.KindStore.ValueType
+		}
+		if !strings.HasPrefix(typeName, "proto:") {
+			continue
+		}
+		typeName = strings.TrimPrefix(typeName, "proto:")
+		if types.messages[typeName] == nil {
+			problems.add(field, "module %q: %s", mod.Name, types.notFound(typeName))
+			continue
+		}
+		for _, missing := range types.unresolved(typeName) {
+			problems.add(field, "module %q: protobuf type %q uses type %q, not found in the protobuf definitions of the package", mod.Name, typeName, missing)
 		}
 	}
 
-	// TODO: Loop through inputs, outputs, and check that all internal proto references are satisfied by the FileDescriptors
+	if err := validateSink(pkg); err != nil {
+		problems.add("sink", "%s", err)
+	}
 
-	return nil
+	return problems
 }
 
-// ValidateModules is run both by the client _and_ the server.
+// ValidateModules is run both by the client _and_ the server. It returns
+// ValidationErrors listing all the problems found.
 func ValidateModules(mods *pbsubstreams.Modules) error {
+	return validateModules(mods).err()
+}
+
+func validateModules(mods *pbsubstreams.Modules) (problems ValidationErrors) {
 	var sumCode int
 	for _, binary := range mods.Binaries {
 		sumCode += len(binary.Content)
 	}
 	if sumCode > 100_000_000 {
-		return fmt.Errorf("limit of 100MB of module code size reached")
+		problems.add("binaries", "limit of 100MB of module code size reached")
 	}
 	if len(mods.Modules) > 100 {
-		return fmt.Errorf("limit of 100 modules reached")
+		problems.add("modules", "limit of 100 modules reached")
+	}
+
+	for idx, binary := range mods.Binaries {
+		if !isBinaryType(binary.Type) {
+			problems.add(fmt.Sprintf("binaries[%d].type", idx), "binary %d: unknown type %q, expected one of %q", idx, binary.Type, BinaryTypes)
+		}
+	}
+
+	modules := map[string]*pbsubstreams.Module{}
+	for idx, mod := range mods.Modules {
+		field := fmt.Sprintf("modules[%d]", idx)
+		if _, found := modules[mod.Name]; found {
+			problems.add(field+".name", "module %q: name used by more than one module", mod.Name)
+		}
+		modules[mod.Name] = mod
 	}
 
-	for _, mod := range mods.Modules {
+	for modIdx, mod := range mods.Modules {
+		field := fmt.Sprintf("modules[%d]", modIdx)
+		if int(mod.BinaryIndex) >= len(mods.Binaries) {
+			problems.add(field+".binary", "module %q: binary index %d out of range, the package has %d binaries", mod.Name, mod.BinaryIndex, len(mods.Binaries))
+		}
+
 		for _, segment := range strings.Split(mod.Name, ":") {
 			if !moduleNameRegexp.MatchString(segment) {
-				return fmt.Errorf("module %q: segment %q does not match regex %s", mod.Name, segment, moduleNameRegexp.String())
+				problems.add(field+".name", "module %q: segment %q does not match regex %s", mod.Name, segment, moduleNameRegexp.String())
+			}
+		}
+		if isReservedModuleName(mod.BinaryEntrypoint) {
+			problems.add(field+".name", "module %q: entrypoint %q collides with a function reserved by the runtime, one of %q", mod.Name, mod.BinaryEntrypoint, ReservedModuleNames)
+		}
+
+		if store, ok := mod.Kind.(*pbsubstreams.Module_KindStore_); ok {
+			updatePolicy, ok := updatePolicyNames[store.KindStore.UpdatePolicy]
+			if !ok {
+				problems.add(field+".updatePolicy", "module %q: unknown update policy value %d", mod.Name, store.KindStore.UpdatePolicy)
+			} else if err := validateStoreCombination(updatePolicy, store.KindStore.ValueType); err != nil {
+				problems.add(field+".updatePolicy", "module %q: %s", mod.Name, err)
 			}
 		}
 
 		if len(mod.Inputs) > 30 {
-			return fmt.Errorf("limit of 30 inputs for a given module (%q) reached", mod.Name)
+			problems.add(field+".inputs", "limit of 30 inputs for a given module (%q) reached", mod.Name)
 		}
 
 		for idx, in := range mod.Inputs {
+			inputField := fmt.Sprintf("%s.inputs[%d]", field, idx)
 			switch i := in.Input.(type) {
 			case *pbsubstreams.Module_Input_Source_:
 				if i.Source.Type == "" {
-					return fmt.Errorf("module %q: source type empty", mod.Name)
+					problems.add(inputField+".source", "module %q: source type empty", mod.Name)
 				}
 			case *pbsubstreams.Module_Input_Map_:
 				seekMod := i.Map.ModuleName
-				var found bool
-				for _, mod2 := range mods.Modules {
-					if mod2.Name == seekMod {
-						found = true
-						if _, ok := mod2.Kind.(*pbsubstreams.Module_KindMap_); !ok {
-							return fmt.Errorf("module %q: input %d: referenced module %q not of 'map' kind", mod.Name, idx, seekMod)
-						}
-					}
-				}
-				if !found {
-					return fmt.Errorf("module %q: map input named %q not found", mod.Name, seekMod)
+				if mod2, found := modules[seekMod]; !found {
+					problems.add(inputField+".map", "module %q: map input named %q not found", mod.Name, seekMod)
+				} else if _, ok := mod2.Kind.(*pbsubstreams.Module_KindMap_); !ok {
+					problems.add(inputField+".map", "module %q: input %d: referenced module %q not of 'map' kind", mod.Name, idx, seekMod)
 				}
 			case *pbsubstreams.Module_Input_Store_:
 				seekMod := i.Store.ModuleName
-				var found bool
-				for _, mod2 := range mods.Modules {
-					if mod2.Name == seekMod {
-						found = true
-						if _, ok := mod2.Kind.(*pbsubstreams.Module_KindStore_); !ok {
-							return fmt.Errorf("module %q: input %d: referenced module %q not of 'store' kind", mod.Name, idx, seekMod)
-						}
-					}
-				}
-				if !found {
-					return fmt.Errorf("module %q: store input named %q not found", mod.Name, seekMod)
+				if mod2, found := modules[seekMod]; !found {
+					problems.add(inputField+".store", "module %q: store input named %q not found", mod.Name, seekMod)
+				} else if _, ok := mod2.Kind.(*pbsubstreams.Module_KindStore_); !ok {
+					problems.add(inputField+".store", "module %q: input %d: referenced module %q not of 'store' kind", mod.Name, idx, seekMod)
 				}
 
 				switch i.Store.Mode {
 				case pbsubstreams.Module_Input_Store_GET, pbsubstreams.Module_Input_Store_DELTAS:
 				default:
-					return fmt.Errorf("module %q: input index %d: unknown store mode value %d", mod.Name, idx, i.Store.Mode)
+					problems.add(inputField+".mode", "module %q: input index %d: unknown store mode value %d", mod.Name, idx, i.Store.Mode)
 				}
 			}
 		}
+
+		if filter := mod.BlockFilter; filter != nil {
+			if mod2, found := modules[filter.Module]; !found {
+				problems.add(field+".blockFilter.module", "module %q: block filter: index module %q not found", mod.Name, filter.Module)
+			} else if _, ok := mod2.Kind.(*pbsubstreams.Module_KindBlockIndex_); !ok {
+				problems.add(field+".blockFilter.module", "module %q: block filter: referenced module %q not of 'blockIndex' kind", mod.Name, filter.Module)
+			}
+			if _, err := ParseBlockFilterQuery(filter.Query); err != nil {
+				problems.add(field+".blockFilter.query", "module %q: block filter: %s", mod.Name, err)
+			}
+		}
 	}
 
-	return nil
+	if cycle := newModuleGraph(mods.Modules).findCycle(); cycle != nil {
+		problems.add("modules", "dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return problems
 }
 
-func loadManifestFile(inputPath string) (*Manifest, error) {
-	m, err := decodeYamlManifestFromFile(inputPath)
+func loadManifestFile(inputPath string, vars map[string]string) (*Manifest, error) {
+	m, err := decodeYamlManifestFromFile(inputPath, vars)
 	if err != nil {
 		return nil, fmt.Errorf("decoding yaml: %w", err)
 	}
@@ -250,63 +313,93 @@ func loadManifestFile(inputPath string) (*Manifest, error) {
 
 	m.Workdir = path.Dir(absoluteManifestPath)
 
+	var problems ValidationErrors
 	if m.SpecVersion != "v0.1.0" {
-		return nil, fmt.Errorf("invalid 'specVersion', must be v0.1.0")
-	}
-
-	// Allow environment variables in `imports` element
-	for i, moduleImport := range m.Imports {
-		m.Imports[i][1] = os.ExpandEnv(moduleImport[1])
+		problems.add("specVersion", "invalid 'specVersion', must be v0.1.0")
 	}
-
-	// Allow environment variables in `protobuf.importPaths` element
-	for i := range m.Protobuf.ImportPaths {
-		m.Protobuf.ImportPaths[i] = os.ExpandEnv(m.Protobuf.ImportPaths[i])
+	if err := ValidateVersion(m.Package.Version); err != nil {
+		problems.add("package.version", "%s", err)
 	}
 
 	// TODO: put some limits on the NUMBER of modules (max 50 ?)
 	// TODO: put a limit on the SIZE of the WASM payload (max 10MB per binary?)
 
-	for _, s := range m.Modules {
-		// TODO: let's make sure this is also checked when received in Protobuf in a remote request.
+	for idx, s := range m.Modules {
+		s.validate(fmt.Sprintf("modules[%d]", idx), &problems)
+	}
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
 
-		switch s.Kind {
-		case ModuleKindMap:
-			if s.Output.Type == "" {
-				return nil, fmt.Errorf("stream %q: missing 'output.type' for kind 'map'", s.Name)
-			}
-		case ModuleKindStore:
-			if err := validateStoreBuilder(s); err != nil {
-				return nil, fmt.Errorf("stream %q: %w", s.Name, err)
-			}
+	return m, nil
+}
 
-		default:
-			return nil, fmt.Errorf("stream %q: invalid kind %q", s.Name, s.Kind)
-		}
-		for idx, input := range s.Inputs {
-			if err := input.parse(); err != nil {
-				return nil, fmt.Errorf("module %q: invalid input [%d]: %w", s.Name, idx, err)
-			}
-		}
+// importOptions are the options of the readers of the imports of the
+// manifest, sharing the ones of `r`.
+func (r *Reader) importOptions() []Options {
+	opts := []Options{WithVariables(r.variables), WithDownloadCache(r.downloadCacheDir)}
+	if r.strictIntegrity {
+		opts = append(opts, StrictIntegrity())
 	}
+	return opts
+}
 
-	return m, nil
+// readImport reads the package imported by the manifest field `field`,
+// checking it against `digest` when given. Imports fetched from a URL are
+// packages, local ones can also be manifests.
+func (r *Reader) readImport(field, digest string) (*pbsubstreams.Package, error) {
+	if digest == "" && !isRemoteReference(r.input) {
+		return r.Read()
+	}
+
+	content, err := r.readReference(field, r.input, digest)
+	if err != nil {
+		return nil, err
+	}
+	if isRemoteReference(r.input) {
+		return r.fromContents(content)
+	}
+	return r.Read()
 }
 
-func loadImports(pkg *pbsubstreams.Package, manif *Manifest) error {
+func loadImports(pkg *pbsubstreams.Package, manif *Manifest, opts ...Options) error {
+	moduleImports := map[string]string{}
+	for _, mod := range pkg.Modules.Modules {
+		moduleImports[mod.Name] = ""
+	}
+
+	seenImports := map[string]bool{}
 	for _, kv := range manif.Imports {
 		importName := kv[0]
-		importPath := manif.resolvePath(kv[1])
+		importRef, digest := splitDigest(kv[1])
+		importPath := manif.resolvePath(importRef)
+		if seenImports[importName] {
+			return fmt.Errorf("import %q declared more than once", importName)
+		}
+		seenImports[importName] = true
 
-		subpkgReader := NewReader(importPath)
-		subpkg, err := subpkgReader.Read()
+		subpkg, err := NewReader(importPath, opts...).readImport(fmt.Sprintf("imports.%s", importName), digest)
 		if err != nil {
 			return fmt.Errorf("importing %q: %w", importPath, err)
 		}
+		if pkg.Network != "" && subpkg.Network != "" && subpkg.Network != pkg.Network {
+			return fmt.Errorf("import %q: made for network %q, not %q", importName, subpkg.Network, pkg.Network)
+		}
 
 		prefixModules(subpkg.Modules.Modules, importName)
+		for _, mod := range subpkg.Modules.Modules {
+			if other, found := moduleImports[mod.Name]; found {
+				if other == "" {
+					return fmt.Errorf("module %q of import %q collides with a module of the manifest", mod.Name, importName)
+				}
+				return fmt.Errorf("module %q of import %q collides with a module of import %q", mod.Name, importName, other)
+			}
+			moduleImports[mod.Name] = importName
+		}
 		reindexAndMergePackage(subpkg, pkg)
-		mergeProtoFiles(subpkg, pkg)
+		if err := mergeProtoFiles(subpkg, pkg); err != nil {
+			return fmt.Errorf("import %q: %w", importName, err)
+		}
 	}
 	// loop through the Manifest, and get the `imports` statements,
 	// pull the Package files from Disk, and merge them into this one
@@ -329,6 +422,9 @@ func prefixModules(mods []*pbsubstreams.Module, prefix string) {
 				panic(fmt.Sprintf("unsupported module type %s", inputIface.Input))
 			}
 		}
+		if mod.BlockFilter != nil {
+			mod.BlockFilter.Module = prefix + PrefixSeparator + mod.BlockFilter.Module
+		}
 	}
 }
 
@@ -350,29 +446,44 @@ func reindexAndMergePackage(src, dest *pbsubstreams.Package) {
 	dest.PackageMeta = append(dest.PackageMeta, src.PackageMeta...)
 }
 
-func mergeProtoFiles(src, dest *pbsubstreams.Package) {
-	seenFiles := map[string]bool{}
+// mergeProtoFiles adds the protobuf files of `src` to the ones of `dest`,
+// the files of the same name being added once, provided they have the same
+// contents. The system files of `dest` win over the ones of `src`, which
+// may have been packed by another version.
+func mergeProtoFiles(src, dest *pbsubstreams.Package) error {
+	systemFiles, err := readSystemProtobufs()
+	if err != nil {
+		return err
+	}
+	systemFileNames := map[string]bool{}
+	for _, file := range systemFiles.File {
+		systemFileNames[file.GetName()] = true
+	}
 
+	seenFiles := map[string]*descriptorpb.FileDescriptorProto{}
 	for _, file := range dest.ProtoFiles {
-		seenFiles[*file.Name] = true
+		seenFiles[*file.Name] = file
 	}
 
 	for _, file := range src.ProtoFiles {
 		key := *file.Name
-		if seenFiles[key] {
+		if seen := seenFiles[key]; seen != nil {
+			if !systemFileNames[key] {
+				if err := checkSameProtoFile(seen, file); err != nil {
+					return err
+				}
+			}
 			zlog.Debug("skipping protofile already seen", zap.String("proto_file", *file.Name))
 			continue
 		}
-		seenFiles[key] = true
+		seenFiles[key] = file
 		dest.ProtoFiles = append(dest.ProtoFiles, file)
 	}
 
-	// TODO: do DEDUPLICATION of those protofiles and/or of the messages therein,
-	// otherwise they can duplicate quickly.
-
 	// TODO: eventually, we want the last Message type to win, or perhaps we'd search in reverse order
 	// upon `print` or generation? The thing is we'll want tools like `protoc` and `buf` to use the most
 	// recent, but it'll simply go in list order..
+	return nil
 }
 
 // manifestToPkg will take a Manifest object, most likely generated from a YAML file, and will create a Proto Pakcage object
@@ -388,10 +499,14 @@ func (r *Reader) manifestToPkg(m *Manifest) (*pbsubstreams.Package, error) {
 		return nil, fmt.Errorf("error loading protobuf: %w", err)
 	}
 
-	if err := loadImports(pkg, m); err != nil {
+	if err := loadImports(pkg, m, r.importOptions()...); err != nil {
 		return nil, fmt.Errorf("error loading imports: %w", err)
 	}
 
+	if err := loadSink(pkg, m); err != nil {
+		return nil, fmt.Errorf("error loading sink: %w", err)
+	}
+
 	return pkg, nil
 }
 
@@ -406,6 +521,9 @@ func (r *Reader) convertToPkg(m *Manifest) (pkg *pbsubstreams.Package, err error
 		Version:     1,
 		PackageMeta: []*pbsubstreams.PackageMetadata{pkgMeta},
 		Modules:     &pbsubstreams.Modules{},
+		Network:     m.Network,
+
+		SubstreamsVersion: LibraryVersion(),
 	}
 
 	moduleCodeIndexes := map[string]int{}
@@ -416,37 +534,38 @@ func (r *Reader) convertToPkg(m *Manifest) (pkg *pbsubstreams.Package, err error
 		var pbmod *pbsubstreams.Module
 
 		binaryName := "default"
-		implicit := ""
+		implicit := "(implicit) "
 		if mod.Binary != "" {
 			binaryName = mod.Binary
-			implicit = "(implicit) "
+			implicit = ""
 		}
 		binaryDef, found := m.Binaries[binaryName]
 		if !found {
 			return nil, fmt.Errorf("module %q refers to %sbinary %q, which is not defined in the 'binaries' section of the manifest", mod.Name, implicit, binaryName)
 		}
 
-		switch binaryDef.Type {
-		case "wasm/rust-v1":
+		switch {
+		case isBinaryType(binaryDef.Type):
 			// OPTIM(abourget): also check if it's not already in
 			// `Binaries`, by comparing its, length + hash or value.
-			codeIndex, found := moduleCodeIndexes[binaryDef.File]
+			// Indexed by name, the same file can be given other types
+			codeIndex, found := moduleCodeIndexes[binaryName]
 			if !found {
 				codePath := binaryDef.File
 				var byteCode []byte
 				if !r.skipSourceCodeImportValidation {
-					byteCode, err = ioutil.ReadFile(codePath)
+					byteCode, err = r.readReference(fmt.Sprintf("binaries.%s.file", binaryName), codePath, binaryDef.Sha256)
 					if err != nil {
-						return nil, fmt.Errorf("failed to read source code %q: %w", codePath, err)
+						return nil, fmt.Errorf("failed to read source code: %w", err)
 					}
 				}
 				pkg.Modules.Binaries = append(pkg.Modules.Binaries, &pbsubstreams.Binary{Type: binaryDef.Type, Content: byteCode})
 				codeIndex = len(pkg.Modules.Binaries) - 1
-				moduleCodeIndexes[binaryDef.File] = codeIndex
+				moduleCodeIndexes[binaryName] = codeIndex
 			}
 			pbmod, err = mod.ToProtoWASM(uint32(codeIndex))
 		default:
-			return nil, fmt.Errorf("module %q: invalid code type %q", mod.Name, binaryDef.Type)
+			return nil, fmt.Errorf("module %q: binary %q: unknown type %q, expected one of %q", mod.Name, binaryName, binaryDef.Type, BinaryTypes)
 		}
 		if err != nil {
 			return nil, err
@@ -459,12 +578,3 @@ func (r *Reader) convertToPkg(m *Manifest) (pkg *pbsubstreams.Package, err error
 
 	return
 }
-
-var validValueTypes = map[string]bool{
-	"bigint":   true,
-	"int64":    true,
-	"bigfloat": true,
-	"bytes":    true,
-	"string":   true,
-	"proto":    true,
-}
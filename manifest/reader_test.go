@@ -11,7 +11,11 @@ import (
 	"github.com/jhump/protoreflect/desc/protoparse"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 func TestReader_Read(t *testing.T) {
@@ -42,9 +46,10 @@ func TestReader_Read(t *testing.T) {
 			"testdata/bare_minimum.yaml",
 			nil,
 			&pbsubstreams.Package{
-				Version:    1,
-				ProtoFiles: append(systemProtoDefs),
-				Modules:    &pbsubstreams.Modules{},
+				Version:           1,
+				SubstreamsVersion: LibraryVersion(),
+				ProtoFiles:        append(systemProtoDefs),
+				Modules:           &pbsubstreams.Modules{},
 				PackageMeta: []*pbsubstreams.PackageMetadata{
 					{
 						Name:    "test",
@@ -58,9 +63,10 @@ func TestReader_Read(t *testing.T) {
 			"testdata/imports_relative_path.yaml",
 			nil,
 			&pbsubstreams.Package{
-				Version:    1,
-				ProtoFiles: append(systemProtoDefs),
-				Modules:    &pbsubstreams.Modules{},
+				Version:           1,
+				SubstreamsVersion: LibraryVersion(),
+				ProtoFiles:        append(systemProtoDefs),
+				Modules:           &pbsubstreams.Modules{},
 				PackageMeta: []*pbsubstreams.PackageMetadata{
 					{
 						Name:    "test",
@@ -80,9 +86,10 @@ func TestReader_Read(t *testing.T) {
 				"SERVER_HOST": strings.Replace(remoteServer.URL, "http://", "", 1),
 			},
 			&pbsubstreams.Package{
-				Version:    1,
-				ProtoFiles: append(systemProtoDefs),
-				Modules:    &pbsubstreams.Modules{},
+				Version:           1,
+				SubstreamsVersion: LibraryVersion(),
+				ProtoFiles:        append(systemProtoDefs),
+				Modules:           &pbsubstreams.Modules{},
 				PackageMeta: []*pbsubstreams.PackageMetadata{
 					{
 						Name:    "test",
@@ -103,9 +110,10 @@ func TestReader_Read(t *testing.T) {
 				"ABSOLUTE_PATH_TO_DEP2": absolutePathToDep2,
 			},
 			&pbsubstreams.Package{
-				Version:    1,
-				ProtoFiles: append(systemProtoDefs),
-				Modules:    &pbsubstreams.Modules{},
+				Version:           1,
+				SubstreamsVersion: LibraryVersion(),
+				ProtoFiles:        append(systemProtoDefs),
+				Modules:           &pbsubstreams.Modules{},
 				PackageMeta: []*pbsubstreams.PackageMetadata{
 					{
 						Name:    "test",
@@ -127,7 +135,8 @@ func TestReader_Read(t *testing.T) {
 			"testdata/protobuf_importPaths_relative_path.yaml",
 			nil,
 			&pbsubstreams.Package{
-				Version: 1,
+				Version:           1,
+				SubstreamsVersion: LibraryVersion(),
 				ProtoFiles: append(
 					systemProtoDefs,
 					readProtoDescriptor(t, "testdata/proto1", "sf/substreams/test1.proto"),
@@ -149,7 +158,8 @@ func TestReader_Read(t *testing.T) {
 				"ABSOLUTE_PATH_TO_PROTO2": absolutePathToProto2,
 			},
 			&pbsubstreams.Package{
-				Version: 1,
+				Version:           1,
+				SubstreamsVersion: LibraryVersion(),
 				ProtoFiles: append(
 					systemProtoDefs,
 					readProtoDescriptor(t, "testdata/proto1", "sf/substreams/test1.proto"),
@@ -206,3 +216,212 @@ func readSystemProtoDescriptors(t *testing.T) (out []*descriptorpb.FileDescripto
 
 	return systemProtoFiles.File
 }
+
+func TestLoadImports_Collisions(t *testing.T) {
+	dep := &pbsubstreams.Package{
+		Version:     1,
+		PackageMeta: []*pbsubstreams.PackageMetadata{{Name: "dep", Version: "v0.0.0"}},
+		ModuleMeta:  []*pbsubstreams.ModuleMetadata{{}},
+		ProtoFiles:  readSystemProtoDescriptors(t),
+		Modules: &pbsubstreams.Modules{
+			Modules: []*pbsubstreams.Module{{
+				Name:   "map_transfers",
+				Kind:   &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:sf.substreams.v1.Clock"}},
+				Inputs: []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.substreams.v1.Clock"}}}},
+			}},
+			Binaries: []*pbsubstreams.Binary{{Type: "wasm/rust-v1", Content: []byte("01")}},
+		},
+	}
+	cnt, err := proto.Marshal(dep)
+	require.NoError(t, err)
+	depPath := filepath.Join(t.TempDir(), "dep.spkg")
+	require.NoError(t, os.WriteFile(depPath, cnt, 0644))
+
+	newPkg := func(moduleNames ...string) *pbsubstreams.Package {
+		pkg := &pbsubstreams.Package{Modules: &pbsubstreams.Modules{}}
+		for _, name := range moduleNames {
+			pkg.Modules.Modules = append(pkg.Modules.Modules, &pbsubstreams.Module{Name: name})
+		}
+		return pkg
+	}
+
+	tests := []struct {
+		name        string
+		moduleNames []string
+		imports     mapSlice
+		expectError string
+	}{
+		{name: "imported", imports: mapSlice{{"dep", depPath}, {"other", depPath}}},
+		{name: "import declared twice", imports: mapSlice{{"dep", depPath}, {"dep", depPath}}, expectError: `import "dep" declared more than once`},
+		{name: "manifest module", moduleNames: []string{"dep:map_transfers"}, imports: mapSlice{{"dep", depPath}}, expectError: `module "dep:map_transfers" of import "dep" collides with a module of the manifest`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkg := newPkg(test.moduleNames...)
+			err := loadImports(pkg, &Manifest{Imports: test.imports})
+			if test.expectError != "" {
+				require.EqualError(t, err, test.expectError)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, pkg.Modules.Modules, 2)
+			require.Equal(t, "dep:map_transfers", pkg.Modules.Modules[0].Name)
+			require.Equal(t, "other:map_transfers", pkg.Modules.Modules[1].Name)
+			require.Len(t, pkg.Modules.Binaries, 2)
+			require.Equal(t, uint32(1), pkg.Modules.Modules[1].BinaryIndex)
+		})
+	}
+}
+
+func TestReader_Sink(t *testing.T) {
+	pkg, err := NewReader("testdata/sink/sink.yaml", SkipSourceCodeReader()).Read()
+	require.NoError(t, err)
+	require.Equal(t, "db_out", pkg.SinkModule)
+	require.Equal(t, "type.googleapis.com/sf.substreams.test.sink.Service", pkg.SinkConfig.TypeUrl)
+
+	// Packed into the package as is, read back from it byte for byte
+	cnt, err := proto.Marshal(pkg)
+	require.NoError(t, err)
+	spkgPath := filepath.Join(t.TempDir(), "sink.spkg")
+	require.NoError(t, os.WriteFile(spkgPath, cnt, 0644))
+	readBack, err := NewReader(spkgPath).Read()
+	require.NoError(t, err)
+	require.Equal(t, "db_out", readBack.SinkModule)
+	require.Equal(t, pkg.SinkConfig.Value, readBack.SinkConfig.Value)
+
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: readBack.ProtoFiles})
+	require.NoError(t, err)
+	desc, err := files.FindDescriptorByName("sf.substreams.test.sink.Service")
+	require.NoError(t, err)
+	config := dynamicpb.NewMessage(desc.(protoreflect.MessageDescriptor))
+	require.NoError(t, readBack.SinkConfig.UnmarshalTo(config))
+
+	schema := "CREATE TABLE transfers (id TEXT PRIMARY KEY);\n"
+	fields := config.Descriptor().Fields()
+	require.Equal(t, schema, config.Get(fields.ByName("schema")).String())
+	require.Equal(t, []byte(schema), config.Get(fields.ByName("seed")).Bytes())
+	require.Equal(t, 2, config.Get(fields.ByName("tables")).List().Len())
+	engine := config.Get(fields.ByName("engine")).Message()
+	require.Equal(t, "postgres", engine.Get(engine.Descriptor().Fields().ByName("name")).String())
+	require.Equal(t, "postgres://localhost/sink\n", engine.Get(engine.Descriptor().Fields().ByName("settings")).String())
+}
+
+func TestReader_SinkErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		sink        string
+		expectError string
+	}{
+		{name: "module not found", sink: "module: db_missing\n  type: sf.substreams.test.sink.Service", expectError: `sink module "db_missing" not found`},
+		{name: "store module", sink: "module: store_totals\n  type: sf.substreams.test.sink.Service", expectError: `sink module "store_totals" is not of 'map' kind`},
+		{name: "type not found", sink: "module: db_out\n  type: sf.substreams.test.sink.Missing", expectError: `type "sf.substreams.test.sink.Missing" not found`},
+		{name: "unknown field", sink: "module: db_out\n  type: sf.substreams.test.sink.Service\n  config:\n    tablez: [transfers]", expectError: `unknown field "tablez"`},
+		{name: "file of a message field", sink: "module: db_out\n  type: sf.substreams.test.sink.Service\n  config:\n    engine: \"@@schema.sql\"", expectError: `only string and bytes fields accept files`},
+		{name: "missing file", sink: "module: db_out\n  type: sf.substreams.test.sink.Service\n  config:\n    schema: \"@@missing.sql\"", expectError: `missing.sql`},
+	}
+
+	cnt, err := os.ReadFile("testdata/sink/sink.yaml")
+	require.NoError(t, err)
+	base := string(cnt[:strings.Index(string(cnt), "sink:\n")])
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifestPath := filepath.Join("testdata/sink", strings.ReplaceAll(test.name, " ", "_")+".yaml")
+			require.NoError(t, os.WriteFile(manifestPath, []byte(base+"sink:\n  "+test.sink+"\n"), 0644))
+			defer os.Remove(manifestPath)
+
+			_, err := NewReader(manifestPath, SkipSourceCodeReader()).Read()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.expectError)
+		})
+	}
+}
+
+func TestReader_Network(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest := func(name, network string, imports ...string) string {
+		content := "specVersion: v0.1.0\npackage:\n  name: " + name + "\n  version: v0.0.0\n"
+		if network != "" {
+			content += "network: " + network + "\n"
+		}
+		if len(imports) != 0 {
+			content += "imports:\n"
+			for _, imp := range imports {
+				content += "  " + strings.TrimSuffix(imp, ".yaml") + ": ./" + imp + "\n"
+			}
+		}
+		manifestPath := filepath.Join(dir, name+".yaml")
+		require.NoError(t, os.WriteFile(manifestPath, []byte(content), 0644))
+		return manifestPath
+	}
+
+	writeManifest("polygon", "polygon")
+	writeManifest("undeclared", "")
+	writeManifest("mainnet", "mainnet")
+
+	pkg, err := NewReader(writeManifest("test", "mainnet", "mainnet.yaml", "undeclared.yaml")).Read()
+	require.NoError(t, err)
+	require.Equal(t, "mainnet", pkg.Network)
+
+	_, err = NewReader(writeManifest("test", "mainnet", "polygon.yaml")).Read()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `import "polygon": made for network "polygon", not "mainnet"`)
+
+	pkg, err = NewReader(writeManifest("test", "", "polygon.yaml")).Read()
+	require.NoError(t, err)
+	require.Equal(t, "", pkg.Network)
+}
+
+func TestReader_Binaries(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest := func(binaries, modules string) string {
+		content := "specVersion: v0.1.0\npackage:\n  name: test\n  version: v0.0.0\nbinaries:\n" + binaries + "modules:\n" + modules
+		manifestPath := filepath.Join(dir, "test.yaml")
+		require.NoError(t, os.WriteFile(manifestPath, []byte(content), 0644))
+		return manifestPath
+	}
+	mapModule := func(name, binary string) string {
+		out := "  - name: " + name + "\n    kind: map\n    inputs:\n      - source: sf.substreams.v1.Clock\n    output:\n      type: proto:sf.substreams.v1.Clock\n"
+		if binary != "" {
+			out += "    binary: " + binary + "\n"
+		}
+		return out
+	}
+
+	// The same file built for wasm and WASI, each module using its own
+	pkg, err := NewReader(writeManifest(
+		"  default:\n    type: wasm/rust-v1\n    file: ./module.wasm\n  wasi:\n    type: wasm/rust-v1+wasi\n    file: ./module.wasm\n",
+		mapModule("map_a", "")+mapModule("map_b", "wasi")+mapModule("map_c", "default"),
+	), SkipSourceCodeReader()).Read()
+	require.NoError(t, err)
+	require.Len(t, pkg.Modules.Binaries, 2)
+	require.Equal(t, "wasm/rust-v1", pkg.Modules.Binaries[pkg.Modules.Modules[0].BinaryIndex].Type)
+	require.Equal(t, "wasm/rust-v1+wasi", pkg.Modules.Binaries[pkg.Modules.Modules[1].BinaryIndex].Type)
+	require.Equal(t, pkg.Modules.Modules[0].BinaryIndex, pkg.Modules.Modules[2].BinaryIndex)
+
+	_, err = NewReader(writeManifest("  default:\n    type: wasm/rust-v1\n    file: ./module.wasm\n", mapModule("map_a", "other")), SkipSourceCodeReader()).Read()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `module "map_a" refers to binary "other", which is not defined in the 'binaries' section of the manifest`)
+
+	_, err = NewReader(writeManifest("  default:\n    type: wasm/assemblyscript\n    file: ./module.wasm\n", mapModule("map_a", "")), SkipSourceCodeReader()).Read()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `module "map_a": binary "default": unknown type "wasm/assemblyscript"`)
+}
+
+func TestValidateModules_Binaries(t *testing.T) {
+	newModules := func(binaryType string, binaryIndex uint32) *pbsubstreams.Modules {
+		return &pbsubstreams.Modules{
+			Modules: []*pbsubstreams.Module{{
+				Name:        "map_a",
+				Kind:        &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:test.Output"}},
+				BinaryIndex: binaryIndex,
+			}},
+			Binaries: []*pbsubstreams.Binary{{Type: binaryType}},
+		}
+	}
+
+	require.NoError(t, ValidateModules(newModules("wasm/rust-v1+wasi", 0)))
+	require.EqualError(t, ValidateModules(newModules("wasm/rust-v1", 1)), `modules[0].binary: module "map_a": binary index 1 out of range, the package has 1 binaries`)
+	require.EqualError(t, ValidateModules(newModules("wasm/assemblyscript", 0)), `binaries[0].type: binary 0: unknown type "wasm/assemblyscript", expected one of ["wasm/rust-v1" "wasm/rust-v1+wasi"]`)
+}
@@ -0,0 +1,215 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// renderedNode is a module, or a source of modules, of a rendered graph.
+type renderedNode struct {
+	id           string
+	name         string
+	kind         string // "source", or the kind of the module
+	initialBlock uint64
+	highlighted  bool
+}
+
+func (n *renderedNode) labelLines() []string {
+	lines := []string{n.name}
+	if n.kind != "source" && n.initialBlock != UNSET {
+		lines = append(lines, fmt.Sprintf("initial block: %d", n.initialBlock))
+	}
+	return lines
+}
+
+// renderedEdge goes from an input to the module consuming it, labeled by
+// the kind of input: "source", "get" or "deltas" for stores, "filter" for
+// the index of a block filter, nothing for maps.
+type renderedEdge struct {
+	from  string
+	to    string
+	label string
+}
+
+type renderedGraph struct {
+	nodes []*renderedNode
+	edges []*renderedEdge
+}
+
+func newRenderedGraph(mods *pbsubstreams.Modules, highlighted []string) (*renderedGraph, error) {
+	g := &renderedGraph{}
+
+	moduleIDs := map[string]string{}
+	byName := map[string]*renderedNode{}
+	modules := make([]*renderedNode, len(mods.Modules))
+	for idx, mod := range mods.Modules {
+		node := &renderedNode{
+			id:           fmt.Sprintf("m%d", idx),
+			name:         mod.Name,
+			initialBlock: mod.InitialBlock,
+		}
+		switch mod.Kind.(type) {
+		case *pbsubstreams.Module_KindMap_:
+			node.kind = ModuleKindMap
+		case *pbsubstreams.Module_KindStore_:
+			node.kind = ModuleKindStore
+		case *pbsubstreams.Module_KindBlockIndex_:
+			node.kind = ModuleKindBlockIndex
+		}
+		moduleIDs[mod.Name] = node.id
+		byName[mod.Name] = node
+		modules[idx] = node
+	}
+	for _, name := range highlighted {
+		node, found := byName[name]
+		if !found {
+			return nil, fmt.Errorf("highlighted module %q not found", name)
+		}
+		node.highlighted = true
+	}
+
+	// Sources come first, in the order they are first consumed
+	sourceIDs := map[string]string{}
+	for idx, mod := range mods.Modules {
+		to := modules[idx].id
+		for _, in := range mod.Inputs {
+			switch input := in.Input.(type) {
+			case *pbsubstreams.Module_Input_Source_:
+				id, found := sourceIDs[input.Source.Type]
+				if !found {
+					id = fmt.Sprintf("s%d", len(sourceIDs))
+					sourceIDs[input.Source.Type] = id
+					g.nodes = append(g.nodes, &renderedNode{id: id, name: input.Source.Type, kind: "source"})
+				}
+				g.edges = append(g.edges, &renderedEdge{from: id, to: to, label: "source"})
+			case *pbsubstreams.Module_Input_Map_:
+				if from, found := moduleIDs[input.Map.ModuleName]; found {
+					g.edges = append(g.edges, &renderedEdge{from: from, to: to})
+				}
+			case *pbsubstreams.Module_Input_Store_:
+				if from, found := moduleIDs[input.Store.ModuleName]; found {
+					label := "get"
+					if input.Store.Mode == pbsubstreams.Module_Input_Store_DELTAS {
+						label = "deltas"
+					}
+					g.edges = append(g.edges, &renderedEdge{from: from, to: to, label: label})
+				}
+			}
+		}
+		if filter := mod.BlockFilter; filter != nil {
+			if from, found := moduleIDs[filter.Module]; found {
+				g.edges = append(g.edges, &renderedEdge{from: from, to: to, label: "filter"})
+			}
+		}
+	}
+	g.nodes = append(g.nodes, modules...)
+
+	return g, nil
+}
+
+var dotShapes = map[string]string{
+	"source":             "ellipse",
+	ModuleKindMap:        "box",
+	ModuleKindStore:      "cylinder",
+	ModuleKindBlockIndex: "hexagon",
+}
+
+// WriteDOT writes the graph of `mods` to `w` in the GraphViz DOT language,
+// each input pointing to the modules consuming it. The shape of the modules
+// tells their kind, and the edges from stores and sources are labeled with
+// the kind of input. The `highlighted` modules, like the outputs of a
+// request, are filled.
+func WriteDOT(w io.Writer, mods *pbsubstreams.Modules, highlighted ...string) error {
+	g, err := newRenderedGraph(mods, highlighted)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph substreams {\n")
+	b.WriteString("  rankdir=TB;\n")
+	for _, node := range g.nodes {
+		var lines []string
+		for _, line := range node.labelLines() {
+			lines = append(lines, dotEscape(line))
+		}
+		fmt.Fprintf(&b, "  %s [label=\"%s\", shape=%s", node.id, strings.Join(lines, `\n`), dotShapes[node.kind])
+		if node.highlighted {
+			b.WriteString(`, style=filled, fillcolor="#ffd966"`)
+		}
+		b.WriteString("];\n")
+	}
+	for _, edge := range g.edges {
+		fmt.Fprintf(&b, "  %s -> %s", edge.from, edge.to)
+		if edge.label != "" {
+			fmt.Fprintf(&b, " [label=\"%s\"]", edge.label)
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+var mermaidShapes = map[string][2]string{
+	"source":             {"([", "])"},
+	ModuleKindMap:        {"[", "]"},
+	ModuleKindStore:      {"[(", ")]"},
+	ModuleKindBlockIndex: {"{{", "}}"},
+}
+
+// WriteMermaid writes the graph of `mods` to `w` as a Mermaid flowchart,
+// rendered like WriteDOT renders it.
+func WriteMermaid(w io.Writer, mods *pbsubstreams.Modules, highlighted ...string) error {
+	g, err := newRenderedGraph(mods, highlighted)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("graph TD;\n")
+	var highlightedIDs []string
+	for _, node := range g.nodes {
+		var lines []string
+		for _, line := range node.labelLines() {
+			lines = append(lines, strings.ReplaceAll(line, `"`, "#quot;"))
+		}
+		shape := mermaidShapes[node.kind]
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s;\n", node.id, shape[0], strings.Join(lines, "<br/>"), shape[1])
+		if node.highlighted {
+			highlightedIDs = append(highlightedIDs, node.id)
+		}
+	}
+	for _, edge := range g.edges {
+		if edge.label != "" {
+			fmt.Fprintf(&b, "  %s -->|%s| %s;\n", edge.from, edge.label, edge.to)
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s;\n", edge.from, edge.to)
+		}
+	}
+	if len(highlightedIDs) != 0 {
+		b.WriteString("  classDef highlighted fill:#ffd966,stroke:#b58900,stroke-width:2px;\n")
+		fmt.Fprintf(&b, "  class %s highlighted;\n", strings.Join(highlightedIDs, ","))
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func PrintMermaid(mods *pbsubstreams.Modules) {
+	fmt.Println("Mermaid graph:\n\n```mermaid")
+	if err := WriteMermaid(os.Stdout, mods); err != nil {
+		fmt.Println("rendering graph:", err)
+	}
+	fmt.Println("```")
+	fmt.Println("")
+}
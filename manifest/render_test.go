@@ -0,0 +1,48 @@
+package manifest
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the tests")
+
+func TestWriteGraph(t *testing.T) {
+	pkg, err := NewReader("testdata/graph/graph.yaml", SkipSourceCodeReader()).Read()
+	require.NoError(t, err)
+
+	tests := []struct {
+		golden string
+		write  func(w io.Writer, mods *pbsubstreams.Modules, highlighted ...string) error
+	}{
+		{"testdata/graph/graph.dot", WriteDOT},
+		{"testdata/graph/graph.mmd", WriteMermaid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.golden, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, test.write(&out, pkg.Modules, "map_volumes", "store_pools"))
+
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(test.golden, out.Bytes(), 0644))
+			}
+			expected, err := os.ReadFile(test.golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), out.String())
+		})
+	}
+}
+
+func TestWriteGraph_UnknownHighlighted(t *testing.T) {
+	mods := &pbsubstreams.Modules{Modules: []*pbsubstreams.Module{{Name: "map_a"}}}
+	assert.EqualError(t, WriteDOT(io.Discard, mods, "map_b"), `highlighted module "map_b" not found`)
+	assert.EqualError(t, WriteMermaid(io.Discard, mods, "map_b"), `highlighted module "map_b" not found`)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 )
@@ -27,6 +28,8 @@ func HashModule(modules *pbsubstreams.Modules, module *pbsubstreams.Module, grap
 		buf.WriteString("map")
 	case *pbsubstreams.Module_KindStore_:
 		buf.WriteString("store")
+	case *pbsubstreams.Module_KindBlockIndex_:
+		buf.WriteString("block_index")
 	default:
 		panic(fmt.Sprintf("invalid module file %T", module.Kind))
 	}
@@ -38,7 +41,20 @@ func HashModule(modules *pbsubstreams.Modules, module *pbsubstreams.Module, grap
 	buf.WriteString("inputs")
 	for _, input := range module.Inputs {
 		buf.WriteString(inputName(input))
-		buf.WriteString(inputValue(input))
+		buf.WriteString(unprefixedName(inputValue(input)))
+	}
+
+	if filter := module.BlockFilter; filter != nil {
+		// The hash of the index module itself is part of the ancestors
+		buf.WriteString("block_filter")
+		buf.WriteString(unprefixedName(filter.Module))
+		buf.WriteString(filter.Query)
+	}
+
+	if module.Params != "" {
+		// Only when set, so the hash of the modules without params is unchanged
+		buf.WriteString("params")
+		buf.WriteString(module.Params)
 	}
 
 	buf.WriteString("ancestors")
@@ -49,7 +65,7 @@ func HashModule(modules *pbsubstreams.Modules, module *pbsubstreams.Module, grap
 	}
 
 	buf.WriteString("entrypoint")
-	buf.WriteString(module.Name)
+	buf.WriteString(unprefixedName(module.Name))
 
 	h := sha1.New()
 	h.Write(buf.Bytes())
@@ -59,6 +75,18 @@ func HashModule(modules *pbsubstreams.Modules, module *pbsubstreams.Module, grap
 func HashModuleAsString(modules *pbsubstreams.Modules, graph *ModuleGraph, module *pbsubstreams.Module) string {
 	return hex.EncodeToString(HashModule(modules, module, graph))
 }
+
+// unprefixedName returns the name of a module without the prefixes of the
+// imports it went through, so an imported module keeps the hash it has in
+// its own package, sharing its caches. The hashes of the ancestors tell
+// apart the modules of different packages with the same name.
+func unprefixedName(name string) string {
+	if idx := strings.LastIndex(name, PrefixSeparator); idx != -1 {
+		return name[idx+len(PrefixSeparator):]
+	}
+	return name
+}
+
 func inputName(input *pbsubstreams.Module_Input) string {
 	switch input.Input.(type) {
 	case *pbsubstreams.Module_Input_Store_:
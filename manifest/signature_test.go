@@ -61,3 +61,127 @@ func Test_HashModule(t *testing.T) {
 
 	require.NotEqual(t, hashMapPoolsInitialized, hashMapPoolsCreated)
 }
+
+func Test_HashModule_BlockFilter(t *testing.T) {
+	newModules := func(indexBinary uint32, query string) *pbsubstreams.Modules {
+		return &pbsubstreams.Modules{
+			Modules: []*pbsubstreams.Module{
+				{
+					Name:        "index_events",
+					BinaryIndex: indexBinary,
+					Kind:        &pbsubstreams.Module_KindBlockIndex_{KindBlockIndex: &pbsubstreams.Module_KindBlockIndex{}},
+					Inputs: []*pbsubstreams.Module_Input{
+						{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.ethereum.type.v1.Block"}}},
+					},
+				},
+				{
+					Name:        "map_transfers",
+					Kind:        &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:transfers"}},
+					BlockFilter: &pbsubstreams.Module_BlockFilter{Module: "index_events", Query: query},
+					Inputs: []*pbsubstreams.Module_Input{
+						{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.ethereum.type.v1.Block"}}},
+					},
+				},
+			},
+			Binaries: []*pbsubstreams.Binary{
+				{Type: "wasm/rust-v1", Content: []byte("01")},
+				{Type: "wasm/rust-v1", Content: []byte("02")},
+			},
+		}
+	}
+	hash := func(modules *pbsubstreams.Modules) string {
+		graph, err := NewModuleGraph(modules.Modules)
+		require.NoError(t, err)
+		return HashModuleAsString(modules, graph, modules.Modules[1])
+	}
+
+	reference := hash(newModules(0, "transfer"))
+	require.Equal(t, reference, hash(newModules(0, "transfer")))
+	require.NotEqual(t, reference, hash(newModules(0, "approval")), "query changed")
+	require.NotEqual(t, reference, hash(newModules(1, "transfer")), "index module changed")
+}
+
+func Test_HashModule_Params(t *testing.T) {
+	newModules := func(params string) *pbsubstreams.Modules {
+		return &pbsubstreams.Modules{
+			Modules: []*pbsubstreams.Module{
+				{
+					Name:   "map_transfers",
+					Kind:   &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:transfers"}},
+					Params: params,
+					Inputs: []*pbsubstreams.Module_Input{
+						{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.ethereum.type.v1.Block"}}},
+					},
+				},
+				{
+					Name: "map_balances",
+					Kind: &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:balances"}},
+					Inputs: []*pbsubstreams.Module_Input{
+						{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: "map_transfers"}}},
+					},
+				},
+			},
+			Binaries: []*pbsubstreams.Binary{
+				{Type: "wasm/rust-v1", Content: []byte("01")},
+			},
+		}
+	}
+	hashes := func(modules *pbsubstreams.Modules) (string, string) {
+		graph, err := NewModuleGraph(modules.Modules)
+		require.NoError(t, err)
+		return HashModuleAsString(modules, graph, modules.Modules[0]), HashModuleAsString(modules, graph, modules.Modules[1])
+	}
+
+	transfers, balances := hashes(newModules("0xa0b8"))
+	otherTransfers, otherBalances := hashes(newModules("0xdac1"))
+	require.NotEqual(t, transfers, otherTransfers)
+	require.NotEqual(t, balances, otherBalances, "params of an ancestor changed")
+
+	unsetTransfers, _ := hashes(newModules(""))
+	require.NotEqual(t, transfers, unsetTransfers)
+}
+
+func Test_HashModule_Imported(t *testing.T) {
+	newModules := func() *pbsubstreams.Modules {
+		return &pbsubstreams.Modules{
+			Modules: []*pbsubstreams.Module{
+				{
+					Name:   "map_transfers",
+					Kind:   &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:test.Transfers"}},
+					Inputs: []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.ethereum.type.v1.Block"}}}},
+				},
+				{
+					Name:   "store_balances",
+					Kind:   &pbsubstreams.Module_KindStore_{KindStore: &pbsubstreams.Module_KindStore{ValueType: "bigint"}},
+					Inputs: []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: "map_transfers"}}}},
+				},
+			},
+			Binaries: []*pbsubstreams.Binary{{Type: "wasm/rust-v1", Content: []byte("01")}},
+		}
+	}
+	hashes := func(modules *pbsubstreams.Modules) map[string]string {
+		graph, err := NewModuleGraph(modules.Modules)
+		require.NoError(t, err)
+		out := map[string]string{}
+		for _, module := range modules.Modules {
+			out[module.Name] = HashModuleAsString(modules, graph, module)
+		}
+		return out
+	}
+
+	original := hashes(newModules())
+
+	// Imported, then imported again by another package
+	imported := newModules()
+	prefixModules(imported.Modules, "dep")
+	prefixModules(imported.Modules, "top")
+	imported.Modules = append(imported.Modules, &pbsubstreams.Module{
+		Name:   "map_top_holders",
+		Kind:   &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:test.Holders"}},
+		Inputs: []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Store_{Store: &pbsubstreams.Module_Input_Store{ModuleName: "top:dep:store_balances"}}}},
+	})
+	importedHashes := hashes(imported)
+
+	require.Equal(t, original["map_transfers"], importedHashes["top:dep:map_transfers"])
+	require.Equal(t, original["store_balances"], importedHashes["top:dep:store_balances"])
+}
@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// sinkFilePrefix marks the string values of a sink config replaced by the
+// content of the file they name, relative to the manifest, ex: "@@schema.sql".
+const sinkFilePrefix = "@@"
+
+// Sink is the configuration of the sink consuming the outputs of a module,
+// packed into the package so it travels with it instead of in files next to
+// it. `Config` is decoded as the message `Type`, found in the protobuf files
+// of the package, its fields named as in their JSON mapping.
+type Sink struct {
+	Module string                 `yaml:"module"`
+	Type   string                 `yaml:"type"`
+	Config map[string]interface{} `yaml:"config"`
+}
+
+// loadSink packs the sink config of the manifest into the package, once its
+// protobuf files and imports are loaded.
+func loadSink(pkg *pbsubstreams.Package, manif *Manifest) error {
+	sink := manif.Sink
+	if sink == nil {
+		return nil
+	}
+	if sink.Module == "" {
+		return fmt.Errorf("'module' is required")
+	}
+	if sink.Type == "" {
+		return fmt.Errorf("'type' is required")
+	}
+
+	// Missing imports of the protobuf files are tolerated, as long as the
+	// config message does not use them
+	files, err := protodesc.FileOptions{AllowUnresolvable: true}.NewFiles(&descriptorpb.FileDescriptorSet{File: pkg.ProtoFiles})
+	if err != nil {
+		return fmt.Errorf("loading protobuf files: %w", err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(sink.Type))
+	if err != nil {
+		return fmt.Errorf("type %q not found in the protobuf files: %w", sink.Type, err)
+	}
+	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return fmt.Errorf("type %q is not a message", sink.Type)
+	}
+
+	config, err := resolveSinkFiles(manif, msgDesc, sink.Config)
+	if err != nil {
+		return err
+	}
+	cnt, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	msg := dynamicpb.NewMessage(msgDesc)
+	if err := protojson.Unmarshal(cnt, msg); err != nil {
+		return fmt.Errorf("decoding config as %q: %w", sink.Type, err)
+	}
+
+	pkg.SinkConfig = &anypb.Any{}
+	if err := anypb.MarshalFrom(pkg.SinkConfig, msg, proto.MarshalOptions{Deterministic: true}); err != nil {
+		return fmt.Errorf("packing config: %w", err)
+	}
+	pkg.SinkModule = sink.Module
+	return nil
+}
+
+// resolveSinkFiles returns `config` with the string values prefixed with
+// sinkFilePrefix replaced by the content of the file they name, base64
+// encoded for the bytes fields as their JSON mapping expects.
+func resolveSinkFiles(manif *Manifest, msgDesc protoreflect.MessageDescriptor, config map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		field := msgDesc.Fields().ByJSONName(key)
+		if field == nil {
+			field = msgDesc.Fields().ByName(protoreflect.Name(key))
+		}
+		if field == nil || field.IsMap() {
+			// Unknown fields are reported when decoding
+			out[key] = value
+			continue
+		}
+
+		resolved, err := resolveSinkValue(manif, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", key, err)
+		}
+		out[key] = resolved
+	}
+	return out, nil
+}
+
+func resolveSinkValue(manif *Manifest, field protoreflect.FieldDescriptor, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		if !field.IsList() {
+			return value, nil
+		}
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			resolved, err := resolveSinkValue(manif, field, elem)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+
+	case map[string]interface{}:
+		if field.Message() == nil {
+			return value, nil
+		}
+		return resolveSinkFiles(manif, field.Message(), v)
+
+	case string:
+		if !strings.HasPrefix(v, sinkFilePrefix) {
+			return value, nil
+		}
+		filename := manif.resolvePath(strings.TrimPrefix(v, sinkFilePrefix))
+		cnt, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("reading %q: %w", filename, err)
+		}
+		switch field.Kind() {
+		case protoreflect.StringKind:
+			return string(cnt), nil
+		case protoreflect.BytesKind:
+			return base64.StdEncoding.EncodeToString(cnt), nil
+		}
+		return nil, fmt.Errorf("file %q given to a field of type %s, only string and bytes fields accept files", filename, field.Kind())
+	}
+	return value, nil
+}
+
+// validateSink checks that the sink of `pkg`, when it has one, consumes a
+// map module of the package.
+func validateSink(pkg *pbsubstreams.Package) error {
+	if pkg.SinkModule == "" {
+		if pkg.SinkConfig != nil {
+			return fmt.Errorf("sink config given without a sink module")
+		}
+		return nil
+	}
+	for _, mod := range pkg.Modules.Modules {
+		if mod.Name != pkg.SinkModule {
+			continue
+		}
+		if _, ok := mod.Kind.(*pbsubstreams.Module_KindMap_); !ok {
+			return fmt.Errorf("sink module %q is not of 'map' kind", pkg.SinkModule)
+		}
+		return nil
+	}
+	return fmt.Errorf("sink module %q not found", pkg.SinkModule)
+}
@@ -0,0 +1 @@
+CREATE TABLE transfers (id TEXT PRIMARY KEY);
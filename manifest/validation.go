@@ -0,0 +1,109 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// ValidationError is a problem found validating a manifest or a package,
+// located by the path of the faulty field in the manifest, like
+// `modules[2].inputs[0].map`.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors lists all the problems found validating a manifest or a
+// package. Validation returns it as an error, get it back with `errors.As`.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	problems := make([]string, len(e))
+	for i, problem := range e {
+		problems[i] = problem.Error()
+	}
+	return fmt.Sprintf("%d problems: %s", len(e), strings.Join(problems, "; "))
+}
+
+func (e *ValidationErrors) add(field string, format string, args ...interface{}) {
+	*e = append(*e, &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ReservedModuleNames are the functions the runtime expects the binaries
+// to export, no module can be named after them.
+var ReservedModuleNames = []string{"memory", "alloc", "dealloc"}
+
+func isReservedModuleName(name string) bool {
+	for _, reserved := range ReservedModuleNames {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+var storeCombinations = []string{
+	"max:bigint",
+	"max:int64",
+	"max:bigfloat",
+	"max:float64",
+	"min:bigint",
+	"min:int64",
+	"min:bigfloat",
+	"min:float64",
+	"add:bigint",
+	"add:int64",
+	"add:bigfloat",
+	"add:float64",
+	"set:bytes",
+	"set:string",
+	"set:proto",
+	"set_if_not_exists:bytes",
+	"set_if_not_exists:string",
+	"set_if_not_exists:proto",
+	"append:bytes",
+	"append:string",
+}
+
+// validateStoreCombination checks that the update policy of a store
+// applies to its value type.
+func validateStoreCombination(updatePolicy, valueType string) error {
+	if strings.HasPrefix(valueType, "proto:") {
+		valueType = "proto"
+	}
+	combination := fmt.Sprintf("%s:%s", updatePolicy, valueType)
+	for _, comb := range storeCombinations {
+		if combination == comb {
+			return nil
+		}
+	}
+	return fmt.Errorf("update policy %q does not apply to value type %q, found %q use one of: %s", updatePolicy, valueType, combination, storeCombinations)
+}
+
+var updatePolicyNames = map[pbsubstreams.Module_KindStore_UpdatePolicy]string{
+	pbsubstreams.Module_KindStore_UPDATE_POLICY_SET:               UpdatePolicySet,
+	pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_IF_NOT_EXISTS: UpdatePolicySetIfNotExists,
+	pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD:               UpdatePolicyAdd,
+	pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX:               UpdatePolicyMax,
+	pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN:               UpdatePolicyMin,
+	pbsubstreams.Module_KindStore_UPDATE_POLICY_APPEND:            UpdatePolicyAppend,
+}
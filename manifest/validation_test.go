@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader_ValidationErrors(t *testing.T) {
+	tests := []struct {
+		manifest       string
+		expectProblems []*ValidationError
+	}{
+		{
+			manifest: "unknown_input.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[1].inputs[0].map", Message: `module "store_totals": map input named "map_missing" not found`},
+			},
+		},
+		{
+			manifest: "kind_mismatch.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[1].inputs[0].store", Message: `module "store_totals": input 0: referenced module "map_transfers" not of 'store' kind`},
+			},
+		},
+		{
+			manifest: "update_policy.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[1].updatePolicy", Message: `module "store_totals": update policy "add" does not apply to value type "string", found "add:string" use one of: ` + fmt.Sprint(storeCombinations)},
+			},
+		},
+		{
+			manifest: "missing_proto_type.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[0].output.type", Message: `module "map_transfers": protobuf type "sf.substreams.test.Transfers" not found in the protobuf definitions of the package`},
+			},
+		},
+		{
+			manifest: "type_typo.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[0].inputs[0].source", Message: `module "map_clocks": source protobuf type "sf.substreams.v1.Clok" not found in the protobuf definitions of the package, did you mean "sf.substreams.v1.Clock"?`},
+				{Field: "modules[0].output.type", Message: `module "map_clocks": protobuf type "sf.substreams.v1.Clocks" not found in the protobuf definitions of the package, did you mean "sf.substreams.v1.Clock"?`},
+				{Field: "modules[1].valueType", Message: `module "store_clocks": protobuf type "substreams.Clock" not found in the protobuf definitions of the package, did you mean "sf.substreams.v1.Clock"?`},
+			},
+		},
+		{
+			manifest: "store_modes.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[1].inputs[0].mode", Message: `module "store_totals": input "map:map_transfers": 'mode' only applies to store inputs`},
+				{Field: "modules[1].inputs[1].store", Message: `module "store_totals": a module can't take its own store as input, found in "get" mode`},
+				{Field: "modules[2].inputs[0].mode", Message: `module "map_totals": input "store:store_totals": unknown mode "delta", expected one of: get, deltas`},
+			},
+		},
+		{
+			manifest: "cycle.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules", Message: "dependency cycle: map_transfers -> map_totals -> map_transfers"},
+			},
+		},
+		{
+			manifest: "reserved_name.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[0].name", Message: `module "alloc": entrypoint "alloc" collides with a function reserved by the runtime, one of ["memory" "alloc" "dealloc"]`},
+			},
+		},
+		{
+			manifest: "invalid_version.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "package.version", Message: `version "v1" should match Semver, like v1.2.3 or v1.2.3-rc.1`},
+			},
+		},
+		{
+			manifest: "many_problems.yaml",
+			expectProblems: []*ValidationError{
+				{Field: "modules[0].output.type", Message: `module "map_transfers": protobuf type "sf.substreams.test.Transfers" not found in the protobuf definitions of the package`},
+				{Field: "modules[1].name", Message: `module "map_transfers": name used by more than one module`},
+				{Field: "modules[1].inputs[0].map", Message: `module "map_transfers": map input named "map_missing" not found`},
+				{Field: "modules[2].inputs[0].store", Message: `module "store_totals": input 0: referenced module "map_transfers" not of 'store' kind`},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.manifest, func(t *testing.T) {
+			_, err := NewReader(filepath.Join("testdata/validation", test.manifest), SkipSourceCodeReader()).Read()
+			var problems ValidationErrors
+			require.ErrorAs(t, err, &problems)
+			assert.Equal(t, test.expectProblems, []*ValidationError(problems))
+		})
+	}
+}
+
+func TestValidateModules_UpdatePolicy(t *testing.T) {
+	newModules := func(updatePolicy pbsubstreams.Module_KindStore_UpdatePolicy, valueType string) *pbsubstreams.Modules {
+		return &pbsubstreams.Modules{
+			Modules: []*pbsubstreams.Module{{
+				Name:             "store_totals",
+				BinaryEntrypoint: "store_totals",
+				Kind:             &pbsubstreams.Module_KindStore_{KindStore: &pbsubstreams.Module_KindStore{UpdatePolicy: updatePolicy, ValueType: valueType}},
+			}},
+			Binaries: []*pbsubstreams.Binary{{Type: "wasm/rust-v1"}},
+		}
+	}
+
+	require.NoError(t, ValidateModules(newModules(pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX, "float64")))
+	require.NoError(t, ValidateModules(newModules(pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "proto:sf.substreams.v1.Clock")))
+	require.EqualError(t, ValidateModules(newModules(pbsubstreams.Module_KindStore_UPDATE_POLICY_UNSET, "int64")), `modules[0].updatePolicy: module "store_totals": unknown update policy value 0`)
+	require.EqualError(t, ValidateModules(newModules(pbsubstreams.Module_KindStore_UPDATE_POLICY_APPEND, "int64")), `modules[0].updatePolicy: module "store_totals": update policy "append" does not apply to value type "int64", found "append:int64" use one of: `+fmt.Sprint(storeCombinations))
+}
+
+func TestValidationErrors_Error(t *testing.T) {
+	problems := ValidationErrors{
+		{Field: "modules[0].name", Message: "first"},
+		{Message: "second"},
+	}
+	assert.EqualError(t, problems[:1], "modules[0].name: first")
+	assert.EqualError(t, problems, "2 problems: modules[0].name: first; second")
+}
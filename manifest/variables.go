@@ -0,0 +1,111 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithVariables sets the values of the `${VAR}` substituted in the manifests
+// read, imported manifests included, taking precedence over the environment
+// variables of the same name.
+func WithVariables(vars map[string]string) Options {
+	return func(r *Reader) *Reader {
+		r.variables = vars
+		return r
+	}
+}
+
+// substitutedFields lists the paths of the manifest fields where variables
+// are substituted, `*` matching any key or index.
+var substitutedFields = [][]string{
+	{"imports", "*"},
+	{"protobuf", "files", "*"},
+	{"protobuf", "importPaths", "*"},
+	{"binaries", "*", "file"},
+	{"binaries", "*", "sha256"},
+	{"modules", "*", "initialBlock"},
+	{"modules", "*", "params"},
+}
+
+// substituteVariables replaces the `${VAR}` and `$VAR` of the fields of the
+// manifest `node` listed in substitutedFields by the value of the variable in
+// `vars`, or else in the environment. It fails on the first variable defined
+// in neither, naming the field holding it. The values are substituted before
+// being decoded, so they are checked like the ones written in the manifest.
+func substituteVariables(node *yaml.Node, vars map[string]string) error {
+	if node.Kind == yaml.DocumentNode {
+		for _, child := range node.Content {
+			if err := substituteVariables(child, vars); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return substituteNode(node, nil, "", vars)
+}
+
+func substituteNode(node *yaml.Node, path []string, fieldPath string, vars map[string]string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			childPath := key
+			if fieldPath != "" {
+				childPath = fieldPath + "." + key
+			}
+			if err := substituteNode(node.Content[i+1], append(path, key), childPath, vars); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			if err := substituteNode(child, append(path, strconv.Itoa(i)), fmt.Sprintf("%s[%d]", fieldPath, i), vars); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !isSubstitutedField(path) || !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		var undefined []string
+		value := os.Expand(node.Value, func(name string) string {
+			if value, found := vars[name]; found {
+				return value
+			}
+			if value, found := os.LookupEnv(name); found {
+				return value
+			}
+			undefined = append(undefined, name)
+			return ""
+		})
+		if len(undefined) != 0 {
+			return fmt.Errorf("%s: undefined variable %q", fieldPath, undefined[0])
+		}
+		// Typed from the substituted value, ex: an integer for `initialBlock`
+		node.Value, node.Tag, node.Style = value, "", 0
+	}
+	return nil
+}
+
+func isSubstitutedField(path []string) bool {
+	for _, field := range substitutedFields {
+		if len(field) != len(path) {
+			continue
+		}
+		matches := true
+		for i, segment := range field {
+			if segment != "*" && segment != path[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
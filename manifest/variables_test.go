@@ -0,0 +1,85 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader_Variables(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		manifestPath := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(manifestPath, []byte(content), 0644))
+		return manifestPath
+	}
+	write("dep.yaml", "specVersion: v0.1.0\npackage:\n  name: dep\n  version: v0.0.0\nbinaries:\n  default:\n    type: wasm/rust-v1\n    file: ./${DEP_TARGET}/dep.wasm\n")
+	write("dep_v1.yaml", "specVersion: v0.1.0\npackage:\n  name: dep\n  version: v1.0.0\n")
+	manifestPath := write("test.yaml", `specVersion: v0.1.0
+package:
+  name: test
+  version: v0.0.0
+  doc: Reads $HOME, left as is
+imports:
+  dep: ./${DEP_FILE}
+binaries:
+  default:
+    type: wasm/rust-v1
+    file: ./${TARGET}/module.wasm
+modules:
+  - name: map_transfers
+    kind: map
+    initialBlock: ${START_BLOCK}
+    params: "contract=${CONTRACT}"
+    inputs:
+      - source: sf.substreams.v1.Clock
+    output:
+      type: proto:sf.substreams.v1.Clock
+`)
+
+	t.Setenv("START_BLOCK", "100")
+	t.Setenv("CONTRACT", "0xenv")
+	t.Setenv("TARGET", "release")
+	vars := map[string]string{"START_BLOCK": "15000000", "DEP_FILE": "dep_v1.yaml"}
+
+	manif, err := loadManifestFile(manifestPath, vars)
+	require.NoError(t, err)
+	assert.Equal(t, "./dep_v1.yaml", manif.Imports[0][1])
+	assert.Equal(t, "./release/module.wasm", manif.Binaries["default"].File)
+	assert.Equal(t, "Reads $HOME, left as is", manif.Package.Doc, "not a substituted field")
+
+	pkg, err := NewReader(manifestPath, SkipSourceCodeReader(), WithVariables(vars)).Read()
+	require.NoError(t, err)
+	module := pkg.Modules.Modules[0]
+	assert.Equal(t, uint64(15_000_000), module.InitialBlock, "variables take precedence over the environment")
+	assert.Equal(t, "contract=0xenv", module.Params)
+
+	_, err = NewReader(manifestPath, SkipSourceCodeReader()).Read()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `imports.dep: undefined variable "DEP_FILE"`)
+
+	// Imported manifests are given the variables too
+	vars["DEP_FILE"] = "dep.yaml"
+	_, err = NewReader(manifestPath, SkipSourceCodeReader(), WithVariables(vars)).Read()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `binaries.default.file: undefined variable "DEP_TARGET"`)
+	vars["DEP_TARGET"] = "release"
+	_, err = NewReader(manifestPath, SkipSourceCodeReader(), WithVariables(vars)).Read()
+	require.NoError(t, err)
+
+	vars["START_BLOCK"] = "latest"
+	_, err = loadManifestFile(manifestPath, vars)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "latest")
+}
+
+func TestSubstituteVariables_UndefinedFieldPath(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "test.yaml")
+	require.NoError(t, os.WriteFile(manifestPath, []byte("specVersion: v0.1.0\nmodules:\n  - name: a\n  - name: b\n    initialBlock: ${UNDEFINED_START_BLOCK}\n"), 0644))
+
+	_, err := loadManifestFile(manifestPath, nil)
+	require.EqualError(t, err, `decoding yaml: substituting variables: modules[1].initialBlock: undefined variable "UNDEFINED_START_BLOCK"`)
+}
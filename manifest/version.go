@@ -0,0 +1,94 @@
+package manifest
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// The parts of a package version to bump, see `BumpVersion`.
+const (
+	VersionPatch = "patch"
+	VersionMinor = "minor"
+	VersionMajor = "major"
+)
+
+const substreamsModulePath = "github.com/streamingfast/substreams"
+
+// ValidateVersion checks that `version` is a complete Semver version
+// prefixed by `v`, like `v1.2.3` or `v1.2.3-rc.1`. The shorthands accepted
+// by Go modules, like `v1` or `v1.2`, are refused.
+func ValidateVersion(version string) error {
+	if !semver.IsValid(version) || semver.Canonical(version) != strings.TrimSuffix(version, semver.Build(version)) {
+		return fmt.Errorf("version %q should match Semver, like v1.2.3 or v1.2.3-rc.1", version)
+	}
+	return nil
+}
+
+// CompareVersions returns -1, 0 or +1 whether package version `a` is
+// lower, equal or greater than `b`, following the Semver precedence rules.
+func CompareVersions(a, b string) (int, error) {
+	if err := ValidateVersion(a); err != nil {
+		return 0, err
+	}
+	if err := ValidateVersion(b); err != nil {
+		return 0, err
+	}
+	return semver.Compare(a, b), nil
+}
+
+// BumpVersion increments the `part` of package version `version`, one of
+// `VersionPatch`, `VersionMinor` or `VersionMajor`, resetting the parts
+// after it. The pre-release and build metadata are dropped, ex: bumping the
+// minor part of `v1.2.3-rc.1` gives `v1.3.0`.
+func BumpVersion(version string, part string) (string, error) {
+	if err := ValidateVersion(version); err != nil {
+		return "", err
+	}
+
+	// Validated above, the parts are numbers
+	parts := strings.SplitN(strings.TrimPrefix(semver.Canonical(version), "v"), ".", 3)
+	parts[2] = strings.TrimSuffix(parts[2], semver.Prerelease(version))
+	var major, minor, patch uint64
+	for i, dest := range []*uint64{&major, &minor, &patch} {
+		*dest, _ = strconv.ParseUint(parts[i], 10, 64)
+	}
+
+	switch part {
+	case VersionPatch:
+		patch++
+	case VersionMinor:
+		minor, patch = minor+1, 0
+	case VersionMajor:
+		major, minor, patch = major+1, 0, 0
+	default:
+		return "", fmt.Errorf("unknown version part %q, expected one of: %s, %s, %s", part, VersionPatch, VersionMinor, VersionMajor)
+	}
+	return fmt.Sprintf("v%d.%d.%d", major, minor, patch), nil
+}
+
+// LibraryVersion returns the version of the substreams library the binary
+// was built with, packed in the packages it builds. It is a pseudo-version
+// or `(devel)` when built from a checkout of the library, empty when the
+// build info is not available.
+func LibraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == substreamsModulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == substreamsModulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
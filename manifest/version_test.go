@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidateVersion(t *testing.T) {
+	tests := []struct {
+		version     string
+		expectValid bool
+	}{
+		{"v0.0.0", true},
+		{"v1.2.3", true},
+		{"v1.2.3-rc.1", true},
+		{"v1.2.3+build.5", true},
+		{"v1.2.3-rc.1+build.5", true},
+		{"v1", false},
+		{"v1.0", false},
+		{"1.0.0", false},
+		{"latest", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		err := ValidateVersion(test.version)
+		if test.expectValid {
+			assert.NoError(t, err, test.version)
+		} else {
+			assert.Error(t, err, test.version)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.10.0", -1},
+		{"v2.0.0", "v1.10.0", 1},
+		{"v1.2.3-rc.1", "v1.2.3", -1},
+		{"v1.2.3+build.5", "v1.2.3", 0},
+	}
+
+	for _, test := range tests {
+		cmp, err := CompareVersions(test.a, test.b)
+		require.NoError(t, err)
+		assert.Equal(t, test.expect, cmp, "%s <=> %s", test.a, test.b)
+	}
+
+	_, err := CompareVersions("v1.2.3", "latest")
+	assert.EqualError(t, err, `version "latest" should match Semver, like v1.2.3 or v1.2.3-rc.1`)
+}
+
+func TestBumpVersion(t *testing.T) {
+	tests := []struct {
+		version     string
+		part        string
+		expect      string
+		expectError string
+	}{
+		{version: "v1.2.3", part: VersionPatch, expect: "v1.2.4"},
+		{version: "v1.2.3", part: VersionMinor, expect: "v1.3.0"},
+		{version: "v1.2.3", part: VersionMajor, expect: "v2.0.0"},
+		{version: "v1.2.3-rc.1+build.5", part: VersionMinor, expect: "v1.3.0"},
+		{version: "v0.9.19", part: VersionPatch, expect: "v0.9.20"},
+		{version: "v1.2.3", part: "build", expectError: `unknown version part "build", expected one of: patch, minor, major`},
+		{version: "1.2", part: VersionPatch, expectError: `version "1.2" should match Semver, like v1.2.3 or v1.2.3-rc.1`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version+" "+test.part, func(t *testing.T) {
+			bumped, err := BumpVersion(test.version, test.part)
+			if test.expectError != "" {
+				assert.EqualError(t, err, test.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expect, bumped)
+		})
+	}
+}
+
+func TestReader_InvalidPackageVersion(t *testing.T) {
+	// Packed before the versions were enforced
+	pkg := &pbsubstreams.Package{
+		Version:     1,
+		PackageMeta: []*pbsubstreams.PackageMetadata{{Name: "legacy", Version: "1.0"}},
+		ModuleMeta:  []*pbsubstreams.ModuleMetadata{},
+		Modules:     &pbsubstreams.Modules{},
+	}
+	cnt, err := proto.Marshal(pkg)
+	require.NoError(t, err)
+	spkgPath := filepath.Join(t.TempDir(), "legacy.spkg")
+	require.NoError(t, os.WriteFile(spkgPath, cnt, 0644))
+
+	readBack, err := NewReader(spkgPath).Read()
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", readBack.PackageMeta[0].Version)
+}
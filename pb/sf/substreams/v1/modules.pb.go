@@ -84,7 +84,7 @@ func (x Module_KindStore_UpdatePolicy) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Module_KindStore_UpdatePolicy.Descriptor instead.
 func (Module_KindStore_UpdatePolicy) EnumDescriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 1, 0}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 3, 0}
 }
 
 type Module_Input_Store_Mode int32
@@ -133,7 +133,7 @@ func (x Module_Input_Store_Mode) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Module_Input_Store_Mode.Descriptor instead.
 func (Module_Input_Store_Mode) EnumDescriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 2, 2, 0}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 4, 2, 0}
 }
 
 type Modules struct {
@@ -191,6 +191,54 @@ func (x *Modules) GetBinaries() []*Binary {
 	return nil
 }
 
+// BlockIndexKeys is the output of a block index module for a single block.
+type BlockIndexKeys struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Keys []string `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+}
+
+func (x *BlockIndexKeys) Reset() {
+	*x = BlockIndexKeys{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlockIndexKeys) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockIndexKeys) ProtoMessage() {}
+
+func (x *BlockIndexKeys) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockIndexKeys.ProtoReflect.Descriptor instead.
+func (*BlockIndexKeys) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BlockIndexKeys) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
 // Binary represents some code compiled to its binary form.
 type Binary struct {
 	state         protoimpl.MessageState
@@ -204,7 +252,7 @@ type Binary struct {
 func (x *Binary) Reset() {
 	*x = Binary{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[1]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -217,7 +265,7 @@ func (x *Binary) String() string {
 func (*Binary) ProtoMessage() {}
 
 func (x *Binary) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[1]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -230,7 +278,7 @@ func (x *Binary) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Binary.ProtoReflect.Descriptor instead.
 func (*Binary) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{1}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Binary) GetType() string {
@@ -256,18 +304,26 @@ type Module struct {
 	// Types that are assignable to Kind:
 	//	*Module_KindMap_
 	//	*Module_KindStore_
+	//	*Module_KindBlockIndex_
 	Kind             isModule_Kind   `protobuf_oneof:"kind"`
 	BinaryIndex      uint32          `protobuf:"varint,4,opt,name=binary_index,json=binaryIndex,proto3" json:"binary_index,omitempty"`
 	BinaryEntrypoint string          `protobuf:"bytes,5,opt,name=binary_entrypoint,json=binaryEntrypoint,proto3" json:"binary_entrypoint,omitempty"`
 	Inputs           []*Module_Input `protobuf:"bytes,6,rep,name=inputs,proto3" json:"inputs,omitempty"`
 	Output           *Module_Output  `protobuf:"bytes,7,opt,name=output,proto3" json:"output,omitempty"`
 	InitialBlock     uint64          `protobuf:"varint,8,opt,name=initial_block,json=initialBlock,proto3" json:"initial_block,omitempty"`
+	// When set, the module is only executed on the blocks matching the filter,
+	// other blocks get an empty output.
+	BlockFilter *Module_BlockFilter `protobuf:"bytes,9,opt,name=block_filter,json=blockFilter,proto3" json:"block_filter,omitempty"`
+	// Value the module reads through the `params` host function, fixed for the
+	// whole request, ex: the address of a contract to filter on. It is part of
+	// the module hash.
+	Params string `protobuf:"bytes,11,opt,name=params,proto3" json:"params,omitempty"`
 }
 
 func (x *Module) Reset() {
 	*x = Module{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[2]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -280,7 +336,7 @@ func (x *Module) String() string {
 func (*Module) ProtoMessage() {}
 
 func (x *Module) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[2]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -293,7 +349,7 @@ func (x *Module) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module.ProtoReflect.Descriptor instead.
 func (*Module) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Module) GetName() string {
@@ -324,6 +380,13 @@ func (x *Module) GetKindStore() *Module_KindStore {
 	return nil
 }
 
+func (x *Module) GetKindBlockIndex() *Module_KindBlockIndex {
+	if x, ok := x.GetKind().(*Module_KindBlockIndex_); ok {
+		return x.KindBlockIndex
+	}
+	return nil
+}
+
 func (x *Module) GetBinaryIndex() uint32 {
 	if x != nil {
 		return x.BinaryIndex
@@ -359,6 +422,20 @@ func (x *Module) GetInitialBlock() uint64 {
 	return 0
 }
 
+func (x *Module) GetBlockFilter() *Module_BlockFilter {
+	if x != nil {
+		return x.BlockFilter
+	}
+	return nil
+}
+
+func (x *Module) GetParams() string {
+	if x != nil {
+		return x.Params
+	}
+	return ""
+}
+
 type isModule_Kind interface {
 	isModule_Kind()
 }
@@ -371,10 +448,16 @@ type Module_KindStore_ struct {
 	KindStore *Module_KindStore `protobuf:"bytes,3,opt,name=kind_store,json=kindStore,proto3,oneof"`
 }
 
+type Module_KindBlockIndex_ struct {
+	KindBlockIndex *Module_KindBlockIndex `protobuf:"bytes,10,opt,name=kind_block_index,json=kindBlockIndex,proto3,oneof"`
+}
+
 func (*Module_KindMap_) isModule_Kind() {}
 
 func (*Module_KindStore_) isModule_Kind() {}
 
+func (*Module_KindBlockIndex_) isModule_Kind() {}
+
 type Module_KindMap struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -386,7 +469,7 @@ type Module_KindMap struct {
 func (x *Module_KindMap) Reset() {
 	*x = Module_KindMap{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[3]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -399,7 +482,7 @@ func (x *Module_KindMap) String() string {
 func (*Module_KindMap) ProtoMessage() {}
 
 func (x *Module_KindMap) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[3]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -412,7 +495,7 @@ func (x *Module_KindMap) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module_KindMap.ProtoReflect.Descriptor instead.
 func (*Module_KindMap) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 0}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 0}
 }
 
 func (x *Module_KindMap) GetOutputType() string {
@@ -422,6 +505,105 @@ func (x *Module_KindMap) GetOutputType() string {
 	return ""
 }
 
+// A block index module outputs the `BlockIndexKeys` of each block, which
+// other modules can filter blocks on.
+type Module_KindBlockIndex struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *Module_KindBlockIndex) Reset() {
+	*x = Module_KindBlockIndex{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Module_KindBlockIndex) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Module_KindBlockIndex) ProtoMessage() {}
+
+func (x *Module_KindBlockIndex) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Module_KindBlockIndex.ProtoReflect.Descriptor instead.
+func (*Module_KindBlockIndex) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 1}
+}
+
+type Module_BlockFilter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name of the block index module consulted, ex: "index_events"
+	Module string `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	// Keys that must be present in the index of a block, separated by `||`
+	// when any key must match and by `&&` when all of them must, `&&` binding
+	// tighter than `||`, ex: "transfer && usdc || approval"
+	Query string `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+}
+
+func (x *Module_BlockFilter) Reset() {
+	*x = Module_BlockFilter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Module_BlockFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Module_BlockFilter) ProtoMessage() {}
+
+func (x *Module_BlockFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Module_BlockFilter.ProtoReflect.Descriptor instead.
+func (*Module_BlockFilter) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 2}
+}
+
+func (x *Module_BlockFilter) GetModule() string {
+	if x != nil {
+		return x.Module
+	}
+	return ""
+}
+
+func (x *Module_BlockFilter) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
 type Module_KindStore struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -442,7 +624,7 @@ type Module_KindStore struct {
 func (x *Module_KindStore) Reset() {
 	*x = Module_KindStore{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[4]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -455,7 +637,7 @@ func (x *Module_KindStore) String() string {
 func (*Module_KindStore) ProtoMessage() {}
 
 func (x *Module_KindStore) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[4]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -468,7 +650,7 @@ func (x *Module_KindStore) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module_KindStore.ProtoReflect.Descriptor instead.
 func (*Module_KindStore) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 1}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 3}
 }
 
 func (x *Module_KindStore) GetUpdatePolicy() Module_KindStore_UpdatePolicy {
@@ -500,7 +682,7 @@ type Module_Input struct {
 func (x *Module_Input) Reset() {
 	*x = Module_Input{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[5]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -513,7 +695,7 @@ func (x *Module_Input) String() string {
 func (*Module_Input) ProtoMessage() {}
 
 func (x *Module_Input) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[5]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -526,7 +708,7 @@ func (x *Module_Input) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module_Input.ProtoReflect.Descriptor instead.
 func (*Module_Input) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 2}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 4}
 }
 
 func (m *Module_Input) GetInput() isModule_Input_Input {
@@ -590,7 +772,7 @@ type Module_Output struct {
 func (x *Module_Output) Reset() {
 	*x = Module_Output{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[6]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -603,7 +785,7 @@ func (x *Module_Output) String() string {
 func (*Module_Output) ProtoMessage() {}
 
 func (x *Module_Output) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[6]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -616,7 +798,7 @@ func (x *Module_Output) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module_Output.ProtoReflect.Descriptor instead.
 func (*Module_Output) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 3}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 5}
 }
 
 func (x *Module_Output) GetType() string {
@@ -637,7 +819,7 @@ type Module_Input_Source struct {
 func (x *Module_Input_Source) Reset() {
 	*x = Module_Input_Source{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[7]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -650,7 +832,7 @@ func (x *Module_Input_Source) String() string {
 func (*Module_Input_Source) ProtoMessage() {}
 
 func (x *Module_Input_Source) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[7]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -663,7 +845,7 @@ func (x *Module_Input_Source) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module_Input_Source.ProtoReflect.Descriptor instead.
 func (*Module_Input_Source) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 2, 0}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 4, 0}
 }
 
 func (x *Module_Input_Source) GetType() string {
@@ -684,7 +866,7 @@ type Module_Input_Map struct {
 func (x *Module_Input_Map) Reset() {
 	*x = Module_Input_Map{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[8]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -697,7 +879,7 @@ func (x *Module_Input_Map) String() string {
 func (*Module_Input_Map) ProtoMessage() {}
 
 func (x *Module_Input_Map) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[8]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -710,7 +892,7 @@ func (x *Module_Input_Map) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module_Input_Map.ProtoReflect.Descriptor instead.
 func (*Module_Input_Map) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 2, 1}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 4, 1}
 }
 
 func (x *Module_Input_Map) GetModuleName() string {
@@ -732,7 +914,7 @@ type Module_Input_Store struct {
 func (x *Module_Input_Store) Reset() {
 	*x = Module_Input_Store{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_modules_proto_msgTypes[9]
+		mi := &file_sf_substreams_v1_modules_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -745,7 +927,7 @@ func (x *Module_Input_Store) String() string {
 func (*Module_Input_Store) ProtoMessage() {}
 
 func (x *Module_Input_Store) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_modules_proto_msgTypes[9]
+	mi := &file_sf_substreams_v1_modules_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -758,7 +940,7 @@ func (x *Module_Input_Store) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Module_Input_Store.ProtoReflect.Descriptor instead.
 func (*Module_Input_Store) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{2, 2, 2}
+	return file_sf_substreams_v1_modules_proto_rawDescGZIP(), []int{3, 4, 2}
 }
 
 func (x *Module_Input_Store) GetModuleName() string {
@@ -788,92 +970,110 @@ var file_sf_substreams_v1_modules_proto_rawDesc = []byte{
 	0x73, 0x12, 0x34, 0x0a, 0x08, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20,
 	0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65,
 	0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x52, 0x08, 0x62,
-	0x69, 0x6e, 0x61, 0x72, 0x69, 0x65, 0x73, 0x22, 0x36, 0x0a, 0x06, 0x42, 0x69, 0x6e, 0x61, 0x72,
-	0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22,
-	0xc2, 0x09, 0x0a, 0x06, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
-	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x3d,
-	0x0a, 0x08, 0x6b, 0x69, 0x6e, 0x64, 0x5f, 0x6d, 0x61, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x20, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73,
-	0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x4b, 0x69, 0x6e, 0x64, 0x4d,
-	0x61, 0x70, 0x48, 0x00, 0x52, 0x07, 0x6b, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x70, 0x12, 0x43, 0x0a,
-	0x0a, 0x6b, 0x69, 0x6e, 0x64, 0x5f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x22, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
-	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x4b, 0x69, 0x6e, 0x64,
-	0x53, 0x74, 0x6f, 0x72, 0x65, 0x48, 0x00, 0x52, 0x09, 0x6b, 0x69, 0x6e, 0x64, 0x53, 0x74, 0x6f,
-	0x72, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x5f, 0x69, 0x6e, 0x64,
-	0x65, 0x78, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79,
-	0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x2b, 0x0a, 0x11, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x5f,
-	0x65, 0x6e, 0x74, 0x72, 0x79, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x10, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x70, 0x6f, 0x69,
-	0x6e, 0x74, 0x12, 0x36, 0x0a, 0x06, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
-	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x49, 0x6e, 0x70,
-	0x75, 0x74, 0x52, 0x06, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x73, 0x12, 0x37, 0x0a, 0x06, 0x6f, 0x75,
-	0x74, 0x70, 0x75, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x66, 0x2e,
-	0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f,
-	0x64, 0x75, 0x6c, 0x65, 0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x52, 0x06, 0x6f, 0x75, 0x74,
-	0x70, 0x75, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x62,
-	0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x69, 0x6e, 0x69, 0x74,
-	0x69, 0x61, 0x6c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x1a, 0x2a, 0x0a, 0x07, 0x4b, 0x69, 0x6e, 0x64,
-	0x4d, 0x61, 0x70, 0x12, 0x1f, 0x0a, 0x0b, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f, 0x74, 0x79,
-	0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74,
-	0x54, 0x79, 0x70, 0x65, 0x1a, 0xc5, 0x02, 0x0a, 0x09, 0x4b, 0x69, 0x6e, 0x64, 0x53, 0x74, 0x6f,
-	0x72, 0x65, 0x12, 0x54, 0x0a, 0x0d, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x70, 0x6f, 0x6c,
-	0x69, 0x63, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2f, 0x2e, 0x73, 0x66, 0x2e, 0x73,
-	0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64,
-	0x75, 0x6c, 0x65, 0x2e, 0x4b, 0x69, 0x6e, 0x64, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x55, 0x70,
-	0x64, 0x61, 0x74, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0c, 0x75, 0x70, 0x64, 0x61,
-	0x74, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x76, 0x61, 0x6c, 0x75,
-	0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x22, 0xc2, 0x01, 0x0a, 0x0c, 0x55, 0x70, 0x64, 0x61,
-	0x74, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x17, 0x0a, 0x13, 0x55, 0x50, 0x44, 0x41,
-	0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x45, 0x54, 0x10,
-	0x00, 0x12, 0x15, 0x0a, 0x11, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49,
-	0x43, 0x59, 0x5f, 0x53, 0x45, 0x54, 0x10, 0x01, 0x12, 0x23, 0x0a, 0x1f, 0x55, 0x50, 0x44, 0x41,
-	0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x53, 0x45, 0x54, 0x5f, 0x49, 0x46,
-	0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x45, 0x58, 0x49, 0x53, 0x54, 0x53, 0x10, 0x02, 0x12, 0x15, 0x0a,
-	0x11, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x41,
-	0x44, 0x44, 0x10, 0x03, 0x12, 0x15, 0x0a, 0x11, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50,
-	0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x4d, 0x49, 0x4e, 0x10, 0x04, 0x12, 0x15, 0x0a, 0x11, 0x55,
-	0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x4d, 0x41, 0x58,
-	0x10, 0x05, 0x12, 0x18, 0x0a, 0x14, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c,
-	0x49, 0x43, 0x59, 0x5f, 0x41, 0x50, 0x50, 0x45, 0x4e, 0x44, 0x10, 0x06, 0x1a, 0x9f, 0x03, 0x0a,
-	0x05, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x3f, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73,
+	0x69, 0x6e, 0x61, 0x72, 0x69, 0x65, 0x73, 0x22, 0x24, 0x0a, 0x0e, 0x42, 0x6c, 0x6f, 0x63, 0x6b,
+	0x49, 0x6e, 0x64, 0x65, 0x78, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x22, 0x36, 0x0a,
+	0x06, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63,
+	0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x63, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0xc7, 0x0b, 0x0a, 0x06, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x3d, 0x0a, 0x08, 0x6b, 0x69, 0x6e, 0x64, 0x5f, 0x6d, 0x61, 0x70,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73,
 	0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65,
-	0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x2e, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x48, 0x00, 0x52,
-	0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x36, 0x0a, 0x03, 0x6d, 0x61, 0x70, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
-	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x49,
-	0x6e, 0x70, 0x75, 0x74, 0x2e, 0x4d, 0x61, 0x70, 0x48, 0x00, 0x52, 0x03, 0x6d, 0x61, 0x70, 0x12,
-	0x3c, 0x0a, 0x05, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24,
-	0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76,
-	0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x2e, 0x53,
-	0x74, 0x6f, 0x72, 0x65, 0x48, 0x00, 0x52, 0x05, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x1a, 0x1c, 0x0a,
-	0x06, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x1a, 0x26, 0x0a, 0x03, 0x4d,
-	0x61, 0x70, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4e,
-	0x61, 0x6d, 0x65, 0x1a, 0x8f, 0x01, 0x0a, 0x05, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x1f, 0x0a,
+	0x2e, 0x4b, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x70, 0x48, 0x00, 0x52, 0x07, 0x6b, 0x69, 0x6e, 0x64,
+	0x4d, 0x61, 0x70, 0x12, 0x43, 0x0a, 0x0a, 0x6b, 0x69, 0x6e, 0x64, 0x5f, 0x73, 0x74, 0x6f, 0x72,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x2e, 0x4b, 0x69, 0x6e, 0x64, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x48, 0x00, 0x52, 0x09, 0x6b,
+	0x69, 0x6e, 0x64, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x53, 0x0a, 0x10, 0x6b, 0x69, 0x6e, 0x64,
+	0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x0a, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x27, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x4b, 0x69, 0x6e,
+	0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x48, 0x00, 0x52, 0x0e, 0x6b,
+	0x69, 0x6e, 0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x21, 0x0a,
+	0x0c, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0b, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x49, 0x6e, 0x64, 0x65, 0x78,
+	0x12, 0x2b, 0x0a, 0x11, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x5f, 0x65, 0x6e, 0x74, 0x72, 0x79,
+	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x62, 0x69, 0x6e,
+	0x61, 0x72, 0x79, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x36, 0x0a,
+	0x06, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e,
+	0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x52, 0x06, 0x69,
+	0x6e, 0x70, 0x75, 0x74, 0x73, 0x12, 0x37, 0x0a, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e,
+	0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x52, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x23,
+	0x0a, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x42, 0x6c,
+	0x6f, 0x63, 0x6b, 0x12, 0x47, 0x0a, 0x0c, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x73, 0x66, 0x2e, 0x73,
+	0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64,
+	0x75, 0x6c, 0x65, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52,
+	0x0b, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06,
+	0x70, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70, 0x61,
+	0x72, 0x61, 0x6d, 0x73, 0x1a, 0x2a, 0x0a, 0x07, 0x4b, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x70, 0x12,
+	0x1f, 0x0a, 0x0b, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x54, 0x79, 0x70, 0x65,
+	0x1a, 0x10, 0x0a, 0x0e, 0x4b, 0x69, 0x6e, 0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x49, 0x6e, 0x64,
+	0x65, 0x78, 0x1a, 0x3b, 0x0a, 0x0b, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x46, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65,
+	0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x1a,
+	0xc5, 0x02, 0x0a, 0x09, 0x4b, 0x69, 0x6e, 0x64, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x54, 0x0a,
+	0x0d, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x2f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x4b,
+	0x69, 0x6e, 0x64, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0c, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x50, 0x6f, 0x6c,
+	0x69, 0x63, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x5f, 0x74, 0x79, 0x70,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79,
+	0x70, 0x65, 0x22, 0xc2, 0x01, 0x0a, 0x0c, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x50, 0x6f, 0x6c,
+	0x69, 0x63, 0x79, 0x12, 0x17, 0x0a, 0x13, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f,
+	0x4c, 0x49, 0x43, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x45, 0x54, 0x10, 0x00, 0x12, 0x15, 0x0a, 0x11,
+	0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x53, 0x45,
+	0x54, 0x10, 0x01, 0x12, 0x23, 0x0a, 0x1f, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f,
+	0x4c, 0x49, 0x43, 0x59, 0x5f, 0x53, 0x45, 0x54, 0x5f, 0x49, 0x46, 0x5f, 0x4e, 0x4f, 0x54, 0x5f,
+	0x45, 0x58, 0x49, 0x53, 0x54, 0x53, 0x10, 0x02, 0x12, 0x15, 0x0a, 0x11, 0x55, 0x50, 0x44, 0x41,
+	0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x41, 0x44, 0x44, 0x10, 0x03, 0x12,
+	0x15, 0x0a, 0x11, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59,
+	0x5f, 0x4d, 0x49, 0x4e, 0x10, 0x04, 0x12, 0x15, 0x0a, 0x11, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45,
+	0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x4d, 0x41, 0x58, 0x10, 0x05, 0x12, 0x18, 0x0a,
+	0x14, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x50, 0x4f, 0x4c, 0x49, 0x43, 0x59, 0x5f, 0x41,
+	0x50, 0x50, 0x45, 0x4e, 0x44, 0x10, 0x06, 0x1a, 0x9f, 0x03, 0x0a, 0x05, 0x49, 0x6e, 0x70, 0x75,
+	0x74, 0x12, 0x3f, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x25, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x49, 0x6e, 0x70, 0x75,
+	0x74, 0x2e, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x48, 0x00, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x12, 0x36, 0x0a, 0x03, 0x6d, 0x61, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x22, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x2e,
+	0x4d, 0x61, 0x70, 0x48, 0x00, 0x52, 0x03, 0x6d, 0x61, 0x70, 0x12, 0x3c, 0x0a, 0x05, 0x73, 0x74,
+	0x6f, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x73, 0x66, 0x2e, 0x73,
+	0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64,
+	0x75, 0x6c, 0x65, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x48,
+	0x00, 0x52, 0x05, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x1a, 0x1c, 0x0a, 0x06, 0x53, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x1a, 0x26, 0x0a, 0x03, 0x4d, 0x61, 0x70, 0x12, 0x1f, 0x0a,
 	0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x3d,
-	0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x29, 0x2e, 0x73,
-	0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e,
-	0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x2e, 0x53, 0x74, 0x6f,
-	0x72, 0x65, 0x2e, 0x4d, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x22, 0x26, 0x0a,
-	0x04, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x55, 0x4e, 0x53, 0x45, 0x54, 0x10, 0x00,
-	0x12, 0x07, 0x0a, 0x03, 0x47, 0x45, 0x54, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x44, 0x45, 0x4c,
-	0x54, 0x41, 0x53, 0x10, 0x02, 0x42, 0x07, 0x0a, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x1c,
-	0x0a, 0x06, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x42, 0x06, 0x0a, 0x04,
-	0x6b, 0x69, 0x6e, 0x64, 0x42, 0x46, 0x5a, 0x44, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x69, 0x6e, 0x67, 0x66, 0x61, 0x73, 0x74,
-	0x2f, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x70, 0x62, 0x2f, 0x73,
-	0x66, 0x2f, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x76, 0x31, 0x3b,
-	0x70, 0x62, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x62, 0x06, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x33,
+	0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x1a, 0x8f,
+	0x01, 0x0a, 0x05, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75,
+	0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d,
+	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x3d, 0x0a, 0x04, 0x6d, 0x6f, 0x64,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x29, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x4d, 0x6f,
+	0x64, 0x65, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x22, 0x26, 0x0a, 0x04, 0x4d, 0x6f, 0x64, 0x65,
+	0x12, 0x09, 0x0a, 0x05, 0x55, 0x4e, 0x53, 0x45, 0x54, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x47,
+	0x45, 0x54, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x44, 0x45, 0x4c, 0x54, 0x41, 0x53, 0x10, 0x02,
+	0x42, 0x07, 0x0a, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x1c, 0x0a, 0x06, 0x4f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x42, 0x06, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x42,
+	0x46, 0x5a, 0x44, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x69, 0x6e, 0x67, 0x66, 0x61, 0x73, 0x74, 0x2f, 0x73, 0x75, 0x62, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x70, 0x62, 0x2f, 0x73, 0x66, 0x2f, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x70, 0x62, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -889,38 +1089,43 @@ func file_sf_substreams_v1_modules_proto_rawDescGZIP() []byte {
 }
 
 var file_sf_substreams_v1_modules_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_sf_substreams_v1_modules_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_sf_substreams_v1_modules_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_sf_substreams_v1_modules_proto_goTypes = []interface{}{
 	(Module_KindStore_UpdatePolicy)(0), // 0: sf.substreams.v1.Module.KindStore.UpdatePolicy
 	(Module_Input_Store_Mode)(0),       // 1: sf.substreams.v1.Module.Input.Store.Mode
 	(*Modules)(nil),                    // 2: sf.substreams.v1.Modules
-	(*Binary)(nil),                     // 3: sf.substreams.v1.Binary
-	(*Module)(nil),                     // 4: sf.substreams.v1.Module
-	(*Module_KindMap)(nil),             // 5: sf.substreams.v1.Module.KindMap
-	(*Module_KindStore)(nil),           // 6: sf.substreams.v1.Module.KindStore
-	(*Module_Input)(nil),               // 7: sf.substreams.v1.Module.Input
-	(*Module_Output)(nil),              // 8: sf.substreams.v1.Module.Output
-	(*Module_Input_Source)(nil),        // 9: sf.substreams.v1.Module.Input.Source
-	(*Module_Input_Map)(nil),           // 10: sf.substreams.v1.Module.Input.Map
-	(*Module_Input_Store)(nil),         // 11: sf.substreams.v1.Module.Input.Store
+	(*BlockIndexKeys)(nil),             // 3: sf.substreams.v1.BlockIndexKeys
+	(*Binary)(nil),                     // 4: sf.substreams.v1.Binary
+	(*Module)(nil),                     // 5: sf.substreams.v1.Module
+	(*Module_KindMap)(nil),             // 6: sf.substreams.v1.Module.KindMap
+	(*Module_KindBlockIndex)(nil),      // 7: sf.substreams.v1.Module.KindBlockIndex
+	(*Module_BlockFilter)(nil),         // 8: sf.substreams.v1.Module.BlockFilter
+	(*Module_KindStore)(nil),           // 9: sf.substreams.v1.Module.KindStore
+	(*Module_Input)(nil),               // 10: sf.substreams.v1.Module.Input
+	(*Module_Output)(nil),              // 11: sf.substreams.v1.Module.Output
+	(*Module_Input_Source)(nil),        // 12: sf.substreams.v1.Module.Input.Source
+	(*Module_Input_Map)(nil),           // 13: sf.substreams.v1.Module.Input.Map
+	(*Module_Input_Store)(nil),         // 14: sf.substreams.v1.Module.Input.Store
 }
 var file_sf_substreams_v1_modules_proto_depIdxs = []int32{
-	4,  // 0: sf.substreams.v1.Modules.modules:type_name -> sf.substreams.v1.Module
-	3,  // 1: sf.substreams.v1.Modules.binaries:type_name -> sf.substreams.v1.Binary
-	5,  // 2: sf.substreams.v1.Module.kind_map:type_name -> sf.substreams.v1.Module.KindMap
-	6,  // 3: sf.substreams.v1.Module.kind_store:type_name -> sf.substreams.v1.Module.KindStore
-	7,  // 4: sf.substreams.v1.Module.inputs:type_name -> sf.substreams.v1.Module.Input
-	8,  // 5: sf.substreams.v1.Module.output:type_name -> sf.substreams.v1.Module.Output
-	0,  // 6: sf.substreams.v1.Module.KindStore.update_policy:type_name -> sf.substreams.v1.Module.KindStore.UpdatePolicy
-	9,  // 7: sf.substreams.v1.Module.Input.source:type_name -> sf.substreams.v1.Module.Input.Source
-	10, // 8: sf.substreams.v1.Module.Input.map:type_name -> sf.substreams.v1.Module.Input.Map
-	11, // 9: sf.substreams.v1.Module.Input.store:type_name -> sf.substreams.v1.Module.Input.Store
-	1,  // 10: sf.substreams.v1.Module.Input.Store.mode:type_name -> sf.substreams.v1.Module.Input.Store.Mode
-	11, // [11:11] is the sub-list for method output_type
-	11, // [11:11] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	5,  // 0: sf.substreams.v1.Modules.modules:type_name -> sf.substreams.v1.Module
+	4,  // 1: sf.substreams.v1.Modules.binaries:type_name -> sf.substreams.v1.Binary
+	6,  // 2: sf.substreams.v1.Module.kind_map:type_name -> sf.substreams.v1.Module.KindMap
+	9,  // 3: sf.substreams.v1.Module.kind_store:type_name -> sf.substreams.v1.Module.KindStore
+	7,  // 4: sf.substreams.v1.Module.kind_block_index:type_name -> sf.substreams.v1.Module.KindBlockIndex
+	10, // 5: sf.substreams.v1.Module.inputs:type_name -> sf.substreams.v1.Module.Input
+	11, // 6: sf.substreams.v1.Module.output:type_name -> sf.substreams.v1.Module.Output
+	8,  // 7: sf.substreams.v1.Module.block_filter:type_name -> sf.substreams.v1.Module.BlockFilter
+	0,  // 8: sf.substreams.v1.Module.KindStore.update_policy:type_name -> sf.substreams.v1.Module.KindStore.UpdatePolicy
+	12, // 9: sf.substreams.v1.Module.Input.source:type_name -> sf.substreams.v1.Module.Input.Source
+	13, // 10: sf.substreams.v1.Module.Input.map:type_name -> sf.substreams.v1.Module.Input.Map
+	14, // 11: sf.substreams.v1.Module.Input.store:type_name -> sf.substreams.v1.Module.Input.Store
+	1,  // 12: sf.substreams.v1.Module.Input.Store.mode:type_name -> sf.substreams.v1.Module.Input.Store.Mode
+	13, // [13:13] is the sub-list for method output_type
+	13, // [13:13] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_sf_substreams_v1_modules_proto_init() }
@@ -942,7 +1147,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Binary); i {
+			switch v := v.(*BlockIndexKeys); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -954,7 +1159,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Module); i {
+			switch v := v.(*Binary); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -966,7 +1171,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Module_KindMap); i {
+			switch v := v.(*Module); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -978,7 +1183,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Module_KindStore); i {
+			switch v := v.(*Module_KindMap); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -990,7 +1195,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Module_Input); i {
+			switch v := v.(*Module_KindBlockIndex); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1002,7 +1207,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Module_Output); i {
+			switch v := v.(*Module_BlockFilter); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1014,7 +1219,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Module_Input_Source); i {
+			switch v := v.(*Module_KindStore); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1026,7 +1231,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Module_Input_Map); i {
+			switch v := v.(*Module_Input); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1038,6 +1243,42 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_modules_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Module_Output); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_modules_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Module_Input_Source); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_modules_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Module_Input_Map); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_modules_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Module_Input_Store); i {
 			case 0:
 				return &v.state
@@ -1050,11 +1291,12 @@ func file_sf_substreams_v1_modules_proto_init() {
 			}
 		}
 	}
-	file_sf_substreams_v1_modules_proto_msgTypes[2].OneofWrappers = []interface{}{
+	file_sf_substreams_v1_modules_proto_msgTypes[3].OneofWrappers = []interface{}{
 		(*Module_KindMap_)(nil),
 		(*Module_KindStore_)(nil),
+		(*Module_KindBlockIndex_)(nil),
 	}
-	file_sf_substreams_v1_modules_proto_msgTypes[5].OneofWrappers = []interface{}{
+	file_sf_substreams_v1_modules_proto_msgTypes[8].OneofWrappers = []interface{}{
 		(*Module_Input_Source_)(nil),
 		(*Module_Input_Map_)(nil),
 		(*Module_Input_Store_)(nil),
@@ -1065,7 +1307,7 @@ func file_sf_substreams_v1_modules_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_sf_substreams_v1_modules_proto_rawDesc,
 			NumEnums:      2,
-			NumMessages:   10,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
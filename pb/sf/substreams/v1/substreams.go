@@ -52,5 +52,56 @@ func ValidateRequest(req *Request) error {
 		}
 	}
 
+	for modName := range req.InitialBlockOverrides {
+		if !seenMods[modName] {
+			return fmt.Errorf("initial block override: %q: not defined modules graph", modName)
+		}
+	}
+
+	if snapshot := req.DebugStoreSnapshot; snapshot != nil {
+		if !req.DevelopmentMode {
+			return fmt.Errorf("debug store snapshot: only available in development mode")
+		}
+		if len(snapshot.StoreModules) == 0 {
+			return fmt.Errorf("debug store snapshot: no store module listed")
+		}
+		if req.StartBlockNum >= 0 && snapshot.AtBlock < uint64(req.StartBlockNum) {
+			return fmt.Errorf("debug store snapshot: block %d is before the start block %d", snapshot.AtBlock, req.StartBlockNum)
+		}
+		for _, modName := range snapshot.StoreModules {
+			if !seenStores[modName] {
+				if seenMods[modName] {
+					return fmt.Errorf("debug store snapshot: %q: not a 'store' module", modName)
+				}
+				return fmt.Errorf("debug store snapshot: %q: not defined modules graph", modName)
+			}
+		}
+	}
+
+	if len(req.FullKvStoreOutputs) != 0 {
+		if !req.DevelopmentMode {
+			return fmt.Errorf("full kv store outputs: only available in development mode")
+		}
+		outputModules := map[string]bool{}
+		for _, outMod := range req.OutputModules {
+			outputModules[outMod] = true
+		}
+		for _, modName := range req.FullKvStoreOutputs {
+			if !seenStores[modName] {
+				if seenMods[modName] {
+					return fmt.Errorf("full kv store outputs: %q: not a 'store' module", modName)
+				}
+				return fmt.Errorf("full kv store outputs: %q: not defined modules graph", modName)
+			}
+			if !outputModules[modName] {
+				return fmt.Errorf("full kv store outputs: %q: not a requested output module", modName)
+			}
+		}
+	}
+
+	if _, found := LogLevel_name[int32(req.MinLogLevel)]; !found {
+		return fmt.Errorf("min log level: unknown level %d", req.MinLogLevel)
+	}
+
 	return nil
 }
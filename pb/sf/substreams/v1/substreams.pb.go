@@ -22,6 +22,59 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type LogLevel int32
+
+const (
+	LogLevel_LOG_LEVEL_DEBUG LogLevel = 0
+	// Level of the logs of the `println` import, which has no level
+	LogLevel_LOG_LEVEL_INFO  LogLevel = 1
+	LogLevel_LOG_LEVEL_WARN  LogLevel = 2
+	LogLevel_LOG_LEVEL_ERROR LogLevel = 3
+)
+
+// Enum value maps for LogLevel.
+var (
+	LogLevel_name = map[int32]string{
+		0: "LOG_LEVEL_DEBUG",
+		1: "LOG_LEVEL_INFO",
+		2: "LOG_LEVEL_WARN",
+		3: "LOG_LEVEL_ERROR",
+	}
+	LogLevel_value = map[string]int32{
+		"LOG_LEVEL_DEBUG": 0,
+		"LOG_LEVEL_INFO":  1,
+		"LOG_LEVEL_WARN":  2,
+		"LOG_LEVEL_ERROR": 3,
+	}
+)
+
+func (x LogLevel) Enum() *LogLevel {
+	p := new(LogLevel)
+	*p = x
+	return p
+}
+
+func (x LogLevel) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LogLevel) Descriptor() protoreflect.EnumDescriptor {
+	return file_sf_substreams_v1_substreams_proto_enumTypes[0].Descriptor()
+}
+
+func (LogLevel) Type() protoreflect.EnumType {
+	return &file_sf_substreams_v1_substreams_proto_enumTypes[0]
+}
+
+func (x LogLevel) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LogLevel.Descriptor instead.
+func (LogLevel) EnumDescriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{0}
+}
+
 type ForkStep int32
 
 const (
@@ -61,11 +114,11 @@ func (x ForkStep) String() string {
 }
 
 func (ForkStep) Descriptor() protoreflect.EnumDescriptor {
-	return file_sf_substreams_v1_substreams_proto_enumTypes[0].Descriptor()
+	return file_sf_substreams_v1_substreams_proto_enumTypes[1].Descriptor()
 }
 
 func (ForkStep) Type() protoreflect.EnumType {
-	return &file_sf_substreams_v1_substreams_proto_enumTypes[0]
+	return &file_sf_substreams_v1_substreams_proto_enumTypes[1]
 }
 
 func (x ForkStep) Number() protoreflect.EnumNumber {
@@ -74,7 +127,7 @@ func (x ForkStep) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ForkStep.Descriptor instead.
 func (ForkStep) EnumDescriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{0}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{1}
 }
 
 type StoreDelta_Operation int32
@@ -113,11 +166,11 @@ func (x StoreDelta_Operation) String() string {
 }
 
 func (StoreDelta_Operation) Descriptor() protoreflect.EnumDescriptor {
-	return file_sf_substreams_v1_substreams_proto_enumTypes[1].Descriptor()
+	return file_sf_substreams_v1_substreams_proto_enumTypes[2].Descriptor()
 }
 
 func (StoreDelta_Operation) Type() protoreflect.EnumType {
-	return &file_sf_substreams_v1_substreams_proto_enumTypes[1]
+	return &file_sf_substreams_v1_substreams_proto_enumTypes[2]
 }
 
 func (x StoreDelta_Operation) Number() protoreflect.EnumNumber {
@@ -126,7 +179,7 @@ func (x StoreDelta_Operation) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use StoreDelta_Operation.Descriptor instead.
 func (StoreDelta_Operation) EnumDescriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{10, 0}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{22, 0}
 }
 
 type Request struct {
@@ -142,6 +195,62 @@ type Request struct {
 	Modules                        *Modules   `protobuf:"bytes,6,opt,name=modules,proto3" json:"modules,omitempty"`
 	OutputModules                  []string   `protobuf:"bytes,7,rep,name=output_modules,json=outputModules,proto3" json:"output_modules,omitempty"`
 	InitialStoreSnapshotForModules []string   `protobuf:"bytes,8,rep,name=initial_store_snapshot_for_modules,json=initialStoreSnapshotForModules,proto3" json:"initial_store_snapshot_for_modules,omitempty"`
+	// DevelopmentMode enables debugging features that are too costly to be
+	// offered on production streams.
+	DevelopmentMode bool `protobuf:"varint,9,opt,name=development_mode,json=developmentMode,proto3" json:"development_mode,omitempty"`
+	// DebugStoreSnapshot requests the full content of some stores at a given
+	// block, only honored in development mode.
+	DebugStoreSnapshot *DebugStoreSnapshotRequest `protobuf:"bytes,10,opt,name=debug_store_snapshot,json=debugStoreSnapshot,proto3" json:"debug_store_snapshot,omitempty"`
+	// TruncateOversizedOutputs replaces the output of a module going over the
+	// server output size limit by an empty one flagged with `output_truncated`,
+	// instead of failing the stream. Only the outputs no other module of the
+	// request depends on are truncated, an oversized output consumed by another
+	// module still fails the stream.
+	TruncateOversizedOutputs bool `protobuf:"varint,11,opt,name=truncate_oversized_outputs,json=truncateOversizedOutputs,proto3" json:"truncate_oversized_outputs,omitempty"`
+	// QuarantineFailedModules keeps the stream going when a module fails
+	// deterministically, as long as some requested output modules do not
+	// depend on it. The failed module and the modules depending on it are not
+	// executed anymore, and the stream ends in error if any of them is a
+	// requested output module.
+	QuarantineFailedModules bool `protobuf:"varint,12,opt,name=quarantine_failed_modules,json=quarantineFailedModules,proto3" json:"quarantine_failed_modules,omitempty"`
+	// FinalBlocksOnly delays the stream to finality: only final blocks are
+	// processed and sent, all of them with the `STEP_IRREVERSIBLE` step, so no
+	// undo is ever sent. A start cursor must then point to a final block.
+	FinalBlocksOnly bool `protobuf:"varint,13,opt,name=final_blocks_only,json=finalBlocksOnly,proto3" json:"final_blocks_only,omitempty"`
+	// FullKvStoreOutputs lists requested output stores whose output carries
+	// their full content after each block, sorted by key, instead of their
+	// deltas. Only available in development mode, the content is capped.
+	FullKvStoreOutputs []string `protobuf:"bytes,14,rep,name=full_kv_store_outputs,json=fullKvStoreOutputs,proto3" json:"full_kv_store_outputs,omitempty"`
+	// PackageName and PackageVersion identify the package of the modules, the
+	// server records the module hashes used by each package version under them.
+	// Not recorded when either is empty.
+	PackageName    string `protobuf:"bytes,15,opt,name=package_name,json=packageName,proto3" json:"package_name,omitempty"`
+	PackageVersion string `protobuf:"bytes,16,opt,name=package_version,json=packageVersion,proto3" json:"package_version,omitempty"`
+	// MinLogLevel drops the logs of the modules under this level, they don't
+	// count against the logs limit of the modules either. All the logs are kept
+	// by default.
+	MinLogLevel LogLevel `protobuf:"varint,17,opt,name=min_log_level,json=minLogLevel,proto3,enum=sf.substreams.v1.LogLevel" json:"min_log_level,omitempty"`
+	// MaxLogByteCount lowers the size of the logs kept for each execution of a
+	// module, it can't go over the server limit.
+	MaxLogByteCount uint64 `protobuf:"varint,18,opt,name=max_log_byte_count,json=maxLogByteCount,proto3" json:"max_log_byte_count,omitempty"`
+	// InitialBlockOverrides starts modules, by name, at a later block than the
+	// initial block they declare, ex: to try a module from a recent block
+	// without changing its package. An override can't be lower than the
+	// declared initial block of the module or of any of its ancestors. The
+	// modules overridden and the ones depending on them get other hashes, so
+	// they don't share caches with the declared initial blocks.
+	InitialBlockOverrides map[string]uint64 `protobuf:"bytes,19,rep,name=initial_block_overrides,json=initialBlockOverrides,proto3" json:"initial_block_overrides,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// Network the modules are made for, usually the one of their package, ex:
+	// "mainnet". The request is refused by a server of another network, unless
+	// `allow_network_mismatch` is set. Not checked when empty.
+	Network string `protobuf:"bytes,20,opt,name=network,proto3" json:"network,omitempty"`
+	// AllowNetworkMismatch lets the request run on a server of another network
+	// than `network`, which is only logged.
+	AllowNetworkMismatch bool `protobuf:"varint,21,opt,name=allow_network_mismatch,json=allowNetworkMismatch,proto3" json:"allow_network_mismatch,omitempty"`
+	// SubstreamsVersion is the version of the substreams library that built the
+	// package of the modules, the server logs a warning when it is newer than
+	// its own. Not checked when empty.
+	SubstreamsVersion string `protobuf:"bytes,22,opt,name=substreams_version,json=substreamsVersion,proto3" json:"substreams_version,omitempty"`
 }
 
 func (x *Request) Reset() {
@@ -232,6 +341,180 @@ func (x *Request) GetInitialStoreSnapshotForModules() []string {
 	return nil
 }
 
+func (x *Request) GetDevelopmentMode() bool {
+	if x != nil {
+		return x.DevelopmentMode
+	}
+	return false
+}
+
+func (x *Request) GetDebugStoreSnapshot() *DebugStoreSnapshotRequest {
+	if x != nil {
+		return x.DebugStoreSnapshot
+	}
+	return nil
+}
+
+func (x *Request) GetTruncateOversizedOutputs() bool {
+	if x != nil {
+		return x.TruncateOversizedOutputs
+	}
+	return false
+}
+
+func (x *Request) GetQuarantineFailedModules() bool {
+	if x != nil {
+		return x.QuarantineFailedModules
+	}
+	return false
+}
+
+func (x *Request) GetFinalBlocksOnly() bool {
+	if x != nil {
+		return x.FinalBlocksOnly
+	}
+	return false
+}
+
+func (x *Request) GetFullKvStoreOutputs() []string {
+	if x != nil {
+		return x.FullKvStoreOutputs
+	}
+	return nil
+}
+
+func (x *Request) GetPackageName() string {
+	if x != nil {
+		return x.PackageName
+	}
+	return ""
+}
+
+func (x *Request) GetPackageVersion() string {
+	if x != nil {
+		return x.PackageVersion
+	}
+	return ""
+}
+
+func (x *Request) GetMinLogLevel() LogLevel {
+	if x != nil {
+		return x.MinLogLevel
+	}
+	return LogLevel_LOG_LEVEL_DEBUG
+}
+
+func (x *Request) GetMaxLogByteCount() uint64 {
+	if x != nil {
+		return x.MaxLogByteCount
+	}
+	return 0
+}
+
+func (x *Request) GetInitialBlockOverrides() map[string]uint64 {
+	if x != nil {
+		return x.InitialBlockOverrides
+	}
+	return nil
+}
+
+func (x *Request) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Request) GetAllowNetworkMismatch() bool {
+	if x != nil {
+		return x.AllowNetworkMismatch
+	}
+	return false
+}
+
+func (x *Request) GetSubstreamsVersion() string {
+	if x != nil {
+		return x.SubstreamsVersion
+	}
+	return ""
+}
+
+type DebugStoreSnapshotRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StoreModules []string `protobuf:"bytes,1,rep,name=store_modules,json=storeModules,proto3" json:"store_modules,omitempty"`
+	// AtBlock is the block after which the stores are sent, the first block
+	// processed at or after it if it was skipped by the chain.
+	AtBlock uint64 `protobuf:"varint,2,opt,name=at_block,json=atBlock,proto3" json:"at_block,omitempty"`
+	// KeyPrefix restricts the keys sent to the ones starting with it, when set.
+	KeyPrefix string `protobuf:"bytes,3,opt,name=key_prefix,json=keyPrefix,proto3" json:"key_prefix,omitempty"`
+	// MaxKeys lowers the number of keys sent per store, it can't go over the
+	// server limit.
+	MaxKeys uint64 `protobuf:"varint,4,opt,name=max_keys,json=maxKeys,proto3" json:"max_keys,omitempty"`
+}
+
+func (x *DebugStoreSnapshotRequest) Reset() {
+	*x = DebugStoreSnapshotRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugStoreSnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugStoreSnapshotRequest) ProtoMessage() {}
+
+func (x *DebugStoreSnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugStoreSnapshotRequest.ProtoReflect.Descriptor instead.
+func (*DebugStoreSnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DebugStoreSnapshotRequest) GetStoreModules() []string {
+	if x != nil {
+		return x.StoreModules
+	}
+	return nil
+}
+
+func (x *DebugStoreSnapshotRequest) GetAtBlock() uint64 {
+	if x != nil {
+		return x.AtBlock
+	}
+	return 0
+}
+
+func (x *DebugStoreSnapshotRequest) GetKeyPrefix() string {
+	if x != nil {
+		return x.KeyPrefix
+	}
+	return ""
+}
+
+func (x *DebugStoreSnapshotRequest) GetMaxKeys() uint64 {
+	if x != nil {
+		return x.MaxKeys
+	}
+	return 0
+}
+
 type Response struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -242,13 +525,18 @@ type Response struct {
 	//	*Response_SnapshotData
 	//	*Response_SnapshotComplete
 	//	*Response_Data
+	//	*Response_UndoSignal
+	//	*Response_DebugStoreSnapshot
+	//	*Response_Stats
+	//	*Response_SessionInit
+	//	*Response_ModuleFailure
 	Message isResponse_Message `protobuf_oneof:"message"`
 }
 
 func (x *Response) Reset() {
 	*x = Response{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[1]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -261,7 +549,7 @@ func (x *Response) String() string {
 func (*Response) ProtoMessage() {}
 
 func (x *Response) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[1]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -274,7 +562,7 @@ func (x *Response) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Response.ProtoReflect.Descriptor instead.
 func (*Response) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{1}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{2}
 }
 
 func (m *Response) GetMessage() isResponse_Message {
@@ -312,6 +600,41 @@ func (x *Response) GetData() *BlockScopedData {
 	return nil
 }
 
+func (x *Response) GetUndoSignal() *BlockUndoSignal {
+	if x, ok := x.GetMessage().(*Response_UndoSignal); ok {
+		return x.UndoSignal
+	}
+	return nil
+}
+
+func (x *Response) GetDebugStoreSnapshot() *DebugStoreSnapshot {
+	if x, ok := x.GetMessage().(*Response_DebugStoreSnapshot); ok {
+		return x.DebugStoreSnapshot
+	}
+	return nil
+}
+
+func (x *Response) GetStats() *PipelineStats {
+	if x, ok := x.GetMessage().(*Response_Stats); ok {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *Response) GetSessionInit() *SessionInit {
+	if x, ok := x.GetMessage().(*Response_SessionInit); ok {
+		return x.SessionInit
+	}
+	return nil
+}
+
+func (x *Response) GetModuleFailure() *ModuleFailure {
+	if x, ok := x.GetMessage().(*Response_ModuleFailure); ok {
+		return x.ModuleFailure
+	}
+	return nil
+}
+
 type isResponse_Message interface {
 	isResponse_Message()
 }
@@ -332,6 +655,28 @@ type Response_Data struct {
 	Data *BlockScopedData `protobuf:"bytes,4,opt,name=data,proto3,oneof"`
 }
 
+type Response_UndoSignal struct {
+	UndoSignal *BlockUndoSignal `protobuf:"bytes,5,opt,name=undo_signal,json=undoSignal,proto3,oneof"`
+}
+
+type Response_DebugStoreSnapshot struct {
+	DebugStoreSnapshot *DebugStoreSnapshot `protobuf:"bytes,6,opt,name=debug_store_snapshot,json=debugStoreSnapshot,proto3,oneof"`
+}
+
+type Response_Stats struct {
+	Stats *PipelineStats `protobuf:"bytes,7,opt,name=stats,proto3,oneof"`
+}
+
+type Response_SessionInit struct {
+	SessionInit *SessionInit `protobuf:"bytes,8,opt,name=session_init,json=sessionInit,proto3,oneof"`
+}
+
+type Response_ModuleFailure struct {
+	// Sent as soon as a module fails, when the request sets
+	// `quarantine_failed_modules`.
+	ModuleFailure *ModuleFailure `protobuf:"bytes,9,opt,name=module_failure,json=moduleFailure,proto3,oneof"`
+}
+
 func (*Response_Progress) isResponse_Message() {}
 
 func (*Response_SnapshotData) isResponse_Message() {}
@@ -340,6 +685,16 @@ func (*Response_SnapshotComplete) isResponse_Message() {}
 
 func (*Response_Data) isResponse_Message() {}
 
+func (*Response_UndoSignal) isResponse_Message() {}
+
+func (*Response_DebugStoreSnapshot) isResponse_Message() {}
+
+func (*Response_Stats) isResponse_Message() {}
+
+func (*Response_SessionInit) isResponse_Message() {}
+
+func (*Response_ModuleFailure) isResponse_Message() {}
+
 type InitialSnapshotComplete struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -351,7 +706,7 @@ type InitialSnapshotComplete struct {
 func (x *InitialSnapshotComplete) Reset() {
 	*x = InitialSnapshotComplete{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[2]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -364,7 +719,7 @@ func (x *InitialSnapshotComplete) String() string {
 func (*InitialSnapshotComplete) ProtoMessage() {}
 
 func (x *InitialSnapshotComplete) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[2]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -377,7 +732,7 @@ func (x *InitialSnapshotComplete) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use InitialSnapshotComplete.ProtoReflect.Descriptor instead.
 func (*InitialSnapshotComplete) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{2}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *InitialSnapshotComplete) GetCursor() string {
@@ -401,7 +756,7 @@ type InitialSnapshotData struct {
 func (x *InitialSnapshotData) Reset() {
 	*x = InitialSnapshotData{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[3]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -414,7 +769,7 @@ func (x *InitialSnapshotData) String() string {
 func (*InitialSnapshotData) ProtoMessage() {}
 
 func (x *InitialSnapshotData) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[3]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -427,7 +782,7 @@ func (x *InitialSnapshotData) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use InitialSnapshotData.ProtoReflect.Descriptor instead.
 func (*InitialSnapshotData) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{3}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *InitialSnapshotData) GetModuleName() string {
@@ -458,34 +813,36 @@ func (x *InitialSnapshotData) GetTotalKeys() uint64 {
 	return 0
 }
 
-type BlockScopedData struct {
+// BlockUndoSignal is sent when a fork is detected and the blocks following
+// `last_valid_block` must be reverted by the client. Stores on the server side
+// have already been rewound to `last_valid_block`.
+type BlockUndoSignal struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Outputs []*ModuleOutput `protobuf:"bytes,1,rep,name=outputs,proto3" json:"outputs,omitempty"`
-	Clock   *Clock          `protobuf:"bytes,3,opt,name=clock,proto3" json:"clock,omitempty"`
-	Step    ForkStep        `protobuf:"varint,6,opt,name=step,proto3,enum=sf.substreams.v1.ForkStep" json:"step,omitempty"`
-	Cursor  string          `protobuf:"bytes,10,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	LastValidBlock *BlockRef `protobuf:"bytes,1,opt,name=last_valid_block,json=lastValidBlock,proto3" json:"last_valid_block,omitempty"`
+	// LastValidCursor can be used to resume the stream right after `last_valid_block`.
+	LastValidCursor string `protobuf:"bytes,2,opt,name=last_valid_cursor,json=lastValidCursor,proto3" json:"last_valid_cursor,omitempty"`
 }
 
-func (x *BlockScopedData) Reset() {
-	*x = BlockScopedData{}
+func (x *BlockUndoSignal) Reset() {
+	*x = BlockUndoSignal{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[4]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *BlockScopedData) String() string {
+func (x *BlockUndoSignal) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BlockScopedData) ProtoMessage() {}
+func (*BlockUndoSignal) ProtoMessage() {}
 
-func (x *BlockScopedData) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[4]
+func (x *BlockUndoSignal) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -496,72 +853,902 @@ func (x *BlockScopedData) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BlockScopedData.ProtoReflect.Descriptor instead.
-func (*BlockScopedData) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use BlockUndoSignal.ProtoReflect.Descriptor instead.
+func (*BlockUndoSignal) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *BlockScopedData) GetOutputs() []*ModuleOutput {
+func (x *BlockUndoSignal) GetLastValidBlock() *BlockRef {
 	if x != nil {
-		return x.Outputs
+		return x.LastValidBlock
 	}
 	return nil
 }
 
-func (x *BlockScopedData) GetClock() *Clock {
+func (x *BlockUndoSignal) GetLastValidCursor() string {
 	if x != nil {
-		return x.Clock
+		return x.LastValidCursor
 	}
-	return nil
+	return ""
 }
 
-func (x *BlockScopedData) GetStep() ForkStep {
-	if x != nil {
-		return x.Step
+// DebugStoreSnapshot holds a chunk of the content of a store at a given
+// block, as requested through `Request.debug_store_snapshot`.
+type DebugStoreSnapshot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ModuleName string           `protobuf:"bytes,1,opt,name=module_name,json=moduleName,proto3" json:"module_name,omitempty"`
+	Clock      *Clock           `protobuf:"bytes,2,opt,name=clock,proto3" json:"clock,omitempty"`
+	Entries    []*StoreKeyValue `protobuf:"bytes,3,rep,name=entries,proto3" json:"entries,omitempty"`
+	SentKeys   uint64           `protobuf:"varint,4,opt,name=sent_keys,json=sentKeys,proto3" json:"sent_keys,omitempty"`
+	TotalKeys  uint64           `protobuf:"varint,5,opt,name=total_keys,json=totalKeys,proto3" json:"total_keys,omitempty"`
+	// Truncated is set on the last chunk of a store when its content was cut
+	// by the keys or bytes limit.
+	Truncated bool `protobuf:"varint,6,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	// Complete is set on the last chunk sent for the store.
+	Complete bool `protobuf:"varint,7,opt,name=complete,proto3" json:"complete,omitempty"`
+}
+
+func (x *DebugStoreSnapshot) Reset() {
+	*x = DebugStoreSnapshot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugStoreSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugStoreSnapshot) ProtoMessage() {}
+
+func (x *DebugStoreSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugStoreSnapshot.ProtoReflect.Descriptor instead.
+func (*DebugStoreSnapshot) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DebugStoreSnapshot) GetModuleName() string {
+	if x != nil {
+		return x.ModuleName
+	}
+	return ""
+}
+
+func (x *DebugStoreSnapshot) GetClock() *Clock {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+func (x *DebugStoreSnapshot) GetEntries() []*StoreKeyValue {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *DebugStoreSnapshot) GetSentKeys() uint64 {
+	if x != nil {
+		return x.SentKeys
+	}
+	return 0
+}
+
+func (x *DebugStoreSnapshot) GetTotalKeys() uint64 {
+	if x != nil {
+		return x.TotalKeys
+	}
+	return 0
+}
+
+func (x *DebugStoreSnapshot) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *DebugStoreSnapshot) GetComplete() bool {
+	if x != nil {
+		return x.Complete
+	}
+	return false
+}
+
+// SessionInit is sent once at the start of the stream, before anything else.
+type SessionInit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ModuleExecutionOrder lists the modules executed for each block, in the
+	// order they are executed.
+	ModuleExecutionOrder []string `protobuf:"bytes,1,rep,name=module_execution_order,json=moduleExecutionOrder,proto3" json:"module_execution_order,omitempty"`
+	// InitialBlockOverrides lists the modules started at another block than
+	// their declared initial block, from `Request.initial_block_overrides`.
+	// Their outputs and stores, and the ones of the modules depending on them,
+	// are cached apart from the ones of the declared initial blocks.
+	InitialBlockOverrides []*InitialBlockOverride `protobuf:"bytes,2,rep,name=initial_block_overrides,json=initialBlockOverrides,proto3" json:"initial_block_overrides,omitempty"`
+	// Network of the server, ex: "mainnet", empty when it doesn't declare one.
+	Network string `protobuf:"bytes,3,opt,name=network,proto3" json:"network,omitempty"`
+}
+
+func (x *SessionInit) Reset() {
+	*x = SessionInit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SessionInit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionInit) ProtoMessage() {}
+
+func (x *SessionInit) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionInit.ProtoReflect.Descriptor instead.
+func (*SessionInit) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SessionInit) GetModuleExecutionOrder() []string {
+	if x != nil {
+		return x.ModuleExecutionOrder
+	}
+	return nil
+}
+
+func (x *SessionInit) GetInitialBlockOverrides() []*InitialBlockOverride {
+	if x != nil {
+		return x.InitialBlockOverrides
+	}
+	return nil
+}
+
+func (x *SessionInit) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+type InitialBlockOverride struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ModuleName           string `protobuf:"bytes,1,opt,name=module_name,json=moduleName,proto3" json:"module_name,omitempty"`
+	DeclaredInitialBlock uint64 `protobuf:"varint,2,opt,name=declared_initial_block,json=declaredInitialBlock,proto3" json:"declared_initial_block,omitempty"`
+	InitialBlock         uint64 `protobuf:"varint,3,opt,name=initial_block,json=initialBlock,proto3" json:"initial_block,omitempty"`
+}
+
+func (x *InitialBlockOverride) Reset() {
+	*x = InitialBlockOverride{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InitialBlockOverride) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InitialBlockOverride) ProtoMessage() {}
+
+func (x *InitialBlockOverride) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InitialBlockOverride.ProtoReflect.Descriptor instead.
+func (*InitialBlockOverride) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *InitialBlockOverride) GetModuleName() string {
+	if x != nil {
+		return x.ModuleName
+	}
+	return ""
+}
+
+func (x *InitialBlockOverride) GetDeclaredInitialBlock() uint64 {
+	if x != nil {
+		return x.DeclaredInitialBlock
+	}
+	return 0
+}
+
+func (x *InitialBlockOverride) GetInitialBlock() uint64 {
+	if x != nil {
+		return x.InitialBlock
+	}
+	return 0
+}
+
+// PipelineStats is sent periodically on the stream, whether blocks flow or
+// not, so clients can tell a live server from a dead one during long ranges
+// without any data. Counters are cumulative since the start of the request.
+type PipelineStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// CurrentBlock is the last block processed, 0 before the first one.
+	CurrentBlock    uint64 `protobuf:"varint,1,opt,name=current_block,json=currentBlock,proto3" json:"current_block,omitempty"`
+	BlocksProcessed uint64 `protobuf:"varint,2,opt,name=blocks_processed,json=blocksProcessed,proto3" json:"blocks_processed,omitempty"`
+	// CacheHitRatio is the ratio of module executions served from the output
+	// caches, between 0 and 1.
+	CacheHitRatio float64        `protobuf:"fixed64,3,opt,name=cache_hit_ratio,json=cacheHitRatio,proto3" json:"cache_hit_ratio,omitempty"`
+	Modules       []*ModuleStats `protobuf:"bytes,4,rep,name=modules,proto3" json:"modules,omitempty"`
+	// BytesSent is the size of the messages sent on the stream so far, this
+	// one excluded.
+	BytesSent uint64 `protobuf:"varint,5,opt,name=bytes_sent,json=bytesSent,proto3" json:"bytes_sent,omitempty"`
+}
+
+func (x *PipelineStats) Reset() {
+	*x = PipelineStats{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PipelineStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipelineStats) ProtoMessage() {}
+
+func (x *PipelineStats) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipelineStats.ProtoReflect.Descriptor instead.
+func (*PipelineStats) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PipelineStats) GetCurrentBlock() uint64 {
+	if x != nil {
+		return x.CurrentBlock
+	}
+	return 0
+}
+
+func (x *PipelineStats) GetBlocksProcessed() uint64 {
+	if x != nil {
+		return x.BlocksProcessed
+	}
+	return 0
+}
+
+func (x *PipelineStats) GetCacheHitRatio() float64 {
+	if x != nil {
+		return x.CacheHitRatio
+	}
+	return 0
+}
+
+func (x *PipelineStats) GetModules() []*ModuleStats {
+	if x != nil {
+		return x.Modules
+	}
+	return nil
+}
+
+func (x *PipelineStats) GetBytesSent() uint64 {
+	if x != nil {
+		return x.BytesSent
+	}
+	return 0
+}
+
+type ModuleStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// ExecutionTimeMs is the time spent executing the module, outputs served
+	// from the caches included.
+	ExecutionTimeMs uint64 `protobuf:"varint,2,opt,name=execution_time_ms,json=executionTimeMs,proto3" json:"execution_time_ms,omitempty"`
+}
+
+func (x *ModuleStats) Reset() {
+	*x = ModuleStats{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ModuleStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModuleStats) ProtoMessage() {}
+
+func (x *ModuleStats) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModuleStats.ProtoReflect.Descriptor instead.
+func (*ModuleStats) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ModuleStats) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ModuleStats) GetExecutionTimeMs() uint64 {
+	if x != nil {
+		return x.ExecutionTimeMs
+	}
+	return 0
+}
+
+type StoreKeyValue struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *StoreKeyValue) Reset() {
+	*x = StoreKeyValue{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreKeyValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreKeyValue) ProtoMessage() {}
+
+func (x *StoreKeyValue) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreKeyValue.ProtoReflect.Descriptor instead.
+func (*StoreKeyValue) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *StoreKeyValue) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *StoreKeyValue) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type BlockRef struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Number uint64 `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+}
+
+func (x *BlockRef) Reset() {
+	*x = BlockRef{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlockRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockRef) ProtoMessage() {}
+
+func (x *BlockRef) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockRef.ProtoReflect.Descriptor instead.
+func (*BlockRef) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *BlockRef) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BlockRef) GetNumber() uint64 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+type BlockScopedData struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Outputs []*ModuleOutput `protobuf:"bytes,1,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	Clock   *Clock          `protobuf:"bytes,3,opt,name=clock,proto3" json:"clock,omitempty"`
+	Step    ForkStep        `protobuf:"varint,6,opt,name=step,proto3,enum=sf.substreams.v1.ForkStep" json:"step,omitempty"`
+	Cursor  string          `protobuf:"bytes,10,opt,name=cursor,proto3" json:"cursor,omitempty"`
+}
+
+func (x *BlockScopedData) Reset() {
+	*x = BlockScopedData{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlockScopedData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockScopedData) ProtoMessage() {}
+
+func (x *BlockScopedData) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockScopedData.ProtoReflect.Descriptor instead.
+func (*BlockScopedData) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *BlockScopedData) GetOutputs() []*ModuleOutput {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+func (x *BlockScopedData) GetClock() *Clock {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+func (x *BlockScopedData) GetStep() ForkStep {
+	if x != nil {
+		return x.Step
+	}
+	return ForkStep_STEP_UNKNOWN
+}
+
+func (x *BlockScopedData) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type ModuleOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Types that are assignable to Data:
+	//	*ModuleOutput_MapOutput
+	//	*ModuleOutput_StoreDeltas
+	//	*ModuleOutput_StoreFullKv
+	Data isModuleOutput_Data `protobuf_oneof:"data"`
+	Logs []string            `protobuf:"bytes,4,rep,name=logs,proto3" json:"logs,omitempty"`
+	// LogsTruncated is a flag that tells you if you received all the logs or if they
+	// were truncated because you logged too much (128 KiB by default, see
+	// `Request.max_log_byte_count`).
+	LogsTruncated bool `protobuf:"varint,5,opt,name=logs_truncated,json=logsTruncated,proto3" json:"logs_truncated,omitempty"`
+	// OutputTruncated is set when the output of the module went over the server
+	// output size limit and was replaced by an empty one, see
+	// `Request.truncate_oversized_outputs`.
+	OutputTruncated bool `protobuf:"varint,6,opt,name=output_truncated,json=outputTruncated,proto3" json:"output_truncated,omitempty"`
+	// LogLevels holds the level of each of the logs, in the same order.
+	LogLevels []LogLevel `protobuf:"varint,9,rep,packed,name=log_levels,json=logLevels,proto3,enum=sf.substreams.v1.LogLevel" json:"log_levels,omitempty"`
+	// DroppedLogs and DroppedLogsBytes count the logs dropped when the logs were
+	// truncated.
+	DroppedLogs      uint64 `protobuf:"varint,10,opt,name=dropped_logs,json=droppedLogs,proto3" json:"dropped_logs,omitempty"`
+	DroppedLogsBytes uint64 `protobuf:"varint,11,opt,name=dropped_logs_bytes,json=droppedLogsBytes,proto3" json:"dropped_logs_bytes,omitempty"`
+}
+
+func (x *ModuleOutput) Reset() {
+	*x = ModuleOutput{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ModuleOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModuleOutput) ProtoMessage() {}
+
+func (x *ModuleOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModuleOutput.ProtoReflect.Descriptor instead.
+func (*ModuleOutput) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ModuleOutput) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (m *ModuleOutput) GetData() isModuleOutput_Data {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (x *ModuleOutput) GetMapOutput() *anypb.Any {
+	if x, ok := x.GetData().(*ModuleOutput_MapOutput); ok {
+		return x.MapOutput
+	}
+	return nil
+}
+
+func (x *ModuleOutput) GetStoreDeltas() *StoreDeltas {
+	if x, ok := x.GetData().(*ModuleOutput_StoreDeltas); ok {
+		return x.StoreDeltas
+	}
+	return nil
+}
+
+func (x *ModuleOutput) GetStoreFullKv() *StoreFullKV {
+	if x, ok := x.GetData().(*ModuleOutput_StoreFullKv); ok {
+		return x.StoreFullKv
+	}
+	return nil
+}
+
+func (x *ModuleOutput) GetLogs() []string {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *ModuleOutput) GetLogsTruncated() bool {
+	if x != nil {
+		return x.LogsTruncated
+	}
+	return false
+}
+
+func (x *ModuleOutput) GetOutputTruncated() bool {
+	if x != nil {
+		return x.OutputTruncated
+	}
+	return false
+}
+
+func (x *ModuleOutput) GetLogLevels() []LogLevel {
+	if x != nil {
+		return x.LogLevels
+	}
+	return nil
+}
+
+func (x *ModuleOutput) GetDroppedLogs() uint64 {
+	if x != nil {
+		return x.DroppedLogs
+	}
+	return 0
+}
+
+func (x *ModuleOutput) GetDroppedLogsBytes() uint64 {
+	if x != nil {
+		return x.DroppedLogsBytes
+	}
+	return 0
+}
+
+type isModuleOutput_Data interface {
+	isModuleOutput_Data()
+}
+
+type ModuleOutput_MapOutput struct {
+	MapOutput *anypb.Any `protobuf:"bytes,2,opt,name=map_output,json=mapOutput,proto3,oneof"`
+}
+
+type ModuleOutput_StoreDeltas struct {
+	StoreDeltas *StoreDeltas `protobuf:"bytes,3,opt,name=store_deltas,json=storeDeltas,proto3,oneof"`
+}
+
+type ModuleOutput_StoreFullKv struct {
+	StoreFullKv *StoreFullKV `protobuf:"bytes,8,opt,name=store_full_kv,json=storeFullKv,proto3,oneof"`
+}
+
+func (*ModuleOutput_MapOutput) isModuleOutput_Data() {}
+
+func (*ModuleOutput_StoreDeltas) isModuleOutput_Data() {}
+
+func (*ModuleOutput_StoreFullKv) isModuleOutput_Data() {}
+
+// StoreFullKV is the content of a store after a block, see
+// `Request.full_kv_store_outputs`.
+type StoreFullKV struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Entries []*StoreKeyValue `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+}
+
+func (x *StoreFullKV) Reset() {
+	*x = StoreFullKV{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreFullKV) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreFullKV) ProtoMessage() {}
+
+func (x *StoreFullKV) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreFullKV.ProtoReflect.Descriptor instead.
+func (*StoreFullKV) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *StoreFullKV) GetEntries() []*StoreKeyValue {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type ModuleFailure struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Reason is the error of the module, including its execution stack.
+	Reason string `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	// QuarantinedModules lists the failed module and the modules depending on
+	// it, none of them is executed anymore.
+	QuarantinedModules []string `protobuf:"bytes,2,rep,name=quarantined_modules,json=quarantinedModules,proto3" json:"quarantined_modules,omitempty"`
+	ModuleName         string   `protobuf:"bytes,3,opt,name=module_name,json=moduleName,proto3" json:"module_name,omitempty"`
+	// Clock of the block the module failed on.
+	Clock *Clock `protobuf:"bytes,4,opt,name=clock,proto3" json:"clock,omitempty"`
+}
+
+func (x *ModuleFailure) Reset() {
+	*x = ModuleFailure{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ModuleFailure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModuleFailure) ProtoMessage() {}
+
+func (x *ModuleFailure) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModuleFailure.ProtoReflect.Descriptor instead.
+func (*ModuleFailure) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ModuleFailure) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ModuleFailure) GetQuarantinedModules() []string {
+	if x != nil {
+		return x.QuarantinedModules
+	}
+	return nil
+}
+
+func (x *ModuleFailure) GetModuleName() string {
+	if x != nil {
+		return x.ModuleName
 	}
-	return ForkStep_STEP_UNKNOWN
+	return ""
 }
 
-func (x *BlockScopedData) GetCursor() string {
+func (x *ModuleFailure) GetClock() *Clock {
 	if x != nil {
-		return x.Cursor
+		return x.Clock
 	}
-	return ""
+	return nil
 }
 
-type ModuleOutput struct {
+// PanicDetail is attached to the details of the gRPC status of a stream
+// failing because a module panicked.
+type PanicDetail struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	// Types that are assignable to Data:
-	//	*ModuleOutput_MapOutput
-	//	*ModuleOutput_StoreDeltas
-	Data isModuleOutput_Data `protobuf_oneof:"data"`
-	Logs []string            `protobuf:"bytes,4,rep,name=logs,proto3" json:"logs,omitempty"`
-	// LogsTruncated is a flag that tells you if you received all the logs or if they
-	// were truncated because you logged too much (fixed limit currently is set to 128 KiB).
-	LogsTruncated bool `protobuf:"varint,5,opt,name=logs_truncated,json=logsTruncated,proto3" json:"logs_truncated,omitempty"`
+	Module   string `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	BlockNum uint64 `protobuf:"varint,2,opt,name=block_num,json=blockNum,proto3" json:"block_num,omitempty"`
+	Message  string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// Filename, LineNumber and ColumnNumber locate the panic in the code of the
+	// module, they are empty when the module did not report them.
+	Filename       string   `protobuf:"bytes,4,opt,name=filename,proto3" json:"filename,omitempty"`
+	LineNumber     uint32   `protobuf:"varint,5,opt,name=line_number,json=lineNumber,proto3" json:"line_number,omitempty"`
+	ColumnNumber   uint32   `protobuf:"varint,6,opt,name=column_number,json=columnNumber,proto3" json:"column_number,omitempty"`
+	ExecutionStack []string `protobuf:"bytes,7,rep,name=execution_stack,json=executionStack,proto3" json:"execution_stack,omitempty"`
 }
 
-func (x *ModuleOutput) Reset() {
-	*x = ModuleOutput{}
+func (x *PanicDetail) Reset() {
+	*x = PanicDetail{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[5]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[17]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ModuleOutput) String() string {
+func (x *PanicDetail) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ModuleOutput) ProtoMessage() {}
+func (*PanicDetail) ProtoMessage() {}
 
-func (x *ModuleOutput) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[5]
+func (x *PanicDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[17]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -572,69 +1759,60 @@ func (x *ModuleOutput) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ModuleOutput.ProtoReflect.Descriptor instead.
-func (*ModuleOutput) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use PanicDetail.ProtoReflect.Descriptor instead.
+func (*PanicDetail) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *ModuleOutput) GetName() string {
+func (x *PanicDetail) GetModule() string {
 	if x != nil {
-		return x.Name
+		return x.Module
 	}
 	return ""
 }
 
-func (m *ModuleOutput) GetData() isModuleOutput_Data {
-	if m != nil {
-		return m.Data
+func (x *PanicDetail) GetBlockNum() uint64 {
+	if x != nil {
+		return x.BlockNum
 	}
-	return nil
+	return 0
 }
 
-func (x *ModuleOutput) GetMapOutput() *anypb.Any {
-	if x, ok := x.GetData().(*ModuleOutput_MapOutput); ok {
-		return x.MapOutput
+func (x *PanicDetail) GetMessage() string {
+	if x != nil {
+		return x.Message
 	}
-	return nil
+	return ""
 }
 
-func (x *ModuleOutput) GetStoreDeltas() *StoreDeltas {
-	if x, ok := x.GetData().(*ModuleOutput_StoreDeltas); ok {
-		return x.StoreDeltas
+func (x *PanicDetail) GetFilename() string {
+	if x != nil {
+		return x.Filename
 	}
-	return nil
+	return ""
 }
 
-func (x *ModuleOutput) GetLogs() []string {
+func (x *PanicDetail) GetLineNumber() uint32 {
 	if x != nil {
-		return x.Logs
+		return x.LineNumber
 	}
-	return nil
+	return 0
 }
 
-func (x *ModuleOutput) GetLogsTruncated() bool {
+func (x *PanicDetail) GetColumnNumber() uint32 {
 	if x != nil {
-		return x.LogsTruncated
+		return x.ColumnNumber
 	}
-	return false
-}
-
-type isModuleOutput_Data interface {
-	isModuleOutput_Data()
-}
-
-type ModuleOutput_MapOutput struct {
-	MapOutput *anypb.Any `protobuf:"bytes,2,opt,name=map_output,json=mapOutput,proto3,oneof"`
+	return 0
 }
 
-type ModuleOutput_StoreDeltas struct {
-	StoreDeltas *StoreDeltas `protobuf:"bytes,3,opt,name=store_deltas,json=storeDeltas,proto3,oneof"`
+func (x *PanicDetail) GetExecutionStack() []string {
+	if x != nil {
+		return x.ExecutionStack
+	}
+	return nil
 }
 
-func (*ModuleOutput_MapOutput) isModuleOutput_Data() {}
-
-func (*ModuleOutput_StoreDeltas) isModuleOutput_Data() {}
-
 type ModulesProgress struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -646,7 +1824,7 @@ type ModulesProgress struct {
 func (x *ModulesProgress) Reset() {
 	*x = ModulesProgress{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[6]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[18]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -659,7 +1837,7 @@ func (x *ModulesProgress) String() string {
 func (*ModulesProgress) ProtoMessage() {}
 
 func (x *ModulesProgress) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[6]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[18]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -672,7 +1850,7 @@ func (x *ModulesProgress) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ModulesProgress.ProtoReflect.Descriptor instead.
 func (*ModulesProgress) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{6}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ModulesProgress) GetModules() []*ModuleProgress {
@@ -699,7 +1877,7 @@ type ModuleProgress struct {
 func (x *ModuleProgress) Reset() {
 	*x = ModuleProgress{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[7]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[19]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -712,7 +1890,7 @@ func (x *ModuleProgress) String() string {
 func (*ModuleProgress) ProtoMessage() {}
 
 func (x *ModuleProgress) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[7]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[19]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -725,7 +1903,7 @@ func (x *ModuleProgress) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ModuleProgress.ProtoReflect.Descriptor instead.
 func (*ModuleProgress) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{7}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *ModuleProgress) GetName() string {
@@ -810,7 +1988,7 @@ type BlockRange struct {
 func (x *BlockRange) Reset() {
 	*x = BlockRange{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[8]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[20]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -823,7 +2001,7 @@ func (x *BlockRange) String() string {
 func (*BlockRange) ProtoMessage() {}
 
 func (x *BlockRange) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[8]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[20]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -836,7 +2014,7 @@ func (x *BlockRange) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BlockRange.ProtoReflect.Descriptor instead.
 func (*BlockRange) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{8}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *BlockRange) GetStartBlock() uint64 {
@@ -864,7 +2042,7 @@ type StoreDeltas struct {
 func (x *StoreDeltas) Reset() {
 	*x = StoreDeltas{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[9]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[21]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -877,7 +2055,7 @@ func (x *StoreDeltas) String() string {
 func (*StoreDeltas) ProtoMessage() {}
 
 func (x *StoreDeltas) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[9]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[21]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -890,7 +2068,7 @@ func (x *StoreDeltas) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StoreDeltas.ProtoReflect.Descriptor instead.
 func (*StoreDeltas) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{9}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *StoreDeltas) GetDeltas() []*StoreDelta {
@@ -915,7 +2093,7 @@ type StoreDelta struct {
 func (x *StoreDelta) Reset() {
 	*x = StoreDelta{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[10]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[22]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -928,7 +2106,7 @@ func (x *StoreDelta) String() string {
 func (*StoreDelta) ProtoMessage() {}
 
 func (x *StoreDelta) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[10]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[22]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -941,7 +2119,7 @@ func (x *StoreDelta) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StoreDelta.ProtoReflect.Descriptor instead.
 func (*StoreDelta) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{10}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *StoreDelta) GetOperation() StoreDelta_Operation {
@@ -993,7 +2171,7 @@ type Output struct {
 func (x *Output) Reset() {
 	*x = Output{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[11]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[23]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1006,7 +2184,7 @@ func (x *Output) String() string {
 func (*Output) ProtoMessage() {}
 
 func (x *Output) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[11]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[23]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1019,7 +2197,7 @@ func (x *Output) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Output.ProtoReflect.Descriptor instead.
 func (*Output) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{11}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *Output) GetBlockNum() uint64 {
@@ -1061,7 +2239,7 @@ type ModuleProgress_ProcessedRange struct {
 func (x *ModuleProgress_ProcessedRange) Reset() {
 	*x = ModuleProgress_ProcessedRange{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[12]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[25]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1074,7 +2252,7 @@ func (x *ModuleProgress_ProcessedRange) String() string {
 func (*ModuleProgress_ProcessedRange) ProtoMessage() {}
 
 func (x *ModuleProgress_ProcessedRange) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[12]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[25]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1087,7 +2265,7 @@ func (x *ModuleProgress_ProcessedRange) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ModuleProgress_ProcessedRange.ProtoReflect.Descriptor instead.
 func (*ModuleProgress_ProcessedRange) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{7, 0}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{19, 0}
 }
 
 func (x *ModuleProgress_ProcessedRange) GetProcessedRanges() []*BlockRange {
@@ -1108,7 +2286,7 @@ type ModuleProgress_InitialState struct {
 func (x *ModuleProgress_InitialState) Reset() {
 	*x = ModuleProgress_InitialState{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[13]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[26]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1121,7 +2299,7 @@ func (x *ModuleProgress_InitialState) String() string {
 func (*ModuleProgress_InitialState) ProtoMessage() {}
 
 func (x *ModuleProgress_InitialState) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[13]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[26]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1134,7 +2312,7 @@ func (x *ModuleProgress_InitialState) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ModuleProgress_InitialState.ProtoReflect.Descriptor instead.
 func (*ModuleProgress_InitialState) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{7, 1}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{19, 1}
 }
 
 func (x *ModuleProgress_InitialState) GetAvailableUpToBlock() uint64 {
@@ -1156,7 +2334,7 @@ type ModuleProgress_ProcessedBytes struct {
 func (x *ModuleProgress_ProcessedBytes) Reset() {
 	*x = ModuleProgress_ProcessedBytes{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[14]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[27]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1169,7 +2347,7 @@ func (x *ModuleProgress_ProcessedBytes) String() string {
 func (*ModuleProgress_ProcessedBytes) ProtoMessage() {}
 
 func (x *ModuleProgress_ProcessedBytes) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[14]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[27]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1182,7 +2360,7 @@ func (x *ModuleProgress_ProcessedBytes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ModuleProgress_ProcessedBytes.ProtoReflect.Descriptor instead.
 func (*ModuleProgress_ProcessedBytes) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{7, 2}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{19, 2}
 }
 
 func (x *ModuleProgress_ProcessedBytes) GetTotalBytesRead() uint64 {
@@ -1214,7 +2392,7 @@ type ModuleProgress_Failed struct {
 func (x *ModuleProgress_Failed) Reset() {
 	*x = ModuleProgress_Failed{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[15]
+		mi := &file_sf_substreams_v1_substreams_proto_msgTypes[28]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1227,7 +2405,7 @@ func (x *ModuleProgress_Failed) String() string {
 func (*ModuleProgress_Failed) ProtoMessage() {}
 
 func (x *ModuleProgress_Failed) ProtoReflect() protoreflect.Message {
-	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[15]
+	mi := &file_sf_substreams_v1_substreams_proto_msgTypes[28]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1240,7 +2418,7 @@ func (x *ModuleProgress_Failed) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ModuleProgress_Failed.ProtoReflect.Descriptor instead.
 func (*ModuleProgress_Failed) Descriptor() ([]byte, []int) {
-	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{7, 3}
+	return file_sf_substreams_v1_substreams_proto_rawDescGZIP(), []int{19, 3}
 }
 
 func (x *ModuleProgress_Failed) GetReason() string {
@@ -1278,7 +2456,7 @@ var file_sf_substreams_v1_substreams_proto_rawDesc = []byte{
 	0x2f, 0x76, 0x31, 0x2f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74,
 	0x6f, 0x1a, 0x1c, 0x73, 0x66, 0x2f, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73,
 	0x2f, 0x76, 0x31, 0x2f, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
-	0x9a, 0x03, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x73,
+	0xed, 0x09, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x73,
 	0x74, 0x61, 0x72, 0x74, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01,
 	0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x73, 0x74, 0x61, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b,
 	0x4e, 0x75, 0x6d, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x63, 0x75, 0x72,
@@ -1303,170 +2481,380 @@ var file_sf_substreams_v1_substreams_proto_rawDesc = []byte{
 	0x65, 0x5f, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x66, 0x6f, 0x72, 0x5f, 0x6d,
 	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x1e, 0x69, 0x6e,
 	0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68,
-	0x6f, 0x74, 0x46, 0x6f, 0x72, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x22, 0xb7, 0x02, 0x0a,
-	0x08, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x08, 0x70, 0x72, 0x6f,
-	0x67, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x73, 0x66,
+	0x6f, 0x74, 0x46, 0x6f, 0x72, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x29, 0x0a, 0x10,
+	0x64, 0x65, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x65,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f, 0x64, 0x65, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x6d,
+	0x65, 0x6e, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x5d, 0x0a, 0x14, 0x64, 0x65, 0x62, 0x75, 0x67,
+	0x5f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x62, 0x75, 0x67, 0x53, 0x74,
+	0x6f, 0x72, 0x65, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x52, 0x12, 0x64, 0x65, 0x62, 0x75, 0x67, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x53, 0x6e,
+	0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x3c, 0x0a, 0x1a, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61,
+	0x74, 0x65, 0x5f, 0x6f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x7a, 0x65, 0x64, 0x5f, 0x6f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x18, 0x74, 0x72, 0x75, 0x6e,
+	0x63, 0x61, 0x74, 0x65, 0x4f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x7a, 0x65, 0x64, 0x4f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x73, 0x12, 0x3a, 0x0a, 0x19, 0x71, 0x75, 0x61, 0x72, 0x61, 0x6e, 0x74, 0x69,
+	0x6e, 0x65, 0x5f, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x5f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65,
+	0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x17, 0x71, 0x75, 0x61, 0x72, 0x61, 0x6e, 0x74,
+	0x69, 0x6e, 0x65, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73,
+	0x12, 0x2a, 0x0a, 0x11, 0x66, 0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73,
+	0x5f, 0x6f, 0x6e, 0x6c, 0x79, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f, 0x66, 0x69, 0x6e,
+	0x61, 0x6c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x4f, 0x6e, 0x6c, 0x79, 0x12, 0x31, 0x0a, 0x15,
+	0x66, 0x75, 0x6c, 0x6c, 0x5f, 0x6b, 0x76, 0x5f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x6f, 0x75,
+	0x74, 0x70, 0x75, 0x74, 0x73, 0x18, 0x0e, 0x20, 0x03, 0x28, 0x09, 0x52, 0x12, 0x66, 0x75, 0x6c,
+	0x6c, 0x4b, 0x76, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x12,
+	0x21, 0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x4e, 0x61,
+	0x6d, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x5f, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x10, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x70, 0x61, 0x63,
+	0x6b, 0x61, 0x67, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x3e, 0x0a, 0x0d, 0x6d,
+	0x69, 0x6e, 0x5f, 0x6c, 0x6f, 0x67, 0x5f, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x18, 0x11, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x67, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x52, 0x0b,
+	0x6d, 0x69, 0x6e, 0x4c, 0x6f, 0x67, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x2b, 0x0a, 0x12, 0x6d,
+	0x61, 0x78, 0x5f, 0x6c, 0x6f, 0x67, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x18, 0x12, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x6d, 0x61, 0x78, 0x4c, 0x6f, 0x67, 0x42,
+	0x79, 0x74, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x6c, 0x0a, 0x17, 0x69, 0x6e, 0x69, 0x74,
+	0x69, 0x61, 0x6c, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x6f, 0x76, 0x65, 0x72, 0x72, 0x69,
+	0x64, 0x65, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x34, 0x2e, 0x73, 0x66, 0x2e, 0x73,
+	0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x2e, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x42, 0x6c, 0x6f, 0x63,
+	0x6b, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x15, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4f, 0x76, 0x65,
+	0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72,
+	0x6b, 0x18, 0x14, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b,
+	0x12, 0x34, 0x0a, 0x16, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x5f, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72,
+	0x6b, 0x5f, 0x6d, 0x69, 0x73, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x18, 0x15, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x14, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x4e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x4d, 0x69,
+	0x73, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x2d, 0x0a, 0x12, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x16, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x11, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x56, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x1a, 0x48, 0x0a, 0x1a, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22,
+	0x95, 0x01, 0x0a, 0x19, 0x44, 0x65, 0x62, 0x75, 0x67, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x53, 0x6e,
+	0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x23, 0x0a,
+	0x0d, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x61, 0x74, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x61, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1d, 0x0a,
+	0x0a, 0x6b, 0x65, 0x79, 0x5f, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x6b, 0x65, 0x79, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x19, 0x0a, 0x08,
+	0x6d, 0x61, 0x78, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07,
+	0x6d, 0x61, 0x78, 0x4b, 0x65, 0x79, 0x73, 0x22, 0x9e, 0x05, 0x0a, 0x08, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65,
+	0x73, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x48, 0x00, 0x52, 0x08, 0x70, 0x72, 0x6f,
+	0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x4c, 0x0a, 0x0d, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f,
+	0x74, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x73,
+	0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x44,
+	0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0c, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x44,
+	0x61, 0x74, 0x61, 0x12, 0x58, 0x0a, 0x11, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f,
+	0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x29,
+	0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f,
+	0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x48, 0x00, 0x52, 0x10, 0x73, 0x6e, 0x61,
+	0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x37, 0x0a,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x73, 0x66,
+	0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42,
+	0x6c, 0x6f, 0x63, 0x6b, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x64, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00,
+	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x44, 0x0a, 0x0b, 0x75, 0x6e, 0x64, 0x6f, 0x5f, 0x73,
+	0x69, 0x67, 0x6e, 0x61, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x73, 0x66,
+	0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42,
+	0x6c, 0x6f, 0x63, 0x6b, 0x55, 0x6e, 0x64, 0x6f, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x48, 0x00,
+	0x52, 0x0a, 0x75, 0x6e, 0x64, 0x6f, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x12, 0x58, 0x0a, 0x14,
+	0x64, 0x65, 0x62, 0x75, 0x67, 0x5f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x73, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x73, 0x66, 0x2e,
+	0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65,
+	0x62, 0x75, 0x67, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74,
+	0x48, 0x00, 0x52, 0x12, 0x64, 0x65, 0x62, 0x75, 0x67, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x53, 0x6e,
+	0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x37, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x69, 0x70, 0x65, 0x6c, 0x69, 0x6e,
+	0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x48, 0x00, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x12,
+	0x42, 0x0a, 0x0c, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x6e, 0x69, 0x74, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x49, 0x6e, 0x69, 0x74, 0x48, 0x00, 0x52, 0x0b, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49,
+	0x6e, 0x69, 0x74, 0x12, 0x48, 0x0a, 0x0e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x66, 0x61,
+	0x69, 0x6c, 0x75, 0x72, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x66,
 	0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
-	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x48, 0x00,
-	0x52, 0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x4c, 0x0a, 0x0d, 0x73, 0x6e,
-	0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x25, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
-	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x6e, 0x61, 0x70,
-	0x73, 0x68, 0x6f, 0x74, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0c, 0x73, 0x6e, 0x61, 0x70,
-	0x73, 0x68, 0x6f, 0x74, 0x44, 0x61, 0x74, 0x61, 0x12, 0x58, 0x0a, 0x11, 0x73, 0x6e, 0x61, 0x70,
-	0x73, 0x68, 0x6f, 0x74, 0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x6e,
-	0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x48, 0x00,
-	0x52, 0x10, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65,
-	0x74, 0x65, 0x12, 0x37, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x21, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73,
-	0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x64, 0x44,
-	0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x42, 0x09, 0x0a, 0x07, 0x6d,
-	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x31, 0x0a, 0x17, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61,
-	0x6c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74,
-	0x65, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0xa9, 0x01, 0x0a, 0x13, 0x49, 0x6e,
-	0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x44, 0x61, 0x74,
-	0x61, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4e, 0x61,
-	0x6d, 0x65, 0x12, 0x35, 0x0a, 0x06, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
-	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61,
-	0x73, 0x52, 0x06, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x6e,
-	0x74, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65,
-	0x6e, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f,
-	0x6b, 0x65, 0x79, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x74, 0x6f, 0x74, 0x61,
-	0x6c, 0x4b, 0x65, 0x79, 0x73, 0x22, 0xc2, 0x01, 0x0a, 0x0f, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53,
-	0x63, 0x6f, 0x70, 0x65, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x38, 0x0a, 0x07, 0x6f, 0x75, 0x74,
-	0x70, 0x75, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x73, 0x66, 0x2e,
-	0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f,
-	0x64, 0x75, 0x6c, 0x65, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x52, 0x07, 0x6f, 0x75, 0x74, 0x70,
-	0x75, 0x74, 0x73, 0x12, 0x2d, 0x0a, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
-	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x05, 0x63, 0x6c, 0x6f,
-	0x63, 0x6b, 0x12, 0x2e, 0x0a, 0x04, 0x73, 0x74, 0x65, 0x70, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e,
-	0x32, 0x1a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73,
-	0x2e, 0x76, 0x31, 0x2e, 0x46, 0x6f, 0x72, 0x6b, 0x53, 0x74, 0x65, 0x70, 0x52, 0x04, 0x73, 0x74,
-	0x65, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x0a, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0xe0, 0x01, 0x0a, 0x0c, 0x4d,
-	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e,
-	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
-	0x35, 0x0a, 0x0a, 0x6d, 0x61, 0x70, 0x5f, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x41, 0x6e, 0x79, 0x48, 0x00, 0x52, 0x09, 0x6d, 0x61, 0x70,
-	0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x42, 0x0a, 0x0c, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f,
-	0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x73,
+	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x48, 0x00, 0x52, 0x0d,
+	0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x42, 0x09, 0x0a,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x31, 0x0a, 0x17, 0x49, 0x6e, 0x69, 0x74,
+	0x69, 0x61, 0x6c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x6f, 0x6d, 0x70, 0x6c,
+	0x65, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0xa9, 0x01, 0x0a, 0x13,
+	0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x44,
+	0x61, 0x74, 0x61, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65,
+	0x4e, 0x61, 0x6d, 0x65, 0x12, 0x35, 0x0a, 0x06, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c,
+	0x74, 0x61, 0x73, 0x52, 0x06, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x73,
+	0x65, 0x6e, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08,
+	0x73, 0x65, 0x6e, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x74, 0x6f, 0x74, 0x61,
+	0x6c, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x74, 0x6f,
+	0x74, 0x61, 0x6c, 0x4b, 0x65, 0x79, 0x73, 0x22, 0x83, 0x01, 0x0a, 0x0f, 0x42, 0x6c, 0x6f, 0x63,
+	0x6b, 0x55, 0x6e, 0x64, 0x6f, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x12, 0x44, 0x0a, 0x10, 0x6c,
+	0x61, 0x73, 0x74, 0x5f, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x65,
+	0x66, 0x52, 0x0e, 0x6c, 0x61, 0x73, 0x74, 0x56, 0x61, 0x6c, 0x69, 0x64, 0x42, 0x6c, 0x6f, 0x63,
+	0x6b, 0x12, 0x2a, 0x0a, 0x11, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x5f,
+	0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x6c, 0x61,
+	0x73, 0x74, 0x56, 0x61, 0x6c, 0x69, 0x64, 0x43, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0x95, 0x02,
+	0x0a, 0x12, 0x44, 0x65, 0x62, 0x75, 0x67, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x53, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x2d, 0x0a, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x05, 0x63,
+	0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x39, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x4b, 0x65,
+	0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12,
+	0x1b, 0x0a, 0x09, 0x73, 0x65, 0x6e, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x6e, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x1d, 0x0a, 0x0a,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x09, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x74,
+	0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09,
+	0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6d,
+	0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x63, 0x6f, 0x6d,
+	0x70, 0x6c, 0x65, 0x74, 0x65, 0x22, 0xbd, 0x01, 0x0a, 0x0b, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x49, 0x6e, 0x69, 0x74, 0x12, 0x34, 0x0a, 0x16, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f,
+	0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x14, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x45, 0x78, 0x65,
+	0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x5e, 0x0a, 0x17, 0x69,
+	0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x6f, 0x76, 0x65,
+	0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x26, 0x2e, 0x73,
 	0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e,
-	0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x48, 0x00, 0x52, 0x0b, 0x73,
-	0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f,
-	0x67, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x25,
-	0x0a, 0x0e, 0x6c, 0x6f, 0x67, 0x73, 0x5f, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x6c, 0x6f, 0x67, 0x73, 0x54, 0x72, 0x75, 0x6e,
-	0x63, 0x61, 0x74, 0x65, 0x64, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x4d, 0x0a,
-	0x0f, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73,
-	0x12, 0x3a, 0x0a, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
-	0x0b, 0x32, 0x20, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
-	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72,
-	0x65, 0x73, 0x73, 0x52, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x22, 0xe6, 0x05, 0x0a,
-	0x0e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x12,
-	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
-	0x61, 0x6d, 0x65, 0x12, 0x5c, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64,
-	0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2f, 0x2e,
+	0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4f, 0x76, 0x65, 0x72,
+	0x72, 0x69, 0x64, 0x65, 0x52, 0x15, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x42, 0x6c, 0x6f,
+	0x63, 0x6b, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6e,
+	0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65,
+	0x74, 0x77, 0x6f, 0x72, 0x6b, 0x22, 0x92, 0x01, 0x0a, 0x14, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61,
+	0x6c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x12, 0x1f,
+	0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12,
+	0x34, 0x0a, 0x16, 0x64, 0x65, 0x63, 0x6c, 0x61, 0x72, 0x65, 0x64, 0x5f, 0x69, 0x6e, 0x69, 0x74,
+	0x69, 0x61, 0x6c, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x14, 0x64, 0x65, 0x63, 0x6c, 0x61, 0x72, 0x65, 0x64, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x23, 0x0a, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c,
+	0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x69, 0x6e,
+	0x69, 0x74, 0x69, 0x61, 0x6c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x22, 0xdf, 0x01, 0x0a, 0x0d, 0x50,
+	0x69, 0x70, 0x65, 0x6c, 0x69, 0x6e, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x23, 0x0a, 0x0d,
+	0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x0c, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x42, 0x6c, 0x6f, 0x63,
+	0x6b, 0x12, 0x29, 0x0a, 0x10, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x5f, 0x70, 0x72, 0x6f, 0x63,
+	0x65, 0x73, 0x73, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x62, 0x6c, 0x6f,
+	0x63, 0x6b, 0x73, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x12, 0x26, 0x0a, 0x0f,
+	0x63, 0x61, 0x63, 0x68, 0x65, 0x5f, 0x68, 0x69, 0x74, 0x5f, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0d, 0x63, 0x61, 0x63, 0x68, 0x65, 0x48, 0x69, 0x74, 0x52,
+	0x61, 0x74, 0x69, 0x6f, 0x12, 0x37, 0x0a, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18,
+	0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x73, 0x52, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x1d, 0x0a,
+	0x0a, 0x62, 0x79, 0x74, 0x65, 0x73, 0x5f, 0x73, 0x65, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x09, 0x62, 0x79, 0x74, 0x65, 0x73, 0x53, 0x65, 0x6e, 0x74, 0x22, 0x4d, 0x0a, 0x0b,
+	0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x2a, 0x0a, 0x11, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x5f, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x65, 0x78, 0x65, 0x63,
+	0x75, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x22, 0x37, 0x0a, 0x0d, 0x53,
+	0x74, 0x6f, 0x72, 0x65, 0x4b, 0x65, 0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x22, 0x32, 0x0a, 0x08, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x65, 0x66,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64,
+	0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0xc2, 0x01, 0x0a, 0x0f, 0x42, 0x6c, 0x6f,
+	0x63, 0x6b, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x38, 0x0a, 0x07,
+	0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e,
 	0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31,
-	0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x2e,
-	0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x48, 0x00,
-	0x52, 0x0f, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65,
-	0x73, 0x12, 0x54, 0x0a, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x73, 0x74, 0x61,
-	0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2d, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75,
-	0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75,
-	0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x2e, 0x49, 0x6e, 0x69, 0x74, 0x69,
-	0x61, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x69, 0x6e, 0x69, 0x74, 0x69,
-	0x61, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x5a, 0x0a, 0x0f, 0x70, 0x72, 0x6f, 0x63, 0x65,
-	0x73, 0x73, 0x65, 0x64, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x2f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73,
-	0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65,
-	0x73, 0x73, 0x2e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65,
-	0x73, 0x48, 0x00, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x42, 0x79,
-	0x74, 0x65, 0x73, 0x12, 0x41, 0x0a, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x18, 0x05, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f,
-	0x67, 0x72, 0x65, 0x73, 0x73, 0x2e, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x48, 0x00, 0x52, 0x06,
-	0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x1a, 0x59, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73,
-	0x73, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x47, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x63,
-	0x65, 0x73, 0x73, 0x65, 0x64, 0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
-	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x61, 0x6e, 0x67, 0x65,
-	0x52, 0x0f, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65,
-	0x73, 0x1a, 0x41, 0x0a, 0x0c, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x74, 0x61, 0x74,
-	0x65, 0x12, 0x31, 0x0a, 0x15, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x75,
-	0x70, 0x5f, 0x74, 0x6f, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x12, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x55, 0x70, 0x54, 0x6f, 0x42,
-	0x6c, 0x6f, 0x63, 0x6b, 0x1a, 0x6a, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65,
-	0x64, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f,
-	0x62, 0x79, 0x74, 0x65, 0x73, 0x5f, 0x72, 0x65, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x0e, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x42, 0x79, 0x74, 0x65, 0x73, 0x52, 0x65, 0x61, 0x64,
-	0x12, 0x2e, 0x0a, 0x13, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x5f,
-	0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x11, 0x74,
-	0x6f, 0x74, 0x61, 0x6c, 0x42, 0x79, 0x74, 0x65, 0x73, 0x57, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e,
-	0x1a, 0x5b, 0x0a, 0x06, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65,
-	0x61, 0x73, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73,
-	0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
+	0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x52, 0x07, 0x6f,
+	0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x12, 0x2d, 0x0a, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x05,
+	0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x2e, 0x0a, 0x04, 0x73, 0x74, 0x65, 0x70, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x6f, 0x72, 0x6b, 0x53, 0x74, 0x65, 0x70, 0x52,
+	0x04, 0x73, 0x74, 0x65, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0xe2, 0x03,
+	0x0a, 0x0c, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x12, 0x35, 0x0a, 0x0a, 0x6d, 0x61, 0x70, 0x5f, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x41, 0x6e, 0x79, 0x48, 0x00, 0x52, 0x09,
+	0x6d, 0x61, 0x70, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x42, 0x0a, 0x0c, 0x73, 0x74, 0x6f,
+	0x72, 0x65, 0x5f, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1d, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x48, 0x00,
+	0x52, 0x0b, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x12, 0x43, 0x0a,
+	0x0d, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x66, 0x75, 0x6c, 0x6c, 0x5f, 0x6b, 0x76, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x46, 0x75, 0x6c,
+	0x6c, 0x4b, 0x56, 0x48, 0x00, 0x52, 0x0b, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x46, 0x75, 0x6c, 0x6c,
+	0x4b, 0x76, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09,
 	0x52, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x6c, 0x6f, 0x67, 0x73, 0x5f, 0x74,
-	0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d,
-	0x6c, 0x6f, 0x67, 0x73, 0x54, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x42, 0x06, 0x0a,
-	0x04, 0x74, 0x79, 0x70, 0x65, 0x22, 0x4a, 0x0a, 0x0a, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x61,
-	0x6e, 0x67, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x62, 0x6c, 0x6f,
-	0x63, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x42,
-	0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1b, 0x0a, 0x09, 0x65, 0x6e, 0x64, 0x5f, 0x62, 0x6c, 0x6f, 0x63,
-	0x6b, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x65, 0x6e, 0x64, 0x42, 0x6c, 0x6f, 0x63,
-	0x6b, 0x22, 0x43, 0x0a, 0x0b, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x73,
-	0x12, 0x34, 0x0a, 0x06, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
-	0x32, 0x1c, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73,
-	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x06,
-	0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x22, 0xf4, 0x01, 0x0a, 0x0a, 0x53, 0x74, 0x6f, 0x72, 0x65,
-	0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x44, 0x0a, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x26, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75,
-	0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72,
-	0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x2e, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
-	0x52, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x6f,
-	0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x6f, 0x72,
-	0x64, 0x69, 0x6e, 0x61, 0x6c, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x6f, 0x6c, 0x64, 0x5f, 0x76,
-	0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6f, 0x6c, 0x64, 0x56,
-	0x61, 0x6c, 0x75, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x65, 0x77, 0x5f, 0x76, 0x61, 0x6c, 0x75,
-	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6e, 0x65, 0x77, 0x56, 0x61, 0x6c, 0x75,
-	0x65, 0x22, 0x3a, 0x0a, 0x09, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x09,
-	0x0a, 0x05, 0x55, 0x4e, 0x53, 0x45, 0x54, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x52, 0x45,
-	0x41, 0x54, 0x45, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x10,
-	0x02, 0x12, 0x0a, 0x0a, 0x06, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x10, 0x03, 0x22, 0xa6, 0x01,
-	0x0a, 0x06, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x62, 0x6c, 0x6f, 0x63,
-	0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x62, 0x6c, 0x6f,
-	0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x69,
-	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x49, 0x64,
-	0x12, 0x38, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
-	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x2a, 0x0a, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
-	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x41, 0x6e, 0x79, 0x52,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x2a, 0x5c, 0x0a, 0x08, 0x46, 0x6f, 0x72, 0x6b, 0x53, 0x74,
-	0x65, 0x70, 0x12, 0x10, 0x0a, 0x0c, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f,
-	0x57, 0x4e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x4e, 0x45, 0x57,
-	0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x55, 0x4e, 0x44, 0x4f, 0x10,
-	0x02, 0x12, 0x15, 0x0a, 0x11, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x49, 0x52, 0x52, 0x45, 0x56, 0x45,
-	0x52, 0x53, 0x49, 0x42, 0x4c, 0x45, 0x10, 0x04, 0x22, 0x04, 0x08, 0x03, 0x10, 0x03, 0x22, 0x04,
-	0x08, 0x05, 0x10, 0x05, 0x32, 0x4b, 0x0a, 0x06, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x41,
-	0x0a, 0x06, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x12, 0x19, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75,
-	0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30,
-	0x01, 0x42, 0x46, 0x5a, 0x44, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
-	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x69, 0x6e, 0x67, 0x66, 0x61, 0x73, 0x74, 0x2f, 0x73, 0x75,
-	0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x70, 0x62, 0x2f, 0x73, 0x66, 0x2f, 0x73,
-	0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x70, 0x62, 0x73,
-	0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d,
+	0x6c, 0x6f, 0x67, 0x73, 0x54, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x12, 0x29, 0x0a,
+	0x10, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65,
+	0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x54,
+	0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x12, 0x39, 0x0a, 0x0a, 0x6c, 0x6f, 0x67, 0x5f,
+	0x6c, 0x65, 0x76, 0x65, 0x6c, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x73,
+	0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x4c, 0x6f, 0x67, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x52, 0x09, 0x6c, 0x6f, 0x67, 0x4c, 0x65, 0x76,
+	0x65, 0x6c, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x5f, 0x6c,
+	0x6f, 0x67, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x64, 0x72, 0x6f, 0x70, 0x70,
+	0x65, 0x64, 0x4c, 0x6f, 0x67, 0x73, 0x12, 0x2c, 0x0a, 0x12, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65,
+	0x64, 0x5f, 0x6c, 0x6f, 0x67, 0x73, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x0b, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x10, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x4c, 0x6f, 0x67, 0x73, 0x42,
+	0x79, 0x74, 0x65, 0x73, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x4a, 0x04, 0x08, 0x07,
+	0x10, 0x08, 0x22, 0x48, 0x0a, 0x0b, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x46, 0x75, 0x6c, 0x6c, 0x4b,
+	0x56, 0x12, 0x39, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x4b, 0x65, 0x79, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0xa8, 0x01, 0x0a,
+	0x0d, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x12, 0x16,
+	0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x2f, 0x0a, 0x13, 0x71, 0x75, 0x61, 0x72, 0x61, 0x6e,
+	0x74, 0x69, 0x6e, 0x65, 0x64, 0x5f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x12, 0x71, 0x75, 0x61, 0x72, 0x61, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x64,
+	0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f,
+	0x64, 0x75, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x2d, 0x0a, 0x05, 0x63, 0x6c, 0x6f, 0x63,
+	0x6b, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x6f, 0x63, 0x6b,
+	0x52, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x22, 0xe7, 0x01, 0x0a, 0x0b, 0x50, 0x61, 0x6e, 0x69,
+	0x63, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12,
+	0x1b, 0x0a, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x08, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x12, 0x18, 0x0a, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61,
+	0x6d, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x6c, 0x69, 0x6e, 0x65, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x6c, 0x69, 0x6e, 0x65, 0x4e, 0x75, 0x6d,
+	0x62, 0x65, 0x72, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x5f, 0x6e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x63, 0x6f, 0x6c, 0x75,
+	0x6d, 0x6e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x27, 0x0a, 0x0f, 0x65, 0x78, 0x65, 0x63,
+	0x75, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x74, 0x61, 0x63, 0x6b, 0x18, 0x07, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x0e, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x63,
+	0x6b, 0x22, 0x4d, 0x0a, 0x0f, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x50, 0x72, 0x6f, 0x67,
+	0x72, 0x65, 0x73, 0x73, 0x12, 0x3a, 0x0a, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50,
+	0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x52, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73,
+	0x22, 0xe6, 0x05, 0x0a, 0x0e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72,
+	0x65, 0x73, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x5c, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x63, 0x65,
+	0x73, 0x73, 0x65, 0x64, 0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x2f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72,
+	0x65, 0x73, 0x73, 0x2e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x52, 0x61, 0x6e,
+	0x67, 0x65, 0x48, 0x00, 0x52, 0x0f, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x52,
+	0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x54, 0x0a, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c,
+	0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2d, 0x2e, 0x73,
+	0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x2e, 0x49,
+	0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x69,
+	0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x5a, 0x0a, 0x0f, 0x70,
+	0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x2f, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x50, 0x72,
+	0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x2e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64,
+	0x42, 0x79, 0x74, 0x65, 0x73, 0x48, 0x00, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
+	0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x41, 0x0a, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65,
+	0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x2e, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64,
+	0x48, 0x00, 0x52, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x1a, 0x59, 0x0a, 0x0e, 0x50, 0x72,
+	0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x47, 0x0a, 0x10,
+	0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52,
+	0x61, 0x6e, 0x67, 0x65, 0x52, 0x0f, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x52,
+	0x61, 0x6e, 0x67, 0x65, 0x73, 0x1a, 0x41, 0x0a, 0x0c, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x31, 0x0a, 0x15, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62,
+	0x6c, 0x65, 0x5f, 0x75, 0x70, 0x5f, 0x74, 0x6f, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x12, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x55,
+	0x70, 0x54, 0x6f, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x1a, 0x6a, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x63,
+	0x65, 0x73, 0x73, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x74, 0x6f,
+	0x74, 0x61, 0x6c, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x5f, 0x72, 0x65, 0x61, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x42, 0x79, 0x74, 0x65, 0x73,
+	0x52, 0x65, 0x61, 0x64, 0x12, 0x2e, 0x0a, 0x13, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x62, 0x79,
+	0x74, 0x65, 0x73, 0x5f, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x11, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x42, 0x79, 0x74, 0x65, 0x73, 0x57, 0x72, 0x69,
+	0x74, 0x74, 0x65, 0x6e, 0x1a, 0x5b, 0x0a, 0x06, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x12, 0x16,
+	0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x6c, 0x6f,
+	0x67, 0x73, 0x5f, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x0d, 0x6c, 0x6f, 0x67, 0x73, 0x54, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65,
+	0x64, 0x42, 0x06, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x22, 0x4a, 0x0a, 0x0a, 0x42, 0x6c, 0x6f,
+	0x63, 0x6b, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x74,
+	0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1b, 0x0a, 0x09, 0x65, 0x6e, 0x64, 0x5f,
+	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x65, 0x6e, 0x64,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x22, 0x43, 0x0a, 0x0b, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65,
+	0x6c, 0x74, 0x61, 0x73, 0x12, 0x34, 0x0a, 0x06, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c,
+	0x74, 0x61, 0x52, 0x06, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x73, 0x22, 0xf4, 0x01, 0x0a, 0x0a, 0x53,
+	0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x44, 0x0a, 0x09, 0x6f, 0x70, 0x65,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x26, 0x2e, 0x73,
+	0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x2e, 0x4f, 0x70, 0x65, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x18, 0x0a, 0x07, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x07, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x6f,
+	0x6c, 0x64, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08,
+	0x6f, 0x6c, 0x64, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x65, 0x77, 0x5f,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6e, 0x65, 0x77,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x3a, 0x0a, 0x09, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x09, 0x0a, 0x05, 0x55, 0x4e, 0x53, 0x45, 0x54, 0x10, 0x00, 0x12, 0x0a, 0x0a,
+	0x06, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x55, 0x50, 0x44,
+	0x41, 0x54, 0x45, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x10,
+	0x03, 0x22, 0xa6, 0x01, 0x0a, 0x06, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x1b, 0x0a, 0x09,
+	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x08, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x6c, 0x6f,
+	0x63, 0x6b, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x62, 0x6c, 0x6f,
+	0x63, 0x6b, 0x49, 0x64, 0x12, 0x38, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x2a,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x41, 0x6e, 0x79, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x2a, 0x5c, 0x0a, 0x08, 0x4c, 0x6f,
+	0x67, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x13, 0x0a, 0x0f, 0x4c, 0x4f, 0x47, 0x5f, 0x4c, 0x45,
+	0x56, 0x45, 0x4c, 0x5f, 0x44, 0x45, 0x42, 0x55, 0x47, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e, 0x4c,
+	0x4f, 0x47, 0x5f, 0x4c, 0x45, 0x56, 0x45, 0x4c, 0x5f, 0x49, 0x4e, 0x46, 0x4f, 0x10, 0x01, 0x12,
+	0x12, 0x0a, 0x0e, 0x4c, 0x4f, 0x47, 0x5f, 0x4c, 0x45, 0x56, 0x45, 0x4c, 0x5f, 0x57, 0x41, 0x52,
+	0x4e, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0f, 0x4c, 0x4f, 0x47, 0x5f, 0x4c, 0x45, 0x56, 0x45, 0x4c,
+	0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x03, 0x2a, 0x5c, 0x0a, 0x08, 0x46, 0x6f, 0x72, 0x6b,
+	0x53, 0x74, 0x65, 0x70, 0x12, 0x10, 0x0a, 0x0c, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x55, 0x4e, 0x4b,
+	0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x4e,
+	0x45, 0x57, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x55, 0x4e, 0x44,
+	0x4f, 0x10, 0x02, 0x12, 0x15, 0x0a, 0x11, 0x53, 0x54, 0x45, 0x50, 0x5f, 0x49, 0x52, 0x52, 0x45,
+	0x56, 0x45, 0x52, 0x53, 0x49, 0x42, 0x4c, 0x45, 0x10, 0x04, 0x22, 0x04, 0x08, 0x03, 0x10, 0x03,
+	0x22, 0x04, 0x08, 0x05, 0x10, 0x05, 0x32, 0x4b, 0x0a, 0x06, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x12, 0x41, 0x0a, 0x06, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x12, 0x19, 0x2e, 0x73, 0x66, 0x2e,
+	0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x30, 0x01, 0x42, 0x46, 0x5a, 0x44, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x69, 0x6e, 0x67, 0x66, 0x61, 0x73, 0x74, 0x2f,
+	0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x70, 0x62, 0x2f, 0x73, 0x66,
+	0x2f, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x70,
+	0x62, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1481,62 +2869,93 @@ func file_sf_substreams_v1_substreams_proto_rawDescGZIP() []byte {
 	return file_sf_substreams_v1_substreams_proto_rawDescData
 }
 
-var file_sf_substreams_v1_substreams_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_sf_substreams_v1_substreams_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_sf_substreams_v1_substreams_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_sf_substreams_v1_substreams_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_sf_substreams_v1_substreams_proto_goTypes = []interface{}{
-	(ForkStep)(0),                         // 0: sf.substreams.v1.ForkStep
-	(StoreDelta_Operation)(0),             // 1: sf.substreams.v1.StoreDelta.Operation
-	(*Request)(nil),                       // 2: sf.substreams.v1.Request
-	(*Response)(nil),                      // 3: sf.substreams.v1.Response
-	(*InitialSnapshotComplete)(nil),       // 4: sf.substreams.v1.InitialSnapshotComplete
-	(*InitialSnapshotData)(nil),           // 5: sf.substreams.v1.InitialSnapshotData
-	(*BlockScopedData)(nil),               // 6: sf.substreams.v1.BlockScopedData
-	(*ModuleOutput)(nil),                  // 7: sf.substreams.v1.ModuleOutput
-	(*ModulesProgress)(nil),               // 8: sf.substreams.v1.ModulesProgress
-	(*ModuleProgress)(nil),                // 9: sf.substreams.v1.ModuleProgress
-	(*BlockRange)(nil),                    // 10: sf.substreams.v1.BlockRange
-	(*StoreDeltas)(nil),                   // 11: sf.substreams.v1.StoreDeltas
-	(*StoreDelta)(nil),                    // 12: sf.substreams.v1.StoreDelta
-	(*Output)(nil),                        // 13: sf.substreams.v1.Output
-	(*ModuleProgress_ProcessedRange)(nil), // 14: sf.substreams.v1.ModuleProgress.ProcessedRange
-	(*ModuleProgress_InitialState)(nil),   // 15: sf.substreams.v1.ModuleProgress.InitialState
-	(*ModuleProgress_ProcessedBytes)(nil), // 16: sf.substreams.v1.ModuleProgress.ProcessedBytes
-	(*ModuleProgress_Failed)(nil),         // 17: sf.substreams.v1.ModuleProgress.Failed
-	(*Modules)(nil),                       // 18: sf.substreams.v1.Modules
-	(*Clock)(nil),                         // 19: sf.substreams.v1.Clock
-	(*anypb.Any)(nil),                     // 20: google.protobuf.Any
-	(*timestamppb.Timestamp)(nil),         // 21: google.protobuf.Timestamp
+	(LogLevel)(0),                         // 0: sf.substreams.v1.LogLevel
+	(ForkStep)(0),                         // 1: sf.substreams.v1.ForkStep
+	(StoreDelta_Operation)(0),             // 2: sf.substreams.v1.StoreDelta.Operation
+	(*Request)(nil),                       // 3: sf.substreams.v1.Request
+	(*DebugStoreSnapshotRequest)(nil),     // 4: sf.substreams.v1.DebugStoreSnapshotRequest
+	(*Response)(nil),                      // 5: sf.substreams.v1.Response
+	(*InitialSnapshotComplete)(nil),       // 6: sf.substreams.v1.InitialSnapshotComplete
+	(*InitialSnapshotData)(nil),           // 7: sf.substreams.v1.InitialSnapshotData
+	(*BlockUndoSignal)(nil),               // 8: sf.substreams.v1.BlockUndoSignal
+	(*DebugStoreSnapshot)(nil),            // 9: sf.substreams.v1.DebugStoreSnapshot
+	(*SessionInit)(nil),                   // 10: sf.substreams.v1.SessionInit
+	(*InitialBlockOverride)(nil),          // 11: sf.substreams.v1.InitialBlockOverride
+	(*PipelineStats)(nil),                 // 12: sf.substreams.v1.PipelineStats
+	(*ModuleStats)(nil),                   // 13: sf.substreams.v1.ModuleStats
+	(*StoreKeyValue)(nil),                 // 14: sf.substreams.v1.StoreKeyValue
+	(*BlockRef)(nil),                      // 15: sf.substreams.v1.BlockRef
+	(*BlockScopedData)(nil),               // 16: sf.substreams.v1.BlockScopedData
+	(*ModuleOutput)(nil),                  // 17: sf.substreams.v1.ModuleOutput
+	(*StoreFullKV)(nil),                   // 18: sf.substreams.v1.StoreFullKV
+	(*ModuleFailure)(nil),                 // 19: sf.substreams.v1.ModuleFailure
+	(*PanicDetail)(nil),                   // 20: sf.substreams.v1.PanicDetail
+	(*ModulesProgress)(nil),               // 21: sf.substreams.v1.ModulesProgress
+	(*ModuleProgress)(nil),                // 22: sf.substreams.v1.ModuleProgress
+	(*BlockRange)(nil),                    // 23: sf.substreams.v1.BlockRange
+	(*StoreDeltas)(nil),                   // 24: sf.substreams.v1.StoreDeltas
+	(*StoreDelta)(nil),                    // 25: sf.substreams.v1.StoreDelta
+	(*Output)(nil),                        // 26: sf.substreams.v1.Output
+	nil,                                   // 27: sf.substreams.v1.Request.InitialBlockOverridesEntry
+	(*ModuleProgress_ProcessedRange)(nil), // 28: sf.substreams.v1.ModuleProgress.ProcessedRange
+	(*ModuleProgress_InitialState)(nil),   // 29: sf.substreams.v1.ModuleProgress.InitialState
+	(*ModuleProgress_ProcessedBytes)(nil), // 30: sf.substreams.v1.ModuleProgress.ProcessedBytes
+	(*ModuleProgress_Failed)(nil),         // 31: sf.substreams.v1.ModuleProgress.Failed
+	(*Modules)(nil),                       // 32: sf.substreams.v1.Modules
+	(*Clock)(nil),                         // 33: sf.substreams.v1.Clock
+	(*anypb.Any)(nil),                     // 34: google.protobuf.Any
+	(*timestamppb.Timestamp)(nil),         // 35: google.protobuf.Timestamp
 }
 var file_sf_substreams_v1_substreams_proto_depIdxs = []int32{
-	0,  // 0: sf.substreams.v1.Request.fork_steps:type_name -> sf.substreams.v1.ForkStep
-	18, // 1: sf.substreams.v1.Request.modules:type_name -> sf.substreams.v1.Modules
-	8,  // 2: sf.substreams.v1.Response.progress:type_name -> sf.substreams.v1.ModulesProgress
-	5,  // 3: sf.substreams.v1.Response.snapshot_data:type_name -> sf.substreams.v1.InitialSnapshotData
-	4,  // 4: sf.substreams.v1.Response.snapshot_complete:type_name -> sf.substreams.v1.InitialSnapshotComplete
-	6,  // 5: sf.substreams.v1.Response.data:type_name -> sf.substreams.v1.BlockScopedData
-	11, // 6: sf.substreams.v1.InitialSnapshotData.deltas:type_name -> sf.substreams.v1.StoreDeltas
-	7,  // 7: sf.substreams.v1.BlockScopedData.outputs:type_name -> sf.substreams.v1.ModuleOutput
-	19, // 8: sf.substreams.v1.BlockScopedData.clock:type_name -> sf.substreams.v1.Clock
-	0,  // 9: sf.substreams.v1.BlockScopedData.step:type_name -> sf.substreams.v1.ForkStep
-	20, // 10: sf.substreams.v1.ModuleOutput.map_output:type_name -> google.protobuf.Any
-	11, // 11: sf.substreams.v1.ModuleOutput.store_deltas:type_name -> sf.substreams.v1.StoreDeltas
-	9,  // 12: sf.substreams.v1.ModulesProgress.modules:type_name -> sf.substreams.v1.ModuleProgress
-	14, // 13: sf.substreams.v1.ModuleProgress.processed_ranges:type_name -> sf.substreams.v1.ModuleProgress.ProcessedRange
-	15, // 14: sf.substreams.v1.ModuleProgress.initial_state:type_name -> sf.substreams.v1.ModuleProgress.InitialState
-	16, // 15: sf.substreams.v1.ModuleProgress.processed_bytes:type_name -> sf.substreams.v1.ModuleProgress.ProcessedBytes
-	17, // 16: sf.substreams.v1.ModuleProgress.failed:type_name -> sf.substreams.v1.ModuleProgress.Failed
-	12, // 17: sf.substreams.v1.StoreDeltas.deltas:type_name -> sf.substreams.v1.StoreDelta
-	1,  // 18: sf.substreams.v1.StoreDelta.operation:type_name -> sf.substreams.v1.StoreDelta.Operation
-	21, // 19: sf.substreams.v1.Output.timestamp:type_name -> google.protobuf.Timestamp
-	20, // 20: sf.substreams.v1.Output.value:type_name -> google.protobuf.Any
-	10, // 21: sf.substreams.v1.ModuleProgress.ProcessedRange.processed_ranges:type_name -> sf.substreams.v1.BlockRange
-	2,  // 22: sf.substreams.v1.Stream.Blocks:input_type -> sf.substreams.v1.Request
-	3,  // 23: sf.substreams.v1.Stream.Blocks:output_type -> sf.substreams.v1.Response
-	23, // [23:24] is the sub-list for method output_type
-	22, // [22:23] is the sub-list for method input_type
-	22, // [22:22] is the sub-list for extension type_name
-	22, // [22:22] is the sub-list for extension extendee
-	0,  // [0:22] is the sub-list for field type_name
+	1,  // 0: sf.substreams.v1.Request.fork_steps:type_name -> sf.substreams.v1.ForkStep
+	32, // 1: sf.substreams.v1.Request.modules:type_name -> sf.substreams.v1.Modules
+	4,  // 2: sf.substreams.v1.Request.debug_store_snapshot:type_name -> sf.substreams.v1.DebugStoreSnapshotRequest
+	0,  // 3: sf.substreams.v1.Request.min_log_level:type_name -> sf.substreams.v1.LogLevel
+	27, // 4: sf.substreams.v1.Request.initial_block_overrides:type_name -> sf.substreams.v1.Request.InitialBlockOverridesEntry
+	21, // 5: sf.substreams.v1.Response.progress:type_name -> sf.substreams.v1.ModulesProgress
+	7,  // 6: sf.substreams.v1.Response.snapshot_data:type_name -> sf.substreams.v1.InitialSnapshotData
+	6,  // 7: sf.substreams.v1.Response.snapshot_complete:type_name -> sf.substreams.v1.InitialSnapshotComplete
+	16, // 8: sf.substreams.v1.Response.data:type_name -> sf.substreams.v1.BlockScopedData
+	8,  // 9: sf.substreams.v1.Response.undo_signal:type_name -> sf.substreams.v1.BlockUndoSignal
+	9,  // 10: sf.substreams.v1.Response.debug_store_snapshot:type_name -> sf.substreams.v1.DebugStoreSnapshot
+	12, // 11: sf.substreams.v1.Response.stats:type_name -> sf.substreams.v1.PipelineStats
+	10, // 12: sf.substreams.v1.Response.session_init:type_name -> sf.substreams.v1.SessionInit
+	19, // 13: sf.substreams.v1.Response.module_failure:type_name -> sf.substreams.v1.ModuleFailure
+	24, // 14: sf.substreams.v1.InitialSnapshotData.deltas:type_name -> sf.substreams.v1.StoreDeltas
+	15, // 15: sf.substreams.v1.BlockUndoSignal.last_valid_block:type_name -> sf.substreams.v1.BlockRef
+	33, // 16: sf.substreams.v1.DebugStoreSnapshot.clock:type_name -> sf.substreams.v1.Clock
+	14, // 17: sf.substreams.v1.DebugStoreSnapshot.entries:type_name -> sf.substreams.v1.StoreKeyValue
+	11, // 18: sf.substreams.v1.SessionInit.initial_block_overrides:type_name -> sf.substreams.v1.InitialBlockOverride
+	13, // 19: sf.substreams.v1.PipelineStats.modules:type_name -> sf.substreams.v1.ModuleStats
+	17, // 20: sf.substreams.v1.BlockScopedData.outputs:type_name -> sf.substreams.v1.ModuleOutput
+	33, // 21: sf.substreams.v1.BlockScopedData.clock:type_name -> sf.substreams.v1.Clock
+	1,  // 22: sf.substreams.v1.BlockScopedData.step:type_name -> sf.substreams.v1.ForkStep
+	34, // 23: sf.substreams.v1.ModuleOutput.map_output:type_name -> google.protobuf.Any
+	24, // 24: sf.substreams.v1.ModuleOutput.store_deltas:type_name -> sf.substreams.v1.StoreDeltas
+	18, // 25: sf.substreams.v1.ModuleOutput.store_full_kv:type_name -> sf.substreams.v1.StoreFullKV
+	0,  // 26: sf.substreams.v1.ModuleOutput.log_levels:type_name -> sf.substreams.v1.LogLevel
+	14, // 27: sf.substreams.v1.StoreFullKV.entries:type_name -> sf.substreams.v1.StoreKeyValue
+	33, // 28: sf.substreams.v1.ModuleFailure.clock:type_name -> sf.substreams.v1.Clock
+	22, // 29: sf.substreams.v1.ModulesProgress.modules:type_name -> sf.substreams.v1.ModuleProgress
+	28, // 30: sf.substreams.v1.ModuleProgress.processed_ranges:type_name -> sf.substreams.v1.ModuleProgress.ProcessedRange
+	29, // 31: sf.substreams.v1.ModuleProgress.initial_state:type_name -> sf.substreams.v1.ModuleProgress.InitialState
+	30, // 32: sf.substreams.v1.ModuleProgress.processed_bytes:type_name -> sf.substreams.v1.ModuleProgress.ProcessedBytes
+	31, // 33: sf.substreams.v1.ModuleProgress.failed:type_name -> sf.substreams.v1.ModuleProgress.Failed
+	25, // 34: sf.substreams.v1.StoreDeltas.deltas:type_name -> sf.substreams.v1.StoreDelta
+	2,  // 35: sf.substreams.v1.StoreDelta.operation:type_name -> sf.substreams.v1.StoreDelta.Operation
+	35, // 36: sf.substreams.v1.Output.timestamp:type_name -> google.protobuf.Timestamp
+	34, // 37: sf.substreams.v1.Output.value:type_name -> google.protobuf.Any
+	23, // 38: sf.substreams.v1.ModuleProgress.ProcessedRange.processed_ranges:type_name -> sf.substreams.v1.BlockRange
+	3,  // 39: sf.substreams.v1.Stream.Blocks:input_type -> sf.substreams.v1.Request
+	5,  // 40: sf.substreams.v1.Stream.Blocks:output_type -> sf.substreams.v1.Response
+	40, // [40:41] is the sub-list for method output_type
+	39, // [39:40] is the sub-list for method input_type
+	39, // [39:39] is the sub-list for extension type_name
+	39, // [39:39] is the sub-list for extension extendee
+	0,  // [0:39] is the sub-list for field type_name
 }
 
 func init() { file_sf_substreams_v1_substreams_proto_init() }
@@ -1560,7 +2979,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Response); i {
+			switch v := v.(*DebugStoreSnapshotRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1572,7 +2991,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*InitialSnapshotComplete); i {
+			switch v := v.(*Response); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1584,7 +3003,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*InitialSnapshotData); i {
+			switch v := v.(*InitialSnapshotComplete); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1596,7 +3015,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*BlockScopedData); i {
+			switch v := v.(*InitialSnapshotData); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1608,7 +3027,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ModuleOutput); i {
+			switch v := v.(*BlockUndoSignal); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1620,7 +3039,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ModulesProgress); i {
+			switch v := v.(*DebugStoreSnapshot); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1632,7 +3051,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ModuleProgress); i {
+			switch v := v.(*SessionInit); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1644,7 +3063,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*BlockRange); i {
+			switch v := v.(*InitialBlockOverride); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1656,7 +3075,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*StoreDeltas); i {
+			switch v := v.(*PipelineStats); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1668,7 +3087,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*StoreDelta); i {
+			switch v := v.(*ModuleStats); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1680,7 +3099,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Output); i {
+			switch v := v.(*StoreKeyValue); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1692,7 +3111,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ModuleProgress_ProcessedRange); i {
+			switch v := v.(*BlockRef); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1704,7 +3123,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ModuleProgress_InitialState); i {
+			switch v := v.(*BlockScopedData); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1716,7 +3135,7 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ModuleProgress_ProcessedBytes); i {
+			switch v := v.(*ModuleOutput); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1728,6 +3147,150 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 		file_sf_substreams_v1_substreams_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StoreFullKV); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModuleFailure); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PanicDetail); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModulesProgress); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModuleProgress); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlockRange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StoreDeltas); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StoreDelta); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Output); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModuleProgress_ProcessedRange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModuleProgress_InitialState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModuleProgress_ProcessedBytes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_v1_substreams_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ModuleProgress_Failed); i {
 			case 0:
 				return &v.state
@@ -1740,17 +3303,23 @@ func file_sf_substreams_v1_substreams_proto_init() {
 			}
 		}
 	}
-	file_sf_substreams_v1_substreams_proto_msgTypes[1].OneofWrappers = []interface{}{
+	file_sf_substreams_v1_substreams_proto_msgTypes[2].OneofWrappers = []interface{}{
 		(*Response_Progress)(nil),
 		(*Response_SnapshotData)(nil),
 		(*Response_SnapshotComplete)(nil),
 		(*Response_Data)(nil),
+		(*Response_UndoSignal)(nil),
+		(*Response_DebugStoreSnapshot)(nil),
+		(*Response_Stats)(nil),
+		(*Response_SessionInit)(nil),
+		(*Response_ModuleFailure)(nil),
 	}
-	file_sf_substreams_v1_substreams_proto_msgTypes[5].OneofWrappers = []interface{}{
+	file_sf_substreams_v1_substreams_proto_msgTypes[14].OneofWrappers = []interface{}{
 		(*ModuleOutput_MapOutput)(nil),
 		(*ModuleOutput_StoreDeltas)(nil),
+		(*ModuleOutput_StoreFullKv)(nil),
 	}
-	file_sf_substreams_v1_substreams_proto_msgTypes[7].OneofWrappers = []interface{}{
+	file_sf_substreams_v1_substreams_proto_msgTypes[19].OneofWrappers = []interface{}{
 		(*ModuleProgress_ProcessedRanges)(nil),
 		(*ModuleProgress_InitialState_)(nil),
 		(*ModuleProgress_ProcessedBytes_)(nil),
@@ -1761,8 +3330,8 @@ func file_sf_substreams_v1_substreams_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_sf_substreams_v1_substreams_proto_rawDesc,
-			NumEnums:      2,
-			NumMessages:   16,
+			NumEnums:      3,
+			NumMessages:   29,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
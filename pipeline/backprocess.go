@@ -47,7 +47,7 @@ func (p *Pipeline) backProcessStores(
 			span.SetStatus(codes.Error, err.Error())
 			return nil, err
 		}
-		workPlan[mod.Name] = orchestrator.SplitWork(mod.Name, p.storeSaveInterval, mod.InitialBlock, uint64(p.request.StartBlockNum), snapshot)
+		workPlan[mod.Name] = orchestrator.SplitWork(mod.Name, p.storeSaveInterval, mod.InitialBlock, p.requestedStartBlockNum, snapshot)
 	}
 
 	logger.Info("work plan ready", zap.Stringer("work_plan", workPlan))
@@ -58,7 +58,7 @@ func (p *Pipeline) backProcessStores(
 		return nil, fmt.Errorf("sending progress: %w", err)
 	}
 
-	upToBlock := uint64(p.request.StartBlockNum)
+	upToBlock := p.requestedStartBlockNum
 
 	jobsPlanner, err := orchestrator.NewJobsPlanner(ctx, workPlan, uint64(p.subrequestSplitSize), initialStoreMap, p.graph)
 	if err != nil {
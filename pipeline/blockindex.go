@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/streamingfast/substreams/manifest"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// blockFilter skips the execution of a module on the blocks whose index keys
+// do not match the query.
+type blockFilter struct {
+	indexModule string
+	query       manifest.BlockFilterQuery
+}
+
+func newBlockFilter(filter *pbsubstreams.Module_BlockFilter) (*blockFilter, error) {
+	query, err := manifest.ParseBlockFilterQuery(filter.Query)
+	if err != nil {
+		return nil, err
+	}
+	return &blockFilter{indexModule: filter.Module, query: query}, nil
+}
+
+// matches tells if the block must be processed, given the outputs of the
+// modules already executed for it, which include the index module.
+func (f *blockFilter) matches(vals map[string][]byte) (bool, error) {
+	indexKeys := &pbsubstreams.BlockIndexKeys{}
+	if err := proto.Unmarshal(vals[f.indexModule], indexKeys); err != nil {
+		return false, fmt.Errorf("unmarshalling keys of index %q: %w", f.indexModule, err)
+	}
+
+	keys := make(map[string]bool, len(indexKeys.Keys))
+	for _, key := range indexKeys.Keys {
+		keys[key] = true
+	}
+	return f.query.Matches(keys), nil
+}
+
+var _ ModuleExecutor = (*IndexModuleExecutor)(nil)
+
+// IndexModuleExecutor runs a block index module, whose output is the
+// `BlockIndexKeys` of the block.
+type IndexModuleExecutor struct {
+	BaseExecutor
+	indexKeys []byte
+}
+
+// Name implements ModuleExecutor
+func (e *IndexModuleExecutor) Name() string {
+	return e.moduleName
+}
+
+func (e *IndexModuleExecutor) String() string {
+	return e.moduleName
+}
+
+func (e *IndexModuleExecutor) run(ctx context.Context, vals map[string][]byte, clock *pbsubstreams.Clock, cursor string) error {
+	ctx, span := e.startSpan(ctx, "exec_index", clock)
+	defer span.End()
+
+	e.Reset()
+
+	if err := e.cachedFailure(clock); err != nil {
+		e.cacheHit = true
+		span.SetAttributes(attribute.Bool("cache_hit", true))
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	output, found := e.cache.Get(clock)
+	e.cacheHit = found
+	if found {
+		vals[e.moduleName] = output
+		e.indexKeys = output
+		span.SetAttributes(attribute.Bool("cache_hit", true), attribute.Int("output_bytes", len(output)))
+		span.SetStatus(codes.Ok, "cache_hit")
+		return nil
+	}
+	span.SetAttributes(attribute.Bool("cache_hit", false))
+
+	instance, err := e.wasmCall(ctx, vals, clock)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		e.cacheFailure(ctx, clock, cursor, err)
+		return err
+	}
+
+	var out []byte
+	if instance != nil {
+		out = instance.Output()
+		if err := proto.Unmarshal(out, &pbsubstreams.BlockIndexKeys{}); err != nil {
+			err = fmt.Errorf("block %d: module %q: invalid index keys output: %w", clock.Number, e.moduleName, err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+	vals[e.moduleName] = out
+	e.indexKeys = out
+	span.SetAttributes(attribute.Int("input_bytes", e.inputsSize()), attribute.Int("output_bytes", len(out)))
+
+	if err := e.cache.Set(clock, cursor, out); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("setting index keys to cache at block %d: %w", clock.Number, err)
+	}
+
+	span.SetStatus(codes.Ok, "module_executed")
+	return nil
+}
+
+func (e *IndexModuleExecutor) moduleLogs() executionLogs {
+	return newExecutionLogs(e.currentInstance())
+}
+
+func (e *IndexModuleExecutor) moduleOutputData() pbsubstreams.ModuleOutputData {
+	if e.indexKeys != nil {
+		return &pbsubstreams.ModuleOutput_MapOutput{
+			MapOutput: &anypb.Any{TypeUrl: "type.googleapis.com/sf.substreams.v1.BlockIndexKeys", Value: e.indexKeys},
+		}
+	}
+	return nil
+}
+
+func (e *IndexModuleExecutor) moduleOutputTruncated() bool {
+	return false
+}
+
+func (e *IndexModuleExecutor) getCurrentExecutionStack() []string {
+	if instance := e.currentInstance(); instance != nil {
+		return instance.ExecutionStack
+	}
+	return nil
+}
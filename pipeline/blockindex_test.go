@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/wasm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBlockFilteredMapper(t *testing.T) {
+	ctx := context.Background()
+	tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+
+	newBase := func(name, input string) BaseExecutor {
+		code, err := wasmtime.Wat2Wasm(echoWAT)
+		require.NoError(t, err)
+		wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, name, "map_echo")
+		require.NoError(t, err)
+
+		cache := outputs.NewOutputCache(name, dstore.NewMockStore(nil), 10, zap.NewNop())
+		_, err = cache.LoadAtBlock(ctx, 0)
+		require.NoError(t, err)
+
+		return BaseExecutor{
+			moduleName: name,
+			wasmModule: wasmModule,
+			wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: input}},
+			cache:      cache,
+			tracer:     tracer,
+		}
+	}
+
+	// The index echoes the keys given as its input
+	indexExecutor := &IndexModuleExecutor{BaseExecutor: newBase("index_events", "keys")}
+
+	filter, err := newBlockFilter(&pbsubstreams.Module_BlockFilter{Module: "index_events", Query: "transfer || mint && usdc"})
+	require.NoError(t, err)
+	mapperBase := newBase("map_transfers", "block")
+	mapperBase.blockFilter = filter
+	mapperExecutor := &MapperModuleExecutor{BaseExecutor: mapperBase}
+
+	tests := []struct {
+		keys          []string
+		expectMatched bool
+	}{
+		{[]string{"transfer"}, true},
+		{[]string{"approval"}, false},
+		{[]string{"mint"}, false},
+		{[]string{"mint", "usdc"}, true},
+		{nil, false},
+	}
+
+	for i, test := range tests {
+		clock := &pbsubstreams.Clock{Id: fmt.Sprintf("%da", i+1), Number: uint64(i + 1)}
+		keys, err := proto.Marshal(&pbsubstreams.BlockIndexKeys{Keys: test.keys})
+		require.NoError(t, err)
+		vals := map[string][]byte{"keys": keys, "block": []byte("block")}
+
+		require.NoError(t, indexExecutor.run(ctx, vals, clock, ""))
+		require.NoError(t, mapperExecutor.run(ctx, vals, clock, ""))
+
+		cached, found := mapperExecutor.cache.Get(clock)
+		require.True(t, found, "filtered out blocks must be cached too")
+		if test.expectMatched {
+			assert.Equal(t, []byte("block"), mapperExecutor.mapperOutput, "keys %v", test.keys)
+			assert.Equal(t, []byte("block"), cached)
+		} else {
+			assert.Nil(t, mapperExecutor.mapperOutput, "keys %v", test.keys)
+			assert.Empty(t, cached)
+		}
+	}
+
+	// The index output must be valid keys
+	clock := &pbsubstreams.Clock{Id: "10a", Number: 10}
+	err = indexExecutor.run(ctx, map[string][]byte{"keys": {0xff}}, clock, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `module "index_events": invalid index keys output`)
+}
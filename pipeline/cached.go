@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/streamingfast/bstream"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+// ProcessCachedBlocks runs the pipeline over the blocks for which every
+// module output is already present in the output caches, without pulling
+// anything from the block source. It stops at the first block that cannot be
+// entirely served from the caches and returns the cursor of the last block
+// it processed, from which the block source must resume. A nil cursor means
+// no block was served from the caches.
+//
+// It returns io.EOF when the stop block of the request was reached.
+func (p *Pipeline) ProcessCachedBlocks() (lastCursor *bstream.Cursor, err error) {
+	ctx, span := p.tracer.Start(p.context, "process_cached_blocks")
+	defer span.End()
+
+	defer func() {
+		if err == io.EOF {
+			for _, hook := range p.postJobHooks {
+				if err := hook(ctx, p.clock); err != nil {
+					p.logger.Warn("post job hook failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	nextBlock := p.requestedStartBlockNum
+	servedCount := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return lastCursor, err
+		}
+
+		_, skipBlockSource := OptimizeExecutors(p.moduleOutputCache.OutputCaches, p.moduleExecutors)
+		if !skipBlockSource {
+			break
+		}
+
+		referenceCache := p.moduleOutputCache.OutputCaches[p.moduleExecutors[0].Name()]
+		currentRange := referenceCache.CurrentBlockRange
+
+		items := cachedItemsFrom(referenceCache, nextBlock)
+		if len(items) == 0 || items[0].BlockNum != nextBlock {
+			// We cannot tell if the blocks before the first cached one
+			// were simply never cached, let the block source handle them.
+			break
+		}
+
+		var lastServedBlock uint64
+		completed := true
+		for _, item := range items {
+			clock := &pbsubstreams.Clock{
+				Number:    item.BlockNum,
+				Id:        item.BlockID,
+				Timestamp: item.Timestamp,
+			}
+
+			cursor, err := bstream.CursorFromOpaque(item.Cursor)
+			if err != nil || item.BlockNum > cursor.LIB.Num() || !p.isCachedForAllExecutors(clock) {
+				// Only final blocks served by every cache are processed from
+				// here, the block source takes over from the previous one.
+				completed = false
+				break
+			}
+
+			if err := p.processCachedBlock(clock, cursor); err != nil {
+				return lastCursor, err
+			}
+
+			lastCursor = cursor
+			lastServedBlock = item.BlockNum
+			servedCount++
+		}
+
+		if !completed || lastServedBlock != currentRange.ExclusiveEndBlock-1 {
+			// The cached range may have been written up to a stop block, so we
+			// only cross into the next one when it was filled to its last block.
+			break
+		}
+
+		nextBlock = currentRange.ExclusiveEndBlock
+		for _, cache := range p.moduleOutputCache.OutputCaches {
+			if _, err := cache.LoadAtBlock(ctx, nextBlock); err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				return lastCursor, fmt.Errorf("loading output cache of module %q at block %d: %w", cache.ModuleName, nextBlock, err)
+			}
+		}
+	}
+
+	p.logger.Info("blocks served from output caches", zap.Int("block_count", servedCount), zap.Stringer("last_cursor", lastCursor))
+	span.SetAttributes(attribute.Int("block_count", servedCount))
+	span.SetStatus(codes.Ok, "")
+	return lastCursor, nil
+}
+
+func (p *Pipeline) processCachedBlock(clock *pbsubstreams.Clock, cursor *bstream.Cursor) (err error) {
+	p.blockLock.Lock()
+	defer p.blockLock.Unlock()
+
+	ctx, span := p.blockTracer(clock.Number).Start(p.context, "process_cached_block")
+	span.SetAttributes(attribute.Int64("block_num", int64(clock.Number)))
+	defer span.End()
+
+	p.logger.Debug("processing cached block", zap.Uint64("block_num", clock.Number))
+
+	p.clock = clock
+	p.currentBlockRef = bstream.NewBlockRef(clock.Id, clock.Number)
+
+	for _, hook := range p.preBlockHooks {
+		if err := hook(ctx, p.clock); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("pre block hook: %w", err)
+		}
+	}
+
+	if err := p.saveStoresOnBoundaries(ctx, span, clock.Number); err != nil {
+		return err
+	}
+
+	if isStopBlockReached(clock.Number, p.request.StopBlockNum) {
+		return p.flushOnStopBlock(ctx, span, clock.Number)
+	}
+
+	if err := p.executeModules(ctx, span, bstream.StepIrreversible, cursor); err != nil {
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+func (p *Pipeline) isCachedForAllExecutors(clock *pbsubstreams.Clock) bool {
+	for _, executor := range p.moduleExecutors {
+		if _, found := p.moduleOutputCache.OutputCaches[executor.Name()].Get(clock); !found {
+			return false
+		}
+	}
+	return true
+}
+
+func cachedItemsFrom(cache *outputs.OutputCache, fromBlock uint64) (out []*outputs.CacheItem) {
+	for _, item := range cache.SortedCacheItems() {
+		if item.BlockNum >= fromBlock {
+			out = append(out, item)
+		}
+	}
+	return
+}
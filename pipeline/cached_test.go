@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/wasm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestOptimizeExecutors(t *testing.T) {
+	tests := []struct {
+		name                  string
+		caches                map[string]*outputs.OutputCache
+		expectOptimized       []string
+		expectSkipBlockSource bool
+	}{
+		{
+			name: "all loaded on same range",
+			caches: map[string]*outputs.OutputCache{
+				"map_a":   loadedTestCache(t, "map_a", 0, 10, 0, 10),
+				"store_b": loadedTestCache(t, "store_b", 0, 10, 0, 10),
+			},
+			expectSkipBlockSource: true,
+		},
+		{
+			name: "one cache not loaded",
+			caches: map[string]*outputs.OutputCache{
+				"map_a":   loadedTestCache(t, "map_a", 0, 10, 0, 10),
+				"store_b": loadedTestCache(t, "store_b", 0, 10, 0, 0),
+			},
+			expectOptimized: []string{"store_b"},
+		},
+		{
+			name: "ranges differ",
+			caches: map[string]*outputs.OutputCache{
+				"map_a":   loadedTestCache(t, "map_a", 0, 10, 0, 10),
+				"store_b": loadedTestCache(t, "store_b", 10, 20, 10, 20),
+			},
+			expectOptimized: []string{"store_b"},
+		},
+		{
+			name: "missing cache",
+			caches: map[string]*outputs.OutputCache{
+				"map_a": loadedTestCache(t, "map_a", 0, 10, 0, 10),
+			},
+			expectOptimized: []string{"store_b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executors := []ModuleExecutor{
+				&MapperModuleExecutor{BaseExecutor: BaseExecutor{moduleName: "map_a"}},
+				&StoreModuleExecutor{BaseExecutor: BaseExecutor{moduleName: "store_b"}},
+			}
+
+			optimized, skipBlockSource := OptimizeExecutors(test.caches, executors)
+
+			var names []string
+			for _, executor := range optimized {
+				names = append(names, executor.Name())
+			}
+			assert.Equal(t, test.expectOptimized, names)
+			assert.Equal(t, test.expectSkipBlockSource, skipBlockSource)
+		})
+	}
+}
+
+func TestPipeline_ProcessCachedBlocks(t *testing.T) {
+	tests := []struct {
+		name             string
+		startBlock       uint64
+		stopBlock        uint64
+		irreversibleUpTo uint64
+		expectBlocks     []uint64
+		expectLastCursor uint64
+		expectEOF        bool
+	}{
+		{
+			name:             "crosses full ranges and stops at the truncated one",
+			startBlock:       2,
+			irreversibleUpTo: 100,
+			expectBlocks:     []uint64{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14},
+			expectLastCursor: 14,
+		},
+		{
+			name:             "stops before reversible blocks",
+			startBlock:       5,
+			irreversibleUpTo: 7,
+			expectBlocks:     []uint64{5, 6, 7},
+			expectLastCursor: 7,
+		},
+		{
+			name:             "reaches stop block",
+			startBlock:       8,
+			stopBlock:        12,
+			irreversibleUpTo: 100,
+			expectBlocks:     []uint64{8, 9, 10, 11},
+			expectEOF:        true,
+		},
+		{
+			name:             "start block not cached",
+			startBlock:       15,
+			irreversibleUpTo: 100,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := dstore.NewMockStore(nil)
+			writeTestCacheFile(t, store, 0, 10, 0, 10, test.irreversibleUpTo)
+			writeTestCacheFile(t, store, 10, 20, 10, 15, test.irreversibleUpTo)
+
+			var sentBlocks []uint64
+			p := &Pipeline{
+				context:                context.Background(),
+				tracer:                 ttrace.NewNoopTracerProvider().Tracer("test"),
+				logger:                 zap.NewNop(),
+				request:                &pbsubstreams.Request{StopBlockNum: test.stopBlock, OutputModules: []string{"map_a"}},
+				requestedStartBlockNum: test.startBlock,
+				outputModuleMap:        map[string]bool{"map_a": true},
+				nextStoreSaveBoundary:  1000,
+				wasmOutputs:            map[string][]byte{},
+				forkHandler:            NewForkHandle(),
+				moduleOutputCache:      outputs.NewModuleOutputCache(10, zap.NewNop()),
+				respFunc: func(resp *pbsubstreams.Response) error {
+					sentBlocks = append(sentBlocks, resp.GetData().Clock.Number)
+					return nil
+				},
+			}
+
+			cache := outputs.NewOutputCache("map_a", store, 10, zap.NewNop())
+			_, err := cache.LoadAtBlock(context.Background(), outputs.ComputeStartBlock(test.startBlock, 10))
+			require.NoError(t, err)
+			p.moduleOutputCache.OutputCaches["map_a"] = cache
+			p.moduleExecutors = []ModuleExecutor{
+				&MapperModuleExecutor{BaseExecutor: BaseExecutor{
+					moduleName: "map_a",
+					wasmModule: &wasm.Module{},
+					cache:      cache,
+					isOutput:   true,
+					tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+				}},
+			}
+
+			lastCursor, err := p.ProcessCachedBlocks()
+			if test.expectEOF {
+				require.Equal(t, io.EOF, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, test.expectBlocks, sentBlocks)
+			if test.expectLastCursor != 0 {
+				require.NotNil(t, lastCursor)
+				assert.Equal(t, test.expectLastCursor, lastCursor.Block.Num())
+			} else if !test.expectEOF {
+				assert.Nil(t, lastCursor)
+			}
+		})
+	}
+}
+
+func loadedTestCache(t *testing.T, moduleName string, rangeStart, rangeEnd, itemStart, itemEnd uint64) *outputs.OutputCache {
+	t.Helper()
+
+	store := dstore.NewMockStore(nil)
+	if itemEnd > itemStart {
+		writeTestCacheFile(t, store, rangeStart, rangeEnd, itemStart, itemEnd, itemEnd)
+	}
+
+	cache := outputs.NewOutputCache(moduleName, store, 10, zap.NewNop())
+	_, err := cache.LoadAtBlock(context.Background(), rangeStart)
+	require.NoError(t, err)
+	return cache
+}
+
+func writeTestCacheFile(t *testing.T, store *dstore.MockStore, rangeStart, rangeEnd, itemStart, itemEnd, irreversibleUpTo uint64) {
+	t.Helper()
+
+	kv := map[string]*outputs.CacheItem{}
+	for num := itemStart; num < itemEnd; num++ {
+		id := fmt.Sprintf("%da", num)
+		ref := bstream.NewBlockRef(id, num)
+		lib := ref
+		if num > irreversibleUpTo {
+			lib = bstream.NewBlockRef(fmt.Sprintf("%da", irreversibleUpTo), irreversibleUpTo)
+		}
+		cursor := &bstream.Cursor{Step: bstream.StepNew, Block: ref, HeadBlock: ref, LIB: lib}
+
+		kv[id] = &outputs.CacheItem{
+			BlockNum: num,
+			BlockID:  id,
+			Payload:  []byte{0x01},
+			Cursor:   cursor.ToOpaque(),
+		}
+	}
+
+	content, err := json.Marshal(kv)
+	require.NoError(t, err)
+	store.SetFile(outputs.ComputeDBinFilename(rangeStart, rangeEnd), content)
+}
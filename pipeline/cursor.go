@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/streamingfast/bstream"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// ParseStartCursor decodes the start cursor of the request and checks that it
+// points to a block the request could have sent.
+func ParseStartCursor(request *pbsubstreams.Request) (*bstream.Cursor, error) {
+	cursor, err := bstream.CursorFromOpaque(request.StartCursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start cursor: %w", err)
+	}
+	if cursor.IsEmpty() {
+		return nil, fmt.Errorf("invalid start cursor: missing block references")
+	}
+
+	blockNum := cursor.Block.Num()
+	if request.StartBlockNum >= 0 && blockNum < uint64(request.StartBlockNum) {
+		return nil, fmt.Errorf("invalid start cursor: block %d is before start block %d", blockNum, request.StartBlockNum)
+	}
+	if request.StopBlockNum != 0 && blockNum >= request.StopBlockNum {
+		return nil, fmt.Errorf("invalid start cursor: block %d is at or after stop block %d", blockNum, request.StopBlockNum)
+	}
+	if request.FinalBlocksOnly && !isFinalCursor(cursor) {
+		return nil, fmt.Errorf("invalid start cursor: block %d is not final, which is required with final blocks only", blockNum)
+	}
+	return cursor, nil
+}
+
+// isFinalCursor returns true when the client has seen nothing past the final
+// blocks of the chain, in which case the stream can simply resume after the
+// cursor block.
+func isFinalCursor(cursor *bstream.Cursor) bool {
+	return cursor.Step != bstream.StepUndo && cursor.Block.Num() <= cursor.LIB.Num()
+}
+
+// resumeStartBlock returns the block from which the pipeline must process
+// blocks to resume the stream at `cursor`. Stores can only be synchronized up
+// to a final block, so unless the cursor block is final itself, processing
+// resumes right after the last final block known by the cursor.
+func resumeStartBlock(cursor *bstream.Cursor, requestStartBlock uint64) uint64 {
+	if isFinalCursor(cursor) {
+		return cursor.Block.Num() + 1
+	}
+
+	startBlock := cursor.LIB.Num() + 1
+	if startBlock < requestStartBlock {
+		return requestStartBlock
+	}
+	return startBlock
+}
+
+// cursorResumption sits in front of the response function while the blocks
+// between the last final block of a start cursor and the cursor block itself
+// are replayed. The client received those blocks before reconnecting, they
+// are replayed only to bring the stores back to the state of the cursor
+// block. Their data is kept aside until the cursor block shows up again:
+//
+//   - when it is still part of the chain, the kept data is dropped and the
+//     stream continues right after it, without any duplicate
+//   - when it was forked out, the client is told to undo everything after the
+//     last final block and the kept data is sent in place of the orphaned
+//     blocks it holds
+type cursorResumption struct {
+	cursor   *bstream.Cursor
+	respFunc func(resp *pbsubstreams.Response) error
+
+	replayed []*pbsubstreams.Response
+	done     bool
+}
+
+func newCursorResumption(cursor *bstream.Cursor, respFunc func(resp *pbsubstreams.Response) error) *cursorResumption {
+	return &cursorResumption{
+		cursor:   cursor,
+		respFunc: respFunc,
+	}
+}
+
+func (r *cursorResumption) send(resp *pbsubstreams.Response) error {
+	if r.done {
+		return r.respFunc(resp)
+	}
+
+	switch msg := resp.Message.(type) {
+	case *pbsubstreams.Response_Data:
+		return r.sendData(resp, msg.Data.Clock)
+	case *pbsubstreams.Response_UndoSignal:
+		// The client never saw the replayed blocks being undone, they are
+		// simply forgotten.
+		lastValidBlock := msg.UndoSignal.LastValidBlock.Number
+		for i, replayed := range r.replayed {
+			if replayed.GetData().Clock.Number > lastValidBlock {
+				r.replayed = r.replayed[:i]
+				break
+			}
+		}
+		return nil
+	default:
+		return r.respFunc(resp)
+	}
+}
+
+func (r *cursorResumption) sendData(resp *pbsubstreams.Response, clock *pbsubstreams.Clock) error {
+	cursorBlock := r.cursor.Block
+	if clock.Number < cursorBlock.Num() {
+		r.replayed = append(r.replayed, resp)
+		return nil
+	}
+
+	r.done = true
+	replayed := r.replayed
+	r.replayed = nil
+
+	if clock.Number == cursorBlock.Num() && clock.Id == cursorBlock.ID() && r.cursor.Step != bstream.StepUndo {
+		// The client is exactly where it left, at a block that is still part of the chain
+		return nil
+	}
+
+	lib := r.cursor.LIB
+	libCursor := &bstream.Cursor{Step: bstream.StepIrreversible, Block: lib, HeadBlock: lib, LIB: lib}
+	if err := returnUndoSignal(lib, libCursor, r.respFunc); err != nil {
+		return fmt.Errorf("undoing orphaned blocks of start cursor: %w", err)
+	}
+
+	for _, replayedResp := range append(replayed, resp) {
+		if err := r.respFunc(replayedResp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
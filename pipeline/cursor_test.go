@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/streamingfast/bstream"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ttrace "go.opentelemetry.io/otel/trace"
+)
+
+func testCursor(step bstream.StepType, blockID string, blockNum uint64, libID string, libNum uint64) *bstream.Cursor {
+	block := bstream.NewBlockRef(blockID, blockNum)
+	return &bstream.Cursor{Step: step, Block: block, HeadBlock: block, LIB: bstream.NewBlockRef(libID, libNum)}
+}
+
+func TestParseStartCursor(t *testing.T) {
+	tests := []struct {
+		name            string
+		cursor          string
+		startBlock      int64
+		stopBlock       uint64
+		finalBlocksOnly bool
+		expectError     bool
+	}{
+		{"valid", testCursor(bstream.StepNew, "12a", 12, "10a", 10).ToOpaque(), 5, 20, false, false},
+		{"at start block", testCursor(bstream.StepNew, "5a", 5, "3a", 3).ToOpaque(), 5, 0, false, false},
+		{"not opaque", "c1:1:12:12a:10:10a", 5, 0, false, true},
+		{"before start block", testCursor(bstream.StepNew, "4a", 4, "3a", 3).ToOpaque(), 5, 0, false, true},
+		{"at stop block", testCursor(bstream.StepNew, "20a", 20, "18a", 18).ToOpaque(), 5, 20, false, true},
+		{"final blocks only", testCursor(bstream.StepIrreversible, "12a", 12, "12a", 12).ToOpaque(), 5, 0, true, false},
+		{"final blocks only on reversible block", testCursor(bstream.StepNew, "12a", 12, "10a", 10).ToOpaque(), 5, 0, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseStartCursor(&pbsubstreams.Request{StartCursor: test.cursor, StartBlockNum: test.startBlock, StopBlockNum: test.stopBlock, FinalBlocksOnly: test.finalBlocksOnly})
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResumeStartBlock(t *testing.T) {
+	tests := []struct {
+		name              string
+		cursor            *bstream.Cursor
+		requestStartBlock uint64
+		expect            uint64
+	}{
+		{"final block", testCursor(bstream.StepIrreversible, "12a", 12, "12a", 12), 5, 13},
+		{"new block behind lib", testCursor(bstream.StepNew, "12a", 12, "15a", 15), 5, 13},
+		{"reversible block", testCursor(bstream.StepNew, "12a", 12, "10a", 10), 5, 11},
+		{"undone block", testCursor(bstream.StepUndo, "12a", 12, "12a", 12), 5, 13},
+		{"undone reversible block", testCursor(bstream.StepUndo, "12a", 12, "10a", 10), 5, 11},
+		{"lib before request start block", testCursor(bstream.StepNew, "12a", 12, "3a", 3), 5, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expect, resumeStartBlock(test.cursor, test.requestStartBlock))
+		})
+	}
+}
+
+func TestPipeline_ResumeFromCursor(t *testing.T) {
+	type event struct {
+		blockID  string
+		blockNum uint64
+		undo     bool
+	}
+	block := func(id string, num uint64) event { return event{blockID: id, blockNum: num} }
+	undoTo := func(id string, num uint64) event { return event{blockID: id, blockNum: num, undo: true} }
+
+	tests := []struct {
+		name       string
+		cursor     *bstream.Cursor
+		events     []event
+		expectSent []string
+	}{
+		{
+			name:       "canonical cursor",
+			cursor:     testCursor(bstream.StepNew, "12a", 12, "10a", 10),
+			events:     []event{block("11a", 11), block("12a", 12), block("13a", 13)},
+			expectSent: []string{"data:13a"},
+		},
+		{
+			name:       "canonical cursor after a fork during replay",
+			cursor:     testCursor(bstream.StepNew, "12a", 12, "10a", 10),
+			events:     []event{block("11b", 11), undoTo("10a", 10), block("11a", 11), block("12a", 12), block("13a", 13)},
+			expectSent: []string{"data:13a"},
+		},
+		{
+			name:       "orphaned cursor",
+			cursor:     testCursor(bstream.StepNew, "12a", 12, "10a", 10),
+			events:     []event{block("11a", 11), block("12b", 12), block("13b", 13)},
+			expectSent: []string{"undo:10a", "data:11a", "data:12b", "data:13b"},
+		},
+		{
+			name:       "orphaned cursor with skipped block number",
+			cursor:     testCursor(bstream.StepNew, "12a", 12, "10a", 10),
+			events:     []event{block("11a", 11), block("13b", 13)},
+			expectSent: []string{"undo:10a", "data:11a", "data:13b"},
+		},
+		{
+			name:       "undo cursor",
+			cursor:     testCursor(bstream.StepUndo, "12a", 12, "10a", 10),
+			events:     []event{block("11a", 11), block("12a", 12)},
+			expectSent: []string{"undo:10a", "data:11a", "data:12a"},
+		},
+		{
+			name:       "final cursor",
+			cursor:     testCursor(bstream.StepIrreversible, "12a", 12, "12a", 12),
+			events:     []event{block("13a", 13), block("14a", 14)},
+			expectSent: []string{"data:13a", "data:14a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent []string
+			respFunc := func(resp *pbsubstreams.Response) error {
+				switch msg := resp.Message.(type) {
+				case *pbsubstreams.Response_Data:
+					sent = append(sent, "data:"+msg.Data.Clock.Id)
+				case *pbsubstreams.Response_UndoSignal:
+					sent = append(sent, "undo:"+msg.UndoSignal.LastValidBlock.Id)
+
+					cursor, err := bstream.CursorFromOpaque(msg.UndoSignal.LastValidCursor)
+					require.NoError(t, err)
+					assert.Equal(t, msg.UndoSignal.LastValidBlock.Number, cursor.Block.Num())
+				default:
+					t.Fatalf("unexpected response %T", resp.Message)
+				}
+				return nil
+			}
+
+			ctx := context.Background()
+			tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+			p := New(ctx, tracer, &pbsubstreams.Request{StartBlockNum: 5}, nil, "", nil, 10, nil, 0, respFunc, WithStartCursor(test.cursor))
+			_, span := tracer.Start(ctx, "test")
+
+			for _, ev := range test.events {
+				cursor := testCursor(bstream.StepNew, ev.blockID, ev.blockNum, test.cursor.LIB.ID(), test.cursor.LIB.Num())
+				if ev.undo {
+					require.NoError(t, returnUndoSignal(cursor.Block, cursor, p.respFunc))
+					continue
+				}
+
+				require.GreaterOrEqual(t, ev.blockNum, p.StartBlockNum(), fmt.Sprintf("block %s", ev.blockID))
+				p.clock = &pbsubstreams.Clock{Id: ev.blockID, Number: ev.blockNum}
+				require.NoError(t, p.executeModules(ctx, span, bstream.StepNew, cursor))
+			}
+
+			assert.Equal(t, test.expectSent, sent)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/streamingfast/substreams"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/state"
+)
+
+const (
+	// debugSnapshotMaxKeys is the maximum number of keys sent for a store
+	debugSnapshotMaxKeys = 100_000
+	// debugSnapshotMaxBytes is the maximum number of key and value bytes sent for a store
+	debugSnapshotMaxBytes = 64 * 1024 * 1024
+	// debugSnapshotChunkBytes keeps each message well below the default gRPC
+	// maximum message size of 4 MiB
+	debugSnapshotChunkBytes = 1024 * 1024
+)
+
+func validateDebugStoreSnapshot(snapshot *pbsubstreams.DebugStoreSnapshotRequest, storeMap map[string]*state.Store) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	for _, modName := range snapshot.StoreModules {
+		if _, found := storeMap[modName]; !found {
+			return fmt.Errorf("debug store snapshot: store %q is not a dependency of the requested output modules", modName)
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline) shouldSendDebugStoreSnapshots(blockNum uint64) bool {
+	snapshot := p.request.DebugStoreSnapshot
+	if snapshot == nil || !p.request.DevelopmentMode || p.isSubrequest || p.debugStoreSnapshotSent {
+		return false
+	}
+	return blockNum >= snapshot.AtBlock
+}
+
+// sendDebugStoreSnapshots sends the content of the requested stores, as of the
+// end of the current block, in chunks of bounded size. Each chunk is sent
+// synchronously, so a slow client applies back pressure on the pipeline
+// instead of having the chunks pile up in memory.
+func (p *Pipeline) sendDebugStoreSnapshots() error {
+	snapshot := p.request.DebugStoreSnapshot
+
+	maxKeys := uint64(debugSnapshotMaxKeys)
+	if snapshot.MaxKeys != 0 && snapshot.MaxKeys < maxKeys {
+		maxKeys = snapshot.MaxKeys
+	}
+
+	for _, modName := range snapshot.StoreModules {
+		store := p.storeMap[modName]
+		if err := sendDebugStoreSnapshot(store, p.clock, snapshot.KeyPrefix, maxKeys, debugSnapshotMaxBytes, p.respFunc); err != nil {
+			return fmt.Errorf("sending debug snapshot of store %q: %w", modName, err)
+		}
+	}
+
+	p.debugStoreSnapshotSent = true
+	return nil
+}
+
+func sendDebugStoreSnapshot(store *state.Store, clock *pbsubstreams.Clock, keyPrefix string, maxKeys, maxBytes uint64, respFunc func(resp *pbsubstreams.Response) error) error {
+	var totalKeys uint64
+	_ = store.Iterate(keyPrefix, func(_ string, _ []byte) error {
+		totalKeys++
+		return nil
+	})
+
+	var sentKeys, sentBytes, chunkBytes uint64
+	var entries []*pbsubstreams.StoreKeyValue
+	send := func(truncated, complete bool) error {
+		msg := &pbsubstreams.DebugStoreSnapshot{
+			ModuleName: store.Name,
+			Clock:      clock,
+			Entries:    entries,
+			SentKeys:   sentKeys,
+			TotalKeys:  totalKeys,
+			Truncated:  truncated,
+			Complete:   complete,
+		}
+		entries = nil
+		chunkBytes = 0
+		return respFunc(substreams.NewDebugStoreSnapshotResponse(msg))
+	}
+
+	truncated := false
+	err := store.Iterate(keyPrefix, func(key string, value []byte) error {
+		size := uint64(len(key) + len(value))
+		if sentKeys >= maxKeys || sentBytes+size > maxBytes {
+			truncated = true
+			return state.ErrStopIteration
+		}
+
+		if chunkBytes+size > debugSnapshotChunkBytes && len(entries) != 0 {
+			if err := send(false, false); err != nil {
+				return err
+			}
+		}
+
+		entries = append(entries, &pbsubstreams.StoreKeyValue{Key: key, Value: value})
+		sentKeys++
+		sentBytes += size
+		chunkBytes += size
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return send(truncated, true)
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSendDebugStoreSnapshot(t *testing.T) {
+	largeValue := make([]byte, debugSnapshotChunkBytes/2)
+
+	tests := []struct {
+		name            string
+		kv              map[string][]byte
+		keyPrefix       string
+		maxKeys         uint64
+		maxBytes        uint64
+		expectChunks    [][]string
+		expectTotalKeys uint64
+		expectTruncated bool
+	}{
+		{
+			name:            "empty store",
+			kv:              map[string][]byte{},
+			maxKeys:         10,
+			maxBytes:        1024,
+			expectChunks:    [][]string{nil},
+			expectTotalKeys: 0,
+		},
+		{
+			name:            "sorted and filtered by prefix",
+			kv:              map[string][]byte{"b:2": {0x01}, "a:1": {0x01}, "b:1": {0x01}},
+			keyPrefix:       "b:",
+			maxKeys:         10,
+			maxBytes:        1024,
+			expectChunks:    [][]string{{"b:1", "b:2"}},
+			expectTotalKeys: 2,
+		},
+		{
+			name:            "truncated by key count",
+			kv:              map[string][]byte{"a": {0x01}, "b": {0x01}, "c": {0x01}},
+			maxKeys:         2,
+			maxBytes:        1024,
+			expectChunks:    [][]string{{"a", "b"}},
+			expectTotalKeys: 3,
+			expectTruncated: true,
+		},
+		{
+			name:            "truncated by size",
+			kv:              map[string][]byte{"a": {0x01, 0x02}, "b": {0x01, 0x02}},
+			maxKeys:         10,
+			maxBytes:        4,
+			expectChunks:    [][]string{{"a"}},
+			expectTotalKeys: 2,
+			expectTruncated: true,
+		},
+		{
+			name:            "split in chunks",
+			kv:              map[string][]byte{"a": largeValue, "b": largeValue, "c": largeValue},
+			maxKeys:         10,
+			maxBytes:        debugSnapshotMaxBytes,
+			expectChunks:    [][]string{{"a"}, {"b"}, {"c"}},
+			expectTotalKeys: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, err := state.NewStore("store_a", 10, 0, "hash_a", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), zap.NewNop())
+			require.NoError(t, err)
+			store.KV = test.kv
+
+			var snapshots []*pbsubstreams.DebugStoreSnapshot
+			err = sendDebugStoreSnapshot(store, &pbsubstreams.Clock{Number: 5}, test.keyPrefix, test.maxKeys, test.maxBytes, func(resp *pbsubstreams.Response) error {
+				snapshots = append(snapshots, resp.GetDebugStoreSnapshot())
+				return nil
+			})
+			require.NoError(t, err)
+
+			var chunks [][]string
+			for i, snapshot := range snapshots {
+				var keys []string
+				for _, entry := range snapshot.Entries {
+					keys = append(keys, entry.Key)
+				}
+				chunks = append(chunks, keys)
+
+				last := i == len(snapshots)-1
+				assert.Equal(t, "store_a", snapshot.ModuleName)
+				assert.Equal(t, uint64(5), snapshot.Clock.Number)
+				assert.Equal(t, test.expectTotalKeys, snapshot.TotalKeys)
+				assert.Equal(t, last, snapshot.Complete, fmt.Sprintf("chunk %d", i))
+				assert.Equal(t, last && test.expectTruncated, snapshot.Truncated, fmt.Sprintf("chunk %d", i))
+			}
+			assert.Equal(t, test.expectChunks, chunks)
+		})
+	}
+}
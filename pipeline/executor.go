@@ -3,8 +3,10 @@ package pipeline
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/streamingfast/substreams/block"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/pipeline/outputs"
 	"github.com/streamingfast/substreams/state"
@@ -17,8 +19,14 @@ import (
 )
 
 type ErrorExecutor struct {
-	message    string
-	stackTrace []string
+	message     string
+	stackTrace  []string
+	panicDetail *pbsubstreams.PanicDetail // nil when the module did not panic
+
+	// configuredLimit is set when the module hit a limit configured on the
+	// server, like its fuel or memory limit: the failure is deterministic for
+	// that limit only, so it is never cached.
+	configuredLimit bool
 }
 
 func (e *ErrorExecutor) Error() string {
@@ -38,6 +46,28 @@ func (e *ErrorExecutor) Error() string {
 	return b.String()
 }
 
+// PanicDetail returns the details of the panic of the module that failed with
+// `err`, nil when it did not fail because of a panic.
+func PanicDetail(err error) *pbsubstreams.PanicDetail {
+	var errExecutor *ErrorExecutor
+	if !errors.As(err, &errExecutor) {
+		return nil
+	}
+	return errExecutor.panicDetail
+}
+
+func newPanicDetail(panicErr *wasm.PanicError, blockNum uint64, executionStack []string) *pbsubstreams.PanicDetail {
+	return &pbsubstreams.PanicDetail{
+		Module:         panicErr.ModuleName(),
+		BlockNum:       blockNum,
+		Message:        panicErr.Message(),
+		Filename:       panicErr.Filename(),
+		LineNumber:     uint32(panicErr.LineNumber()),
+		ColumnNumber:   uint32(panicErr.ColumnNumber()),
+		ExecutionStack: executionStack,
+	}
+}
+
 type ModuleExecutor interface {
 	// Name returns the name of the module as defined in the manifest.
 	Name() string
@@ -50,19 +80,83 @@ type ModuleExecutor interface {
 
 	run(ctx context.Context, vals map[string][]byte, clock *pbsubstreams.Clock, cursor string) error
 
-	moduleLogs() (logs []string, truncated bool)
+	moduleLogs() executionLogs
 	moduleOutputData() pbsubstreams.ModuleOutputData
+	moduleOutputTruncated() bool
+	outputFromCache() bool
 	getCurrentExecutionStack() []string
 }
 
+// executionLogs are the logs of the last execution of a module.
+type executionLogs struct {
+	logs         []string
+	levels       []pbsubstreams.LogLevel
+	truncated    bool
+	droppedLogs  uint64 // once truncated
+	droppedBytes uint64
+}
+
+func newExecutionLogs(instance *wasm.Instance) executionLogs {
+	if instance == nil {
+		return executionLogs{}
+	}
+	return executionLogs{
+		logs:         instance.Logs,
+		levels:       instance.LogLevels,
+		truncated:    instance.ReachedLogsMaxByteCount(),
+		droppedLogs:  instance.DroppedLogs,
+		droppedBytes: instance.DroppedLogsByteCount,
+	}
+}
+
+// setOn sets the logs of `out`, returned for convenience.
+func (l executionLogs) setOn(out *pbsubstreams.ModuleOutput) *pbsubstreams.ModuleOutput {
+	out.Logs = l.logs
+	out.LogLevels = l.levels
+	out.LogsTruncated = l.truncated
+	out.DroppedLogs = l.droppedLogs
+	out.DroppedLogsBytes = l.droppedBytes
+	return out
+}
+
 type BaseExecutor struct {
 	moduleName string
-	wasmModule *wasm.Module
+	wasmModule *wasm.Module   // held between the execution of a block and the next Reset when pooled
+	instance   *wasm.Instance // of the last execution, until the next Reset
 	wasmInputs []*wasm.Input
 	cache      *outputs.OutputCache
 	isOutput   bool // whether output is enabled for this module
 	entrypoint string
 	tracer     ttrace.Tracer
+
+	blockFilter *blockFilter // nil when the module runs on every block
+
+	maxMemorySize uint64 // of the wasm instance, 0 means no limit
+	fuelLimit     uint64 // per execution, 0 means no limit
+
+	cacheHit bool // whether the output of the last execution came from the cache
+
+	// When set, the wasm module is checked out of the pool for each
+	// execution instead of being owned by the executor.
+	wasmPool *wasm.ModulePool
+	poolKey  string // module hash and memory limit, the compiled code depends on both
+	wasmCode []byte
+	request  *pbsubstreams.Request
+}
+
+// Reset drops the wasm instance of the last execution, returning its module to
+// the pool when it came from it.
+func (e *BaseExecutor) Reset() {
+	e.instance = nil
+	if e.wasmModule == nil || e.wasmPool == nil {
+		return
+	}
+	e.wasmPool.Return(e.wasmModule)
+	e.wasmModule = nil
+}
+
+func (e *BaseExecutor) currentInstance() *wasm.Instance {
+	return e.instance
 }
 
 var _ ModuleExecutor = (*MapperModuleExecutor)(nil)
@@ -71,6 +165,14 @@ type MapperModuleExecutor struct {
 	BaseExecutor
 	outputType   string
 	mapperOutput []byte
+
+	maxOutputSize           uint64 // 0 means no limit
+	truncateOversizedOutput bool
+	outputTruncated         bool
+	// Set when other modules of the request take the output as input: it is
+	// then never truncated, their outputs and stores would be computed from
+	// an input only existing for this request, and cached.
+	hasDependents bool
 }
 
 var _ ModuleExecutor = (*StoreModuleExecutor)(nil)
@@ -98,24 +200,106 @@ func (e *StoreModuleExecutor) String() string {
 	return e.moduleName
 }
 
+// startSpan starts the span of the execution of the module for the block of
+// `clock`, as a child of the span of the block.
+func (e *BaseExecutor) startSpan(ctx context.Context, spanName string, clock *pbsubstreams.Clock) (context.Context, ttrace.Span) {
+	return childTracer(ctx, e.tracer).Start(ctx, spanName, ttrace.WithAttributes(
+		attribute.String("module", e.moduleName),
+		attribute.Int64("block_num", int64(clock.Number)),
+	))
+}
+
+func (e *BaseExecutor) outputFromCache() bool {
+	return e.cacheHit
+}
+
+// cachedFailure returns the deterministic failure of the module recorded in
+// the cache for the block of `clock`, as if the module had just failed again.
+func (e *BaseExecutor) cachedFailure(clock *pbsubstreams.Clock) error {
+	failure, found := e.cache.GetFailure(clock)
+	if !found {
+		return nil
+	}
+
+	errExecutor := ErrorExecutor{
+		message:     failure.Message,
+		stackTrace:  failure.StackTrace,
+		panicDetail: failure.Panic,
+	}
+	return fmt.Errorf("block %d: module %q: wasm execution failed (cached failure): %w", clock.Number, e.moduleName, &errExecutor)
+}
+
+// cacheFailure records `err` in the cache when it is a deterministic failure
+// of the module, so that it is not executed again on that block. A failure
+// caused by a limit configured on the server is not recorded, the same block
+// can succeed under another configuration.
+func (e *BaseExecutor) cacheFailure(ctx context.Context, clock *pbsubstreams.Clock, cursor string, err error) {
+	var errExecutor *ErrorExecutor
+	if !errors.As(err, &errExecutor) || errExecutor.configuredLimit {
+		return
+	}
+
+	e.cache.SaveFailure(ctx, clock, cursor, &outputs.CacheFailure{
+		Message:    errExecutor.message,
+		StackTrace: errExecutor.stackTrace,
+		Panic:      errExecutor.panicDetail,
+	})
+}
+
+// inputsSize returns the number of bytes of streamed inputs passed to the
+// last execution of the module.
+func (e *BaseExecutor) inputsSize() (size int) {
+	for _, input := range e.wasmInputs {
+		size += len(input.StreamData)
+	}
+	return
+}
+
 func (e *MapperModuleExecutor) run(ctx context.Context, vals map[string][]byte, clock *pbsubstreams.Clock, cursor string) error {
-	ctx, span := e.tracer.Start(ctx, "exec_map")
-	span.SetAttributes(attribute.String("module", e.moduleName))
+	ctx, span := e.startSpan(ctx, "exec_map", clock)
 	defer span.End()
 
+	// Logs are only reported for an actual execution of the module, a cache
+	// hit or a skipped execution must not carry the logs of a previous block.
+	e.Reset()
+	e.outputTruncated = false
+
+	if err := e.cachedFailure(clock); err != nil {
+		e.cacheHit = true
+		span.SetAttributes(attribute.Bool("cache_hit", true))
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
 	output, found := e.cache.Get(clock)
+	e.cacheHit = found
 	if found {
+		// Modules depending on this one might not be cached, they still
+		// need the output as their input.
+		vals[e.moduleName] = output
 		e.mapperOutput = output
+		span.SetAttributes(attribute.Bool("cache_hit", true), attribute.Int("output_bytes", len(output)))
 		span.SetStatus(codes.Ok, "cache_hit")
 		return nil
 	}
+	span.SetAttributes(attribute.Bool("cache_hit", false))
 
 	if err := e.wasmMapCall(ctx, vals, clock); err != nil {
 		span.SetStatus(codes.Error, err.Error())
+		e.cacheFailure(ctx, clock, cursor, err)
 		return err
 	}
+	span.SetAttributes(attribute.Int("input_bytes", e.inputsSize()), attribute.Int("output_bytes", len(e.mapperOutput)))
+
+	if e.outputTruncated {
+		// A truncated output depends on the request, it is not cached so that
+		// other requests still get the real output or the failure.
+		span.SetStatus(codes.Ok, "module_executed_output_truncated")
+		return nil
+	}
 
 	if err := e.cache.Set(clock, cursor, e.mapperOutput); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("setting mapper output to cache at block %d: %w", clock.Number, err)
 	}
 
@@ -124,11 +308,20 @@ func (e *MapperModuleExecutor) run(ctx context.Context, vals map[string][]byte,
 }
 
 func (e *StoreModuleExecutor) run(ctx context.Context, vals map[string][]byte, clock *pbsubstreams.Clock, cursor string) error {
-	ctx, span := e.tracer.Start(ctx, "exec_store")
-	span.SetAttributes(attribute.String("module", e.moduleName))
+	ctx, span := e.startSpan(ctx, "exec_store", clock)
 	defer span.End()
 
+	e.Reset()
+
+	if err := e.cachedFailure(clock); err != nil {
+		e.cacheHit = true
+		span.SetAttributes(attribute.Bool("cache_hit", true))
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
 	output, found := e.cache.Get(clock)
+	e.cacheHit = found
 
 	if found {
 		deltas := &pbsubstreams.StoreDeltas{}
@@ -141,11 +334,15 @@ func (e *StoreModuleExecutor) run(ctx context.Context, vals map[string][]byte, c
 		for _, delta := range deltas.Deltas {
 			e.outputStore.ApplyDelta(delta)
 		}
+		span.SetAttributes(attribute.Bool("cache_hit", true), attribute.Int("output_bytes", len(output)))
 		span.SetStatus(codes.Ok, "cache_hit")
 		return nil
 	}
+	span.SetAttributes(attribute.Bool("cache_hit", false))
 
 	if err := e.wasmStoreCall(ctx, vals, clock); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		e.cacheFailure(ctx, clock, cursor, err)
 		return err
 	}
 
@@ -157,6 +354,7 @@ func (e *StoreModuleExecutor) run(ctx context.Context, vals map[string][]byte, c
 		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("caching: marshalling delta: %w", err)
 	}
+	span.SetAttributes(attribute.Int("input_bytes", e.inputsSize()), attribute.Int("output_bytes", len(data)))
 	if err = e.cache.Set(clock, cursor, data); err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("setting delta to cache at block %d: %w", clock.Number, err)
@@ -174,13 +372,24 @@ func (e *MapperModuleExecutor) wasmMapCall(ctx context.Context, vals map[string]
 	}
 
 	name := e.moduleName
-	if vm != nil {
+	if vm != nil && !vm.Skipped() {
 		out := vm.Output()
+		if e.maxOutputSize != 0 && uint64(len(out)) > e.maxOutputSize {
+			if !e.truncateOversizedOutput {
+				return fmt.Errorf("block %d: module %q: output size of %d bytes exceeds the limit of %d bytes", clock.Number, name, len(out), e.maxOutputSize)
+			}
+			if e.hasDependents {
+				return fmt.Errorf("block %d: module %q: output size of %d bytes exceeds the limit of %d bytes, not truncated as other modules depend on it", clock.Number, name, len(out), e.maxOutputSize)
+			}
+			out = []byte{}
+			e.outputTruncated = true
+		}
 		vals[name] = out
 		e.mapperOutput = out
 
 	} else {
-		// This means wasm execution was skipped because all inputs were empty.
+		// This means wasm execution was skipped because all inputs were empty,
+		// or the module skipped the block itself.
 		vals[name] = nil
 		e.mapperOutput = nil
 	}
@@ -207,45 +416,105 @@ func (e *BaseExecutor) wasmCall(ctx context.Context, vals map[string][]byte, clo
 			} else {
 				input.StreamData = nil
 			}
+		case wasm.InputStoreDeltas:
+			// The upstream store already ran for this block, either
+			// executed or fed from its cached deltas.
+			input.StreamData = nil
+			if deltas := input.Store.Deltas; len(deltas) != 0 {
+				data, err := proto.Marshal(&pbsubstreams.StoreDeltas{Deltas: deltas})
+				if err != nil {
+					return nil, fmt.Errorf("block %d: module %q: marshalling deltas of store %q: %w", clock.Number, e.moduleName, input.Name, err)
+				}
+				input.StreamData = data
+				hasInput = true
+			}
 		case wasm.InputStore:
 			hasInput = true
 		case wasm.OutputStore:
 
 		default:
-			panic(fmt.Sprintf("Invalid input type %d", input.Type))
+			return nil, fmt.Errorf("block %d: module %q: input %q: invalid input type %d", clock.Number, e.moduleName, input.Name, input.Type)
 		}
 	}
 
+	if hasInput && e.blockFilter != nil {
+		matches, err := e.blockFilter.matches(vals)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: module %q: block filter: %w", clock.Number, e.moduleName, err)
+		}
+		// Blocks filtered out are handled like blocks without input
+		hasInput = matches
+	}
+
 	// This allows us to skip the execution of the VM if there are no inputs.
 	// This assumption should either be configurable by the manifest, or clearly documented:
 	//  state builders will not be called if their input streams are 0 bytes length (and there'e no
 	//  state store in read mode)
 	if hasInput {
+		if e.wasmPool != nil {
+			e.Reset()
+			if e.wasmModule, err = e.wasmPool.Checkout(ctx, e.request, e.poolKey, e.wasmCode, e.moduleName, e.entrypoint); err != nil {
+				return nil, fmt.Errorf("checking out wasm module: %w", err)
+			}
+		}
+
+		e.wasmModule.SetFuelLimit(e.fuelLimit)
+		e.wasmModule.SetMaxMemorySize(e.maxMemorySize)
 		instance, err = e.wasmModule.NewInstance(clock, e.wasmInputs)
 		if err != nil {
 			return nil, fmt.Errorf("new wasm instance: %w", err)
 		}
-
-		if err = instance.Execute(); err != nil {
+		e.instance = instance
+		defer func(instance *wasm.Instance) {
+			// The output and the logs are Go copies, the guest memory is not
+			// needed anymore
+			if closeErr := instance.Close(ctx); closeErr != nil && err == nil {
+				err = fmt.Errorf("block %d: module %q: closing wasm instance: %w", clock.Number, e.moduleName, closeErr)
+			}
+		}(instance)
+
+		err = instance.Execute(ctx)
+		fuelConsumed := e.wasmModule.FuelConsumed()
+		observeWasmFuelConsumed(e.moduleName, fuelConsumed)
+		ttrace.SpanFromContext(ctx).SetAttributes(attribute.Int64("wasm_fuel_consumed", int64(fuelConsumed)))
+		var interrupted *wasm.InterruptedError
+		if errors.As(err, &interrupted) {
+			// Not deterministic, never cached
+			return nil, fmt.Errorf("block %d: module %q: %w", clock.Number, e.moduleName, err)
+		}
+		if err != nil {
 			errExecutor := ErrorExecutor{
 				message:    err.Error(),
 				stackTrace: instance.ExecutionStack,
 			}
-			return nil, fmt.Errorf("block %d: module %q: wasm execution failed: %v", clock.Number, e.moduleName, errExecutor.Error())
+			var panicErr *wasm.PanicError
+			if errors.As(err, &panicErr) {
+				errExecutor.panicDetail = newPanicDetail(panicErr, clock.Number, instance.ExecutionStack)
+			}
+			var budgetErr *wasm.ExecutionBudgetExceededError
+			var memoryErr *wasm.MemoryLimitError
+			errExecutor.configuredLimit = errors.As(err, &budgetErr) || errors.As(err, &memoryErr)
+			return nil, fmt.Errorf("block %d: module %q: wasm execution failed: %w", clock.Number, e.moduleName, &errExecutor)
 		}
-		err = instance.Module.Heap.Clear()
-		if err != nil {
-			return nil, fmt.Errorf("block %d: module %q: wasm heap clear failed: %w", clock.Number, e.moduleName, err)
+
+		memorySize := e.wasmModule.MemorySize()
+		observeWasmMemorySize(e.moduleName, memorySize)
+		if e.maxMemorySize != 0 && memorySize > e.maxMemorySize {
+			// Memory growth only depends on the inputs, but the limit is
+			// server configuration.
+			errExecutor := ErrorExecutor{
+				message:         fmt.Sprintf("wasm memory size of %d bytes exceeds the limit of %d bytes", memorySize, e.maxMemorySize),
+				stackTrace:      instance.ExecutionStack,
+				configuredLimit: true,
+			}
+			return nil, fmt.Errorf("block %d: module %q: wasm execution failed: %w", clock.Number, e.moduleName, &errExecutor)
 		}
 	}
 	return
 }
 
-func (e *StoreModuleExecutor) moduleLogs() (logs []string, truncated bool) {
-	if instance := e.wasmModule.CurrentInstance; instance != nil {
-		return instance.Logs, instance.ReachedLogsMaxByteCount()
-	}
-	return
+func (e *StoreModuleExecutor) moduleLogs() executionLogs {
+	return newExecutionLogs(e.currentInstance())
 }
 
 func (e *StoreModuleExecutor) moduleOutputData() pbsubstreams.ModuleOutputData {
@@ -257,8 +526,15 @@ func (e *StoreModuleExecutor) moduleOutputData() pbsubstreams.ModuleOutputData {
 	return nil
 }
 
+func (e *StoreModuleExecutor) moduleOutputTruncated() bool {
+	return false
+}
+
 func (e *StoreModuleExecutor) getCurrentExecutionStack() []string {
-	return e.wasmModule.CurrentInstance.ExecutionStack
+	if instance := e.currentInstance(); instance != nil {
+		return instance.ExecutionStack
+	}
+	return nil
 }
 
 // func (e *StoreModuleExecutor) appendOutput(moduleOutputs []*pbsubstreams.ModuleOutput) []*pbsubstreams.ModuleOutput {
@@ -288,15 +564,8 @@ func (e *StoreModuleExecutor) getCurrentExecutionStack() []string {
 // 	return moduleOutputs
 // }
 
-func (e *StoreModuleExecutor) Reset() { e.wasmModule.CurrentInstance = nil }
-
-func (e *MapperModuleExecutor) Reset() { e.wasmModule.CurrentInstance = nil }
-
-func (e *MapperModuleExecutor) moduleLogs() (logs []string, truncated bool) {
-	if instance := e.wasmModule.CurrentInstance; instance != nil {
-		return instance.Logs, instance.ReachedLogsMaxByteCount()
-	}
-	return
+func (e *MapperModuleExecutor) moduleLogs() executionLogs {
+	return newExecutionLogs(e.currentInstance())
 }
 
 func (e *MapperModuleExecutor) moduleOutputData() pbsubstreams.ModuleOutputData {
@@ -308,8 +577,15 @@ func (e *MapperModuleExecutor) moduleOutputData() pbsubstreams.ModuleOutputData
 	return nil
 }
 
+func (e *MapperModuleExecutor) moduleOutputTruncated() bool {
+	return e.outputTruncated
+}
+
 func (e *MapperModuleExecutor) getCurrentExecutionStack() []string {
-	return e.wasmModule.CurrentInstance.ExecutionStack
+	if instance := e.currentInstance(); instance != nil {
+		return instance.ExecutionStack
+	}
+	return nil
 }
 
 // func (e *MapperModuleExecutor) appendOutput(moduleOutputs []*pbsubstreams.ModuleOutput) []*pbsubstreams.ModuleOutput {
@@ -339,7 +615,32 @@ func (e *MapperModuleExecutor) getCurrentExecutionStack() []string {
 // 	return moduleOutputs
 // }
 
-func OptimizeExecutors(moduleOutputCache map[string]*outputs.OutputCache, moduleExecutors []ModuleExecutor, requestedOutputStores []string) (optimizedModuleExecutors []ModuleExecutor, skipBlockSource bool) {
+// OptimizeExecutors returns the executors that still need the block source to
+// run over the output cache ranges currently loaded. An executor is served by
+// its cache only when its current range was loaded from a saved cache file
+// and matches the range of the other executors, which modules with
+// different save intervals rarely share. When no executor needs the block
+// source, `skipBlockSource` is true.
+func OptimizeExecutors(moduleOutputCache map[string]*outputs.OutputCache, moduleExecutors []ModuleExecutor) (optimizedModuleExecutors []ModuleExecutor, skipBlockSource bool) {
+	if len(moduleExecutors) == 0 {
+		return nil, false
+	}
+
+	var referenceRange *block.Range
+	for _, executor := range moduleExecutors {
+		cache, found := moduleOutputCache[executor.Name()]
+		if !found || !cache.IsLoaded() {
+			optimizedModuleExecutors = append(optimizedModuleExecutors, executor)
+			continue
+		}
+
+		if referenceRange == nil {
+			referenceRange = cache.CurrentBlockRange
+		}
+		if !cache.CurrentBlockRange.Equals(referenceRange) {
+			optimizedModuleExecutors = append(optimizedModuleExecutors, executor)
+		}
+	}
 
-	return nil, false
+	return optimizedModuleExecutors, len(optimizedModuleExecutors) == 0
 }
@@ -0,0 +1,750 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/client"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/state"
+	"github.com/streamingfast/substreams/wasm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// echoWAT outputs the bytes of its single input.
+const echoWAT = `
+(module
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (i32.add (i32.load (i32.const 0)) (i32.const 1024)))
+    (i32.store (i32.const 0) (i32.add (i32.load (i32.const 0)) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_echo") (param $ptr i32) (param $len i32)
+    (call $output (local.get $ptr) (local.get $len)))
+)
+`
+
+// logWAT logs its single input and outputs nothing.
+const logWAT = `
+(module
+  (import "logger" "println" (func $println (param i32 i32)))
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (i32.add (i32.load (i32.const 0)) (i32.const 1024)))
+    (i32.store (i32.const 0) (i32.add (i32.load (i32.const 0)) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_log") (param $ptr i32) (param $len i32)
+    (call $println (local.get $ptr) (local.get $len)))
+)
+`
+
+func TestMapperConsumingStoreDeltas(t *testing.T) {
+	ctx := context.Background()
+	tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+
+	store, err := state.NewStore("store_a", 10, 0, "hash_a", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), zap.NewNop())
+	require.NoError(t, err)
+
+	storeCache := outputs.NewOutputCache("store_a", dstore.NewMockStore(nil), 10, zap.NewNop())
+	_, err = storeCache.LoadAtBlock(ctx, 0)
+	require.NoError(t, err)
+
+	storeExecutor := &StoreModuleExecutor{
+		BaseExecutor: BaseExecutor{moduleName: "store_a", wasmModule: &wasm.Module{}, cache: storeCache, tracer: tracer},
+		outputStore:  store,
+	}
+
+	code, err := wasmtime.Wat2Wasm(echoWAT)
+	require.NoError(t, err)
+	wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_echo")
+	require.NoError(t, err)
+
+	mapperCache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+	_, err = mapperCache.LoadAtBlock(ctx, 0)
+	require.NoError(t, err)
+
+	mapperExecutor := &MapperModuleExecutor{
+		BaseExecutor: BaseExecutor{
+			moduleName: "map_b",
+			wasmModule: wasmModule,
+			wasmInputs: []*wasm.Input{{Type: wasm.InputStoreDeltas, Name: "store_a", Store: store}},
+			cache:      mapperCache,
+			tracer:     tracer,
+		},
+	}
+
+	// Block 1, deltas fed from the store output cache
+	clock := &pbsubstreams.Clock{Id: "1a", Number: 1}
+	deltas := &pbsubstreams.StoreDeltas{Deltas: []*pbsubstreams.StoreDelta{
+		{Operation: pbsubstreams.StoreDelta_CREATE, Key: "key_1", NewValue: []byte("value_1")},
+	}}
+	cachedDeltas, err := proto.Marshal(deltas)
+	require.NoError(t, err)
+	require.NoError(t, storeCache.Set(clock, "", cachedDeltas))
+
+	vals := map[string][]byte{}
+	require.NoError(t, storeExecutor.run(ctx, vals, clock, ""))
+	require.NoError(t, mapperExecutor.run(ctx, vals, clock, ""))
+
+	received := &pbsubstreams.StoreDeltas{}
+	require.NoError(t, proto.Unmarshal(mapperExecutor.mapperOutput, received))
+	assert.True(t, proto.Equal(deltas, received))
+	store.Flush()
+
+	// Block 2, no deltas, the mapper is not executed
+	clock = &pbsubstreams.Clock{Id: "2a", Number: 2}
+	require.NoError(t, storeCache.Set(clock, "", nil))
+
+	require.NoError(t, storeExecutor.run(ctx, vals, clock, ""))
+	require.NoError(t, mapperExecutor.run(ctx, vals, clock, ""))
+	assert.Nil(t, mapperExecutor.mapperOutput)
+}
+
+func TestMapperOutputSizeLimit(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxOutputSize  uint64
+		truncate       bool
+		hasDependents  bool
+		expectOutput   []byte
+		expectTruncate bool
+		expectError    string
+	}{
+		{
+			name:          "under limit",
+			maxOutputSize: 8,
+			expectOutput:  []byte("12345678"),
+		},
+		{
+			name:          "no limit",
+			maxOutputSize: 0,
+			expectOutput:  []byte("12345678"),
+		},
+		{
+			name:          "over limit fails",
+			maxOutputSize: 4,
+			expectError:   `block 1: module "map_b": output size of 8 bytes exceeds the limit of 4 bytes`,
+		},
+		{
+			name:          "over limit with dependents fails",
+			maxOutputSize: 4,
+			truncate:      true,
+			hasDependents: true,
+			expectError:   `block 1: module "map_b": output size of 8 bytes exceeds the limit of 4 bytes, not truncated as other modules depend on it`,
+		},
+		{
+			name:           "over limit truncated",
+			maxOutputSize:  4,
+			truncate:       true,
+			expectOutput:   []byte{},
+			expectTruncate: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			code, err := wasmtime.Wat2Wasm(echoWAT)
+			require.NoError(t, err)
+			wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_echo")
+			require.NoError(t, err)
+
+			cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+			_, err = cache.LoadAtBlock(ctx, 0)
+			require.NoError(t, err)
+
+			executor := &MapperModuleExecutor{
+				BaseExecutor: BaseExecutor{
+					moduleName: "map_b",
+					wasmModule: wasmModule,
+					wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+					cache:      cache,
+					tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+				},
+				maxOutputSize:           test.maxOutputSize,
+				truncateOversizedOutput: test.truncate,
+				hasDependents:           test.hasDependents,
+			}
+
+			clock := &pbsubstreams.Clock{Id: "1a", Number: 1}
+			vals := map[string][]byte{"map_a": []byte("12345678")}
+			err = executor.run(ctx, vals, clock, "")
+			if test.expectError != "" {
+				require.EqualError(t, err, test.expectError)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectOutput, executor.mapperOutput)
+			assert.Equal(t, test.expectOutput, vals["map_b"])
+			assert.Equal(t, test.expectTruncate, executor.moduleOutputTruncated())
+
+			_, cached := cache.Get(clock)
+			assert.Equal(t, !test.expectTruncate, cached, "truncated outputs must not be cached")
+		})
+	}
+}
+
+func TestRunExecutor_IntermediateModuleLogs(t *testing.T) {
+	tests := []struct {
+		name            string
+		developmentMode bool
+		expectOutputs   []*pbsubstreams.ModuleOutput
+	}{
+		{
+			name:            "development mode",
+			developmentMode: true,
+			expectOutputs:   []*pbsubstreams.ModuleOutput{{Name: "map_b", Logs: []string{"hello"}, LogLevels: []pbsubstreams.LogLevel{pbsubstreams.LogLevel_LOG_LEVEL_INFO}}},
+		},
+		{
+			name: "production mode",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+
+			code, err := wasmtime.Wat2Wasm(logWAT)
+			require.NoError(t, err)
+			wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_log")
+			require.NoError(t, err)
+
+			cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+			_, err = cache.LoadAtBlock(ctx, 0)
+			require.NoError(t, err)
+
+			executor := &MapperModuleExecutor{
+				BaseExecutor: BaseExecutor{
+					moduleName: "map_b",
+					wasmModule: wasmModule,
+					wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+					cache:      cache,
+					tracer:     tracer,
+				},
+			}
+
+			p := &Pipeline{
+				request:         &pbsubstreams.Request{DevelopmentMode: test.developmentMode, OutputModules: []string{"map_c"}},
+				outputModuleMap: map[string]bool{"map_c": true},
+				forkHandler:     NewForkHandle(),
+				logger:          zap.NewNop(),
+				clock:           &pbsubstreams.Clock{Id: "1a", Number: 1},
+				wasmOutputs:     map[string][]byte{"map_a": []byte("hello")},
+			}
+			require.NoError(t, p.runExecutor(ctx, executor, ""))
+			assert.Equal(t, test.expectOutputs, p.moduleOutputs)
+
+			// The output of block 2 is cached, no logs from block 1 must be reported
+			p.moduleOutputs = nil
+			p.clock = &pbsubstreams.Clock{Id: "2a", Number: 2}
+			require.NoError(t, cache.Set(p.clock, "", []byte{}))
+			executor.instance, err = wasmModule.NewInstance(p.clock, executor.wasmInputs)
+			require.NoError(t, err)
+			executor.instance.Logs = []string{"stale"}
+
+			require.NoError(t, p.runExecutor(ctx, executor, ""))
+			assert.Nil(t, p.moduleOutputs)
+		})
+	}
+}
+
+func TestRunExecutor_PooledWasmModule(t *testing.T) {
+	ctx := context.Background()
+
+	code, err := wasmtime.Wat2Wasm(logWAT)
+	require.NoError(t, err)
+	pool := wasm.NewModulePool(wasm.NewRuntime(nil), 1)
+
+	cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+	_, err = cache.LoadAtBlock(ctx, 0)
+	require.NoError(t, err)
+
+	executor := &MapperModuleExecutor{
+		BaseExecutor: BaseExecutor{
+			moduleName: "map_b",
+			entrypoint: "map_log",
+			wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+			cache:      cache,
+			tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+			wasmPool:   pool,
+			poolKey:    "hash_b",
+			wasmCode:   code,
+			request:    &pbsubstreams.Request{},
+		},
+	}
+
+	p := &Pipeline{
+		request:         &pbsubstreams.Request{DevelopmentMode: true, OutputModules: []string{"map_c"}},
+		outputModuleMap: map[string]bool{"map_c": true},
+		forkHandler:     NewForkHandle(),
+		logger:          zap.NewNop(),
+		wasmOutputs:     map[string][]byte{"map_a": []byte("hello")},
+	}
+
+	for num := uint64(1); num <= 2; num++ {
+		p.moduleOutputs = nil
+		p.clock = &pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}
+		require.NoError(t, p.runExecutor(ctx, executor, ""))
+		assert.Equal(t, []*pbsubstreams.ModuleOutput{{Name: "map_b", Logs: []string{"hello"}, LogLevels: []pbsubstreams.LogLevel{pbsubstreams.LogLevel_LOG_LEVEL_INFO}}}, p.moduleOutputs)
+
+		// The module goes back to the pool once its logs are reported
+		assert.Nil(t, executor.wasmModule)
+		assert.Equal(t, 1, pool.IdleCount("hash_b"))
+	}
+}
+
+func TestMapperCachedFailure(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	store.SetOverwrite(true)
+	clock := &pbsubstreams.Clock{Id: "12a", Number: 12}
+
+	newExecutor := func(wat, entrypoint string) *MapperModuleExecutor {
+		code, err := wasmtime.Wat2Wasm(wat)
+		require.NoError(t, err)
+		wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", entrypoint)
+		require.NoError(t, err)
+
+		cache := outputs.NewOutputCache("map_b", store, 10, zap.NewNop())
+		_, err = cache.LoadAtBlock(ctx, 10)
+		require.NoError(t, err)
+
+		return &MapperModuleExecutor{
+			BaseExecutor: BaseExecutor{
+				moduleName: "map_b",
+				wasmModule: wasmModule,
+				wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+				cache:      cache,
+				tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+			},
+		}
+	}
+	run := func(executor *MapperModuleExecutor) error {
+		return executor.run(ctx, map[string][]byte{"map_a": []byte("block")}, clock, "")
+	}
+
+	err := run(newExecutor(trapWAT, "map_trap"))
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "cached failure")
+
+	// The module that would now succeed is not executed, the failure is
+	// served from the cache saved at failure time
+	executor := newExecutor(echoWAT, "map_echo")
+	err = run(executor)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `block 12: module "map_b": wasm execution failed (cached failure)`)
+	var errExecutor *ErrorExecutor
+	assert.ErrorAs(t, err, &errExecutor)
+	assert.True(t, executor.outputFromCache())
+
+	cleared, err := outputs.ClearFailures(ctx, store, 12)
+	require.NoError(t, err)
+	assert.Equal(t, []string{outputs.ComputeSegmentFilename(10, 13, 10)}, cleared)
+
+	executor = newExecutor(echoWAT, "map_echo")
+	require.NoError(t, run(executor))
+	assert.Equal(t, []byte("block"), executor.mapperOutput)
+}
+
+// skipWAT skips the blocks whose input is "skip", and outputs the input of
+// the others.
+const skipWAT = `
+(module
+  (import "env" "output" (func $output (param i32 i32)))
+  (import "env" "skip_block" (func $skip_block))
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (i32.add (i32.load (i32.const 0)) (i32.const 1024)))
+    (i32.store (i32.const 0) (i32.add (i32.load (i32.const 0)) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_skip") (param $ptr i32) (param $len i32)
+    (if (i32.eq (local.get $len) (i32.const 4)) (then (call $skip_block) unreachable))
+    (call $output (local.get $ptr) (local.get $len)))
+)
+`
+
+func TestMapperSkipBlock(t *testing.T) {
+	ctx := context.Background()
+	code, err := wasmtime.Wat2Wasm(skipWAT)
+	require.NoError(t, err)
+	wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_skip")
+	require.NoError(t, err)
+
+	cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+	_, err = cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+
+	executor := &MapperModuleExecutor{
+		BaseExecutor: BaseExecutor{
+			moduleName: "map_b",
+			wasmModule: wasmModule,
+			wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+			cache:      cache,
+			tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+		},
+	}
+	run := func(blockNum uint64, input string) map[string][]byte {
+		vals := map[string][]byte{"map_a": []byte(input)}
+		require.NoError(t, executor.run(ctx, vals, &pbsubstreams.Clock{Id: fmt.Sprintf("%da", blockNum), Number: blockNum}, ""))
+		return vals
+	}
+
+	vals := run(12, "skip")
+	assert.Nil(t, vals["map_b"])
+	assert.Nil(t, executor.mapperOutput)
+	assert.Nil(t, executor.moduleOutputData())
+	assert.False(t, executor.outputFromCache())
+
+	// Replayed from the cache as a skip too
+	vals = run(12, "skip")
+	assert.True(t, executor.outputFromCache())
+	assert.Nil(t, vals["map_b"])
+	assert.Nil(t, executor.moduleOutputData())
+
+	// The module is still usable after skipping
+	vals = run(13, "block")
+	assert.Equal(t, []byte("block"), vals["map_b"])
+	assert.Equal(t, []byte("block"), executor.mapperOutput)
+}
+
+// growWAT grows its memory by 1 MiB on every call and outputs nothing.
+const growWAT = `
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_grow") (param $ptr i32) (param $len i32)
+    (drop (memory.grow (i32.const 16))))
+)
+`
+
+func TestMapperMaxWasmMemorySize(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxMemorySize uint64
+		expectError   string
+	}{
+		{
+			name:          "under limit",
+			maxMemorySize: 2 * 1024 * 1024,
+		},
+		{
+			name:          "no limit",
+			maxMemorySize: 0,
+		},
+		{
+			name:          "over limit fails",
+			maxMemorySize: 1024 * 1024,
+			expectError:   `block 1: module "map_grow": wasm execution failed: wasm memory size of 1179648 bytes exceeds the limit of 1048576 bytes`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			code, err := wasmtime.Wat2Wasm(growWAT)
+			require.NoError(t, err)
+			wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_grow", "map_grow")
+			require.NoError(t, err)
+
+			cache := outputs.NewOutputCache("map_grow", dstore.NewMockStore(nil), 10, zap.NewNop())
+			_, err = cache.LoadAtBlock(ctx, 0)
+			require.NoError(t, err)
+
+			executor := &MapperModuleExecutor{
+				BaseExecutor: BaseExecutor{
+					moduleName:    "map_grow",
+					wasmModule:    wasmModule,
+					wasmInputs:    []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+					cache:         cache,
+					tracer:        ttrace.NewNoopTracerProvider().Tracer("test"),
+					maxMemorySize: test.maxMemorySize,
+				},
+			}
+
+			err = executor.run(ctx, map[string][]byte{"map_a": []byte("block")}, &pbsubstreams.Clock{Id: "1a", Number: 1}, "")
+			assert.Equal(t, uint64(1179648), wasmMemoryObserved.maxSizes["map_grow"], "1 MiB grown over the initial page and the heap arena")
+			if test.expectError != "" {
+				require.EqualError(t, err, test.expectError)
+				var errExecutor *ErrorExecutor
+				assert.ErrorAs(t, err, &errExecutor, "the failure must be deterministic")
+				// The limit is server configuration, the failure is not cached
+				assert.NoError(t, executor.cachedFailure(&pbsubstreams.Clock{Id: "1a", Number: 1}))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMapperWasmFuelLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		fuelLimit   uint64
+		expectError string
+	}{
+		{
+			name: "no limit",
+		},
+		{
+			name:        "over limit fails",
+			fuelLimit:   1,
+			expectError: `block 1: module "map_b": wasm execution failed: executing module "map_b": execution budget exceeded:`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			code, err := wasmtime.Wat2Wasm(echoWAT)
+			require.NoError(t, err)
+			wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_echo")
+			require.NoError(t, err)
+
+			cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+			_, err = cache.LoadAtBlock(ctx, 0)
+			require.NoError(t, err)
+
+			executor := &MapperModuleExecutor{
+				BaseExecutor: BaseExecutor{
+					moduleName: "map_b",
+					wasmModule: wasmModule,
+					wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+					cache:      cache,
+					tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+					fuelLimit:  test.fuelLimit,
+				},
+			}
+
+			err = executor.run(ctx, map[string][]byte{"map_a": []byte("block")}, &pbsubstreams.Clock{Id: "1a", Number: 1}, "")
+			if test.expectError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectError)
+				var errExecutor *ErrorExecutor
+				assert.ErrorAs(t, err, &errExecutor, "the failure must be deterministic")
+				// The limit is server configuration, the failure is not cached
+				assert.NoError(t, executor.cachedFailure(&pbsubstreams.Clock{Id: "1a", Number: 1}))
+				return
+			}
+			require.NoError(t, err)
+			assert.NotZero(t, wasmModule.FuelConsumed())
+			assert.Equal(t, []byte("block"), executor.mapperOutput)
+		})
+	}
+}
+
+func TestMapperInterruptedExecution(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(`
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_spin") (param $ptr i32) (param $len i32)
+    (loop $forever (br $forever)))
+)`)
+	require.NoError(t, err)
+	wasmModule, err := wasm.NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "map_spin", "map_spin")
+	require.NoError(t, err)
+
+	cache := outputs.NewOutputCache("map_spin", dstore.NewMockStore(nil), 10, zap.NewNop())
+	_, err = cache.LoadAtBlock(context.Background(), 0)
+	require.NoError(t, err)
+
+	executor := &MapperModuleExecutor{
+		BaseExecutor: BaseExecutor{
+			moduleName: "map_spin",
+			wasmModule: wasmModule,
+			wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+			cache:      cache,
+			tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	clock := &pbsubstreams.Clock{Id: "1a", Number: 1}
+	err = executor.run(ctx, map[string][]byte{"map_a": []byte("block")}, clock, "")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Not deterministic, so not cached as a failure
+	var errExecutor *ErrorExecutor
+	assert.False(t, errors.As(err, &errExecutor))
+	assert.NoError(t, executor.cachedFailure(clock))
+}
+
+// panicWAT panics with "boom" at src/lib.rs:12:5.
+const panicWAT = `
+(module
+  (import "env" "register_panic" (func $register_panic (param i32 i32 i32 i32 i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "boom")
+  (data (i32.const 16) "src/lib.rs")
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_panic") (param $ptr i32) (param $len i32)
+    (call $register_panic (i32.const 0) (i32.const 4) (i32.const 16) (i32.const 10) (i32.const 12) (i32.const 5))
+    unreachable)
+)
+`
+
+func TestMapperPanicDetail(t *testing.T) {
+	ctx := context.Background()
+	code, err := wasmtime.Wat2Wasm(panicWAT)
+	require.NoError(t, err)
+	wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_panic")
+	require.NoError(t, err)
+
+	cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+	_, err = cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+
+	executor := &MapperModuleExecutor{
+		BaseExecutor: BaseExecutor{
+			moduleName: "map_b",
+			wasmModule: wasmModule,
+			wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+			cache:      cache,
+			tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+		},
+	}
+	expected := &pbsubstreams.PanicDetail{
+		Module:       "map_b",
+		BlockNum:     12,
+		Message:      "boom",
+		Filename:     "src/lib.rs",
+		LineNumber:   12,
+		ColumnNumber: 5,
+	}
+
+	// Sent to the client in the details of the status failing the stream, as
+	// the service does
+	roundTrip := func(err error) *pbsubstreams.PanicDetail {
+		detail := PanicDetail(err)
+		require.NotNil(t, detail)
+		st, err := status.Newf(codes.Internal, "unexpected termination: %s", err).WithDetails(detail)
+		require.NoError(t, err)
+		wire, err := proto.Marshal(st.Proto())
+		require.NoError(t, err)
+		received := &spb.Status{}
+		require.NoError(t, proto.Unmarshal(wire, received))
+
+		out, found := client.PanicDetail(status.FromProto(received).Err())
+		require.True(t, found)
+		return out
+	}
+
+	vals := map[string][]byte{"map_a": []byte("block")}
+	err = executor.run(ctx, vals, &pbsubstreams.Clock{Id: "12a", Number: 12}, "")
+	require.Error(t, err)
+	assert.True(t, proto.Equal(expected, roundTrip(err)))
+
+	// Replayed from the cache with the same details
+	err = executor.run(ctx, vals, &pbsubstreams.Clock{Id: "12a", Number: 12}, "")
+	require.Error(t, err)
+	assert.True(t, executor.outputFromCache())
+	assert.True(t, proto.Equal(expected, roundTrip(err)))
+
+	_, found := client.PanicDetail(fmt.Errorf("not a status"))
+	assert.False(t, found)
+}
+
+// Executions of the same module in concurrent requests each get their own
+// module and instance, run with -race.
+func TestMapper_ConcurrentExecutions(t *testing.T) {
+	ctx := context.Background()
+	code, err := wasmtime.Wat2Wasm(logWAT)
+	require.NoError(t, err)
+	pool := wasm.NewModulePool(wasm.NewRuntime(nil), 2)
+
+	newExecutor := func() *MapperModuleExecutor {
+		cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 1000, zap.NewNop())
+		_, err := cache.LoadAtBlock(ctx, 0)
+		require.NoError(t, err)
+		return &MapperModuleExecutor{
+			BaseExecutor: BaseExecutor{
+				moduleName: "map_b",
+				entrypoint: "map_log",
+				wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+				cache:      cache,
+				tracer:     ttrace.NewNoopTracerProvider().Tracer("test"),
+				wasmPool:   pool,
+				poolKey:    "hash_b",
+				wasmCode:   code,
+				request:    &pbsubstreams.Request{},
+			},
+		}
+	}
+
+	const workers = 8
+	executors := make([]*MapperModuleExecutor, workers)
+	for i := range executors {
+		executors[i] = newExecutor()
+	}
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, workers)
+	for i, executor := range executors {
+		wg.Add(1)
+		go func(worker int, executor *MapperModuleExecutor) {
+			defer wg.Done()
+			for num := uint64(1); num <= 50; num++ {
+				input := fmt.Sprintf("worker %d block %d", worker, num)
+				vals := map[string][]byte{"map_a": []byte(input)}
+				if err := executor.run(ctx, vals, &pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, ""); err != nil {
+					errs <- err
+					return
+				}
+				if logs := executor.moduleLogs().logs; len(logs) != 1 || logs[0] != input {
+					errs <- fmt.Errorf("worker %d: got logs %q, expected %q", worker, logs, input)
+					return
+				}
+				executor.Reset()
+			}
+		}(i, executor)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, pool.IdleCount("hash_b"), 2)
+}
+
+func TestWasmCallInvalidInputType(t *testing.T) {
+	executor := &BaseExecutor{
+		moduleName: "map_b",
+		wasmInputs: []*wasm.Input{{Type: wasm.InputType(42), Name: "map_a"}},
+	}
+
+	_, err := executor.wasmCall(context.Background(), map[string][]byte{"map_a": []byte("block")}, &pbsubstreams.Clock{Id: "1a", Number: 1})
+	assert.EqualError(t, err, `block 1: module "map_b": input "map_a": invalid input type 42`)
+}
@@ -2,12 +2,17 @@ package pipeline
 
 import (
 	"fmt"
+
 	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/substreams"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/pipeline/outputs"
 	"github.com/streamingfast/substreams/state"
 )
 
+// ForkHandler keeps the outputs of every executed module for the blocks that
+// are still reversible, so that they can be reverted when the source sends
+// an undo step for one of them.
 type ForkHandler struct {
 	reversibleOutputs map[uint64][]*pbsubstreams.ModuleOutput
 }
@@ -18,24 +23,36 @@ func NewForkHandle() *ForkHandler {
 	}
 }
 
+// handleUndo reverts the store deltas produced at `clock`, invalidates the
+// output cache entries of that block for every module and notifies the
+// client that everything after `lastValidBlock` must be discarded.
 func (f *ForkHandler) handleUndo(
 	clock *pbsubstreams.Clock,
 	cursor *bstream.Cursor,
+	lastValidBlock bstream.BlockRef,
 	moduleOutputCache *outputs.ModulesOutputCache,
 	storeMap map[string]*state.Store,
 	respFunc func(resp *pbsubstreams.Response) error,
 ) error {
 	if moduleOutputs, found := f.reversibleOutputs[clock.Number]; found {
-		if err := returnModuleDataOutputs(clock, bstream.StepUndo, cursor, moduleOutputs, respFunc); err != nil {
-			return fmt.Errorf("calling return func when reverting outputs: %w", err)
-		}
-		for _, moduleOutput := range moduleOutputs {
-			if outputCache, ok := moduleOutputCache.OutputCaches[moduleOutput.Name]; ok {
-				outputCache.Delete(clock.Id)
+		for i := len(moduleOutputs) - 1; i >= 0; i-- {
+			moduleOutput := moduleOutputs[i]
+			if deltas := moduleOutput.GetStoreDeltas(); deltas != nil {
+				reverseDeltas(storeMap, moduleOutput.Name, deltas)
 			}
-			reverseDeltas(storeMap, moduleOutput.Name, moduleOutput.GetStoreDeltas())
+		}
+		delete(f.reversibleOutputs, clock.Number)
+	}
+
+	if moduleOutputCache != nil {
+		for _, outputCache := range moduleOutputCache.OutputCaches {
+			outputCache.Delete(clock.Id)
 		}
 	}
+
+	if err := returnUndoSignal(lastValidBlock, cursor, respFunc); err != nil {
+		return fmt.Errorf("calling return func when reverting outputs: %w", err)
+	}
 	return nil
 }
 
@@ -56,3 +73,15 @@ func reverseDeltas(storeMap map[string]*state.Store, name string, deltaGetter De
 		store.ApplyDeltaReverse(deltaGetter.GetDeltas())
 	}
 }
+
+func returnUndoSignal(lastValidBlock bstream.BlockRef, cursor *bstream.Cursor, respFunc func(resp *pbsubstreams.Response) error) error {
+	signal := &pbsubstreams.BlockUndoSignal{
+		LastValidBlock: &pbsubstreams.BlockRef{
+			Id:     lastValidBlock.ID(),
+			Number: lastValidBlock.Num(),
+		},
+		LastValidCursor: cursor.ToOpaque(),
+	}
+
+	return respFunc(substreams.NewBlockUndoSignalResponse(signal))
+}
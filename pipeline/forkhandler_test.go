@@ -1,10 +1,18 @@
 package pipeline
 
 import (
+	"context"
+	"testing"
+
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
 	"github.com/streamingfast/substreams/state"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"testing"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 var reversibleOutputs = map[uint64][]*pbsubstreams.ModuleOutput{
@@ -261,3 +269,95 @@ type TestStoreDeltas struct {
 func (t *TestStoreDeltas) GetDeltas() []*pbsubstreams.StoreDelta {
 	return t.deltas
 }
+
+func Test_HandleUndo_ForkSequence(t *testing.T) {
+	ctx := context.Background()
+
+	store := &state.Store{Name: "store_a", KV: map[string][]byte{}}
+	storeMap := map[string]*state.Store{"store_a": store}
+
+	moduleOutputCache := outputs.NewModuleOutputCache(100, zap.NewNop())
+	for _, name := range []string{"store_a", "map_b"} {
+		cache := outputs.NewOutputCache(name, dstore.NewMockStore(nil), 100, zap.NewNop())
+		_, err := cache.LoadAtBlock(ctx, 0)
+		require.NoError(t, err)
+		moduleOutputCache.OutputCaches[name] = cache
+	}
+
+	forkHandler := NewForkHandle()
+	var responses []*pbsubstreams.Response
+	respFunc := func(resp *pbsubstreams.Response) error {
+		responses = append(responses, resp)
+		return nil
+	}
+
+	execute := func(num uint64, id string, deltas ...*pbsubstreams.StoreDelta) *pbsubstreams.Clock {
+		clock := &pbsubstreams.Clock{Number: num, Id: id}
+		for _, delta := range deltas {
+			store.ApplyDelta(delta)
+		}
+		require.NoError(t, moduleOutputCache.OutputCaches["store_a"].Set(clock, "", []byte("deltas")))
+		require.NoError(t, moduleOutputCache.OutputCaches["map_b"].Set(clock, "", []byte("map")))
+
+		forkHandler.addModuleOutput(&pbsubstreams.ModuleOutput{
+			Name: "store_a",
+			Data: &pbsubstreams.ModuleOutput_StoreDeltas{StoreDeltas: &pbsubstreams.StoreDeltas{Deltas: deltas}},
+		}, num)
+		forkHandler.addModuleOutput(&pbsubstreams.ModuleOutput{
+			Name: "map_b",
+			Data: &pbsubstreams.ModuleOutput_MapOutput{MapOutput: &anypb.Any{}},
+		}, num)
+		return clock
+	}
+
+	undo := func(clock *pbsubstreams.Clock, lastValid bstream.BlockRef) {
+		cursor := &bstream.Cursor{
+			Step:      bstream.StepUndo,
+			Block:     bstream.NewBlockRef(clock.Id, clock.Number),
+			LIB:       bstream.NewBlockRef("0a", 0),
+			HeadBlock: bstream.NewBlockRef("3b", 3),
+		}
+		require.NoError(t, forkHandler.handleUndo(clock, cursor, lastValid, moduleOutputCache, storeMap, respFunc))
+	}
+
+	execute(1, "1a", &pbsubstreams.StoreDelta{Operation: pbsubstreams.StoreDelta_CREATE, Key: "a", NewValue: []byte("1")})
+	clock2a := execute(2, "2a",
+		&pbsubstreams.StoreDelta{Operation: pbsubstreams.StoreDelta_UPDATE, Key: "a", OldValue: []byte("1"), NewValue: []byte("2")},
+		&pbsubstreams.StoreDelta{Operation: pbsubstreams.StoreDelta_CREATE, Key: "b", NewValue: []byte("1")},
+	)
+	clock3a := execute(3, "3a", &pbsubstreams.StoreDelta{Operation: pbsubstreams.StoreDelta_DELETE, Key: "b", OldValue: []byte("1")})
+	require.Equal(t, map[string][]byte{"a": []byte("2")}, store.KV)
+
+	undo(clock3a, bstream.NewBlockRef("2a", 2))
+	require.Equal(t, map[string][]byte{"a": []byte("2"), "b": []byte("1")}, store.KV)
+
+	undo(clock2a, bstream.NewBlockRef("1a", 1))
+	require.Equal(t, map[string][]byte{"a": []byte("1")}, store.KV)
+
+	clock2b := execute(2, "2b", &pbsubstreams.StoreDelta{Operation: pbsubstreams.StoreDelta_CREATE, Key: "c", NewValue: []byte("1")})
+	require.Equal(t, map[string][]byte{"a": []byte("1"), "c": []byte("1")}, store.KV)
+
+	for _, cache := range moduleOutputCache.OutputCaches {
+		_, found := cache.Get(clock3a)
+		assert.False(t, found, "module %q block 3a", cache.ModuleName)
+		_, found = cache.Get(clock2a)
+		assert.False(t, found, "module %q block 2a", cache.ModuleName)
+		_, found = cache.Get(clock2b)
+		assert.True(t, found, "module %q block 2b", cache.ModuleName)
+	}
+
+	require.Len(t, forkHandler.reversibleOutputs[2], 2)
+	require.Len(t, forkHandler.reversibleOutputs[3], 0)
+
+	require.Len(t, responses, 2)
+	first := responses[0].GetUndoSignal()
+	require.NotNil(t, first)
+	assert.Equal(t, &pbsubstreams.BlockRef{Id: "2a", Number: 2}, first.LastValidBlock)
+	firstCursor, err := bstream.CursorFromOpaque(first.LastValidCursor)
+	require.NoError(t, err)
+	assert.Equal(t, "3a", firstCursor.Block.ID())
+
+	second := responses[1].GetUndoSignal()
+	require.NotNil(t, second)
+	assert.Equal(t, &pbsubstreams.BlockRef{Id: "1a", Number: 1}, second.LastValidBlock)
+}
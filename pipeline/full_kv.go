@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"fmt"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/state"
+)
+
+// fullKVMaxBytes is the maximum number of key and value bytes of the content
+// of a store sent in place of its deltas, kept below the default gRPC maximum
+// message size of 4 MiB
+const fullKVMaxBytes = 1024 * 1024
+
+// withFullKVStoreOutputs returns the module outputs to send for the current
+// block, where the deltas of the stores listed in
+// `Request.full_kv_store_outputs` are replaced by their full content.
+//
+// The module outputs are not modified, the fork handler keeps the deltas to
+// undo the stores and the cache holds deltas too.
+func (p *Pipeline) withFullKVStoreOutputs(moduleOutputs []*pbsubstreams.ModuleOutput) ([]*pbsubstreams.ModuleOutput, error) {
+	if len(p.request.FullKvStoreOutputs) == 0 || !p.request.DevelopmentMode || p.isSubrequest {
+		return moduleOutputs, nil
+	}
+
+	out := make([]*pbsubstreams.ModuleOutput, len(moduleOutputs))
+	copy(out, moduleOutputs)
+
+	for _, modName := range p.request.FullKvStoreOutputs {
+		store := p.storeMap[modName]
+		if store == nil || p.quarantinedModules[modName] {
+			// the content of a quarantined store stopped being meaningful
+			continue
+		}
+
+		fullKV, err := storeFullKV(store, fullKVMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: store %q: %w", p.clock.Number, modName, err)
+		}
+
+		idx := -1
+		for i, moduleOutput := range out {
+			if moduleOutput.Name == modName {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			out = append(out, &pbsubstreams.ModuleOutput{Name: modName, Data: fullKV})
+			continue
+		}
+
+		original := out[idx]
+		out[idx] = &pbsubstreams.ModuleOutput{
+			Name:             original.Name,
+			Data:             fullKV,
+			Logs:             original.Logs,
+			LogLevels:        original.LogLevels,
+			LogsTruncated:    original.LogsTruncated,
+			DroppedLogs:      original.DroppedLogs,
+			DroppedLogsBytes: original.DroppedLogsBytes,
+			OutputTruncated:  original.OutputTruncated,
+		}
+	}
+
+	return out, nil
+}
+
+func storeFullKV(store *state.Store, maxBytes uint64) (*pbsubstreams.ModuleOutput_StoreFullKv, error) {
+	var totalBytes uint64
+	var entries []*pbsubstreams.StoreKeyValue
+	err := store.Iterate("", func(key string, value []byte) error {
+		totalBytes += uint64(len(key) + len(value))
+		entries = append(entries, &pbsubstreams.StoreKeyValue{Key: key, Value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if totalBytes > maxBytes {
+		return nil, fmt.Errorf("full content of %d bytes exceeds the limit of %d bytes", totalBytes, maxBytes)
+	}
+
+	return &pbsubstreams.ModuleOutput_StoreFullKv{
+		StoreFullKv: &pbsubstreams.StoreFullKV{Entries: entries},
+	}, nil
+}
@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPipeline_WithFullKVStoreOutputs(t *testing.T) {
+	deltas := &pbsubstreams.ModuleOutput_StoreDeltas{StoreDeltas: &pbsubstreams.StoreDeltas{
+		Deltas: []*pbsubstreams.StoreDelta{{Key: "b", NewValue: []byte{0x02}}},
+	}}
+
+	tests := []struct {
+		name             string
+		developmentMode  bool
+		quarantined      bool
+		moduleOutputs    []*pbsubstreams.ModuleOutput
+		expectFullKVKeys []string
+		expectDeltas     bool
+	}{
+		{
+			name:             "deltas replaced",
+			developmentMode:  true,
+			moduleOutputs:    []*pbsubstreams.ModuleOutput{{Name: "map_a"}, {Name: "store_a", Data: deltas, Logs: []string{"log"}}},
+			expectFullKVKeys: []string{"a", "b"},
+		},
+		{
+			name:             "store without deltas",
+			developmentMode:  true,
+			moduleOutputs:    []*pbsubstreams.ModuleOutput{{Name: "map_a"}},
+			expectFullKVKeys: []string{"a", "b"},
+		},
+		{
+			name:          "production mode",
+			moduleOutputs: []*pbsubstreams.ModuleOutput{{Name: "map_a"}, {Name: "store_a", Data: deltas, Logs: []string{"log"}}},
+			expectDeltas:  true,
+		},
+		{
+			name:            "quarantined store",
+			developmentMode: true,
+			quarantined:     true,
+			moduleOutputs:   []*pbsubstreams.ModuleOutput{{Name: "map_a"}, {Name: "store_a", Data: deltas, Logs: []string{"log"}}},
+			expectDeltas:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, err := state.NewStore("store_a", 10, 0, "hash_a", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), zap.NewNop())
+			require.NoError(t, err)
+			store.KV = map[string][]byte{"b": {0x02}, "a": {0x01}}
+
+			p := &Pipeline{
+				request: &pbsubstreams.Request{
+					DevelopmentMode:    test.developmentMode,
+					FullKvStoreOutputs: []string{"store_a"},
+				},
+				clock:              &pbsubstreams.Clock{Number: 5},
+				storeMap:           map[string]*state.Store{"store_a": store},
+				quarantinedModules: map[string]bool{"store_a": test.quarantined},
+			}
+
+			out, err := p.withFullKVStoreOutputs(test.moduleOutputs)
+			require.NoError(t, err)
+
+			var storeOutput *pbsubstreams.ModuleOutput
+			for _, moduleOutput := range out {
+				if moduleOutput.Name == "store_a" {
+					storeOutput = moduleOutput
+				}
+			}
+
+			if test.expectDeltas {
+				require.NotNil(t, storeOutput)
+				assert.Equal(t, deltas, storeOutput.Data)
+				return
+			}
+
+			require.NotNil(t, storeOutput)
+			var keys []string
+			for _, entry := range storeOutput.GetStoreFullKv().Entries {
+				keys = append(keys, entry.Key)
+			}
+			assert.Equal(t, test.expectFullKVKeys, keys)
+
+			// The deltas are still needed to undo the store
+			for _, moduleOutput := range test.moduleOutputs {
+				if moduleOutput.Name == "store_a" {
+					assert.Equal(t, deltas, moduleOutput.Data)
+					assert.Equal(t, moduleOutput.Logs, storeOutput.Logs)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreFullKV_Limit(t *testing.T) {
+	store, err := state.NewStore("store_a", 10, 0, "hash_a", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), zap.NewNop())
+	require.NoError(t, err)
+	store.KV = map[string][]byte{"a": {0x01, 0x02}, "b": {0x01, 0x02}}
+
+	_, err = storeFullKV(store, 5)
+	assert.EqualError(t, err, "full content of 6 bytes exceeds the limit of 5 bytes")
+
+	_, err = storeFullKV(store, 6)
+	assert.NoError(t, err)
+}
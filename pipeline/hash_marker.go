@@ -0,0 +1,269 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/manifest"
+	"go.uber.org/zap"
+)
+
+// moduleHashMarkersPrefix is where the hashes used by each package version
+// are recorded in the base state store, as
+// `modules/<package_name>/<package_version>/<module_name>/<module_hash>`. A
+// marker is written once per hash, so the store never needs to overwrite it.
+const moduleHashMarkersPrefix = "modules"
+
+// ModuleHashMarker is the record of a module hash used by a package version.
+type ModuleHashMarker struct {
+	PackageName    string
+	PackageVersion string
+	ModuleName     string
+	ModuleHash     string
+}
+
+func (m ModuleHashMarker) filename() string {
+	return path.Join(moduleHashMarkersPrefix, url.PathEscape(m.PackageName), url.PathEscape(m.PackageVersion), url.PathEscape(m.ModuleName), m.ModuleHash)
+}
+
+func parseModuleHashMarker(filename string) (marker ModuleHashMarker, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(filename, moduleHashMarkersPrefix+"/"), "/")
+	if len(parts) != 4 {
+		return marker, false
+	}
+	unescaped := make([]string, 3)
+	for i := range unescaped {
+		var err error
+		if unescaped[i], err = url.PathUnescape(parts[i]); err != nil {
+			return marker, false
+		}
+	}
+	return ModuleHashMarker{PackageName: unescaped[0], PackageVersion: unescaped[1], ModuleName: unescaped[2], ModuleHash: parts[3]}, true
+}
+
+// ModuleHashMarkers records the module hashes used by the package versions
+// requested, usually shared by all the requests of the process: each marker
+// is only looked up on the store the first time it is seen by the process.
+//
+// The markers are only a record, the files of the module hashes which are
+// not used anymore are deleted by `DeletePackageVersions`, on demand.
+type ModuleHashMarkers struct {
+	store dstore.Store
+
+	lock     sync.Mutex
+	recorded map[string]bool // by marker filename
+}
+
+func NewModuleHashMarkers(store dstore.Store) *ModuleHashMarkers {
+	return &ModuleHashMarkers{
+		store:    store,
+		recorded: map[string]bool{},
+	}
+}
+
+// record writes `marker` when it is not on the store yet and returns the
+// other hashes recorded for the same module name by the other versions of
+// the package, only when the marker was written.
+func (m *ModuleHashMarkers) record(ctx context.Context, marker ModuleHashMarker) (previousHashes []string, err error) {
+	filename := marker.filename()
+
+	// Not held while accessing the store, writing a marker twice being
+	// harmless
+	m.lock.Lock()
+	recorded := m.recorded[filename]
+	m.lock.Unlock()
+	if recorded {
+		return nil, nil
+	}
+
+	found := false
+	seen := map[string]bool{marker.ModuleHash: true}
+	err = m.store.Walk(ctx, path.Join(moduleHashMarkersPrefix, url.PathEscape(marker.PackageName))+"/", func(existing string) error {
+		if existing == filename {
+			found = true
+			return nil
+		}
+		other, ok := parseModuleHashMarker(existing)
+		if !ok || other.ModuleName != marker.ModuleName || seen[other.ModuleHash] {
+			return nil
+		}
+		seen[other.ModuleHash] = true
+		previousHashes = append(previousHashes, other.ModuleHash)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing hash markers of package %q: %w", marker.PackageName, err)
+	}
+
+	if found {
+		m.setRecorded(filename)
+		return nil, nil
+	}
+
+	if err := m.store.WriteObject(ctx, filename, bytes.NewReader(nil)); err != nil {
+		return nil, fmt.Errorf("writing hash marker of module %q: %w", marker.ModuleName, err)
+	}
+	m.setRecorded(filename)
+
+	sort.Strings(previousHashes)
+	return previousHashes, nil
+}
+
+func (m *ModuleHashMarkers) setRecorded(filename string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.recorded[filename] = true
+}
+
+// checkModuleHashes records the hash of every module of the request under its
+// package version, and warns about the hashes used by the same module names
+// in other versions of the package, whose files stay on the store until their
+// package versions are deleted.
+func (p *Pipeline) checkModuleHashes(ctx context.Context) {
+	if p.moduleHashMarkers == nil || p.request.PackageName == "" || p.request.PackageVersion == "" {
+		return
+	}
+
+	for _, module := range p.modules {
+		marker := ModuleHashMarker{
+			PackageName:    p.request.PackageName,
+			PackageVersion: p.request.PackageVersion,
+			ModuleName:     module.Name,
+			ModuleHash:     manifest.HashModuleAsString(p.request.Modules, p.graph, module),
+		}
+
+		previousHashes, err := p.moduleHashMarkers.record(ctx, marker)
+		if err != nil {
+			// Only a record, the files are keyed by module hash anyway
+			p.logger.Warn("unable to record module hash", zap.String("module_name", module.Name), zap.Error(err))
+			continue
+		}
+		if len(previousHashes) != 0 {
+			p.logger.Warn("module hash differs from the other versions of the package, their files are kept until these versions are deleted",
+				zap.String("package_name", marker.PackageName),
+				zap.String("package_version", marker.PackageVersion),
+				zap.String("module_name", marker.ModuleName),
+				zap.String("module_hash", marker.ModuleHash),
+				zap.Strings("previous_hashes", previousHashes),
+			)
+		}
+	}
+}
+
+// ListModuleHashMarkers returns the module hashes recorded in `store`, the
+// base state store, by every package version requested, sorted.
+func ListModuleHashMarkers(ctx context.Context, store dstore.Store) (markers []ModuleHashMarker, err error) {
+	err = store.Walk(ctx, moduleHashMarkersPrefix+"/", func(filename string) error {
+		if marker, ok := parseModuleHashMarker(filename); ok {
+			markers = append(markers, marker)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing module hash markers: %w", err)
+	}
+
+	sort.Slice(markers, func(i, j int) bool {
+		return markers[i].filename() < markers[j].filename()
+	})
+	return markers, nil
+}
+
+// PackageVersion identifies a package version whose modules are recorded by
+// hash markers.
+type PackageVersion struct {
+	Name    string
+	Version string
+}
+
+// ParsePackageVersion parses a package version written as `<name>@<version>`.
+func ParsePackageVersion(in string) (PackageVersion, error) {
+	name, version, found := strings.Cut(in, "@")
+	if !found || name == "" || version == "" {
+		return PackageVersion{}, fmt.Errorf("invalid package version %q, expected <name>@<version>", in)
+	}
+	return PackageVersion{Name: name, Version: version}, nil
+}
+
+// DeletePackageVersions deletes from `store`, the base state store, the output
+// caches and store snapshots of the module hashes only recorded by the
+// `versions` given, then their markers. A module hash also recorded by
+// another package version is kept, along with its files, since the modules
+// of a hash are the same wherever they come from. With `dryRun`, nothing is
+// deleted. It returns the module hashes whose files are, or would be,
+// deleted.
+func DeletePackageVersions(ctx context.Context, store dstore.Store, versions []PackageVersion, dryRun bool, logger *zap.Logger) (deleted []string, err error) {
+	markers, err := ListModuleHashMarkers(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	deleting := map[PackageVersion]bool{}
+	for _, version := range versions {
+		deleting[version] = true
+	}
+
+	var deletedMarkers []ModuleHashMarker
+	candidates := map[string]bool{}
+	kept := map[string]bool{}
+	for _, marker := range markers {
+		if deleting[PackageVersion{Name: marker.PackageName, Version: marker.PackageVersion}] {
+			deletedMarkers = append(deletedMarkers, marker)
+			candidates[marker.ModuleHash] = true
+		} else {
+			kept[marker.ModuleHash] = true
+		}
+	}
+	for hash := range candidates {
+		if !kept[hash] {
+			deleted = append(deleted, hash)
+		}
+	}
+	sort.Strings(deleted)
+
+	if dryRun {
+		return deleted, nil
+	}
+
+	for _, hash := range deleted {
+		if err := deleteModuleHashFiles(ctx, store, hash, logger); err != nil {
+			return nil, err
+		}
+	}
+	// Last, so a failed deletion can be run again
+	for _, marker := range deletedMarkers {
+		if err := store.DeleteObject(ctx, marker.filename()); err != nil {
+			return nil, fmt.Errorf("deleting hash marker %s: %w", marker.filename(), err)
+		}
+	}
+	return deleted, nil
+}
+
+// deleteModuleHashFiles deletes the output caches and store snapshots kept
+// under `moduleHash`.
+func deleteModuleHashFiles(ctx context.Context, store dstore.Store, moduleHash string, logger *zap.Logger) error {
+	var filenames []string
+	err := store.Walk(ctx, moduleHash+"/", func(filename string) error {
+		filenames = append(filenames, filename)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("listing files of module hash %q: %w", moduleHash, err)
+	}
+
+	for _, filename := range filenames {
+		if err := store.DeleteObject(ctx, filename); err != nil {
+			return fmt.Errorf("deleting %s: %w", filename, err)
+		}
+	}
+
+	logger.Info("module hash files deleted", zap.String("module_hash", moduleHash), zap.Int("file_count", len(filenames)))
+	return nil
+}
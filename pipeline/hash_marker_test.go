@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestModuleHashMarkers_Record(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	markers := NewModuleHashMarkers(store)
+
+	marker := func(version, module, hash string) ModuleHashMarker {
+		return ModuleHashMarker{PackageName: "pkg", PackageVersion: version, ModuleName: module, ModuleHash: hash}
+	}
+
+	previous, err := markers.record(ctx, marker("v1.0.0", "map_a", "hash1"))
+	require.NoError(t, err)
+	assert.Empty(t, previous)
+
+	// Another module name is not affected
+	previous, err = markers.record(ctx, marker("v1.0.0", "map_b", "hash3"))
+	require.NoError(t, err)
+	assert.Empty(t, previous)
+
+	previous, err = markers.record(ctx, marker("v1.1.0", "map_a", "hash2"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hash1"}, previous)
+
+	// Another package is not affected
+	previous, err = markers.record(ctx, ModuleHashMarker{PackageName: "other", PackageVersion: "v1.0.0", ModuleName: "map_a", ModuleHash: "hash4"})
+	require.NoError(t, err)
+	assert.Empty(t, previous)
+
+	// Already recorded, by another process as well
+	previous, err = NewModuleHashMarkers(store).record(ctx, marker("v1.1.0", "map_a", "hash2"))
+	require.NoError(t, err)
+	assert.Empty(t, previous)
+
+	listed, err := ListModuleHashMarkers(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, []ModuleHashMarker{
+		{PackageName: "other", PackageVersion: "v1.0.0", ModuleName: "map_a", ModuleHash: "hash4"},
+		marker("v1.0.0", "map_a", "hash1"),
+		marker("v1.0.0", "map_b", "hash3"),
+		marker("v1.1.0", "map_a", "hash2"),
+	}, listed)
+}
+
+func TestDeletePackageVersions(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	markers := NewModuleHashMarkers(store)
+
+	for _, marker := range []ModuleHashMarker{
+		{PackageName: "pkg", PackageVersion: "v1.0.0", ModuleName: "map_a", ModuleHash: "hash1"},
+		{PackageName: "pkg", PackageVersion: "v1.0.0", ModuleName: "map_b", ModuleHash: "hash3"},
+		{PackageName: "pkg", PackageVersion: "v1.1.0", ModuleName: "map_a", ModuleHash: "hash2"},
+		{PackageName: "pkg", PackageVersion: "v1.1.0", ModuleName: "map_b", ModuleHash: "hash3"},
+	} {
+		_, err := markers.record(ctx, marker)
+		require.NoError(t, err)
+	}
+	store.SetFile("hash1/outputs/0000000000-0000001000.output", []byte("{}"))
+	store.SetFile("hash1/states/0000001000-0000000000.kv", []byte("{}"))
+	store.SetFile("hash2/outputs/0000000000-0000001000.output", []byte("{}"))
+	store.SetFile("hash3/outputs/0000000000-0000001000.output", []byte("{}"))
+
+	versions := []PackageVersion{{Name: "pkg", Version: "v1.0.0"}}
+	deleted, err := DeletePackageVersions(ctx, store, versions, true, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hash1"}, deleted, "hash3 is still used by v1.1.0")
+	assert.Len(t, listFiles(t, store), 8, "nothing deleted in dry run")
+
+	deleted, err = DeletePackageVersions(ctx, store, versions, false, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hash1"}, deleted)
+	assert.Equal(t, []string{
+		"hash2/outputs/0000000000-0000001000.output",
+		"hash3/outputs/0000000000-0000001000.output",
+		"modules/pkg/v1.1.0/map_a/hash2",
+		"modules/pkg/v1.1.0/map_b/hash3",
+	}, listFiles(t, store))
+}
+
+func TestParsePackageVersion(t *testing.T) {
+	version, err := ParsePackageVersion("my-pkg@v1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, PackageVersion{Name: "my-pkg", Version: "v1.2.3"}, version)
+
+	_, err = ParsePackageVersion("my-pkg")
+	assert.EqualError(t, err, `invalid package version "my-pkg", expected <name>@<version>`)
+}
+
+func listFiles(t *testing.T, store dstore.Store) (files []string) {
+	require.NoError(t, store.Walk(context.Background(), "", func(filename string) error {
+		files = append(files, filename)
+		return nil
+	}))
+	return files
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/streamingfast/dmetrics"
+)
+
+// MetricsSet holds the metrics of the pipeline, to be registered by the
+// application running the service.
+var MetricsSet = dmetrics.NewSet()
+
+var wasmMemoryMaxSize = MetricsSet.NewGaugeVec("substreams_wasm_memory_max_size_bytes", []string{"module"}, "Largest linear memory of a wasm instance observed after an execution, per module")
+
+var wasmFuelConsumed = MetricsSet.NewCounterVec("substreams_wasm_fuel_consumed", []string{"module"}, "Fuel consumed by the wasm executions, roughly the number of wasm instructions executed, per module")
+
+var wasmMemoryObserved = struct {
+	sync.Mutex
+	maxSizes map[string]uint64
+}{maxSizes: map[string]uint64{}}
+
+// observeWasmMemorySize records the size of the linear memory of the wasm
+// instance of a module after an execution, keeping the largest one across
+// requests.
+func observeWasmMemorySize(moduleName string, size uint64) {
+	wasmMemoryObserved.Lock()
+	defer wasmMemoryObserved.Unlock()
+
+	if size <= wasmMemoryObserved.maxSizes[moduleName] {
+		return
+	}
+	wasmMemoryObserved.maxSizes[moduleName] = size
+	wasmMemoryMaxSize.SetUint64(size, moduleName)
+}
+
+// observeWasmFuelConsumed records the fuel consumed by an execution of a
+// module.
+func observeWasmFuelConsumed(moduleName string, fuel uint64) {
+	wasmFuelConsumed.AddUint64(fuel, moduleName)
+}
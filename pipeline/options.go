@@ -2,9 +2,14 @@ package pipeline
 
 import (
 	"context"
+	"time"
+
+	"github.com/streamingfast/bstream"
 
 	"github.com/streamingfast/substreams"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/wasm"
 )
 
 type PipelineOptioner interface {
@@ -28,6 +33,188 @@ func WithStoresSaveInterval(seconds uint64) Option {
 	}
 }
 
+// WithModuleOutputCacheSaveInterval overrides the number of blocks of the
+// output cache files of the module named `moduleName`, 0 keeps the default.
+func WithModuleOutputCacheSaveInterval(moduleName string, blocks uint64) Option {
+	return func(p *Pipeline) {
+		if p.moduleOutCacheSaveIntervals == nil {
+			p.moduleOutCacheSaveIntervals = map[string]uint64{}
+		}
+		p.moduleOutCacheSaveIntervals[moduleName] = blocks
+	}
+}
+
+// WithOutputCacheMaxPendingWrites sets the number of segments of a module
+// written in the background before the pipeline waits for them, 0 writing
+// them synchronously.
+func WithOutputCacheMaxPendingWrites(maxPending int) Option {
+	return func(p *Pipeline) {
+		p.outputCacheMaxPendingWrites = maxPending
+	}
+}
+
+// WithOutputCacheWriteTimeout bounds each attempt at writing an output cache
+// segment, a stalled attempt being retried, 0 leaving the attempts
+// unbounded.
+func WithOutputCacheWriteTimeout(timeout time.Duration) Option {
+	return func(p *Pipeline) {
+		p.outputCacheWriteTimeout = timeout
+	}
+}
+
+// WithOutputCacheTempObjectsMaxAge sets the age past which the temporary
+// objects left behind by interrupted output cache writes are deleted, 0
+// keeping them.
+func WithOutputCacheTempObjectsMaxAge(maxAge time.Duration) Option {
+	return func(p *Pipeline) {
+		p.outputCacheTempObjectsMaxAge = maxAge
+	}
+}
+
+// WithOutputCacheCompressionLevel sets the zstd level the output cache files
+// are written with, 0 writing them uncompressed. Files are read whether they
+// are compressed or not.
+func WithOutputCacheCompressionLevel(level int) Option {
+	return func(p *Pipeline) {
+		p.outputCacheCompressionLevel = level
+	}
+}
+
+// WithMaxModuleOutputSize overrides the maximum size in bytes of the output of
+// a mapper for a single block, 0 disables the limit.
+func WithMaxModuleOutputSize(maxSize uint64) Option {
+	return func(p *Pipeline) {
+		p.maxModuleOutputSize = maxSize
+	}
+}
+
+// WithMaxWasmMemorySize overrides the maximum size in bytes of the linear
+// memory of the wasm instance of a module, 0 disables the limit.
+func WithMaxWasmMemorySize(maxSize uint64) Option {
+	return func(p *Pipeline) {
+		p.maxWasmMemorySize = maxSize
+	}
+}
+
+// WithModuleMaxWasmMemorySize overrides the maximum size in bytes of the
+// linear memory of the module named `moduleName`, 0 disables the limit for it.
+func WithModuleMaxWasmMemorySize(moduleName string, maxSize uint64) Option {
+	return func(p *Pipeline) {
+		if p.moduleMaxWasmMemorySizes == nil {
+			p.moduleMaxWasmMemorySizes = map[string]uint64{}
+		}
+		p.moduleMaxWasmMemorySizes[moduleName] = maxSize
+	}
+}
+
+// WithInitialWasmMemorySize sets the initial size in bytes of the linear
+// memory of the wasm instance of a module, rounded up to whole pages. 0, the
+// default, keeps the size declared by the code of the module.
+func WithInitialWasmMemorySize(initialSize uint64) Option {
+	return func(p *Pipeline) {
+		p.initialWasmMemorySize = initialSize
+	}
+}
+
+// WithModuleInitialWasmMemorySize overrides the initial size in bytes of the
+// linear memory of the module named `moduleName`.
+func WithModuleInitialWasmMemorySize(moduleName string, initialSize uint64) Option {
+	return func(p *Pipeline) {
+		if p.moduleInitialWasmMemorySizes == nil {
+			p.moduleInitialWasmMemorySizes = map[string]uint64{}
+		}
+		p.moduleInitialWasmMemorySizes[moduleName] = initialSize
+	}
+}
+
+// WithWasmFuelLimit sets the fuel available to each execution of a module,
+// roughly the number of wasm instructions it can execute, 0 disables the
+// limit. Unlike a timeout, an execution running out of fuel fails the same way
+// on any hardware.
+func WithWasmFuelLimit(limit uint64) Option {
+	return func(p *Pipeline) {
+		p.wasmFuelLimit = limit
+	}
+}
+
+// WithModuleWasmFuelLimit overrides the fuel limit of the module named
+// `moduleName`, 0 disables the limit for it.
+func WithModuleWasmFuelLimit(moduleName string, limit uint64) Option {
+	return func(p *Pipeline) {
+		if p.moduleWasmFuelLimits == nil {
+			p.moduleWasmFuelLimits = map[string]uint64{}
+		}
+		p.moduleWasmFuelLimits[moduleName] = limit
+	}
+}
+
+// WithWasmModulePool checks out the wasm modules from `pool`, usually shared
+// by all the requests of the process, instead of a pool private to the
+// pipeline.
+func WithWasmModulePool(pool *wasm.ModulePool) Option {
+	return func(p *Pipeline) {
+		p.wasmModulePool = pool
+	}
+}
+
+// WithOutputSegmentCache keeps the output cache segments loaded in `cache`,
+// usually shared by all the requests of the process, so that requests
+// replaying the same ranges do not download them again.
+func WithOutputSegmentCache(cache *outputs.SegmentCache) Option {
+	return func(p *Pipeline) {
+		p.outputSegmentCache = cache
+	}
+}
+
+// WithOutputCacheLocalCache serves the output cache files read from the
+// local copies of `cache`, usually shared by all the requests of the
+// process.
+func WithOutputCacheLocalCache(cache *outputs.LocalCache) Option {
+	return func(p *Pipeline) {
+		p.outputLocalCache = cache
+	}
+}
+
+// WithModuleHashMarkers records the module hashes of the package version of
+// the request in `markers`, usually shared by all the requests of the process.
+func WithModuleHashMarkers(markers *ModuleHashMarkers) Option {
+	return func(p *Pipeline) {
+		p.moduleHashMarkers = markers
+	}
+}
+
+// WithBlockTraceSampling only traces one block out of every `everyNBlocks`
+// blocks, the other blocks produce no span. 0 or 1 traces every block.
+func WithBlockTraceSampling(everyNBlocks uint64) Option {
+	return func(p *Pipeline) {
+		p.blockTraceSampling = everyNBlocks
+	}
+}
+
+// WithNetwork sets the network of the server reported to the client when the
+// stream starts.
+func WithNetwork(network string) Option {
+	return func(p *Pipeline) {
+		p.network = network
+	}
+}
+
+// WithStatsInterval overrides the wall clock time between two stats messages
+// sent on the stream, 0 disables them.
+func WithStatsInterval(interval time.Duration) Option {
+	return func(p *Pipeline) {
+		p.statsInterval = interval
+	}
+}
+
+// WithStartCursor resumes the stream right after the block of `cursor`,
+// which must have been validated with ParseStartCursor.
+func WithStartCursor(cursor *bstream.Cursor) Option {
+	return func(p *Pipeline) {
+		p.startCursor = cursor
+	}
+}
+
 func WithPreBlockHook(f substreams.BlockHook) Option {
 	return func(p *Pipeline) {
 		p.preBlockHooks = append(p.preBlockHooks, f)
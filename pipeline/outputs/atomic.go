@@ -0,0 +1,132 @@
+package outputs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/derr"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+// DefaultTempObjectsMaxAge is the age past which the temporary objects left
+// behind by interrupted writes are deleted.
+const DefaultTempObjectsMaxAge = time.Hour
+
+// tempFilenameRegex matches the temporary objects segments are written to
+// first, named after their segment and the time the write started.
+var tempFilenameRegex = regexp.MustCompile(`\.tmp-(\d+)$`)
+
+func tempFilename(filename string, now time.Time) string {
+	return filename + ".tmp-" + strconv.FormatInt(now.UnixNano(), 10)
+}
+
+func isTempFilename(filename string) bool {
+	return tempFilenameRegex.MatchString(filename)
+}
+
+// atomicWrites tells if the writes of `store` only become visible once
+// complete: the local store writes to a temporary file renamed once
+// written, the objects of the cloud stores appear once uploaded.
+func atomicWrites(store dstore.Store) bool {
+	if layered, ok := store.(*layeredStore); ok {
+		store = layered.Store
+	}
+	switch store.(type) {
+	case *dstore.LocalStore, *dstore.GSStore, *dstore.S3Store, *dstore.AzureStore:
+		return true
+	}
+	return false
+}
+
+// writeSegmentFile writes `cnt` to `filename` so that a reader never sees it
+// partially written. On stores not known for atomic writes, the segment is
+// written to a temporary object first, copied to `filename` once complete.
+// The temporary objects of interrupted writes are ignored by the readers and
+// deleted by cleanTempObjects.
+func writeSegmentFile(ctx context.Context, store dstore.Store, filename string, cnt []byte) error {
+	if atomicWrites(store) {
+		return store.WriteObject(ctx, filename, bytes.NewReader(cnt))
+	}
+
+	tempName := tempFilename(filename, time.Now())
+	if err := store.WriteObject(ctx, tempName, bytes.NewReader(cnt)); err != nil {
+		return fmt.Errorf("writing temporary object %s: %w", tempName, err)
+	}
+
+	reader, err := store.OpenObject(ctx, tempName)
+	if err != nil {
+		return fmt.Errorf("opening temporary object %s: %w", tempName, err)
+	}
+	defer reader.Close()
+
+	if err := store.WriteObject(ctx, filename, reader); err != nil {
+		return fmt.Errorf("copying temporary object %s: %w", tempName, err)
+	}
+
+	// Left for cleanTempObjects when it fails
+	_ = store.DeleteObject(ctx, tempName)
+	return nil
+}
+
+// cleanTempObjects deletes the temporary objects of `store` whose write
+// started more than `maxAge` ago, left behind by interrupted writes. It
+// returns the names of the objects deleted.
+func cleanTempObjects(ctx context.Context, store dstore.Store, maxAge time.Duration, now time.Time) (deleted []string, err error) {
+	var stale []string
+	err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+		stale = nil
+		return store.Walk(ctx, "", func(filename string) error {
+			match := tempFilenameRegex.FindStringSubmatch(filename)
+			if match == nil {
+				return nil
+			}
+			startedAt, err := strconv.ParseInt(match[1], 10, 64)
+			if err != nil {
+				return nil
+			}
+			if now.Sub(time.Unix(0, startedAt)) > maxAge {
+				stale = append(stale, filename)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking cache outputs: %w", err)
+	}
+
+	for _, filename := range stale {
+		if err := store.DeleteObject(ctx, filename); err != nil {
+			return deleted, fmt.Errorf("deleting %s: %w", filename, err)
+		}
+		deleted = append(deleted, filename)
+	}
+	return deleted, nil
+}
+
+// cleanedStores holds the URL of the stores whose temporary objects were
+// cleaned by the process already.
+var cleanedStores sync.Map
+
+// startTempObjectsJanitor cleans the temporary objects of `store` in the
+// background, once per process.
+func startTempObjectsJanitor(store dstore.Store, maxAge time.Duration, logger *zap.Logger) {
+	if _, cleaned := cleanedStores.LoadOrStore(store.ObjectURL(""), true); cleaned {
+		return
+	}
+
+	go func() {
+		deleted, err := cleanTempObjects(context.Background(), store, maxAge, time.Now())
+		if err != nil {
+			logger.Warn("failed cleaning temporary cache objects", zap.String("store", store.ObjectURL("")), zap.Error(err))
+		}
+		if len(deleted) > 0 {
+			logger.Info("temporary cache objects deleted", zap.String("store", store.ObjectURL("")), zap.Int("deleted_count", len(deleted)))
+		}
+	}()
+}
@@ -0,0 +1,99 @@
+package outputs
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func listFiles(t *testing.T, store dstore.Store) (out []string) {
+	require.NoError(t, store.Walk(context.Background(), "", func(filename string) error {
+		out = append(out, filename)
+		return nil
+	}))
+	return out
+}
+
+func TestWriteSegmentFile(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+
+	require.NoError(t, writeSegmentFile(ctx, store, "0000000010-0000000020.s10.output", []byte("content")))
+	assert.Equal(t, []string{"0000000010-0000000020.s10.output"}, listFiles(t, store), "temporary object deleted")
+
+	reader, err := store.OpenObject(ctx, "0000000010-0000000020.s10.output")
+	require.NoError(t, err)
+	cnt, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(cnt))
+
+	local, err := dstore.NewLocalStore(&url.URL{Scheme: "file", Path: t.TempDir()}, "", "", false)
+	require.NoError(t, err)
+	assert.True(t, atomicWrites(local))
+	assert.False(t, atomicWrites(store))
+}
+
+func TestOutputCache_InterruptedWrite(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	// A write interrupted after a part of the temporary object was written
+	store.SetFile(tempFilename(ComputeSegmentFilename(10, 20, 10), time.Now()), []byte(`{"10a":{"block_`))
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	found, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	ranges, err := cache.ListCacheRanges(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	cleared, err := ClearFailures(ctx, store, 12)
+	require.NoError(t, err)
+	assert.Empty(t, cleared)
+
+	require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: "10a", Number: 10}, "", []byte{0x01}))
+	require.NoError(t, cache.saveTruncated(ctx))
+	reloaded := NewOutputCache("module1", store, 10, zap.NewNop())
+	found, err = reloaded.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Len(t, reloaded.SortedCacheItems(), 1)
+}
+
+func TestCleanTempObjects(t *testing.T) {
+	now := time.Now()
+	store := dstore.NewMockStore(nil)
+	segment := ComputeSegmentFilename(10, 20, 10)
+	stale := tempFilename(ComputeSegmentFilename(20, 30, 10), now.Add(-2*time.Hour))
+	recent := tempFilename(ComputeSegmentFilename(30, 40, 10), now.Add(-time.Minute))
+	for _, filename := range []string{segment, stale, recent} {
+		store.SetFile(filename, []byte("{}"))
+	}
+
+	deleted, err := cleanTempObjects(context.Background(), store, time.Hour, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{stale}, deleted)
+	assert.ElementsMatch(t, []string{segment, recent}, listFiles(t, store))
+}
+
+func TestIsTempFilename(t *testing.T) {
+	for filename, expected := range map[string]bool{
+		"0000000010-0000000020.output":                       false,
+		"0000000010-0000000020.s10.output":                   false,
+		"0000000010-0000000020.s10.output.tmp-1665000000000": true,
+		"0000000010-0000000020.s10.output.tmp-":              false,
+	} {
+		assert.Equal(t, expected, isTempFilename(filename), filename)
+	}
+	_, err := fileNameToRange("0000000010-0000000020.s10.output.tmp-1665000000000")
+	assert.Error(t, err, "temporary objects are not segments")
+}
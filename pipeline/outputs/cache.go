@@ -1,16 +1,16 @@
 package outputs
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"path"
 	"regexp"
 	"sort"
-	"strconv"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/streamingfast/bstream"
 	"github.com/streamingfast/derr"
@@ -25,13 +25,41 @@ import (
 var cacheFilenameRegex *regexp.Regexp
 
 func init() {
-	cacheFilenameRegex = regexp.MustCompile(`([\d]+)-([\d]+)\.output`)
+	cacheFilenameRegex = regexp.MustCompile(`(?:^|/)([\d]+)-([\d]+)(?:\.s([\d]+))?\.output$`)
 }
 
 type ModulesOutputCache struct {
 	OutputCaches      map[string]*OutputCache
 	SaveBlockInterval uint64
-	logger            *zap.Logger
+	// ModuleSaveBlockIntervals overrides SaveBlockInterval per module name
+	ModuleSaveBlockIntervals map[string]uint64
+	// CompressionLevel is the zstd level the cache files are written with, 0
+	// writing them uncompressed.
+	CompressionLevel int
+	// MaxPendingWrites is the number of segments of a module written in the
+	// background before saving another one waits for them, 0 writing them
+	// synchronously.
+	MaxPendingWrites int
+	// WriteTimeout bounds each attempt at writing a segment, 0 leaving the
+	// attempts unbounded.
+	WriteTimeout time.Duration
+	// TempObjectsMaxAge is the age past which the temporary objects of the
+	// interrupted writes of a module are deleted, when its cache is
+	// registered, 0 keeping them.
+	TempObjectsMaxAge time.Duration
+	// SegmentCache keeps the segments loaded in memory for the requests of
+	// the process, nil loading them from the store every time.
+	SegmentCache *SegmentCache
+	// LocalCache keeps copies of the cache files read in a local directory
+	// for the requests of the process, nil reading them from the store.
+	LocalCache *LocalCache
+	// ReadAhead fetches the next segment of a module in the background once
+	// one is loaded, for the requests served from the caches.
+	ReadAhead bool
+	// TrackAccess records the access to the output caches of the modules
+	// registered, at most every AccessMarkerInterval, for ApplyRetention.
+	TrackAccess bool
+	logger      *zap.Logger
 }
 
 func NewModuleOutputCache(saveBlockInterval uint64, logger *zap.Logger) *ModulesOutputCache {
@@ -39,6 +67,9 @@ func NewModuleOutputCache(saveBlockInterval uint64, logger *zap.Logger) *Modules
 	moduleOutputCache := &ModulesOutputCache{
 		OutputCaches:      make(map[string]*OutputCache),
 		SaveBlockInterval: saveBlockInterval,
+		CompressionLevel:  DefaultCompressionLevel,
+		MaxPendingWrites:  DefaultMaxPendingWrites,
+		WriteTimeout:      DefaultWriteTimeout,
 		logger:            logger.Named("out"),
 	}
 
@@ -56,33 +87,51 @@ func (c *ModulesOutputCache) RegisterModule(module *pbsubstreams.Module, hash st
 	if err != nil {
 		return nil, fmt.Errorf("creating substore for module %q: %w", module.Name, err)
 	}
+	if c.LocalCache != nil {
+		moduleStore = c.LocalCache.Wrap(moduleStore, module.Name)
+	}
+
+	saveBlockInterval := c.SaveBlockInterval
+	if interval := c.ModuleSaveBlockIntervals[module.Name]; interval != 0 {
+		saveBlockInterval = interval
+	}
 
-	cache := NewOutputCache(module.Name, moduleStore, c.SaveBlockInterval, c.logger)
+	cache := NewOutputCache(module.Name, moduleStore, saveBlockInterval, c.logger)
+	cache.ModuleHash = hash
+	cache.initialBlock = module.InitialBlock
+	cache.compressionLevel = c.CompressionLevel
+	cache.segments = c.SegmentCache
+	cache.readAhead = c.ReadAhead
+	cache.writer = newSegmentWriter(moduleStore, c.MaxPendingWrites, cache.logger)
+	cache.writer.writeTimeout = c.WriteTimeout
+	cache.writer.written = cache.segmentWritten
+	cache.index = newSegmentIndex(baseCacheStore, path.Join(hash, segmentIndexFilename), cache.logger)
+	if c.TempObjectsMaxAge > 0 {
+		startTempObjectsJanitor(moduleStore, c.TempObjectsMaxAge, cache.logger)
+	}
+	if c.TrackAccess {
+		startAccessMarker(baseCacheStore, hash, cache.logger)
+	}
 
 	c.OutputCaches[module.Name] = cache
 
 	return cache, nil
 }
 
-func (c *ModulesOutputCache) Update(ctx context.Context, blockRef bstream.BlockRef) error {
+// SaveFinalSegments saves the block range of every module once all its blocks
+// are final, as of `lastFinalBlock`, and moves on to the next block range.
+// This is how live streaming fills the caches along the way.
+func (c *ModulesOutputCache) SaveFinalSegments(ctx context.Context, lastFinalBlock uint64) error {
 	for _, moduleCache := range c.OutputCaches {
-		if moduleCache.IsOutOfRange(blockRef) {
-			c.logger.Debug("updating cache", zap.Stringer("block_ref", blockRef))
-
-			previousFilename := moduleCache.currentFilename()
-			if err := moduleCache.save(ctx, previousFilename); err != nil {
-				return fmt.Errorf("saving blocks for module kv %s: %w", moduleCache.ModuleName, err)
-			}
-
-			if _, err := moduleCache.LoadAtBlock(ctx, moduleCache.CurrentBlockRange.ExclusiveEndBlock); err != nil {
-				return fmt.Errorf("loading blocks %d for module kv %s: %w", moduleCache.CurrentBlockRange.ExclusiveEndBlock, moduleCache.ModuleName, err)
-			}
+		if err := moduleCache.saveFinalSegments(ctx, lastFinalBlock); err != nil {
+			return fmt.Errorf("saving final outputs of module %s: %w", moduleCache.ModuleName, err)
 		}
 	}
-
 	return nil
 }
 
+// Flush saves the current block range of every module and waits for all the
+// segments to be written, returning the error of a failed write.
 func (c *ModulesOutputCache) Flush(ctx context.Context) error {
 	c.logger.Info("Saving caches")
 	for _, moduleCache := range c.OutputCaches {
@@ -95,6 +144,25 @@ func (c *ModulesOutputCache) Flush(ctx context.Context) error {
 			return fmt.Errorf("save: saving outpust or module kv %s: %w", moduleCache.ModuleName, err)
 		}
 	}
+	for _, moduleCache := range c.OutputCaches {
+		if err := moduleCache.writer.wait(ctx); err != nil {
+			return fmt.Errorf("saving outputs of module %s: %w", moduleCache.ModuleName, err)
+		}
+	}
+	return nil
+}
+
+// FlushTruncated synchronously saves the outputs collected so far in the
+// current block range of every module as truncated segments, once the
+// segments written in the background are. It is meant for interruptions,
+// where the background writes could be lost.
+func (c *ModulesOutputCache) FlushTruncated(ctx context.Context) error {
+	c.logger.Info("saving truncated caches")
+	for _, moduleCache := range c.OutputCaches {
+		if err := moduleCache.saveTruncated(ctx); err != nil {
+			return fmt.Errorf("saving truncated outputs of module %s: %w", moduleCache.ModuleName, err)
+		}
+	}
 	return nil
 }
 
@@ -104,6 +172,19 @@ type CacheItem struct {
 	Payload   []byte                 `json:"payload"`
 	Timestamp *timestamppb.Timestamp `json:"timestamp"`
 	Cursor    string                 `json:"cursor"`
+	Failure   *CacheFailure          `json:"failure,omitempty"`
+	// Skipped marks a block the module produced no output for, either not
+	// executed or skipping the block itself, replayed as a nil output.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// CacheFailure records that the module failed deterministically on the
+// block, in place of its output. Caches live under the hash of the module,
+// so a new version of the module never sees the failures of the previous one.
+type CacheFailure struct {
+	Message    string                    `json:"message"`
+	StackTrace []string                  `json:"stack_trace,omitempty"`
+	Panic      *pbsubstreams.PanicDetail `json:"panic,omitempty"`
 }
 
 type outputKV map[string]*CacheItem
@@ -112,24 +193,54 @@ type OutputCache struct {
 	sync.RWMutex
 
 	ModuleName        string
+	ModuleHash        string
 	CurrentBlockRange *block.Range
+	loaded            bool // whether CurrentBlockRange was loaded from a saved cache file
 	kv                outputKV
 	Store             dstore.Store
+	writer            *segmentWriter
 	saveBlockInterval uint64
+	compressionLevel  int
+	segments          *SegmentCache // nil when the segments are not kept in memory
+	index             *segmentIndex // nil when the cache files are always listed
+	readAhead         bool          // fetches the next segment once one is loaded
+	ahead             *segmentReadAhead
+	stats             requestStats
 	logger            *zap.Logger
+
+	initialBlock uint64
+	// filledEnd is the exclusive end of the blocks of the current range known
+	// to be present, having been loaded from a saved cache file
+	filledEnd uint64
+	// incomplete is set when outputs of the current range are missing before
+	// the first processed block, the range must not be saved then as it would
+	// be taken for a complete one
+	incomplete bool
 }
 
 func NewOutputCache(moduleName string, store dstore.Store, saveBlockInterval uint64, logger *zap.Logger) *OutputCache {
-	return &OutputCache{
+	logger = logger.Named("cache")
+	cache := &OutputCache{
 		ModuleName:        moduleName,
 		Store:             store,
+		writer:            newSegmentWriter(store, DefaultMaxPendingWrites, logger),
 		saveBlockInterval: saveBlockInterval,
-		logger:            logger.Named("cache"),
+		compressionLevel:  DefaultCompressionLevel,
+		logger:            logger,
 	}
+	cache.writer.written = cache.segmentWritten
+	return cache
 }
 
 func (c *OutputCache) currentFilename() string {
-	return ComputeDBinFilename(c.CurrentBlockRange.StartBlock, c.CurrentBlockRange.ExclusiveEndBlock)
+	return ComputeSegmentFilename(c.CurrentBlockRange.StartBlock, c.CurrentBlockRange.ExclusiveEndBlock, c.saveBlockInterval)
+}
+
+// segmentFrom returns the range of the segment starting at `startBlock`,
+// which ends on the next boundary of the save interval. Segments start off a
+// boundary after files written with another interval.
+func (c *OutputCache) segmentFrom(startBlock uint64) *block.Range {
+	return block.NewRange(startBlock, ComputeStartBlock(startBlock, c.saveBlockInterval)+c.saveBlockInterval)
 }
 
 func (c *OutputCache) SortedCacheItems() (out []*CacheItem) {
@@ -142,23 +253,39 @@ func (c *OutputCache) SortedCacheItems() (out []*CacheItem) {
 	return
 }
 
+// IsLoaded returns whether the current block range was loaded from a
+// previously saved cache file, as opposed to being a new empty range.
+func (c *OutputCache) IsLoaded() bool {
+	return c.loaded
+}
+
 func (c *OutputCache) IsOutOfRange(ref bstream.BlockRef) bool {
 	return !c.CurrentBlockRange.ContainsBlockRef(ref)
 }
 
+// SetProcessingStart tells the cache that blocks are processed from
+// `blockNum` on, in the current range. When outputs are missing before it,
+// the range is never saved.
+func (c *OutputCache) SetProcessingStart(blockNum uint64) {
+	c.incomplete = blockNum > c.filledEnd && blockNum > c.initialBlock
+}
+
+// Set records the output of the module on the block of `clock`, a nil `data`
+// meaning the module produced no output at all, unlike an empty one.
 func (c *OutputCache) Set(clock *pbsubstreams.Clock, cursor string, data []byte) error {
 	c.Lock()
 	defer c.Unlock()
 
-	cp := make([]byte, len(data))
-	copy(cp, data)
-
 	ci := &CacheItem{
 		BlockNum:  clock.Number,
 		BlockID:   clock.Id,
 		Timestamp: clock.Timestamp,
 		Cursor:    cursor,
-		Payload:   cp,
+		Skipped:   data == nil,
+	}
+	if data != nil {
+		ci.Payload = make([]byte, len(data))
+		copy(ci.Payload, data)
 	}
 
 	c.kv[clock.Id] = ci
@@ -166,11 +293,46 @@ func (c *OutputCache) Set(clock *pbsubstreams.Clock, cursor string, data []byte)
 	return nil
 }
 
+// SaveFailure records the deterministic failure of the module on the block of
+// `clock` and saves the outputs of the current range up to that block right
+// away, since the module cannot get past it. The failure is kept in memory
+// only when the range cannot be saved, as when processing started past its
+// beginning.
+func (c *OutputCache) SaveFailure(ctx context.Context, clock *pbsubstreams.Clock, cursor string, failure *CacheFailure) {
+	c.Lock()
+	c.kv[clock.Id] = &CacheItem{
+		BlockNum:  clock.Number,
+		BlockID:   clock.Id,
+		Timestamp: clock.Timestamp,
+		Cursor:    cursor,
+		Failure:   failure,
+	}
+	c.Unlock()
+
+	if err := c.saveTruncated(ctx); err != nil {
+		// The module simply gets executed again by the next request
+		c.logger.Warn("unable to save module failure", zap.String("module_name", c.ModuleName), zap.Uint64("block_num", clock.Number), zap.Error(err))
+	}
+}
+
+// GetFailure returns the failure recorded for the block of `clock`, if any.
+func (c *OutputCache) GetFailure(clock *pbsubstreams.Clock) (*CacheFailure, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	cacheItem, found := c.kv[clock.Id]
+	if !found || cacheItem.Failure == nil {
+		return nil, false
+	}
+	return cacheItem.Failure, true
+}
+
 func (c *OutputCache) Get(clock *pbsubstreams.Clock) ([]byte, bool) {
 	c.Lock()
 	defer c.Unlock()
 
 	cacheItem, found := c.kv[clock.Id]
+	c.countGet(found)
 
 	if !found {
 		return nil, false
@@ -192,87 +354,399 @@ func (c *OutputCache) GetAtBlock(blockNumber uint64) ([]byte, bool) {
 	return nil, false
 }
 
+// LoadAtBlock loads the cache file holding `atBlock`, whatever the save
+// interval it was written with. When there is none, the current range is
+// the segment holding `atBlock`, starting after the last file before it.
 func (c *OutputCache) LoadAtBlock(ctx context.Context, atBlock uint64) (found bool, err error) {
 	c.logger.Info("loading cache at block", zap.String("module_name", c.ModuleName), zap.Uint64("at_block_num", atBlock))
 
 	c.kv = make(outputKV)
+	c.loaded = false
+	c.incomplete = false
 
-	blockRange, found, err := findBlockRange(ctx, c.Store, atBlock)
+	segmentStart := ComputeStartBlock(atBlock, c.saveBlockInterval)
+	file, previousEnd, fromIndex, prefetched, err := c.lookupSegment(ctx, atBlock, segmentStart)
 	if err != nil {
-		return found, fmt.Errorf("computing block range for module %q: %w", c.ModuleName, err)
+		return false, fmt.Errorf("computing block range for module %q: %w", c.ModuleName, err)
 	}
 
-	c.logger.Debug("block range found", zap.Object("block_range", blockRange))
-
-	if !found {
-		blockRange = block.NewRange(atBlock, atBlock+c.saveBlockInterval)
-		c.CurrentBlockRange = blockRange
-		return found, nil
+	if file == nil {
+		if previousEnd > segmentStart {
+			segmentStart = previousEnd
+		}
+		c.startSegment(segmentStart)
+		c.logger.Debug("no cache file found", zap.String("module_name", c.ModuleName), zap.Stringer("block_range", c.CurrentBlockRange))
+		return false, nil
 	}
 
-	err = c.Load(ctx, blockRange)
+	blockRange := file.blockRange
+	c.logger.Debug("block range found", zap.Object("block_range", blockRange), zap.Uint64("save_interval", file.saveInterval))
+
+	err = c.load(ctx, file.filename, blockRange, prefetched)
+	var unsupportedErr *unsupportedFormatError
+	if errors.As(err, &unsupportedErr) {
+		// Written by a more recent release, the blocks are executed again.
+		// The segment saved then is in a format known to that release too.
+		c.logger.Warn("cache file of an unsupported format, ignoring it", zap.String("module_name", c.ModuleName), zap.String("file_name", file.filename), zap.Error(err))
+		c.startSegment(segmentStart)
+		return false, nil
+	}
+	if errors.Is(err, dstore.ErrNotFound) {
+		// Deleted since it was listed, by the retention of the caches
+		c.logger.Info("cache file gone, ignoring it", zap.String("module_name", c.ModuleName), zap.String("file_name", file.filename))
+		if fromIndex {
+			c.index.remove(ctx, file.filename)
+			return c.LoadAtBlock(ctx, atBlock)
+		}
+		c.startSegment(segmentStart)
+		return false, nil
+	}
+	var corruptErr *corruptFileError
+	if errors.As(err, &corruptErr) {
+		// The module executes again over the blocks of the corrupt file,
+		// which are written anew once its segment is complete.
+		c.logger.Error("corrupt cache file, ignoring it", zap.String("module_name", c.ModuleName), zap.String("file_name", file.filename), zap.Error(err))
+		if err := c.quarantine(ctx, file.filename); err != nil {
+			c.logger.Warn("failed quarantining corrupt cache file", zap.String("module_name", c.ModuleName), zap.String("file_name", file.filename), zap.Error(err))
+			c.startSegment(segmentStart)
+			return false, nil
+		}
+		return c.LoadAtBlock(ctx, atBlock)
+	}
 	if err != nil {
 		return false, fmt.Errorf("loading cache: %w", err)
 	}
-	return found, nil
+	c.filledEnd = blockRange.ExclusiveEndBlock
+
+	// Files named without their interval were written with the current one
+	if file.truncated(c.saveBlockInterval) {
+		// A truncated segment, saved when processing was interrupted. The
+		// rest of the segment gets filled as blocks are processed, and the
+		// whole segment is saved once its end is reached.
+		c.logger.Debug("truncated segment loaded", zap.String("module_name", c.ModuleName), zap.Object("block_range", blockRange))
+		if segment := c.segmentFrom(blockRange.StartBlock); segment.ExclusiveEndBlock > blockRange.ExclusiveEndBlock {
+			c.CurrentBlockRange = segment
+		}
+		return true, nil
+	}
+
+	if c.readAhead {
+		c.startReadAhead(ctx, blockRange.ExclusiveEndBlock)
+	}
+	return true, nil
+}
 
+// startSegment makes the current range the segment starting at
+// `startBlock`, empty.
+func (c *OutputCache) startSegment(startBlock uint64) {
+	c.kv = make(outputKV)
+	c.CurrentBlockRange = c.segmentFrom(startBlock)
+	c.filledEnd = startBlock
 }
+
+// Load loads the cache file of `blockRange` written without its save
+// interval in its name.
 func (c *OutputCache) Load(ctx context.Context, blockRange *block.Range) error {
-	c.logger.Debug("loading cache", zap.String("module_name", c.ModuleName), zap.Object("range", blockRange))
+	return c.load(ctx, ComputeDBinFilename(blockRange.StartBlock, blockRange.ExclusiveEndBlock), blockRange, nil)
+}
+
+// load makes `blockRange` the current range, with the outputs of the cache
+// file `filename`, read from the store unless given as `kv`.
+func (c *OutputCache) load(ctx context.Context, filename string, blockRange *block.Range, kv outputKV) (err error) {
 	c.kv = make(outputKV)
 
-	filename := ComputeDBinFilename(blockRange.StartBlock, blockRange.ExclusiveEndBlock)
 	c.logger.Debug("loading outputs data", zap.String("file_name", filename), zap.String("cache_module_name", c.ModuleName), zap.Object("block_range", blockRange))
 
+	if kv == nil {
+		if kv, err = c.readSegment(ctx, filename); err != nil {
+			return err
+		}
+	}
+	// The segment kept in memory is shared, its items are never modified
+	for id, item := range kv {
+		c.kv[id] = item
+	}
+	c.countSegmentLoaded()
+
+	c.CurrentBlockRange = blockRange
+	c.loaded = true
+	c.logger.Debug("outputs data loaded", zap.String("module_name", c.ModuleName), zap.Int("output_count", len(c.kv)), zap.Stringer("block_range", c.CurrentBlockRange))
+	return nil
+}
+
+// readSegment returns the items of the cache file `filename`, from memory
+// when the segments are kept there. They must not be modified.
+func (c *OutputCache) readSegment(ctx context.Context, filename string) (kv outputKV, err error) {
+	if c.segments != nil {
+		kv, err = c.segments.load(ctx, c.ModuleName, segmentKey(c.ModuleHash, filename), func(ctx context.Context) (outputKV, error) {
+			return c.fetch(ctx, filename)
+		})
+	} else {
+		kv, err = c.fetch(ctx, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+// RangeNotCachedError is returned by ReadRange when the cache files stop
+// before the end of the range read.
+type RangeNotCachedError struct {
+	ModuleName string
+	// CachedUntil is the exclusive end of the blocks read, the first one
+	// without a cache file.
+	CachedUntil uint64
+}
+
+func (e *RangeNotCachedError) Error() string {
+	return fmt.Sprintf("outputs of module %q not cached from block %d", e.ModuleName, e.CachedUntil)
+}
+
+// ReadRange calls `fn` with the cached outputs of the blocks of `r`, in
+// order, reading the cache files one at a time whatever the save interval
+// they were written with. The blocks where the module produced no output
+// are passed with nil data. It returns a *RangeNotCachedError holding the
+// block reached when the cache files stop before the end of `r`, and an
+// error when the module failed on a block of `r`. The current range of the
+// cache is left untouched.
+func (c *OutputCache) ReadRange(ctx context.Context, r *block.Range, fn func(clock *pbsubstreams.Clock, data []byte) error) error {
+	return c.readItems(ctx, r, func(item *CacheItem) error {
+		if item.Failure != nil {
+			return fmt.Errorf("module %q failed on block %d: %s", c.ModuleName, item.BlockNum, item.Failure.Message)
+		}
+		data := item.Payload
+		if item.Skipped {
+			data = nil
+		}
+		clock := &pbsubstreams.Clock{Id: item.BlockID, Number: item.BlockNum, Timestamp: item.Timestamp}
+		return fn(clock, data)
+	})
+}
+
+// readItems calls `fn` with the cached items of the blocks of `r`, in order,
+// returning a *RangeNotCachedError when the cache files stop before the end
+// of `r`.
+func (c *OutputCache) readItems(ctx context.Context, r *block.Range, fn func(item *CacheItem) error) error {
+	next := r.StartBlock
+	for next < r.ExclusiveEndBlock {
+		file, _, _, err := c.findSegmentFile(ctx, next, next)
+		if err != nil {
+			return fmt.Errorf("finding cache file of module %q at block %d: %w", c.ModuleName, next, err)
+		}
+		if file == nil {
+			return &RangeNotCachedError{ModuleName: c.ModuleName, CachedUntil: next}
+		}
+
+		kv, err := c.readSegment(ctx, file.filename)
+		if errors.Is(err, dstore.ErrNotFound) {
+			// Deleted since it was listed, by the retention of the caches
+			return &RangeNotCachedError{ModuleName: c.ModuleName, CachedUntil: next}
+		}
+		if err != nil {
+			return fmt.Errorf("reading cache file %s: %w", file.filename, err)
+		}
+
+		items := make([]*CacheItem, 0, len(kv))
+		for _, item := range kv {
+			if item.BlockNum >= next && item.BlockNum < r.ExclusiveEndBlock {
+				items = append(items, item)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].BlockNum < items[j].BlockNum
+		})
+
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		next = file.blockRange.ExclusiveEndBlock
+	}
+	return nil
+}
+
+// fetch reads and decodes the cache file `filename`.
+func (c *OutputCache) fetch(ctx context.Context, filename string) (outputKV, error) {
+	var cnt []byte
+	notFound := false
 	err := derr.RetryContext(ctx, 3, func(ctx context.Context) error {
 		objectReader, err := c.Store.OpenObject(ctx, filename)
+		if errors.Is(err, dstore.ErrNotFound) {
+			notFound = true
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("loading block reader %s: %w", filename, err)
 		}
+		defer objectReader.Close()
 
-		if err = json.NewDecoder(objectReader).Decode(&c.kv); err != nil {
-			return fmt.Errorf("json decoding file %s: %w", filename, err)
+		if cnt, err = io.ReadAll(objectReader); err != nil {
+			return fmt.Errorf("reading file %s: %w", filename, err)
 		}
 
 		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("retried: %w", err)
+		return nil, fmt.Errorf("retried: %w", err)
 	}
+	if notFound {
+		return nil, fmt.Errorf("loading block reader %s: %w", filename, dstore.ErrNotFound)
+	}
+	c.countBytesRead(len(cnt))
 
-	c.CurrentBlockRange = blockRange
-	c.logger.Debug("outputs data loaded", zap.String("module_name", c.ModuleName), zap.Int("output_count", len(c.kv)), zap.Stringer("block_range", c.CurrentBlockRange))
-	return nil
+	// Decoded once read entirely, retrying a corrupt file being pointless
+	kv := make(outputKV)
+	format, err := unmarshalKV(cnt, &kv)
+	if err != nil {
+		return nil, fmt.Errorf("decoding file %s: %w", filename, err)
+	}
+	// Files written before the module hash was recorded are accepted
+	if format.moduleHash != "" && c.ModuleHash != "" && format.moduleHash != c.ModuleHash {
+		return nil, fmt.Errorf("cache file %s: written by module hash %q, refusing to load it for module %q with hash %q", filename, format.moduleHash, c.ModuleName, c.ModuleHash)
+	}
+	return kv, nil
 }
 
 func (c *OutputCache) save(ctx context.Context, filename string) error {
-	c.logger.Info("saving cache", zap.String("module_name", c.ModuleName), zap.Stringer("block_range", c.CurrentBlockRange), zap.String("filename", filename))
+	if c.incomplete {
+		c.logger.Info("skipping save of incomplete cache", zap.String("module_name", c.ModuleName), zap.Stringer("block_range", c.CurrentBlockRange))
+		return nil
+	}
+	cnt, uncompressedSize, err := c.encode()
+	if err != nil {
+		return err
+	}
+	c.logger.Info("saving cache", zap.String("module_name", c.ModuleName), zap.Stringer("block_range", c.CurrentBlockRange), zap.String("filename", filename),
+		zap.Int("uncompressed_size", uncompressedSize), zap.Int("compressed_size", len(cnt)))
+
+	return c.writer.write(ctx, filename, cnt)
+}
 
-	buffer := bytes.NewBuffer(nil)
-	err := json.NewEncoder(buffer).Encode(c.kv)
+// saveTruncated writes the outputs of the current block range up to the last
+// block present, under a file name ending right after that block. Loading
+// the range finds the truncated segment as long as the complete one was not
+// saved since. It waits for the segments written in the background first,
+// and saves nothing when one of them failed.
+func (c *OutputCache) saveTruncated(ctx context.Context) error {
+	items := c.SortedCacheItems()
+	if len(items) == 0 || c.incomplete {
+		return nil
+	}
+
+	if err := c.writer.wait(ctx); err != nil {
+		return err
+	}
+
+	exclusiveEndBlock := items[len(items)-1].BlockNum + 1
+	if exclusiveEndBlock > c.CurrentBlockRange.ExclusiveEndBlock {
+		exclusiveEndBlock = c.CurrentBlockRange.ExclusiveEndBlock
+	}
+	filename := ComputeSegmentFilename(c.CurrentBlockRange.StartBlock, exclusiveEndBlock, c.saveBlockInterval)
+	cnt, uncompressedSize, err := c.encode()
 	if err != nil {
-		return fmt.Errorf("json encoding outputs: %w", err)
+		return err
 	}
-	cnt := buffer.Bytes()
+	c.logger.Info("saving truncated cache", zap.String("module_name", c.ModuleName), zap.Stringer("block_range", c.CurrentBlockRange), zap.String("filename", filename),
+		zap.Int("uncompressed_size", uncompressedSize), zap.Int("compressed_size", len(cnt)))
 
-	go func() {
-		err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
-			reader := bytes.NewReader(cnt)
-			return c.Store.WriteObject(ctx, filename, reader)
-		})
-		if err != nil {
-			c.logger.Warn("failed writing output cache", zap.Error(err))
+	err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+		return writeSegmentFile(ctx, c.Store, filename, cnt)
+	})
+	if err != nil {
+		c.invalidateSegment(filename)
+		return err
+	}
+	c.segmentWritten(filename, len(cnt))
+	return nil
+}
+
+// segmentWritten is called once the segment `filename` of `size` bytes is
+// written.
+func (c *OutputCache) segmentWritten(filename string, size int) {
+	c.invalidateSegment(filename)
+	c.countBytesWritten(size)
+	// Along with the segments, which outlive the request
+	if c.index != nil {
+		c.index.add(context.Background(), filename)
+	}
+	c.deleteSupersededSegments(context.Background(), filename)
+}
+
+// invalidateSegment forgets the segment `filename` kept in memory, when it
+// was rewritten or deleted.
+func (c *OutputCache) invalidateSegment(filename string) {
+	if c.segments != nil {
+		c.segments.invalidate(segmentKey(c.ModuleHash, filename))
+	}
+}
+
+// saveFinalSegments writes the current range, when complete, once its last
+// block is final and moves on to the next range, keeping the outputs of the
+// blocks after the saved range. The segments are written in the background,
+// the error of a failed write being returned by the next call.
+func (c *OutputCache) saveFinalSegments(ctx context.Context, lastFinalBlock uint64) error {
+	for c.CurrentBlockRange.ExclusiveEndBlock <= lastFinalBlock+1 {
+		segment := c.CurrentBlockRange
+
+		c.Lock()
+		inSegment, after := make(outputKV), make(outputKV)
+		for id, item := range c.kv {
+			if item.BlockNum < segment.ExclusiveEndBlock {
+				inSegment[id] = item
+			} else {
+				after[id] = item
+			}
+		}
+		c.Unlock()
+
+		if !c.incomplete && c.filledEnd < segment.ExclusiveEndBlock {
+			filename := ComputeSegmentFilename(segment.StartBlock, segment.ExclusiveEndBlock, c.saveBlockInterval)
+			cnt, uncompressedSize, err := encodeKV(inSegment, c.ModuleHash, c.compressionLevel)
+			if err != nil {
+				return err
+			}
+			c.logger.Info("saving final cache segment", zap.String("module_name", c.ModuleName), zap.Stringer("block_range", segment), zap.String("filename", filename),
+				zap.Int("uncompressed_size", uncompressedSize), zap.Int("compressed_size", len(cnt)))
+			if err := c.writer.write(ctx, filename, cnt); err != nil {
+				return err
+			}
 		}
-	}()
 
+		c.Lock()
+		c.kv = after
+		c.CurrentBlockRange = c.segmentFrom(segment.ExclusiveEndBlock)
+		c.loaded = false
+		c.incomplete = false
+		c.filledEnd = segment.ExclusiveEndBlock
+		c.Unlock()
+	}
 	return nil
 }
 
+func (c *OutputCache) encode() ([]byte, int, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	return encodeKV(c.kv, c.ModuleHash, c.compressionLevel)
+}
+
 func (c *OutputCache) String() string {
 	return c.Store.ObjectURL("")
 }
 
+// ListContinuousCacheRanges returns the cached ranges following each other
+// from `from`. They come from the segment index when there is one, which
+// may miss the last segments written by other processes, so the ranges
+// returned may stop short of the ones cached but never go past them.
 func (c *OutputCache) ListContinuousCacheRanges(ctx context.Context, from uint64) (block.Ranges, error) {
+	if c.index != nil {
+		if cachedRanges := c.index.ranges(ctx); len(cachedRanges) != 0 {
+			return listContinuousCacheRanges(withoutSupersededRanges(cachedRanges), from), nil
+		}
+	}
+
 	cachedRanges, err := c.ListCacheRanges(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("listing cached ranges %q: %w", c.ModuleName, err)
@@ -281,10 +755,15 @@ func (c *OutputCache) ListContinuousCacheRanges(ctx context.Context, from uint64
 	return out, nil
 }
 
+// ListCacheRanges returns the ranges of the cache files, sorted, leaving out
+// the truncated segments covered by another file.
 func (c *OutputCache) ListCacheRanges(ctx context.Context) (block.Ranges, error) {
 	var out block.Ranges
 	err := derr.RetryContext(ctx, 3, func(ctx context.Context) error {
 		if err := c.Store.Walk(ctx, "", func(filename string) (err error) {
+			if isIgnoredFilename(filename) {
+				return nil
+			}
 			r, err := fileNameToRange(filename)
 			if err != nil {
 				return fmt.Errorf("getting range from filename: %w", err)
@@ -299,11 +778,8 @@ func (c *OutputCache) ListCacheRanges(ctx context.Context) (block.Ranges, error)
 	if err != nil {
 		return nil, err
 	}
-	sort.Slice(out, func(i, j int) bool {
-		return out[i].StartBlock < out[j].StartBlock
-	})
 
-	return out, nil
+	return withoutSupersededRanges(out), nil
 }
 
 func (c *OutputCache) Delete(blockID string) {
@@ -313,6 +789,81 @@ func (c *OutputCache) Delete(blockID string) {
 	delete(c.kv, blockID)
 }
 
+// ClearFailures removes the failures recorded at `blockNum` from the output
+// cache files of a module found in `store`, so the module gets executed again
+// on that block. It returns the names of the files rewritten, which requires
+// `store` to allow overwrites. The files are rewritten compressed with
+// DefaultCompressionLevel when they were compressed. The processes keeping
+// the segments in memory keep serving the failures until evicted.
+func ClearFailures(ctx context.Context, store dstore.Store, blockNum uint64) (cleared []string, err error) {
+	var filenames []string
+	err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+		filenames = nil
+		return store.Walk(ctx, "", func(filename string) error {
+			if isIgnoredFilename(filename) {
+				return nil
+			}
+			r, err := fileNameToRange(filename)
+			if err != nil {
+				return fmt.Errorf("getting range from filename: %w", err)
+			}
+			if r.Contains(blockNum) {
+				filenames = append(filenames, filename)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking cache outputs: %w", err)
+	}
+
+	for _, filename := range filenames {
+		kv := outputKV{}
+		var format fileFormat
+		err := derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+			objectReader, err := store.OpenObject(ctx, filename)
+			if err != nil {
+				return fmt.Errorf("opening %s: %w", filename, err)
+			}
+			defer objectReader.Close()
+
+			format, err = decodeKV(objectReader, &kv)
+			return err
+		})
+		if err != nil {
+			return cleared, fmt.Errorf("loading %s: %w", filename, err)
+		}
+
+		found := false
+		for blockID, item := range kv {
+			if item.BlockNum == blockNum && item.Failure != nil {
+				delete(kv, blockID)
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+
+		compressionLevel := 0
+		if format.compressed {
+			compressionLevel = DefaultCompressionLevel
+		}
+		cnt, _, err := encodeKV(kv, format.moduleHash, compressionLevel)
+		if err != nil {
+			return cleared, err
+		}
+		err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+			return writeSegmentFile(ctx, store, filename, cnt)
+		})
+		if err != nil {
+			return cleared, fmt.Errorf("writing %s: %w", filename, err)
+		}
+		cleared = append(cleared, filename)
+	}
+	return cleared, nil
+}
+
 func listContinuousCacheRanges(cachedRanges block.Ranges, from uint64) block.Ranges {
 	cachedRangeCount := len(cachedRanges)
 	var out block.Ranges
@@ -332,60 +883,133 @@ func listContinuousCacheRanges(cachedRanges block.Ranges, from uint64) block.Ran
 	return out
 }
 
-func fileNameToRange(filename string) (*block.Range, error) {
+// segmentFile is an output cache file, with the save interval it was
+// written with, 0 when its name predates the interval in names.
+type segmentFile struct {
+	filename     string
+	blockRange   *block.Range
+	saveInterval uint64
+}
+
+func fileNameToSegment(filename string) (*segmentFile, error) {
 	res := cacheFilenameRegex.FindAllStringSubmatch(filename, 1)
 	if len(res) != 1 {
 		return nil, fmt.Errorf("invalid output cache filename, %q", filename)
 	}
 
-	start := uint64(utils.MustAtoi(res[0][1]))
-	end := uint64(utils.MustAtoi(res[0][2]))
+	file := &segmentFile{
+		filename: filename,
+		blockRange: &block.Range{
+			StartBlock:        uint64(utils.MustAtoi(res[0][1])),
+			ExclusiveEndBlock: uint64(utils.MustAtoi(res[0][2])),
+		},
+	}
+	if res[0][3] != "" {
+		file.saveInterval = uint64(utils.MustAtoi(res[0][3]))
+	}
+	return file, nil
+}
 
-	return &block.Range{
-		StartBlock:        start,
-		ExclusiveEndBlock: end,
-	}, nil
+func fileNameToRange(filename string) (*block.Range, error) {
+	file, err := fileNameToSegment(filename)
+	if err != nil {
+		return nil, err
+	}
+	return file.blockRange, nil
 }
 
-func findBlockRange(ctx context.Context, store dstore.Store, prefixStartBlock uint64) (*block.Range, bool, error) {
-	var exclusiveEndBlock uint64
+// findSegmentFile returns the cache file holding `atBlock` found in the
+// segment index, listing the store when the index has none, and whether it
+// came from the index.
+func (c *OutputCache) findSegmentFile(ctx context.Context, atBlock, notBefore uint64) (found *segmentFile, previousEnd uint64, fromIndex bool, err error) {
+	if c.index != nil {
+		if found := c.index.find(ctx, atBlock); found != nil {
+			return found, 0, true, nil
+		}
+	}
+	found, previousEnd, err = findSegmentFile(ctx, c.Store, atBlock, notBefore)
+	return found, previousEnd, false, err
+}
 
-	paddedBlock := pad(prefixStartBlock)
+// findSegmentFile returns the cache file holding `atBlock`, the one reaching
+// the furthest when several do. The files starting at `atBlock` are listed
+// first, all of them only when there is none. When no file holds `atBlock`,
+// it returns the end of the last file ending between `notBefore` and
+// `atBlock` instead, 0 when there is none.
+func findSegmentFile(ctx context.Context, store dstore.Store, atBlock, notBefore uint64) (found *segmentFile, previousEnd uint64, err error) {
+	paddedBlock := pad(atBlock)
 
 	var files []string
-	err := derr.RetryContext(ctx, 3, func(ctx context.Context) (err error) {
+	err = derr.RetryContext(ctx, 3, func(ctx context.Context) (err error) {
 		files, err = store.ListFiles(ctx, paddedBlock, math.MaxInt64)
 		return
 	})
 	if err != nil {
-		return nil, false, fmt.Errorf("walking prefix for padded block %s: %w", paddedBlock, err)
+		return nil, 0, fmt.Errorf("walking prefix for padded block %s: %w", paddedBlock, err)
 	}
+	files = withoutIgnoredFiles(files)
 
 	if len(files) == 0 {
-		return nil, false, nil
+		err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+			files = nil
+			return store.Walk(ctx, "", func(filename string) error {
+				files = append(files, filename)
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("walking cache outputs: %w", err)
+		}
+		files = withoutIgnoredFiles(files)
 	}
 
-	biggestEndBlock := uint64(0)
-
-	for _, file := range files {
-		endBlock, err := getExclusiveEndBlock(file)
+	segments := make([]*segmentFile, 0, len(files))
+	for _, filename := range files {
+		file, err := fileNameToSegment(filename)
 		if err != nil {
-			return nil, false, fmt.Errorf("getting exclusive end block from file %s: %w", file, err)
-		}
-		if endBlock > biggestEndBlock {
-			biggestEndBlock = endBlock
+			return nil, 0, fmt.Errorf("getting range from filename: %w", err)
 		}
+		segments = append(segments, file)
 	}
 
-	exclusiveEndBlock = biggestEndBlock
+	found, previousEnd = pickSegmentFile(segments, atBlock, notBefore)
+	return found, previousEnd, nil
+}
 
-	return block.NewRange(prefixStartBlock, exclusiveEndBlock), true, nil
+// pickSegmentFile returns the file of `files` holding `atBlock`, the one
+// reaching the furthest when several do, or the end of the last file ending
+// between `notBefore` and `atBlock` when none does.
+func pickSegmentFile(files []*segmentFile, atBlock, notBefore uint64) (found *segmentFile, previousEnd uint64) {
+	for _, file := range files {
+		r := file.blockRange
+		if r.Contains(atBlock) {
+			if found == nil || r.ExclusiveEndBlock > found.blockRange.ExclusiveEndBlock ||
+				(r.ExclusiveEndBlock == found.blockRange.ExclusiveEndBlock && r.StartBlock < found.blockRange.StartBlock) {
+				found = file
+			}
+			continue
+		}
+		if r.ExclusiveEndBlock > notBefore && r.ExclusiveEndBlock <= atBlock && r.ExclusiveEndBlock > previousEnd {
+			previousEnd = r.ExclusiveEndBlock
+		}
+	}
+	return found, previousEnd
 }
 
+// ComputeDBinFilename returns the name of a cache file written before the
+// save interval was part of the names, as read from older caches.
 func ComputeDBinFilename(startBlock, stopBlock uint64) string {
 	return fmt.Sprintf("%010d-%010d.output", startBlock, stopBlock)
 }
 
+// ComputeSegmentFilename returns the name of the cache file of the blocks
+// from `startBlock` to `stopBlock`, written with `saveBlockInterval`. The
+// interval tells a truncated segment from a complete one of a smaller
+// interval.
+func ComputeSegmentFilename(startBlock, stopBlock, saveBlockInterval uint64) string {
+	return fmt.Sprintf("%010d-%010d.s%d.output", startBlock, stopBlock, saveBlockInterval)
+}
+
 func pad(blockNumber uint64) string {
 	return fmt.Sprintf("%010d", blockNumber)
 }
@@ -393,14 +1017,3 @@ func pad(blockNumber uint64) string {
 func ComputeStartBlock(startBlock uint64, saveBlockInterval uint64) uint64 {
 	return startBlock - startBlock%saveBlockInterval
 }
-
-func getExclusiveEndBlock(filename string) (uint64, error) {
-	endBlock := strings.Split(strings.Split(filename, "-")[1], ".")[0]
-	parsedInt, err := strconv.ParseInt(strings.TrimLeft(endBlock, "0"), 10, 64)
-
-	if err != nil {
-		return 0, fmt.Errorf("parsing int %d: %w", parsedInt, err)
-	}
-
-	return uint64(parsedInt), nil
-}
@@ -1,12 +1,22 @@
 package outputs
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streamingfast/dstore"
 	"github.com/streamingfast/logging"
 
 	"github.com/streamingfast/substreams/block"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestOutputCache_listContinuousCacheRanges(t *testing.T) {
@@ -122,3 +132,664 @@ func TestOutputCache_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputCache_TruncatedSegment(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	_, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	for num := uint64(10); num < 16; num++ {
+		require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", []byte{0x01}))
+	}
+	require.NoError(t, cache.saveTruncated(ctx))
+
+	exists, err := store.FileExists(ctx, ComputeSegmentFilename(10, 16, 10))
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// The truncated segment is loaded over the whole range, to be completed
+	reloaded := NewOutputCache("module1", store, 10, zap.NewNop())
+	found, err := reloaded.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.True(t, reloaded.IsLoaded())
+	assert.Equal(t, block.NewRange(10, 20), reloaded.CurrentBlockRange)
+	assert.Len(t, reloaded.SortedCacheItems(), 6)
+
+	// The complete segment takes precedence once saved
+	for num := uint64(16); num < 20; num++ {
+		require.NoError(t, reloaded.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", []byte{0x01}))
+	}
+	require.NoError(t, reloaded.saveTruncated(ctx))
+
+	complete := NewOutputCache("module1", store, 10, zap.NewNop())
+	_, err = complete.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, block.NewRange(10, 20), complete.CurrentBlockRange)
+	assert.Len(t, complete.SortedCacheItems(), 10)
+	assert.Equal(t, []string{ComputeSegmentFilename(10, 20, 10)}, listFiles(t, store), "truncated segment replaced")
+}
+
+func TestOutputCache_SaveFinalSegments(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialBlock    uint64
+		truncatedEnd    uint64 // a truncated segment 10 to truncatedEnd is saved beforehand, when set
+		processingStart uint64
+		expectSaved     int // number of outputs saved for segment 10-20, none when 0
+	}{
+		{name: "processed from segment start", processingStart: 10, expectSaved: 10},
+		{name: "processed from middle of segment", processingStart: 14},
+		{name: "processed from module initial block", initialBlock: 14, processingStart: 14, expectSaved: 6},
+		{name: "processed after truncated segment", truncatedEnd: 14, processingStart: 14, expectSaved: 10},
+		{name: "processed after gap in truncated segment", truncatedEnd: 12, processingStart: 14},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dstore.NewMockStore(nil)
+			setBlocks := func(cache *OutputCache, from, to uint64) {
+				for num := from; num < to; num++ {
+					require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", []byte{0x01}))
+				}
+			}
+
+			if test.truncatedEnd != 0 {
+				truncated := NewOutputCache("module1", store, 10, zap.NewNop())
+				_, err := truncated.LoadAtBlock(ctx, 10)
+				require.NoError(t, err)
+				setBlocks(truncated, 10, test.truncatedEnd)
+				require.NoError(t, truncated.saveTruncated(ctx))
+			}
+
+			cache := NewOutputCache("module1", store, 10, zap.NewNop())
+			cache.initialBlock = test.initialBlock
+			_, err := cache.LoadAtBlock(ctx, 10)
+			require.NoError(t, err)
+			cache.SetProcessingStart(test.processingStart)
+			setBlocks(cache, test.processingStart, 23)
+
+			// The last block of the segment is not final yet
+			require.NoError(t, cache.saveFinalSegments(ctx, 18))
+			assert.Equal(t, block.NewRange(10, 20), cache.CurrentBlockRange)
+
+			require.NoError(t, cache.saveFinalSegments(ctx, 21))
+			assert.Equal(t, block.NewRange(20, 30), cache.CurrentBlockRange)
+			assert.Len(t, cache.SortedCacheItems(), 3, "outputs after the saved segment must be kept")
+			require.NoError(t, cache.writer.wait(ctx))
+
+			exists, err := store.FileExists(ctx, ComputeSegmentFilename(10, 20, 10))
+			require.NoError(t, err)
+			require.Equal(t, test.expectSaved != 0, exists)
+			if exists {
+				reloaded := NewOutputCache("module1", store, 10, zap.NewNop())
+				_, err := reloaded.LoadAtBlock(ctx, 10)
+				require.NoError(t, err)
+				assert.Len(t, reloaded.SortedCacheItems(), test.expectSaved)
+			}
+
+			// The following segment is complete
+			require.NoError(t, cache.saveTruncated(ctx))
+			exists, err = store.FileExists(ctx, ComputeSegmentFilename(20, 23, 10))
+			require.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
+
+func TestOutputCache_LoadModuleHashMismatch(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	cache.ModuleHash = "hash1"
+	_, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	for num := uint64(10); num < 20; num++ {
+		require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", []byte{0x01}))
+	}
+	require.NoError(t, cache.saveTruncated(ctx))
+
+	reloaded := NewOutputCache("module1", store, 10, zap.NewNop())
+	reloaded.ModuleHash = "hash1"
+	found, err := reloaded.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	// Same files, as with a misconfigured path, under another module hash
+	other := NewOutputCache("module1", store, 10, zap.NewNop())
+	other.ModuleHash = "hash2"
+	_, err = other.LoadAtBlock(ctx, 10)
+	assert.EqualError(t, err, `loading cache: cache file 0000000010-0000000020.s10.output: written by module hash "hash1", refusing to load it for module "module1" with hash "hash2"`)
+	assert.Empty(t, other.SortedCacheItems())
+}
+
+func TestEncodeKV_ModuleHash(t *testing.T) {
+	tests := []struct {
+		name             string
+		kv               outputKV
+		moduleHash       string
+		compressionLevel int
+	}{
+		{"outputs", outputKV{"10a": {BlockNum: 10, BlockID: "10a", Payload: []byte{0x01}}}, "hash1", 0},
+		{"compressed outputs", outputKV{"10a": {BlockNum: 10, BlockID: "10a", Payload: []byte{0x01}}}, "hash1", DefaultCompressionLevel},
+		{"no outputs", outputKV{}, "hash1", 0},
+		{"no module hash", outputKV{"10a": {BlockNum: 10, BlockID: "10a", Payload: []byte{0x01}}}, "", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cnt, _, err := encodeKV(test.kv, test.moduleHash, test.compressionLevel)
+			require.NoError(t, err)
+
+			kv := outputKV{}
+			format, err := decodeKV(bytes.NewReader(cnt), &kv)
+			require.NoError(t, err)
+			assert.Equal(t, test.moduleHash, format.moduleHash)
+			assert.Equal(t, test.compressionLevel != 0, format.compressed)
+			assert.Equal(t, test.kv, kv)
+		})
+	}
+}
+
+func TestOutputCache_SkippedBlocks(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	_, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: "10a", Number: 10}, "", nil))
+	require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: "11a", Number: 11}, "", []byte{}))
+	require.NoError(t, cache.saveTruncated(ctx))
+
+	reloaded := NewOutputCache("module1", store, 10, zap.NewNop())
+	_, err = reloaded.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+
+	skipped, found := reloaded.Get(&pbsubstreams.Clock{Id: "10a", Number: 10})
+	require.True(t, found)
+	assert.Nil(t, skipped)
+
+	empty, found := reloaded.Get(&pbsubstreams.Clock{Id: "11a", Number: 11})
+	require.True(t, found)
+	assert.NotNil(t, empty)
+	assert.Len(t, empty, 0)
+}
+
+func TestOutputCache_Compression(t *testing.T) {
+	tests := []struct {
+		name             string
+		compressionLevel int
+		expectCompressed bool
+	}{
+		{"uncompressed", 0, false},
+		{"default level", DefaultCompressionLevel, true},
+		{"best level", 19, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dstore.NewMockStore(nil)
+
+			cache := NewOutputCache("module1", store, 10, zap.NewNop())
+			cache.compressionLevel = test.compressionLevel
+			_, err := cache.LoadAtBlock(ctx, 10)
+			require.NoError(t, err)
+			for num := uint64(10); num < 20; num++ {
+				require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", bytes.Repeat([]byte{0x01}, 100)))
+			}
+			require.NoError(t, cache.saveTruncated(ctx))
+
+			reader, err := store.OpenObject(ctx, ComputeSegmentFilename(10, 20, 10))
+			require.NoError(t, err)
+			cnt, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(cnt, formatMagic), "written in the latest format")
+			assert.Equal(t, test.expectCompressed, bytes.HasPrefix(cnt[formatHeaderSize:], zstdMagic))
+
+			_, uncompressedSize, err := cache.encode()
+			require.NoError(t, err)
+			if test.expectCompressed {
+				assert.Less(t, len(cnt), uncompressedSize)
+			} else {
+				assert.Equal(t, formatHeaderSize+uncompressedSize, len(cnt))
+			}
+
+			// Files are read whatever the level of the reading cache
+			reloaded := NewOutputCache("module1", store, 10, zap.NewNop())
+			reloaded.compressionLevel = DefaultCompressionLevel - test.compressionLevel
+			found, err := reloaded.LoadAtBlock(ctx, 10)
+			require.NoError(t, err)
+			require.True(t, found)
+			payload, found := reloaded.Get(&pbsubstreams.Clock{Id: "15a", Number: 15})
+			require.True(t, found)
+			assert.Equal(t, bytes.Repeat([]byte{0x01}, 100), payload)
+		})
+	}
+}
+
+func TestOutputCache_LoadUncompressed(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	store.SetFile(ComputeDBinFilename(10, 20), []byte(`{"10a":{"block_num":10,"block_id":"10a","payload":"AQ==","timestamp":null,"cursor":""}}`+"\n"))
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	found, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	payload, found := cache.Get(&pbsubstreams.Clock{Id: "10a", Number: 10})
+	require.True(t, found)
+	assert.Equal(t, []byte{0x01}, payload)
+}
+
+func TestOutputCache_LoadAtBlockMixedSaveIntervals(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         map[string][2]uint64 // blocks from and to in each file
+		saveInterval  uint64
+		atBlock       uint64
+		expectFound   bool
+		expectRange   *block.Range
+		expectedItems int
+	}{
+		{
+			name:          "legacy file",
+			files:         map[string][2]uint64{ComputeDBinFilename(10, 20): {10, 20}},
+			saveInterval:  10,
+			atBlock:       10,
+			expectFound:   true,
+			expectRange:   block.NewRange(10, 20),
+			expectedItems: 10,
+		},
+		{
+			name:          "legacy truncated file",
+			files:         map[string][2]uint64{ComputeDBinFilename(10, 15): {10, 15}},
+			saveInterval:  10,
+			atBlock:       10,
+			expectFound:   true,
+			expectRange:   block.NewRange(10, 20),
+			expectedItems: 5,
+		},
+		{
+			name:          "complete file of a smaller interval",
+			files:         map[string][2]uint64{ComputeSegmentFilename(10, 15, 5): {10, 15}},
+			saveInterval:  10,
+			atBlock:       10,
+			expectFound:   true,
+			expectRange:   block.NewRange(10, 15),
+			expectedItems: 5,
+		},
+		{
+			name:          "truncated file of a larger interval",
+			files:         map[string][2]uint64{ComputeSegmentFilename(10, 12, 10): {10, 12}},
+			saveInterval:  5,
+			atBlock:       10,
+			expectFound:   true,
+			expectRange:   block.NewRange(10, 15),
+			expectedItems: 2,
+		},
+		{
+			name:          "file of a larger interval holding the block",
+			files:         map[string][2]uint64{ComputeSegmentFilename(0, 20, 20): {0, 20}},
+			saveInterval:  5,
+			atBlock:       15,
+			expectFound:   true,
+			expectRange:   block.NewRange(0, 20),
+			expectedItems: 20,
+		},
+		{
+			name: "furthest file holding the block",
+			files: map[string][2]uint64{
+				ComputeSegmentFilename(10, 15, 5):  {10, 15},
+				ComputeSegmentFilename(10, 20, 10): {10, 20},
+			},
+			saveInterval:  5,
+			atBlock:       12,
+			expectFound:   true,
+			expectRange:   block.NewRange(10, 20),
+			expectedItems: 10,
+		},
+		{
+			name:         "after a file of a smaller interval",
+			files:        map[string][2]uint64{ComputeSegmentFilename(10, 15, 5): {10, 15}},
+			saveInterval: 10,
+			atBlock:      17,
+			expectRange:  block.NewRange(15, 20),
+		},
+		{
+			name:         "no file",
+			saveInterval: 10,
+			atBlock:      17,
+			expectRange:  block.NewRange(10, 20),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dstore.NewMockStore(nil)
+			for filename, blocks := range test.files {
+				kv := outputKV{}
+				for num := blocks[0]; num < blocks[1]; num++ {
+					kv[fmt.Sprintf("%da", num)] = &CacheItem{BlockNum: num, BlockID: fmt.Sprintf("%da", num), Payload: []byte{0x01}}
+				}
+				cnt, _, err := encodeKV(kv, "", DefaultCompressionLevel)
+				require.NoError(t, err)
+				store.SetFile(filename, cnt)
+			}
+
+			cache := NewOutputCache("module1", store, test.saveInterval, zap.NewNop())
+			found, err := cache.LoadAtBlock(ctx, test.atBlock)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectFound, found)
+			assert.Equal(t, test.expectRange, cache.CurrentBlockRange)
+			assert.Len(t, cache.SortedCacheItems(), test.expectedItems)
+		})
+	}
+}
+
+func TestOutputCache_SaveIntervalChange(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	clock := func(num uint64) *pbsubstreams.Clock {
+		return &pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}
+	}
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	_, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	for num := uint64(10); num < 20; num++ {
+		require.NoError(t, cache.Set(clock(num), "", []byte{0x01}))
+	}
+	require.NoError(t, cache.saveFinalSegments(ctx, 19))
+	require.NoError(t, cache.writer.wait(ctx))
+
+	// A smaller interval reads the segment written with the previous one,
+	// and writes its own segments after it
+	smaller := NewOutputCache("module1", store, 5, zap.NewNop())
+	found, err := smaller.LoadAtBlock(ctx, 15)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, block.NewRange(10, 20), smaller.CurrentBlockRange)
+	for num := uint64(20); num < 28; num++ {
+		require.NoError(t, smaller.Set(clock(num), "", []byte{0x02}))
+	}
+	output, found := smaller.Get(clock(15))
+	require.True(t, found)
+	assert.Equal(t, []byte{0x01}, output)
+	output, found = smaller.Get(clock(25))
+	require.True(t, found)
+	assert.Equal(t, []byte{0x02}, output)
+
+	require.NoError(t, smaller.saveFinalSegments(ctx, 27))
+	require.NoError(t, smaller.writer.wait(ctx))
+	assert.Equal(t, block.NewRange(25, 30), smaller.CurrentBlockRange)
+	output, found = smaller.Get(clock(26))
+	require.True(t, found, "outputs after the saved segments kept")
+	assert.Equal(t, []byte{0x02}, output)
+
+	exists, err := store.FileExists(ctx, ComputeSegmentFilename(20, 25, 5))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// The larger interval reads the segment of the smaller one as complete
+	reloaded := NewOutputCache("module1", store, 10, zap.NewNop())
+	found, err = reloaded.LoadAtBlock(ctx, 20)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, block.NewRange(20, 25), reloaded.CurrentBlockRange)
+	assert.Len(t, reloaded.SortedCacheItems(), 5)
+
+	ranges, err := reloaded.ListContinuousCacheRanges(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "[10, 20),[20, 25)", ranges.String())
+}
+
+func TestModulesOutputCache_ModuleSaveBlockIntervals(t *testing.T) {
+	ctx := context.Background()
+	caches := NewModuleOutputCache(10, zap.NewNop())
+	caches.ModuleSaveBlockIntervals = map[string]uint64{"sparse": 100, "unset": 0}
+
+	for name, expectedRange := range map[string]*block.Range{
+		"sparse": block.NewRange(100, 200),
+		"unset":  block.NewRange(150, 160),
+		"dense":  block.NewRange(150, 160),
+	} {
+		cache, err := caches.RegisterModule(&pbsubstreams.Module{Name: name}, "hash_"+name, dstore.NewMockStore(nil))
+		require.NoError(t, err)
+		_, err = cache.LoadAtBlock(ctx, 155)
+		require.NoError(t, err)
+		assert.Equal(t, expectedRange, cache.CurrentBlockRange, name)
+	}
+}
+
+func TestOutputCache_FailedSegmentWrite(t *testing.T) {
+	ctx := context.Background()
+	recorder := &recordingStore{}
+	store := recorder.store(func(filename string) error {
+		if filename == ComputeSegmentFilename(10, 20, 10) {
+			return fmt.Errorf("store unavailable")
+		}
+		return nil
+	})
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	cache.writer.retries = 0
+	_, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	for num := uint64(10); num < 35; num++ {
+		require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", []byte{0x01}))
+	}
+	require.NoError(t, cache.saveFinalSegments(ctx, 19))
+
+	err = cache.saveFinalSegments(ctx, 29)
+	if err == nil {
+		// The failure may not be known yet when saving the next segment
+		err = cache.saveTruncated(ctx)
+	}
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "store unavailable")
+
+	assert.Equal(t, err, cache.saveTruncated(ctx), "no truncated segment after a failed one")
+	caches := &ModulesOutputCache{OutputCaches: map[string]*OutputCache{"module1": cache}, logger: zap.NewNop()}
+	assert.Error(t, caches.Flush(ctx))
+	assert.Empty(t, recorder.filenames())
+}
+
+func TestOutputCache_QuarantineCorruptFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{
+			name:    "truncated json",
+			content: []byte(`{"10a":{"block_num":10,"block_`),
+		},
+		{
+			name:    "damaged zstd frame",
+			content: append(append([]byte{}, zstdMagic...), 0x00, 0x01, 0x02),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dstore.NewMockStore(nil)
+			filename := ComputeSegmentFilename(10, 20, 10)
+			store.SetFile(filename, test.content)
+			quarantined := testutil.ToFloat64(quarantinedFiles.Native().WithLabelValues("module1"))
+
+			cache := NewOutputCache("module1", store, 10, zap.NewNop())
+			found, err := cache.LoadAtBlock(ctx, 12)
+			require.NoError(t, err)
+			assert.False(t, found)
+			assert.Equal(t, block.NewRange(10, 20), cache.CurrentBlockRange)
+			assert.Empty(t, cache.SortedCacheItems())
+
+			assert.Equal(t, []string{filename + corruptSuffix}, listFiles(t, store))
+			assert.Equal(t, quarantined+1, testutil.ToFloat64(quarantinedFiles.Native().WithLabelValues("module1")))
+
+			ranges, err := cache.ListCacheRanges(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, ranges)
+		})
+	}
+}
+
+func TestOutputCache_ReadRange(t *testing.T) {
+	tests := []struct {
+		name              string
+		files             map[string][2]uint64 // blocks from and to in each file
+		readRange         *block.Range
+		expectBlocks      [2]uint64 // blocks from and to read
+		expectNotCachedAt uint64
+	}{
+		{
+			name: "across a save interval change",
+			files: map[string][2]uint64{
+				ComputeSegmentFilename(0, 10, 10):  {0, 10},
+				ComputeSegmentFilename(10, 15, 5):  {10, 15},
+				ComputeSegmentFilename(15, 20, 5):  {15, 20},
+				ComputeSegmentFilename(20, 40, 20): {20, 40},
+			},
+			readRange:    block.NewRange(5, 25),
+			expectBlocks: [2]uint64{5, 25},
+		},
+		{
+			name: "truncated file and the complete one",
+			files: map[string][2]uint64{
+				ComputeSegmentFilename(10, 12, 10): {10, 12},
+				ComputeSegmentFilename(10, 20, 10): {10, 20},
+			},
+			readRange:    block.NewRange(10, 20),
+			expectBlocks: [2]uint64{10, 20},
+		},
+		{
+			name: "tail not cached",
+			files: map[string][2]uint64{
+				ComputeDBinFilename(0, 10):         {0, 10},
+				ComputeSegmentFilename(10, 15, 10): {10, 15},
+			},
+			readRange:         block.NewRange(0, 30),
+			expectBlocks:      [2]uint64{0, 15},
+			expectNotCachedAt: 15,
+		},
+		{
+			name:              "nothing cached",
+			readRange:         block.NewRange(0, 10),
+			expectNotCachedAt: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dstore.NewMockStore(nil)
+			for filename, blocks := range test.files {
+				kv := outputKV{}
+				for num := blocks[0]; num < blocks[1]; num++ {
+					item := &CacheItem{BlockNum: num, BlockID: fmt.Sprintf("%da", num), Payload: []byte{byte(num)}}
+					if num%7 == 0 {
+						item.Payload, item.Skipped = nil, true
+					}
+					kv[item.BlockID] = item
+				}
+				cnt, _, err := encodeKV(kv, "", DefaultCompressionLevel)
+				require.NoError(t, err)
+				store.SetFile(filename, cnt)
+			}
+
+			cache := NewOutputCache("module1", store, 10, zap.NewNop())
+			var read []uint64
+			err := cache.ReadRange(ctx, test.readRange, func(clock *pbsubstreams.Clock, data []byte) error {
+				if clock.Number%7 == 0 {
+					assert.Nil(t, data, "block %d skipped", clock.Number)
+				} else {
+					assert.Equal(t, []byte{byte(clock.Number)}, data)
+				}
+				assert.Equal(t, fmt.Sprintf("%da", clock.Number), clock.Id)
+				read = append(read, clock.Number)
+				return nil
+			})
+
+			var expectRead []uint64
+			for num := test.expectBlocks[0]; num < test.expectBlocks[1]; num++ {
+				expectRead = append(expectRead, num)
+			}
+			assert.Equal(t, expectRead, read)
+
+			if test.expectBlocks[1] == test.readRange.ExclusiveEndBlock {
+				require.NoError(t, err)
+			} else {
+				var notCached *RangeNotCachedError
+				require.True(t, errors.As(err, &notCached), "got %v", err)
+				assert.Equal(t, test.expectNotCachedAt, notCached.CachedUntil)
+			}
+			assert.Nil(t, cache.CurrentBlockRange, "current range untouched")
+		})
+	}
+}
+
+func TestOutputCache_ReadRangeFailure(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	cnt, _, err := encodeKV(outputKV{
+		"10a": {BlockNum: 10, BlockID: "10a", Payload: []byte{0x01}},
+		"11a": {BlockNum: 11, BlockID: "11a", Failure: &CacheFailure{Message: "boom"}},
+	}, "", DefaultCompressionLevel)
+	require.NoError(t, err)
+	store.SetFile(ComputeSegmentFilename(10, 12, 10), cnt)
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	var read []uint64
+	err = cache.ReadRange(ctx, block.NewRange(10, 12), func(clock *pbsubstreams.Clock, data []byte) error {
+		read = append(read, clock.Number)
+		return nil
+	})
+	assert.EqualError(t, err, `module "module1" failed on block 11: boom`)
+	assert.Equal(t, []uint64{10}, read)
+
+	err = cache.ReadRange(ctx, block.NewRange(10, 12), func(clock *pbsubstreams.Clock, data []byte) error {
+		return fmt.Errorf("stop")
+	})
+	assert.EqualError(t, err, "stop")
+}
+
+func TestOutputCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	hits := testutil.ToFloat64(cacheHits.Native().WithLabelValues("stats_module"))
+	written := testutil.ToFloat64(bytesWritten.Native().WithLabelValues("stats_module"))
+
+	writer := NewOutputCache("stats_module", store, 10, zap.NewNop())
+	_, err := writer.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	_, found := writer.Get(&pbsubstreams.Clock{Id: "10a", Number: 10})
+	assert.False(t, found)
+	require.NoError(t, writer.Set(&pbsubstreams.Clock{Id: "10a", Number: 10}, "", []byte{0x01}))
+	require.NoError(t, writer.saveTruncated(ctx))
+
+	reader := NewOutputCache("stats_module", store, 10, zap.NewNop())
+	_, err = reader.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	_, found = reader.Get(&pbsubstreams.Clock{Id: "10a", Number: 10})
+	assert.True(t, found)
+
+	objectReader, err := store.OpenObject(ctx, ComputeSegmentFilename(10, 11, 10))
+	require.NoError(t, err)
+	cnt, err := io.ReadAll(objectReader)
+	require.NoError(t, err)
+	fileSize := uint64(len(cnt))
+	assert.Equal(t, CacheStats{Misses: 1, BytesWritten: fileSize}, writer.Stats())
+	assert.Equal(t, CacheStats{Hits: 1, SegmentsLoaded: 1, BytesRead: fileSize}, reader.Stats())
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, SegmentsLoaded: 1, BytesRead: fileSize, BytesWritten: fileSize}, ModulesCacheStats{"a": writer.Stats(), "b": reader.Stats()}.Total())
+
+	assert.Equal(t, hits+1, testutil.ToFloat64(cacheHits.Native().WithLabelValues("stats_module")))
+	assert.Equal(t, written+float64(fileSize), testutil.ToFloat64(bytesWritten.Native().WithLabelValues("stats_module")))
+}
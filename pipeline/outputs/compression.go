@@ -0,0 +1,36 @@
+package outputs
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultCompressionLevel is the zstd level of the output cache files. A
+// level of 0 writes them uncompressed.
+const DefaultCompressionLevel = 3
+
+// zstdMagic starts every zstd frame. The cache files of format version 0
+// are told apart by it rather than by their name, a JSON file always
+// starting with `{`.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+// zstdEncoders holds one encoder per compression level, EncodeAll being safe
+// for concurrent use.
+var zstdEncoders sync.Map
+
+func zstdEncoder(level int) (*zstd.Encoder, error) {
+	if encoder, found := zstdEncoders.Load(level); found {
+		return encoder.(*zstd.Encoder), nil
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	if err != nil {
+		return nil, fmt.Errorf("creating zstd encoder of level %d: %w", level, err)
+	}
+	actual, _ := zstdEncoders.LoadOrStore(level, encoder)
+	return actual.(*zstd.Encoder), nil
+}
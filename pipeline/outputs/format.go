@@ -0,0 +1,230 @@
+package outputs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Since format version 1, the cache files start with a header:
+//
+//	magic        4 bytes, "sfoc"
+//	version      uint16, big endian
+//	compression  1 byte, compressionNone or compressionZstd
+//
+// followed by the JSON encoded outputs, compressed or not. The files of
+// format version 0, written before, have no header: they hold the JSON
+// encoded outputs, compressed with zstd or not. In both, the hash of the
+// module that wrote the file is recorded in the JSON, under moduleHashKey.
+const (
+	formatVersionLegacy uint16 = 0
+	formatVersion1      uint16 = 1
+
+	// FormatVersion is the format version of the cache files written.
+	FormatVersion = formatVersion1
+)
+
+var formatMagic = []byte("sfoc")
+
+// formatHeaderSize is the size of the header of format version 1
+const formatHeaderSize = 7
+
+const (
+	compressionNone byte = 0
+	compressionZstd byte = 1
+)
+
+// fileFormat describes how a cache file was written.
+type fileFormat struct {
+	version    uint16
+	compressed bool
+	moduleHash string // empty for the files written before it was recorded
+}
+
+// formatDecoders decode the content of the cache files following the magic
+// and version, per format version. Readers know the formats of the versions
+// found here only, the next ones being added before writers emit them.
+var formatDecoders = map[uint16]func(cnt []byte, kv *outputKV, format *fileFormat) error{
+	formatVersionLegacy: decodeLegacy,
+	formatVersion1:      decodeVersion1,
+}
+
+// encodeKV encodes `kv` written by the module of hash `moduleHash` in the
+// latest format version, compressed at `compressionLevel` unless it is 0, and
+// returns the size of the uncompressed JSON as well.
+func encodeKV(kv outputKV, moduleHash string, compressionLevel int) (cnt []byte, uncompressedSize int, err error) {
+	encoded, err := encodeJSON(kv, moduleHash)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := make([]byte, formatHeaderSize)
+	copy(header, formatMagic)
+	binary.BigEndian.PutUint16(header[len(formatMagic):], FormatVersion)
+	if compressionLevel == 0 {
+		header[formatHeaderSize-1] = compressionNone
+		return append(header, encoded...), len(encoded), nil
+	}
+
+	encoder, err := zstdEncoder(compressionLevel)
+	if err != nil {
+		return nil, 0, err
+	}
+	header[formatHeaderSize-1] = compressionZstd
+	return encoder.EncodeAll(encoded, header), len(encoded), nil
+}
+
+// corruptFileError is the error of a cache file read entirely but which
+// cannot be decoded, truncated or otherwise damaged.
+type corruptFileError struct {
+	err error
+}
+
+func (e *corruptFileError) Error() string { return e.err.Error() }
+func (e *corruptFileError) Unwrap() error { return e.err }
+
+// unsupportedFormatError is the error of a cache file written in a format
+// version more recent than the ones known, by a more recent release.
+type unsupportedFormatError struct {
+	version uint16
+}
+
+func (e *unsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported cache file format version %d, the latest known being %d", e.version, FormatVersion)
+}
+
+// decodeKV reads the outputs of a cache file, whatever its format version,
+// and returns how it was written.
+func decodeKV(reader io.Reader, kv *outputKV) (format fileFormat, err error) {
+	cnt, err := io.ReadAll(reader)
+	if err != nil {
+		return format, fmt.Errorf("reading outputs: %w", err)
+	}
+	return unmarshalKV(cnt, kv)
+}
+
+// unmarshalKV decodes the content of a cache file, dispatching on its format
+// version. A *corruptFileError is returned when it cannot be decoded, an
+// *unsupportedFormatError when its version is not known.
+func unmarshalKV(cnt []byte, kv *outputKV) (format fileFormat, err error) {
+	format.version = formatVersionLegacy
+	if bytes.HasPrefix(cnt, formatMagic) {
+		if len(cnt) < len(formatMagic)+2 {
+			return format, &corruptFileError{fmt.Errorf("truncated header")}
+		}
+		format.version = binary.BigEndian.Uint16(cnt[len(formatMagic):])
+		cnt = cnt[len(formatMagic)+2:]
+	}
+
+	decode, found := formatDecoders[format.version]
+	if !found {
+		return format, &unsupportedFormatError{version: format.version}
+	}
+	err = decode(cnt, kv, &format)
+	return format, err
+}
+
+func decodeLegacy(cnt []byte, kv *outputKV, format *fileFormat) error {
+	format.compressed = bytes.HasPrefix(cnt, zstdMagic)
+	return decodeOutputs(cnt, kv, format)
+}
+
+func decodeVersion1(cnt []byte, kv *outputKV, format *fileFormat) error {
+	if len(cnt) < 1 {
+		return &corruptFileError{fmt.Errorf("truncated header")}
+	}
+	switch cnt[0] {
+	case compressionNone:
+	case compressionZstd:
+		format.compressed = true
+	default:
+		return &corruptFileError{fmt.Errorf("unknown compression %d", cnt[0])}
+	}
+	return decodeOutputs(cnt[1:], kv, format)
+}
+
+// decodeOutputs decodes the JSON encoded outputs, compressed or not as told
+// by `format`, which receives the module hash recorded along them.
+func decodeOutputs(cnt []byte, kv *outputKV, format *fileFormat) (err error) {
+	if format.compressed {
+		if cnt, err = zstdDecoder.DecodeAll(cnt, nil); err != nil {
+			return &corruptFileError{fmt.Errorf("zstd decompressing outputs: %w", err)}
+		}
+	}
+	if format.moduleHash, err = decodeJSON(bytes.NewReader(cnt), kv); err != nil {
+		return &corruptFileError{fmt.Errorf("json decoding outputs: %w", err)}
+	}
+	return nil
+}
+
+// moduleHashKey holds the hash of the module that wrote a cache file, next to
+// its outputs keyed by block ID. It is never part of the outputs.
+const moduleHashKey = "__!__module_hash"
+
+// encodeJSON encodes `kv` as the JSON object of the cache files, written by the
+// module of hash `moduleHash`, recorded first under moduleHashKey when set.
+func encodeJSON(kv outputKV, moduleHash string) ([]byte, error) {
+	if kv == nil {
+		kv = outputKV{}
+	}
+	buffer := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buffer).Encode(kv); err != nil {
+		return nil, fmt.Errorf("json encoding outputs: %w", err)
+	}
+	if moduleHash == "" {
+		return buffer.Bytes(), nil
+	}
+
+	encodedHash, err := json.Marshal(moduleHash)
+	if err != nil {
+		return nil, fmt.Errorf("json encoding module hash: %w", err)
+	}
+	cnt := make([]byte, 0, buffer.Len()+len(moduleHashKey)+len(encodedHash)+5)
+	cnt = append(cnt, `{"`+moduleHashKey+`":`...)
+	cnt = append(cnt, encodedHash...)
+	if len(kv) != 0 {
+		cnt = append(cnt, ',')
+	}
+	return append(cnt, buffer.Bytes()[1:]...), nil
+}
+
+// decodeJSON decodes the JSON object of a cache file read from `r` into `kv`,
+// and returns the hash of the module that wrote it, empty for the files
+// written before it was recorded.
+func decodeJSON(r io.Reader, kv *outputKV) (moduleHash string, err error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return "", err
+	} else if token != json.Delim('{') {
+		return "", fmt.Errorf("expected an object, got %v", token)
+	}
+
+	if *kv == nil {
+		*kv = make(outputKV)
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		key := token.(string)
+		if key == moduleHashKey {
+			if err := decoder.Decode(&moduleHash); err != nil {
+				return "", fmt.Errorf("decoding module hash: %w", err)
+			}
+			continue
+		}
+
+		item := &CacheItem{}
+		if err := decoder.Decode(item); err != nil {
+			return "", fmt.Errorf("decoding output %q: %w", key, err)
+		}
+		(*kv)[key] = item
+	}
+	if _, err := decoder.Token(); err != nil {
+		return "", err
+	}
+	return moduleHash, nil
+}
@@ -0,0 +1,163 @@
+package outputs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testJSON = `{"10a":{"block_num":10,"block_id":"10a","payload":"AQ==","timestamp":null,"cursor":""}}` + "\n"
+
+func versionHeader(version uint16) []byte {
+	header := make([]byte, len(formatMagic)+2)
+	copy(header, formatMagic)
+	binary.BigEndian.PutUint16(header[len(formatMagic):], version)
+	return header
+}
+
+func TestUnmarshalKV(t *testing.T) {
+	encoder, err := zstdEncoder(DefaultCompressionLevel)
+	require.NoError(t, err)
+	zstdJSON := encoder.EncodeAll([]byte(testJSON), nil)
+
+	tests := []struct {
+		name              string
+		content           []byte
+		expectFormat      fileFormat
+		expectCorrupt     bool
+		expectUnsupported bool
+	}{
+		{
+			name:         "legacy json",
+			content:      []byte(testJSON),
+			expectFormat: fileFormat{version: formatVersionLegacy},
+		},
+		{
+			name:         "legacy zstd",
+			content:      zstdJSON,
+			expectFormat: fileFormat{version: formatVersionLegacy, compressed: true},
+		},
+		{
+			name:         "version 1 uncompressed",
+			content:      append(append(versionHeader(1), compressionNone), testJSON...),
+			expectFormat: fileFormat{version: formatVersion1},
+		},
+		{
+			name:         "version 1 zstd",
+			content:      append(append(versionHeader(1), compressionZstd), zstdJSON...),
+			expectFormat: fileFormat{version: formatVersion1, compressed: true},
+		},
+		{
+			name:         "version 1 with module hash",
+			content:      append(append(versionHeader(1), compressionNone), `{"__!__module_hash":"hash1",`+testJSON[1:]...),
+			expectFormat: fileFormat{version: formatVersion1, moduleHash: "hash1"},
+		},
+		{
+			name:              "version unknown",
+			content:           append(append(versionHeader(FormatVersion+1), 0x42), testJSON...),
+			expectFormat:      fileFormat{version: FormatVersion + 1},
+			expectUnsupported: true,
+		},
+		{
+			name:          "truncated header",
+			content:       formatMagic[:4],
+			expectCorrupt: true,
+		},
+		{
+			name:          "unknown compression",
+			content:       append(append(versionHeader(1), 0x42), testJSON...),
+			expectFormat:  fileFormat{version: formatVersion1},
+			expectCorrupt: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kv := outputKV{}
+			format, err := unmarshalKV(test.content, &kv)
+
+			var corrupt *corruptFileError
+			var unsupported *unsupportedFormatError
+			assert.Equal(t, test.expectCorrupt, errors.As(err, &corrupt), "corrupt: %v", err)
+			assert.Equal(t, test.expectUnsupported, errors.As(err, &unsupported), "unsupported: %v", err)
+			if test.expectCorrupt || test.expectUnsupported {
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectFormat, format)
+			require.Contains(t, kv, "10a")
+			assert.Equal(t, []byte{0x01}, kv["10a"].Payload)
+		})
+	}
+}
+
+func TestEncodeKV_LatestFormat(t *testing.T) {
+	for _, level := range []int{0, DefaultCompressionLevel} {
+		cnt, _, err := encodeKV(outputKV{"10a": {BlockNum: 10, BlockID: "10a", Payload: []byte{0x01}}}, "hash1", level)
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(cnt, versionHeader(FormatVersion)))
+
+		kv := outputKV{}
+		format, err := unmarshalKV(cnt, &kv)
+		require.NoError(t, err)
+		assert.Equal(t, fileFormat{version: FormatVersion, compressed: level != 0, moduleHash: "hash1"}, format)
+		assert.Len(t, kv, 1)
+	}
+}
+
+func TestOutputCache_UnsupportedFormat(t *testing.T) {
+	ctx := context.Background()
+	store := (&recordingStore{}).store(func(filename string) error { return nil })
+	filename := ComputeSegmentFilename(10, 20, 10)
+	store.SetFile(filename, append(append(versionHeader(FormatVersion+1), 0x42), testJSON...))
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	found, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	assert.False(t, found, "executed again")
+	assert.Equal(t, []string{filename}, listFiles(t, store), "not quarantined")
+}
+
+func TestMigrateSegments(t *testing.T) {
+	ctx := context.Background()
+	store := (&recordingStore{}).store(func(filename string) error { return nil })
+	encoder, err := zstdEncoder(DefaultCompressionLevel)
+	require.NoError(t, err)
+	latest, _, err := encodeKV(outputKV{"20a": {BlockNum: 20, BlockID: "20a", Payload: []byte{0x01}}}, "hash1", DefaultCompressionLevel)
+	require.NoError(t, err)
+
+	legacyJSON := "hash1/outputs/" + ComputeDBinFilename(10, 20)
+	legacyZstd := "hash2/outputs/" + ComputeDBinFilename(10, 20)
+	store.SetFile(legacyJSON, []byte(testJSON))
+	store.SetFile(legacyZstd, encoder.EncodeAll([]byte(testJSON), nil))
+	store.SetFile("hash1/outputs/"+ComputeSegmentFilename(20, 30, 10), latest)
+	store.SetFile("hash1/states/0000000100-0000000000.kv", []byte("not a cache file"))
+
+	migrated, err := MigrateSegments(ctx, store, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{legacyJSON, legacyZstd}, migrated)
+
+	for filename, compressed := range map[string]bool{legacyJSON: false, legacyZstd: true} {
+		reader, err := store.OpenObject(ctx, filename)
+		require.NoError(t, err)
+		cnt, err := io.ReadAll(reader)
+		require.NoError(t, err)
+
+		kv := outputKV{}
+		format, err := unmarshalKV(cnt, &kv)
+		require.NoError(t, err)
+		assert.Equal(t, fileFormat{version: FormatVersion, compressed: compressed}, format, filename)
+		assert.Equal(t, []byte{0x01}, kv["10a"].Payload)
+	}
+
+	migrated, err = MigrateSegments(ctx, store, "hash1/")
+	require.NoError(t, err)
+	assert.Empty(t, migrated, "migrated already")
+}
@@ -0,0 +1,176 @@
+package outputs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/block"
+	"go.uber.org/zap"
+)
+
+// segmentIndexFilename is the name of the segment index of a module, next to
+// its `outputs` folder under the module hash. It is kept out of the folder
+// so that the releases listing it do not choke on a file which is not a
+// segment.
+const segmentIndexFilename = "outputs.index"
+
+// segmentIndex lists the cache files of a module with their block ranges,
+// sparing the listing of the store to find the file holding a block. It is
+// loaded once per request and written anew after each segment written.
+//
+// The index only ever speeds up lookups: segments written by other
+// processes may be missing from it, when their updates raced or the store
+// does not overwrite objects, so a block not found in it is looked up by
+// listing the store as before.
+type segmentIndex struct {
+	store    dstore.Store
+	filename string
+	logger   *zap.Logger
+
+	lock     sync.Mutex
+	loaded   bool
+	segments map[string]*block.Range // by file name
+}
+
+type segmentIndexFile struct {
+	Segments []segmentIndexEntry `json:"segments"`
+}
+
+type segmentIndexEntry struct {
+	Filename string `json:"file"`
+	Start    uint64 `json:"start"`
+	End      uint64 `json:"end"`
+}
+
+func newSegmentIndex(store dstore.Store, filename string, logger *zap.Logger) *segmentIndex {
+	return &segmentIndex{
+		store:    store,
+		filename: filename,
+		logger:   logger,
+		segments: map[string]*block.Range{},
+	}
+}
+
+// load reads the index from the store the first time it is called, an index
+// missing or unreadable being taken as empty.
+func (i *segmentIndex) load(ctx context.Context) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.loaded {
+		return
+	}
+	i.loaded = true
+
+	reader, err := i.store.OpenObject(ctx, i.filename)
+	if err != nil {
+		if !errors.Is(err, dstore.ErrNotFound) {
+			i.logger.Warn("unable to open segment index, listing the cache files instead", zap.String("filename", i.filename), zap.Error(err))
+		}
+		return
+	}
+	defer reader.Close()
+
+	file := &segmentIndexFile{}
+	cnt, err := io.ReadAll(reader)
+	if err == nil {
+		err = json.Unmarshal(cnt, file)
+	}
+	if err != nil {
+		i.logger.Warn("unable to read segment index, listing the cache files instead", zap.String("filename", i.filename), zap.Error(err))
+		return
+	}
+	for _, entry := range file.Segments {
+		i.segments[entry.Filename] = block.NewRange(entry.Start, entry.End)
+	}
+}
+
+// find returns the indexed cache file holding `atBlock`, chosen like
+// findSegmentFile does, or nil when the index has none.
+func (i *segmentIndex) find(ctx context.Context, atBlock uint64) *segmentFile {
+	i.load(ctx)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	var files []*segmentFile
+	for filename := range i.segments {
+		file, err := fileNameToSegment(filename)
+		if err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	found, _ := pickSegmentFile(files, atBlock, atBlock)
+	return found
+}
+
+// ranges returns the block ranges of the indexed cache files, sorted.
+func (i *segmentIndex) ranges(ctx context.Context) (out block.Ranges) {
+	i.load(ctx)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, r := range i.segments {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].StartBlock < out[b].StartBlock
+	})
+	return out
+}
+
+// add records the cache file `filename`, just written, and writes the index.
+func (i *segmentIndex) add(ctx context.Context, filename string) {
+	r, err := fileNameToRange(filename)
+	if err != nil {
+		return
+	}
+	i.load(ctx)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.segments[filename] = r
+	i.save(ctx)
+}
+
+// remove forgets the cache file `filename`, deleted or set aside, and writes
+// the index.
+func (i *segmentIndex) remove(ctx context.Context, filename string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if _, found := i.segments[filename]; !found {
+		return
+	}
+	delete(i.segments, filename)
+	i.save(ctx)
+}
+
+// save writes the index, a failure leaving the previous one, which only
+// makes lookups fall back to listing.
+func (i *segmentIndex) save(ctx context.Context) {
+	file := &segmentIndexFile{}
+	for filename, r := range i.segments {
+		file.Segments = append(file.Segments, segmentIndexEntry{Filename: filename, Start: r.StartBlock, End: r.ExclusiveEndBlock})
+	}
+	sort.Slice(file.Segments, func(a, b int) bool {
+		return file.Segments[a].Filename < file.Segments[b].Filename
+	})
+
+	cnt, err := json.Marshal(file)
+	if err == nil {
+		err = i.store.WriteObject(ctx, i.filename, bytes.NewReader(cnt))
+	}
+	if err != nil {
+		i.logger.Warn("unable to write segment index", zap.String("filename", i.filename), zap.Error(err))
+	}
+}
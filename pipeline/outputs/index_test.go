@@ -0,0 +1,86 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/block"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOutputCache_SegmentIndex(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	indexStore := dstore.NewMockStore(nil)
+	indexStore.SetOverwrite(true)
+	newCache := func() *OutputCache {
+		cache := NewOutputCache("module1", store, 10, zap.NewNop())
+		cache.index = newSegmentIndex(indexStore, segmentIndexFilename, zap.NewNop())
+		return cache
+	}
+
+	writer := newCache()
+	writer.writer.maxPending = 0
+	_, err := writer.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	for num := uint64(10); num < 25; num++ {
+		require.NoError(t, writer.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", []byte{0x01}))
+	}
+	require.NoError(t, writer.saveFinalSegments(ctx, 19))
+	require.NoError(t, writer.saveTruncated(ctx))
+
+	// Found without listing the store
+	listings := 0
+	store.ListFilesFunc = func(ctx context.Context, prefix string, max int) ([]string, error) {
+		listings++
+		return nil, nil
+	}
+	reader := newCache()
+	found, err := reader.LoadAtBlock(ctx, 22)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, block.NewRange(20, 30), reader.CurrentBlockRange, "truncated segment")
+	assert.Equal(t, 0, listings)
+
+	ranges, err := reader.ListContinuousCacheRanges(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, block.Ranges{block.NewRange(10, 20), block.NewRange(20, 25)}, ranges)
+
+	// Blocks missing from the index are listed
+	found, err = reader.LoadAtBlock(ctx, 32)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 1, listings)
+	store.ListFilesFunc = nil
+
+	// Files gone are dropped from the index, then listed
+	require.NoError(t, store.DeleteObject(ctx, ComputeSegmentFilename(10, 20, 10)))
+	store.OpenObjectFunc = func(ctx context.Context, name string) (out io.ReadCloser, err error) {
+		return nil, dstore.ErrNotFound
+	}
+	reader = newCache()
+	found, err = reader.LoadAtBlock(ctx, 12)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, block.NewRange(10, 20), reader.CurrentBlockRange)
+
+	ranges, err = newCache().ListContinuousCacheRanges(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, block.Ranges{block.NewRange(20, 25)}, ranges, "index written without the file gone")
+}
+
+func TestSegmentIndex_Unreadable(t *testing.T) {
+	ctx := context.Background()
+	indexStore := dstore.NewMockStore(nil)
+	indexStore.SetFile(segmentIndexFilename, []byte(`{"segments":[{"file":"0000000010-`))
+
+	index := newSegmentIndex(indexStore, segmentIndexFilename, zap.NewNop())
+	assert.Nil(t, index.find(ctx, 12))
+	assert.Empty(t, index.ranges(ctx))
+}
@@ -0,0 +1,335 @@
+package outputs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+// localEntryHeaderSize is the size of the header of the local copies: the
+// size of the object, uint64 big endian, then its sha256.
+const localEntryHeaderSize = 8 + sha256.Size
+
+// localTempMaxAge is the age past which the temporary files of the local
+// copies, left behind by interrupted processes, are deleted.
+const localTempMaxAge = time.Hour
+
+// LocalCache keeps copies of the output cache files read from the cache
+// store in a local directory, bounded to a byte budget, the least recently
+// used copies being evicted past it. It sits in front of the cache stores
+// of the modules wrapped with Wrap.
+//
+// The directory can be shared by concurrent processes: copies are written
+// to a temporary file then renamed, so a reader never sees a partial one,
+// and the time they were last used is their modification time. Each copy
+// starts with the size and checksum of the object, a copy not matching them
+// is deleted and the object read from the store again. The object is still
+// checked to exist on the store before a copy is used, so the files deleted
+// from the store, by the retention or the quarantine of another process, are
+// not served. The copies of the files rewritten in place by another process
+// are only refreshed once evicted.
+type LocalCache struct {
+	dir      string
+	maxBytes int64
+
+	lock sync.Mutex
+	size int64 // of the copies, as last scanned plus the copies written since
+}
+
+func NewLocalCache(dir string, maxBytes int64) (*LocalCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating local cache directory %q: %w", dir, err)
+	}
+	c := &LocalCache{dir: dir, maxBytes: maxBytes}
+	if err := c.evict(); err != nil {
+		return nil, fmt.Errorf("scanning local cache directory %q: %w", dir, err)
+	}
+	return c, nil
+}
+
+// Wrap returns `store` with the reads of the output cache files of
+// `moduleName` served from the local copies when possible. Writes and
+// deletions go to `store` first, then to the local copies.
+func (c *LocalCache) Wrap(store dstore.Store, moduleName string) dstore.Store {
+	prefix := sha256.Sum256([]byte(store.ObjectURL("")))
+	return &layeredStore{
+		Store:      store,
+		local:      c,
+		moduleName: moduleName,
+		dir:        filepath.Join(c.dir, hex.EncodeToString(prefix[:8])),
+	}
+}
+
+// layeredStore is a store of output cache files whose reads are served from
+// the copies of a LocalCache when possible.
+type layeredStore struct {
+	dstore.Store
+	local      *LocalCache
+	moduleName string
+	dir        string // of the copies of the objects of Store
+}
+
+func (s *layeredStore) localFilename(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+func (s *layeredStore) OpenObject(ctx context.Context, name string) (io.ReadCloser, error) {
+	if isIgnoredFilename(name) {
+		return s.Store.OpenObject(ctx, name)
+	}
+
+	filename := s.localFilename(name)
+	if cnt := s.local.read(filename); cnt != nil {
+		exists, err := s.Store.FileExists(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			localCacheHits.Inc(s.moduleName)
+			return io.NopCloser(bytes.NewReader(cnt)), nil
+		}
+		s.local.remove(filename)
+		return nil, dstore.ErrNotFound
+	}
+	localCacheMisses.Inc(s.moduleName)
+
+	reader, err := s.Store.OpenObject(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	cnt, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	s.local.write(filename, cnt)
+	return io.NopCloser(bytes.NewReader(cnt)), nil
+}
+
+func (s *layeredStore) WriteObject(ctx context.Context, name string, f io.Reader) error {
+	if isIgnoredFilename(name) {
+		return s.Store.WriteObject(ctx, name, f)
+	}
+
+	cnt, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.WriteObject(ctx, name, bytes.NewReader(cnt)); err != nil {
+		return err
+	}
+
+	filename := s.localFilename(name)
+	if !s.Store.Overwrite() {
+		// The store may have kept the object there already, copied again
+		// from the store on the next read
+		s.local.remove(filename)
+		return nil
+	}
+	s.local.write(filename, cnt)
+	return nil
+}
+
+func (s *layeredStore) PushLocalFile(ctx context.Context, localFile, toBaseName string) error {
+	if err := s.Store.PushLocalFile(ctx, localFile, toBaseName); err != nil {
+		return err
+	}
+	s.local.remove(s.localFilename(toBaseName))
+	return nil
+}
+
+func (s *layeredStore) DeleteObject(ctx context.Context, name string) error {
+	if err := s.Store.DeleteObject(ctx, name); err != nil {
+		return err
+	}
+	s.local.remove(s.localFilename(name))
+	return nil
+}
+
+func (s *layeredStore) SubStore(subFolder string) (dstore.Store, error) {
+	store, err := s.Store.SubStore(subFolder)
+	if err != nil {
+		return nil, err
+	}
+	return s.local.Wrap(store, s.moduleName), nil
+}
+
+// read returns the content of the local copy `filename`, or nil when there
+// is none or it does not match its header, deleting it then.
+func (c *LocalCache) read(filename string) []byte {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zlog.Warn("unable to read local cache copy", zap.String("filename", filename), zap.Error(err))
+		}
+		return nil
+	}
+
+	if len(content) < localEntryHeaderSize {
+		c.discard(filename, fmt.Errorf("copy of %d bytes is too short", len(content)))
+		return nil
+	}
+	size, checksum, cnt := binary.BigEndian.Uint64(content), content[8:localEntryHeaderSize], content[localEntryHeaderSize:]
+	if size != uint64(len(cnt)) {
+		c.discard(filename, fmt.Errorf("size mismatch, %d bytes instead of %d", len(cnt), size))
+		return nil
+	}
+	if sum := sha256.Sum256(cnt); !bytes.Equal(checksum, sum[:]) {
+		c.discard(filename, fmt.Errorf("checksum mismatch"))
+		return nil
+	}
+
+	// Most recently used, for the eviction
+	now := time.Now()
+	_ = os.Chtimes(filename, now, now)
+	return cnt
+}
+
+func (c *LocalCache) discard(filename string, cause error) {
+	zlog.Warn("discarding corrupt local cache copy", zap.String("filename", filename), zap.Error(cause))
+	c.remove(filename)
+}
+
+func (c *LocalCache) remove(filename string) {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		zlog.Warn("unable to delete local cache copy", zap.String("filename", filename), zap.Error(err))
+	}
+}
+
+// write keeps `cnt` as the local copy `filename`, evicting the least recently
+// used copies when over the budget. The local copies are only an
+// optimization, failing to write one is logged.
+func (c *LocalCache) write(filename string, cnt []byte) {
+	if int64(len(cnt)+localEntryHeaderSize) > c.maxBytes {
+		return
+	}
+	if err := c.writeFile(filename, cnt); err != nil {
+		zlog.Warn("unable to write local cache copy", zap.String("filename", filename), zap.Error(err))
+		return
+	}
+
+	c.lock.Lock()
+	c.size += int64(len(cnt) + localEntryHeaderSize)
+	overBudget := c.size > c.maxBytes
+	c.lock.Unlock()
+
+	if overBudget {
+		if err := c.evict(); err != nil {
+			zlog.Warn("unable to evict local cache copies", zap.String("dir", c.dir), zap.Error(err))
+		}
+	}
+}
+
+func (c *LocalCache) writeFile(filename string, cnt []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	header := make([]byte, localEntryHeaderSize)
+	binary.BigEndian.PutUint64(header, uint64(len(cnt)))
+	checksum := sha256.Sum256(cnt)
+	copy(header[8:], checksum[:])
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(header)
+	if err == nil {
+		_, err = tmp.Write(cnt)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	// Atomic, another process writing the same copy at the same time writes
+	// the same content
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("renaming temporary file: %w", err)
+	}
+	return nil
+}
+
+type localCopy struct {
+	filename string
+	size     int64
+	usedAt   time.Time
+}
+
+// evict scans the copies of the directory, the ones of the other processes
+// sharing it included, and deletes the least recently used ones until they
+// take 90% of the budget at most, leaving room for the next copies. The
+// temporary files of interrupted writes are deleted as well.
+func (c *LocalCache) evict() error {
+	var copies []localCopy
+	var total int64
+	now := time.Now()
+	err := filepath.WalkDir(c.dir, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Deleted by another process meanwhile
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if strings.Contains(entry.Name(), ".tmp-") {
+			if now.Sub(info.ModTime()) > localTempMaxAge {
+				c.remove(filename)
+			}
+			return nil
+		}
+		copies = append(copies, localCopy{filename: filename, size: info.Size(), usedAt: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(copies, func(i, j int) bool { return copies[i].usedAt.Before(copies[j].usedAt) })
+	target := c.maxBytes / 10 * 9
+	evicted := 0
+	for _, entry := range copies {
+		if total <= target {
+			break
+		}
+		c.remove(entry.filename)
+		total -= entry.size
+		evicted++
+	}
+	if evicted > 0 {
+		zlog.Debug("local cache copies evicted", zap.String("dir", c.dir), zap.Int("evicted_count", evicted), zap.Int64("size", total))
+	}
+
+	c.lock.Lock()
+	c.size = total
+	c.lock.Unlock()
+	return nil
+}
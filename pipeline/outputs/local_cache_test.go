@@ -0,0 +1,124 @@
+package outputs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readObject(t *testing.T, store dstore.Store, name string) ([]byte, error) {
+	reader, err := store.OpenObject(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	cnt, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return cnt, nil
+}
+
+func TestLocalCache_Reads(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocalCache(t.TempDir(), 1024)
+	require.NoError(t, err)
+
+	remote := dstore.NewMockStore(nil)
+	filename := ComputeSegmentFilename(10, 20, 10)
+	remote.SetFile(filename, []byte("segment"))
+	store := local.Wrap(remote, "module1").(*layeredStore)
+
+	cnt, err := readObject(t, store, filename)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("segment"), cnt)
+
+	// Served from the local copy
+	remote.OpenObjectFunc = func(ctx context.Context, name string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("read %s", name)
+	}
+	cnt, err = readObject(t, store, filename)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("segment"), cnt)
+	remote.OpenObjectFunc = nil
+
+	// Corrupt copies are read from the store again
+	copyName := store.localFilename(filename)
+	content, err := os.ReadFile(copyName)
+	require.NoError(t, err)
+	content[len(content)-1] = 'x'
+	require.NoError(t, os.WriteFile(copyName, content, 0644))
+	cnt, err = readObject(t, store, filename)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("segment"), cnt)
+
+	// Copies of the objects deleted from the store are not served
+	require.NoError(t, remote.DeleteObject(ctx, filename))
+	_, err = readObject(t, store, filename)
+	assert.ErrorIs(t, err, dstore.ErrNotFound)
+	assert.NoFileExists(t, copyName)
+}
+
+func TestLocalCache_Writes(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocalCache(t.TempDir(), 1024)
+	require.NoError(t, err)
+
+	remote := dstore.NewMockStore(nil)
+	remote.SetOverwrite(true)
+	store := local.Wrap(remote, "module1").(*layeredStore)
+	filename := ComputeSegmentFilename(10, 20, 10)
+
+	require.NoError(t, store.WriteObject(ctx, filename, bytes.NewReader([]byte("segment"))))
+	assert.Equal(t, []byte("segment"), local.read(store.localFilename(filename)), "written to both")
+
+	require.NoError(t, store.DeleteObject(ctx, filename))
+	assert.NoFileExists(t, store.localFilename(filename))
+
+	// Not copied without overwrites, the store may have kept another object
+	remote.SetOverwrite(false)
+	require.NoError(t, store.WriteObject(ctx, filename, bytes.NewReader([]byte("segment"))))
+	assert.NoFileExists(t, store.localFilename(filename))
+}
+
+func TestLocalCache_Eviction(t *testing.T) {
+	dir := t.TempDir()
+	entrySize := int64(100 + localEntryHeaderSize)
+	// Room for 3 copies and a half, evicting down to 90% keeps 3
+	maxBytes := 3*entrySize + entrySize/2
+	local, err := NewLocalCache(dir, maxBytes)
+	require.NoError(t, err)
+
+	remote := dstore.NewMockStore(nil)
+	store := local.Wrap(remote, "module1").(*layeredStore)
+	for i, name := range []string{"a", "b", "c"} {
+		remote.SetFile(name, make([]byte, 100))
+		_, err := readObject(t, store, name)
+		require.NoError(t, err)
+		usedAt := time.Now().Add(time.Duration(i-10) * time.Minute)
+		require.NoError(t, os.Chtimes(store.localFilename(name), usedAt, usedAt))
+	}
+	// Most recently used
+	_, err = readObject(t, store, "a")
+	require.NoError(t, err)
+
+	remote.SetFile("d", make([]byte, 100))
+	_, err = readObject(t, store, "d")
+	require.NoError(t, err)
+
+	assert.FileExists(t, store.localFilename("a"))
+	assert.NoFileExists(t, store.localFilename("b"), "least recently used")
+	assert.FileExists(t, store.localFilename("c"))
+	assert.FileExists(t, store.localFilename("d"))
+
+	// The size is known from the directory, shared with other processes
+	restarted, err := NewLocalCache(dir, maxBytes)
+	require.NoError(t, err)
+	assert.Equal(t, 3*entrySize, restarted.size)
+}
@@ -0,0 +1,7 @@
+package outputs
+
+import (
+	"github.com/streamingfast/logging"
+)
+
+var zlog, _ = logging.PackageLogger("outputs", "github.com/streamingfast/substreams/pipeline/outputs")
@@ -0,0 +1,31 @@
+package outputs
+
+import (
+	"github.com/streamingfast/dmetrics"
+)
+
+// MetricsSet holds the metrics of the output cache, to be registered by the
+// application running the service.
+var MetricsSet = dmetrics.NewSet()
+
+var quarantinedFiles = MetricsSet.NewCounterVec("substreams_output_cache_quarantined_files", []string{"module"}, "Corrupt output cache files set aside under a `.corrupt` name, per module")
+
+var segmentCacheHits = MetricsSet.NewCounterVec("substreams_output_cache_segment_hits", []string{"module"}, "Output cache segments loaded from memory, or from the download of a concurrent request, per module")
+
+var segmentCacheMisses = MetricsSet.NewCounterVec("substreams_output_cache_segment_misses", []string{"module"}, "Output cache segments downloaded and decoded because the segment cache did not hold them, per module")
+
+var cacheHits = MetricsSet.NewCounterVec("substreams_output_cache_hits", []string{"module"}, "Module outputs served from the output cache, per module")
+
+var cacheMisses = MetricsSet.NewCounterVec("substreams_output_cache_misses", []string{"module"}, "Module outputs not found in the output cache, the module being executed, per module")
+
+var segmentsLoaded = MetricsSet.NewCounterVec("substreams_output_cache_segments_loaded", []string{"module"}, "Output cache segments loaded, from memory or from the store, per module")
+
+var bytesRead = MetricsSet.NewCounterVec("substreams_output_cache_bytes_read", []string{"module"}, "Bytes of output cache files read from the store, per module")
+
+var bytesWritten = MetricsSet.NewCounterVec("substreams_output_cache_bytes_written", []string{"module"}, "Bytes of output cache files written to the store, per module")
+
+var localCacheHits = MetricsSet.NewCounterVec("substreams_output_cache_local_hits", []string{"module"}, "Output cache files read from their local copy, per module")
+
+var localCacheMisses = MetricsSet.NewCounterVec("substreams_output_cache_local_misses", []string{"module"}, "Output cache files read from the store because they had no local copy, per module")
+
+var readAheadHits = MetricsSet.NewCounterVec("substreams_output_cache_read_ahead_hits", []string{"module"}, "Output cache segments read ahead in the background before being reached, per module")
@@ -0,0 +1,74 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/streamingfast/derr"
+	"github.com/streamingfast/dstore"
+)
+
+// MigrateSegments rewrites the cache files found in `store` under `prefix`
+// written in a format version older than FormatVersion, keeping them
+// compressed or not, so they remain readable once the old versions are no
+// longer supported. Objects other than cache files are left alone, so
+// `store` can be the base cache store, `prefix` selecting the module hashes
+// or the block ranges to migrate. It returns the names of the files
+// rewritten, which requires `store` to allow overwrites.
+func MigrateSegments(ctx context.Context, store dstore.Store, prefix string) (migrated []string, err error) {
+	var filenames []string
+	err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+		filenames = nil
+		return store.Walk(ctx, prefix, func(filename string) error {
+			if isIgnoredFilename(filename) {
+				return nil
+			}
+			if _, err := fileNameToSegment(filename); err != nil {
+				return nil
+			}
+			filenames = append(filenames, filename)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking cache outputs: %w", err)
+	}
+
+	for _, filename := range filenames {
+		kv := outputKV{}
+		var format fileFormat
+		err := derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+			objectReader, err := store.OpenObject(ctx, filename)
+			if err != nil {
+				return fmt.Errorf("opening %s: %w", filename, err)
+			}
+			defer objectReader.Close()
+
+			format, err = decodeKV(objectReader, &kv)
+			return err
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("loading %s: %w", filename, err)
+		}
+		if format.version >= FormatVersion {
+			continue
+		}
+
+		compressionLevel := 0
+		if format.compressed {
+			compressionLevel = DefaultCompressionLevel
+		}
+		cnt, _, err := encodeKV(kv, format.moduleHash, compressionLevel)
+		if err != nil {
+			return migrated, err
+		}
+		err = derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+			return writeSegmentFile(ctx, store, filename, cnt)
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("writing %s: %w", filename, err)
+		}
+		migrated = append(migrated, filename)
+	}
+	return migrated, nil
+}
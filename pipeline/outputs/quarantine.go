@@ -0,0 +1,62 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/streamingfast/derr"
+	"go.uber.org/zap"
+)
+
+// corruptSuffix ends the name of the cache files found corrupt, kept aside
+// for inspection and ignored by the readers.
+const corruptSuffix = ".corrupt"
+
+// isIgnoredFilename tells if `filename` is not a segment to the readers: a
+// temporary object or a quarantined file.
+func isIgnoredFilename(filename string) bool {
+	return isTempFilename(filename) || strings.HasSuffix(filename, corruptSuffix)
+}
+
+// withoutIgnoredFiles filters out the temporary objects left behind by
+// interrupted writes and the quarantined files, which readers take as
+// non-existent.
+func withoutIgnoredFiles(filenames []string) (out []string) {
+	for _, filename := range filenames {
+		if !isIgnoredFilename(filename) {
+			out = append(out, filename)
+		}
+	}
+	return out
+}
+
+// quarantine sets the corrupt cache file `filename` aside, copying it under
+// a `.corrupt` name before deleting it, so the blocks it covers are a cache
+// miss from then on.
+func (c *OutputCache) quarantine(ctx context.Context, filename string) error {
+	err := derr.RetryContext(ctx, 3, func(ctx context.Context) error {
+		reader, err := c.Store.OpenObject(ctx, filename)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", filename, err)
+		}
+		defer reader.Close()
+
+		return c.Store.WriteObject(ctx, filename+corruptSuffix, reader)
+	})
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", filename, err)
+	}
+
+	if err := c.Store.DeleteObject(ctx, filename); err != nil {
+		return fmt.Errorf("deleting %s: %w", filename, err)
+	}
+
+	c.invalidateSegment(filename)
+	if c.index != nil {
+		c.index.remove(ctx, filename)
+	}
+	quarantinedFiles.Inc(c.ModuleName)
+	c.logger.Warn("corrupt cache file quarantined", zap.String("module_name", c.ModuleName), zap.String("file_name", filename+corruptSuffix))
+	return nil
+}
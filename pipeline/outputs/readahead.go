@@ -0,0 +1,81 @@
+package outputs
+
+import (
+	"context"
+)
+
+// segmentReadAhead is the lookup and fetch of the segment holding a block,
+// done in the background before the block is reached, so that crossing into
+// the next segment while serving cached blocks does not stall on the store.
+// A module has one at most, the next segment being fetched once one is
+// loaded. Its outputs are kept in memory until taken, and added to the
+// segment cache like any other segment loaded when there is one.
+type segmentReadAhead struct {
+	atBlock uint64
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// Set before done is closed
+	file        *segmentFile
+	previousEnd uint64
+	fromIndex   bool
+	kv          outputKV
+	err         error
+}
+
+// startReadAhead looks up and fetches the segment holding `atBlock` in the
+// background, cancelling the one in flight. It stops with `ctx`, the context
+// of the request.
+func (c *OutputCache) startReadAhead(ctx context.Context, atBlock uint64) {
+	c.cancelReadAhead()
+
+	ctx, cancel := context.WithCancel(ctx)
+	ahead := &segmentReadAhead{atBlock: atBlock, cancel: cancel, done: make(chan struct{})}
+	c.ahead = ahead
+
+	go func() {
+		defer close(ahead.done)
+
+		ahead.file, ahead.previousEnd, ahead.fromIndex, ahead.err = c.findSegmentFile(ctx, atBlock, ComputeStartBlock(atBlock, c.saveBlockInterval))
+		if ahead.err != nil || ahead.file == nil {
+			return
+		}
+		ahead.kv, ahead.err = c.readSegment(ctx, ahead.file.filename)
+	}()
+}
+
+func (c *OutputCache) cancelReadAhead() {
+	if c.ahead != nil {
+		c.ahead.cancel()
+		c.ahead = nil
+	}
+}
+
+// lookupSegment returns the cache file holding `atBlock`, like
+// findSegmentFile, along with its outputs when they were read ahead.
+func (c *OutputCache) lookupSegment(ctx context.Context, atBlock, notBefore uint64) (file *segmentFile, previousEnd uint64, fromIndex bool, kv outputKV, err error) {
+	ahead := c.ahead
+	if ahead != nil && ahead.atBlock != atBlock {
+		c.cancelReadAhead()
+		ahead = nil
+	}
+	c.ahead = nil
+
+	if ahead != nil {
+		select {
+		case <-ahead.done:
+			ahead.cancel()
+			if ahead.err == nil {
+				readAheadHits.Inc(c.ModuleName)
+				return ahead.file, ahead.previousEnd, ahead.fromIndex, ahead.kv, nil
+			}
+			// Looked up again, its error handled like the ones of any load
+		case <-ctx.Done():
+			ahead.cancel()
+			return nil, 0, false, nil, ctx.Err()
+		}
+	}
+
+	file, previousEnd, fromIndex, err = c.findSegmentFile(ctx, atBlock, notBefore)
+	return file, previousEnd, fromIndex, nil, err
+}
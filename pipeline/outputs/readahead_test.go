@@ -0,0 +1,92 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOutputCache_ReadAhead(t *testing.T) {
+	ctx := context.Background()
+	store, err := dstore.NewLocalStore(&url.URL{Scheme: "file", Path: t.TempDir()}, "", "", false)
+	require.NoError(t, err)
+
+	for _, start := range []uint64{10, 20} {
+		kv := outputKV{}
+		for num := start; num < start+10; num++ {
+			kv[fmt.Sprintf("%da", num)] = &CacheItem{BlockNum: num, BlockID: fmt.Sprintf("%da", num), Payload: []byte{0x01}}
+		}
+		cnt, _, err := encodeKV(kv, "", DefaultCompressionLevel)
+		require.NoError(t, err)
+		require.NoError(t, writeSegmentFile(ctx, store, ComputeSegmentFilename(start, start+10, 10), cnt))
+	}
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	cache.readAhead = true
+	found, err := cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NotNil(t, cache.ahead)
+	assert.Equal(t, uint64(20), cache.ahead.atBlock)
+	<-cache.ahead.done
+
+	// Served from the segment read ahead
+	require.NoError(t, store.DeleteObject(ctx, ComputeSegmentFilename(20, 30, 10)))
+	found, err = cache.LoadAtBlock(ctx, 20)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Len(t, cache.SortedCacheItems(), 10)
+	_, ok := cache.Get(&pbsubstreams.Clock{Id: "25a", Number: 25})
+	assert.True(t, ok)
+
+	// Nothing cached past 30, the read ahead finds nothing
+	require.NotNil(t, cache.ahead)
+	ahead := cache.ahead
+	<-ahead.done
+	assert.NoError(t, ahead.err)
+	assert.Nil(t, ahead.file)
+
+	// Loading another block cancels it
+	found, err = cache.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NotNil(t, cache.ahead)
+	assert.Equal(t, uint64(20), cache.ahead.atBlock)
+	assert.NotSame(t, ahead, cache.ahead)
+
+	// Not started when disabled
+	disabled := NewOutputCache("module1", store, 10, zap.NewNop())
+	_, err = disabled.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	assert.Nil(t, disabled.ahead)
+}
+
+func TestOutputCache_ReadAheadCancelled(t *testing.T) {
+	store := dstore.NewMockStore(nil)
+	listing, stopped := make(chan struct{}), make(chan struct{})
+	store.ListFilesFunc = func(ctx context.Context, prefix string, max int) ([]string, error) {
+		close(listing)
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	}
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cache.startReadAhead(ctx, 20)
+	ahead := cache.ahead
+	<-listing
+
+	// The request ends while its next segment is read ahead
+	cancel()
+	<-stopped
+	<-ahead.done
+	assert.Nil(t, ahead.file)
+}
@@ -0,0 +1,54 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/block"
+)
+
+// The functions below read the output caches of a module as written by the
+// servers, for tools. `baseStore` is the cache store of the servers, holding
+// the caches of every module under its hash.
+
+func newCacheReader(baseStore dstore.Store, moduleName, moduleHash string) (*OutputCache, error) {
+	moduleStore, err := baseStore.SubStore(path.Join(moduleHash, "outputs"))
+	if err != nil {
+		return nil, fmt.Errorf("creating substore for module %q: %w", moduleName, err)
+	}
+
+	// The save interval only matters to the writes
+	cache := NewOutputCache(moduleName, moduleStore, 1, zlog)
+	cache.ModuleHash = moduleHash
+	cache.index = newSegmentIndex(baseStore, path.Join(moduleHash, segmentIndexFilename), zlog)
+	return cache, nil
+}
+
+// ReadOutputs calls `fn` with the cached items of the module `moduleName` of
+// hash `moduleHash` over the blocks of `blockRange`, in block order, whatever
+// the save interval, compression and format version of the cache files. The
+// items where the module failed or produced no output are passed as well,
+// with their Failure or Skipped set. It returns a *RangeNotCachedError
+// holding the block reached when the cache files stop before the end of
+// `blockRange`.
+func ReadOutputs(ctx context.Context, baseStore dstore.Store, moduleName, moduleHash string, blockRange *block.Range, fn func(item *CacheItem) error) error {
+	cache, err := newCacheReader(baseStore, moduleName, moduleHash)
+	if err != nil {
+		return err
+	}
+	return cache.readItems(ctx, blockRange, fn)
+}
+
+// ListCachedRanges returns the block ranges of the cache files of the module
+// of hash `moduleHash`, sorted by start block. They are the blocks the files
+// actually hold, a truncated segment saved when a request was interrupted
+// ending at its last block, and being left out once covered by another.
+func ListCachedRanges(ctx context.Context, baseStore dstore.Store, moduleHash string) (block.Ranges, error) {
+	cache, err := newCacheReader(baseStore, "", moduleHash)
+	if err != nil {
+		return nil, err
+	}
+	return cache.ListCacheRanges(ctx)
+}
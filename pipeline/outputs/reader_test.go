@@ -0,0 +1,63 @@
+package outputs
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOutputs(t *testing.T) {
+	ctx := context.Background()
+	baseStore, err := dstore.NewLocalStore(&url.URL{Scheme: "file", Path: t.TempDir()}, "", "", false)
+	require.NoError(t, err)
+	moduleStore, err := baseStore.SubStore("hash1/outputs")
+	require.NoError(t, err)
+
+	write := func(filename string, kv outputKV, compressionLevel int) {
+		cnt, _, err := encodeKV(kv, "hash1", compressionLevel)
+		require.NoError(t, err)
+		require.NoError(t, writeSegmentFile(ctx, moduleStore, filename, cnt))
+	}
+	// Legacy file, then files of another interval, compressed or not
+	require.NoError(t, writeSegmentFile(ctx, moduleStore, ComputeDBinFilename(10, 20), []byte(testJSON)))
+	write(ComputeSegmentFilename(20, 40, 20), outputKV{
+		"20a": {BlockNum: 20, BlockID: "20a", Payload: []byte{0x02}, Cursor: "c20"},
+		"30a": {BlockNum: 30, BlockID: "30a", Skipped: true},
+	}, DefaultCompressionLevel)
+	write(ComputeSegmentFilename(40, 45, 20), outputKV{
+		"41a": {BlockNum: 41, BlockID: "41a", Failure: &CacheFailure{Message: "boom"}},
+	}, 0)
+
+	ranges, err := ListCachedRanges(ctx, baseStore, "hash1")
+	require.NoError(t, err)
+	assert.Equal(t, block.Ranges{block.NewRange(10, 20), block.NewRange(20, 40), block.NewRange(40, 45)}, ranges)
+
+	var read []*CacheItem
+	err = ReadOutputs(ctx, baseStore, "module1", "hash1", block.NewRange(10, 50), func(item *CacheItem) error {
+		read = append(read, item)
+		return nil
+	})
+	var notCached *RangeNotCachedError
+	require.True(t, errors.As(err, &notCached), "got %v", err)
+	assert.Equal(t, uint64(45), notCached.CachedUntil)
+
+	require.Len(t, read, 4)
+	assert.Equal(t, []byte{0x01}, read[0].Payload)
+	assert.Equal(t, "c20", read[1].Cursor)
+	assert.True(t, read[2].Skipped)
+	assert.Equal(t, "boom", read[3].Failure.Message)
+
+	read = nil
+	require.NoError(t, ReadOutputs(ctx, baseStore, "module1", "hash1", block.NewRange(25, 40), func(item *CacheItem) error {
+		read = append(read, item)
+		return nil
+	}))
+	require.Len(t, read, 1)
+	assert.Equal(t, uint64(30), read[0].BlockNum)
+}
@@ -0,0 +1,269 @@
+package outputs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+// accessMarkersPrefix is where the last access of the output caches of each
+// module hash is recorded in the base store, as
+// `outputs-access/<module_hash>/<unix_seconds>`. Markers are written once,
+// a new access writing a new marker and deleting the previous ones, so the
+// store never needs to overwrite them.
+const accessMarkersPrefix = "outputs-access"
+
+// moduleHashMarkersPrefix is where the hashes used by each module name are
+// recorded in the base store by the pipeline, as
+// `modules/<module_name>/<module_hash>`.
+const moduleHashMarkersPrefix = "modules"
+
+// AccessMarkerInterval is how often the access to the output caches of a
+// module hash is recorded at most, per process.
+const AccessMarkerInterval = time.Hour
+
+// touchedHashes holds the last time the access to a module hash was recorded
+// by the process, per base store and module hash.
+var touchedHashes sync.Map
+
+func accessMarkerFilename(moduleHash string, at time.Time) string {
+	return path.Join(accessMarkersPrefix, moduleHash, fmt.Sprintf("%020d", at.Unix()))
+}
+
+// touchAccessMarker records the access to the output caches of `moduleHash`
+// at `now`, unless the process recorded one less than AccessMarkerInterval
+// ago. It returns whether a marker was written.
+func touchAccessMarker(ctx context.Context, baseStore dstore.Store, moduleHash string, now time.Time) (bool, error) {
+	key := baseStore.ObjectURL(moduleHash)
+	if last, found := touchedHashes.Load(key); found && now.Sub(last.(time.Time)) < AccessMarkerInterval {
+		return false, nil
+	}
+	touchedHashes.Store(key, now)
+
+	previous, err := listAccessMarkers(ctx, baseStore, moduleHash)
+	if err != nil {
+		return false, err
+	}
+
+	filename := accessMarkerFilename(moduleHash, now)
+	if err := baseStore.WriteObject(ctx, filename, bytes.NewReader(nil)); err != nil {
+		touchedHashes.Delete(key)
+		return false, fmt.Errorf("writing access marker %s: %w", filename, err)
+	}
+
+	for _, marker := range previous {
+		if marker.filename == filename {
+			continue
+		}
+		if err := baseStore.DeleteObject(ctx, marker.filename); err != nil {
+			return true, fmt.Errorf("deleting access marker %s: %w", marker.filename, err)
+		}
+	}
+	return true, nil
+}
+
+// startAccessMarker records the access to the output caches of `moduleHash`
+// in the background, the request not waiting on it.
+func startAccessMarker(baseStore dstore.Store, moduleHash string, logger *zap.Logger) {
+	go func() {
+		if _, err := touchAccessMarker(context.Background(), baseStore, moduleHash, time.Now()); err != nil {
+			logger.Warn("failed recording output cache access", zap.String("module_hash", moduleHash), zap.Error(err))
+		}
+	}()
+}
+
+type accessMarker struct {
+	filename string
+	at       time.Time
+}
+
+// listAccessMarkers returns the access markers of `moduleHash`, the most
+// recent last.
+func listAccessMarkers(ctx context.Context, baseStore dstore.Store, moduleHash string) (markers []accessMarker, err error) {
+	prefix := path.Join(accessMarkersPrefix, moduleHash) + "/"
+	err = baseStore.Walk(ctx, prefix, func(filename string) error {
+		seconds, err := strconv.ParseInt(strings.TrimPrefix(filename, prefix), 10, 64)
+		if err != nil {
+			// Not a marker
+			return nil
+		}
+		markers = append(markers, accessMarker{filename: filename, at: time.Unix(seconds, 0)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing access markers of module hash %q: %w", moduleHash, err)
+	}
+
+	sort.Slice(markers, func(i, j int) bool { return markers[i].at.Before(markers[j].at) })
+	return markers, nil
+}
+
+// RetentionPolicy tells which output caches ApplyRetention deletes.
+type RetentionPolicy struct {
+	// MaxIdle is the time past which the output caches of a module hash not
+	// accessed are deleted.
+	MaxIdle time.Duration
+	// Keep holds the module names and hashes whose output caches are never
+	// deleted.
+	Keep []string
+	// DryRun only reports the module hashes which would be deleted.
+	DryRun bool
+}
+
+// RetentionResult is the outcome of ApplyRetention, by module hash.
+type RetentionResult struct {
+	// Deleted holds the hashes whose output caches were deleted, or would
+	// be on a dry run.
+	Deleted []string
+	// Kept holds the hashes protected by the Keep list of the policy.
+	Kept []string
+	// FirstSeen holds the hashes without an access recorded yet, marked as
+	// accessed now to be considered by the next runs.
+	FirstSeen []string
+}
+
+// ApplyRetention deletes the output caches, under `<module_hash>/outputs/`,
+// of the module hashes found in `baseStore` and not accessed within
+// `policy.MaxIdle` as of `now`. The module hashes are found from their hash
+// markers and access markers. The store snapshots of the modules are left
+// untouched. A request reading a segment deleted meanwhile executes its
+// module again.
+func ApplyRetention(ctx context.Context, baseStore dstore.Store, policy RetentionPolicy, now time.Time, logger *zap.Logger) (*RetentionResult, error) {
+	if policy.MaxIdle <= 0 {
+		return nil, fmt.Errorf("invalid max idle time %s, it must be positive", policy.MaxIdle)
+	}
+
+	keep := map[string]bool{}
+	for _, entry := range policy.Keep {
+		keep[entry] = true
+	}
+
+	// Module names per hash, from the hash markers
+	hashes := map[string][]string{}
+	err := baseStore.Walk(ctx, moduleHashMarkersPrefix+"/", func(filename string) error {
+		parts := strings.Split(strings.TrimPrefix(filename, moduleHashMarkersPrefix+"/"), "/")
+		if len(parts) != 2 {
+			return nil
+		}
+		hashes[parts[1]] = append(hashes[parts[1]], parts[0])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing module hash markers: %w", err)
+	}
+	err = baseStore.Walk(ctx, accessMarkersPrefix+"/", func(filename string) error {
+		parts := strings.Split(strings.TrimPrefix(filename, accessMarkersPrefix+"/"), "/")
+		if len(parts) != 2 {
+			return nil
+		}
+		if _, found := hashes[parts[0]]; !found {
+			hashes[parts[0]] = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing access markers: %w", err)
+	}
+
+	sortedHashes := make([]string, 0, len(hashes))
+	for hash := range hashes {
+		sortedHashes = append(sortedHashes, hash)
+	}
+	sort.Strings(sortedHashes)
+
+	result := &RetentionResult{}
+	for _, hash := range sortedHashes {
+		if isKept(keep, hash, hashes[hash]) {
+			result.Kept = append(result.Kept, hash)
+			continue
+		}
+
+		markers, err := listAccessMarkers(ctx, baseStore, hash)
+		if err != nil {
+			return result, err
+		}
+		if len(markers) == 0 {
+			result.FirstSeen = append(result.FirstSeen, hash)
+			if policy.DryRun {
+				continue
+			}
+			filename := accessMarkerFilename(hash, now)
+			if err := baseStore.WriteObject(ctx, filename, bytes.NewReader(nil)); err != nil {
+				return result, fmt.Errorf("writing access marker %s: %w", filename, err)
+			}
+			continue
+		}
+
+		lastAccess := markers[len(markers)-1].at
+		if now.Sub(lastAccess) <= policy.MaxIdle {
+			continue
+		}
+
+		result.Deleted = append(result.Deleted, hash)
+		if policy.DryRun {
+			logger.Info("output caches would be deleted", zap.String("module_hash", hash), zap.Strings("module_names", hashes[hash]), zap.Time("last_access", lastAccess))
+			continue
+		}
+		if err := deleteModuleOutputs(ctx, baseStore, hash, markers); err != nil {
+			return result, err
+		}
+		logger.Info("output caches deleted", zap.String("module_hash", hash), zap.Strings("module_names", hashes[hash]), zap.Time("last_access", lastAccess))
+	}
+
+	return result, nil
+}
+
+func isKept(keep map[string]bool, hash string, moduleNames []string) bool {
+	if keep[hash] {
+		return true
+	}
+	for _, name := range moduleNames {
+		if keep[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteModuleOutputs deletes the output caches of `moduleHash` and their
+// segment index, then its access markers, so that an interrupted deletion
+// is done again by the next run.
+func deleteModuleOutputs(ctx context.Context, baseStore dstore.Store, moduleHash string, markers []accessMarker) error {
+	var filenames []string
+	err := baseStore.Walk(ctx, moduleHash+"/outputs/", func(filename string) error {
+		filenames = append(filenames, filename)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("listing output caches of module hash %q: %w", moduleHash, err)
+	}
+	indexFilename := path.Join(moduleHash, segmentIndexFilename)
+	indexExists, err := baseStore.FileExists(ctx, indexFilename)
+	if err != nil {
+		return fmt.Errorf("checking segment index of module hash %q: %w", moduleHash, err)
+	}
+	if indexExists {
+		filenames = append(filenames, indexFilename)
+	}
+
+	for _, filename := range filenames {
+		if err := baseStore.DeleteObject(ctx, filename); err != nil {
+			return fmt.Errorf("deleting %s: %w", filename, err)
+		}
+	}
+	for _, marker := range markers {
+		if err := baseStore.DeleteObject(ctx, marker.filename); err != nil {
+			return fmt.Errorf("deleting access marker %s: %w", marker.filename, err)
+		}
+	}
+	return nil
+}
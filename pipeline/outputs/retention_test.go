@@ -0,0 +1,150 @@
+package outputs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/block"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTouchAccessMarker(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	now := time.Unix(1_000_000, 0)
+
+	touched, err := touchAccessMarker(ctx, store, "touchhash", now)
+	require.NoError(t, err)
+	assert.True(t, touched)
+
+	touched, err = touchAccessMarker(ctx, store, "touchhash", now.Add(10*time.Minute))
+	require.NoError(t, err)
+	assert.False(t, touched, "recorded less than an hour ago")
+
+	touched, err = touchAccessMarker(ctx, store, "touchhash", now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, touched)
+	assert.Equal(t, []string{accessMarkerFilename("touchhash", now.Add(2*time.Hour))}, listFiles(t, store), "previous marker deleted")
+}
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	maxIdle := 24 * time.Hour
+	segment := ComputeSegmentFilename(10, 20, 10)
+
+	tests := []struct {
+		name            string
+		accessedAt      map[string]time.Time
+		keep            []string
+		dryRun          bool
+		expectDeleted   []string
+		expectKept      []string
+		expectFirstSeen []string
+		expectRemaining []string
+	}{
+		{
+			name:            "idle deleted",
+			accessedAt:      map[string]time.Time{"hash1": now.Add(-48 * time.Hour), "hash2": now.Add(-time.Hour)},
+			expectDeleted:   []string{"hash1"},
+			expectRemaining: []string{"hash2/outputs/" + segment, accessMarkerFilename("hash2", now.Add(-time.Hour))},
+		},
+		{
+			name:          "kept by module name",
+			accessedAt:    map[string]time.Time{"hash1": now.Add(-48 * time.Hour), "hash2": now.Add(-48 * time.Hour)},
+			keep:          []string{"map_b"},
+			expectDeleted: []string{"hash1"},
+			expectKept:    []string{"hash2"},
+			expectRemaining: []string{
+				"hash2/outputs/" + segment,
+				accessMarkerFilename("hash2", now.Add(-48*time.Hour)),
+			},
+		},
+		{
+			name:          "kept by module hash",
+			accessedAt:    map[string]time.Time{"hash1": now.Add(-48 * time.Hour), "hash2": now.Add(-48 * time.Hour)},
+			keep:          []string{"hash1"},
+			expectDeleted: []string{"hash2"},
+			expectKept:    []string{"hash1"},
+			expectRemaining: []string{
+				"hash1/outputs/" + segment,
+				accessMarkerFilename("hash1", now.Add(-48*time.Hour)),
+			},
+		},
+		{
+			name:          "dry run",
+			accessedAt:    map[string]time.Time{"hash1": now.Add(-48 * time.Hour), "hash2": now.Add(-time.Hour)},
+			dryRun:        true,
+			expectDeleted: []string{"hash1"},
+			expectRemaining: []string{
+				"hash1/outputs/" + segment,
+				"hash2/outputs/" + segment,
+				accessMarkerFilename("hash1", now.Add(-48*time.Hour)),
+				accessMarkerFilename("hash2", now.Add(-time.Hour)),
+			},
+		},
+		{
+			name:            "first seen",
+			accessedAt:      map[string]time.Time{"hash1": now.Add(-48 * time.Hour)},
+			expectDeleted:   []string{"hash1"},
+			expectFirstSeen: []string{"hash2"},
+			expectRemaining: []string{"hash2/outputs/" + segment, accessMarkerFilename("hash2", now)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dstore.NewMockStore(nil)
+			for hash, name := range map[string]string{"hash1": "map_a", "hash2": "map_b"} {
+				store.SetFile("modules/"+name+"/"+hash, nil)
+				store.SetFile(hash+"/outputs/"+segment, []byte(testJSON))
+			}
+			for hash, at := range test.accessedAt {
+				store.SetFile(accessMarkerFilename(hash, at), nil)
+			}
+
+			result, err := ApplyRetention(ctx, store, RetentionPolicy{MaxIdle: maxIdle, Keep: test.keep, DryRun: test.dryRun}, now, zap.NewNop())
+			require.NoError(t, err)
+			assert.Equal(t, test.expectDeleted, result.Deleted)
+			assert.Equal(t, test.expectKept, result.Kept)
+			assert.Equal(t, test.expectFirstSeen, result.FirstSeen)
+
+			var remaining []string
+			for _, filename := range listFiles(t, store) {
+				if filename != "modules/map_a/hash1" && filename != "modules/map_b/hash2" {
+					remaining = append(remaining, filename)
+				}
+			}
+			assert.ElementsMatch(t, test.expectRemaining, remaining)
+		})
+	}
+}
+
+func TestOutputCache_SegmentDeletedWhileReading(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	filename := ComputeSegmentFilename(10, 20, 10)
+	store.SetFile(filename, []byte(testJSON))
+	// Listed, then deleted by the retention before being read
+	store.OpenObjectFunc = func(ctx context.Context, name string) (io.ReadCloser, error) {
+		return nil, dstore.ErrNotFound
+	}
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	found, err := cache.LoadAtBlock(ctx, 12)
+	require.NoError(t, err)
+	assert.False(t, found, "executed again")
+	assert.Equal(t, block.NewRange(10, 20), cache.CurrentBlockRange)
+
+	err = cache.ReadRange(ctx, block.NewRange(10, 20), func(clock *pbsubstreams.Clock, data []byte) error { return nil })
+	var notCached *RangeNotCachedError
+	require.True(t, errors.As(err, &notCached), "got %v", err)
+	assert.Equal(t, uint64(10), notCached.CachedUntil)
+}
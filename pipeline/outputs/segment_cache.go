@@ -0,0 +1,170 @@
+package outputs
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultSegmentCacheMaxBytes is the memory the decoded segments kept by a
+// SegmentCache take at most.
+const DefaultSegmentCacheMaxBytes = 256 * 1024 * 1024
+
+// cacheItemOverhead roughly accounts for the memory of a CacheItem besides
+// its byte fields.
+const cacheItemOverhead = 128
+
+// SegmentCache keeps the last segments decoded by the output caches of a
+// process, so that requests replaying the same ranges of a module do not
+// download and decode them again. Concurrent loads of a segment missing are
+// done once. It is shared by all the requests, the least recently used
+// segments being evicted past its byte budget.
+type SegmentCache struct {
+	maxBytes int64
+	loads    singleflight.Group
+
+	lock       sync.Mutex
+	size       int64
+	entries    map[string]*list.Element
+	order      *list.List // most recently used first
+	generation uint64     // bumped by every invalidation
+}
+
+type segmentEntry struct {
+	key  string
+	kv   outputKV
+	size int64
+}
+
+func NewSegmentCache(maxBytes int64) *SegmentCache {
+	return &SegmentCache{
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// segmentKey identifies the cache file `filename` of the module of hash
+// `moduleHash`, the file name holding its range.
+func segmentKey(moduleHash, filename string) string {
+	return moduleHash + "/" + filename
+}
+
+// load returns the segment of `key`, fetching it with `fetch` when it is not
+// kept already. The segment returned is shared and must not be modified.
+func (s *SegmentCache) load(ctx context.Context, moduleName, key string, fetch func(ctx context.Context) (outputKV, error)) (outputKV, error) {
+	for {
+		if kv, found := s.get(key); found {
+			segmentCacheHits.Inc(moduleName)
+			return kv, nil
+		}
+
+		s.lock.Lock()
+		generation := s.generation
+		s.lock.Unlock()
+
+		fetched := false
+		result := s.loads.DoChan(key, func() (interface{}, error) {
+			fetched = true
+			kv, err := fetch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			s.add(key, kv, generation)
+			return kv, nil
+		})
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res := <-result:
+			if res.Err != nil {
+				if !fetched && ctx.Err() == nil && (errors.Is(res.Err, context.Canceled) || errors.Is(res.Err, context.DeadlineExceeded)) {
+					// The request fetching it went away, not this one
+					continue
+				}
+				return nil, res.Err
+			}
+			if fetched {
+				segmentCacheMisses.Inc(moduleName)
+			} else {
+				segmentCacheHits.Inc(moduleName)
+			}
+			return res.Val.(outputKV), nil
+		}
+	}
+}
+
+func (s *SegmentCache) get(key string) (outputKV, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	element, found := s.entries[key]
+	if !found {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*segmentEntry).kv, true
+}
+
+// add keeps the segment of `key` fetched as of `generation`, unless it was
+// invalidated since, and evicts the least recently used ones past the byte
+// budget.
+func (s *SegmentCache) add(key string, kv outputKV, generation uint64) {
+	size := kvSize(kv)
+	if size > s.maxBytes {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if generation != s.generation {
+		return
+	}
+	if element, found := s.entries[key]; found {
+		s.remove(element)
+	}
+	s.entries[key] = s.order.PushFront(&segmentEntry{key: key, kv: kv, size: size})
+	s.size += size
+
+	for s.size > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+}
+
+// invalidate forgets the segment of `key`, rewritten or deleted, along with
+// the loads of any segment in flight.
+func (s *SegmentCache) invalidate(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.generation++
+	if element, found := s.entries[key]; found {
+		s.remove(element)
+	}
+}
+
+func (s *SegmentCache) remove(element *list.Element) {
+	entry := element.Value.(*segmentEntry)
+	s.order.Remove(element)
+	delete(s.entries, entry.key)
+	s.size -= entry.size
+}
+
+// kvSize estimates the memory taken by the items of `kv`.
+func kvSize(kv outputKV) (size int64) {
+	for id, item := range kv {
+		size += int64(len(id) + len(item.BlockID) + len(item.Payload) + len(item.Cursor) + cacheItemOverhead)
+		if item.Failure != nil {
+			size += int64(len(item.Failure.Message))
+			for _, line := range item.Failure.StackTrace {
+				size += int64(len(line))
+			}
+		}
+	}
+	return size
+}
@@ -0,0 +1,168 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testSegment(payloadSize int) outputKV {
+	return outputKV{"a": {BlockNum: 1, BlockID: "a", Payload: make([]byte, payloadSize)}}
+}
+
+func TestSegmentCache_Eviction(t *testing.T) {
+	ctx := context.Background()
+	segmentSize := kvSize(testSegment(100))
+	cache := NewSegmentCache(2 * segmentSize)
+
+	fetches := map[string]int{}
+	load := func(key string) {
+		_, err := cache.load(ctx, "module1", key, func(ctx context.Context) (outputKV, error) {
+			fetches[key]++
+			return testSegment(100), nil
+		})
+		require.NoError(t, err)
+	}
+
+	hits := testutil.ToFloat64(segmentCacheHits.Native().WithLabelValues("module1"))
+	misses := testutil.ToFloat64(segmentCacheMisses.Native().WithLabelValues("module1"))
+
+	load("a")
+	load("b")
+	load("a")
+	load("c") // evicts b, the least recently used
+	load("a")
+	load("b")
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 1}, fetches)
+	assert.Equal(t, 2*segmentSize, cache.size)
+	assert.Equal(t, hits+2, testutil.ToFloat64(segmentCacheHits.Native().WithLabelValues("module1")))
+	assert.Equal(t, misses+4, testutil.ToFloat64(segmentCacheMisses.Native().WithLabelValues("module1")))
+
+	_, err := cache.load(ctx, "module1", "large", func(ctx context.Context) (outputKV, error) {
+		return testSegment(1000), nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, cache.entries, 2, "segment larger than the budget not kept")
+}
+
+func TestSegmentCache_SingleDownload(t *testing.T) {
+	ctx := context.Background()
+	cache := NewSegmentCache(DefaultSegmentCacheMaxBytes)
+	release := make(chan struct{})
+	var fetches int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kv, err := cache.load(ctx, "module1", "a", func(ctx context.Context) (outputKV, error) {
+				atomic.AddInt32(&fetches, 1)
+				<-release
+				return testSegment(10), nil
+			})
+			assert.NoError(t, err)
+			assert.Len(t, kv, 1)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestSegmentCache_CancelledDownload(t *testing.T) {
+	cache := NewSegmentCache(DefaultSegmentCacheMaxBytes)
+	started := make(chan struct{})
+	leaderCtx, cancel := context.WithCancel(context.Background())
+
+	leaderDone := make(chan error)
+	go func() {
+		_, err := cache.load(leaderCtx, "module1", "a", func(ctx context.Context) (outputKV, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		leaderDone <- err
+	}()
+	<-started
+
+	followerDone := make(chan error)
+	go func() {
+		_, err := cache.load(context.Background(), "module1", "a", func(ctx context.Context) (outputKV, error) {
+			return testSegment(10), nil
+		})
+		followerDone <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-leaderDone)
+	assert.NoError(t, <-followerDone, "fetched again by the request still there")
+}
+
+func TestOutputCache_SegmentCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	// Overwriting segments
+	store := (&recordingStore{}).store(func(filename string) error { return nil })
+	segments := NewSegmentCache(DefaultSegmentCacheMaxBytes)
+	newCache := func() *OutputCache {
+		cache := NewOutputCache("module1", store, 10, zap.NewNop())
+		cache.segments = segments
+		return cache
+	}
+
+	writer := newCache()
+	_, err := writer.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	require.NoError(t, writer.Set(&pbsubstreams.Clock{Id: "10a", Number: 10}, "", []byte{0x01}))
+	require.NoError(t, writer.saveTruncated(ctx))
+
+	load := func() []byte {
+		cache := newCache()
+		found, err := cache.LoadAtBlock(ctx, 10)
+		require.NoError(t, err)
+		require.True(t, found)
+		payload, found := cache.Get(&pbsubstreams.Clock{Id: "10a", Number: 10})
+		require.True(t, found)
+		return payload
+	}
+	assert.Equal(t, []byte{0x01}, load())
+
+	// Served from memory, the store no longer being read
+	store.OpenObjectFunc = func(ctx context.Context, name string) (out io.ReadCloser, err error) {
+		return nil, fmt.Errorf("read %s", name)
+	}
+	assert.Equal(t, []byte{0x01}, load())
+	store.OpenObjectFunc = nil
+
+	// Loaded segments are copies
+	reader := newCache()
+	_, err = reader.LoadAtBlock(ctx, 10)
+	require.NoError(t, err)
+	reader.Delete("10a")
+	assert.Equal(t, []byte{0x01}, load())
+
+	require.NoError(t, writer.Set(&pbsubstreams.Clock{Id: "10a", Number: 10}, "", []byte{0x02}))
+	require.NoError(t, writer.saveTruncated(ctx))
+	assert.Equal(t, []byte{0x02}, load(), "rewritten segment loaded again")
+
+	// An invalidation while fetching leaves the segment out
+	generation := segments.generation
+	segments.invalidate("other")
+	segments.add(segmentKey("", "stale"), testSegment(10), generation)
+	_, found := segments.get(segmentKey("", "stale"))
+	assert.False(t, found)
+}
@@ -0,0 +1,119 @@
+package outputs
+
+import (
+	"sort"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// CacheStats counts the use of an output cache by a request.
+type CacheStats struct {
+	Hits           uint64 // outputs served from the cache
+	Misses         uint64 // outputs not found, the module being executed
+	SegmentsLoaded uint64
+	BytesRead      uint64 // read from the store, segments kept in memory excluded
+	BytesWritten   uint64
+}
+
+func (s *CacheStats) Add(other CacheStats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.SegmentsLoaded += other.SegmentsLoaded
+	s.BytesRead += other.BytesRead
+	s.BytesWritten += other.BytesWritten
+}
+
+func (s CacheStats) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddUint64("hits", s.Hits)
+	enc.AddUint64("misses", s.Misses)
+	enc.AddUint64("segments_loaded", s.SegmentsLoaded)
+	enc.AddUint64("bytes_read", s.BytesRead)
+	enc.AddUint64("bytes_written", s.BytesWritten)
+	return nil
+}
+
+// ModulesCacheStats holds the CacheStats of every module of a request.
+type ModulesCacheStats map[string]CacheStats
+
+func (s ModulesCacheStats) Total() (total CacheStats) {
+	for _, stats := range s {
+		total.Add(stats)
+	}
+	return total
+}
+
+func (s ModulesCacheStats) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := enc.AddObject(name, s[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestStats accumulates the CacheStats of an output cache, the segments
+// being written in the background.
+type requestStats struct {
+	lock  sync.Mutex
+	stats CacheStats
+}
+
+// Stats returns the use of the cache since it was created.
+func (c *OutputCache) Stats() CacheStats {
+	c.stats.lock.Lock()
+	defer c.stats.lock.Unlock()
+	return c.stats.stats
+}
+
+// Stats returns the use of the cache of every module since they were
+// registered.
+func (c *ModulesOutputCache) Stats() ModulesCacheStats {
+	out := ModulesCacheStats{}
+	for name, cache := range c.OutputCaches {
+		out[name] = cache.Stats()
+	}
+	return out
+}
+
+func (c *OutputCache) countGet(found bool) {
+	c.stats.lock.Lock()
+	defer c.stats.lock.Unlock()
+
+	if found {
+		c.stats.stats.Hits++
+		cacheHits.Inc(c.ModuleName)
+	} else {
+		c.stats.stats.Misses++
+		cacheMisses.Inc(c.ModuleName)
+	}
+}
+
+func (c *OutputCache) countSegmentLoaded() {
+	c.stats.lock.Lock()
+	defer c.stats.lock.Unlock()
+
+	c.stats.stats.SegmentsLoaded++
+	segmentsLoaded.Inc(c.ModuleName)
+}
+
+func (c *OutputCache) countBytesRead(size int) {
+	c.stats.lock.Lock()
+	defer c.stats.lock.Unlock()
+
+	c.stats.stats.BytesRead += uint64(size)
+	bytesRead.AddInt(size, c.ModuleName)
+}
+
+func (c *OutputCache) countBytesWritten(size int) {
+	c.stats.lock.Lock()
+	defer c.stats.lock.Unlock()
+
+	c.stats.stats.BytesWritten += uint64(size)
+	bytesWritten.AddInt(size, c.ModuleName)
+}
@@ -0,0 +1,95 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/streamingfast/derr"
+	"github.com/streamingfast/substreams/block"
+	"go.uber.org/zap"
+)
+
+// Segments are named after the blocks they actually hold, as
+// `<start>-<exclusive_end>.s<interval>.output`, so their coverage is known
+// from their name. A segment ending before the boundary of its interval is
+// a truncated one, saved when a request stopped in its middle, which is
+// completed by the next requests processing its blocks. Once the complete
+// segment is written, the truncated ones it covers are deleted.
+
+// truncated tells if the segment ends before the boundary of its interval,
+// `defaultInterval` being the one of the files named without it.
+func (f *segmentFile) truncated(defaultInterval uint64) bool {
+	interval := f.saveInterval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	r := f.blockRange
+	return r.ExclusiveEndBlock < ComputeStartBlock(r.StartBlock, interval)+interval
+}
+
+// deleteSupersededSegments deletes the segments starting where `filename`,
+// just written, starts and ending before it: the truncated segments it
+// completes. Failing to delete them only leaves files the readers skip, the
+// error is logged.
+func (c *OutputCache) deleteSupersededSegments(ctx context.Context, filename string) {
+	written, err := fileNameToSegment(filename)
+	if err != nil {
+		return
+	}
+
+	paddedStart := pad(written.blockRange.StartBlock)
+	var files []string
+	err = derr.RetryContext(ctx, 3, func(ctx context.Context) (err error) {
+		files, err = c.Store.ListFiles(ctx, paddedStart, math.MaxInt64)
+		return
+	})
+	if err != nil {
+		c.logger.Warn("unable to list truncated segments", zap.String("module_name", c.ModuleName), zap.String("file_name", filename), zap.Error(err))
+		return
+	}
+
+	for _, other := range withoutIgnoredFiles(files) {
+		file, err := fileNameToSegment(other)
+		if err != nil || file.filename == filename {
+			continue
+		}
+		r := file.blockRange
+		if r.StartBlock != written.blockRange.StartBlock || r.ExclusiveEndBlock >= written.blockRange.ExclusiveEndBlock {
+			continue
+		}
+
+		if err := c.Store.DeleteObject(ctx, file.filename); err != nil {
+			c.logger.Warn("unable to delete truncated segment", zap.String("module_name", c.ModuleName), zap.String("file_name", file.filename), zap.Error(fmt.Errorf("deleting: %w", err)))
+			continue
+		}
+		c.invalidateSegment(file.filename)
+		if c.index != nil {
+			c.index.remove(ctx, file.filename)
+		}
+		c.logger.Debug("truncated segment replaced", zap.String("module_name", c.ModuleName), zap.String("file_name", file.filename), zap.String("by", filename))
+	}
+}
+
+// withoutSupersededRanges returns the ranges of `ranges` not contained in
+// another, dropping the truncated segments completed or extended by others
+// not deleted yet, sorted by start block.
+func withoutSupersededRanges(ranges block.Ranges) (out block.Ranges) {
+	sorted := make(block.Ranges, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].StartBlock != sorted[j].StartBlock {
+			return sorted[i].StartBlock < sorted[j].StartBlock
+		}
+		return sorted[i].ExclusiveEndBlock > sorted[j].ExclusiveEndBlock
+	})
+
+	for _, r := range sorted {
+		if len(out) != 0 && r.ExclusiveEndBlock <= out[len(out)-1].ExclusiveEndBlock {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/block"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWithoutSupersededRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges block.Ranges
+		expect block.Ranges
+	}{
+		{
+			name:   "none superseded",
+			ranges: block.Ranges{block.NewRange(20, 30), block.NewRange(10, 20)},
+			expect: block.Ranges{block.NewRange(10, 20), block.NewRange(20, 30)},
+		},
+		{
+			name:   "truncated and complete",
+			ranges: block.Ranges{block.NewRange(10, 20), block.NewRange(20, 25), block.NewRange(20, 30), block.NewRange(30, 32)},
+			expect: block.Ranges{block.NewRange(10, 20), block.NewRange(20, 30), block.NewRange(30, 32)},
+		},
+		{
+			name:   "covered by a larger interval",
+			ranges: block.Ranges{block.NewRange(0, 100), block.NewRange(10, 20), block.NewRange(100, 110)},
+			expect: block.Ranges{block.NewRange(0, 100), block.NewRange(100, 110)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expect, withoutSupersededRanges(test.ranges))
+		})
+	}
+}
+
+func TestOutputCache_TruncatedSegmentCoverage(t *testing.T) {
+	ctx := context.Background()
+	store := dstore.NewMockStore(nil)
+	store.SetFile(ComputeSegmentFilename(10, 20, 10), []byte(testJSON))
+	store.SetFile(ComputeSegmentFilename(20, 23, 10), []byte(testJSON))
+	store.SetFile(ComputeSegmentFilename(20, 27, 10), []byte(testJSON))
+
+	cache := NewOutputCache("module1", store, 10, zap.NewNop())
+	ranges, err := cache.ListContinuousCacheRanges(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, block.Ranges{block.NewRange(10, 20), block.NewRange(20, 27)}, ranges, "actual coverage of the truncated segment")
+
+	// Completed live, the truncated segments are replaced
+	found, err := cache.LoadAtBlock(ctx, 20)
+	require.NoError(t, err)
+	require.True(t, found)
+	cache.kv = outputKV{} // the seeded files hold block 10 only
+	for num := uint64(20); num < 32; num++ {
+		require.NoError(t, cache.Set(&pbsubstreams.Clock{Id: fmt.Sprintf("%da", num), Number: num}, "", []byte{0x01}))
+	}
+	require.NoError(t, cache.saveFinalSegments(ctx, 31))
+	require.NoError(t, cache.writer.wait(ctx))
+
+	assert.Equal(t, []string{ComputeSegmentFilename(10, 20, 10), ComputeSegmentFilename(20, 30, 10)}, listFiles(t, store))
+	ranges, err = cache.ListContinuousCacheRanges(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, block.Ranges{block.NewRange(10, 20), block.NewRange(20, 30)}, ranges)
+}
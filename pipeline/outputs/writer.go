@@ -0,0 +1,145 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/derr"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxPendingWrites is the number of segments of a module waiting to
+// be written before the pipeline waits for them.
+const DefaultMaxPendingWrites = 4
+
+// DefaultWriteTimeout bounds each attempt at writing a segment, so that a
+// stalled upload is retried instead of holding the writes of the module
+// forever.
+const DefaultWriteTimeout = 5 * time.Minute
+
+// segmentWriter writes the segments of an output cache in the background,
+// one after the other in the order they were saved. Once a write failed,
+// the segments saved after it are dropped, so that a segment never becomes
+// visible while one before it is missing, and the error is returned by the
+// next saves.
+type segmentWriter struct {
+	store        dstore.Store
+	maxPending   int
+	pending      chan struct{} // one per write not done yet
+	retries      uint64
+	writeTimeout time.Duration                   // per attempt, 0 leaving the attempts unbounded
+	written      func(filename string, size int) // called once a segment is written, when set
+	logger       *zap.Logger
+
+	lock sync.Mutex
+	last chan struct{} // closed once the last write saved is done
+	err  error
+}
+
+// newSegmentWriter returns a writer keeping up to `maxPending` segments
+// waiting, 0 making the writes synchronous.
+func newSegmentWriter(store dstore.Store, maxPending int, logger *zap.Logger) *segmentWriter {
+	capacity := maxPending
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &segmentWriter{
+		store:        store,
+		maxPending:   maxPending,
+		pending:      make(chan struct{}, capacity),
+		retries:      3,
+		writeTimeout: DefaultWriteTimeout,
+		logger:       logger,
+	}
+}
+
+// write queues the write of `cnt` to `filename`, waiting when too many
+// writes are pending already. It returns the error of a previous write.
+func (w *segmentWriter) write(ctx context.Context, filename string, cnt []byte) error {
+	if err := w.error(); err != nil {
+		return err
+	}
+
+	select {
+	case w.pending <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	w.lock.Lock()
+	previous := w.last
+	done := make(chan struct{})
+	w.last = done
+	w.lock.Unlock()
+
+	go func() {
+		defer func() {
+			<-w.pending
+			close(done)
+		}()
+
+		if previous != nil {
+			<-previous
+		}
+		if w.error() != nil {
+			w.logger.Info("dropping cache segment written after a failed one", zap.String("filename", filename))
+			return
+		}
+
+		// The writes outlive the request that saved them, a shutting down
+		// request waits for them with its own context. The error of the last
+		// attempt is kept as is, the one returned by RetryContext having its
+		// outermost wrapping stripped.
+		var err error
+		_ = derr.RetryContext(context.Background(), w.retries, func(ctx context.Context) error {
+			if w.writeTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, w.writeTimeout)
+				defer cancel()
+			}
+			err = writeSegmentFile(ctx, w.store, filename, cnt)
+			return err
+		})
+		if err != nil {
+			w.logger.Warn("failed writing output cache", zap.String("filename", filename), zap.Error(err))
+			w.lock.Lock()
+			w.err = fmt.Errorf("writing %s: %w", filename, err)
+			w.lock.Unlock()
+			return
+		}
+		if w.written != nil {
+			w.written(filename, len(cnt))
+		}
+	}()
+
+	if w.maxPending == 0 {
+		return w.wait(ctx)
+	}
+	return nil
+}
+
+// wait waits for the pending writes to be done, and returns the error of
+// the first one that failed.
+func (w *segmentWriter) wait(ctx context.Context) error {
+	w.lock.Lock()
+	last := w.last
+	w.lock.Unlock()
+
+	if last != nil {
+		select {
+		case <-last:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return w.error()
+}
+
+func (w *segmentWriter) error() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err
+}
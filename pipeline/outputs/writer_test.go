@@ -0,0 +1,158 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type recordingStore struct {
+	lock    sync.Mutex
+	written []string
+}
+
+// store returns a store calling `write` before each write, which fails
+// when it returns an error.
+func (s *recordingStore) store(write func(filename string) error) *dstore.MockStore {
+	store := dstore.NewMockStore(nil)
+	store.WriteObjectFunc = func(ctx context.Context, filename string, f io.Reader) error {
+		if err := write(filename); err != nil {
+			return err
+		}
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		store.SetFile(filename, content)
+		s.written = append(s.written, filename)
+		return nil
+	}
+	return store
+}
+
+// filenames returns the names of the segments written, in order.
+func (s *recordingStore) filenames() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return withoutIgnoredFiles(s.written)
+}
+
+func TestSegmentWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxPending    int
+		failing       string
+		expectWritten []string
+		expectErr     string
+	}{
+		{
+			name:          "written in order",
+			maxPending:    2,
+			expectWritten: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:          "synchronous",
+			maxPending:    0,
+			expectWritten: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:          "segments after a failed one dropped",
+			maxPending:    4,
+			failing:       "b",
+			expectWritten: []string{"a"},
+			expectErr:     "writing b: copying temporary object",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			recorder := &recordingStore{}
+			writer := newSegmentWriter(recorder.store(func(filename string) error {
+				// Slower first writes catch out of order ones
+				time.Sleep(time.Duration(4-len(recorder.filenames())) * time.Millisecond)
+				if filename == test.failing {
+					return fmt.Errorf("failed")
+				}
+				return nil
+			}), test.maxPending, zap.NewNop())
+			writer.retries = 0
+
+			var err error
+			for _, filename := range []string{"a", "b", "c", "d"} {
+				if err = writer.write(ctx, filename, nil); err != nil {
+					break
+				}
+				if test.maxPending == 0 {
+					assert.Equal(t, filename, recorder.filenames()[len(recorder.filenames())-1], "written before returning")
+				}
+			}
+			if err == nil {
+				err = writer.wait(ctx)
+			}
+
+			if test.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectErr)
+				assert.Equal(t, err, writer.write(ctx, "e", nil), "next writes fail")
+				assert.Equal(t, err, writer.wait(ctx))
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectWritten, recorder.filenames())
+		})
+	}
+}
+
+func TestSegmentWriter_MaxPending(t *testing.T) {
+	release := make(chan struct{})
+	recorder := &recordingStore{}
+	writer := newSegmentWriter(recorder.store(func(filename string) error {
+		<-release
+		return nil
+	}), 2, zap.NewNop())
+
+	require.NoError(t, writer.write(context.Background(), "a", nil))
+	require.NoError(t, writer.write(context.Background(), "b", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, writer.write(ctx, "c", nil), "waits for pending writes")
+	assert.Equal(t, context.DeadlineExceeded, writer.wait(ctx))
+
+	close(release)
+	require.NoError(t, writer.write(context.Background(), "c", nil))
+	require.NoError(t, writer.wait(context.Background()))
+	assert.Equal(t, []string{"a", "b", "c"}, recorder.filenames())
+}
+
+func TestSegmentWriter_WriteTimeout(t *testing.T) {
+	attempts := 0
+	store := dstore.NewMockStore(nil)
+	store.WriteObjectFunc = func(ctx context.Context, filename string, f io.Reader) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	writer := newSegmentWriter(store, 0, zap.NewNop())
+	writer.retries = 0
+	writer.writeTimeout = 20 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := writer.write(ctx, "a", nil)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "stalled attempt aborted")
+	assert.NoError(t, ctx.Err(), "aborted by the write timeout")
+	assert.Equal(t, 1, attempts)
+}
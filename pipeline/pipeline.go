@@ -6,7 +6,10 @@ import (
 	"io"
 	"math"
 	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/streamingfast/bstream"
 	"github.com/streamingfast/dstore"
@@ -41,6 +44,7 @@ type Pipeline struct {
 	postJobHooks   []substreams.PostJobHook
 
 	wasmRuntime    *wasm.Runtime
+	wasmModulePool *wasm.ModulePool
 	wasmExtensions []wasm.WASMExtensioner
 
 	context  context.Context
@@ -48,7 +52,8 @@ type Pipeline struct {
 	graph    *manifest.ModuleGraph
 	respFunc func(resp *pbsubstreams.Response) error
 
-	modules              []*pbsubstreams.Module
+	modules              []*pbsubstreams.Module // in execution order
+	executionOrder       []string
 	outputModuleMap      map[string]bool
 	storeModules         []*pbsubstreams.Module
 	storeMap             map[string]*state.Store
@@ -61,6 +66,8 @@ type Pipeline struct {
 	baseStateStore    dstore.Store
 	storeSaveInterval uint64
 
+	moduleHashMarkers *ModuleHashMarkers // nil when the module hashes are not recorded
+
 	clock         *pbsubstreams.Clock
 	moduleOutputs []*pbsubstreams.ModuleOutput
 	logs          []string
@@ -70,15 +77,60 @@ type Pipeline struct {
 
 	partialsWritten block.Ranges // when backprocessing, to report back to orchestrator
 
+	debugStoreSnapshotSent bool
+
+	quarantinedModules map[string]bool
+
+	// blockTraceSampling traces one block out of every `blockTraceSampling`
+	// blocks, 0 or 1 traces all of them
+	blockTraceSampling uint64
+
+	stats         pipelineStats
+	statsInterval time.Duration // 0 disables the stats messages
+	statsCancel   context.CancelFunc
+	statsDone     chan struct{}
+	network       string // of the server, reported in the session init
+
+	startCursor      *bstream.Cursor
+	cursorResumption *cursorResumption
+
+	// blockLock is held while a block is processed, so that Shutdown never
+	// runs in the middle of one.
+	blockLock           sync.Mutex
+	blockInProgress     bool
+	lastProcessedCursor *bstream.Cursor
+
 	currentBlockRef bstream.BlockRef
 
 	outputCacheSaveBlockInterval uint64
+	moduleOutCacheSaveIntervals  map[string]uint64 // overrides outputCacheSaveBlockInterval per module name
+	outputCacheCompressionLevel  int
+	outputCacheMaxPendingWrites  int
+	outputCacheWriteTimeout      time.Duration
+	outputCacheTempObjectsMaxAge time.Duration
+	outputSegmentCache           *outputs.SegmentCache // nil loads the output cache segments from the store every time
+	outputLocalCache             *outputs.LocalCache   // nil reads the output cache files from the store
 	subrequestSplitSize          int
+	maxModuleOutputSize          uint64
+	maxWasmMemorySize            uint64
+	moduleMaxWasmMemorySizes     map[string]uint64 // overrides maxWasmMemorySize per module name
+	initialWasmMemorySize        uint64
+	moduleInitialWasmMemorySizes map[string]uint64 // overrides initialWasmMemorySize per module name
+	wasmFuelLimit                uint64
+	moduleWasmFuelLimits         map[string]uint64 // overrides wasmFuelLimit per module name
 
 	logger *zap.Logger
 	tracer ttrace.Tracer
 }
 
+// defaultMaxModuleOutputSize caps the output of a module for a single block,
+// a larger output would exhaust the resources of the gRPC stream anyway.
+const defaultMaxModuleOutputSize = 100 * 1024 * 1024
+
+// defaultMaxWasmMemorySize caps the linear memory of the wasm instance of a
+// module, so a module leaking memory fails instead of exhausting the host.
+const defaultMaxWasmMemorySize = 2 * 1024 * 1024 * 1024
+
 var _zlog, _ = logging.PackageLogger("pipe", "github.com/streamingfast/substreams/pipeline")
 
 func New(
@@ -107,10 +159,16 @@ func New(
 		blockType:                    blockType,
 		wasmExtensions:               wasmExtensions,
 		outputCacheSaveBlockInterval: outputCacheSaveBlockInterval,
+		outputCacheCompressionLevel:  outputs.DefaultCompressionLevel,
+		outputCacheMaxPendingWrites:  outputs.DefaultMaxPendingWrites,
+		outputCacheWriteTimeout:      outputs.DefaultWriteTimeout,
+		outputCacheTempObjectsMaxAge: outputs.DefaultTempObjectsMaxAge,
 		subrequestSplitSize:          subrequestSplitSize,
 		maxStoreSyncRangeSize:        math.MaxUint64,
-		respFunc:                     respFunc,
+		maxModuleOutputSize:          defaultMaxModuleOutputSize,
+		maxWasmMemorySize:            defaultMaxWasmMemorySize,
 		forkHandler:                  NewForkHandle(),
+		statsInterval:                defaultStatsInterval,
 		logger:                       _zlog,
 	}
 
@@ -122,9 +180,26 @@ func New(
 		opt(pipe)
 	}
 
+	pipe.stats.respFunc = respFunc
+	pipe.respFunc = pipe.stats.send
+
+	if pipe.startCursor != nil {
+		pipe.requestedStartBlockNum = resumeStartBlock(pipe.startCursor, pipe.requestedStartBlockNum)
+		if !isFinalCursor(pipe.startCursor) {
+			pipe.cursorResumption = newCursorResumption(pipe.startCursor, pipe.respFunc)
+			pipe.respFunc = pipe.cursorResumption.send
+		}
+	}
+
 	return pipe
 }
 
+// StartBlockNum returns the first block the pipeline expects from the block
+// source. It differs from the request start block when resuming from a cursor.
+func (p *Pipeline) StartBlockNum() uint64 {
+	return p.requestedStartBlockNum
+}
+
 func (p *Pipeline) isOutputModule(name string) bool {
 	_, found := p.outputModuleMap[name]
 	return found
@@ -145,12 +220,48 @@ func (p *Pipeline) Init(workerPool *orchestrator.WorkerPool) (err error) {
 	p.logger.Info("initializing handler", zap.Uint64("requested_start_block", p.requestedStartBlockNum), zap.Uint64("requested_stop_block", p.request.StopBlockNum), zap.Bool("is_backprocessing", p.isSubrequest), zap.Strings("outputs", p.request.OutputModules))
 
 	p.moduleOutputCache = outputs.NewModuleOutputCache(p.outputCacheSaveBlockInterval, p.logger)
+	p.moduleOutputCache.ModuleSaveBlockIntervals = p.moduleOutCacheSaveIntervals
+	p.moduleOutputCache.CompressionLevel = p.outputCacheCompressionLevel
+	p.moduleOutputCache.MaxPendingWrites = p.outputCacheMaxPendingWrites
+	p.moduleOutputCache.WriteTimeout = p.outputCacheWriteTimeout
+	p.moduleOutputCache.TempObjectsMaxAge = p.outputCacheTempObjectsMaxAge
+	p.moduleOutputCache.SegmentCache = p.outputSegmentCache
+	p.moduleOutputCache.LocalCache = p.outputLocalCache
+	p.moduleOutputCache.TrackAccess = true
+	p.moduleOutputCache.ReadAhead = true
+
+	// Before the modules are hashed, their hash depending on their initial
+	// block
+	initialBlockOverrides, err := manifest.ApplyInitialBlockOverrides(p.graph, p.request.InitialBlockOverrides)
+	if err != nil {
+		err = fmt.Errorf("invalid request: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	for _, override := range initialBlockOverrides {
+		p.logger.Info("module initial block overridden", zap.String("module", override.ModuleName), zap.Uint64("declared_initial_block", override.DeclaredInitialBlock), zap.Uint64("initial_block", override.InitialBlock))
+	}
 
 	if err := p.build(); err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("building pipeline: %w", err)
 	}
 
+	if !p.isSubrequest {
+		if err := p.respFunc(substreams.NewSessionInitResponse(&pbsubstreams.SessionInit{
+			ModuleExecutionOrder:  p.executionOrder,
+			InitialBlockOverrides: initialBlockOverrides,
+			Network:               p.network,
+		})); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("sending session init: %w", err)
+		}
+
+		if p.statsInterval != 0 {
+			p.startStats()
+		}
+	}
+
 	for _, module := range p.modules {
 		isOutput := p.outputModuleMap[module.Name]
 
@@ -168,6 +279,10 @@ func (p *Pipeline) Init(workerPool *orchestrator.WorkerPool) (err error) {
 		}
 	}
 
+	if !p.isSubrequest {
+		p.checkModuleHashes(ctx)
+	}
+
 	p.logger.Info("initializing and loading stores")
 	initialStoreMap, err := p.buildStoreMap()
 	p.logger.Info("stores load", zap.Int("number_of_stores", len(initialStoreMap)))
@@ -176,6 +291,11 @@ func (p *Pipeline) Init(workerPool *orchestrator.WorkerPool) (err error) {
 		return fmt.Errorf("building store map: %w", err)
 	}
 
+	if err := validateDebugStoreSnapshot(p.request.DebugStoreSnapshot, initialStoreMap); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
 	// Fetch the stores
 	if p.isSubrequest { // this is actually never gone in because of the wrong `if` statement in service.go
 		// truncateLeaf()
@@ -240,11 +360,11 @@ func (p *Pipeline) Init(workerPool *orchestrator.WorkerPool) (err error) {
 	}
 
 	for _, cache := range p.moduleOutputCache.OutputCaches {
-		atBlock := outputs.ComputeStartBlock(p.requestedStartBlockNum, p.outputCacheSaveBlockInterval)
-		if _, err := cache.LoadAtBlock(ctx, atBlock); err != nil {
+		if _, err := cache.LoadAtBlock(ctx, p.requestedStartBlockNum); err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			return fmt.Errorf("loading outputs caches")
 		}
+		cache.SetProcessingStart(p.requestedStartBlockNum)
 	}
 
 	span.SetStatus(codes.Ok, "")
@@ -267,7 +387,10 @@ func (p *Pipeline) computeNextStoreSaveBoundary(fromBlock uint64) uint64 {
 }
 
 func (p *Pipeline) ProcessBlock(block *bstream.Block, obj interface{}) (err error) {
-	ctx, span := p.tracer.Start(p.context, "process_block")
+	p.blockLock.Lock()
+	defer p.blockLock.Unlock()
+
+	ctx, span := p.blockTracer(block.Num()).Start(p.context, "process_block")
 	span.SetAttributes(attribute.Int64("block_num", int64(block.Num())))
 	defer span.End()
 
@@ -300,25 +423,19 @@ func (p *Pipeline) ProcessBlock(block *bstream.Block, obj interface{}) (err erro
 	step := obj.(bstream.Stepable).Step()
 
 	if step == bstream.StepUndo {
-		span.AddEvent("handling_step_undo")
-		if err = p.forkHandler.handleUndo(p.clock, cursor, p.moduleOutputCache, p.storeMap, p.respFunc); err != nil {
+		if p.request.FinalBlocksOnly {
+			err = fmt.Errorf("unexpected undo of block %s while processing final blocks only", block.AsRef())
 			span.SetStatus(codes.Error, err.Error())
-			return fmt.Errorf("reverting outputs: %w", err)
+			return err
 		}
-		return nil
-	}
 
-	if step == bstream.StepIrreversible {
-		if err = p.moduleOutputCache.Update(ctx, p.currentBlockRef); err != nil {
+		span.AddEvent("handling_step_undo")
+		if err = p.forkHandler.handleUndo(p.clock, cursor, block.PreviousRef(), p.moduleOutputCache, p.storeMap, p.respFunc); err != nil {
 			span.SetStatus(codes.Error, err.Error())
-			return fmt.Errorf("updating module output cache: %w", err)
+			return fmt.Errorf("reverting outputs: %w", err)
 		}
-	}
-
-	if step == bstream.StepIrreversible {
-		// todo: should we send the output??
-		span.AddEvent("handling_step_irreversible")
-		p.forkHandler.handleIrreversible(block.Number)
+		p.lastProcessedCursor = nil
+		return nil
 	}
 
 	if step == bstream.StepStalled {
@@ -335,6 +452,37 @@ func (p *Pipeline) ProcessBlock(block *bstream.Block, obj interface{}) (err erro
 		}
 	}
 
+	if err := p.saveStoresOnBoundaries(ctx, span, blockNum); err != nil {
+		return err
+	}
+
+	if isStopBlockReached(blockNum, p.request.StopBlockNum) {
+		return p.flushOnStopBlock(ctx, span, blockNum)
+	}
+
+	if err = p.assignSource(block); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("setting up sources: %w", err)
+	}
+
+	if err := p.executeModules(ctx, span, step, cursor); err != nil {
+		return err
+	}
+
+	if !p.isSubrequest {
+		// Sub requests save their caches when reaching their stop block
+		if err := p.moduleOutputCache.SaveFinalSegments(ctx, cursor.LIB.Num()); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("saving final output cache segments: %w", err)
+		}
+	}
+
+	p.logger.Debug("block processed", zap.Uint64("block_num", block.Number))
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+func (p *Pipeline) saveStoresOnBoundaries(ctx context.Context, span ttrace.Span, blockNum uint64) error {
 	// NOTE: the tests for this code test on a COPY of these lines: (TestBump)
 	for p.nextStoreSaveBoundary <= blockNum {
 		p.logger.Debug("saving stores on boundary", zap.Uint64("block_num", p.nextStoreSaveBoundary))
@@ -348,32 +496,32 @@ func (p *Pipeline) ProcessBlock(block *bstream.Block, obj interface{}) (err erro
 			break
 		}
 	}
+	return nil
+}
 
-	if isStopBlockReached(blockNum, p.request.StopBlockNum) {
-		p.logger.Debug("about to save cache output", zap.Uint64("clock", blockNum), zap.Uint64("stop_block", p.request.StopBlockNum))
-		if err := p.moduleOutputCache.Flush(ctx); err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			return fmt.Errorf("saving partial caches")
-		}
-		return io.EOF
+func (p *Pipeline) flushOnStopBlock(ctx context.Context, span ttrace.Span, blockNum uint64) error {
+	p.logger.Debug("about to save cache output", zap.Uint64("clock", blockNum), zap.Uint64("stop_block", p.request.StopBlockNum))
+	if err := p.moduleOutputCache.Flush(ctx); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("saving partial caches: %w", err)
 	}
-
-	if err = p.assignSource(block); err != nil {
+	if err := p.quarantineTerminationError(); err != nil {
 		span.SetStatus(codes.Error, err.Error())
-		return fmt.Errorf("setting up sources: %w", err)
+		return err
 	}
+	return io.EOF
+}
 
-	ctx, execSpan := p.tracer.Start(ctx, "modules_executions")
-	for _, executor := range p.moduleExecutors {
-		err = p.runExecutor(ctx, executor, cursor.ToOpaque())
-		if err != nil {
-			//if returnErr := p.returnFailureProgress(err, executor); returnErr != nil {
-			//	return fmt.Errorf("progress error: %w", returnErr)
-			//}
-			return err
-		}
+// executeModules runs every module executor over the current clock, sends
+// the resulting outputs and flushes the stores, whether the block data comes
+// from the block source or the modules are served from their output caches.
+func (p *Pipeline) executeModules(ctx context.Context, span ttrace.Span, step bstream.StepType, cursor *bstream.Cursor) (err error) {
+	blockNum := p.clock.Number
+	p.blockInProgress = true
+
+	if err := p.runModuleExecutors(ctx, cursor); err != nil {
+		return err
 	}
-	execSpan.End()
 
 	// Snapshot all outputs, in case we undo
 	// map[block_id]outputs
@@ -387,12 +535,32 @@ func (p *Pipeline) ProcessBlock(block *bstream.Block, obj interface{}) (err erro
 
 	if shouldReturnDataOutputs(blockNum, p.requestedStartBlockNum, p.isSubrequest) {
 		p.logger.Debug("will return module outputs")
-		if err := returnModuleDataOutputs(p.clock, step, cursor, p.moduleOutputs, p.respFunc); err != nil {
+		sortModuleOutputs(p.moduleOutputs, p.request.OutputModules)
+		moduleOutputs, err := p.withFullKVStoreOutputs(p.moduleOutputs)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		if err := returnModuleDataOutputs(p.clock, step, cursor, moduleOutputs, p.request.FinalBlocksOnly, p.respFunc); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+
+	if p.shouldSendDebugStoreSnapshots(blockNum) {
+		span.AddEvent("sending_debug_store_snapshots")
+		if err := p.sendDebugStoreSnapshots(); err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			return err
 		}
 	}
 
+	if step.Matches(bstream.StepIrreversible) {
+		// Final blocks can never be undone, no need to keep their outputs around
+		span.AddEvent("handling_step_irreversible")
+		p.forkHandler.handleIrreversible(blockNum)
+	}
+
 	for _, s := range p.storeMap {
 		s.Flush()
 	}
@@ -400,8 +568,37 @@ func (p *Pipeline) ProcessBlock(block *bstream.Block, obj interface{}) (err erro
 	p.moduleOutputs = nil
 	p.wasmOutputs = map[string][]byte{}
 
-	p.logger.Debug("block processed", zap.Uint64("block_num", block.Number))
-	span.SetStatus(codes.Ok, "")
+	p.stats.blockProcessed(blockNum)
+
+	p.blockInProgress = false
+	p.lastProcessedCursor = cursor
+	return nil
+}
+
+// runModuleExecutors runs every module executor not quarantined over the
+// current clock, quarantining the failed modules when the request allows it.
+func (p *Pipeline) runModuleExecutors(ctx context.Context, cursor *bstream.Cursor) error {
+	ctx, span := childTracer(ctx, p.tracer).Start(ctx, "modules_executions")
+	defer span.End()
+
+	for _, executor := range p.moduleExecutors {
+		if p.isQuarantined(executor.Name()) {
+			continue
+		}
+
+		err := p.runExecutor(ctx, executor, cursor.ToOpaque())
+		if err != nil {
+			//if returnErr := p.returnFailureProgress(err, executor); returnErr != nil {
+			//	return fmt.Errorf("progress error: %w", returnErr)
+			//}
+			if !p.shouldQuarantine(err) {
+				return err
+			}
+			if err := p.quarantine(executor, err); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -417,32 +614,42 @@ func (p *Pipeline) runExecutor(ctx context.Context, executor ModuleExecutor, cur
 	executorName := executor.Name()
 	p.logger.Debug("executing", zap.String("module_name", executorName))
 
+	start := time.Now()
 	err := executor.run(ctx, p.wasmOutputs, p.clock, cursor)
+	p.stats.moduleExecuted(executorName, time.Since(start), executor.outputFromCache())
 	if err != nil {
-		logs, truncated := executor.moduleLogs()
+		logs := executor.moduleLogs()
 		outputData := executor.moduleOutputData()
-		if len(logs) != 0 || outputData != nil {
-			p.moduleOutputs = append(p.moduleOutputs, &pbsubstreams.ModuleOutput{
-				Name:          executorName,
-				Data:          outputData,
-				Logs:          logs,
-				LogsTruncated: truncated,
-			})
+		if len(logs.logs) != 0 || outputData != nil {
+			p.moduleOutputs = append(p.moduleOutputs, logs.setOn(&pbsubstreams.ModuleOutput{
+				Name: executorName,
+				Data: outputData,
+			}))
 		}
 		return fmt.Errorf("running module: %w", err)
 	}
 
-	if p.isOutputModule(executorName) {
-		logs, truncated := executor.moduleLogs()
-		outputData := executor.moduleOutputData()
-		if len(logs) != 0 || outputData != nil {
-			moduleOutput := &pbsubstreams.ModuleOutput{
-				Name:          executorName,
-				Data:          outputData,
-				Logs:          logs,
-				LogsTruncated: truncated,
-			}
+	logs := executor.moduleLogs()
+	outputData := executor.moduleOutputData()
+	if len(logs.logs) != 0 || outputData != nil {
+		moduleOutput := logs.setOn(&pbsubstreams.ModuleOutput{
+			Name:            executorName,
+			Data:            outputData,
+			OutputTruncated: executor.moduleOutputTruncated(),
+		})
+		if p.isOutputModule(executorName) {
 			p.moduleOutputs = append(p.moduleOutputs, moduleOutput)
+		} else if p.shouldSendAllModuleLogs() && len(logs.logs) != 0 {
+			// Intermediate modules only report their logs, their data is
+			// not part of what was requested.
+			p.moduleOutputs = append(p.moduleOutputs, logs.setOn(&pbsubstreams.ModuleOutput{
+				Name: executorName,
+			}))
+		}
+		// Every executed module is tracked, not only the requested outputs, since
+		// all stores need to be rewound when the block gets undone. Final blocks
+		// are never undone.
+		if !p.request.FinalBlocksOnly {
 			p.forkHandler.addModuleOutput(moduleOutput, p.clock.Number)
 		}
 	}
@@ -451,6 +658,36 @@ func (p *Pipeline) runExecutor(ctx context.Context, executor ModuleExecutor, cur
 	return nil
 }
 
+// shouldSendAllModuleLogs tells if the logs of the modules that are not
+// requested outputs are sent along with the outputs, which is only done in
+// development mode.
+func (p *Pipeline) shouldSendAllModuleLogs() bool {
+	return p.request.DevelopmentMode && !p.isSubrequest
+}
+
+// sortModuleOutputs orders the module outputs as the output modules were
+// listed in the request, modules are otherwise executed in topological order.
+// Modules that were not requested, only present for their logs, come last.
+func sortModuleOutputs(moduleOutputs []*pbsubstreams.ModuleOutput, requestedOutputs []string) {
+	requestIndex := make(map[string]int, len(requestedOutputs))
+	for i, name := range requestedOutputs {
+		if _, found := requestIndex[name]; !found {
+			requestIndex[name] = i
+		}
+	}
+
+	index := func(name string) int {
+		if i, found := requestIndex[name]; found {
+			return i
+		}
+		return len(requestedOutputs)
+	}
+
+	sort.SliceStable(moduleOutputs, func(i, j int) bool {
+		return index(moduleOutputs[i].Name) < index(moduleOutputs[j].Name)
+	})
+}
+
 func shouldReturn(blockNum, requestedStartBlockNum uint64) bool {
 	return blockNum >= requestedStartBlockNum
 }
@@ -515,13 +752,13 @@ func (p *Pipeline) returnFailureProgress(err error, failedExecutor ModuleExecuto
 			failedProgress.GetFailed().LogsTruncated = moduleOutput.GetLogsTruncated()
 		}
 
-		if len(moduleOutput.Logs) != 0 {
+		if moduleOutput.Name != failedExecutor.Name() && len(moduleOutput.Logs) != 0 {
 			out = append(out, &pbsubstreams.ModuleProgress{
 				Name: moduleOutput.Name,
 				Type: &pbsubstreams.ModuleProgress_Failed_{
 					Failed: &pbsubstreams.ModuleProgress_Failed{
 						Reason:        fmt.Sprintf("Failed to execute %s: %s", failedExecutor.Name(), err.Error()),
-						Logs:          failedExecutor.getCurrentExecutionStack(),
+						Logs:          moduleOutput.Logs,
 						LogsTruncated: moduleOutput.LogsTruncated,
 					},
 				},
@@ -535,7 +772,7 @@ func (p *Pipeline) returnFailureProgress(err error, failedExecutor ModuleExecuto
 
 func (p *Pipeline) assignSource(block *bstream.Block) error {
 	switch p.vmType {
-	case "wasm/rust-v1":
+	case "wasm/rust-v1", wasm.WASIBinaryType:
 		blkBytes, err := block.Payload.Get()
 		if err != nil {
 			return fmt.Errorf("getting block %d %q: %w", block.Number, block.Id, err)
@@ -558,13 +795,16 @@ func (p *Pipeline) build() error {
 	if err := p.buildModules(); err != nil {
 		return fmt.Errorf("build modules graph: %w", err)
 	}
+	if err := p.validateBinaries(); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (p *Pipeline) validate() error {
 	for _, binary := range p.request.Modules.Binaries {
-		if binary.Type != "wasm/rust-v1" {
-			return fmt.Errorf("unsupported binary type: %q, supported: %q", binary.Type, p.vmType)
+		if binary.Type != "wasm/rust-v1" && binary.Type != wasm.WASIBinaryType {
+			return fmt.Errorf("unsupported binary type: %q, supported: %q", binary.Type, []string{"wasm/rust-v1", wasm.WASIBinaryType})
 		}
 		p.vmType = binary.Type
 	}
@@ -577,6 +817,19 @@ func (p *Pipeline) buildModules() error {
 		return fmt.Errorf("building execution graph: %w", err)
 	}
 	p.modules = modules
+	if skipped := len(p.request.GetModules().GetModules()) - len(modules); skipped > 0 {
+		// Only the ancestors of the requested outputs are compiled, cached
+		// and executed
+		p.logger.Info("skipping modules not leading to the requested outputs", zap.Int("skipped_modules", skipped), zap.Int("executed_modules", len(modules)))
+	}
+
+	// Executors are built and run in this order, every module coming after
+	// its inputs.
+	p.executionOrder = nil
+	for _, module := range modules {
+		p.executionOrder = append(p.executionOrder, module.Name)
+	}
+	p.logger.Debug("module execution order", zap.Strings("modules", p.executionOrder))
 
 	storeModules, err := p.graph.StoresDownTo(p.request.OutputModules)
 	if err != nil {
@@ -587,11 +840,96 @@ func (p *Pipeline) buildModules() error {
 	return nil
 }
 
+// maxWasmMemorySizeOf returns the maximum size in bytes of the linear memory
+// of `moduleName`, 0 means no limit.
+func (p *Pipeline) maxWasmMemorySizeOf(moduleName string) uint64 {
+	if maxSize, found := p.moduleMaxWasmMemorySizes[moduleName]; found {
+		return maxSize
+	}
+	return p.maxWasmMemorySize
+}
+
+// initialWasmMemorySizeOf returns the initial size in bytes of the linear
+// memory of `moduleName`, 0 means the size declared by its code.
+func (p *Pipeline) initialWasmMemorySizeOf(moduleName string) uint64 {
+	if initialSize, found := p.moduleInitialWasmMemorySizes[moduleName]; found {
+		return initialSize
+	}
+	return p.initialWasmMemorySize
+}
+
+// wasmFuelLimitOf returns the fuel available to each execution of `moduleName`,
+// 0 means no limit.
+func (p *Pipeline) wasmFuelLimitOf(moduleName string) uint64 {
+	if limit, found := p.moduleWasmFuelLimits[moduleName]; found {
+		return limit
+	}
+	return p.wasmFuelLimit
+}
+
+// validateBinaries compiles the code of every module to execute and checks it
+// exports what its execution needs, before any block is processed. All the
+// modules are checked, the error reports the problems of each invalid one.
+// The compiled code is kept by the module pool for the executions.
+func (p *Pipeline) validateBinaries() error {
+	p.initWASMPool()
+
+	var invalid []string
+	for _, module := range p.modules {
+		code, poolKey, _, err := p.wasmCodeOf(module)
+		if err == nil {
+			err = p.wasmModulePool.Validate(poolKey, code, module)
+		}
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("module %q: %s", module.Name, err))
+		}
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("invalid module binaries: %s", strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+func (p *Pipeline) initWASMPool() {
+	if p.wasmModulePool == nil {
+		p.wasmRuntime = wasm.NewRuntime(p.wasmExtensions)
+		p.wasmModulePool = wasm.NewModulePool(p.wasmRuntime, wasm.DefaultMaxIdleInstances)
+	}
+}
+
+// wasmCodeOf returns the code of `module` with its memory sized, the key of
+// its modules in the pool and its memory limit.
+func (p *Pipeline) wasmCodeOf(module *pbsubstreams.Module) (code []byte, poolKey string, maxMemorySize uint64, err error) {
+	if int(module.BinaryIndex) >= len(p.request.Modules.Binaries) {
+		return nil, "", 0, fmt.Errorf("binary index %d out of range", module.BinaryIndex)
+	}
+	initialMemorySize := p.initialWasmMemorySizeOf(module.Name)
+	maxMemorySize = p.maxWasmMemorySizeOf(module.Name)
+	code, err = wasm.SizeMemory(p.request.Modules.Binaries[module.BinaryIndex].Content, initialMemorySize, maxMemorySize)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("sizing wasm memory: %w", err)
+	}
+	p.logger.Info("wasm memory sized", zap.String("module", module.Name), zap.Uint64("initial_memory_size", initialMemorySize), zap.Uint64("max_memory_size", maxMemorySize))
+
+	poolKey = fmt.Sprintf("%s-%d-%d", manifest.HashModuleAsString(p.request.Modules, p.graph, module), initialMemorySize, maxMemorySize)
+	return code, poolKey, maxMemorySize, nil
+}
+
 func (p *Pipeline) buildWASM(ctx context.Context, request *pbsubstreams.Request, modules []*pbsubstreams.Module) error {
 	p.wasmOutputs = map[string][]byte{}
-	p.wasmRuntime = wasm.NewRuntime(p.wasmExtensions)
+	p.initWASMPool()
 	tracer := otel.GetTracerProvider().Tracer("executor")
 
+	// Mappers whose output is the input of another module
+	consumed := map[string]bool{}
+	for _, module := range modules {
+		for _, input := range module.Inputs {
+			if in, ok := input.Input.(*pbsubstreams.Module_Input_Map_); ok {
+				consumed[in.Map.ModuleName] = true
+			}
+		}
+	}
+
 	for _, module := range modules {
 		isOutput := p.outputModuleMap[module.Name]
 		var inputs []*wasm.Input
@@ -605,23 +943,26 @@ func (p *Pipeline) buildWASM(ctx context.Context, request *pbsubstreams.Request,
 				})
 			case *pbsubstreams.Module_Input_Store_:
 				inputName := input.GetStore().ModuleName
-				if input.GetStore().Mode == pbsubstreams.Module_Input_Store_DELTAS {
-					inputs = append(inputs, &wasm.Input{
-						Type:   wasm.InputStore,
-						Name:   inputName,
-						Store:  p.storeMap[inputName],
-						Deltas: true,
-					})
-				} else {
-					inputs = append(inputs, &wasm.Input{
-						Type:  wasm.InputStore,
-						Name:  inputName,
-						Store: p.storeMap[inputName],
-					})
-					if p.storeMap[inputName] == nil {
-						return fmt.Errorf("no store with name %q", inputName)
-					}
+				if p.storeMap[inputName] == nil {
+					return fmt.Errorf("no store with name %q", inputName)
+				}
+				var inputType wasm.InputType
+				switch mode := in.Store.Mode; mode {
+				case pbsubstreams.Module_Input_Store_UNSET, pbsubstreams.Module_Input_Store_GET:
+					// Unset is read as get, like packages built before the
+					// modes were checked
+					inputType = wasm.InputStore
+				case pbsubstreams.Module_Input_Store_DELTAS:
+					inputType = wasm.InputStoreDeltas
+				default:
+					// Refused by the validation of the modules
+					return fmt.Errorf("module %q: store input %q: unknown mode %s", module.Name, inputName, mode)
 				}
+				inputs = append(inputs, &wasm.Input{
+					Type:  inputType,
+					Name:  inputName,
+					Store: p.storeMap[inputName],
+				})
 
 			case *pbsubstreams.Module_Input_Source_:
 				inputs = append(inputs, &wasm.Input{
@@ -635,30 +976,52 @@ func (p *Pipeline) buildWASM(ctx context.Context, request *pbsubstreams.Request,
 
 		modName := module.Name // to ensure it's enclosed
 		entrypoint := module.BinaryEntrypoint
-		code := p.request.Modules.Binaries[module.BinaryIndex]
-		wasmModule, err := p.wasmRuntime.NewModule(ctx, request, code.Content, module.Name, entrypoint)
+		code, poolKey, maxMemorySize, err := p.wasmCodeOf(module)
+		if err != nil {
+			return fmt.Errorf("module %q: %w", module.Name, err)
+		}
+		wasmModule, err := p.wasmModulePool.Checkout(ctx, request, poolKey, code, module.Name, entrypoint)
 		if err != nil {
 			return fmt.Errorf("new wasm module: %w", err)
 		}
 
+		var filter *blockFilter
+		if module.BlockFilter != nil {
+			if filter, err = newBlockFilter(module.BlockFilter); err != nil {
+				return fmt.Errorf("module %q: block filter: %w", module.Name, err)
+			}
+		}
+
 		switch kind := module.Kind.(type) {
 		case *pbsubstreams.Module_KindMap_:
 			outType := strings.TrimPrefix(module.Output.Type, "proto:")
 
 			baseExecutor := BaseExecutor{
-				moduleName: module.Name,
-				wasmModule: wasmModule,
-				entrypoint: entrypoint,
-				wasmInputs: inputs,
-				isOutput:   isOutput,
-				tracer:     tracer,
+				moduleName:  module.Name,
+				wasmModule:  wasmModule,
+				entrypoint:  entrypoint,
+				wasmInputs:  inputs,
+				isOutput:    isOutput,
+				tracer:      tracer,
+				blockFilter: filter,
+
+				maxMemorySize: maxMemorySize,
+				fuelLimit:     p.wasmFuelLimitOf(module.Name),
+
+				wasmPool: p.wasmModulePool,
+				poolKey:  poolKey,
+				wasmCode: code,
+				request:  request,
 			}
 
 			baseExecutor.cache = p.moduleOutputCache.OutputCaches[module.Name]
 
 			executor := &MapperModuleExecutor{
-				BaseExecutor: baseExecutor,
-				outputType:   outType,
+				BaseExecutor:            baseExecutor,
+				outputType:              outType,
+				maxOutputSize:           p.maxModuleOutputSize,
+				truncateOversizedOutput: request.TruncateOversizedOutputs,
+				hasDependents:           consumed[module.Name],
 			}
 
 			p.moduleExecutors = append(p.moduleExecutors, executor)
@@ -680,12 +1043,21 @@ func (p *Pipeline) buildWASM(ctx context.Context, request *pbsubstreams.Request,
 			})
 
 			baseExecutor := BaseExecutor{
-				moduleName: modName,
-				isOutput:   isOutput,
-				wasmModule: wasmModule,
-				entrypoint: entrypoint,
-				wasmInputs: inputs,
-				tracer:     tracer,
+				moduleName:  modName,
+				isOutput:    isOutput,
+				wasmModule:  wasmModule,
+				entrypoint:  entrypoint,
+				wasmInputs:  inputs,
+				tracer:      tracer,
+				blockFilter: filter,
+
+				maxMemorySize: maxMemorySize,
+				fuelLimit:     p.wasmFuelLimitOf(module.Name),
+
+				wasmPool: p.wasmModulePool,
+				poolKey:  poolKey,
+				wasmCode: code,
+				request:  request,
 			}
 
 			baseExecutor.cache = p.moduleOutputCache.OutputCaches[module.Name]
@@ -697,6 +1069,29 @@ func (p *Pipeline) buildWASM(ctx context.Context, request *pbsubstreams.Request,
 
 			p.moduleExecutors = append(p.moduleExecutors, s)
 			continue
+		case *pbsubstreams.Module_KindBlockIndex_:
+			baseExecutor := BaseExecutor{
+				moduleName:  modName,
+				isOutput:    isOutput,
+				wasmModule:  wasmModule,
+				entrypoint:  entrypoint,
+				wasmInputs:  inputs,
+				tracer:      tracer,
+				blockFilter: filter,
+
+				maxMemorySize: maxMemorySize,
+				fuelLimit:     p.wasmFuelLimitOf(module.Name),
+
+				wasmPool: p.wasmModulePool,
+				poolKey:  poolKey,
+				wasmCode: code,
+				request:  request,
+			}
+
+			baseExecutor.cache = p.moduleOutputCache.OutputCaches[module.Name]
+
+			p.moduleExecutors = append(p.moduleExecutors, &IndexModuleExecutor{BaseExecutor: baseExecutor})
+			continue
 		default:
 			return fmt.Errorf("invalid kind %q input module %q", module.Kind, module.Name)
 		}
@@ -711,6 +1106,10 @@ func (p *Pipeline) saveStoresSnapshots(ctx context.Context, boundaryBlock uint64
 			// skip saving snapshot for non-output stores in sub request
 			continue
 		}
+		if p.isQuarantined(store.Name) {
+			// the store stopped being updated when it was quarantined
+			continue
+		}
 
 		_, span := p.tracer.Start(ctx, "save_store_snapshot", ttrace.WithAttributes(attribute.String("store", store.Name)))
 		stateWriter, err := store.WriteState(ctx, boundaryBlock)
@@ -775,8 +1174,12 @@ func loadCompleteStores(ctx context.Context, storeMap map[string]*state.Store, r
 	return nil
 }
 
-func returnModuleDataOutputs(clock *pbsubstreams.Clock, step bstream.StepType, cursor *bstream.Cursor, moduleOutputs []*pbsubstreams.ModuleOutput, respFunc func(resp *pbsubstreams.Response) error) error {
-	protoStep, _ := pbsubstreams.StepToProto(step, false)
+func returnModuleDataOutputs(clock *pbsubstreams.Clock, step bstream.StepType, cursor *bstream.Cursor, moduleOutputs []*pbsubstreams.ModuleOutput, finalBlocksOnly bool, respFunc func(resp *pbsubstreams.Response) error) error {
+	protoStep, skip := pbsubstreams.StepToProto(step, finalBlocksOnly)
+	if finalBlocksOnly && skip {
+		// Never sent, a block that is not final can still be undone
+		return nil
+	}
 	out := &pbsubstreams.BlockScopedData{
 		Outputs: moduleOutputs,
 		Clock:   clock,
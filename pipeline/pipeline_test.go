@@ -1,10 +1,21 @@
 package pipeline
 
 import (
+	"context"
 	"testing"
 
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/manifest"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/wasm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestStoreSaveBoundaries(t *testing.T) {
@@ -224,3 +235,317 @@ func TestBump(t *testing.T) {
 		})
 	}
 }
+
+func TestSortModuleOutputs(t *testing.T) {
+	moduleOutputs := []*pbsubstreams.ModuleOutput{
+		{Name: "store_a"},
+		{Name: "store_logs_only"},
+		{Name: "map_b"},
+		{Name: "map_logs_only"},
+		{Name: "map_c"},
+	}
+
+	sortModuleOutputs(moduleOutputs, []string{"map_c", "store_a", "map_b", "map_c"})
+
+	var names []string
+	for _, moduleOutput := range moduleOutputs {
+		names = append(names, moduleOutput.Name)
+	}
+	assert.Equal(t, []string{"map_c", "store_a", "map_b", "store_logs_only", "map_logs_only"}, names)
+}
+
+func TestPipeline_FinalBlocksOnly(t *testing.T) {
+	tests := []struct {
+		name             string
+		finalBlocksOnly  bool
+		step             bstream.StepType
+		expectStep       pbsubstreams.ForkStep
+		expectSent       bool
+		expectReversible bool
+	}{
+		{"new block", false, bstream.StepNew, pbsubstreams.ForkStep_STEP_NEW, true, true},
+		{"final block", true, bstream.StepIrreversible, pbsubstreams.ForkStep_STEP_IRREVERSIBLE, true, false},
+		{"new final block", true, bstream.StepNewIrreversible, pbsubstreams.ForkStep_STEP_IRREVERSIBLE, true, false},
+		{"reversible block in final blocks only", true, bstream.StepNew, pbsubstreams.ForkStep_STEP_UNKNOWN, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+
+			code, err := wasmtime.Wat2Wasm(echoWAT)
+			require.NoError(t, err)
+			wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_echo")
+			require.NoError(t, err)
+
+			cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+			_, err = cache.LoadAtBlock(ctx, 0)
+			require.NoError(t, err)
+
+			var sent []*pbsubstreams.BlockScopedData
+			p := &Pipeline{
+				request:         &pbsubstreams.Request{OutputModules: []string{"map_b"}, FinalBlocksOnly: test.finalBlocksOnly},
+				outputModuleMap: map[string]bool{"map_b": true},
+				forkHandler:     NewForkHandle(),
+				logger:          zap.NewNop(),
+				tracer:          tracer,
+				clock:           &pbsubstreams.Clock{Id: "1a", Number: 1},
+				wasmOutputs:     map[string][]byte{"map_a": []byte("block")},
+				respFunc: func(resp *pbsubstreams.Response) error {
+					sent = append(sent, resp.GetData())
+					return nil
+				},
+				moduleExecutors: []ModuleExecutor{&MapperModuleExecutor{BaseExecutor: BaseExecutor{
+					moduleName: "map_b",
+					wasmModule: wasmModule,
+					wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+					cache:      cache,
+					tracer:     tracer,
+				}}},
+			}
+			_, span := tracer.Start(ctx, "test")
+
+			cursor := testCursor(test.step, "1a", 1, "1a", 1)
+			require.NoError(t, p.executeModules(ctx, span, test.step, cursor))
+
+			if test.expectSent {
+				require.Len(t, sent, 1)
+				assert.Equal(t, test.expectStep, sent[0].Step)
+				assert.Equal(t, cursor.ToOpaque(), sent[0].Cursor)
+			} else {
+				assert.Empty(t, sent)
+			}
+			assert.Equal(t, test.expectReversible, len(p.forkHandler.reversibleOutputs) != 0)
+		})
+	}
+}
+
+func TestPipeline_PrunedModules(t *testing.T) {
+	sourceInput := []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.test.Block"}}}}
+	mapInput := func(name string) []*pbsubstreams.Module_Input {
+		return []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: name}}}}
+	}
+	storeInput := func(name string) *pbsubstreams.Module_Input {
+		return &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Store_{Store: &pbsubstreams.Module_Input_Store{ModuleName: name}}}
+	}
+	mapKind := &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:sf.test.Output"}}
+	output := &pbsubstreams.Module_Output{Type: "proto:sf.test.Output"}
+	storeKind := &pbsubstreams.Module_KindStore_{KindStore: &pbsubstreams.Module_KindStore{UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, ValueType: "string"}}
+
+	echoCode, err := wasmtime.Wat2Wasm(echoWAT)
+	require.NoError(t, err)
+	binaries := []*pbsubstreams.Binary{
+		{Type: "wasm/rust-v1", Content: echoCode},
+		// Compiling the modules using it fails
+		{Type: "wasm/rust-v1", Content: []byte("not wasm")},
+	}
+
+	leafModules := []*pbsubstreams.Module{
+		{Name: "map_a", Kind: mapKind, Inputs: sourceInput, Output: output, BinaryEntrypoint: "map_echo"},
+		{Name: "map_leaf", Kind: mapKind, Inputs: mapInput("map_a"), Output: output, BinaryEntrypoint: "map_echo"},
+	}
+	packageModules := []*pbsubstreams.Module{
+		leafModules[0],
+		leafModules[1],
+		{Name: "store_unrelated", Kind: storeKind, Inputs: mapInput("map_a"), BinaryIndex: 1, BinaryEntrypoint: "store"},
+		{Name: "map_unrelated", Kind: mapKind, Inputs: append(mapInput("map_a"), storeInput("store_unrelated")), Output: output, BinaryIndex: 1, BinaryEntrypoint: "map"},
+	}
+
+	run := func(t *testing.T, modules []*pbsubstreams.Module) []*pbsubstreams.ModuleOutput {
+		ctx := context.Background()
+		tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+
+		graph, err := manifest.NewModuleGraph(modules)
+		require.NoError(t, err)
+
+		var sent []*pbsubstreams.ModuleOutput
+		request := &pbsubstreams.Request{
+			OutputModules: []string{"map_leaf"},
+			Modules:       &pbsubstreams.Modules{Modules: modules, Binaries: binaries},
+		}
+		p := New(ctx, tracer, request, graph, "sf.test.Block", dstore.NewMockStore(nil), 10, nil, 0, func(resp *pbsubstreams.Response) error {
+			sent = append(sent, resp.GetData().Outputs...)
+			return nil
+		})
+		p.logger = zap.NewNop()
+		p.moduleOutputCache = outputs.NewModuleOutputCache(10, zap.NewNop())
+
+		require.NoError(t, p.build())
+		for _, module := range p.modules {
+			_, err := p.moduleOutputCache.RegisterModule(module, manifest.HashModuleAsString(request.Modules, graph, module), p.baseStateStore)
+			require.NoError(t, err)
+		}
+		p.storeMap, err = p.buildStoreMap()
+		require.NoError(t, err)
+		require.NoError(t, p.buildWASM(ctx, request, p.modules))
+		for _, cache := range p.moduleOutputCache.OutputCaches {
+			_, err := cache.LoadAtBlock(ctx, 0)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []string{"map_a", "map_leaf"}, p.executionOrder)
+		assert.Empty(t, p.storeModules)
+		assert.Len(t, p.moduleOutputCache.OutputCaches, 2)
+
+		_, span := tracer.Start(ctx, "test")
+		for _, blockNum := range []uint64{1, 2} {
+			p.clock = &pbsubstreams.Clock{Id: "a", Number: blockNum}
+			p.wasmOutputs = map[string][]byte{"sf.test.Block": []byte("block")}
+			cursor := testCursor(bstream.StepNew, "a", blockNum, "a", 0)
+			require.NoError(t, p.executeModules(ctx, span, bstream.StepNew, cursor))
+		}
+		return sent
+	}
+
+	expected := run(t, leafModules)
+	require.Len(t, expected, 2)
+	assert.Equal(t, []byte("block"), expected[0].GetMapOutput().Value)
+
+	pruned := run(t, packageModules)
+	require.Len(t, pruned, len(expected))
+	for i := range expected {
+		assert.True(t, proto.Equal(expected[i], pruned[i]), "block %d", i+1)
+	}
+}
+
+func TestPipeline_CorruptOutputCache(t *testing.T) {
+	ctx := context.Background()
+	tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+	sourceInput := []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.test.Block"}}}}
+	mapInput := []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: "map_a"}}}}
+	mapKind := &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:sf.test.Output"}}
+	output := &pbsubstreams.Module_Output{Type: "proto:sf.test.Output"}
+
+	echoCode, err := wasmtime.Wat2Wasm(echoWAT)
+	require.NoError(t, err)
+	modules := []*pbsubstreams.Module{
+		{Name: "map_a", Kind: mapKind, Inputs: sourceInput, Output: output, BinaryEntrypoint: "map_echo"},
+		{Name: "map_leaf", Kind: mapKind, Inputs: mapInput, Output: output, BinaryEntrypoint: "map_echo"},
+	}
+	graph, err := manifest.NewModuleGraph(modules)
+	require.NoError(t, err)
+
+	var sent []*pbsubstreams.ModuleOutput
+	request := &pbsubstreams.Request{
+		OutputModules: []string{"map_leaf"},
+		Modules:       &pbsubstreams.Modules{Modules: modules, Binaries: []*pbsubstreams.Binary{{Type: "wasm/rust-v1", Content: echoCode}}},
+	}
+	p := New(ctx, tracer, request, graph, "sf.test.Block", dstore.NewMockStore(nil), 10, nil, 0, func(resp *pbsubstreams.Response) error {
+		sent = append(sent, resp.GetData().Outputs...)
+		return nil
+	})
+	p.logger = zap.NewNop()
+	p.moduleOutputCache = outputs.NewModuleOutputCache(10, zap.NewNop())
+
+	require.NoError(t, p.build())
+	corruptFile := outputs.ComputeSegmentFilename(0, 10, 10)
+	for _, module := range p.modules {
+		cache, err := p.moduleOutputCache.RegisterModule(module, manifest.HashModuleAsString(request.Modules, graph, module), p.baseStateStore)
+		require.NoError(t, err)
+		// A segment holding a wrong output for the blocks, cut short
+		cache.Store.(*dstore.MockStore).SetFile(corruptFile, []byte(`{"a":{"block_num":1,"block_id":"a","payload":"d3Jvbmc=","timest`))
+	}
+	p.storeMap, err = p.buildStoreMap()
+	require.NoError(t, err)
+	require.NoError(t, p.buildWASM(ctx, request, p.modules))
+	for _, cache := range p.moduleOutputCache.OutputCaches {
+		found, err := cache.LoadAtBlock(ctx, 0)
+		require.NoError(t, err)
+		assert.False(t, found, "corrupt file taken as a cache miss")
+	}
+
+	_, span := tracer.Start(ctx, "test")
+	for _, blockNum := range []uint64{1, 2} {
+		p.clock = &pbsubstreams.Clock{Id: "a", Number: blockNum}
+		p.wasmOutputs = map[string][]byte{"sf.test.Block": []byte("block")}
+		cursor := testCursor(bstream.StepNew, "a", blockNum, "a", 0)
+		require.NoError(t, p.executeModules(ctx, span, bstream.StepNew, cursor))
+	}
+
+	require.Len(t, sent, 2)
+	for _, out := range sent {
+		assert.Equal(t, "map_leaf", out.Name)
+		assert.Equal(t, []byte("block"), out.GetMapOutput().Value)
+	}
+	for name, cache := range p.moduleOutputCache.OutputCaches {
+		_, err := cache.Store.OpenObject(ctx, corruptFile)
+		assert.Error(t, err, "%s: corrupt file deleted", name)
+		_, err = cache.Store.OpenObject(ctx, corruptFile+".corrupt")
+		assert.NoError(t, err, "%s: corrupt file kept aside", name)
+	}
+}
+
+func TestPipeline_PrunedModulesKeepStoreInputs(t *testing.T) {
+	mapInput := func(name string) *pbsubstreams.Module_Input {
+		return &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: name}}}
+	}
+	storeInput := func(name string) *pbsubstreams.Module_Input {
+		return &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Store_{Store: &pbsubstreams.Module_Input_Store{ModuleName: name}}}
+	}
+	mapKind := &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}}
+	storeKind := &pbsubstreams.Module_KindStore_{KindStore: &pbsubstreams.Module_KindStore{}}
+
+	modules := []*pbsubstreams.Module{
+		{Name: "map_a", Kind: mapKind},
+		{Name: "store_read", Kind: storeKind, Inputs: []*pbsubstreams.Module_Input{mapInput("map_a")}},
+		{Name: "map_leaf", Kind: mapKind, Inputs: []*pbsubstreams.Module_Input{mapInput("map_a"), storeInput("store_read")}},
+		{Name: "store_unrelated", Kind: storeKind, Inputs: []*pbsubstreams.Module_Input{mapInput("map_a")}},
+		{Name: "map_unrelated", Kind: mapKind, Inputs: []*pbsubstreams.Module_Input{storeInput("store_unrelated")}},
+	}
+	graph, err := manifest.NewModuleGraph(modules)
+	require.NoError(t, err)
+
+	p := &Pipeline{
+		request: &pbsubstreams.Request{OutputModules: []string{"map_leaf"}, Modules: &pbsubstreams.Modules{Modules: modules}},
+		graph:   graph,
+		logger:  zap.NewNop(),
+	}
+	require.NoError(t, p.buildModules())
+
+	var names []string
+	for _, module := range p.modules {
+		names = append(names, module.Name)
+	}
+	assert.ElementsMatch(t, []string{"map_a", "store_read", "map_leaf"}, names)
+	require.Len(t, p.storeModules, 1)
+	assert.Equal(t, "store_read", p.storeModules[0].Name)
+}
+
+func TestPipeline_ValidateBinaries(t *testing.T) {
+	sourceInput := &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.test.Block"}}}
+	mapInput := func(name string) *pbsubstreams.Module_Input {
+		return &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: name}}}
+	}
+	mapKind := &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}}
+
+	echoCode, err := wasmtime.Wat2Wasm(echoWAT)
+	require.NoError(t, err)
+	binaries := []*pbsubstreams.Binary{
+		{Type: "wasm/rust-v1", Content: echoCode},
+		{Type: "wasm/rust-v1", Content: []byte("not wasm")},
+	}
+
+	modules := []*pbsubstreams.Module{
+		{Name: "map_a", Kind: mapKind, Inputs: []*pbsubstreams.Module_Input{sourceInput}, BinaryEntrypoint: "map_echo"},
+		{Name: "map_missing", Kind: mapKind, Inputs: []*pbsubstreams.Module_Input{mapInput("map_a")}, BinaryEntrypoint: "map_missing"},
+		{Name: "map_two_inputs", Kind: mapKind, Inputs: []*pbsubstreams.Module_Input{mapInput("map_a"), sourceInput}, BinaryEntrypoint: "map_echo"},
+		{Name: "map_garbage", Kind: mapKind, Inputs: []*pbsubstreams.Module_Input{mapInput("map_a")}, BinaryIndex: 1, BinaryEntrypoint: "map_echo"},
+		{Name: "map_leaf", Kind: mapKind, Inputs: []*pbsubstreams.Module_Input{mapInput("map_missing"), mapInput("map_two_inputs"), mapInput("map_garbage")}, BinaryEntrypoint: "map_echo"},
+	}
+	graph, err := manifest.NewModuleGraph(modules)
+	require.NoError(t, err)
+
+	p := &Pipeline{
+		request: &pbsubstreams.Request{OutputModules: []string{"map_leaf"}, Modules: &pbsubstreams.Modules{Modules: modules, Binaries: binaries}},
+		graph:   graph,
+		logger:  zap.NewNop(),
+	}
+	err = p.build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `module "map_missing": entrypoint "map_missing" is not an exported function`)
+	assert.Contains(t, err.Error(), `module "map_two_inputs": entrypoint "map_echo" takes 2 parameters but the module inputs imply 4`)
+	assert.Contains(t, err.Error(), `module "map_garbage": invalid wasm code`)
+	assert.Contains(t, err.Error(), `module "map_leaf": entrypoint "map_echo" takes 2 parameters but the module inputs imply 6`)
+	assert.NotContains(t, err.Error(), `module "map_a"`)
+}
@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/streamingfast/bstream"
+)
+
+// DefaultBlockPrefetchDepth is the number of blocks read ahead of the one
+// being executed
+const DefaultBlockPrefetchDepth = 4
+
+type prefetchedBlock struct {
+	block *bstream.Block
+	obj   interface{}
+	// processed is set for the blocks handled synchronously, it receives
+	// the result of the handler
+	processed chan error
+}
+
+// BlockPrefetcher sits between the block source and a handler, usually the
+// pipeline: it reads up to `depth` blocks ahead, loading their payload, while
+// the handler executes the current block from its own goroutine. Blocks are
+// still handled one at a time and in order.
+//
+// Undo steps flush the prefetched blocks: they are handled once every block
+// before them was, before the block source moves on.
+//
+// The block source must run with the context returned by NewBlockPrefetcher,
+// which gets cancelled when the handler fails, and Wait must be called once
+// it returns.
+type BlockPrefetcher struct {
+	handler bstream.Handler
+	blocks  chan *prefetchedBlock
+	stop    chan struct{}
+	cancel  context.CancelFunc
+
+	done chan struct{}
+	err  error // handler error, set before done is closed
+}
+
+func NewBlockPrefetcher(ctx context.Context, handler bstream.Handler, depth int) (*BlockPrefetcher, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &BlockPrefetcher{
+		handler: handler,
+		blocks:  make(chan *prefetchedBlock, depth),
+		stop:    make(chan struct{}),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p, ctx
+}
+
+func (p *BlockPrefetcher) run() {
+	defer close(p.done)
+
+	for {
+		// Stopping takes precedence over the queued blocks
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		var next *prefetchedBlock
+		select {
+		case <-p.stop:
+			return
+		case b, ok := <-p.blocks:
+			if !ok {
+				return
+			}
+			next = b
+		}
+
+		err := p.handler.ProcessBlock(next.block, next.obj)
+		if next.processed != nil {
+			next.processed <- err
+		}
+		if err != nil {
+			p.err = err
+			// Stops the block source, which could otherwise wait for the
+			// next block before noticing
+			p.cancel()
+			return
+		}
+	}
+}
+
+// ProcessBlock queues the block for the handler, waiting while `depth` blocks
+// are already queued. It returns the error of the handler once it failed.
+func (p *BlockPrefetcher) ProcessBlock(block *bstream.Block, obj interface{}) error {
+	block, err := preloadPayload(block)
+	if err != nil {
+		return err
+	}
+
+	next := &prefetchedBlock{block: block, obj: obj}
+	if stepable, ok := obj.(bstream.Stepable); ok && stepable.Step() == bstream.StepUndo {
+		next.processed = make(chan error, 1)
+	}
+
+	select {
+	case <-p.done:
+		return p.err
+	case p.blocks <- next:
+	}
+
+	if next.processed == nil {
+		return nil
+	}
+	select {
+	case <-p.done:
+		return p.err
+	case err := <-next.processed:
+		return err
+	}
+}
+
+// Wait returns once the handler stopped, given `sourceErr`, the result of the
+// block source. When the source ended without error, the queued blocks are
+// handled first. Otherwise they are dropped. The error of the handler takes
+// precedence, since it stopped the source.
+func (p *BlockPrefetcher) Wait(sourceErr error) error {
+	if sourceErr == nil {
+		close(p.blocks)
+	} else {
+		close(p.stop)
+	}
+	<-p.done
+	p.cancel()
+
+	if p.err != nil {
+		return p.err
+	}
+	return sourceErr
+}
+
+// preloadPayload returns a copy of `block` holding its payload in memory, the
+// payload of a block can be fetched from a remote store when first read. The
+// block itself is left untouched, it can be shared with other streams.
+func preloadPayload(block *bstream.Block) (*bstream.Block, error) {
+	if block.Payload == nil {
+		return block, nil
+	}
+
+	data, err := block.Payload.Get()
+	if err != nil {
+		return nil, fmt.Errorf("getting block %d %q: %w", block.Number, block.Id, err)
+	}
+
+	return bstream.MemoryBlockPayloadSetter(&bstream.Block{
+		Id:             block.Id,
+		Number:         block.Number,
+		PreviousId:     block.PreviousId,
+		Timestamp:      block.Timestamp,
+		LibNum:         block.LibNum,
+		PayloadKind:    block.PayloadKind,
+		PayloadVersion: block.PayloadVersion,
+	}, data)
+}
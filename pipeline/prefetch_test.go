@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/bstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStepObj struct {
+	step bstream.StepType
+}
+
+func (o *testStepObj) Step() bstream.StepType { return o.step }
+
+type recordingHandler struct {
+	lock      sync.Mutex
+	processed []string
+	delay     time.Duration
+	failAt    uint64
+}
+
+func (h *recordingHandler) ProcessBlock(block *bstream.Block, obj interface{}) error {
+	time.Sleep(h.delay)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.failAt != 0 && block.Number == h.failAt {
+		return fmt.Errorf("failed at block %d", block.Number)
+	}
+	h.processed = append(h.processed, fmt.Sprintf("%d%s", block.Number, obj.(*testStepObj).step))
+	return nil
+}
+
+func (h *recordingHandler) blocks() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]string(nil), h.processed...)
+}
+
+func TestBlockPrefetcher(t *testing.T) {
+	handler := &recordingHandler{delay: time.Millisecond}
+	prefetcher, _ := NewBlockPrefetcher(context.Background(), handler, 2)
+
+	for num := uint64(1); num <= 3; num++ {
+		require.NoError(t, prefetcher.ProcessBlock(&bstream.Block{Number: num}, &testStepObj{step: bstream.StepNew}))
+	}
+
+	// Undo steps flush the prefetched blocks
+	require.NoError(t, prefetcher.ProcessBlock(&bstream.Block{Number: 3}, &testStepObj{step: bstream.StepUndo}))
+	assert.Equal(t, []string{"1new", "2new", "3new", "3undo"}, handler.blocks())
+
+	for num := uint64(3); num <= 5; num++ {
+		require.NoError(t, prefetcher.ProcessBlock(&bstream.Block{Number: num}, &testStepObj{step: bstream.StepNew}))
+	}
+
+	// The queued blocks are handled once the source ends
+	require.NoError(t, prefetcher.Wait(nil))
+	assert.Equal(t, []string{"1new", "2new", "3new", "3undo", "3new", "4new", "5new"}, handler.blocks())
+}
+
+func TestBlockPrefetcher_HandlerError(t *testing.T) {
+	handler := &recordingHandler{failAt: 2}
+	prefetcher, ctx := NewBlockPrefetcher(context.Background(), handler, 2)
+
+	var err error
+	for num := uint64(1); num <= 10 && err == nil; num++ {
+		err = prefetcher.ProcessBlock(&bstream.Block{Number: num}, &testStepObj{step: bstream.StepNew})
+	}
+
+	// The block source is stopped even when no block comes anymore
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("block source context not cancelled")
+	}
+
+	assert.EqualError(t, prefetcher.Wait(context.Canceled), "failed at block 2")
+	assert.Equal(t, []string{"1new"}, handler.blocks())
+}
+
+func TestBlockPrefetcher_SourceError(t *testing.T) {
+	handler := &recordingHandler{delay: 10 * time.Millisecond}
+	prefetcher, _ := NewBlockPrefetcher(context.Background(), handler, 4)
+
+	for num := uint64(1); num <= 4; num++ {
+		require.NoError(t, prefetcher.ProcessBlock(&bstream.Block{Number: num}, &testStepObj{step: bstream.StepNew}))
+	}
+
+	sourceErr := errors.New("source failed")
+	assert.Equal(t, sourceErr, prefetcher.Wait(sourceErr))
+	assert.Less(t, len(handler.blocks()), 4, "queued blocks are dropped")
+}
+
+// BenchmarkBlockPrefetcher simulates a block source and an execution taking
+// the same time per block: with read ahead, a block costs about the max of
+// both instead of their sum.
+func BenchmarkBlockPrefetcher(b *testing.B) {
+	const delay = 200 * time.Microsecond
+
+	for _, depth := range []int{0, 4} {
+		b.Run(fmt.Sprintf("depth_%d", depth), func(b *testing.B) {
+			handler := &recordingHandler{delay: delay}
+			var h bstream.Handler = handler
+			var prefetcher *BlockPrefetcher
+			if depth > 0 {
+				prefetcher, _ = NewBlockPrefetcher(context.Background(), handler, depth)
+				h = prefetcher
+			}
+
+			for i := 0; i < b.N; i++ {
+				time.Sleep(delay) // fetching the block
+				if err := h.ProcessBlock(&bstream.Block{Number: uint64(i)}, &testStepObj{step: bstream.StepNew}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if prefetcher != nil {
+				if err := prefetcher.Wait(nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/streamingfast/substreams"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"go.uber.org/zap"
+)
+
+// shouldQuarantine tells if the failure of a module can be contained to the
+// modules depending on it. Only deterministic failures of the module code
+// qualify, any other error could affect every module.
+func (p *Pipeline) shouldQuarantine(err error) bool {
+	if !p.request.QuarantineFailedModules || p.isSubrequest {
+		return false
+	}
+
+	var errExecutor *ErrorExecutor
+	return errors.As(err, &errExecutor)
+}
+
+func (p *Pipeline) isQuarantined(moduleName string) bool {
+	_, found := p.quarantinedModules[moduleName]
+	return found
+}
+
+// quarantine marks the failed module and every module depending on it as
+// failed, so they are not executed anymore, and sends the failure to the
+// client right away, whether the outputs of the block are sent or not. The
+// original error is returned when no requested output module is left to be
+// served.
+func (p *Pipeline) quarantine(failedExecutor ModuleExecutor, err error) error {
+	failedName := failedExecutor.Name()
+
+	descendants, graphErr := p.graph.DescendantsOf(failedName)
+	if graphErr != nil {
+		return fmt.Errorf("quarantining module %q: %w", failedName, graphErr)
+	}
+
+	if p.quarantinedModules == nil {
+		p.quarantinedModules = map[string]bool{}
+	}
+	quarantined := []string{failedName}
+	p.quarantinedModules[failedName] = true
+	for _, module := range descendants {
+		if !p.quarantinedModules[module.Name] {
+			p.quarantinedModules[module.Name] = true
+			quarantined = append(quarantined, module.Name)
+		}
+	}
+
+	if len(p.failedOutputModules()) == len(p.outputModuleMap) {
+		return err
+	}
+
+	p.logger.Warn("module quarantined after failure", zap.String("module_name", failedName), zap.Strings("quarantined_modules", quarantined), zap.Error(err))
+
+	failure := &pbsubstreams.ModuleFailure{
+		Reason:             err.Error(),
+		QuarantinedModules: quarantined,
+		ModuleName:         failedName,
+		Clock:              p.clock,
+	}
+	if err := p.respFunc(substreams.NewModuleFailureResponse(failure)); err != nil {
+		return fmt.Errorf("sending failure of module %q: %w", failedName, err)
+	}
+	return nil
+}
+
+func (p *Pipeline) failedOutputModules() (out []string) {
+	for name := range p.outputModuleMap {
+		if p.isQuarantined(name) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return
+}
+
+// quarantineTerminationError returns an error when some requested output
+// modules were quarantined, the stream must not end successfully then.
+func (p *Pipeline) quarantineTerminationError() error {
+	if failed := p.failedOutputModules(); len(failed) != 0 {
+		return fmt.Errorf("requested output modules failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/manifest"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/wasm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// trapWAT fails on every call.
+const trapWAT = `
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_trap") (param $ptr i32) (param $len i32)
+    unreachable)
+)
+`
+
+func TestPipeline_QuarantineFailedModules(t *testing.T) {
+	mapInput := func(name string) []*pbsubstreams.Module_Input {
+		return []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: name}}}}
+	}
+	// map_b fails and map_c depends on it, map_d is on a separate branch
+	modules := []*pbsubstreams.Module{
+		{Name: "map_b", Kind: &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}}},
+		{Name: "map_c", Kind: &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}}, Inputs: mapInput("map_b")},
+		{Name: "map_d", Kind: &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{}}},
+	}
+
+	tests := []struct {
+		name          string
+		quarantine    bool
+		outputModules []string
+		startBlock    uint64
+		expectError   bool
+		expectOutputs []string // names of the outputs sent, in order
+	}{
+		{"quarantine disabled", false, []string{"map_c", "map_d"}, 1, true, nil},
+		{"unaffected output served", true, []string{"map_c", "map_d"}, 1, false, []string{"map_d", "map_d"}},
+		{"failure before the start block", true, []string{"map_c", "map_d"}, 2, false, []string{"map_d"}},
+		{"all outputs failed", true, []string{"map_c"}, 1, true, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			tracer := ttrace.NewNoopTracerProvider().Tracer("test")
+
+			graph, err := manifest.NewModuleGraph(modules)
+			require.NoError(t, err)
+
+			newExecutor := func(name, wat, entrypoint, input string) ModuleExecutor {
+				code, err := wasmtime.Wat2Wasm(wat)
+				require.NoError(t, err)
+				wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, name, entrypoint)
+				require.NoError(t, err)
+
+				cache := outputs.NewOutputCache(name, dstore.NewMockStore(nil), 10, zap.NewNop())
+				_, err = cache.LoadAtBlock(ctx, 0)
+				require.NoError(t, err)
+
+				return &MapperModuleExecutor{BaseExecutor: BaseExecutor{
+					moduleName: name,
+					wasmModule: wasmModule,
+					wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: input}},
+					cache:      cache,
+					tracer:     tracer,
+				}}
+			}
+
+			var sent []string
+			var failures []*pbsubstreams.ModuleFailure
+			outputModuleMap := map[string]bool{}
+			for _, name := range test.outputModules {
+				outputModuleMap[name] = true
+			}
+			p := &Pipeline{
+				request:                &pbsubstreams.Request{OutputModules: test.outputModules, QuarantineFailedModules: test.quarantine},
+				outputModuleMap:        outputModuleMap,
+				graph:                  graph,
+				forkHandler:            NewForkHandle(),
+				logger:                 zap.NewNop(),
+				tracer:                 tracer,
+				requestedStartBlockNum: test.startBlock,
+				respFunc: func(resp *pbsubstreams.Response) error {
+					if failure := resp.GetModuleFailure(); failure != nil {
+						failures = append(failures, failure)
+					}
+					for _, output := range resp.GetData().GetOutputs() {
+						sent = append(sent, output.Name)
+					}
+					return nil
+				},
+				moduleExecutors: []ModuleExecutor{
+					newExecutor("map_b", trapWAT, "map_trap", "src"),
+					newExecutor("map_c", echoWAT, "map_echo", "map_b"),
+					newExecutor("map_d", echoWAT, "map_echo", "src"),
+				},
+			}
+			_, span := tracer.Start(ctx, "test")
+
+			for _, blockNum := range []uint64{1, 2} {
+				p.clock = &pbsubstreams.Clock{Id: "a", Number: blockNum}
+				p.wasmOutputs = map[string][]byte{"src": []byte("block")}
+				cursor := testCursor(bstream.StepNew, "a", blockNum, "a", 0)
+
+				err = p.executeModules(ctx, span, bstream.StepNew, cursor)
+				if test.expectError {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), `module "map_b": wasm execution failed`)
+					return
+				}
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, test.expectOutputs, sent)
+			require.Len(t, failures, 1, "the failed module must be reported once, when it fails")
+			assert.Equal(t, "map_b", failures[0].ModuleName)
+			assert.Equal(t, uint64(1), failures[0].Clock.Number)
+			assert.Equal(t, []string{"map_b", "map_c"}, failures[0].QuarantinedModules)
+			assert.Contains(t, failures[0].Reason, `module "map_b": wasm execution failed`)
+
+			assert.EqualError(t, p.quarantineTerminationError(), "requested output modules failed: map_c")
+		})
+	}
+}
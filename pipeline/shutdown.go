@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+// Shutdown saves the work done since the last save boundaries when the
+// processing is interrupted, typically because the server is shutting down
+// or the client went away. It must be called once the block source stopped,
+// with a context that is still valid.
+//
+// The outputs collected in the current range of every output cache are saved
+// as truncated segments, each item holding the cursor of its block, so the
+// next run serves them from the caches and resumes the block source right
+// after them. The stores are saved up to the last processed block, provided
+// it is final and was entirely processed: a block interrupted halfway would
+// leave the stores in an inconsistent state.
+func (p *Pipeline) Shutdown(ctx context.Context) error {
+	p.blockLock.Lock()
+	defer p.blockLock.Unlock()
+
+	if p.moduleOutputCache == nil {
+		// Interrupted before the pipeline was initialized, nothing to save
+		return nil
+	}
+
+	ctx, span := p.tracer.Start(ctx, "pipeline_shutdown")
+	defer span.End()
+
+	if err := p.moduleOutputCache.FlushTruncated(ctx); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("saving output caches: %w", err)
+	}
+
+	cursor := p.lastProcessedCursor
+	switch {
+	case p.isSubrequest:
+		// Partial stores are only of use on the boundaries requested by
+		// the jobs planner, the job will be run again as a whole.
+	case p.blockInProgress:
+		p.logger.Warn("processing interrupted in the middle of a block, stores not saved", zap.Uint64("block_num", p.clock.Number))
+	case cursor == nil:
+	case !isFinalCursor(cursor):
+		p.logger.Info("last processed block is not final, stores not saved", zap.Stringer("cursor", cursor))
+	default:
+		if err := p.saveStoresSnapshots(ctx, cursor.Block.Num()+1); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("saving stores: %w", err)
+		}
+	}
+
+	p.logger.Info("pipeline shut down", zap.Stringer("last_processed_cursor", cursor))
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestPipeline_Shutdown(t *testing.T) {
+	tests := []struct {
+		name              string
+		lastCursor        *bstream.Cursor
+		blockInProgress   bool
+		isSubrequest      bool
+		expectStoreSaved  bool
+		expectCacheOutput string
+	}{
+		{
+			name:              "final block",
+			lastCursor:        testCursor(bstream.StepNewIrreversible, "15a", 15, "15a", 15),
+			expectStoreSaved:  true,
+			expectCacheOutput: outputs.ComputeSegmentFilename(10, 16, 10),
+		},
+		{
+			name:              "reversible block",
+			lastCursor:        testCursor(bstream.StepNew, "15a", 15, "12a", 12),
+			expectCacheOutput: outputs.ComputeSegmentFilename(10, 16, 10),
+		},
+		{
+			name:              "block in progress",
+			lastCursor:        testCursor(bstream.StepNewIrreversible, "15a", 15, "15a", 15),
+			blockInProgress:   true,
+			expectCacheOutput: outputs.ComputeSegmentFilename(10, 16, 10),
+		},
+		{
+			name:              "subrequest",
+			lastCursor:        testCursor(bstream.StepNewIrreversible, "15a", 15, "15a", 15),
+			isSubrequest:      true,
+			expectCacheOutput: outputs.ComputeSegmentFilename(10, 16, 10),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			store, err := state.NewStore("store_a", 10, 0, "hash_a", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), zap.NewNop())
+			require.NoError(t, err)
+			store.KV = map[string][]byte{"key": []byte("value")}
+
+			cacheStore := dstore.NewMockStore(nil)
+			cache := outputs.NewOutputCache("store_a", cacheStore, 10, zap.NewNop())
+			_, err = cache.LoadAtBlock(ctx, 10)
+			require.NoError(t, err)
+			for _, clock := range []*pbsubstreams.Clock{{Id: "14a", Number: 14}, {Id: "15a", Number: 15}} {
+				require.NoError(t, cache.Set(clock, "", []byte{0x01}))
+			}
+
+			p := &Pipeline{
+				tracer:              ttrace.NewNoopTracerProvider().Tracer("test"),
+				logger:              zap.NewNop(),
+				clock:               &pbsubstreams.Clock{Id: "15a", Number: 15},
+				isSubrequest:        test.isSubrequest,
+				storeMap:            map[string]*state.Store{"store_a": store},
+				moduleOutputCache:   outputs.NewModuleOutputCache(10, zap.NewNop()),
+				blockInProgress:     test.blockInProgress,
+				lastProcessedCursor: test.lastCursor,
+			}
+			p.moduleOutputCache.OutputCaches["store_a"] = cache
+
+			require.NoError(t, p.Shutdown(ctx))
+
+			exists, err := cacheStore.FileExists(ctx, test.expectCacheOutput)
+			require.NoError(t, err)
+			assert.True(t, exists, "truncated output cache segment")
+
+			exists, err = store.Store.FileExists(ctx, "0000000016-0000000000.kv")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectStoreSaved, exists, "store state")
+		})
+	}
+}
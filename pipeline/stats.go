@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/substreams"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"go.opentelemetry.io/otel/attribute"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultStatsInterval is the wall clock time between two stats messages
+const defaultStatsInterval = 10 * time.Second
+
+// pipelineStats accumulates the statistics of a request and sends them
+// periodically on the stream. Its zero value is ready to use.
+//
+// The stats are sent from their own goroutine, so every message of the stream
+// goes through `send`, which serializes them.
+type pipelineStats struct {
+	sendLock sync.Mutex
+	respFunc func(resp *pbsubstreams.Response) error
+
+	lock             sync.Mutex
+	currentBlock     uint64
+	blocksProcessed  uint64
+	moduleExecutions uint64
+	cacheHits        uint64
+	bytesSent        uint64
+	moduleNames      []string // in order of first execution
+	executionTimes   map[string]time.Duration
+}
+
+func (s *pipelineStats) send(resp *pbsubstreams.Response) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	if err := s.respFunc(resp); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.bytesSent += uint64(proto.Size(resp))
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *pipelineStats) moduleExecuted(moduleName string, duration time.Duration, cacheHit bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.executionTimes == nil {
+		s.executionTimes = map[string]time.Duration{}
+	}
+	if _, found := s.executionTimes[moduleName]; !found {
+		s.moduleNames = append(s.moduleNames, moduleName)
+	}
+	s.executionTimes[moduleName] += duration
+
+	s.moduleExecutions++
+	if cacheHit {
+		s.cacheHits++
+	}
+}
+
+func (s *pipelineStats) blockProcessed(blockNum uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.currentBlock = blockNum
+	s.blocksProcessed++
+}
+
+func (s *pipelineStats) message() *pbsubstreams.PipelineStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	msg := &pbsubstreams.PipelineStats{
+		CurrentBlock:    s.currentBlock,
+		BlocksProcessed: s.blocksProcessed,
+		BytesSent:       s.bytesSent,
+	}
+	if s.moduleExecutions != 0 {
+		msg.CacheHitRatio = float64(s.cacheHits) / float64(s.moduleExecutions)
+	}
+	for _, name := range s.moduleNames {
+		msg.Modules = append(msg.Modules, &pbsubstreams.ModuleStats{
+			Name:            name,
+			ExecutionTimeMs: uint64(s.executionTimes[name].Milliseconds()),
+		})
+	}
+	return msg
+}
+
+// run sends the stats every `interval` until `ctx` is done. Sending is driven
+// by wall clock time and not by blocks, so that the stream keeps showing
+// signs of life while no block gets processed.
+func (s *pipelineStats) run(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.send(substreams.NewPipelineStatsResponse(s.message())); err != nil {
+			// The stream itself fails on the next message it sends
+			logger.Debug("unable to send pipeline stats, stopping", zap.Error(err))
+			return
+		}
+	}
+}
+
+// startStats sends the stats of the request from their own goroutine, until
+// `StopStats` is called.
+func (p *Pipeline) startStats() {
+	ctx, cancel := context.WithCancel(p.context)
+	p.statsCancel = cancel
+	p.statsDone = make(chan struct{})
+
+	go func() {
+		defer close(p.statsDone)
+		p.stats.run(ctx, p.statsInterval, p.logger)
+	}()
+}
+
+// StopStats stops sending the stats of the request and waits for the
+// goroutine sending them to exit, so that nothing is sent on the stream
+// once the request handler returned. It is a no-op when the stats were never
+// started.
+func (p *Pipeline) StopStats() {
+	if p.statsCancel == nil {
+		return
+	}
+	p.statsCancel()
+	<-p.statsDone
+}
+
+// LogOutputCacheStats logs the use of the output caches by the request once
+// its stream ended, per module, and records the totals on `span`, the span
+// of the request.
+func (p *Pipeline) LogOutputCacheStats(span ttrace.Span) {
+	if p.moduleOutputCache == nil {
+		return
+	}
+
+	stats := p.moduleOutputCache.Stats()
+	total := stats.Total()
+	p.logger.Info("output cache stats", zap.Object("total", total), zap.Object("modules", stats))
+	span.SetAttributes(
+		attribute.Int64("output_cache.hits", int64(total.Hits)),
+		attribute.Int64("output_cache.misses", int64(total.Misses)),
+		attribute.Int64("output_cache.segments_loaded", int64(total.SegmentsLoaded)),
+		attribute.Int64("output_cache.bytes_read", int64(total.BytesRead)),
+		attribute.Int64("output_cache.bytes_written", int64(total.BytesWritten)),
+	)
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPipelineStats_Message(t *testing.T) {
+	stats := &pipelineStats{respFunc: func(resp *pbsubstreams.Response) error { return nil }}
+
+	stats.moduleExecuted("map_a", 2*time.Millisecond, false)
+	stats.moduleExecuted("store_b", 5*time.Millisecond, false)
+	stats.blockProcessed(10)
+	stats.moduleExecuted("map_a", 3*time.Millisecond, true)
+	stats.moduleExecuted("store_b", 1*time.Millisecond, true)
+	stats.blockProcessed(11)
+
+	data := substreams.NewBlockScopedDataResponse(&pbsubstreams.BlockScopedData{Clock: &pbsubstreams.Clock{Id: "11a", Number: 11}})
+	require.NoError(t, stats.send(data))
+
+	expected := &pbsubstreams.PipelineStats{
+		CurrentBlock:    11,
+		BlocksProcessed: 2,
+		CacheHitRatio:   0.5,
+		Modules: []*pbsubstreams.ModuleStats{
+			{Name: "map_a", ExecutionTimeMs: 5},
+			{Name: "store_b", ExecutionTimeMs: 6},
+		},
+		BytesSent: uint64(proto.Size(data)),
+	}
+	assert.True(t, proto.Equal(expected, stats.message()), "got %s", stats.message())
+}
+
+func TestPipelineStats_SentWithoutBlocks(t *testing.T) {
+	received := make(chan *pbsubstreams.PipelineStats, 10)
+	stats := &pipelineStats{respFunc: func(resp *pbsubstreams.Response) error {
+		received <- resp.GetStats()
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stats.run(ctx, time.Millisecond, zap.NewNop())
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			require.NotNil(t, msg)
+			assert.Equal(t, uint64(0), msg.BlocksProcessed)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no stats received")
+		}
+	}
+}
+
+func TestPipeline_StopStats(t *testing.T) {
+	received := make(chan *pbsubstreams.PipelineStats, 100)
+	p := &Pipeline{context: context.Background(), statsInterval: time.Millisecond, logger: zap.NewNop()}
+	p.stats.respFunc = func(resp *pbsubstreams.Response) error {
+		received <- resp.GetStats()
+		return nil
+	}
+
+	p.StopStats() // never started
+
+	p.startStats()
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no stats received")
+	}
+
+	p.StopStats()
+	for len(received) > 0 {
+		<-received
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, received, 0, "stats sent after StopStats returned")
+}
+
+func TestPipeline_LogOutputCacheStats(t *testing.T) {
+	p := &Pipeline{logger: zap.NewNop(), moduleOutputCache: outputs.NewModuleOutputCache(10, zap.NewNop())}
+	for _, name := range []string{"map_a", "map_b"} {
+		_, err := p.moduleOutputCache.RegisterModule(&pbsubstreams.Module{Name: name}, name+"hash", dstore.NewMockStore(nil))
+		require.NoError(t, err)
+		cache := p.moduleOutputCache.OutputCaches[name]
+		_, err = cache.LoadAtBlock(context.Background(), 0)
+		require.NoError(t, err)
+		cache.Get(&pbsubstreams.Clock{Id: "1a", Number: 1})
+	}
+
+	_, span := (&recordingTracer{}).Start(context.Background(), "request")
+	p.LogOutputCacheStats(span)
+
+	attributes := span.(*recordedSpan).attributes
+	assert.Equal(t, int64(0), attributes["output_cache.hits"].AsInt64())
+	assert.Equal(t, int64(2), attributes["output_cache.misses"].AsInt64())
+	assert.Equal(t, int64(0), attributes["output_cache.bytes_read"].AsInt64())
+}
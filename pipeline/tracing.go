@@ -0,0 +1,28 @@
+package pipeline
+
+import (
+	"context"
+
+	ttrace "go.opentelemetry.io/otel/trace"
+)
+
+var noopTracer = ttrace.NewNoopTracerProvider().Tracer("")
+
+// blockTracer returns the tracer used for the spans of block `blockNum`. With
+// a sampling interval of N, only one block out of N is traced, the others
+// produce no span at all.
+func (p *Pipeline) blockTracer(blockNum uint64) ttrace.Tracer {
+	if p.blockTraceSampling > 1 && blockNum%p.blockTraceSampling != 0 {
+		return noopTracer
+	}
+	return p.tracer
+}
+
+// childTracer returns `tracer` when the span carried by `ctx` is recording, so
+// that the spans of the modules are only recorded under a traced block.
+func childTracer(ctx context.Context, tracer ttrace.Tracer) ttrace.Tracer {
+	if !ttrace.SpanFromContext(ctx).IsRecording() {
+		return noopTracer
+	}
+	return tracer
+}
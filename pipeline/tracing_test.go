@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"github.com/streamingfast/substreams/wasm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// recordingTracer keeps the name and attributes of the spans it starts.
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	ttrace.Span
+	name       string
+	attributes map[attribute.Key]attribute.Value
+}
+
+func (t *recordingTracer) Start(ctx context.Context, spanName string, opts ...ttrace.SpanStartOption) (context.Context, ttrace.Span) {
+	_, noopSpan := noopTracer.Start(ctx, spanName)
+	span := &recordedSpan{Span: noopSpan, name: spanName, attributes: map[attribute.Key]attribute.Value{}}
+	config := ttrace.NewSpanStartConfig(opts...)
+	span.SetAttributes(config.Attributes()...)
+	t.spans = append(t.spans, span)
+	return ttrace.ContextWithSpan(ctx, span), span
+}
+
+func (s *recordedSpan) IsRecording() bool { return true }
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		s.attributes[attr.Key] = attr.Value
+	}
+}
+
+func TestPipeline_BlockTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+
+	tests := []struct {
+		sampling     uint64
+		blockNum     uint64
+		expectTraced bool
+	}{
+		{0, 11, true},
+		{1, 11, true},
+		{10, 10, true},
+		{10, 11, false},
+	}
+
+	for _, test := range tests {
+		p := &Pipeline{tracer: tracer, blockTraceSampling: test.sampling}
+		if test.expectTraced {
+			assert.Same(t, tracer, p.blockTracer(test.blockNum), "sampling %d, block %d", test.sampling, test.blockNum)
+		} else {
+			assert.Equal(t, noopTracer, p.blockTracer(test.blockNum), "sampling %d, block %d", test.sampling, test.blockNum)
+		}
+	}
+}
+
+func TestMapperExecutorSpan(t *testing.T) {
+	ctx := context.Background()
+	tracer := &recordingTracer{}
+
+	code, err := wasmtime.Wat2Wasm(echoWAT)
+	require.NoError(t, err)
+	wasmModule, err := wasm.NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_b", "map_echo")
+	require.NoError(t, err)
+
+	cache := outputs.NewOutputCache("map_b", dstore.NewMockStore(nil), 10, zap.NewNop())
+	_, err = cache.LoadAtBlock(ctx, 0)
+	require.NoError(t, err)
+
+	executor := &MapperModuleExecutor{
+		BaseExecutor: BaseExecutor{
+			moduleName: "map_b",
+			wasmModule: wasmModule,
+			wasmInputs: []*wasm.Input{{Type: wasm.InputSource, Name: "map_a"}},
+			cache:      cache,
+			tracer:     tracer,
+		},
+	}
+	clock := &pbsubstreams.Clock{Id: "1a", Number: 1}
+
+	// Not under a traced block
+	require.NoError(t, executor.run(ctx, map[string][]byte{"map_a": []byte("12345678")}, clock, ""))
+	assert.Empty(t, tracer.spans)
+
+	blockCtx, _ := tracer.Start(ctx, "process_block")
+	require.NoError(t, executor.run(blockCtx, map[string][]byte{"map_a": []byte("12345678")}, &pbsubstreams.Clock{Id: "2a", Number: 2}, ""))
+	require.NoError(t, executor.run(blockCtx, map[string][]byte{}, clock, ""))
+
+	require.Len(t, tracer.spans, 3)
+	executed, cacheHit := tracer.spans[1], tracer.spans[2]
+
+	assert.Equal(t, "exec_map", executed.name)
+	assert.Equal(t, "map_b", executed.attributes["module"].AsString())
+	assert.Equal(t, int64(2), executed.attributes["block_num"].AsInt64())
+	assert.False(t, executed.attributes["cache_hit"].AsBool())
+	assert.Equal(t, int64(8), executed.attributes["input_bytes"].AsInt64())
+	assert.Equal(t, int64(8), executed.attributes["output_bytes"].AsInt64())
+
+	assert.Equal(t, int64(1), cacheHit.attributes["block_num"].AsInt64())
+	assert.True(t, cacheHit.attributes["cache_hit"].AsBool())
+	assert.Equal(t, int64(8), cacheHit.attributes["output_bytes"].AsInt64())
+}
@@ -0,0 +1,32 @@
+//! Clock of the block being processed
+//!
+//! The functions of this module read the clock from the host, which is cheaper
+//! than taking the `Clock` as an input of the handler and decoding it.
+//!
+
+use crate::externs;
+use crate::memory;
+
+/// Number of the block being processed
+pub fn number() -> u64 {
+    unsafe { externs::clock_number() }
+}
+
+/// Id of the block being processed
+pub fn id() -> String {
+    unsafe {
+        let output_ptr = memory::alloc(8);
+        externs::clock_id(output_ptr as u32);
+        String::from_utf8_unchecked(memory::get_output_data(output_ptr))
+    }
+}
+
+/// Timestamp of the block being processed
+pub fn timestamp() -> prost_types::Timestamp {
+    let nanos = unsafe { externs::clock_timestamp() };
+
+    prost_types::Timestamp {
+        seconds: nanos.div_euclid(1_000_000_000),
+        nanos: nanos.rem_euclid(1_000_000_000) as i32,
+    }
+}
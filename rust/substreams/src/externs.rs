@@ -1,6 +1,10 @@
 #[link(wasm_import_module = "env")]
 extern "C" {
     pub fn output(ptr: *const u8, len: u32);
+    pub fn skip_block() -> !;
+    pub fn clock_number() -> u64;
+    pub fn clock_id(output_ptr: u32);
+    pub fn clock_timestamp() -> i64;
     pub fn register_panic(
         msg_ptr: *const u8,
         msg_len: u32,
@@ -14,6 +18,9 @@ extern "C" {
 #[link(wasm_import_module = "logger")]
 extern "C" {
     pub fn println(ptr: *const u8, len: usize);
+    pub fn debug(ptr: *const u8, len: usize);
+    pub fn warn(ptr: *const u8, len: usize);
+    pub fn error(ptr: *const u8, len: usize);
 }
 
 pub mod state {
@@ -28,6 +35,8 @@ pub mod state {
             key_len: u32,
             output_ptr: u32,
         ) -> u32;
+        pub fn has_last(store_idx: u32, key_ptr: *const u8, key_len: u32) -> u32;
+        pub fn has_at(store_idx: u32, ord: i64, key_ptr: *const u8, key_len: u32) -> u32;
         pub fn set(
             ord: i64,
             key_ptr: *const u8,
@@ -57,6 +66,13 @@ pub mod state {
             value_ptr: *const u8,
             value_len: u32,
         );
+        pub fn add_bigint_le(
+            ord: i64,
+            key_ptr: *const u8,
+            key_len: u32,
+            value_ptr: *const u8,
+            value_len: u32,
+        );
         pub fn add_int64(
             ord: i64,
             key_ptr: *const u8,
@@ -89,6 +105,13 @@ pub mod state {
             value_ptr: *const u8,
             value_len: u32,
         );
+        pub fn set_min_bigint_le(
+            ord: i64,
+            key_ptr: *const u8,
+            key_len: u32,
+            value_ptr: *const u8,
+            value_len: u32,
+        );
         pub fn set_min_float64(
             ord: i64,
             key_ptr: *const u8,
@@ -115,6 +138,13 @@ pub mod state {
             value_ptr: *const u8,
             value_len: u32,
         );
+        pub fn set_max_bigint_le(
+            ord: i64,
+            key_ptr: *const u8,
+            key_len: u32,
+            value_ptr: *const u8,
+            value_len: u32,
+        );
         pub fn set_max_float64(
             ord: i64,
             key_ptr: *const u8,
@@ -70,6 +70,7 @@
 //!```
 extern crate core;
 
+pub mod clock;
 pub mod errors;
 mod externs;
 pub mod handlers;
@@ -100,6 +101,13 @@ pub fn output_raw(data: Vec<u8>) {
     unsafe { externs::output(data.as_ptr(), data.len() as u32) }
 }
 
+/// Ends the execution of the handler right away, without output, as when the
+/// block is of no interest to the module. Changes made to the output store of a
+/// `store` handler before the call are kept.
+pub fn skip_block() -> ! {
+    unsafe { externs::skip_block() }
+}
+
 /// Registers a Substreams custom panic hook. The panic hook is invoked when then handler panics
 pub fn register_panic_hook() {
     use std::sync::Once;
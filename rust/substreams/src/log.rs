@@ -66,22 +66,92 @@ macro_rules! log_info {
 #[doc(hidden)]
 #[macro_export]
 macro_rules! log_debug {
-    // We have a special case when matching an expression directly to forward directly to `println`. This is to avoid
-    // any allocation and pass directly the literal to `println` which is able to deal with. However, I'm wondering if
+    // We have a special case when matching an expression directly to forward directly to `debugln`. This is to avoid
+    // any allocation and pass directly the literal to `debugln` which is able to deal with. However, I'm wondering if
     // this will cause WTF moment for some cases.
     ($msg:expr) => {
-        $crate::log::println($msg);
+        $crate::log::debugln($msg);
     };
 
     ($($arg:tt)*) => {{
         let message = std::fmt::format(format_args!($($arg)*));
 
-        $crate::log::println(message);
+        $crate::log::debugln(message);
+    }}
+}
+
+/// Logs a message at WARN level on the logger of the current substream using interpolation of
+/// runtime expressions.
+///
+/// The behavior is exactly like [std::format::format!] built-in Rust formatting primitive.
+///
+/// # Panics
+///
+/// `format!` panics if a formatting trait implementation returns an error.
+/// This indicates an incorrect implementation
+/// since `fmt::Write for String` never returns an error itself.
+///
+/// # Examples
+///
+/// ```no_run
+/// use substreams::log;
+///
+/// log::warn!("test");
+/// log::warn!("hello {}", "world!");
+/// log::warn!("x = {}, y = {y}", 10, y = 30);
+/// ```
+#[doc(hidden)]
+#[macro_export]
+macro_rules! log_warn {
+    ($msg:expr) => {
+        $crate::log::warnln($msg);
+    };
+
+    ($($arg:tt)*) => {{
+        let message = std::fmt::format(format_args!($($arg)*));
+
+        $crate::log::warnln(message);
+    }}
+}
+
+/// Logs a message at ERROR level on the logger of the current substream using interpolation of
+/// runtime expressions.
+///
+/// The behavior is exactly like [std::format::format!] built-in Rust formatting primitive.
+///
+/// # Panics
+///
+/// `format!` panics if a formatting trait implementation returns an error.
+/// This indicates an incorrect implementation
+/// since `fmt::Write for String` never returns an error itself.
+///
+/// # Examples
+///
+/// ```no_run
+/// use substreams::log;
+///
+/// log::error!("test");
+/// log::error!("hello {}", "world!");
+/// log::error!("x = {}, y = {y}", 10, y = 30);
+/// ```
+#[doc(hidden)]
+#[macro_export]
+macro_rules! log_error {
+    ($msg:expr) => {
+        $crate::log::errorln($msg);
+    };
+
+    ($($arg:tt)*) => {{
+        let message = std::fmt::format(format_args!($($arg)*));
+
+        $crate::log::errorln(message);
     }}
 }
 
 pub use log_debug as debug;
+pub use log_error as error;
 pub use log_info as info;
+pub use log_warn as warn;
 
 pub fn println<T: AsRef<str>>(msg: T) {
     let reference = msg.as_ref();
@@ -90,3 +160,27 @@ pub fn println<T: AsRef<str>>(msg: T) {
         externs::println(reference.as_ptr(), reference.len());
     }
 }
+
+pub fn debugln<T: AsRef<str>>(msg: T) {
+    let reference = msg.as_ref();
+
+    unsafe {
+        externs::debug(reference.as_ptr(), reference.len());
+    }
+}
+
+pub fn warnln<T: AsRef<str>>(msg: T) {
+    let reference = msg.as_ref();
+
+    unsafe {
+        externs::warn(reference.as_ptr(), reference.len());
+    }
+}
+
+pub fn errorln<T: AsRef<str>>(msg: T) {
+    let reference = msg.as_ref();
+
+    unsafe {
+        externs::error(reference.as_ptr(), reference.len());
+    }
+}
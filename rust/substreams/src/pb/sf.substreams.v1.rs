@@ -6,6 +6,12 @@ pub struct Modules {
     #[prost(message, repeated, tag="2")]
     pub binaries: ::prost::alloc::vec::Vec<Binary>,
 }
+/// BlockIndexKeys is the output of a block index module for a single block.
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct BlockIndexKeys {
+    #[prost(string, repeated, tag="1")]
+    pub keys: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
+}
 /// Binary represents some code compiled to its binary form.
 #[derive(Clone, PartialEq, ::prost::Message)]
 pub struct Binary {
@@ -28,7 +34,16 @@ pub struct Module {
     pub output: ::core::option::Option<module::Output>,
     #[prost(uint64, tag="8")]
     pub initial_block: u64,
-    #[prost(oneof="module::Kind", tags="2, 3")]
+    /// When set, the module is only executed on the blocks matching the filter,
+    /// other blocks get an empty output.
+    #[prost(message, optional, tag="9")]
+    pub block_filter: ::core::option::Option<module::BlockFilter>,
+    /// Value the module reads through the `params` host function, fixed for the
+    /// whole request, ex: the address of a contract to filter on. It is part of
+    /// the module hash.
+    #[prost(string, tag="11")]
+    pub params: ::prost::alloc::string::String,
+    #[prost(oneof="module::Kind", tags="2, 3, 10")]
     pub kind: ::core::option::Option<module::Kind>,
 }
 /// Nested message and enum types in `Module`.
@@ -38,6 +53,22 @@ pub mod module {
         #[prost(string, tag="1")]
         pub output_type: ::prost::alloc::string::String,
     }
+    /// A block index module outputs the `BlockIndexKeys` of each block, which
+    /// other modules can filter blocks on.
+    #[derive(Clone, PartialEq, ::prost::Message)]
+    pub struct KindBlockIndex {
+    }
+    #[derive(Clone, PartialEq, ::prost::Message)]
+    pub struct BlockFilter {
+        /// Name of the block index module consulted, ex: "index_events"
+        #[prost(string, tag="1")]
+        pub module: ::prost::alloc::string::String,
+        /// Keys that must be present in the index of a block, separated by `||`
+        /// when any key must match and by `&&` when all of them must, `&&` binding
+        /// tighter than `||`, ex: "transfer && usdc || approval"
+        #[prost(string, tag="2")]
+        pub query: ::prost::alloc::string::String,
+    }
     #[derive(Clone, PartialEq, ::prost::Message)]
     pub struct KindStore {
         /// The `update_policy` determines the functions available to mutate the store
@@ -130,6 +161,8 @@ pub mod module {
         KindMap(KindMap),
         #[prost(message, tag="3")]
         KindStore(KindStore),
+        #[prost(message, tag="10")]
+        KindBlockIndex(KindBlockIndex),
     }
 }
 #[derive(Clone, PartialEq, ::prost::Message)]
@@ -159,10 +192,96 @@ pub struct Request {
     pub output_modules: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
     #[prost(string, repeated, tag="8")]
     pub initial_store_snapshot_for_modules: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
+    /// DevelopmentMode enables debugging features that are too costly to be
+    /// offered on production streams.
+    #[prost(bool, tag="9")]
+    pub development_mode: bool,
+    /// DebugStoreSnapshot requests the full content of some stores at a given
+    /// block, only honored in development mode.
+    #[prost(message, optional, tag="10")]
+    pub debug_store_snapshot: ::core::option::Option<DebugStoreSnapshotRequest>,
+    /// TruncateOversizedOutputs replaces the output of a module going over the
+    /// server output size limit by an empty one flagged with `output_truncated`,
+    /// instead of failing the stream. Only the outputs no other module of the
+    /// request depends on are truncated, an oversized output consumed by another
+    /// module still fails the stream.
+    #[prost(bool, tag="11")]
+    pub truncate_oversized_outputs: bool,
+    /// QuarantineFailedModules keeps the stream going when a module fails
+    /// deterministically, as long as some requested output modules do not
+    /// depend on it. The failed module and the modules depending on it are not
+    /// executed anymore, and the stream ends in error if any of them is a
+    /// requested output module.
+    #[prost(bool, tag="12")]
+    pub quarantine_failed_modules: bool,
+    /// FinalBlocksOnly delays the stream to finality: only final blocks are
+    /// processed and sent, all of them with the `STEP_IRREVERSIBLE` step, so no
+    /// undo is ever sent. A start cursor must then point to a final block.
+    #[prost(bool, tag="13")]
+    pub final_blocks_only: bool,
+    /// FullKvStoreOutputs lists requested output stores whose output carries
+    /// their full content after each block, sorted by key, instead of their
+    /// deltas. Only available in development mode, the content is capped.
+    #[prost(string, repeated, tag="14")]
+    pub full_kv_store_outputs: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
+    /// PackageName and PackageVersion identify the package of the modules, the
+    /// server records the module hashes used by each package version under them.
+    /// Not recorded when either is empty.
+    #[prost(string, tag="15")]
+    pub package_name: ::prost::alloc::string::String,
+    #[prost(string, tag="16")]
+    pub package_version: ::prost::alloc::string::String,
+    /// MinLogLevel drops the logs of the modules under this level, they don't
+    /// count against the logs limit of the modules either. All the logs are kept
+    /// by default.
+    #[prost(enumeration="LogLevel", tag="17")]
+    pub min_log_level: i32,
+    /// MaxLogByteCount lowers the size of the logs kept for each execution of a
+    /// module, it can't go over the server limit.
+    #[prost(uint64, tag="18")]
+    pub max_log_byte_count: u64,
+    /// InitialBlockOverrides starts modules, by name, at a later block than the
+    /// initial block they declare, ex: to try a module from a recent block
+    /// without changing its package. An override can't be lower than the
+    /// declared initial block of the module or of any of its ancestors. The
+    /// modules overridden and the ones depending on them get other hashes, so
+    /// they don't share caches with the declared initial blocks.
+    #[prost(map="string, uint64", tag="19")]
+    pub initial_block_overrides: ::std::collections::HashMap<::prost::alloc::string::String, u64>,
+    /// Network the modules are made for, usually the one of their package, ex:
+    /// "mainnet". The request is refused by a server of another network, unless
+    /// `allow_network_mismatch` is set. Not checked when empty.
+    #[prost(string, tag="20")]
+    pub network: ::prost::alloc::string::String,
+    /// AllowNetworkMismatch lets the request run on a server of another network
+    /// than `network`, which is only logged.
+    #[prost(bool, tag="21")]
+    pub allow_network_mismatch: bool,
+    /// SubstreamsVersion is the version of the substreams library that built the
+    /// package of the modules, the server logs a warning when it is newer than
+    /// its own. Not checked when empty.
+    #[prost(string, tag="22")]
+    pub substreams_version: ::prost::alloc::string::String,
+}
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct DebugStoreSnapshotRequest {
+    #[prost(string, repeated, tag="1")]
+    pub store_modules: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
+    /// AtBlock is the block after which the stores are sent, the first block
+    /// processed at or after it if it was skipped by the chain.
+    #[prost(uint64, tag="2")]
+    pub at_block: u64,
+    /// KeyPrefix restricts the keys sent to the ones starting with it, when set.
+    #[prost(string, tag="3")]
+    pub key_prefix: ::prost::alloc::string::String,
+    /// MaxKeys lowers the number of keys sent per store, it can't go over the
+    /// server limit.
+    #[prost(uint64, tag="4")]
+    pub max_keys: u64,
 }
 #[derive(Clone, PartialEq, ::prost::Message)]
 pub struct Response {
-    #[prost(oneof="response::Message", tags="1, 2, 3, 4")]
+    #[prost(oneof="response::Message", tags="1, 2, 3, 4, 5, 6, 7, 8, 9")]
     pub message: ::core::option::Option<response::Message>,
 }
 /// Nested message and enum types in `Response`.
@@ -178,6 +297,18 @@ pub mod response {
         SnapshotComplete(super::InitialSnapshotComplete),
         #[prost(message, tag="4")]
         Data(super::BlockScopedData),
+        #[prost(message, tag="5")]
+        UndoSignal(super::BlockUndoSignal),
+        #[prost(message, tag="6")]
+        DebugStoreSnapshot(super::DebugStoreSnapshot),
+        #[prost(message, tag="7")]
+        Stats(super::PipelineStats),
+        #[prost(message, tag="8")]
+        SessionInit(super::SessionInit),
+        /// Sent as soon as a module fails, when the request sets
+        /// `quarantine_failed_modules`.
+        #[prost(message, tag="9")]
+        ModuleFailure(super::ModuleFailure),
     }
 }
 #[derive(Clone, PartialEq, ::prost::Message)]
@@ -196,6 +327,109 @@ pub struct InitialSnapshotData {
     #[prost(uint64, tag="3")]
     pub total_keys: u64,
 }
+/// DebugStoreSnapshot holds a chunk of the content of a store at a given
+/// block, as requested through `Request.debug_store_snapshot`.
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct DebugStoreSnapshot {
+    #[prost(string, tag="1")]
+    pub module_name: ::prost::alloc::string::String,
+    #[prost(message, optional, tag="2")]
+    pub clock: ::core::option::Option<Clock>,
+    #[prost(message, repeated, tag="3")]
+    pub entries: ::prost::alloc::vec::Vec<StoreKeyValue>,
+    #[prost(uint64, tag="4")]
+    pub sent_keys: u64,
+    #[prost(uint64, tag="5")]
+    pub total_keys: u64,
+    /// Truncated is set on the last chunk of a store when its content was cut
+    /// by the keys or bytes limit.
+    #[prost(bool, tag="6")]
+    pub truncated: bool,
+    /// Complete is set on the last chunk sent for the store.
+    #[prost(bool, tag="7")]
+    pub complete: bool,
+}
+/// SessionInit is sent once at the start of the stream, before anything else.
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct SessionInit {
+    /// ModuleExecutionOrder lists the modules executed for each block, in the
+    /// order they are executed.
+    #[prost(string, repeated, tag="1")]
+    pub module_execution_order: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
+    /// InitialBlockOverrides lists the modules started at another block than
+    /// their declared initial block, from `Request.initial_block_overrides`.
+    /// Their outputs and stores, and the ones of the modules depending on them,
+    /// are cached apart from the ones of the declared initial blocks.
+    #[prost(message, repeated, tag="2")]
+    pub initial_block_overrides: ::prost::alloc::vec::Vec<InitialBlockOverride>,
+    /// Network of the server, ex: "mainnet", empty when it doesn't declare one.
+    #[prost(string, tag="3")]
+    pub network: ::prost::alloc::string::String,
+}
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct InitialBlockOverride {
+    #[prost(string, tag="1")]
+    pub module_name: ::prost::alloc::string::String,
+    #[prost(uint64, tag="2")]
+    pub declared_initial_block: u64,
+    #[prost(uint64, tag="3")]
+    pub initial_block: u64,
+}
+/// PipelineStats is sent periodically on the stream, whether blocks flow or
+/// not, so clients can tell a live server from a dead one during long ranges
+/// without any data. Counters are cumulative since the start of the request.
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct PipelineStats {
+    /// CurrentBlock is the last block processed, 0 before the first one.
+    #[prost(uint64, tag="1")]
+    pub current_block: u64,
+    #[prost(uint64, tag="2")]
+    pub blocks_processed: u64,
+    /// CacheHitRatio is the ratio of module executions served from the output
+    /// caches, between 0 and 1.
+    #[prost(double, tag="3")]
+    pub cache_hit_ratio: f64,
+    #[prost(message, repeated, tag="4")]
+    pub modules: ::prost::alloc::vec::Vec<ModuleStats>,
+    /// BytesSent is the size of the messages sent on the stream so far, this
+    /// one excluded.
+    #[prost(uint64, tag="5")]
+    pub bytes_sent: u64,
+}
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct ModuleStats {
+    #[prost(string, tag="1")]
+    pub name: ::prost::alloc::string::String,
+    /// ExecutionTimeMs is the time spent executing the module, outputs served
+    /// from the caches included.
+    #[prost(uint64, tag="2")]
+    pub execution_time_ms: u64,
+}
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct StoreKeyValue {
+    #[prost(string, tag="1")]
+    pub key: ::prost::alloc::string::String,
+    #[prost(bytes="vec", tag="2")]
+    pub value: ::prost::alloc::vec::Vec<u8>,
+}
+/// BlockUndoSignal is sent when a fork is detected and the blocks following
+/// `last_valid_block` must be reverted by the client. Stores on the server side
+/// have already been rewound to `last_valid_block`.
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct BlockUndoSignal {
+    #[prost(message, optional, tag="1")]
+    pub last_valid_block: ::core::option::Option<BlockRef>,
+    /// LastValidCursor can be used to resume the stream right after `last_valid_block`.
+    #[prost(string, tag="2")]
+    pub last_valid_cursor: ::prost::alloc::string::String,
+}
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct BlockRef {
+    #[prost(string, tag="1")]
+    pub id: ::prost::alloc::string::String,
+    #[prost(uint64, tag="2")]
+    pub number: u64,
+}
 #[derive(Clone, PartialEq, ::prost::Message)]
 pub struct BlockScopedData {
     #[prost(message, repeated, tag="1")]
@@ -214,10 +448,25 @@ pub struct ModuleOutput {
     #[prost(string, repeated, tag="4")]
     pub logs: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
     /// LogsTruncated is a flag that tells you if you received all the logs or if they
-    /// were truncated because you logged too much (fixed limit currently is set to 128 KiB).
+    /// were truncated because you logged too much (128 KiB by default, see
+    /// `Request.max_log_byte_count`).
     #[prost(bool, tag="5")]
     pub logs_truncated: bool,
-    #[prost(oneof="module_output::Data", tags="2, 3")]
+    /// OutputTruncated is set when the output of the module went over the server
+    /// output size limit and was replaced by an empty one, see
+    /// `Request.truncate_oversized_outputs`.
+    #[prost(bool, tag="6")]
+    pub output_truncated: bool,
+    /// LogLevels holds the level of each of the logs, in the same order.
+    #[prost(enumeration="LogLevel", repeated, tag="9")]
+    pub log_levels: ::prost::alloc::vec::Vec<i32>,
+    /// DroppedLogs and DroppedLogsBytes count the logs dropped when the logs were
+    /// truncated.
+    #[prost(uint64, tag="10")]
+    pub dropped_logs: u64,
+    #[prost(uint64, tag="11")]
+    pub dropped_logs_bytes: u64,
+    #[prost(oneof="module_output::Data", tags="2, 3, 8")]
     pub data: ::core::option::Option<module_output::Data>,
 }
 /// Nested message and enum types in `ModuleOutput`.
@@ -228,8 +477,53 @@ pub mod module_output {
         MapOutput(::prost_types::Any),
         #[prost(message, tag="3")]
         StoreDeltas(super::StoreDeltas),
+        #[prost(message, tag="8")]
+        StoreFullKv(super::StoreFullKv),
     }
 }
+/// StoreFullKV is the content of a store after a block, see
+/// `Request.full_kv_store_outputs`.
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct StoreFullKv {
+    #[prost(message, repeated, tag="1")]
+    pub entries: ::prost::alloc::vec::Vec<StoreKeyValue>,
+}
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct ModuleFailure {
+    /// Reason is the error of the module, including its execution stack.
+    #[prost(string, tag="1")]
+    pub reason: ::prost::alloc::string::String,
+    /// QuarantinedModules lists the failed module and the modules depending on
+    /// it, none of them is executed anymore.
+    #[prost(string, repeated, tag="2")]
+    pub quarantined_modules: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
+    #[prost(string, tag="3")]
+    pub module_name: ::prost::alloc::string::String,
+    /// Clock of the block the module failed on.
+    #[prost(message, optional, tag="4")]
+    pub clock: ::core::option::Option<Clock>,
+}
+/// PanicDetail is attached to the details of the gRPC status of a stream
+/// failing because a module panicked.
+#[derive(Clone, PartialEq, ::prost::Message)]
+pub struct PanicDetail {
+    #[prost(string, tag="1")]
+    pub module: ::prost::alloc::string::String,
+    #[prost(uint64, tag="2")]
+    pub block_num: u64,
+    #[prost(string, tag="3")]
+    pub message: ::prost::alloc::string::String,
+    /// Filename, LineNumber and ColumnNumber locate the panic in the code of the
+    /// module, they are empty when the module did not report them.
+    #[prost(string, tag="4")]
+    pub filename: ::prost::alloc::string::String,
+    #[prost(uint32, tag="5")]
+    pub line_number: u32,
+    #[prost(uint32, tag="6")]
+    pub column_number: u32,
+    #[prost(string, repeated, tag="7")]
+    pub execution_stack: ::prost::alloc::vec::Vec<::prost::alloc::string::String>,
+}
 #[derive(Clone, PartialEq, ::prost::Message)]
 pub struct ModulesProgress {
     #[prost(message, repeated, tag="1")]
@@ -333,6 +627,15 @@ pub struct Output {
 }
 #[derive(Clone, Copy, Debug, PartialEq, Eq, Hash, PartialOrd, Ord, ::prost::Enumeration)]
 #[repr(i32)]
+pub enum LogLevel {
+    Debug = 0,
+    /// Level of the logs of the `println` import, which has no level
+    Info = 1,
+    Warn = 2,
+    Error = 3,
+}
+#[derive(Clone, Copy, Debug, PartialEq, Eq, Hash, PartialOrd, Ord, ::prost::Enumeration)]
+#[repr(i32)]
 pub enum ForkStep {
     StepUnknown = 0,
     /// Block is new head block of the chain, that is linear with the previous block
@@ -63,6 +63,16 @@ pub fn get_first<K: AsRef<str>>(store_idx: u32, key: K) -> Option<Vec<u8>> {
         };
     }
 }
+pub fn has_at<K: AsRef<str>>(store_idx: u32, ord: i64, key: K) -> bool {
+    let key_bytes = key.as_ref().as_bytes();
+    unsafe {
+        externs::state::has_at(store_idx, ord, key_bytes.as_ptr(), key_bytes.len() as u32) == 1
+    }
+}
+pub fn has_last<K: AsRef<str>>(store_idx: u32, key: K) -> bool {
+    let key_bytes = key.as_ref().as_bytes();
+    unsafe { externs::state::has_last(store_idx, key_bytes.as_ptr(), key_bytes.len() as u32) == 1 }
+}
 pub fn set<K: AsRef<str>>(ord: i64, key: K, value: &Vec<u8>) {
     let key = key.as_ref();
 
@@ -124,6 +134,25 @@ pub fn add_bigint<K: AsRef<str>>(ord: i64, key: K, value: &BigInt)  {
         )
     }
 }
+
+/// Same as `add_bigint`, passing the value in its two's complement little endian
+/// form instead of its decimal one, cheaper to produce. Requires a server
+/// providing the `add_bigint_le` import.
+pub fn add_bigint_le<K: AsRef<str>>(ord: i64, key: K, value: &BigInt) {
+    let key = key.as_ref();
+    let data = value.to_signed_bytes_le();
+
+    unsafe {
+        externs::state::add_bigint_le(
+            ord,
+            key.as_ptr(),
+            key.len() as u32,
+            data.as_ptr(),
+            data.len() as u32,
+        )
+    }
+}
+
 pub fn add_int64<K: AsRef<str>>(ord: i64, key: K, value: i64) {
     let key = key.as_ref();
 
@@ -172,6 +201,24 @@ pub fn set_min_bigint<K: AsRef<str>>(ord: i64, key: K, value: &BigInt) {
     }
 }
 
+/// Same as `set_min_bigint`, passing the value in its two's complement little endian
+/// form instead of its decimal one, cheaper to produce. Requires a server
+/// providing the `set_min_bigint_le` import.
+pub fn set_min_bigint_le<K: AsRef<str>>(ord: i64, key: K, value: &BigInt) {
+    let key = key.as_ref();
+    let data = value.to_signed_bytes_le();
+
+    unsafe {
+        externs::state::set_min_bigint_le(
+            ord,
+            key.as_ptr(),
+            key.len() as u32,
+            data.as_ptr(),
+            data.len() as u32,
+        )
+    }
+}
+
 pub fn set_min_float64<K: AsRef<str>>(ord: i64, key: K, value: f64) {
     let key = key.as_ref();
 
@@ -214,6 +261,24 @@ pub fn set_max_bigint<K: AsRef<str>>(ord: i64, key: K, value: &BigInt) {
     }
 }
 
+/// Same as `set_max_bigint`, passing the value in its two's complement little endian
+/// form instead of its decimal one, cheaper to produce. Requires a server
+/// providing the `set_max_bigint_le` import.
+pub fn set_max_bigint_le<K: AsRef<str>>(ord: i64, key: K, value: &BigInt) {
+    let key = key.as_ref();
+    let data = value.to_signed_bytes_le();
+
+    unsafe {
+        externs::state::set_max_bigint_le(
+            ord,
+            key.as_ptr(),
+            key.len() as u32,
+            data.as_ptr(),
+            data.len() as u32,
+        )
+    }
+}
+
 pub fn set_max_float64<K: AsRef<str>>(ord: i64, key: K, value: f64) {
     let key = key.as_ref();
 
@@ -284,4 +284,16 @@ impl StoreGet {
     pub fn get_first<K: AsRef<str>>(&self, key: K) -> Option<Vec<u8>> {
         return state::get_first(self.idx, key);
     }
+
+    /// Tells if a key is in the store at the given ordinal, like `get_at`,
+    /// without copying its value to the module memory.
+    pub fn has_at<K: AsRef<str>>(&self, ord: u64, key: K) -> bool {
+        return state::has_at(self.idx, ord as i64, key);
+    }
+
+    /// Tells if a key is in the store, like `get_last`, without copying its
+    /// value to the module memory.
+    pub fn has_last<K: AsRef<str>>(&self, key: K) -> bool {
+        return state::has_last(self.idx, key);
+    }
 }
@@ -269,7 +269,7 @@ func TestRustScript(t *testing.T) {
 			instance, err := module.NewInstance(&pbsubstreams.Clock{}, nil)
 			require.NoError(t, err)
 			instance.SetOutputStore(c.builder)
-			err = instance.Execute()
+			err = instance.Execute(context.Background())
 			require.NoError(t, err)
 			c.assert(t, module, instance, c.builder)
 		})
@@ -292,7 +292,7 @@ func Test_Recursion(t *testing.T) {
 
 	instance, err := module.NewInstance(&pbsubstreams.Clock{}, nil)
 	require.NoError(t, err)
-	err = instance.ExecuteWithArgs(9000)
+	err = instance.ExecuteWithArgs(context.Background(), 9000)
 	//err = instance.ExecuteWithArgs(3)
 	require.NoError(t, err)
 
@@ -49,7 +49,7 @@ func TestExtensionCalls(t *testing.T) {
 			instance, err := module.NewInstance(&pbsubstreams.Clock{}, nil)
 			require.NoError(t, err)
 
-			err = instance.Execute()
+			err = instance.Execute(context.Background())
 			if c.expectError != nil {
 				assert.Equal(t, c.expectError.Error(), err.Error())
 			} else {
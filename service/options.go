@@ -1,6 +1,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/streamingfast/substreams/pipeline"
 	"github.com/streamingfast/substreams/wasm"
 )
@@ -36,3 +38,195 @@ func WithOutCacheSaveInterval(block uint64) Option {
 		s.outputCacheSaveBlockInterval = block
 	}
 }
+
+// WithBlockPrefetchDepth sets the number of blocks read ahead from the block
+// source while the current one is executed, 0 disables the read ahead.
+func WithBlockPrefetchDepth(depth int) Option {
+	return func(s *Service) {
+		s.blockPrefetchDepth = &depth
+	}
+}
+
+// WithBlockTraceSampling only traces one block out of every `everyNBlocks`
+// blocks processed by a request, 0 or 1 traces every block.
+func WithBlockTraceSampling(everyNBlocks uint64) Option {
+	return func(s *Service) {
+		s.blockTraceSampling = everyNBlocks
+	}
+}
+
+// WithOutputCacheCompressionLevel sets the zstd level the output cache files
+// are written with, 0 writing them uncompressed.
+func WithOutputCacheCompressionLevel(level int) Option {
+	return func(s *Service) {
+		s.outputCacheCompressionLevel = &level
+	}
+}
+
+// WithOutputCacheMaxPendingWrites sets the number of segments of a module
+// written in the background before the pipeline waits for them, 0 writing
+// them synchronously.
+func WithOutputCacheMaxPendingWrites(maxPending int) Option {
+	return func(s *Service) {
+		s.outputCacheMaxPendingWrites = &maxPending
+	}
+}
+
+// WithOutputCacheWriteTimeout bounds each attempt at writing an output cache
+// segment, a stalled attempt being retried, 0 leaving the attempts
+// unbounded.
+func WithOutputCacheWriteTimeout(timeout time.Duration) Option {
+	return func(s *Service) {
+		s.outputCacheWriteTimeout = &timeout
+	}
+}
+
+// WithOutputCacheTempObjectsMaxAge sets the age past which the temporary
+// objects left behind by interrupted output cache writes are deleted, 0
+// keeping them.
+func WithOutputCacheTempObjectsMaxAge(maxAge time.Duration) Option {
+	return func(s *Service) {
+		s.outputCacheTempObjectsMaxAge = &maxAge
+	}
+}
+
+// WithOutputCacheLocalDir keeps local copies of the output cache files read
+// in `dir`, up to `maxBytes`, the least recently used being evicted past it.
+// The directory can be shared with other processes.
+func WithOutputCacheLocalDir(dir string, maxBytes int64) Option {
+	return func(s *Service) {
+		s.outputCacheLocalDir = dir
+		s.outputCacheLocalMaxBytes = maxBytes
+	}
+}
+
+// WithOutputCacheMemorySize sets the memory in bytes taken at most by the
+// output cache segments kept for the requests of the process, 0 loading them
+// from the store every time.
+func WithOutputCacheMemorySize(maxBytes int64) Option {
+	return func(s *Service) {
+		s.outputCacheMemorySize = &maxBytes
+	}
+}
+
+// WithMaxModuleOutputSize sets the maximum size in bytes of the output of a
+// mapper for a single block, 0 disables the limit.
+func WithMaxModuleOutputSize(maxSize uint64) Option {
+	return func(s *Service) {
+		s.maxModuleOutputSize = &maxSize
+	}
+}
+
+// WithMaxWasmMemorySize sets the maximum size in bytes of the linear memory
+// of the wasm instance of a module, 0 disables the limit.
+func WithMaxWasmMemorySize(maxSize uint64) Option {
+	return func(s *Service) {
+		s.maxWasmMemorySize = &maxSize
+	}
+}
+
+// WithWasmCompilationCacheDir keeps the compiled code of the wasm modules in
+// `dir`, so a restarted process does not compile them again. The directory
+// can be shared with other processes.
+func WithWasmCompilationCacheDir(dir string) Option {
+	return func(s *Service) {
+		s.wasmCompilationCacheDir = dir
+	}
+}
+
+// WithMaxLogByteCount sets the size of the logs kept for each execution of a
+// module, capped to wasm.MaxLogByteCountCeiling. Requests can lower it.
+func WithMaxLogByteCount(maxByteCount uint64) Option {
+	return func(s *Service) {
+		s.maxLogByteCount = maxByteCount
+	}
+}
+
+// WithModuleOutCacheSaveInterval overrides the number of blocks of the
+// output cache files of the module named `moduleName`, 0 keeps the one of
+// WithOutCacheSaveInterval. Files written with another interval keep being
+// read.
+func WithModuleOutCacheSaveInterval(moduleName string, block uint64) Option {
+	return func(s *Service) {
+		if s.moduleOutCacheSaveIntervals == nil {
+			s.moduleOutCacheSaveIntervals = map[string]uint64{}
+		}
+		s.moduleOutCacheSaveIntervals[moduleName] = block
+	}
+}
+
+// WithStoreReadCache remembers the values read from the input stores during
+// each execution of a module, see wasm.WithStoreReadCache.
+func WithStoreReadCache() Option {
+	return func(s *Service) {
+		s.storeReadCache = true
+	}
+}
+
+// WithModuleMaxWasmMemorySize overrides the maximum size in bytes of the
+// linear memory of the module named `moduleName`, 0 disables the limit for it.
+func WithModuleMaxWasmMemorySize(moduleName string, maxSize uint64) Option {
+	return func(s *Service) {
+		if s.moduleMaxWasmMemorySizes == nil {
+			s.moduleMaxWasmMemorySizes = map[string]uint64{}
+		}
+		s.moduleMaxWasmMemorySizes[moduleName] = maxSize
+	}
+}
+
+// WithInitialWasmMemorySize sets the initial size in bytes of the linear
+// memory of the wasm instance of a module, so modules known to need a lot of
+// memory do not grow it page after page. 0 keeps the size declared by the
+// code of the module. It must not exceed the maximum size of the module.
+func WithInitialWasmMemorySize(initialSize uint64) Option {
+	return func(s *Service) {
+		s.initialWasmMemorySize = initialSize
+	}
+}
+
+// WithModuleInitialWasmMemorySize overrides the initial size in bytes of the
+// linear memory of the module named `moduleName`.
+func WithModuleInitialWasmMemorySize(moduleName string, initialSize uint64) Option {
+	return func(s *Service) {
+		if s.moduleInitialWasmMemorySizes == nil {
+			s.moduleInitialWasmMemorySizes = map[string]uint64{}
+		}
+		s.moduleInitialWasmMemorySizes[moduleName] = initialSize
+	}
+}
+
+// WithWasmFuelLimit sets the fuel available to each execution of a module,
+// roughly the number of wasm instructions it can execute, 0 disables the
+// limit.
+func WithWasmFuelLimit(limit uint64) Option {
+	return func(s *Service) {
+		s.wasmFuelLimit = &limit
+	}
+}
+
+// WithModuleWasmFuelLimit overrides the fuel limit of the module named
+// `moduleName`, 0 disables the limit for it.
+func WithModuleWasmFuelLimit(moduleName string, limit uint64) Option {
+	return func(s *Service) {
+		if s.moduleWasmFuelLimits == nil {
+			s.moduleWasmFuelLimits = map[string]uint64{}
+		}
+		s.moduleWasmFuelLimits[moduleName] = limit
+	}
+}
+
+// WithNetwork sets the network the server serves, ex: "mainnet". Requests
+// made for another network are refused, unless they allow it.
+func WithNetwork(network string) Option {
+	return func(s *Service) {
+		s.network = network
+	}
+}
+
+// WithStatsInterval sets the wall clock time between two stats messages sent
+// on the stream of a request, 0 disables them.
+func WithStatsInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.statsInterval = &interval
+	}
+}
@@ -7,14 +7,15 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/streamingfast/bstream"
 	"github.com/streamingfast/bstream/stream"
 	"github.com/streamingfast/dstore"
 	"github.com/streamingfast/firehose"
 	firehoseServer "github.com/streamingfast/firehose/server"
 	"github.com/streamingfast/logging"
 	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
-	"github.com/streamingfast/substreams"
 	"github.com/streamingfast/substreams/client"
 	"github.com/streamingfast/substreams/manifest"
 	"github.com/streamingfast/substreams/orchestrator"
@@ -27,14 +28,17 @@ import (
 	otelcode "go.opentelemetry.io/otel/codes"
 	ttrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/types/known/anypb"
 )
 
+// pipelineShutdownTimeout bounds the time spent saving the work of an
+// interrupted pipeline.
+const pipelineShutdownTimeout = 30 * time.Second
+
 type Service struct {
 	baseStateStore     dstore.Store
 	blockType          string // NOTE: can't that be extracted from the actual block messages? with some proto machinery? Was probably useful when `sf.ethereum.codec.v1.Block` didn't correspond to the `sf.ethereum.type.v1.Block` target type.. but that's not true anymore.
@@ -44,7 +48,34 @@ type Service struct {
 	pipelineOptions []pipeline.PipelineOptioner
 
 	storesSaveInterval           uint64
+	maxModuleOutputSize          *uint64
+	maxWasmMemorySize            *uint64
+	moduleMaxWasmMemorySizes     map[string]uint64
+	initialWasmMemorySize        uint64
+	moduleInitialWasmMemorySizes map[string]uint64
+	wasmFuelLimit                *uint64
+	moduleWasmFuelLimits         map[string]uint64
+	statsInterval                *time.Duration
+	blockTraceSampling           uint64
 	outputCacheSaveBlockInterval uint64
+	moduleOutCacheSaveIntervals  map[string]uint64
+	outputCacheCompressionLevel  *int
+	outputCacheMaxPendingWrites  *int
+	outputCacheWriteTimeout      *time.Duration
+	outputCacheTempObjectsMaxAge *time.Duration
+	outputCacheMemorySize        *int64
+	outputSegmentCache           *outputs.SegmentCache // shared by all the requests, nil when disabled
+	outputCacheLocalDir          string
+	outputCacheLocalMaxBytes     int64
+	outputLocalCache             *outputs.LocalCache         // shared by all the requests, nil when disabled
+	moduleHashMarkers            *pipeline.ModuleHashMarkers // shared by all the requests
+	blockPrefetchDepth           *int
+	network                      string
+
+	wasmCompilationCacheDir string
+	maxLogByteCount         uint64           // 0 keeps wasm.DefaultMaxLogByteCount
+	wasmModulePool          *wasm.ModulePool // shared by all the requests
+	storeReadCache          bool
 
 	firehoseServer *firehoseServer.Server
 	streamFactory  *firehose.StreamFactory
@@ -94,11 +125,45 @@ func New(
 	}
 
 	s.workerPool = orchestrator.NewWorkerPool(parallelSubRequests, grpcClient, grpcCallOpts)
+	s.moduleHashMarkers = pipeline.NewModuleHashMarkers(stateStore)
+	zlog.Info("module hash changes are only detected for requests giving a package name and version, detection is disabled for the others")
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	var poolOpts []wasm.PoolOption
+	if s.wasmCompilationCacheDir != "" {
+		cache, err := wasm.NewCompilationCache(s.wasmCompilationCacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("wasm compilation cache: %w", err)
+		}
+		poolOpts = append(poolOpts, wasm.WithCompilationCache(cache))
+	}
+	var runtimeOpts []wasm.RuntimeOption
+	if s.maxLogByteCount != 0 {
+		runtimeOpts = append(runtimeOpts, wasm.WithMaxLogByteCount(s.maxLogByteCount))
+	}
+	if s.storeReadCache {
+		runtimeOpts = append(runtimeOpts, wasm.WithStoreReadCache())
+	}
+	s.wasmModulePool = wasm.NewModulePool(wasm.NewRuntime(s.wasmExtensions, runtimeOpts...), wasm.DefaultMaxIdleInstances, poolOpts...)
+
+	outputCacheMemorySize := int64(outputs.DefaultSegmentCacheMaxBytes)
+	if s.outputCacheMemorySize != nil {
+		outputCacheMemorySize = *s.outputCacheMemorySize
+	}
+	if outputCacheMemorySize > 0 {
+		s.outputSegmentCache = outputs.NewSegmentCache(outputCacheMemorySize)
+	}
+	if s.outputCacheLocalDir != "" {
+		localCache, err := outputs.NewLocalCache(s.outputCacheLocalDir, s.outputCacheLocalMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("output cache local copies: %w", err)
+		}
+		s.outputLocalCache = localCache
+	}
+
 	return s, nil
 }
 
@@ -145,7 +210,7 @@ func (s *Service) Blocks(request *pbsubstreams.Request, streamSrv pbsubstreams.S
 	}
 
 	if err := manifest.ValidateModules(request.Modules); err != nil {
-		err := status.Error(codes.InvalidArgument, fmt.Sprintf("modules validation failed: %s", err))
+		err := validationStatus(err)
 		span.SetStatus(otelcode.Error, err.Error())
 		return err
 	}
@@ -176,7 +241,7 @@ func (s *Service) Blocks(request *pbsubstreams.Request, streamSrv pbsubstreams.S
 	// payload, we'd send the increment in EgressBytes sent.  We'll
 	// want to review that anyway.
 
-	var opts []pipeline.Option
+	opts := []pipeline.Option{pipeline.WithWasmModulePool(s.wasmModulePool), pipeline.WithOutputSegmentCache(s.outputSegmentCache), pipeline.WithOutputCacheLocalCache(s.outputLocalCache), pipeline.WithModuleHashMarkers(s.moduleHashMarkers)}
 	for _, pipeOpts := range s.pipelineOptions {
 		for _, opt := range pipeOpts.PipelineOptions(ctx, request) {
 			opts = append(opts, opt)
@@ -205,59 +270,101 @@ func (s *Service) Blocks(request *pbsubstreams.Request, streamSrv pbsubstreams.S
 	}
 	span.SetAttributes(attribute.Bool("sub_request", isSubrequest))
 
-	if s.storesSaveInterval != 0 {
-		opts = append(opts, pipeline.WithStoresSaveInterval(s.storesSaveInterval))
-	}
-
-	responseHandler := func(resp *pbsubstreams.Response) error {
-		if err := streamSrv.Send(resp); err != nil {
+	if !isSubrequest {
+		if err := s.checkNetwork(request, logger); err != nil {
+			err := status.Error(codes.InvalidArgument, err.Error())
 			span.SetStatus(otelcode.Error, err.Error())
-			return NewErrSendBlock(err)
+			return err
 		}
-		return nil
+		s.checkSubstreamsVersion(request, logger)
 	}
 
-	// TODO: check p.cacheEnabled here also if we make this condition back to true
-	if false && !isSubrequest && len(request.OutputModules) == 1 && len(request.InitialStoreSnapshotForModules) == 0 {
-		moduleName := request.OutputModules[0]
-		module, err := graph.Module(moduleName)
-
-		zlog.Info("try to send module output from cached files", zap.String("module_name", moduleName))
+	if request.StartCursor != "" {
+		startCursor, err := pipeline.ParseStartCursor(request)
 		if err != nil {
+			err := status.Error(codes.InvalidArgument, err.Error())
 			span.SetStatus(otelcode.Error, err.Error())
-			return fmt.Errorf("getting module %q from graph: %w", moduleName, err)
+			return err
 		}
+		logger.Info("resuming from start cursor", zap.Stringer("cursor", startCursor))
+		opts = append(opts, pipeline.WithStartCursor(startCursor))
+	}
 
-		hash := manifest.HashModuleAsString(request.Modules, graph, module)
-		moduleCacheStore, err := s.baseStateStore.SubStore(fmt.Sprintf("%s/outputs", hash))
-		moduleOutputCache := outputs.NewOutputCache(moduleName, moduleCacheStore, s.outputCacheSaveBlockInterval, zlog)
+	if s.storesSaveInterval != 0 {
+		opts = append(opts, pipeline.WithStoresSaveInterval(s.storesSaveInterval))
+	}
 
-		lastBlockSent, err := sendCachedModuleOutput(ctx, uint64(request.StartBlockNum), request.StopBlockNum, module, moduleOutputCache, responseHandler)
-		if err != nil {
-			fmt.Println("sending cached module output: %w", err)
-		}
+	for moduleName, interval := range s.moduleOutCacheSaveIntervals {
+		opts = append(opts, pipeline.WithModuleOutputCacheSaveInterval(moduleName, interval))
+	}
 
-		if lastBlockSent != nil && *lastBlockSent >= request.StopBlockNum {
-			zlog.Info("sent full requested data from cached output", zap.String("module_name", moduleName), zap.Uint64("last_block_sent", *lastBlockSent))
-			span.SetStatus(otelcode.Ok, "")
-			return nil // all done
-		}
+	if s.outputCacheMaxPendingWrites != nil {
+		opts = append(opts, pipeline.WithOutputCacheMaxPendingWrites(*s.outputCacheMaxPendingWrites))
+	}
 
-		if lastBlockSent != nil {
-			zlog.Info("sent cached data", zap.String("module_name", moduleName), zap.Uint64("last_block_sent", *lastBlockSent))
-			// FIXME(abourget): +1 is always smelly, why wouldn't `sendCachedModuleOutput` return a cursor?
-			request.StartBlockNum = int64(*lastBlockSent + 1)
-		}
+	if s.outputCacheWriteTimeout != nil {
+		opts = append(opts, pipeline.WithOutputCacheWriteTimeout(*s.outputCacheWriteTimeout))
+	}
+
+	if s.outputCacheTempObjectsMaxAge != nil {
+		opts = append(opts, pipeline.WithOutputCacheTempObjectsMaxAge(*s.outputCacheTempObjectsMaxAge))
+	}
+
+	if s.outputCacheCompressionLevel != nil {
+		opts = append(opts, pipeline.WithOutputCacheCompressionLevel(*s.outputCacheCompressionLevel))
+	}
+
+	if s.maxModuleOutputSize != nil {
+		opts = append(opts, pipeline.WithMaxModuleOutputSize(*s.maxModuleOutputSize))
+	}
+
+	if s.maxWasmMemorySize != nil {
+		opts = append(opts, pipeline.WithMaxWasmMemorySize(*s.maxWasmMemorySize))
+	}
+	for moduleName, maxSize := range s.moduleMaxWasmMemorySizes {
+		opts = append(opts, pipeline.WithModuleMaxWasmMemorySize(moduleName, maxSize))
+	}
+	if s.initialWasmMemorySize != 0 {
+		opts = append(opts, pipeline.WithInitialWasmMemorySize(s.initialWasmMemorySize))
+	}
+	for moduleName, initialSize := range s.moduleInitialWasmMemorySizes {
+		opts = append(opts, pipeline.WithModuleInitialWasmMemorySize(moduleName, initialSize))
+	}
+	if s.wasmFuelLimit != nil {
+		opts = append(opts, pipeline.WithWasmFuelLimit(*s.wasmFuelLimit))
+	}
+	for moduleName, limit := range s.moduleWasmFuelLimits {
+		opts = append(opts, pipeline.WithModuleWasmFuelLimit(moduleName, limit))
+	}
 
+	if s.statsInterval != nil {
+		opts = append(opts, pipeline.WithStatsInterval(*s.statsInterval))
 	}
+
+	if s.network != "" {
+		opts = append(opts, pipeline.WithNetwork(s.network))
+	}
+
+	if s.blockTraceSampling > 1 {
+		opts = append(opts, pipeline.WithBlockTraceSampling(s.blockTraceSampling))
+	}
+
+	responseHandler := func(resp *pbsubstreams.Response) error {
+		if err := streamSrv.Send(resp); err != nil {
+			span.SetStatus(otelcode.Error, err.Error())
+			return NewErrSendBlock(err)
+		}
+		return nil
+	}
+
 	pipeTracer := otel.GetTracerProvider().Tracer("pipeline")
 	pipe := pipeline.New(ctx, pipeTracer, request, graph, s.blockType, s.baseStateStore, s.outputCacheSaveBlockInterval, s.wasmExtensions, s.blockRangeSizeSubRequests, responseHandler, opts...)
+	defer pipe.StopStats()
 
 	firehoseReq := &pbfirehose.Request{
 		StartBlockNum:   request.StartBlockNum,
 		StopBlockNum:    request.StopBlockNum,
-		Cursor:          request.StartCursor,
-		FinalBlocksOnly: false,
+		FinalBlocksOnly: request.FinalBlocksOnly,
 		// FIXME(abourget), right now, the pbsubstreams.Request has a
 		// ForkSteps that we IGNORE. Eventually, we will want to honor
 		// it, but ONLY when we are certain that our Pipeline supports
@@ -270,133 +377,195 @@ func (s *Service) Blocks(request *pbsubstreams.Request, streamSrv pbsubstreams.S
 		span.SetStatus(otelcode.Error, err.Error())
 		return fmt.Errorf("error building pipeline: %w", err)
 	}
+	defer pipe.LogOutputCacheStats(span)
+
+	if request.StartCursor != "" {
+		// The start cursor is not handed to the block source: the pipeline
+		// replays the blocks following the last final block of the cursor to
+		// rebuild the stores, and detects itself whether the cursor block
+		// was forked out in the meantime.
+		firehoseReq.StartBlockNum = int64(pipe.StartBlockNum())
+	}
+
+	lastCursor, err := pipe.ProcessCachedBlocks()
+	if err != nil {
+		s.shutdownInterruptedPipeline(ctx, pipe, logger)
+		return s.streamTerminationError(err, pipe, streamSrv, span, logger)
+	}
+	if lastCursor != nil {
+		zlog.Info("resuming block source after blocks served from output caches", zap.Stringer("cursor", lastCursor))
+		if request.FinalBlocksOnly {
+			// Cached blocks are all final, but their cursors were recorded
+			// when they were first processed and might not be on a final
+			// block, which the block source refuses in final blocks only mode.
+			firehoseReq.StartBlockNum = int64(lastCursor.Block.Num() + 1)
+		} else {
+			firehoseReq.Cursor = lastCursor.ToOpaque()
+		}
+	}
 
 	zlog.Info("creating firehose stream",
 		zap.Int64("start_block", firehoseReq.StartBlockNum),
 		zap.Uint64("end_block", firehoseReq.StopBlockNum),
 	)
-	blockStream, err := s.streamFactory.New(ctx, pipe, firehoseReq, false, zap.NewNop())
+	prefetchDepth := pipeline.DefaultBlockPrefetchDepth
+	if s.blockPrefetchDepth != nil {
+		prefetchDepth = *s.blockPrefetchDepth
+	}
+
+	var handler bstream.Handler = pipe
+	streamCtx := ctx
+	var prefetcher *pipeline.BlockPrefetcher
+	if prefetchDepth > 0 {
+		prefetcher, streamCtx = pipeline.NewBlockPrefetcher(ctx, pipe, prefetchDepth)
+		handler = prefetcher
+	}
+
+	blockStream, err := s.streamFactory.New(streamCtx, handler, firehoseReq, false, zap.NewNop())
 	if err != nil {
+		if prefetcher != nil {
+			prefetcher.Wait(err)
+		}
 		span.SetStatus(otelcode.Error, err.Error())
 		return fmt.Errorf("error getting stream: %w", err)
 	}
-	if err := blockStream.Run(ctx); err != nil {
-		if errors.Is(err, io.EOF) {
-			var d []string
-			for _, rng := range pipe.PartialsWritten() {
-				d = append(d, fmt.Sprintf("%d-%d", rng.StartBlock, rng.ExclusiveEndBlock))
-			}
-			partialsWritten := []string{strings.Join(d, ",")}
-			zlog.Info("setting trailer", zap.Strings("ranges", partialsWritten))
-			streamSrv.SetTrailer(metadata.MD{"substreams-partials-written": partialsWritten})
-			span.SetStatus(otelcode.Ok, "")
-			return nil
-		}
+	err = blockStream.Run(streamCtx)
+	if prefetcher != nil {
+		err = prefetcher.Wait(err)
+	}
+	if err != nil {
+		s.shutdownInterruptedPipeline(ctx, pipe, logger)
+		return s.streamTerminationError(err, pipe, streamSrv, span, logger)
+	}
+	span.SetStatus(otelcode.Ok, "")
+	return nil
+}
 
-		if errors.Is(err, stream.ErrStopBlockReached) {
-			logger.Info("stream of blocks reached end block")
-			span.SetStatus(otelcode.Ok, "")
-			return nil
-		}
+// shutdownInterruptedPipeline saves the work of the pipeline done since its
+// last save boundaries when the stream was interrupted by the cancellation of
+// its context, on server shutdown or client disconnection.
+func (s *Service) shutdownInterruptedPipeline(ctx context.Context, pipe *pipeline.Pipeline, logger *zap.Logger) {
+	if ctx.Err() == nil {
+		return
+	}
 
-		if errors.Is(err, context.Canceled) {
-			span.SetStatus(otelcode.Error, err.Error())
-			return status.Error(codes.Canceled, "source canceled")
-		}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), pipelineShutdownTimeout)
+	defer cancel()
 
-		if errors.Is(err, context.DeadlineExceeded) {
-			span.SetStatus(otelcode.Error, err.Error())
-			return status.Error(codes.DeadlineExceeded, "source deadline exceeded")
-		}
+	if err := pipe.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("unable to save work of interrupted pipeline", zap.Error(err))
+	}
+}
 
-		var errInvalidArg *stream.ErrInvalidArg
-		if errors.As(err, &errInvalidArg) {
-			span.SetStatus(otelcode.Error, err.Error())
-			return status.Error(codes.InvalidArgument, errInvalidArg.Error())
+func (s *Service) streamTerminationError(err error, pipe *pipeline.Pipeline, streamSrv pbsubstreams.Stream_BlocksServer, span ttrace.Span, logger *zap.Logger) error {
+	if errors.Is(err, io.EOF) {
+		var d []string
+		for _, rng := range pipe.PartialsWritten() {
+			d = append(d, fmt.Sprintf("%d-%d", rng.StartBlock, rng.ExclusiveEndBlock))
 		}
+		partialsWritten := []string{strings.Join(d, ",")}
+		zlog.Info("setting trailer", zap.Strings("ranges", partialsWritten))
+		streamSrv.SetTrailer(metadata.MD{"substreams-partials-written": partialsWritten})
+		span.SetStatus(otelcode.Ok, "")
+		return nil
+	}
 
-		var errSendBlock *ErrSendBlock
-		if errors.As(err, &errSendBlock) {
-			logger.Info("unable to send block probably due to client disconnecting", zap.Error(errSendBlock.inner))
-			span.SetStatus(otelcode.Error, err.Error())
-			return status.Error(codes.Unavailable, errSendBlock.inner.Error())
-		}
+	if errors.Is(err, stream.ErrStopBlockReached) {
+		logger.Info("stream of blocks reached end block")
+		span.SetStatus(otelcode.Ok, "")
+		return nil
+	}
 
-		logger.Info("unexpected stream of blocks termination", zap.Error(err))
+	if errors.Is(err, context.Canceled) {
 		span.SetStatus(otelcode.Error, err.Error())
-		return status.Errorf(codes.Internal, "unexpected termination: %s", err)
+		return status.Error(codes.Canceled, "source canceled")
 	}
-	span.SetStatus(otelcode.Ok, "")
-	return nil
-}
 
-func sendCachedModuleOutput(ctx context.Context, startBlock, stopBlock uint64, module *pbsubstreams.Module, cache *outputs.OutputCache, responseFunc func(resp *pbsubstreams.Response) error) (lastBlockSent *uint64, err error) {
-	cachedRanges, err := cache.ListContinuousCacheRanges(ctx, startBlock)
-	if err != nil {
-		return nil, fmt.Errorf("listing cached ranges: %w", err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		span.SetStatus(otelcode.Error, err.Error())
+		return status.Error(codes.DeadlineExceeded, "source deadline exceeded")
 	}
 
-	zlog.Info("found cached ranges", zap.Int("range_count", len(cachedRanges)))
-	for _, r := range cachedRanges {
-		//todo: check context
-		err := cache.Load(ctx, r)
-		if err != nil {
-			return nil, fmt.Errorf("loading cache: %w", err)
-		}
-
-		for _, item := range cache.SortedCacheItems() {
-			//todo: check context
-			if item.BlockNum >= stopBlock {
-				break
-			}
+	var errInvalidArg *stream.ErrInvalidArg
+	if errors.As(err, &errInvalidArg) {
+		span.SetStatus(otelcode.Error, err.Error())
+		return status.Error(codes.InvalidArgument, errInvalidArg.Error())
+	}
 
-			var output pbsubstreams.ModuleOutputData
-			switch module.Kind.(type) {
-			case *pbsubstreams.Module_KindMap_:
-				output = &pbsubstreams.ModuleOutput_MapOutput{
-					MapOutput: &anypb.Any{
-						TypeUrl: "type.googleapis.com/" + module.Output.Type,
-						Value:   item.Payload,
-					},
-				}
-			case *pbsubstreams.Module_KindStore_:
-				deltas := &pbsubstreams.StoreDeltas{}
-				err := proto.Unmarshal(item.Payload, deltas)
-				if err != nil {
-					return nil, fmt.Errorf("unmarshalling output deltas: %w", err)
-				}
-
-				output = &pbsubstreams.ModuleOutput_StoreDeltas{
-					StoreDeltas: &pbsubstreams.StoreDeltas{Deltas: deltas.Deltas},
-				}
-			default:
-				panic(fmt.Sprintf("invalid module file %T", module.Kind))
-			}
+	var errSendBlock *ErrSendBlock
+	if errors.As(err, &errSendBlock) {
+		logger.Info("unable to send block probably due to client disconnecting", zap.Error(errSendBlock.inner))
+		span.SetStatus(otelcode.Error, err.Error())
+		return status.Error(codes.Unavailable, errSendBlock.inner.Error())
+	}
 
-			out := &pbsubstreams.BlockScopedData{
-				Outputs: []*pbsubstreams.ModuleOutput{
-					{
-						Name: cache.ModuleName,
-						Data: output,
-					},
-				},
-				Clock: &pbsubstreams.Clock{
-					Id:        item.BlockID,
-					Number:    item.BlockNum,
-					Timestamp: item.Timestamp,
-				},
-				Step:   pbsubstreams.ForkStep_STEP_IRREVERSIBLE,
-				Cursor: item.Cursor,
-			}
+	logger.Info("unexpected stream of blocks termination", zap.Error(err))
+	span.SetStatus(otelcode.Error, err.Error())
+	st := status.Newf(codes.Internal, "unexpected termination: %s", err)
+	if detail := pipeline.PanicDetail(err); detail != nil {
+		if withDetails, detailsErr := st.WithDetails(detail); detailsErr == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
 
-			if err := responseFunc(substreams.NewBlockScopedDataResponse(out)); err != nil {
-				return nil, fmt.Errorf("calling return func: %w", err)
-			}
-			lastBlockSent = &item.BlockNum
+// checkNetwork refuses `request` when made for another network than the one
+// of the server, unless it allows it. Requests or servers without a network
+// are only logged, nothing telling them apart.
+func (s *Service) checkNetwork(request *pbsubstreams.Request, logger *zap.Logger) error {
+	switch {
+	case request.Network == "":
+		logger.Warn("request declares no network, running it unchecked", zap.String("server_network", s.network))
+	case s.network == "":
+		logger.Debug("server declares no network, running request unchecked", zap.String("request_network", request.Network))
+	case request.Network != s.network:
+		if !request.AllowNetworkMismatch {
+			return fmt.Errorf("request made for network %q, this server serves network %q", request.Network, s.network)
 		}
-		x := r.ExclusiveEndBlock - 1
-		lastBlockSent = &x
+		logger.Warn("request made for another network, running it as allowed", zap.String("request_network", request.Network), zap.String("server_network", s.network))
 	}
+	return nil
+}
 
-	return
+// checkSubstreamsVersion warns when the package of `request` was built by a
+// newer version of the substreams library than the server, it may use
+// features the server doesn't support. Development builds, without a Semver
+// version, are not compared.
+func (s *Service) checkSubstreamsVersion(request *pbsubstreams.Request, logger *zap.Logger) {
+	if request.SubstreamsVersion == "" {
+		return
+	}
+	serverVersion := manifest.LibraryVersion()
+	cmp, err := manifest.CompareVersions(request.SubstreamsVersion, serverVersion)
+	if err != nil {
+		logger.Debug("unable to compare substreams versions", zap.String("package_version", request.SubstreamsVersion), zap.String("server_version", serverVersion), zap.Error(err))
+		return
+	}
+	if cmp > 0 {
+		logger.Warn("package built by a newer substreams version than the server, it may use unsupported features", zap.String("package_version", request.SubstreamsVersion), zap.String("server_version", serverVersion))
+	}
+}
+
+// validationStatus turns the failed validation of the modules of a request
+// into an InvalidArgument status, each problem found listed as a field
+// violation of a BadRequest detail.
+func validationStatus(err error) error {
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("modules validation failed: %s", err))
+
+	var problems manifest.ValidationErrors
+	if !errors.As(err, &problems) {
+		return st.Err()
+	}
+	badRequest := &errdetails.BadRequest{}
+	for _, problem := range problems {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       problem.Field,
+			Description: problem.Message,
+		})
+	}
+	if withDetails, err := st.WithDetails(badRequest); err == nil {
+		st = withDetails
+	}
+	return st.Err()
 }
@@ -6,6 +6,8 @@ type Reader interface {
 	GetFirst(key string) ([]byte, bool)
 	GetLast(key string) ([]byte, bool)
 	GetAt(ord uint64, key string) ([]byte, bool)
+	HasLast(key string) bool
+	HasAt(ord uint64, key string) bool
 }
 
 type UpdateKeySetter interface {
@@ -33,7 +35,7 @@ type MaxInt64Setter interface {
 	SetMaxInt64(ord uint64, key string, value int64)
 }
 type MaxFloat64Setter interface {
-	SetMaxFloat64(ord uint64, key string, value float64)
+	SetMaxFloat64(ord uint64, key string, value float64) error
 }
 type MaxBigFloatSetter interface {
 	SetMaxBigFloat(ord uint64, key string, value *big.Float)
@@ -46,7 +48,7 @@ type MinInt64Setter interface {
 	SetMinInt64(ord uint64, key string, value int64)
 }
 type MinFloat64Setter interface {
-	SetMinFloat64(ord uint64, key string, value float64)
+	SetMinFloat64(ord uint64, key string, value float64) error
 }
 type MinBigFloatSetter interface {
 	SetMinBigFloat(ord uint64, key string, value *big.Float)
@@ -59,7 +61,7 @@ type SumInt64Setter interface {
 	SumInt64(ord uint64, key string, value int64)
 }
 type SumFloat64Setter interface {
-	SumFloat64(ord uint64, key string, value float64)
+	SumFloat64(ord uint64, key string, value float64) error
 }
 type SumBigFloatSetter interface {
 	SumBigFloat(ord uint64, key string, value *big.Float)
@@ -18,6 +18,9 @@ const (
 	OutputValueTypeString   = "string"
 
 	mergeDataKey = "__!__metadata" ///NEVER EVER CHANGE THIS
+	// moduleHashKey holds the hash of the module in the written files, it is
+	// added while encoding them and never part of the state
+	moduleHashKey = "__!__module_hash"
 )
 
 func (s *Store) clearMergeData() {
@@ -96,7 +99,11 @@ func (s *Store) Merge(nextStore *Store) error {
 				v0b, fv0 := s.KV[k]
 				v0 := foundOrZeroFloat(v0b, fv0)
 				v1 := foundOrZeroFloat(v, true)
-				s.KV[k] = []byte(floatToStr(sum(v0, v1)))
+				value, err := canonicalFloat64(k, sum(v0, v1))
+				if err != nil {
+					return fmt.Errorf("merging key %q: %w", k, err)
+				}
+				s.KV[k] = []byte(floatToStr(value))
 			}
 		case OutputValueTypeBigInt:
 			sum := func(a, b *big.Int) *big.Int {
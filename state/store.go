@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/streamingfast/derr"
 	"github.com/streamingfast/dstore"
@@ -120,6 +121,7 @@ func (s *Store) Fetch(ctx context.Context, exclusiveEndBlock uint64) error {
 
 func (s *Store) load(ctx context.Context, stateFileName string) error {
 	s.logger.Debug("loading state from file", zap.String("module_name", s.Name), zap.String("file_name", stateFileName))
+	var kv map[string][]byte
 	err := derr.RetryContext(ctx, 3, func(ctx context.Context) error {
 		r, err := s.Store.OpenObject(ctx, stateFileName)
 		if err != nil {
@@ -131,23 +133,43 @@ func (s *Store) load(ctx context.Context, stateFileName string) error {
 		}
 		defer r.Close()
 
-		kv := map[string][]byte{}
+		kv = map[string][]byte{}
 		if err = json.Unmarshal(data, &kv); err != nil {
 			return fmt.Errorf("unmarshal data: %w", err)
 		}
-		s.KV = kv
-
-		s.logger.Debug("unmarshalling kv", zap.String("file_name", stateFileName), zap.Object("store", s))
 		return nil
 	})
 	if err != nil {
 		return fmt.Errorf("storage file %s: %w", stateFileName, err)
 	}
 
+	if err := s.checkModuleHash(kv); err != nil {
+		return fmt.Errorf("storage file %s: %w", stateFileName, err)
+	}
+	s.KV = kv
+	s.logger.Debug("unmarshalling kv", zap.String("file_name", stateFileName), zap.Object("store", s))
+
 	s.logger.Debug("state loaded", zap.String("store_name", s.Name), zap.String("file_name", stateFileName))
 	return nil
 }
 
+// checkModuleHash refuses the content `kv` of a file written by another
+// version of the module, which would mix the state of both, and removes the
+// hash from it. Files written before the module hash was recorded are
+// accepted.
+func (s *Store) checkModuleHash(kv map[string][]byte) error {
+	fileHash, found := kv[moduleHashKey]
+	if !found {
+		return nil
+	}
+	delete(kv, moduleHashKey)
+
+	if string(fileHash) != s.ModuleHash {
+		return fmt.Errorf("written by module hash %q, refusing to load it for module %q with hash %q", string(fileHash), s.Name, s.ModuleHash)
+	}
+	return nil
+}
+
 // WriteState is to be called ONLY when we just passed the
 // `nextExpectedBoundary` and processed nothing more after that
 // boundary.
@@ -156,7 +178,7 @@ func (s *Store) WriteState(ctx context.Context, endBoundaryBlock uint64) (*store
 
 	//kv := stringMap(s.KV) // FOR READABILITY ON DISK
 
-	content, err := json.MarshalIndent(s.KV, "", "  ")
+	content, err := marshalState(s.KV, s.ModuleHash)
 	if err != nil {
 		return nil, fmt.Errorf("marshal kv state: %w", err)
 	}
@@ -181,6 +203,47 @@ func (s *Store) WriteState(ctx context.Context, endBoundaryBlock uint64) (*store
 	return sw, nil
 }
 
+// marshalState encodes `kv` as the JSON object of the state files, indented,
+// with `moduleHash` recorded under moduleHashKey. The hash is added while
+// encoding, `kv` is never modified.
+func marshalState(kv map[string][]byte, moduleHash string) ([]byte, error) {
+	keys := make([]string, 0, len(kv)+1)
+	for key := range kv {
+		if key != moduleHashKey {
+			keys = append(keys, key)
+		}
+	}
+	keys = append(keys, moduleHashKey)
+	sort.Strings(keys)
+
+	buffer := bytes.NewBuffer(nil)
+	buffer.WriteString("{")
+	for i, key := range keys {
+		value := kv[key]
+		if key == moduleHashKey {
+			value = []byte(moduleHash)
+		}
+
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		encodedValue, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if i != 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString("\n  ")
+		buffer.Write(encodedKey)
+		buffer.WriteString(": ")
+		buffer.Write(encodedValue)
+	}
+	buffer.WriteString("\n}")
+	return buffer.Bytes(), nil
+}
+
 type storeWriter struct {
 	objStore     dstore.Store
 	filename     string
@@ -282,6 +345,12 @@ func (s *Store) Roll(lastBlock uint64) {
 // 	s.nextExpectedBoundary += s.SaveInterval
 // }
 
+// LastOrdinal returns the ordinal of the last write to the store during the
+// current block, writes with a lower one are rejected.
+func (s *Store) LastOrdinal() uint64 {
+	return s.lastOrdinal
+}
+
 func (s *Store) bumpOrdinal(ord uint64) {
 	if s.lastOrdinal > ord {
 		panic("cannot Set or Del a value on a state.Builder with an ordinal lower than the previous")
@@ -38,7 +38,14 @@ func (s *Store) SetMaxInt64(ord uint64, key string, value int64) {
 	s.set(ord, key, []byte(fmt.Sprintf("%d", max)))
 }
 
-func (s *Store) SetMaxFloat64(ord uint64, key string, value float64) {
+// SetMaxFloat64 sets the value of `key` to `value` when it is greater than its current
+// value. It fails with a NaNError when `value` is NaN, see canonicalFloat64.
+func (s *Store) SetMaxFloat64(ord uint64, key string, value float64) error {
+	value, err := canonicalFloat64(key, value)
+	if err != nil {
+		return err
+	}
+
 	var max float64
 	val, found := s.GetAt(ord, key)
 	if !found {
@@ -52,7 +59,11 @@ func (s *Store) SetMaxFloat64(ord uint64, key string, value float64) {
 			max = prev
 		}
 	}
+	if max, err = canonicalFloat64(key, max); err != nil {
+		return err
+	}
 	s.set(ord, key, []byte(strconv.FormatFloat(max, 'g', 100, 64)))
+	return nil
 }
 
 func (s *Store) SetMaxBigFloat(ord uint64, key string, value *big.Float) {
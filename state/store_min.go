@@ -38,7 +38,14 @@ func (s *Store) SetMinInt64(ord uint64, key string, value int64) {
 	s.set(ord, key, []byte(fmt.Sprintf("%d", min)))
 }
 
-func (s *Store) SetMinFloat64(ord uint64, key string, value float64) {
+// SetMinFloat64 sets the value of `key` to `value` when it is lower than its current
+// value. It fails with a NaNError when `value` is NaN, see canonicalFloat64.
+func (s *Store) SetMinFloat64(ord uint64, key string, value float64) error {
+	value, err := canonicalFloat64(key, value)
+	if err != nil {
+		return err
+	}
+
 	var min float64
 	val, found := s.GetAt(ord, key)
 	if !found {
@@ -52,7 +59,11 @@ func (s *Store) SetMinFloat64(ord uint64, key string, value float64) {
 			min = prev
 		}
 	}
+	if min, err = canonicalFloat64(key, min); err != nil {
+		return err
+	}
 	s.set(ord, key, []byte(strconv.FormatFloat(min, 'g', 100, 64)))
+	return nil
 }
 
 func (s *Store) SetMinBigFloat(ord uint64, key string, value *big.Float) {
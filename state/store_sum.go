@@ -37,7 +37,14 @@ func (s *Store) SumInt64(ord uint64, key string, value int64) {
 	s.set(ord, key, []byte(strconv.FormatInt(sum, 10)))
 }
 
-func (s *Store) SumFloat64(ord uint64, key string, value float64) {
+// SumFloat64 adds `value` to the value of `key`. It fails with a NaNError
+// when `value` or the sum is NaN, see canonicalFloat64.
+func (s *Store) SumFloat64(ord uint64, key string, value float64) error {
+	value, err := canonicalFloat64(key, value)
+	if err != nil {
+		return err
+	}
+
 	var sum float64
 	val, found := s.GetAt(ord, key)
 	if !found {
@@ -50,7 +57,11 @@ func (s *Store) SumFloat64(ord uint64, key string, value float64) {
 			sum = prev + value
 		}
 	}
+	if sum, err = canonicalFloat64(key, sum); err != nil {
+		return err
+	}
 	s.set(ord, key, []byte(strconv.FormatFloat(sum, 'g', 100, 64)))
+	return nil
 }
 
 func (s *Store) SumBigFloat(ord uint64, key string, value *big.Float) {
@@ -1,6 +1,8 @@
 package state
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/streamingfast/substreams/block"
@@ -62,3 +64,57 @@ func TestFileName(t *testing.T) {
 	partialFileName := PartialFileName(&block.Range{StartBlock: 10000, ExclusiveEndBlock: 20000})
 	require.Equal(t, "0000020000-0000010000.partial", partialFileName)
 }
+
+func TestStore_LoadModuleHashMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	written := mustNewStore(t, "b", 0, "modulehash.1", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", nil)
+	written.Set(0, "1", "val1")
+	writer, err := written.WriteState(ctx, 10_000)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write())
+	assert.Len(t, written.KV, 1)
+
+	loaded := written.CloneStructure(0)
+	require.NoError(t, loaded.Fetch(ctx, 10_000))
+	assert.Equal(t, map[string][]byte{"1": []byte("val1")}, loaded.KV)
+
+	// Same files, as with a misconfigured path, under another module hash
+	other := written.CloneStructure(0)
+	other.ModuleHash = "modulehash.2"
+	err = other.Fetch(ctx, 10_000)
+	assert.EqualError(t, err, `storage file 0000010000-0000000000.kv: written by module hash "modulehash.1", refusing to load it for module "b" with hash "modulehash.2"`)
+}
+
+func TestMarshalState(t *testing.T) {
+	kv := map[string][]byte{"b": []byte("val2"), "a<": []byte("val1"), "__!__z": {}}
+	content, err := marshalState(kv, "modulehash.1")
+	require.NoError(t, err)
+
+	// Same files as encoding the hash along the state
+	expected, err := json.MarshalIndent(map[string][]byte{"b": []byte("val2"), "a<": []byte("val1"), "__!__z": {}, moduleHashKey: []byte("modulehash.1")}, "", "  ")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(content))
+	assert.Len(t, kv, 3, "the state is left alone")
+}
+
+func TestStore_Has(t *testing.T) {
+	s := mustNewStore(t, "b", 0, "modulehash.1", pbsubstreams.Module_KindStore_UPDATE_POLICY_UNSET, "", nil)
+	s.Set(0, "kept", "val1")
+	s.Set(0, "deleted", "val2")
+	s.Flush()
+	s.Set(2, "created", "val3")
+	s.Del(4, "deleted")
+
+	for _, key := range []string{"kept", "deleted", "created", "absent"} {
+		for _, ord := range []uint64{0, 3, 5} {
+			_, found := s.GetAt(ord, key)
+			assert.Equal(t, found, s.HasAt(ord, key), "key %q at %d", key, ord)
+		}
+		_, found := s.GetLast(key)
+		assert.Equal(t, found, s.HasLast(key), "key %q", key)
+	}
+	assert.True(t, s.HasAt(3, "deleted"))
+	assert.False(t, s.HasAt(5, "deleted"))
+	assert.False(t, s.HasLast("deleted"))
+}
@@ -0,0 +1,45 @@
+package state
+
+import (
+	"math/big"
+)
+
+// BigIntFromSignedBytesLE decodes the two's complement little endian form of
+// a big integer, the one of `to_signed_bytes_le` in Rust. The value is still
+// stored in its decimal form, only the host functions receive it this way.
+// An empty input is zero.
+func BigIntFromSignedBytesLE(in []byte) *big.Int {
+	be := make([]byte, len(in))
+	for i, b := range in {
+		be[len(in)-1-i] = b
+	}
+	value := new(big.Int).SetBytes(be)
+	if len(in) != 0 && in[len(in)-1]&0x80 != 0 {
+		// Negative, the magnitude is the complement to 2^(8*len)
+		value.Sub(value, new(big.Int).Lsh(big.NewInt(1), uint(8*len(in))))
+	}
+	return value
+}
+
+// BigIntToSignedBytesLE is the inverse of BigIntFromSignedBytesLE, returning
+// the shortest form of `value`.
+func BigIntToSignedBytesLE(value *big.Int) []byte {
+	if value.Sign() == 0 {
+		return []byte{0}
+	}
+	length := value.BitLen()/8 + 1 // room for the sign bit
+	twos := new(big.Int).Set(value)
+	if value.Sign() < 0 {
+		twos.Add(twos, new(big.Int).Lsh(big.NewInt(1), uint(8*length)))
+	}
+	be := twos.FillBytes(make([]byte, length))
+	out := make([]byte, length)
+	for i, b := range be {
+		out[length-1-i] = b
+	}
+	// Drops the redundant sign bytes
+	for len(out) > 1 && ((out[len(out)-1] == 0 && out[len(out)-2]&0x80 == 0) || (out[len(out)-1] == 0xff && out[len(out)-2]&0x80 != 0)) {
+		out = out[:len(out)-1]
+	}
+	return out
+}
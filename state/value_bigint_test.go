@@ -0,0 +1,44 @@
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBigIntSignedBytesLE(t *testing.T) {
+	huge, ok := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	require.True(t, ok)
+
+	tests := []struct {
+		value    *big.Int
+		expected []byte
+	}{
+		{big.NewInt(0), []byte{0}},
+		{big.NewInt(1), []byte{1}},
+		{big.NewInt(-1), []byte{0xff}},
+		{big.NewInt(127), []byte{0x7f}},
+		{big.NewInt(128), []byte{0x80, 0x00}},
+		{big.NewInt(-128), []byte{0x80}},
+		{big.NewInt(-129), []byte{0x7f, 0xff}},
+		{big.NewInt(256), []byte{0x00, 0x01}},
+		{big.NewInt(-256), []byte{0x00, 0xff}},
+		{huge, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.value.String(), func(t *testing.T) {
+			encoded := BigIntToSignedBytesLE(test.value)
+			if test.expected != nil {
+				assert.Equal(t, test.expected, encoded)
+			}
+			assert.Equal(t, test.value.String(), BigIntFromSignedBytesLE(encoded).String())
+		})
+	}
+
+	// Sign extended and empty forms
+	assert.Equal(t, "-1", BigIntFromSignedBytesLE([]byte{0xff, 0xff, 0xff}).String())
+	assert.Equal(t, "1", BigIntFromSignedBytesLE([]byte{0x01, 0x00, 0x00}).String())
+	assert.Equal(t, "0", BigIntFromSignedBytesLE(nil).String())
+}
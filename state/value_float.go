@@ -0,0 +1,31 @@
+package state
+
+import (
+	"fmt"
+	"math"
+)
+
+// NaNError is returned by the float64 operations whose value or result is
+// NaN, like the sum of the two infinities. NaN compares inconsistently, it is
+// never stored.
+type NaNError struct {
+	Key string
+}
+
+func (e *NaNError) Error() string {
+	return fmt.Sprintf("float64 value of key %q is NaN", e.Key)
+}
+
+// canonicalFloat64 returns the value of `f` stored for `key`, so all the
+// servers write the same bytes for it: the infinities are kept, and stored as
+// "+Inf" and "-Inf", the negative zero is stored as 0, NaN is rejected.
+func canonicalFloat64(key string, f float64) (float64, error) {
+	if math.IsNaN(f) {
+		return 0, &NaNError{Key: key}
+	}
+	if f == 0 {
+		// Drops the sign of the negative zero
+		return 0, nil
+	}
+	return f, nil
+}
@@ -0,0 +1,72 @@
+package state
+
+import (
+	"math"
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Float64Canonical(t *testing.T) {
+	sum := func(s *Store, value float64) error { return s.SumFloat64(0, "key", value) }
+	max := func(s *Store, value float64) error { return s.SetMaxFloat64(0, "key", value) }
+	min := func(s *Store, value float64) error { return s.SetMinFloat64(0, "key", value) }
+
+	tests := []struct {
+		name          string
+		operation     func(s *Store, value float64) error
+		existingValue []byte
+		value         float64
+		expectedValue string
+		expectNaN     bool
+	}{
+		{"sum NaN", sum, nil, math.NaN(), "", true},
+		{"max NaN", max, []byte("3"), math.NaN(), "", true},
+		{"min NaN", min, []byte("3"), math.NaN(), "", true},
+		{"sum of the infinities", sum, []byte("+Inf"), math.Inf(-1), "", true},
+		{"sum +Inf", sum, []byte("3"), math.Inf(1), "+Inf", false},
+		{"sum to +Inf", sum, []byte("+Inf"), 3, "+Inf", false},
+		{"max +Inf", max, []byte("3"), math.Inf(1), "+Inf", false},
+		{"max -Inf", max, nil, math.Inf(-1), "-Inf", false},
+		{"min -Inf", min, []byte("3"), math.Inf(-1), "-Inf", false},
+		{"min negative zero", min, nil, math.Copysign(0, -1), "0", false},
+		{"max negative zero", max, []byte("0"), math.Copysign(0, -1), "0", false},
+		{"sum negative zeros", sum, nil, math.Copysign(0, -1), "0", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := initTestStore("key", test.existingValue)
+
+			err := test.operation(store, test.value)
+			if test.expectNaN {
+				var nanErr *NaNError
+				require.ErrorAs(t, err, &nanErr)
+				assert.Equal(t, test.existingValue, store.KV["key"], "left untouched")
+				return
+			}
+			require.NoError(t, err)
+			value, found := store.GetAt(0, "key")
+			require.True(t, found)
+			assert.Equal(t, test.expectedValue, string(value))
+		})
+	}
+}
+
+func TestStore_MergeFloat64Infinities(t *testing.T) {
+	prev := mustNewStore(t, "b", 0, "modulehash.1", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, OutputValueTypeFloat64, nil)
+	prev.KV = map[string][]byte{"a": []byte("+Inf"), "b": []byte("+Inf")}
+	latest := mustNewStore(t, "b", 0, "modulehash.1", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, OutputValueTypeFloat64, nil)
+	latest.KV = map[string][]byte{"a": []byte("1")}
+
+	require.NoError(t, prev.Merge(latest))
+	assert.Equal(t, "+Inf", string(prev.KV["a"]))
+
+	latest.KV = map[string][]byte{"b": []byte("-Inf")}
+	err := prev.Merge(latest)
+	var nanErr *NaNError
+	require.ErrorAs(t, err, &nanErr)
+	assert.Contains(t, err.Error(), `merging key "b"`)
+}
@@ -1,11 +1,18 @@
 package state
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 )
 
+// ErrStopIteration can be returned by the function passed to Iterate to stop
+// the iteration early.
+var ErrStopIteration = errors.New("stop iteration")
+
 func (s *Store) GetFirst(key string) ([]byte, bool) {
 	for _, delta := range s.Deltas {
 		if delta.Key == key {
@@ -53,3 +60,39 @@ func (s *Store) GetAt(ord uint64, key string) (out []byte, found bool) {
 	}
 	return
 }
+
+// HasLast tells if `key` is in the store, like GetLast without the value.
+func (s *Store) HasLast(key string) bool {
+	_, found := s.KV[key]
+	return found
+}
+
+// HasAt tells if `key` is in the state that includes the processing of `ord`,
+// like GetAt without the value.
+func (s *Store) HasAt(ord uint64, key string) bool {
+	_, found := s.GetAt(ord, key)
+	return found
+}
+
+// Iterate calls `f` for every key of the store starting with `prefix`, in
+// lexicographic order of the keys, until `f` returns an error. Returning
+// ErrStopIteration stops the iteration without error.
+func (s *Store) Iterate(prefix string, f func(key string, value []byte) error) error {
+	var keys []string
+	for key := range s.KV {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := f(key, s.KV[key]); err != nil {
+			if err == ErrStopIteration {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
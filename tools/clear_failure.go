@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"go.uber.org/zap"
+)
+
+var clearFailureCmd = &cobra.Command{
+	Use:   "clear-failure <module_outputs_store_url> <block_num>",
+	Short: "Removes the failure of a module cached at a given block, so the module gets executed again on it",
+	Long:  "Deterministic failures of a module are cached along its outputs, use this command when a failure was caused by the environment instead. The store URL points to the outputs of the module, as in '<cache_store_url>/<module_hash>/outputs'.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  clearFailureE,
+}
+
+func init() {
+	Cmd.AddCommand(clearFailureCmd)
+}
+
+func clearFailureE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Cache files are rewritten in place
+	store, err := dstore.NewStore(args[0], "", "", true)
+	if err != nil {
+		return fmt.Errorf("could not create store from %s: %w", args[0], err)
+	}
+
+	blockNum, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number %q: %w", args[1], err)
+	}
+
+	cleared, err := outputs.ClearFailures(ctx, store, blockNum)
+	if err != nil {
+		return fmt.Errorf("clearing failures: %w", err)
+	}
+
+	if len(cleared) == 0 {
+		zlog.Info("no failure found", zap.Uint64("block_num", blockNum))
+		return nil
+	}
+	zlog.Info("failure cleared", zap.Uint64("block_num", blockNum), zap.Strings("files", cleared))
+	return nil
+}
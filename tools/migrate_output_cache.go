@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"go.uber.org/zap"
+)
+
+var migrateOutputCacheCmd = &cobra.Command{
+	Use:   "migrate-output-cache <cache_store_url> [<prefix>]",
+	Short: "Rewrites the output cache files of an older format in the latest one",
+	Long:  "Output cache files are read whatever their format, this command upgrades the ones written by previous releases ahead of the removal of their format, without executing the modules again. The prefix restricts the files migrated, as in '<module_hash>/outputs/00012' for the files of a module starting at block 120000 to 129999.",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  migrateOutputCacheE,
+}
+
+func init() {
+	Cmd.AddCommand(migrateOutputCacheCmd)
+}
+
+func migrateOutputCacheE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Cache files are rewritten in place
+	store, err := dstore.NewStore(args[0], "", "", true)
+	if err != nil {
+		return fmt.Errorf("could not create store from %s: %w", args[0], err)
+	}
+
+	prefix := ""
+	if len(args) == 2 {
+		prefix = args[1]
+	}
+
+	migrated, err := outputs.MigrateSegments(ctx, store, prefix)
+	if err != nil {
+		return fmt.Errorf("migrating output cache files: %w", err)
+	}
+
+	zlog.Info("output cache files migrated", zap.Int("format_version", int(outputs.FormatVersion)), zap.Int("migrated_count", len(migrated)))
+	return nil
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/pipeline"
+	"go.uber.org/zap"
+)
+
+var moduleHashesCmd = &cobra.Command{
+	Use:   "module-hashes <state_store_url>",
+	Short: "Lists the module hashes used by each package version, and deletes the files of the package versions not served anymore",
+	Long:  "The servers record the module hashes used by each package version requested, under 'modules/' in the state store. Without --delete, this command lists them. With --delete, the output caches and store snapshots of the module hashes only used by the given package versions are deleted, the hashes also used by other package versions being kept. Module hashes requested without a package name and version are not recorded: delete package versions only when such requests are not served from the same state store.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  moduleHashesE,
+}
+
+func init() {
+	moduleHashesCmd.Flags().StringSlice("delete", nil, "Package versions, as <name>@<version>, whose module hashes are deleted, comma-separated or repeated")
+	moduleHashesCmd.Flags().Bool("dry-run", false, "Only list the module hashes whose files would be deleted")
+
+	Cmd.AddCommand(moduleHashesCmd)
+}
+
+func moduleHashesE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := dstore.NewStore(args[0], "", "", false)
+	if err != nil {
+		return fmt.Errorf("could not create store from %s: %w", args[0], err)
+	}
+
+	deleting := mustGetStringSlice(cmd, "delete")
+	var versions []pipeline.PackageVersion
+	for _, in := range deleting {
+		version, err := pipeline.ParsePackageVersion(in)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, version)
+	}
+
+	if len(versions) == 0 {
+		markers, err := pipeline.ListModuleHashMarkers(ctx, store)
+		if err != nil {
+			return err
+		}
+		for _, marker := range markers {
+			fmt.Printf("%s@%s\t%s\t%s\n", marker.PackageName, marker.PackageVersion, marker.ModuleName, marker.ModuleHash)
+		}
+		return nil
+	}
+
+	dryRun := mustGetBool(cmd, "dry-run")
+	deleted, err := pipeline.DeletePackageVersions(ctx, store, versions, dryRun, zlog)
+	if err != nil {
+		return fmt.Errorf("deleting package versions: %w", err)
+	}
+	zlog.Info("package versions deleted", zap.Bool("dry_run", dryRun), zap.Strings("package_versions", deleting), zap.Strings("deleted_module_hashes", deleted))
+	return nil
+}
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/pipeline/outputs"
+	"go.uber.org/zap"
+)
+
+var outputCacheRetentionCmd = &cobra.Command{
+	Use:   "output-cache-retention <cache_store_url>",
+	Short: "Deletes the output caches of the modules not accessed for a while",
+	Long:  "The servers record when the output caches of each module hash are accessed, at most hourly. This command deletes the output caches of the module hashes not accessed within --max-idle, leaving their store snapshots. Module hashes never accessed since the servers record it are marked as accessed now, and considered by the next runs. Requests reading a cache file deleted meanwhile execute the module again.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  outputCacheRetentionE,
+}
+
+func init() {
+	outputCacheRetentionCmd.Flags().Duration("max-idle", 30*24*time.Hour, "Time past which the output caches of a module hash not accessed are deleted")
+	outputCacheRetentionCmd.Flags().StringSlice("keep", nil, "Module names or hashes whose output caches are always kept, comma-separated or repeated")
+	outputCacheRetentionCmd.Flags().Bool("dry-run", false, "Only list the module hashes whose output caches would be deleted")
+
+	Cmd.AddCommand(outputCacheRetentionCmd)
+}
+
+func outputCacheRetentionE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := dstore.NewStore(args[0], "", "", false)
+	if err != nil {
+		return fmt.Errorf("could not create store from %s: %w", args[0], err)
+	}
+
+	policy := outputs.RetentionPolicy{
+		MaxIdle: mustGetDuration(cmd, "max-idle"),
+		Keep:    mustGetStringSlice(cmd, "keep"),
+		DryRun:  mustGetBool(cmd, "dry-run"),
+	}
+	result, err := outputs.ApplyRetention(ctx, store, policy, time.Now(), zlog)
+	if err != nil {
+		return fmt.Errorf("applying output cache retention: %w", err)
+	}
+
+	zlog.Info("output cache retention applied",
+		zap.Bool("dry_run", policy.DryRun),
+		zap.Strings("deleted", result.Deleted),
+		zap.Strings("kept", result.Kept),
+		zap.Strings("first_seen", result.FirstSeen),
+	)
+	return nil
+}
@@ -19,8 +19,14 @@ import (
 func (ui *TUI) decoratedBlockScopedData(output *pbsubstreams.BlockScopedData) error {
 	var s []string
 	for _, out := range output.Outputs {
-		for _, log := range out.Logs {
-			s = append(s, fmt.Sprintf("%s: log: %s\n", out.Name, log))
+		for i, log := range out.Logs {
+			s = append(s, fmt.Sprintf("%s: %s: %s\n", out.Name, logPrefix(out, i), log))
+		}
+		if out.LogsTruncated {
+			s = append(s, fmt.Sprintf("%s: logs truncated, %s lines dropped (%s)\n", out.Name, humanize.Comma(int64(out.DroppedLogs)), humanize.IBytes(out.DroppedLogsBytes)))
+		}
+		if out.OutputTruncated {
+			s = append(s, fmt.Sprintf("%s: output truncated, over the server output size limit\n", out.Name))
 		}
 
 		switch data := out.Data.(type) {
@@ -36,6 +42,8 @@ func (ui *TUI) decoratedBlockScopedData(output *pbsubstreams.BlockScopedData) er
 			if len(data.StoreDeltas.Deltas) != 0 {
 				s = append(s, ui.renderDecoratedDeltas(out.Name, output.Clock.Number, data.StoreDeltas.Deltas, false)...)
 			}
+		case *pbsubstreams.ModuleOutput_StoreFullKv:
+			s = append(s, ui.renderDecoratedFullKV(out.Name, output.Clock.Number, data.StoreFullKv.Entries)...)
 		}
 	}
 	if len(s) != 0 {
@@ -109,6 +117,39 @@ func indent(in []byte) []byte {
 	return bytes.Replace(in, []byte("\n"), []byte("\n    "), -1)
 }
 
+func (ui *TUI) renderDecoratedFullKV(modName string, blockNum uint64, entries []*pbsubstreams.StoreKeyValue) (s []string) {
+	msgDesc := ui.msgDescs[modName]
+	msgType := ui.msgTypes[modName]
+	s = append(s, fmt.Sprintf("%s: store content (%d keys):\n", modName, len(entries)))
+	for _, entry := range entries {
+		keyStr, _ := json.Marshal(entry.Key)
+		value := ui.decodeDynamicStoreDeltas(msgType, msgDesc, blockNum, modName, entry.Value)
+		s = append(s, fmt.Sprintf("  KEY: %s\n", ui.prettyFormat(keyStr, false)))
+		s = append(s, fmt.Sprintf("    VALUE: %s\n", indent(ui.prettyFormat(value, false))))
+	}
+	return
+}
+
+func (ui *TUI) printJSONFullKV(modName string, blockNum uint64, entries []*pbsubstreams.StoreKeyValue) error {
+	msgDesc := ui.msgDescs[modName]
+	msgType := ui.msgTypes[modName]
+
+	for _, entry := range entries {
+		wrap := DebugStoreEntryWrap{
+			Module:   modName,
+			BlockNum: blockNum,
+			Key:      entry.Key,
+			Value:    json.RawMessage(ui.decodeDynamicStoreDeltas(msgType, msgDesc, blockNum, modName, entry.Value)),
+		}
+		cnt, err := json.Marshal(wrap)
+		if err != nil {
+			return fmt.Errorf("marshal wrap: %w", err)
+		}
+		fmt.Println(string(ui.prettyFormat(cnt, false)))
+	}
+	return nil
+}
+
 func (ui *TUI) jsonBlockScopedData(output *pbsubstreams.BlockScopedData) error {
 	for _, out := range output.Outputs {
 		switch data := out.Data.(type) {
@@ -127,6 +168,10 @@ func (ui *TUI) jsonBlockScopedData(output *pbsubstreams.BlockScopedData) error {
 					return fmt.Errorf("print json deltas: %w", err)
 				}
 			}
+		case *pbsubstreams.ModuleOutput_StoreFullKv:
+			if err := ui.printJSONFullKV(out.Name, output.Clock.Number, data.StoreFullKv.Entries); err != nil {
+				return fmt.Errorf("print json full kv: %w", err)
+			}
 		}
 	}
 	return nil
@@ -180,6 +225,48 @@ func (ui *TUI) jsonSnapshotData(output *pbsubstreams.InitialSnapshotData) error
 	return nil
 }
 
+func (ui *TUI) decoratedDebugStoreSnapshot(output *pbsubstreams.DebugStoreSnapshot) {
+	modName := output.ModuleName
+	msgDesc := ui.msgDescs[modName]
+	msgType := ui.msgTypes[modName]
+
+	var s []string
+	for _, entry := range output.Entries {
+		keyStr, _ := json.Marshal(entry.Key)
+		value := ui.decodeDynamicStoreDeltas(msgType, msgDesc, output.Clock.Number, modName, entry.Value)
+		s = append(s, fmt.Sprintf("%s: store snapshot at block %d: KEY: %s\n    %s\n", modName, output.Clock.Number, ui.prettyFormat(keyStr, false), ui.prettyFormat(value, true)))
+	}
+	if output.Complete {
+		s = append(s, fmt.Sprintf("%s: store snapshot at block %d complete, %d/%d keys sent", modName, output.Clock.Number, output.SentKeys, output.TotalKeys))
+		if output.Truncated {
+			s = append(s, " (truncated)")
+		}
+		s = append(s, "\n")
+	}
+	fmt.Print(strings.Join(s, ""))
+}
+
+func (ui *TUI) jsonDebugStoreSnapshot(output *pbsubstreams.DebugStoreSnapshot) error {
+	modName := output.ModuleName
+	msgDesc := ui.msgDescs[modName]
+	msgType := ui.msgTypes[modName]
+
+	for _, entry := range output.Entries {
+		wrap := DebugStoreEntryWrap{
+			Module:   modName,
+			BlockNum: output.Clock.Number,
+			Key:      entry.Key,
+			Value:    json.RawMessage(ui.decodeDynamicStoreDeltas(msgType, msgDesc, output.Clock.Number, modName, entry.Value)),
+		}
+		cnt, err := json.Marshal(wrap)
+		if err != nil {
+			return fmt.Errorf("marshal wrap: %w", err)
+		}
+		fmt.Println(string(ui.prettyFormat(cnt, false)))
+	}
+	return nil
+}
+
 func (ui *TUI) formatPostDataProgress(msg *pbsubstreams.Response_Progress) {
 	var displayedFailure bool
 	for _, mod := range msg.Progress.Modules {
@@ -329,6 +416,13 @@ type SnapshotDeltaWrap struct {
 	Delta    DeltaWrap `json:"@delta"`
 }
 
+type DebugStoreEntryWrap struct {
+	Module   string          `json:"@module"`
+	BlockNum uint64          `json:"@block"`
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+}
+
 type DeltaWrap struct {
 	Operation string          `json:"op"`
 	Ordinal   uint64          `json:"ordinal"`
@@ -365,6 +459,16 @@ func printClock(block *pbsubstreams.BlockScopedData) {
 	fmt.Printf("----------- %s BLOCK #%s (%d) ---------------\n", strings.ToUpper(stepFromProto(block.Step).String()), humanize.Comma(int64(block.Clock.Number)), block.Clock.Number)
 }
 
+func printModuleFailure(failure *pbsubstreams.ModuleFailure) {
+	fmt.Printf("%s: failed at block #%d: %s\n", failure.ModuleName, failure.Clock.GetNumber(), failure.Reason)
+	fmt.Printf("%s: quarantined modules: %s\n", failure.ModuleName, strings.Join(failure.QuarantinedModules, ", "))
+}
+
+func printUndoSignal(signal *pbsubstreams.BlockUndoSignal) {
+	lastValid := signal.LastValidBlock
+	fmt.Printf("----------- UNDO UP TO BLOCK #%s (%d) %s ---------------\n", humanize.Comma(int64(lastValid.Number)), lastValid.Number, lastValid.Id)
+}
+
 func stepFromProto(step pbsubstreams.ForkStep) bstream.StepType {
 	switch step {
 	case pbsubstreams.ForkStep_STEP_NEW:
@@ -376,3 +480,21 @@ func stepFromProto(step pbsubstreams.ForkStep) bstream.StepType {
 	}
 	return bstream.StepType(0)
 }
+
+// logPrefix returns the prefix of the log `i` of `out`, the same as before
+// log levels for the info ones.
+func logPrefix(out *pbsubstreams.ModuleOutput, i int) string {
+	if i >= len(out.LogLevels) {
+		return "log"
+	}
+	switch out.LogLevels[i] {
+	case pbsubstreams.LogLevel_LOG_LEVEL_DEBUG:
+		return "debug"
+	case pbsubstreams.LogLevel_LOG_LEVEL_WARN:
+		return "warn"
+	case pbsubstreams.LogLevel_LOG_LEVEL_ERROR:
+		return "error"
+	default:
+		return "log"
+	}
+}
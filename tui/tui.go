@@ -9,6 +9,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/mattn/go-isatty"
+	"github.com/streamingfast/substreams/manifest"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 )
 
@@ -66,6 +67,8 @@ func (ui *TUI) Init(outputMode string) error {
 					msgType = modKind.KindStore.ValueType
 				case *pbsubstreams.Module_KindMap_:
 					msgType = modKind.KindMap.OutputType
+				case *pbsubstreams.Module_KindBlockIndex_:
+					msgType = manifest.BlockIndexKeysType
 				}
 				msgType = strings.TrimPrefix(msgType, "proto:")
 
@@ -158,10 +161,44 @@ func (ui *TUI) IncomingMessage(resp *pbsubstreams.Response) error {
 			return ui.jsonSnapshotData(m.SnapshotData)
 		}
 		fmt.Println("Incoming snapshot data")
+	case *pbsubstreams.Response_UndoSignal:
+		if ui.decorateOutput {
+			ui.ensureTerminalUnlocked()
+		}
+		printUndoSignal(m.UndoSignal)
+	case *pbsubstreams.Response_DebugStoreSnapshot:
+		if ui.decorateOutput {
+			ui.ensureTerminalUnlocked()
+			ui.decoratedDebugStoreSnapshot(m.DebugStoreSnapshot)
+		} else {
+			return ui.jsonDebugStoreSnapshot(m.DebugStoreSnapshot)
+		}
 	case *pbsubstreams.Response_SnapshotComplete:
 		if ui.decorateOutput {
 			fmt.Println("Snapshot data dump complete")
 		}
+	case *pbsubstreams.Response_SessionInit:
+		if ui.decorateOutput {
+			fmt.Printf("Module execution order: %s\n", strings.Join(m.SessionInit.ModuleExecutionOrder, ", "))
+			if network := m.SessionInit.Network; network != "" && ui.req.Network != "" && network != ui.req.Network {
+				fmt.Printf("Warning: the package is made for network %s, the endpoint serves network %s\n", ui.req.Network, network)
+			}
+			for _, override := range m.SessionInit.InitialBlockOverrides {
+				fmt.Printf("Module %s starts at block %d instead of %d, its caches and the ones of the modules depending on it are not shared with the declared initial block\n", override.ModuleName, override.InitialBlock, override.DeclaredInitialBlock)
+			}
+			for _, name := range ui.outputStreamNames {
+				if doc, _, err := manifest.ModuleDoc(ui.pkg, name); err == nil && doc != "" {
+					fmt.Printf("Module %s: %s\n", name, manifest.DocSummary(doc))
+				}
+			}
+		}
+	case *pbsubstreams.Response_ModuleFailure:
+		if ui.decorateOutput {
+			ui.ensureTerminalUnlocked()
+		}
+		printModuleFailure(m.ModuleFailure)
+	case *pbsubstreams.Response_Stats:
+		// Only a sign of life of the server, nothing to show
 	default:
 		fmt.Println("Unsupported response")
 	}
@@ -32,5 +32,35 @@ func NewSnapshotComplete() *pbsubstreams.Response {
 	}
 }
 
+func NewBlockUndoSignalResponse(in *pbsubstreams.BlockUndoSignal) *pbsubstreams.Response {
+	return &pbsubstreams.Response{
+		Message: &pbsubstreams.Response_UndoSignal{UndoSignal: in},
+	}
+}
+
+func NewDebugStoreSnapshotResponse(in *pbsubstreams.DebugStoreSnapshot) *pbsubstreams.Response {
+	return &pbsubstreams.Response{
+		Message: &pbsubstreams.Response_DebugStoreSnapshot{DebugStoreSnapshot: in},
+	}
+}
+
+func NewPipelineStatsResponse(in *pbsubstreams.PipelineStats) *pbsubstreams.Response {
+	return &pbsubstreams.Response{
+		Message: &pbsubstreams.Response_Stats{Stats: in},
+	}
+}
+
+func NewSessionInitResponse(in *pbsubstreams.SessionInit) *pbsubstreams.Response {
+	return &pbsubstreams.Response{
+		Message: &pbsubstreams.Response_SessionInit{SessionInit: in},
+	}
+}
+
+func NewModuleFailureResponse(in *pbsubstreams.ModuleFailure) *pbsubstreams.Response {
+	return &pbsubstreams.Response{
+		Message: &pbsubstreams.Response_ModuleFailure{ModuleFailure: in},
+	}
+}
+
 type BlockHook func(ctx context.Context, clock *pbsubstreams.Clock) error
 type PostJobHook func(ctx context.Context, clock *pbsubstreams.Clock) error
@@ -0,0 +1,145 @@
+package wasm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CompilationCache keeps the compiled wasm code of the modules on disk, keyed
+// by module hash, so a process starting cold does not compile every module of
+// a package again. The entries live in a sub directory per engine version and
+// architecture, since compiled code can only be loaded by the exact engine
+// that produced it.
+//
+// The directory can be shared by concurrent processes: entries are written to
+// a temporary file then renamed, so a reader never sees a partial entry. Each
+// entry starts with the checksum of the compiled code, a corrupt entry is
+// deleted and the module compiled again.
+type CompilationCache struct {
+	baseDir string
+}
+
+func NewCompilationCache(baseDir string) (*CompilationCache, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating compilation cache directory %q: %w", baseDir, err)
+	}
+	return &CompilationCache{baseDir: baseDir}, nil
+}
+
+// filename returns the path of the entry of `moduleHash` compiled by `e`.
+func (c *CompilationCache) filename(e engine, moduleHash string) string {
+	return filepath.Join(c.baseDir, fmt.Sprintf("%s-%s", e.cacheKey(), runtime.GOARCH), moduleHash)
+}
+
+// compile returns the compiled `wasmCode` of `moduleHash`, loading it from
+// the cache when possible, or compiling it and writing it to the cache
+// otherwise. The cache is only an optimization, failing to write to it is
+// logged.
+func (c *CompilationCache) compile(e engine, moduleHash string, wasmCode []byte) (compiledModule, error) {
+	if c == nil {
+		return compileModule(e, wasmCode)
+	}
+
+	filename := c.filename(e, moduleHash)
+	if compiled := c.load(e, filename); compiled != nil {
+		compilationCacheHits.Inc()
+		return compiled, nil
+	}
+	compilationCacheMisses.Inc()
+
+	compiled, err := compileModule(e, wasmCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.write(filename, compiled); err != nil {
+		zlog.Warn("unable to write compiled module to the compilation cache", zap.String("module_hash", moduleHash), zap.Error(err))
+	}
+	return compiled, nil
+}
+
+func (c *CompilationCache) load(e engine, filename string) compiledModule {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zlog.Warn("unable to read compilation cache entry", zap.String("filename", filename), zap.Error(err))
+		}
+		return nil
+	}
+
+	if len(content) < sha256.Size {
+		c.discard(filename, fmt.Errorf("entry of %d bytes is too short", len(content)))
+		return nil
+	}
+	checksum, serialized := content[:sha256.Size], content[sha256.Size:]
+	if sum := sha256.Sum256(serialized); !bytes.Equal(checksum, sum[:]) {
+		c.discard(filename, fmt.Errorf("checksum mismatch"))
+		return nil
+	}
+
+	compiled, err := e.deserialize(serialized)
+	if err != nil {
+		c.discard(filename, err)
+		return nil
+	}
+	return compiled
+}
+
+func (c *CompilationCache) discard(filename string, cause error) {
+	zlog.Warn("discarding corrupt compilation cache entry", zap.String("filename", filename), zap.Error(cause))
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		zlog.Warn("unable to delete compilation cache entry", zap.String("filename", filename), zap.Error(err))
+	}
+}
+
+func (c *CompilationCache) write(filename string, compiled compiledModule) error {
+	serialized, err := compiled.serialize()
+	if err != nil {
+		return fmt.Errorf("serializing module: %w", err)
+	}
+	checksum := sha256.Sum256(serialized)
+
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(checksum[:])
+	if err == nil {
+		_, err = tmp.Write(serialized)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	// Atomic, another process writing the same entry at the same time writes
+	// the same content
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("renaming temporary file: %w", err)
+	}
+	return nil
+}
+
+func compileModule(e engine, wasmCode []byte) (compiledModule, error) {
+	start := time.Now()
+	compiled, err := e.compile(wasmCode)
+	if err != nil {
+		return nil, err
+	}
+	compileDuration.ObserveSince(start)
+	return compiled, nil
+}
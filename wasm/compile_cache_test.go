@@ -0,0 +1,64 @@
+package wasm
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilationCache(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(counterWAT)
+	require.NoError(t, err)
+	cache, err := NewCompilationCache(t.TempDir())
+	require.NoError(t, err)
+	filename := cache.filename(newWasmtimeEngine(), "hash_a")
+
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T)
+	}{
+		{name: "cache miss"},
+		{name: "cache hit"},
+		{
+			name: "truncated entry",
+			corrupt: func(t *testing.T) {
+				require.NoError(t, os.WriteFile(filename, []byte("short"), 0644))
+			},
+		},
+		{
+			name: "checksum mismatch",
+			corrupt: func(t *testing.T) {
+				content, err := os.ReadFile(filename)
+				require.NoError(t, err)
+				content[len(content)-1] ^= 0xff
+				require.NoError(t, os.WriteFile(filename, content, 0644))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.corrupt != nil {
+				test.corrupt(t)
+			}
+
+			// Each pool stands for a process starting cold
+			pool := NewModulePool(NewRuntime(nil), 1, WithCompilationCache(cache))
+			module, err := pool.Checkout(context.Background(), &pbsubstreams.Request{}, "hash_a", code, "counter", "map_counter")
+			require.NoError(t, err)
+
+			memoryCounter, globalCounter, err := executeCounter(t, module, []byte("block"))
+			require.NoError(t, err)
+			assert.Equal(t, uint32(1), memoryCounter)
+			assert.Equal(t, uint32(1), globalCounter)
+
+			// The entry is always valid afterwards, rewritten when corrupt
+			assert.NotNil(t, cache.load(newWasmtimeEngine(), filename))
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// engine is the wasm runtime compiling and executing the code of the modules,
+// keeping the specifics of the runtime out of the modules and their
+// instances. wasmtime is its only implementation, see newWasmtimeEngine.
+//
+// Each module gets its own engine, interrupting an engine only interrupts
+// the calls of its module.
+type engine interface {
+	// cacheKey identifies the engine and its version in the compilation
+	// cache, the compiled code of an engine can only be loaded by the same
+	// one.
+	cacheKey() string
+
+	compile(wasmCode []byte) (compiledModule, error)
+	// deserialize loads code compiled and serialized by an engine with the
+	// same cache key.
+	deserialize(serialized []byte) (compiledModule, error)
+	// link resolves the imports of `module` to `functions`, instantiated
+	// later as many times as needed. An import without a function fails the
+	// instantiation.
+	link(module compiledModule, functions []hostFunction) (linkedModule, error)
+
+	// interrupt interrupts the calls running in the instances of the engine
+	// watched for interruption, see engineInstance.watchInterruption. They
+	// fail with a trap.
+	interrupt()
+}
+
+type compiledModule interface {
+	imports() []externType
+	exports() []externType
+	serialize() ([]byte, error)
+}
+
+type linkedModule interface {
+	// instantiate creates a new instance of the module, with its own memory
+	// and globals. It fails with errMemoryNotExported when the module does
+	// not export its memory.
+	instantiate() (engineInstance, error)
+}
+
+var errMemoryNotExported = errors.New("memory is not exported")
+
+type engineInstance interface {
+	// function returns the exported function `name`, nil when there is none.
+	function(name string) engineFunction
+
+	// memory returns the exported linear memory, writing to it writes to the
+	// guest memory. It is only valid until the next call or memory growth.
+	memory() []byte
+	memorySize() uint64
+	// growMemory grows the memory by `pages`, returning its previous size in
+	// pages.
+	growMemory(pages uint64) (uint64, error)
+	// snapshotGlobals returns a function restoring the mutable globals
+	// exported by the instance to their current values.
+	snapshotGlobals() (restore func() error)
+
+	// setFuel brings the fuel available to the calls to `level`, a call
+	// running out of fuel traps.
+	setFuel(level uint64) error
+	// fuelConsumed returns the fuel consumed since the instance was created.
+	fuelConsumed() uint64
+	// watchInterruption makes the calls of the instance interruptible by the
+	// engine, or not anymore.
+	watchInterruption(watch bool)
+}
+
+type engineFunction interface {
+	// call calls the function with `args`, of the Go types matching its
+	// parameters: int32, int64, float32 or float64. It returns its result,
+	// nil when it has none.
+	call(args ...interface{}) (interface{}, error)
+}
+
+// hostFunction is a function of the host imported by the modules. `fn` is a
+// Go function whose parameters and results are int32, int64, float32 or
+// float64, optionally followed by an error result: a non-nil error traps the
+// guest with its message.
+type hostFunction struct {
+	namespace string
+	name      string
+	fn        interface{}
+}
+
+type externKind int
+
+const (
+	externFunc externKind = iota
+	externMemory
+	externGlobal
+	externTable
+)
+
+// externType is a function, memory, global or table imported or exported by
+// a module. `namespace` is empty for the exports, `params` and `results` are
+// only set for the functions.
+type externType struct {
+	namespace string
+	name      string
+	kind      externKind
+	params    []valueKind
+	results   []valueKind
+}
+
+type valueKind int
+
+const (
+	valueI32 valueKind = iota
+	valueI64
+	valueF32
+	valueF64
+	valueOther // references and vectors, never used by the host functions
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case valueI32:
+		return "i32"
+	case valueI64:
+		return "i64"
+	case valueF32:
+		return "f32"
+	case valueF64:
+		return "f64"
+	}
+	return "other"
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+var goValueKinds = map[reflect.Type]valueKind{
+	reflect.TypeOf(int32(0)):   valueI32,
+	reflect.TypeOf(int64(0)):   valueI64,
+	reflect.TypeOf(float32(0)): valueF32,
+	reflect.TypeOf(float64(0)): valueF64,
+}
+
+var goValueTypes = map[valueKind]reflect.Type{
+	valueI32: reflect.TypeOf(int32(0)),
+	valueI64: reflect.TypeOf(int64(0)),
+	valueF32: reflect.TypeOf(float32(0)),
+	valueF64: reflect.TypeOf(float64(0)),
+}
+
+// signature returns the value kinds of the parameters and results of `fn`,
+// the function of a hostFunction, and whether it returns an error last.
+func (f hostFunction) signature() (params, results []valueKind, returnsError bool, err error) {
+	ty := reflect.TypeOf(f.fn)
+	if ty == nil || ty.Kind() != reflect.Func {
+		return nil, nil, false, fmt.Errorf("host function %s.%s is not a function", f.namespace, f.name)
+	}
+	for i := 0; i < ty.NumIn(); i++ {
+		kind, ok := goValueKinds[ty.In(i)]
+		if !ok {
+			return nil, nil, false, fmt.Errorf("host function %s.%s: unsupported parameter type %s", f.namespace, f.name, ty.In(i))
+		}
+		params = append(params, kind)
+	}
+	for i := 0; i < ty.NumOut(); i++ {
+		if i == ty.NumOut()-1 && ty.Out(i) == errorType {
+			returnsError = true
+			break
+		}
+		kind, ok := goValueKinds[ty.Out(i)]
+		if !ok {
+			return nil, nil, false, fmt.Errorf("host function %s.%s: unsupported result type %s", f.namespace, f.name, ty.Out(i))
+		}
+		results = append(results, kind)
+	}
+	return params, results, returnsError, nil
+}
+
+// trappingFunction returns a host function with the signature of the
+// imported function `imported`, trapping with `cause` whenever it is called.
+func trappingFunction(imported externType, cause error) (hostFunction, error) {
+	in := make([]reflect.Type, len(imported.params))
+	for i, kind := range imported.params {
+		if in[i] = goValueTypes[kind]; in[i] == nil {
+			return hostFunction{}, fmt.Errorf("unsupported %s parameter", kind)
+		}
+	}
+	out := make([]reflect.Type, len(imported.results), len(imported.results)+1)
+	for i, kind := range imported.results {
+		if out[i] = goValueTypes[kind]; out[i] == nil {
+			return hostFunction{}, fmt.Errorf("unsupported %s result", kind)
+		}
+	}
+	out = append(out, errorType)
+
+	fn := reflect.MakeFunc(reflect.FuncOf(in, out, false), func([]reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, len(out))
+		for i, ty := range out[:len(out)-1] {
+			results[i] = reflect.Zero(ty)
+		}
+		results[len(out)-1] = reflect.ValueOf(&cause).Elem()
+		return results
+	})
+	return hostFunction{namespace: imported.namespace, name: imported.name, fn: fn.Interface()}, nil
+}
@@ -0,0 +1,316 @@
+package wasm
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/bytecodealliance/wasmtime-go"
+)
+
+const wasmtimeModulePath = "github.com/bytecodealliance/wasmtime-go"
+
+// noEpochDeadline is the epoch deadline of the wasm store outside of the
+// calls watched for interruption.
+const noEpochDeadline = 1 << 62
+
+type wasmtimeEngine struct {
+	engine *wasmtime.Engine
+}
+
+// newWasmtimeEngine returns an engine metering the instructions executed by
+// the wasm code, so the execution budget of a module is the same on any
+// hardware, and able to interrupt it through epochs: an epoch increment
+// interrupts every store of the engine.
+func newWasmtimeEngine() engine {
+	config := wasmtime.NewConfig()
+	config.SetConsumeFuel(true)
+	config.SetEpochInterruption(true)
+	return &wasmtimeEngine{engine: wasmtime.NewEngineWithConfig(config)}
+}
+
+func (e *wasmtimeEngine) cacheKey() string {
+	return "wasmtime-" + wasmtimeVersion()
+}
+
+func (e *wasmtimeEngine) compile(wasmCode []byte) (compiledModule, error) {
+	module, err := wasmtime.NewModule(e.engine, wasmCode)
+	if err != nil {
+		return nil, err
+	}
+	return &wasmtimeModule{module: module}, nil
+}
+
+func (e *wasmtimeEngine) deserialize(serialized []byte) (compiledModule, error) {
+	module, err := wasmtime.NewModuleDeserialize(e.engine, serialized)
+	if err != nil {
+		return nil, err
+	}
+	return &wasmtimeModule{module: module}, nil
+}
+
+func (e *wasmtimeEngine) link(module compiledModule, functions []hostFunction) (linkedModule, error) {
+	linker := wasmtime.NewLinker(e.engine)
+	for _, f := range functions {
+		ty, callback, err := wasmtimeCallback(f)
+		if err != nil {
+			return nil, err
+		}
+		if err := linker.FuncNew(f.namespace, f.name, ty, callback); err != nil {
+			return nil, fmt.Errorf("defining %s.%s: %w", f.namespace, f.name, err)
+		}
+	}
+	return &wasmtimeLinked{engine: e.engine, linker: linker, module: module.(*wasmtimeModule).module}, nil
+}
+
+func (e *wasmtimeEngine) interrupt() {
+	e.engine.IncrementEpoch()
+}
+
+// wasmtimeCallback adapts the function of `f` to the linker, calling it with
+// the arguments of the guest.
+func wasmtimeCallback(f hostFunction) (*wasmtime.FuncType, func(*wasmtime.Caller, []wasmtime.Val) ([]wasmtime.Val, *wasmtime.Trap), error) {
+	params, results, returnsError, err := f.signature()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fn := reflect.ValueOf(f.fn)
+	callback := func(_ *wasmtime.Caller, args []wasmtime.Val) ([]wasmtime.Val, *wasmtime.Trap) {
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			in[i] = reflect.ValueOf(arg.Get())
+		}
+		out := fn.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nil, wasmtime.NewTrap(err.Error())
+			}
+			out = out[:len(out)-1]
+		}
+
+		vals := make([]wasmtime.Val, len(out))
+		for i, value := range out {
+			switch results[i] {
+			case valueI32:
+				vals[i] = wasmtime.ValI32(int32(value.Int()))
+			case valueI64:
+				vals[i] = wasmtime.ValI64(value.Int())
+			case valueF32:
+				vals[i] = wasmtime.ValF32(float32(value.Float()))
+			case valueF64:
+				vals[i] = wasmtime.ValF64(value.Float())
+			}
+		}
+		return vals, nil
+	}
+	return wasmtime.NewFuncType(wasmtimeValTypes(params), wasmtimeValTypes(results)), callback, nil
+}
+
+func wasmtimeValTypes(kinds []valueKind) []*wasmtime.ValType {
+	types := make([]*wasmtime.ValType, len(kinds))
+	for i, kind := range kinds {
+		switch kind {
+		case valueI32:
+			types[i] = wasmtime.NewValType(wasmtime.KindI32)
+		case valueI64:
+			types[i] = wasmtime.NewValType(wasmtime.KindI64)
+		case valueF32:
+			types[i] = wasmtime.NewValType(wasmtime.KindF32)
+		case valueF64:
+			types[i] = wasmtime.NewValType(wasmtime.KindF64)
+		}
+	}
+	return types
+}
+
+type wasmtimeModule struct {
+	module *wasmtime.Module
+}
+
+func (m *wasmtimeModule) imports() []externType {
+	imports := m.module.Imports()
+	out := make([]externType, 0, len(imports))
+	for _, imported := range imports {
+		name := ""
+		if imported.Name() != nil {
+			name = *imported.Name()
+		}
+		out = append(out, wasmtimeExternType(imported.Module(), name, imported.Type()))
+	}
+	return out
+}
+
+func (m *wasmtimeModule) exports() []externType {
+	exports := m.module.Exports()
+	out := make([]externType, 0, len(exports))
+	for _, export := range exports {
+		out = append(out, wasmtimeExternType("", export.Name(), export.Type()))
+	}
+	return out
+}
+
+func (m *wasmtimeModule) serialize() ([]byte, error) {
+	return m.module.Serialize()
+}
+
+func wasmtimeExternType(namespace, name string, ty *wasmtime.ExternType) externType {
+	out := externType{namespace: namespace, name: name}
+	switch {
+	case ty.FuncType() != nil:
+		out.kind = externFunc
+		out.params = wasmtimeValueKinds(ty.FuncType().Params())
+		out.results = wasmtimeValueKinds(ty.FuncType().Results())
+	case ty.MemoryType() != nil:
+		out.kind = externMemory
+	case ty.GlobalType() != nil:
+		out.kind = externGlobal
+	default:
+		out.kind = externTable
+	}
+	return out
+}
+
+func wasmtimeValueKinds(types []*wasmtime.ValType) []valueKind {
+	kinds := make([]valueKind, len(types))
+	for i, ty := range types {
+		switch ty.Kind() {
+		case wasmtime.KindI32:
+			kinds[i] = valueI32
+		case wasmtime.KindI64:
+			kinds[i] = valueI64
+		case wasmtime.KindF32:
+			kinds[i] = valueF32
+		case wasmtime.KindF64:
+			kinds[i] = valueF64
+		default:
+			kinds[i] = valueOther
+		}
+	}
+	return kinds
+}
+
+type wasmtimeLinked struct {
+	engine *wasmtime.Engine
+	linker *wasmtime.Linker
+	module *wasmtime.Module
+}
+
+// instantiate creates the instance in its own store, so the previous one can
+// be released.
+func (l *wasmtimeLinked) instantiate() (engineInstance, error) {
+	store := wasmtime.NewStore(l.engine)
+	if err := store.AddFuel(unlimitedFuel); err != nil {
+		return nil, fmt.Errorf("adding fuel: %w", err)
+	}
+	store.SetEpochDeadline(noEpochDeadline)
+	instance, err := l.linker.Instantiate(store, l.module)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryExport := instance.GetExport(store, "memory")
+	if memoryExport == nil || memoryExport.Memory() == nil {
+		return nil, errMemoryNotExported
+	}
+	return &wasmtimeInstance{store: store, instance: instance, mem: memoryExport.Memory()}, nil
+}
+
+type wasmtimeInstance struct {
+	store    *wasmtime.Store
+	instance *wasmtime.Instance
+	mem      *wasmtime.Memory
+}
+
+func (i *wasmtimeInstance) function(name string) engineFunction {
+	fn := i.instance.GetFunc(i.store, name)
+	if fn == nil {
+		return nil
+	}
+	return &wasmtimeFunction{fn: fn, store: i.store}
+}
+
+func (i *wasmtimeInstance) memory() []byte {
+	return i.mem.UnsafeData(i.store)
+}
+
+func (i *wasmtimeInstance) memorySize() uint64 {
+	return uint64(i.mem.DataSize(i.store))
+}
+
+func (i *wasmtimeInstance) growMemory(pages uint64) (uint64, error) {
+	return i.mem.Grow(i.store, pages)
+}
+
+func (i *wasmtimeInstance) snapshotGlobals() (restore func() error) {
+	var globals []*wasmtime.Global
+	var values []wasmtime.Val
+	for _, export := range i.instance.Exports(i.store) {
+		if global := export.Global(); global != nil && global.Type(i.store).Mutable() {
+			globals = append(globals, global)
+			values = append(values, global.Get(i.store))
+		}
+	}
+	return func() error {
+		for index, global := range globals {
+			if err := global.Set(i.store, values[index]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (i *wasmtimeInstance) setFuel(level uint64) error {
+	remaining, err := i.store.ConsumeFuel(0)
+	if err != nil {
+		return fmt.Errorf("getting remaining fuel: %w", err)
+	}
+	if remaining < level {
+		return i.store.AddFuel(level - remaining)
+	}
+	if remaining > level {
+		_, err = i.store.ConsumeFuel(remaining - level)
+	}
+	return err
+}
+
+func (i *wasmtimeInstance) fuelConsumed() uint64 {
+	consumed, _ := i.store.FuelConsumed()
+	return consumed
+}
+
+func (i *wasmtimeInstance) watchInterruption(watch bool) {
+	if watch {
+		i.store.SetEpochDeadline(1)
+	} else {
+		i.store.SetEpochDeadline(noEpochDeadline)
+	}
+}
+
+type wasmtimeFunction struct {
+	fn    *wasmtime.Func
+	store *wasmtime.Store
+}
+
+func (f *wasmtimeFunction) call(args ...interface{}) (interface{}, error) {
+	return f.fn.Call(f.store, args...)
+}
+
+// wasmtimeVersion returns the version of the wasmtime bindings the binary was
+// built with, "unknown" when the build info is not available.
+func wasmtimeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == wasmtimeModulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,59 @@
+package wasm
+
+import (
+	"fmt"
+)
+
+// OutOfBoundsError is returned when a guest passes to a host function a
+// pointer and a length which are not in its memory. It only depends on the
+// wasm code and its inputs, so the same call fails the same way everywhere.
+type OutOfBoundsError struct {
+	Module     string
+	Call       string // the host function
+	Ptr        uint32
+	Length     uint32
+	MemorySize uint64
+}
+
+func (e *OutOfBoundsError) Error() string {
+	return fmt.Sprintf("module %q passed out-of-bounds pointer to %s: %d bytes at %d, memory is %d bytes", e.Module, e.Call, e.Length, e.Ptr, e.MemorySize)
+}
+
+func (m *Module) outOfBounds(call string, ptr, length int32) *OutOfBoundsError {
+	return &OutOfBoundsError{Module: m.name, Call: call, Ptr: uint32(ptr), Length: uint32(length), MemorySize: m.MemorySize()}
+}
+
+// readGuestBytes returns the `length` bytes at `ptr` the guest passed to the
+// host function `call`, or an OutOfBoundsError trapping the guest when they
+// are not all in its memory. The bytes returned are the guest memory itself.
+func (m *Module) readGuestBytes(call string, ptr, length int32) ([]byte, error) {
+	data, ok := m.Heap.TryReadBytes(ptr, length)
+	if !ok {
+		return nil, m.outOfBounds(call, ptr, length)
+	}
+	return data, nil
+}
+
+// readGuestString is readGuestBytes for strings, copied out of the guest
+// memory.
+func (m *Module) readGuestString(call string, ptr, length int32) (string, error) {
+	data, trap := m.readGuestBytes(call, ptr, length)
+	if trap != nil {
+		return "", trap
+	}
+	return string(data), nil
+}
+
+// readKeyValue reads the key and the value the guest passed to the store
+// host function `call`.
+func (m *Module) readKeyValue(call string, keyPtr, keyLength, valPtr, valLength int32) (string, []byte, error) {
+	key, trap := m.readGuestString(call, keyPtr, keyLength)
+	if trap != nil {
+		return "", nil, trap
+	}
+	value, trap := m.readGuestBytes(call, valPtr, valLength)
+	if trap != nil {
+		return "", nil, trap
+	}
+	return key, value, nil
+}
@@ -0,0 +1,103 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outOfBoundsWAT passes the key of `len` bytes at `ptr` to `set` with
+// `store_set`, and asks `get_last` to write its result at `outputPtr` with
+// `map_get`.
+const outOfBoundsWAT = `
+(module
+  (import "state" "set" (func $set (param i64 i32 i32 i32 i32)))
+  (import "state" "get_last" (func $get_last (param i32 i32 i32 i32) (result i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "key")
+  (data (i32.const 16) "v")
+  (global $top (mut i32) (i32.const 1024))
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $top))
+    (global.set $top (i32.add (global.get $top) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "store_set") (param $ptr i32) (param $len i32)
+    (call $set (i64.const 1) (local.get $ptr) (local.get $len) (i32.const 16) (i32.const 1)))
+  (func (export "map_get") (param $store i32) (param $outputPtr i32)
+    (drop (call $get_last (local.get $store) (i32.const 0) (i32.const 3) (local.get $outputPtr))))
+)
+`
+
+func TestModule_OutOfBoundsPointers(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(outOfBoundsWAT)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	store := newTestStore(t)
+	store.SetBytes(1, "key", []byte("value"))
+
+	tests := []struct {
+		name          string
+		entrypoint    string
+		args          []interface{}
+		expectedError string
+	}{
+		{"valid key", "store_set", []interface{}{int32(0), int32(3)}, ""},
+		{"key past the end of memory", "store_set", []interface{}{int32(-2), int32(3)}, `module "test" passed out-of-bounds pointer to state.set: 3 bytes at 4294967294, memory is`},
+		{"negative length", "store_set", []interface{}{int32(0), int32(-1)}, `module "test" passed out-of-bounds pointer to state.set: 4294967295 bytes at 0, memory is`},
+		{"valid output", "map_get", []interface{}{int32(0), int32(64)}, ""},
+		{"output past the end", "map_get", []interface{}{int32(0), int32(-4)}, `module "test" passed out-of-bounds pointer to state.get_last: 8 bytes at 4294967292, memory is`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "test", test.entrypoint)
+			require.NoError(t, err)
+			var inputs []*Input
+			if test.entrypoint == "store_set" {
+				inputs = []*Input{{Type: OutputStore, Name: "out", Store: newTestStore(t), UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET}}
+			} else {
+				inputs = []*Input{{Type: InputStore, Name: "in", Store: store}}
+			}
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, inputs)
+			require.NoError(t, err)
+
+			err = instance.ExecuteWithArgs(ctx, test.args...)
+			if test.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func FuzzModule_ReadGuestBytes(f *testing.F) {
+	module := newLoopModule(f)
+	for _, seed := range [][2]int32{{0, 0}, {0, 16}, {wasmPageSize - 1, 1}, {wasmPageSize, 1}, {-1, 1}, {1, -1}, {-8, 16}} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, ptr, length int32) {
+		inBounds := uint64(uint32(ptr))+uint64(uint32(length)) <= module.MemorySize()
+
+		data, trap := module.readGuestBytes("test", ptr, length)
+		if inBounds {
+			require.Nil(t, trap)
+			assert.Len(t, data, int(uint32(length)))
+		} else {
+			require.NotNil(t, trap)
+			assert.Contains(t, trap.Error(), `module "loop" passed out-of-bounds pointer to test`)
+		}
+
+		_, err := module.Heap.WriteAtPtr(make([]byte, uint32(length)%64), ptr, "test")
+		fits := uint64(uint32(ptr))+uint64(uint32(length)%64) <= module.MemorySize()
+		assert.Equal(t, fits, err == nil)
+	})
+}
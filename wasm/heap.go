@@ -3,29 +3,42 @@ package wasm
 import (
 	"fmt"
 	"sort"
-
-	"github.com/bytecodealliance/wasmtime-go"
 )
 
+// defaultArenaSize is the size of the region of guest memory where the host
+// writes the inputs of a call, larger inputs go through the guest allocator.
+const defaultArenaSize = wasmPageSize
+
+// arenaAlignment keeps the values written to the arena aligned for the guest.
+const arenaAlignment = 8
+
 type allocation struct {
 	ptr    int32
 	length int
 }
 
+// Heap writes the values of the host to the guest memory. The values owned by
+// the host for the duration of a call are bumped into an arena, a region of
+// guest memory out of reach of the guest allocator, freed all at once by
+// Clear. The other ones, and the ones not fitting in the arena, are allocated
+// with the guest allocator.
 type Heap struct {
-	allocations []*allocation
-	memory      *wasmtime.Memory
-	allocator   *wasmtime.Func
-	dealloc     *wasmtime.Func
-	store       *wasmtime.Store
+	allocations []*allocation // made with the guest allocator and tracked
+	arenaBase   int32
+	arenaSize   int32 // 0 when no arena could be reserved
+	arenaOffset int32
+	instance    engineInstance
+	allocator   engineFunction
+	dealloc     engineFunction
+
+	bytesWritten uint64 // since the last call, see Instance.observeCall
 }
 
-func NewHeap(memory *wasmtime.Memory, allocator, dealloc *wasmtime.Func, store *wasmtime.Store) *Heap {
+func newHeap(instance engineInstance, allocator, dealloc engineFunction) *Heap {
 	return &Heap{
-		memory:    memory,
+		instance:  instance,
 		allocator: allocator,
 		dealloc:   dealloc,
-		store:     store,
 	}
 }
 
@@ -33,9 +46,18 @@ func (h *Heap) Write(bytes []byte, from string) (int32, error) {
 	return h.WriteAndTrack(bytes, true, from)
 }
 
+// WriteAndTrack writes `bytes` to the guest memory. When `track` is set, the
+// value is owned by the host and freed by Clear, otherwise its ownership goes
+// to the guest, which frees it with its own allocator.
 func (h *Heap) WriteAndTrack(bytes []byte, track bool, from string) (int32, error) {
 	size := len(bytes)
-	results, err := h.allocator.Call(h.store, int32(size))
+	if track {
+		if ptr, ok := h.arenaAlloc(size); ok {
+			return h.WriteAtPtr(bytes, ptr, from)
+		}
+	}
+
+	results, err := h.allocator.call(int32(size))
 	if err != nil {
 		return 0, fmt.Errorf("allocating memory for size %d:%w", size, err)
 	}
@@ -47,18 +69,85 @@ func (h *Heap) WriteAndTrack(bytes []byte, track bool, from string) (int32, erro
 	return h.WriteAtPtr(bytes, ptr, from)
 }
 
+// WriteBatch writes `values` one after the other in a single region of the
+// guest memory, owned by the host and freed by Clear like the values written
+// with Write. The region comes from the arena when it fits, from a single
+// call to the guest allocator otherwise. It returns the pointer of each value.
+func (h *Heap) WriteBatch(values [][]byte, from string) ([]int32, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	size := 0
+	for _, value := range values {
+		size += alignedSize(len(value))
+	}
+
+	base, ok := h.arenaAlloc(size)
+	if !ok {
+		results, err := h.allocator.call(int32(size))
+		if err != nil {
+			return nil, fmt.Errorf("allocating memory for size %d:%w", size, err)
+		}
+		base = results.(int32)
+		h.allocations = append(h.allocations, &allocation{ptr: base, length: size})
+	}
+
+	ptrs := make([]int32, len(values))
+	ptr := base
+	for i, value := range values {
+		ptrs[i], _ = h.WriteAtPtr(value, ptr, from)
+		ptr += int32(alignedSize(len(value)))
+	}
+	return ptrs, nil
+}
+
+// reserveArena grows the guest memory by `size` bytes, rounded up to whole
+// pages, for the arena. The guest allocator never hands out pages it did not
+// grow itself. The arena stays disabled when the memory cannot grow.
+func (h *Heap) reserveArena(size int) {
+	pages := (size + wasmPageSize - 1) / wasmPageSize
+	previousPages, err := h.instance.growMemory(uint64(pages))
+	if err != nil {
+		return
+	}
+	h.arenaBase = int32(previousPages * wasmPageSize)
+	h.arenaSize = int32(pages * wasmPageSize)
+	h.arenaOffset = 0
+}
+
+func (h *Heap) arenaAlloc(size int) (int32, bool) {
+	aligned := alignedSize(size)
+	if aligned > int(h.arenaSize-h.arenaOffset) {
+		return 0, false
+	}
+	ptr := h.arenaBase + h.arenaOffset
+	h.arenaOffset += int32(aligned)
+	return ptr, true
+}
+
+func alignedSize(size int) int {
+	return (size + arenaAlignment - 1) &^ (arenaAlignment - 1)
+}
+
 func (h *Heap) WriteAtPtr(bytes []byte, ptr int32, from string) (int32, error) {
-	data := h.memory.UnsafeData(h.store)
-	copy(data[ptr:], bytes)
+	data, ok := h.TryReadBytes(ptr, int32(len(bytes)))
+	if !ok {
+		return 0, fmt.Errorf("writing %d bytes at ptr %d for %s: out of the wasm memory", len(bytes), uint32(ptr), from)
+	}
+	copy(data, bytes)
+	h.bytesWritten += uint64(len(bytes))
 	return ptr, nil
 }
 
+// Clear frees the values owned by the host: the arena is reset at once, only
+// the values which did not fit in it are given back to the guest allocator.
 func (h *Heap) Clear() error {
+	h.arenaOffset = 0
 	sort.Slice(h.allocations, func(i, j int) bool {
 		return h.allocations[i].ptr < h.allocations[j].ptr
 	})
 	for _, a := range h.allocations {
-		if _, err := h.dealloc.Call(h.store, a.ptr, int32(a.length)); err != nil {
+		if _, err := h.dealloc.call(a.ptr, int32(a.length)); err != nil {
 			return fmt.Errorf("deallocating memory at ptr %d: %w", a.ptr, err)
 		}
 	}
@@ -66,16 +155,36 @@ func (h *Heap) Clear() error {
 	return nil
 }
 
+// reset forgets about the tracked allocations, used when the whole guest
+// memory has been restored.
+func (h *Heap) reset() {
+	h.allocations = nil
+	h.arenaOffset = 0
+}
+
 func (h *Heap) ReadString(ptr int32, length int32) string {
 	data := h.ReadBytes(ptr, length)
 	return string(data)
 }
 
 func (h *Heap) ReadBytes(ptr int32, length int32) []byte {
-	data := h.memory.UnsafeData(h.store)
+	data := h.instance.memory()
 	return data[ptr : ptr+length]
 }
 
+// TryReadBytes reads `length` bytes at `ptr`, both unsigned for the guest,
+// returning false instead of panicking when they are not in the guest memory.
+// The bytes returned are the guest memory itself, writing to them writes to
+// it.
+func (h *Heap) TryReadBytes(ptr int32, length int32) ([]byte, bool) {
+	data := h.instance.memory()
+	start, end := uint64(uint32(ptr)), uint64(uint32(ptr))+uint64(uint32(length))
+	if end > uint64(len(data)) {
+		return nil, false
+	}
+	return data[start:end], true
+}
+
 //func (h *Heap) PrintMem() {
 //	data := h.memory.Data()
 //	for i, datum := range data {
@@ -0,0 +1,204 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLoopModule(t testing.TB) *Module {
+	t.Helper()
+
+	code, err := wasmtime.Wat2Wasm(loopWAT)
+	require.NoError(t, err)
+	module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "loop", "map_loop")
+	require.NoError(t, err)
+	return module
+}
+
+func TestHeap_Arena(t *testing.T) {
+	heap := newLoopModule(t).Heap
+	require.Equal(t, int32(defaultArenaSize), heap.arenaSize)
+	base := heap.arenaBase
+	assert.Equal(t, int32(wasmPageSize), base, "reserved over the initial page")
+
+	ptr, err := heap.Write([]byte("abc"), "test")
+	require.NoError(t, err)
+	assert.Equal(t, base, ptr)
+	ptr, err = heap.Write([]byte("defgh"), "test")
+	require.NoError(t, err)
+	assert.Equal(t, base+arenaAlignment, ptr)
+	assert.Equal(t, "defgh", heap.ReadString(ptr, 5))
+
+	// Values owned by the guest go through its allocator
+	ptr, err = heap.WriteAndTrack([]byte("output"), false, "test")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1024), ptr)
+
+	// So do values larger than the arena
+	ptr, err = heap.Write(make([]byte, defaultArenaSize+1), "test")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1024), ptr)
+	assert.Len(t, heap.allocations, 1)
+
+	require.NoError(t, heap.Clear())
+	assert.Empty(t, heap.allocations)
+	ptr, err = heap.Write([]byte("abc"), "test")
+	require.NoError(t, err)
+	assert.Equal(t, base, ptr)
+}
+
+func TestHeap_NoArenaWhenMemoryCannotGrow(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(loopWAT)
+	require.NoError(t, err)
+	limited, err := LimitMemory(code, wasmPageSize)
+	require.NoError(t, err)
+	module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, limited, "loop", "map_loop")
+	require.NoError(t, err)
+
+	assert.Zero(t, module.Heap.arenaSize)
+	ptr, err := module.Heap.Write([]byte("abc"), "test")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1024), ptr)
+}
+
+// bumpWAT allocates from the pages it grows itself, after the arena, and
+// frees all its allocations at once on any `dealloc`.
+const bumpWAT = `
+(module
+  (memory (export "memory") 1)
+  (global $base (mut i32) (i32.const 0))
+  (global $top (mut i32) (i32.const 0))
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local $end i32)
+    (if (i32.eqz (global.get $base))
+      (then
+        (global.set $base (i32.mul (memory.size) (i32.const 65536)))
+        (global.set $top (global.get $base))))
+    (local.set $ptr (global.get $top))
+    (local.set $end (i32.add (local.get $ptr) (local.get $size)))
+    (if (i32.gt_u (local.get $end) (i32.mul (memory.size) (i32.const 65536)))
+      (then
+        (drop (memory.grow (i32.div_u
+          (i32.add (i32.sub (local.get $end) (i32.mul (memory.size) (i32.const 65536))) (i32.const 65535))
+          (i32.const 65536))))))
+    (global.set $top (local.get $end))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32)
+    (global.set $top (global.get $base)))
+  (func (export "map_inputs") (param i32 i32 i32 i32 i32))
+)
+`
+
+func newBumpModule(t testing.TB, opts ...RuntimeOption) *Module {
+	t.Helper()
+
+	code, err := wasmtime.Wat2Wasm(bumpWAT)
+	require.NoError(t, err)
+	module, err := NewRuntime(nil, opts...).NewModule(context.Background(), &pbsubstreams.Request{}, code, "inputs", "map_inputs")
+	require.NoError(t, err)
+	return module
+}
+
+func TestModule_WriteInputs(t *testing.T) {
+	tests := []struct {
+		name                string
+		opts                []RuntimeOption
+		blockSize           int
+		expectedAllocations int
+		expectContiguous    bool
+	}{
+		{"batch in arena", nil, 10, 0, true},
+		{"batch", nil, defaultArenaSize + 1, 1, true},
+		{"per input in arena", []RuntimeOption{WithPerInputWrites()}, 10, 0, true},
+		{"per input", []RuntimeOption{WithPerInputWrites()}, defaultArenaSize + 1, 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module := newBumpModule(t, test.opts...)
+			block := bytes.Repeat([]byte{'b'}, test.blockSize)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{
+				{Type: InputSource, Name: "block", StreamData: block},
+				{Type: InputStore, Name: "store", Store: newTestStore(t)},
+				{Type: InputStoreDeltas, Name: "deltas", StreamData: []byte("deltas")},
+			})
+			require.NoError(t, err)
+
+			require.Len(t, instance.args, 5)
+			assert.Equal(t, int32(len(block)), instance.args[1])
+			assert.Equal(t, int32(0), instance.args[2])
+			assert.Equal(t, int32(6), instance.args[4])
+			assert.Equal(t, block, module.Heap.ReadBytes(instance.args[0].(int32), int32(len(block))))
+			assert.Equal(t, "deltas", module.Heap.ReadString(instance.args[3].(int32), 6))
+			assert.Len(t, module.Heap.allocations, test.expectedAllocations)
+			contiguous := instance.args[3].(int32) == instance.args[0].(int32)+int32(alignedSize(len(block)))
+			assert.Equal(t, test.expectContiguous, contiguous)
+			require.NoError(t, instance.Execute(context.Background()))
+		})
+	}
+}
+
+// BenchmarkModule_WriteInputs prepares the arguments of a module taking a
+// block of 10 MB and two store deltas of 1 MB.
+func BenchmarkModule_WriteInputs(b *testing.B) {
+	inputs := []*Input{
+		{Type: InputSource, Name: "block", StreamData: make([]byte, 10*1024*1024)},
+		{Type: InputStoreDeltas, Name: "deltas_a", StreamData: make([]byte, 1024*1024)},
+		{Type: InputStoreDeltas, Name: "deltas_b", StreamData: make([]byte, 1024*1024)},
+	}
+
+	for _, test := range []struct {
+		name string
+		opts []RuntimeOption
+	}{{"batch", nil}, {"per_input", []RuntimeOption{WithPerInputWrites()}}} {
+		b.Run(test.name, func(b *testing.B) {
+			module := newBumpModule(b, test.opts...)
+			clock := &pbsubstreams.Clock{}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := module.NewInstance(clock, inputs); err != nil {
+					b.Fatal(err)
+				}
+				if err := module.Heap.Clear(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkHeap_SmallWrites writes many small inputs, like the keys and
+// values of a store, then frees them as done between two blocks.
+func BenchmarkHeap_SmallWrites(b *testing.B) {
+	value := make([]byte, 32)
+
+	for _, test := range []struct {
+		name  string
+		arena bool
+	}{{"arena", true}, {"guest_allocator", false}} {
+		b.Run(test.name, func(b *testing.B) {
+			heap := newLoopModule(b).Heap
+			if !test.arena {
+				heap.arenaSize = 0
+			}
+
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < 1000; j++ {
+					if _, err := heap.Write(value, "bench"); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := heap.Clear(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
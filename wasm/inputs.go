@@ -11,6 +11,7 @@ const (
 	InputSource InputType = iota
 	InputStore
 	OutputStore
+	InputStoreDeltas // the deltas of an upstream store for the current block, instead of an access to the store
 )
 
 type Input struct {
@@ -20,9 +21,8 @@ type Input struct {
 	// Transient data between calls
 	StreamData []byte
 
-	// InputType == InputStore || OutputStore
-	Store  *state.Store
-	Deltas bool // whether we want to have the Deltas instead of an access to the store
+	// InputType == InputStore || InputStoreDeltas || OutputStore
+	Store *state.Store
 
 	// If InputType == OutputStore
 	UpdatePolicy pbsubstreams.Module_KindStore_UpdatePolicy
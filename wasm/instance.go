@@ -1,10 +1,12 @@
 package wasm
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/bytecodealliance/wasmtime-go"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/state"
 )
@@ -14,6 +16,11 @@ type Instance struct {
 	outputStore  *state.Store
 	updatePolicy pbsubstreams.Module_KindStore_UpdatePolicy
 
+	// Names of the store inputs, for the errors of the store operations
+	inputStoreNames  []string // indexed like inputStores
+	deltasStoreNames []string
+	outputStoreName  string
+
 	valueType string
 
 	clock *pbsubstreams.Clock
@@ -21,16 +28,76 @@ type Instance struct {
 	args        []interface{} // to the `entrypoint` function
 	returnValue []byte
 	panicError  *PanicError
+	skipped     bool // the guest called `skip_block`
 
 	Logs           []string
+	LogLevels      []pbsubstreams.LogLevel // level of each of the logs
 	LogsByteCount  uint64
 	ExecutionStack []string
 	Module         *Module
-	entrypoint     *wasmtime.Func
+	entrypoint     engineFunction
+
+	// DroppedLogs and DroppedLogsByteCount count the logs dropped once the
+	// logs reached their maximum size
+	DroppedLogs          uint64
+	DroppedLogsByteCount uint64
+	maxLogByteCount      uint64
+
+	// Deterministic random sequence of the WASI `random_get`, see fillRandom
+	randomCounter uint64
+	randomBuffer  []byte
+
+	storeReads map[storeRead]storeReadResult // nil when the store reads are not cached
+
+	// traceHostCalls is set in development mode, the host calls are then
+	// recorded in the execution stack, see traceHostCall
+	traceHostCalls bool
+	hostCallTraces int
+
+	closed bool
+}
+
+// maxHostCallTraces caps the number of host calls recorded in the execution
+// stack of an instance.
+const maxHostCallTraces = 1000
+
+// Value sizes of traceHostCall for the calls without a value
+const (
+	traceNoValue  = -1
+	traceNotFound = -2
+)
+
+// ExecutionBudgetExceededError is returned when a call runs out of the fuel
+// allowed by the limit of its module. The fuel consumed only depends on the
+// wasm code and its inputs, so the same call fails the same way everywhere the
+// same limit is configured.
+type ExecutionBudgetExceededError struct {
+	Consumed uint64
+	Limit    uint64
 }
 
-func (i *Instance) Execute() (err error) {
-	if _, err = i.entrypoint.Call(i.Module.wasmStore, i.args...); err != nil {
+func (e *ExecutionBudgetExceededError) Error() string {
+	return fmt.Sprintf("execution budget exceeded: %d fuel consumed, limit is %d", e.Consumed, e.Limit)
+}
+
+// InterruptedError is returned when a call is interrupted because its context
+// is done. Unlike the other failures, it does not depend on the wasm code and
+// its inputs, the same call can succeed when retried.
+type InterruptedError struct {
+	Cause error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("execution interrupted: %s", e.Cause)
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Cause
+}
+
+// Execute calls the entrypoint, interrupting it once `ctx` is done.
+func (i *Instance) Execute(ctx context.Context) (err error) {
+	if err = i.call(ctx, i.args); err != nil {
 		if i.panicError != nil {
 			return i.panicError
 		}
@@ -39,8 +106,8 @@ func (i *Instance) Execute() (err error) {
 	return nil
 }
 
-func (i *Instance) ExecuteWithArgs(args ...interface{}) (err error) {
-	if _, err = i.entrypoint.Call(i.Module.wasmStore, args...); err != nil {
+func (i *Instance) ExecuteWithArgs(ctx context.Context, args ...interface{}) (err error) {
+	if err = i.call(ctx, args); err != nil {
 		if i.panicError != nil {
 			return i.panicError
 		}
@@ -49,7 +116,77 @@ func (i *Instance) ExecuteWithArgs(args ...interface{}) (err error) {
 	return nil
 }
 
+// call calls the entrypoint with the fuel allowed by the limit of the module,
+// measuring the fuel it consumed.
+func (i *Instance) call(ctx context.Context, args []interface{}) error {
+	m := i.Module
+	if err := ctx.Err(); err != nil {
+		return &InterruptedError{Cause: err}
+	}
+
+	m.callCount++
+	m.clean = false
+	m.lastFuelConsumed = 0
+	m.currentInstance = i
+	defer func() { m.currentInstance = nil }()
+
+	budget := uint64(unlimitedFuel)
+	if m.fuelLimit != 0 {
+		budget = m.fuelLimit
+	}
+	if err := m.wasmInstance.setFuel(budget); err != nil {
+		m.instanceFailed = true
+		return fmt.Errorf("setting fuel: %w", err)
+	}
+
+	consumedBefore := m.wasmInstance.fuelConsumed()
+	droppedBefore := i.DroppedLogs
+	start := time.Now()
+	stopWatching := m.watchInterruption(ctx)
+	_, err := i.entrypoint.call(args...)
+	stopWatching()
+	consumedAfter := m.wasmInstance.fuelConsumed()
+	m.lastFuelConsumed = consumedAfter - consumedBefore
+	i.observeCall(start, i.DroppedLogs-droppedBefore)
+
+	if err != nil && i.skipped {
+		// Trapped on purpose, the instance is still usable: it is reset
+		// before the next call anyway
+		i.returnValue = nil
+		return nil
+	}
+	if err != nil {
+		m.instanceFailed = true
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return &InterruptedError{Cause: ctxErr}
+		}
+		if i.panicError != nil {
+			return err
+		}
+		if m.fuelLimit != 0 && m.lastFuelConsumed >= m.fuelLimit {
+			return &ExecutionBudgetExceededError{Consumed: m.lastFuelConsumed, Limit: m.fuelLimit}
+		}
+		if m.maxMemorySize != 0 && m.MemorySize()+wasmPageSize > m.maxMemorySize {
+			// The guest allocator aborts when it cannot grow the memory
+			return &MemoryLimitError{Limit: m.maxMemorySize}
+		}
+		return err
+	}
+
+	if err := m.wasmInstance.setFuel(unlimitedFuel); err != nil {
+		m.instanceFailed = true
+		return fmt.Errorf("setting fuel: %w", err)
+	}
+	return nil
+}
+
+// WriteOutputToHeap writes `value` to the guest memory, then its pointer and
+// length at `outputPtr`. `from` is the host function returning the value, an
+// OutOfBoundsError is returned when `outputPtr` is not in the guest memory.
 func (i *Instance) WriteOutputToHeap(outputPtr int32, value []byte, from string) error {
+	if _, ok := i.Module.Heap.TryReadBytes(outputPtr, 8); !ok {
+		return i.Module.outOfBounds(from, outputPtr, 8)
+	}
 	valuePtr, err := i.Module.Heap.WriteAndTrack(value, false, from+":WriteOutputToHeap1")
 	if err != nil {
 		return fmt.Errorf("writting value to heap: %w", err)
@@ -66,6 +203,61 @@ func (i *Instance) WriteOutputToHeap(outputPtr int32, value []byte, from string)
 	return nil
 }
 
+// Close releases the guest memory used by the call, once its outputs and logs
+// were copied out. The wasm instance of the module is reset, or dropped along
+// with its store when it would be recreated before the next call anyway: the
+// call trapped, grew the memory or was the last one allowed on the instance.
+// Closing an instance twice, or once its module moved on to another instance,
+// does nothing.
+func (i *Instance) Close(ctx context.Context) error {
+	m := i.Module
+	if i.closed || m.lastInstance != i {
+		i.closed = true
+		return nil
+	}
+	i.closed = true
+
+	if m.clean || m.released {
+		return nil
+	}
+	if m.mustRecreate() {
+		m.release()
+		return nil
+	}
+	if err := m.resetInstance(); err != nil {
+		return fmt.Errorf("resetting module %q: %w", m.name, err)
+	}
+	return nil
+}
+
+// describeStores lists the stores configured for the instance, as the guest
+// sees them: the index of each store in get mode, the stores in deltas mode
+// and the output store.
+func (i *Instance) describeStores() string {
+	var parts []string
+	if len(i.inputStoreNames) != 0 {
+		names := make([]string, len(i.inputStoreNames))
+		for index, name := range i.inputStoreNames {
+			names[index] = fmt.Sprintf("%d %q", index, name)
+		}
+		parts = append(parts, "get mode "+strings.Join(names, ", "))
+	}
+	if len(i.deltasStoreNames) != 0 {
+		names := make([]string, len(i.deltasStoreNames))
+		for index, name := range i.deltasStoreNames {
+			names[index] = fmt.Sprintf("%q", name)
+		}
+		parts = append(parts, "deltas mode "+strings.Join(names, ", "))
+	}
+	if i.outputStore != nil {
+		parts = append(parts, fmt.Sprintf("output %q", i.outputStoreName))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, "; ")
+}
+
 func (i *Instance) Err() error {
 	return i.panicError
 }
@@ -74,16 +266,55 @@ func (i *Instance) Output() []byte {
 	return i.returnValue
 }
 
+// Skipped tells if the guest ended its execution with `skip_block`, in which
+// case it has no output.
+func (i *Instance) Skipped() bool {
+	return i.skipped
+}
+
 func (i *Instance) SetOutputStore(store *state.Store) {
 	i.outputStore = store
 }
 
-const maxLogByteCount = 128 * 1024 // 128 KiB
-
 func (i *Instance) ReachedLogsMaxByteCount() bool {
-	return i.LogsByteCount >= maxLogByteCount
+	return i.LogsByteCount >= i.maxLogByteCount
+}
+
+func (i *Instance) dropLog(length int32) {
+	i.DroppedLogs++
+	i.DroppedLogsByteCount += uint64(length)
 }
 
 func (i *Instance) PushExecutionStack(event string) {
 	i.ExecutionStack = append(i.ExecutionStack, event)
 }
+
+// traceHostCall records the host function `call` in the execution stack when
+// the host calls are traced, with the key or prefix it was given and the size
+// of the value it read or wrote. Only the first maxHostCallTraces calls are
+// recorded. It does nothing otherwise, so its arguments must not be computed
+// for it alone.
+func (i *Instance) traceHostCall(call string, key string, valueSize int) {
+	if !i.traceHostCalls {
+		return
+	}
+	i.hostCallTraces++
+	if i.hostCallTraces > maxHostCallTraces {
+		if i.hostCallTraces == maxHostCallTraces+1 {
+			i.PushExecutionStack(fmt.Sprintf("%s: host call traces capped at %d, the next calls are not recorded", i.Module.name, maxHostCallTraces))
+		}
+		return
+	}
+
+	event := fmt.Sprintf("%s: %s", i.Module.name, call)
+	if key != "" {
+		event += fmt.Sprintf(" %q", key)
+	}
+	switch {
+	case valueSize == traceNotFound:
+		event += " not found"
+	case valueSize >= 0:
+		event += fmt.Sprintf(" (%d bytes)", valueSize)
+	}
+	i.PushExecutionStack(event)
+}
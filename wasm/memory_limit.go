@@ -0,0 +1,150 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const wasmPageSize = 64 * 1024
+
+const memorySectionID = 5
+
+// maxMemoryPages is the largest linear memory of a 32-bit wasm module, 4 GiB.
+const maxMemoryPages = 65536
+
+// MemoryLimitError is returned when a call fails after the guest reached the
+// maximum size of its linear memory, its allocator being unable to grow it.
+// Memory growth only depends on the wasm code and its inputs, so the same call
+// fails the same way everywhere the same limit is configured.
+type MemoryLimitError struct {
+	Limit uint64
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("wasm memory limit of %d bytes reached", e.Limit)
+}
+
+// LimitMemory returns a copy of `wasmCode` whose linear memories cannot grow
+// over `maxSize` bytes, rounded down to a whole number of pages: the maximum
+// size declared by the memory section is lowered to it. A guest growing its
+// memory over it sees `memory.grow` fail, as it would on a host out of memory.
+// 0 means no limit, `wasmCode` is then returned as is.
+func LimitMemory(wasmCode []byte, maxSize uint64) ([]byte, error) {
+	return SizeMemory(wasmCode, 0, maxSize)
+}
+
+// SizeMemory is LimitMemory also raising the initial size of the linear
+// memories to `initialSize` bytes, rounded up to a whole number of pages, so a
+// module known to need that much memory does not grow it page after page. The
+// initial size declared by the memory section is kept when larger, and the
+// maximum size it declares is never exceeded. 0 means the declared initial
+// size.
+func SizeMemory(wasmCode []byte, initialSize, maxSize uint64) ([]byte, error) {
+	if maxSize != 0 && initialSize > maxSize {
+		return nil, fmt.Errorf("initial memory size of %d bytes exceeds the maximum of %d bytes", initialSize, maxSize)
+	}
+	if initialSize == 0 && maxSize == 0 {
+		return wasmCode, nil
+	}
+	maxPages := uint64(maxMemoryPages)
+	if maxSize != 0 && maxSize/wasmPageSize < maxPages {
+		maxPages = maxSize / wasmPageSize
+	}
+	initialPages := (initialSize + wasmPageSize - 1) / wasmPageSize
+	if initialPages > maxPages {
+		return nil, fmt.Errorf("initial memory size of %d bytes exceeds the maximum of %d bytes once rounded to pages", initialPages*wasmPageSize, maxPages*wasmPageSize)
+	}
+
+	if len(wasmCode) < 8 {
+		return nil, fmt.Errorf("invalid wasm code of %d bytes", len(wasmCode))
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(wasmCode)))
+	out.Write(wasmCode[:8]) // magic and version
+
+	reader := bytes.NewReader(wasmCode[8:])
+	for reader.Len() > 0 {
+		id, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading size of section %d: %w", id, err)
+		}
+		if size > uint64(reader.Len()) {
+			return nil, fmt.Errorf("section %d of %d bytes overflows the wasm code", id, size)
+		}
+		content := make([]byte, size)
+		_, _ = reader.Read(content)
+
+		if id == memorySectionID {
+			if content, err = sizeMemorySection(content, initialPages, maxPages); err != nil {
+				return nil, err
+			}
+		}
+
+		out.WriteByte(id)
+		out.Write(appendUvarint(nil, uint64(len(content))))
+		out.Write(content)
+	}
+	return out.Bytes(), nil
+}
+
+func sizeMemorySection(content []byte, initialPages, maxPages uint64) ([]byte, error) {
+	reader := bytes.NewReader(content)
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("reading memory count: %w", err)
+	}
+
+	out := appendUvarint(nil, count)
+	for i := uint64(0); i < count; i++ {
+		flags, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading memory %d: %w", i, err)
+		}
+		if flags > 1 {
+			// Shared and 64-bit memories are not enabled in the engine
+			return nil, fmt.Errorf("memory %d: unsupported limits flags 0x%02x", i, flags)
+		}
+
+		min, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading memory %d: %w", i, err)
+		}
+		max := maxPages
+		if flags == 1 {
+			declaredMax, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("reading memory %d: %w", i, err)
+			}
+			if declaredMax < max {
+				max = declaredMax
+			}
+		}
+		if min > max {
+			return nil, fmt.Errorf("memory %d: initial size of %d bytes exceeds the limit of %d bytes", i, min*wasmPageSize, maxPages*wasmPageSize)
+		}
+		if initialPages > min {
+			min = initialPages
+			if min > max {
+				// Declared by the module itself
+				min = max
+			}
+		}
+
+		out = append(out, 1)
+		out = appendUvarint(out, min)
+		out = appendUvarint(out, max)
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes in memory section", reader.Len())
+	}
+	return out, nil
+}
+
+func appendUvarint(buf []byte, value uint64) []byte {
+	encoded := make([]byte, binary.MaxVarintLen64)
+	return append(buf, encoded[:binary.PutUvarint(encoded, value)]...)
+}
@@ -0,0 +1,235 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allocWAT grows its memory one page at a time until it fails, then aborts
+// like a guest allocator would, unless its input is empty.
+const allocWAT = `
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_alloc") (param $ptr i32) (param $len i32)
+    (if (i32.eqz (local.get $len)) (then return))
+    (loop $grow
+      (br_if $grow (i32.ne (memory.grow (i32.const 1)) (i32.const -1))))
+    unreachable)
+)
+`
+
+func TestLimitMemory(t *testing.T) {
+	tests := []struct {
+		name        string
+		wat         string
+		maxSize     uint64
+		expectMax   uint32
+		expectError string
+	}{
+		{
+			name:      "no declared maximum",
+			wat:       `(module (memory (export "memory") 1))`,
+			maxSize:   4 * wasmPageSize,
+			expectMax: 4,
+		},
+		{
+			name:      "rounded down to a page",
+			wat:       `(module (memory (export "memory") 1))`,
+			maxSize:   4*wasmPageSize + 100,
+			expectMax: 4,
+		},
+		{
+			name:      "lower declared maximum kept",
+			wat:       `(module (memory (export "memory") 1 2))`,
+			maxSize:   4 * wasmPageSize,
+			expectMax: 2,
+		},
+		{
+			name:        "initial size over limit",
+			wat:         `(module (memory (export "memory") 8))`,
+			maxSize:     4 * wasmPageSize,
+			expectError: "memory 0: initial size of 524288 bytes exceeds the limit of 262144 bytes",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := wasmtime.Wat2Wasm(test.wat)
+			require.NoError(t, err)
+
+			limited, err := LimitMemory(code, test.maxSize)
+			if test.expectError != "" {
+				assert.EqualError(t, err, test.expectError)
+				return
+			}
+			require.NoError(t, err)
+
+			module, err := wasmtime.NewModule(wasmtime.NewEngine(), limited)
+			require.NoError(t, err)
+			require.Len(t, module.Exports(), 1)
+			found, max := module.Exports()[0].Type().MemoryType().Maximum()
+			assert.True(t, found)
+			assert.Equal(t, uint64(test.expectMax), max)
+		})
+	}
+}
+
+func TestSizeMemory(t *testing.T) {
+	tests := []struct {
+		name          string
+		wat           string
+		initialSize   uint64
+		maxSize       uint64
+		expectInitial uint32
+		expectMax     uint32
+		expectError   string
+	}{
+		{
+			name:          "raised",
+			wat:           `(module (memory (export "memory") 1))`,
+			initialSize:   3 * wasmPageSize,
+			maxSize:       4 * wasmPageSize,
+			expectInitial: 3,
+			expectMax:     4,
+		},
+		{
+			name:          "rounded up to a page",
+			wat:           `(module (memory (export "memory") 1))`,
+			initialSize:   2*wasmPageSize + 1,
+			maxSize:       4 * wasmPageSize,
+			expectInitial: 3,
+			expectMax:     4,
+		},
+		{
+			name:          "larger declared initial size kept",
+			wat:           `(module (memory (export "memory") 3))`,
+			initialSize:   2 * wasmPageSize,
+			maxSize:       4 * wasmPageSize,
+			expectInitial: 3,
+			expectMax:     4,
+		},
+		{
+			name:          "capped to the declared maximum",
+			wat:           `(module (memory (export "memory") 1 2))`,
+			initialSize:   3 * wasmPageSize,
+			maxSize:       4 * wasmPageSize,
+			expectInitial: 2,
+			expectMax:     2,
+		},
+		{
+			name:          "no limit",
+			wat:           `(module (memory (export "memory") 1))`,
+			initialSize:   3 * wasmPageSize,
+			expectInitial: 3,
+			expectMax:     maxMemoryPages,
+		},
+		{
+			name:        "initial size over maximum",
+			wat:         `(module (memory (export "memory") 1))`,
+			initialSize: 8 * wasmPageSize,
+			maxSize:     4 * wasmPageSize,
+			expectError: "initial memory size of 524288 bytes exceeds the maximum of 262144 bytes",
+		},
+		{
+			name:        "initial size over maximum once rounded",
+			wat:         `(module (memory (export "memory") 1))`,
+			initialSize: 4*wasmPageSize + 1,
+			maxSize:     4*wasmPageSize + 100,
+			expectError: "initial memory size of 327680 bytes exceeds the maximum of 262144 bytes once rounded to pages",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := wasmtime.Wat2Wasm(test.wat)
+			require.NoError(t, err)
+
+			sized, err := SizeMemory(code, test.initialSize, test.maxSize)
+			if test.expectError != "" {
+				assert.EqualError(t, err, test.expectError)
+				return
+			}
+			require.NoError(t, err)
+
+			module, err := wasmtime.NewModule(wasmtime.NewEngine(), sized)
+			require.NoError(t, err)
+			memoryType := module.Exports()[0].Type().MemoryType()
+			assert.Equal(t, uint64(test.expectInitial), memoryType.Minimum())
+			found, max := memoryType.Maximum()
+			assert.True(t, found)
+			assert.Equal(t, uint64(test.expectMax), max)
+		})
+	}
+}
+
+func TestModule_InitialMemorySize(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(allocWAT)
+	require.NoError(t, err)
+	sized, err := SizeMemory(code, 2*wasmPageSize, 6*wasmPageSize)
+	require.NoError(t, err)
+
+	module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, sized, "alloc", "map_alloc")
+	require.NoError(t, err)
+	module.SetMaxMemorySize(6 * wasmPageSize)
+	assert.Equal(t, uint64(2*wasmPageSize+defaultArenaSize), module.MemorySize(), "initial size and arena")
+
+	// Growing over the maximum fails the same way every time
+	var errs []string
+	for i := 0; i < 2; i++ {
+		instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+		require.NoError(t, err)
+		err = instance.Execute(context.Background())
+		var limitErr *MemoryLimitError
+		require.ErrorAs(t, err, &limitErr)
+		errs = append(errs, err.Error())
+		assert.Equal(t, uint64(6*wasmPageSize), module.MemorySize())
+	}
+	assert.Equal(t, errs[0], errs[1])
+}
+
+func TestModule_MemoryLimit(t *testing.T) {
+	const maxSize = 4 * wasmPageSize
+
+	code, err := wasmtime.Wat2Wasm(allocWAT)
+	require.NoError(t, err)
+	limited, err := LimitMemory(code, maxSize)
+	require.NoError(t, err)
+
+	pool := NewModulePool(NewRuntime(nil), 1)
+	module, err := pool.Checkout(context.Background(), &pbsubstreams.Request{}, "hash_alloc", limited, "alloc", "map_alloc")
+	require.NoError(t, err)
+	module.SetMaxMemorySize(maxSize)
+
+	execute := func(input []byte) error {
+		instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: input}})
+		require.NoError(t, err)
+		return instance.Execute(context.Background())
+	}
+
+	for i := 0; i < 2; i++ {
+		err = execute([]byte("block"))
+		var limitErr *MemoryLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.EqualError(t, err, `executing module "alloc": wasm memory limit of 262144 bytes reached`)
+		assert.Equal(t, uint64(maxSize), module.MemorySize(), "the memory never grows over the limit")
+	}
+
+	// The module works again once its instance is recreated
+	require.NoError(t, execute(nil))
+
+	// Other modules keep working
+	counterCode, err := wasmtime.Wat2Wasm(counterWAT)
+	require.NoError(t, err)
+	counter, err := pool.Checkout(context.Background(), &pbsubstreams.Request{}, "hash_counter", counterCode, "counter", "map_counter")
+	require.NoError(t, err)
+	memoryCounter, _, err := executeCounter(t, counter, []byte("block"))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), memoryCounter)
+}
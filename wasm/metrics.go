@@ -0,0 +1,39 @@
+package wasm
+
+import (
+	"time"
+
+	"github.com/streamingfast/dmetrics"
+)
+
+// MetricsSet holds the metrics of the wasm runtime, to be registered by the
+// application running the service.
+var MetricsSet = dmetrics.NewSet()
+
+var compileDuration = MetricsSet.NewHistogram("substreams_wasm_compile_duration_seconds", "Time spent compiling the wasm code of a module, cache misses only")
+var compilationCacheHits = MetricsSet.NewCounter("substreams_wasm_compilation_cache_hits", "Wasm modules loaded already compiled from the on-disk compilation cache")
+var compilationCacheMisses = MetricsSet.NewCounter("substreams_wasm_compilation_cache_misses", "Wasm modules compiled because the on-disk compilation cache did not hold them, or held a corrupt entry")
+
+var instantiations = MetricsSet.NewCounterVec("substreams_wasm_instantiations", []string{"module"}, "Wasm instances created, per module")
+var instantiationDuration = MetricsSet.NewHistogramVec("substreams_wasm_instantiation_duration_seconds", []string{"module"}, "Time spent creating a wasm instance, per module")
+var executionDuration = MetricsSet.NewHistogramVec("substreams_wasm_execution_duration_seconds", []string{"module"}, "Time spent in a call to the entrypoint of a module, per module")
+var heapBytesWritten = MetricsSet.NewHistogramVec("substreams_wasm_heap_bytes_written", []string{"module"}, "Bytes written by the host to the guest memory for a call, inputs included, per module")
+var memorySize = MetricsSet.NewGaugeVec("substreams_wasm_memory_size_bytes", []string{"module"}, "Size of the linear memory of the last wasm instance of a module after a call, per module")
+var panics = MetricsSet.NewCounterVec("substreams_wasm_panics", []string{"module"}, "Calls ended by a panic of the module, per module")
+var droppedLogs = MetricsSet.NewCounterVec("substreams_wasm_dropped_logs", []string{"module"}, "Logs dropped once the logs of an execution reached their maximum size, per module")
+
+// observeCall records the metrics of a call of `i` started at `start`, during
+// which `dropped` logs were dropped.
+func (i *Instance) observeCall(start time.Time, dropped uint64) {
+	m := i.Module
+	executionDuration.ObserveSince(start, m.name)
+	heapBytesWritten.ObserveUint64(int64(m.Heap.bytesWritten), m.name)
+	m.Heap.bytesWritten = 0
+	memorySize.SetUint64(m.MemorySize(), m.name)
+	if i.panicError != nil {
+		panics.Inc(m.name)
+	}
+	if dropped != 0 {
+		droppedLogs.AddUint64(dropped, m.name)
+	}
+}
@@ -0,0 +1,61 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/streamingfast/dmetrics"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterValue(counter *dmetrics.CounterVec, module string) float64 {
+	return testutil.ToFloat64(counter.Native().WithLabelValues(module))
+}
+
+func sampleCount(t *testing.T, histogram *dmetrics.HistogramVec, module string) uint64 {
+	metric := &dto.Metric{}
+	require.NoError(t, histogram.Native().WithLabelValues(module).(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestModule_Metrics(t *testing.T) {
+	ctx := context.Background()
+	run := func(t *testing.T, wat, name, entrypoint string, opts []RuntimeOption, args ...interface{}) error {
+		code, err := wasmtime.Wat2Wasm(wat)
+		require.NoError(t, err)
+		module, err := NewRuntime(nil, opts...).NewModule(ctx, &pbsubstreams.Request{}, code, name, entrypoint)
+		require.NoError(t, err)
+		instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+		require.NoError(t, err)
+		if args != nil {
+			return instance.ExecuteWithArgs(ctx, args...)
+		}
+		return instance.Execute(ctx)
+	}
+
+	t.Run("logs", func(t *testing.T) {
+		instantiationsBefore := counterValue(instantiations, "metrics_logs")
+		droppedBefore := counterValue(droppedLogs, "metrics_logs")
+		executionsBefore := sampleCount(t, executionDuration, "metrics_logs")
+		writesBefore := sampleCount(t, heapBytesWritten, "metrics_logs")
+
+		require.NoError(t, run(t, leveledLogsWAT, "metrics_logs", "map_logs", []RuntimeOption{WithMaxLogByteCount(2)}))
+		assert.Equal(t, instantiationsBefore+1, counterValue(instantiations, "metrics_logs"))
+		assert.Equal(t, droppedBefore+4, counterValue(droppedLogs, "metrics_logs"))
+		assert.Equal(t, executionsBefore+1, sampleCount(t, executionDuration, "metrics_logs"))
+		assert.Equal(t, writesBefore+1, sampleCount(t, heapBytesWritten, "metrics_logs"))
+		assert.Equal(t, float64(wasmPageSize+defaultArenaSize), testutil.ToFloat64(memorySize.Native().WithLabelValues("metrics_logs")))
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		panicsBefore := counterValue(panics, "metrics_panic")
+		require.Error(t, run(t, panicWAT, "metrics_panic", "panic_with", nil, int32(0), int32(4), int32(16), int32(10), int32(42), int32(7)))
+		assert.Equal(t, panicsBefore+1, counterValue(panics, "metrics_panic"))
+	})
+}
@@ -4,136 +4,339 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
-	"github.com/bytecodealliance/wasmtime-go"
 	"github.com/dustin/go-humanize"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"go.uber.org/zap"
-	"google.golang.org/protobuf/proto"
+	"go.uber.org/zap/zapcore"
 )
 
+// defaultMaxInstanceCalls is the number of calls after which the wasm instance
+// of a module is recreated from scratch instead of being reset.
+const defaultMaxInstanceCalls = 10_000
+
+// unlimitedFuel is the fuel available to the wasm code outside of the calls
+// to the entrypoint, and during them when the module has no fuel limit.
+const unlimitedFuel = 1 << 62
+
 type Module struct {
 	runtime *Runtime
 
 	name string
-
-	wasmCode        []byte
-	CurrentInstance *Instance
+	hash string // module hash, set when the module comes from a ModulePool
+
+	// ctx and request are the ones of the current execution, for the wasm
+	// extensions
+	ctx     context.Context
+	request *pbsubstreams.Request
+
+	wasmCode []byte
+	// currentInstance is the instance whose call is running, for the host
+	// functions. A module runs a single call at a time, concurrent executions
+	// each check out their own module from a ModulePool.
+	currentInstance *Instance
 	entrypoint      string
-	wasmInstance    *wasmtime.Instance
-	wasmEngine      *wasmtime.Engine
-	wasmStore       *wasmtime.Store
-	wasmModule      *wasmtime.Module
-	wasmLinker      *wasmtime.Linker
+	wasmInstance    engineInstance
+	wasmEngine      engine
+	wasmModule      compiledModule
+	wasmLinked      linkedModule
 	Heap            *Heap
+
+	// The wasm instance is reused across calls, it is reset to the state it
+	// had right after its instantiation before each call.
+	memorySnapshot []byte
+	restoreGlobals func() error
+	callCount      uint64 // calls executed on the current instance
+	maxCallCount   uint64
+	instanceFailed bool // the last call trapped, the instance cannot be trusted anymore
+	clean          bool // no call was executed since the instance was created or reset
+	released       bool // the instance was dropped by release, the next call creates a new one
+	lastInstance   *Instance
+
+	fuelLimit        uint64 // per call, 0 means no limit
+	lastFuelConsumed uint64
+	maxMemorySize    uint64 // enforced by the code itself, see LimitMemory
 }
 
 func (r *Runtime) NewModule(ctx context.Context, request *pbsubstreams.Request, wasmCode []byte, name string, entrypoint string) (*Module, error) {
-	engine := wasmtime.NewEngine()
-	linker := wasmtime.NewLinker(engine)
-	store := wasmtime.NewStore(engine)
-	module, err := wasmtime.NewModule(store.Engine, wasmCode)
+	e := r.newEngine()
+	module, err := e.compile(wasmCode)
 	if err != nil {
 		return nil, fmt.Errorf("creating new module: %w", err)
 	}
+	return r.newModule(ctx, request, e, module, wasmCode, name, entrypoint)
+}
 
+// newModule instantiates `module`, compiled with `e`.
+func (r *Runtime) newModule(ctx context.Context, request *pbsubstreams.Request, e engine, module compiledModule, wasmCode []byte, name string, entrypoint string) (*Module, error) {
 	m := &Module{
-		runtime:    r,
-		wasmEngine: engine,
-		wasmLinker: linker,
-		wasmStore:  store,
-		wasmModule: module,
-		name:       name,
-		wasmCode:   wasmCode,
-		entrypoint: entrypoint,
-	}
-	if err := m.newImports(); err != nil {
+		runtime:      r,
+		ctx:          ctx,
+		request:      request,
+		wasmEngine:   e,
+		wasmModule:   module,
+		name:         name,
+		wasmCode:     wasmCode,
+		entrypoint:   entrypoint,
+		maxCallCount: defaultMaxInstanceCalls,
+	}
+	functions, err := m.newImports()
+	if err != nil {
 		return nil, fmt.Errorf("instantiating imports: %w", err)
 	}
 	for namespace, imports := range r.extensions {
 		for importName, f := range imports {
-			f := m.newExtensionFunction(ctx, request, namespace, importName, f)
-			if err := linker.FuncWrap(namespace, importName, f); err != nil {
-				return nil, fmt.Errorf("instantiating extension import, [%s@%s]: %w", namespace, name, err)
-			}
+			functions = append(functions, hostFunction{namespace: namespace, name: importName, fn: m.newExtensionFunction(namespace, importName, f)})
 		}
 	}
+	if m.wasmLinked, err = e.link(module, functions); err != nil {
+		return nil, fmt.Errorf("linking imports: %w", err)
+	}
 
-	instance, err := m.wasmLinker.Instantiate(m.wasmStore, m.wasmModule)
+	if err := m.instantiate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// instantiate creates a new wasm instance, so the previous one can be
+// released, and snapshots its memory and mutable globals.
+func (m *Module) instantiate() error {
+	start := time.Now()
+	instance, err := m.wasmLinked.instantiate()
+	if errors.Is(err, errMemoryNotExported) {
+		return fmt.Errorf("module %q does not export its memory", m.name)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("creating new instance: %w", err)
+		return fmt.Errorf("creating new instance: %w", err)
 	}
-	memory := instance.GetExport(m.wasmStore, "memory").Memory()
 
-	alloc := instance.GetExport(m.wasmStore, "alloc").Func()
-	dealloc := instance.GetExport(m.wasmStore, "dealloc").Func()
+	alloc := instance.function("alloc")
+	dealloc := instance.function("dealloc")
 	if alloc == nil || dealloc == nil {
-		panic("missing malloc or free")
+		return fmt.Errorf("module %q does not export its alloc and dealloc functions", m.name)
 	}
 
-	heap := NewHeap(memory, alloc, dealloc, m.wasmStore)
-	m.Heap = heap
+	// Reserved before the snapshots, so the guest allocator keeps knowing
+	// about the arena once restored
+	heap := newHeap(instance, alloc, dealloc)
+	heap.reserveArena(defaultArenaSize)
+
 	m.wasmInstance = instance
-	return m, nil
+	m.restoreGlobals = instance.snapshotGlobals()
+	m.memorySnapshot = append([]byte(nil), instance.memory()...)
+	m.Heap = heap
+	m.callCount = 0
+	m.instanceFailed = false
+	m.clean = true
+	m.released = false
+
+	instantiations.Inc(m.name)
+	instantiationDuration.ObserveSince(start, m.name)
+	return nil
+}
+
+// resetInstance brings back the wasm instance to its freshly instantiated
+// state so nothing leaks from one call to the next. The instance is
+// recreated instead when it trapped, when it served too many calls or when
+// the guest grew its memory, since the restored guest allocator would not
+// know about the grown pages.
+func (m *Module) resetInstance() error {
+	if m.clean || m.released {
+		return nil
+	}
+
+	if m.mustRecreate() {
+		if err := m.instantiate(); err != nil {
+			return fmt.Errorf("recreating instance: %w", err)
+		}
+		return nil
+	}
+
+	copy(m.wasmInstance.memory(), m.memorySnapshot)
+	if err := m.restoreGlobals(); err != nil {
+		return fmt.Errorf("restoring globals: %w", err)
+	}
+	m.Heap.reset()
+	m.clean = true
+	return nil
+}
+
+// mustRecreate tells if the wasm instance cannot be reset for the next call.
+func (m *Module) mustRecreate() bool {
+	return m.instanceFailed || m.callCount >= m.maxCallCount || m.wasmInstance.memorySize() != uint64(len(m.memorySnapshot))
+}
+
+// release drops the wasm instance, so the memory it holds is freed without
+// waiting for the next call, which creates a new instance.
+func (m *Module) release() {
+	m.wasmInstance = nil
+	m.memorySnapshot = nil
+	m.restoreGlobals = nil
+	m.Heap = nil
+	m.clean = false
+	m.released = true
+}
+
+// SetFuelLimit sets the fuel available to each call to the entrypoint, 0
+// means no limit. A call running out of fuel fails with an
+// ExecutionBudgetExceededError.
+func (m *Module) SetFuelLimit(limit uint64) {
+	m.fuelLimit = limit
+}
+
+// SetMaxMemorySize tells the module the limit its code was built with by
+// LimitMemory, so a call failing once the memory reached it returns a
+// MemoryLimitError. 0 means no limit.
+func (m *Module) SetMaxMemorySize(maxSize uint64) {
+	m.maxMemorySize = maxSize
+}
+
+// FuelConsumed returns the fuel consumed by the last call to the entrypoint,
+// roughly the number of wasm instructions it executed.
+func (m *Module) FuelConsumed() uint64 {
+	return m.lastFuelConsumed
+}
+
+// watchInterruption interrupts the wasm code running in the instance once
+// `ctx` is done. The returned function stops watching, the engine is not
+// interrupted anymore once it returned.
+func (m *Module) watchInterruption(ctx context.Context) (stop func()) {
+	done := ctx.Done()
+	if done == nil {
+		return func() {}
+	}
+
+	m.wasmInstance.watchInterruption(true)
+	stopped := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-done:
+			m.wasmEngine.interrupt()
+		case <-stopped:
+		}
+	}()
+
+	return func() {
+		close(stopped)
+		<-exited
+		m.wasmInstance.watchInterruption(false)
+	}
+}
+
+// MemorySize returns the current size in bytes of the linear memory of the
+// wasm instance, 0 once it was released.
+func (m *Module) MemorySize() uint64 {
+	if m.released {
+		return 0
+	}
+	return m.wasmInstance.memorySize()
 }
 
 func (m *Module) NewInstance(clock *pbsubstreams.Clock, inputs []*Input) (*Instance, error) {
-	entrypoint := m.wasmInstance.GetExport(m.wasmStore, m.entrypoint).Func()
+	if m.released {
+		if err := m.instantiate(); err != nil {
+			return nil, fmt.Errorf("recreating module %q: %w", m.name, err)
+		}
+	}
+	if err := m.resetInstance(); err != nil {
+		return nil, fmt.Errorf("resetting module %q: %w", m.name, err)
+	}
+
+	entrypoint := m.wasmInstance.function(m.entrypoint)
 	if entrypoint == nil {
-		return nil, fmt.Errorf("failed to get exported function %q", entrypoint)
+		return nil, fmt.Errorf("failed to get exported function %q", m.entrypoint)
 	}
 
-	m.CurrentInstance = &Instance{
-		Module:     m,
-		clock:      clock,
-		entrypoint: entrypoint,
+	instance := &Instance{
+		Module:          m,
+		clock:           clock,
+		entrypoint:      entrypoint,
+		maxLogByteCount: m.maxLogByteCount(),
+		traceHostCalls:  m.request.GetDevelopmentMode(),
+	}
+	m.lastInstance = instance
+	if m.runtime.storeReadCache {
+		instance.storeReads = map[storeRead]storeReadResult{}
 	}
 
 	var args []interface{}
+	var written []*Input
+	var ptrArgs []int // index in `args` of the pointer to each written input
 	for _, input := range inputs {
 		switch input.Type {
-		case InputSource:
-			ptr, err := m.Heap.Write(input.StreamData, input.Name)
-			if err != nil {
-				return nil, fmt.Errorf("writing %q to heap: %w", input.Name, err)
+		case InputSource, InputStoreDeltas:
+			written = append(written, input)
+			ptrArgs = append(ptrArgs, len(args))
+			args = append(args, int32(0), int32(len(input.StreamData)))
+			if input.Type == InputStoreDeltas {
+				instance.deltasStoreNames = append(instance.deltasStoreNames, input.Name)
 			}
-			length := int32(len(input.StreamData))
-			args = append(args, ptr, length)
 		case InputStore:
-			if input.Deltas {
-				//todo: this maybe sub optimal when deltas are extrated from zeroModule output cache
-				cnt, err := proto.Marshal(&pbsubstreams.StoreDeltas{Deltas: input.Store.Deltas})
-				if err != nil {
-					return nil, fmt.Errorf("marshaling store deltas: %w", err)
-				}
-				ptr, err := m.Heap.Write(cnt, input.Name)
-				if err != nil {
-					return nil, fmt.Errorf("writing %q (deltas=%v) to heap: %w", input.Name, input.Deltas, err)
-				}
-
-				args = append(args, ptr, int32(len(cnt)))
-			} else {
-				m.CurrentInstance.inputStores = append(m.CurrentInstance.inputStores, input.Store)
-				args = append(args, int32(len(m.CurrentInstance.inputStores)-1))
-			}
+			instance.inputStores = append(instance.inputStores, input.Store)
+			instance.inputStoreNames = append(instance.inputStoreNames, input.Name)
+			args = append(args, int32(len(instance.inputStores)-1))
 		case OutputStore:
-			m.CurrentInstance.outputStore = input.Store
-			m.CurrentInstance.updatePolicy = input.UpdatePolicy
-			m.CurrentInstance.valueType = input.ValueType
+			instance.outputStore = input.Store
+			instance.outputStoreName = input.Name
+			instance.updatePolicy = input.UpdatePolicy
+			instance.valueType = input.ValueType
 		}
 	}
-	m.CurrentInstance.args = args
 
-	return m.CurrentInstance, nil
+	ptrs, err := m.writeInputs(written)
+	if err != nil {
+		return nil, err
+	}
+	for i, ptr := range ptrs {
+		args[ptrArgs[i]] = ptr
+	}
+	instance.args = args
+
+	return instance, nil
+}
+
+// writeInputs writes the data of `inputs` to the guest memory, all at once
+// unless the runtime writes them one by one, and returns their pointers.
+func (m *Module) writeInputs(inputs []*Input) ([]int32, error) {
+	if !m.runtime.perInputWrites {
+		values := make([][]byte, len(inputs))
+		for i, input := range inputs {
+			values[i] = input.StreamData
+		}
+		ptrs, err := m.Heap.WriteBatch(values, "inputs")
+		if err != nil {
+			return nil, fmt.Errorf("writing inputs to heap: %w", err)
+		}
+		return ptrs, nil
+	}
+
+	ptrs := make([]int32, len(inputs))
+	for i, input := range inputs {
+		ptr, err := m.Heap.Write(input.StreamData, input.Name)
+		if err != nil {
+			return nil, fmt.Errorf("writing %q to heap: %w", input.Name, err)
+		}
+		ptrs[i] = ptr
+	}
+	return ptrs, nil
 }
 
-func (m *Module) newExtensionFunction(ctx context.Context, request *pbsubstreams.Request, namespace, name string, f WASMExtension) interface{} {
-	return func(ptr, length, outputPtr int32) {
-		heap := m.Heap
+func (m *Module) newExtensionFunction(namespace, name string, f WASMExtension) interface{} {
+	call := namespace + "." + name
+	return func(ptr, length, outputPtr int32) error {
+		ctx, request := m.ctx, m.request
 
-		data := heap.ReadBytes(ptr, length)
+		data, trap := m.readGuestBytes(call, ptr, length)
+		if trap != nil {
+			return trap
+		}
+		m.currentInstance.traceHostCall(call, "", len(data))
 
-		out, err := f(ctx, request, m.CurrentInstance.clock, data)
+		out, err := f(ctx, request, m.currentInstance.clock, data)
 		if err != nil {
 			panic(fmt.Errorf(`running wasm extension "%s::%s": %w`, namespace, name, err))
 		}
@@ -144,83 +347,193 @@ func (m *Module) newExtensionFunction(ctx context.Context, request *pbsubstreams
 			panic(fmt.Errorf("running wasm extension has been stop upstream in the call stack: %w", ctx.Err()))
 		}
 
-		err = m.CurrentInstance.WriteOutputToHeap(outputPtr, out, name)
+		if err := m.currentInstance.WriteOutputToHeap(outputPtr, out, call); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+func (m *Module) newImports() ([]hostFunction, error) {
+	functions := append(m.loggerImports(), m.stateImports()...)
+	functions = append(functions, m.clockImports()...)
+
+	if m.binaryType() == WASIBinaryType {
+		wasiFunctions, err := m.wasiImports()
 		if err != nil {
-			panic(fmt.Errorf("write output to heap %w", err))
+			return nil, fmt.Errorf("registering wasi imports: %w", err)
 		}
+		functions = append(functions, wasiFunctions...)
 	}
+
+	functions = append(functions,
+		hostFunction{namespace: "env", name: "register_panic", fn: func(msgPtr, msgLength int32, filenamePtr, filenameLength int32, lineNumber, columnNumber int32) {
+			m.currentInstance.traceHostCall("env.register_panic", "", traceNoValue)
+			m.currentInstance.panicError = newPanicError(m.Heap, m.name, msgPtr, msgLength, filenamePtr, filenameLength, lineNumber, columnNumber)
+		}},
+		hostFunction{namespace: "env", name: "output", fn: func(ptr, length int32) error {
+			message, trap := m.readGuestBytes("env.output", ptr, length)
+			if trap != nil {
+				return trap
+			}
+			m.currentInstance.traceHostCall("env.output", "", len(message))
+			m.currentInstance.returnValue = make([]byte, length)
+			copy(m.currentInstance.returnValue, message)
+			return nil
+		}},
+		hostFunction{namespace: "env", name: "skip_block", fn: func() error {
+			// Unwinds the guest, see Instance.call
+			m.currentInstance.traceHostCall("env.skip_block", "", traceNoValue)
+			m.currentInstance.skipped = true
+			return errSkipBlock
+		}},
+		hostFunction{namespace: "env", name: "params", fn: func(outputPtr int32) error {
+			params := m.params()
+			m.currentInstance.traceHostCall("env.params", "", len(params))
+			if err := m.currentInstance.WriteOutputToHeap(outputPtr, []byte(params), "env.params"); err != nil {
+				return err
+			}
+			return nil
+		}},
+	)
+	return functions, nil
 }
 
-func (m *Module) newImports() error {
-	linker := m.wasmLinker
+// errSkipBlock traps the guest calling `skip_block`.
+var errSkipBlock = errors.New("skip_block")
 
-	err := m.registerLoggerImports(linker)
-	if err != nil {
-		return fmt.Errorf("registering logger imports: %w", err)
+// params returns the params of the module in the current request, the same
+// for all its blocks.
+func (m *Module) params() string {
+	if m.request == nil || m.request.Modules == nil {
+		return ""
 	}
-	err = m.registerStateImports(linker)
-	if err != nil {
-		return fmt.Errorf("registering state imports: %w", err)
+	for _, module := range m.request.Modules.Modules {
+		if module.Name == m.name {
+			return module.Params
+		}
 	}
+	return ""
+}
 
-	if err = linker.FuncWrap("env", "register_panic",
-		func(msgPtr, msgLength int32, filenamePtr, filenameLength int32, lineNumber, columnNumber int32, caller *wasmtime.Caller) {
-			message := m.Heap.ReadString(msgPtr, msgLength)
-
-			var filename string
-			if filenamePtr != 0 {
-				filename = m.Heap.ReadString(filenamePtr, filenameLength)
+// clockImports expose the clock of the block being processed, so modules can
+// read it without decoding the serialized `Clock` input.
+func (m *Module) clockImports() []hostFunction {
+	return []hostFunction{
+		{namespace: "env", name: "clock_number", fn: func() int64 {
+			m.currentInstance.traceHostCall("env.clock_number", "", traceNoValue)
+			return int64(m.currentInstance.clock.GetNumber())
+		}},
+		{namespace: "env", name: "clock_id", fn: func(outputPtr int32) error {
+			m.currentInstance.traceHostCall("env.clock_id", "", traceNoValue)
+			if err := m.currentInstance.WriteOutputToHeap(outputPtr, []byte(m.currentInstance.clock.GetId()), "env.clock_id"); err != nil {
+				return err
 			}
+			return nil
+		}},
+		{namespace: "env", name: "clock_timestamp", fn: func() int64 {
+			m.currentInstance.traceHostCall("env.clock_timestamp", "", traceNoValue)
+			// Nanoseconds since the Unix epoch
+			return m.currentInstance.clock.GetTimestamp().AsTime().UnixNano()
+		}},
+	}
+}
+
+// logImports are the leveled log imports of the `logger` namespace.
+// `println` predates them, its logs are at the info level.
+var logImports = map[string]pbsubstreams.LogLevel{
+	"println": pbsubstreams.LogLevel_LOG_LEVEL_INFO,
+	"debug":   pbsubstreams.LogLevel_LOG_LEVEL_DEBUG,
+	"info":    pbsubstreams.LogLevel_LOG_LEVEL_INFO,
+	"warn":    pbsubstreams.LogLevel_LOG_LEVEL_WARN,
+	"error":   pbsubstreams.LogLevel_LOG_LEVEL_ERROR,
+}
 
-			m.CurrentInstance.panicError = &PanicError{message, filename, int(lineNumber), int(columnNumber)}
-		},
-	); err != nil {
-		return fmt.Errorf("registering panic import: %w", err)
+func (m *Module) loggerImports() (functions []hostFunction) {
+	for importName, level := range logImports {
+		level, call := level, "logger."+importName
+		functions = append(functions, hostFunction{namespace: "logger", name: importName, fn: func(ptr int32, length int32) error {
+			return m.log(call, level, ptr, length)
+		}})
 	}
+	return functions
+}
 
-	if err = linker.FuncWrap("env", "output",
-		func(ptr, length int32) {
-			message := m.Heap.ReadBytes(ptr, length)
-			m.CurrentInstance.returnValue = make([]byte, length)
-			copy(m.CurrentInstance.returnValue, message)
-		},
-	); err != nil {
-		return fmt.Errorf("registering output import: %w", err)
+func (m *Module) log(call string, level pbsubstreams.LogLevel, ptr int32, length int32) error {
+	if !m.shouldLog(level, length) {
+		return nil
 	}
 
+	if length > MaxLogByteCountCeiling {
+		panic(fmt.Errorf("message to log is too big, max size is %s", humanize.IBytes(MaxLogByteCountCeiling)))
+	}
+
+	message, trap := m.readGuestString(call, ptr, length)
+	if trap != nil {
+		return trap
+	}
+	m.appendLog(level, message)
 	return nil
 }
 
-func (m *Module) registerLoggerImports(linker *wasmtime.Linker) error {
-	if err := linker.FuncWrap("logger", "println",
-		func(ptr int32, length int32) {
-			if m.CurrentInstance.ReachedLogsMaxByteCount() {
-				// Early exit, we don't even need to collect the message as we would not store it anyway
-				return
-			}
+// shouldLog tells if a message of `length` bytes logged at `level` must be
+// read, counting it as dropped when the logs are truncated already.
+func (m *Module) shouldLog(level pbsubstreams.LogLevel, length int32) bool {
+	if level < m.minLogLevel() {
+		// Filtered out by the request, it does not count against the limit
+		return false
+	}
 
-			if length > maxLogByteCount {
-				panic(fmt.Errorf("message to log is too big, max size is %s", humanize.IBytes(uint64(length))))
-			}
+	if m.currentInstance.ReachedLogsMaxByteCount() {
+		// Early exit, we don't even need to collect the message as we would not store it anyway
+		m.currentInstance.dropLog(length)
+		return false
+	}
+	return true
+}
 
-			message := m.Heap.ReadString(ptr, length)
-			if tracer.Enabled() {
-				zlog.Debug(message, zap.String("module_name", m.CurrentInstance.Module.name), zap.String("wasm_file", m.CurrentInstance.Module.name))
-			}
+func (m *Module) appendLog(level pbsubstreams.LogLevel, message string) {
+	if tracer.Enabled() {
+		if ce := zlog.Check(zapLevels[level], message); ce != nil {
+			ce.Write(zap.String("module_name", m.currentInstance.Module.name), zap.String("wasm_file", m.currentInstance.Module.name))
+		}
+	}
 
-			// len(<string>) in Go count number of bytes and not characters, so we are good here
-			m.CurrentInstance.LogsByteCount += uint64(len(message))
-			if !m.CurrentInstance.ReachedLogsMaxByteCount() {
-				m.CurrentInstance.Logs = append(m.CurrentInstance.Logs, message)
-				m.CurrentInstance.PushExecutionStack(fmt.Sprintf("log: %s", message))
-			}
+	// len(<string>) in Go count number of bytes and not characters, so we are good here
+	m.currentInstance.LogsByteCount += uint64(len(message))
+	if !m.currentInstance.ReachedLogsMaxByteCount() {
+		m.currentInstance.Logs = append(m.currentInstance.Logs, message)
+		m.currentInstance.LogLevels = append(m.currentInstance.LogLevels, level)
+		m.currentInstance.PushExecutionStack(fmt.Sprintf("log: %s", message))
+	} else {
+		m.currentInstance.dropLog(int32(len(message)))
+	}
+}
 
-			return
-		},
-	); err != nil {
-		return fmt.Errorf("registering println import: %w", err)
+var zapLevels = map[pbsubstreams.LogLevel]zapcore.Level{
+	pbsubstreams.LogLevel_LOG_LEVEL_DEBUG: zapcore.DebugLevel,
+	pbsubstreams.LogLevel_LOG_LEVEL_INFO:  zapcore.InfoLevel,
+	pbsubstreams.LogLevel_LOG_LEVEL_WARN:  zapcore.WarnLevel,
+	pbsubstreams.LogLevel_LOG_LEVEL_ERROR: zapcore.ErrorLevel,
+}
+
+// maxLogByteCount returns the size of the logs kept for each execution, the
+// one of the runtime unless the current request lowers it.
+func (m *Module) maxLogByteCount() uint64 {
+	limit := m.runtime.maxLogByteCount
+	if m.request != nil && m.request.MaxLogByteCount != 0 && m.request.MaxLogByteCount < limit {
+		limit = m.request.MaxLogByteCount
 	}
-	return nil
+	return limit
+}
+
+// minLogLevel returns the level under which the logs are dropped, set by the
+// current request.
+func (m *Module) minLogLevel() pbsubstreams.LogLevel {
+	if m.request == nil {
+		return pbsubstreams.LogLevel_LOG_LEVEL_DEBUG
+	}
+	return m.request.MinLogLevel
 }
 
 type externError struct {
@@ -244,33 +557,35 @@ func returnError(moduleName string, cause error) {
 	panic(newExternError(moduleName, cause))
 }
 
-func (m *Module) registerStateImports(linker *wasmtime.Linker) error {
-	functions := map[string]interface{}{}
-	functions["set"] = m.set
-	functions["set_if_not_exists"] = m.setIfNotExists
-	functions["append"] = m.append
-	functions["delete_prefix"] = m.deletePrefix
-	functions["add_bigint"] = m.addBigInt
-	functions["add_bigfloat"] = m.addBigFloat
-	functions["add_int64"] = m.addInt64
-	functions["add_float64"] = m.addFloat64
-	functions["set_min_int64"] = m.setMinInt64
-	functions["set_min_bigint"] = m.setMinBigint
-	functions["set_min_float64"] = m.setMinfloat64
-	functions["set_min_bigfloat"] = m.setMinBigfloat
-	functions["set_max_int64"] = m.setMaxInt64
-	functions["set_max_bigint"] = m.setMaxBigint
-	functions["set_max_float64"] = m.setMaxFloat64
-	functions["set_max_bigfloat"] = m.setMaxBigfloat
-	functions["get_at"] = m.getAt
-	functions["get_first"] = m.getFirst
-	functions["get_last"] = m.getLast
-
-	for n, f := range functions {
-		if err := linker.FuncWrap("state", n, f); err != nil {
-			return fmt.Errorf("registering %s import: %w", n, err)
-		}
+func (m *Module) stateImports() (functions []hostFunction) {
+	stateFunctions := map[string]interface{}{}
+	stateFunctions["set"] = m.set
+	stateFunctions["set_if_not_exists"] = m.setIfNotExists
+	stateFunctions["append"] = m.append
+	stateFunctions["delete_prefix"] = m.deletePrefix
+	stateFunctions["add_bigint"] = m.addBigInt
+	stateFunctions["add_bigint_le"] = m.addBigIntLE
+	stateFunctions["add_bigfloat"] = m.addBigFloat
+	stateFunctions["add_int64"] = m.addInt64
+	stateFunctions["add_float64"] = m.addFloat64
+	stateFunctions["set_min_int64"] = m.setMinInt64
+	stateFunctions["set_min_bigint"] = m.setMinBigint
+	stateFunctions["set_min_bigint_le"] = m.setMinBigIntLE
+	stateFunctions["set_min_float64"] = m.setMinfloat64
+	stateFunctions["set_min_bigfloat"] = m.setMinBigfloat
+	stateFunctions["set_max_int64"] = m.setMaxInt64
+	stateFunctions["set_max_bigint"] = m.setMaxBigint
+	stateFunctions["set_max_bigint_le"] = m.setMaxBigIntLE
+	stateFunctions["set_max_float64"] = m.setMaxFloat64
+	stateFunctions["set_max_bigfloat"] = m.setMaxBigfloat
+	stateFunctions["get_at"] = m.getAt
+	stateFunctions["get_first"] = m.getFirst
+	stateFunctions["get_last"] = m.getLast
+	stateFunctions["has_at"] = m.hasAt
+	stateFunctions["has_last"] = m.hasLast
+
+	for n, f := range stateFunctions {
+		functions = append(functions, hostFunction{namespace: "state", name: n, fn: f})
 	}
-
-	return nil
+	return functions
 }
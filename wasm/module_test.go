@@ -0,0 +1,581 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// counterWAT increments a counter kept in memory and another one kept in an
+// exported global on every call, and outputs both. It traps after the
+// increments when its input is a single byte.
+const counterWAT = `
+(module
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (global $counter (export "counter") (mut i32) (i32.const 0))
+
+  ;; bump allocator, its top is kept in memory at offset 4
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (i32.add (i32.load (i32.const 4)) (i32.const 1024)))
+    (i32.store (i32.const 4) (i32.add (i32.load (i32.const 4)) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+
+  (func (export "map_counter") (param $ptr i32) (param $len i32)
+    (i32.store (i32.const 0) (i32.add (i32.load (i32.const 0)) (i32.const 1)))
+    (global.set $counter (i32.add (global.get $counter) (i32.const 1)))
+    (if (i32.eq (local.get $len) (i32.const 1)) (then unreachable))
+    (i32.store (i32.const 8) (i32.load (i32.const 0)))
+    (i32.store (i32.const 12) (global.get $counter))
+    (call $output (i32.const 8) (i32.const 8)))
+)
+`
+
+func newCounterModule(t *testing.T) *Module {
+	t.Helper()
+
+	code, err := wasmtime.Wat2Wasm(counterWAT)
+	require.NoError(t, err)
+
+	module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "counter", "map_counter")
+	require.NoError(t, err)
+	return module
+}
+
+func executeCounter(t *testing.T, module *Module, input []byte) (memoryCounter, globalCounter uint32, err error) {
+	t.Helper()
+
+	instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: input}})
+	require.NoError(t, err)
+
+	if err := instance.Execute(context.Background()); err != nil {
+		return 0, 0, err
+	}
+
+	output := instance.Output()
+	require.Len(t, output, 8)
+	return binary.LittleEndian.Uint32(output[0:4]), binary.LittleEndian.Uint32(output[4:8]), nil
+}
+
+func TestModule_NoGuestStateLeakBetweenCalls(t *testing.T) {
+	module := newCounterModule(t)
+	firstInstance := module.wasmInstance
+
+	for i := 0; i < 5; i++ {
+		memoryCounter, globalCounter, err := executeCounter(t, module, []byte("block"))
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), memoryCounter, "stale guest memory at call %d", i)
+		assert.Equal(t, uint32(1), globalCounter, "stale guest global at call %d", i)
+	}
+
+	assert.Same(t, firstInstance, module.wasmInstance, "instance should have been reused")
+}
+
+func TestModule_RecreatesInstanceAfterTrap(t *testing.T) {
+	module := newCounterModule(t)
+	firstInstance := module.wasmInstance
+
+	_, _, err := executeCounter(t, module, []byte{0x01})
+	require.Error(t, err)
+
+	memoryCounter, globalCounter, err := executeCounter(t, module, []byte("block"))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), memoryCounter)
+	assert.Equal(t, uint32(1), globalCounter)
+	assert.NotSame(t, firstInstance, module.wasmInstance)
+}
+
+func TestModule_RecreatesInstanceAfterMaxCalls(t *testing.T) {
+	module := newCounterModule(t)
+	module.maxCallCount = 2
+
+	var instances []engineInstance
+	for i := 0; i < 3; i++ {
+		_, _, err := executeCounter(t, module, []byte("block"))
+		require.NoError(t, err)
+		instances = append(instances, module.wasmInstance)
+	}
+
+	assert.Same(t, instances[0], instances[1])
+	assert.NotSame(t, instances[1], instances[2])
+}
+
+// growWAT grows its memory by a page on each call.
+const growWAT = `
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_grow") (param $ptr i32) (param $len i32)
+    (drop (memory.grow (i32.const 1))))
+)
+`
+
+func TestInstance_Close(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reset", func(t *testing.T) {
+		module := newCounterModule(t)
+		firstInstance := module.wasmInstance
+
+		instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+		require.NoError(t, err)
+		require.NoError(t, instance.Execute(ctx))
+		require.NoError(t, instance.Close(ctx))
+		assert.True(t, module.clean)
+		assert.Same(t, firstInstance, module.wasmInstance)
+		assert.Equal(t, []byte{1, 0, 0, 0, 1, 0, 0, 0}, instance.Output(), "the output outlives the guest memory")
+
+		require.NoError(t, instance.Close(ctx), "closing twice")
+	})
+
+	t.Run("released", func(t *testing.T) {
+		code, err := wasmtime.Wat2Wasm(growWAT)
+		require.NoError(t, err)
+		module, err := NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "grow", "map_grow")
+		require.NoError(t, err)
+		initialSize := module.MemorySize()
+
+		for i := 0; i < 1000; i++ {
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+			require.NoError(t, err)
+			require.Equal(t, initialSize, module.MemorySize(), "block %d", i)
+			require.NoError(t, instance.Execute(ctx))
+			require.Equal(t, initialSize+wasmPageSize, module.MemorySize(), "block %d", i)
+
+			require.NoError(t, instance.Close(ctx))
+			require.True(t, module.released, "block %d", i)
+			require.Nil(t, module.wasmInstance, "block %d", i)
+			require.NoError(t, instance.Close(ctx))
+		}
+	})
+
+	t.Run("stale instance", func(t *testing.T) {
+		module := newCounterModule(t)
+		stale, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+		require.NoError(t, err)
+		require.NoError(t, stale.Execute(ctx))
+
+		current, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+		require.NoError(t, err)
+		require.NoError(t, current.Execute(ctx))
+		require.NoError(t, stale.Close(ctx))
+		assert.False(t, module.clean, "the current call is left alone")
+	})
+}
+
+// loopWAT loops as many times as its input has bytes.
+const loopWAT = `
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_loop") (param $ptr i32) (param $len i32)
+    (block $done
+      (loop $next
+        (br_if $done (i32.eqz (local.get $len)))
+        (local.set $len (i32.sub (local.get $len) (i32.const 1)))
+        (br $next))))
+)
+`
+
+func TestModule_FuelLimit(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(loopWAT)
+	require.NoError(t, err)
+	module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "loop", "map_loop")
+	require.NoError(t, err)
+
+	execute := func(loops int) error {
+		instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: make([]byte, loops)}})
+		require.NoError(t, err)
+		return instance.Execute(context.Background())
+	}
+
+	// Measured below the limit, and proportional to the work done
+	require.NoError(t, execute(10))
+	small := module.FuelConsumed()
+	require.NoError(t, execute(1000))
+	large := module.FuelConsumed()
+	assert.Greater(t, large, 10*small)
+
+	module.SetFuelLimit(large / 2)
+	var errs []string
+	for i := 0; i < 2; i++ {
+		err = execute(1000)
+		var budgetErr *ExecutionBudgetExceededError
+		require.ErrorAs(t, err, &budgetErr)
+		assert.Equal(t, large/2, budgetErr.Limit)
+		errs = append(errs, err.Error())
+	}
+	assert.Equal(t, errs[0], errs[1], "the failure must be deterministic")
+	assert.Contains(t, errs[0], `executing module "loop": execution budget exceeded:`)
+
+	// The instance is recreated after the failure
+	require.NoError(t, execute(10))
+	assert.Equal(t, small, module.FuelConsumed())
+}
+
+// spinWAT loops forever.
+const spinWAT = `
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_spin") (param $ptr i32) (param $len i32)
+    (loop $forever (br $forever)))
+)
+`
+
+func TestModule_Interruption(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(spinWAT)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		ctx         func() (context.Context, context.CancelFunc)
+		expectCause error
+	}{
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			expectCause: context.DeadlineExceeded,
+		},
+		{
+			name: "cancellation",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			expectCause: context.Canceled,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "spin", "map_spin")
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+			require.NoError(t, err)
+
+			ctx, cancel := test.ctx()
+			defer cancel()
+
+			start := time.Now()
+			err = instance.Execute(ctx)
+			assert.Less(t, time.Since(start), 5*time.Second)
+
+			var interrupted *InterruptedError
+			require.ErrorAs(t, err, &interrupted)
+			assert.ErrorIs(t, err, test.expectCause)
+
+			// Already done, not executed at all
+			instance, err = module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+			require.NoError(t, err)
+			require.ErrorAs(t, instance.Execute(ctx), &interrupted)
+		})
+	}
+
+	// The interruption of a module does not affect another one
+	counter := newCounterModule(t)
+	_, _, err = executeCounter(t, counter, []byte("block"))
+	require.NoError(t, err)
+}
+
+// paramsWAT outputs its params.
+const paramsWAT = `
+(module
+  (import "env" "params" (func $params (param i32)))
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (global $top (mut i32) (i32.const 1024))
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $top))
+    (global.set $top (i32.add (global.get $top) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_params") (param $ptr i32) (param $len i32)
+    (call $params (i32.const 16))
+    (call $output (i32.load (i32.const 16)) (i32.load (i32.const 20))))
+)
+`
+
+func TestModule_Params(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(paramsWAT)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		request      *pbsubstreams.Request
+		expectOutput string
+	}{
+		{
+			name: "set",
+			request: &pbsubstreams.Request{Modules: &pbsubstreams.Modules{Modules: []*pbsubstreams.Module{
+				{Name: "other", Params: "other_params"},
+				{Name: "params", Params: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"},
+			}}},
+			expectOutput: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+		},
+		{
+			name:         "unset",
+			request:      &pbsubstreams.Request{},
+			expectOutput: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(context.Background(), test.request, code, "params", "map_params")
+			require.NoError(t, err)
+
+			// Same value on every block
+			for i := 0; i < 2; i++ {
+				instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+				require.NoError(t, err)
+				require.NoError(t, instance.Execute(context.Background()))
+				assert.Equal(t, test.expectOutput, string(instance.Output()))
+			}
+		})
+	}
+}
+
+// leveledLogsWAT logs "d", "i", "w", "e" and "p" through the debug, info,
+// warn, error and println imports.
+const leveledLogsWAT = `
+(module
+  (import "logger" "debug" (func $debug (param i32 i32)))
+  (import "logger" "info" (func $info (param i32 i32)))
+  (import "logger" "warn" (func $warn (param i32 i32)))
+  (import "logger" "error" (func $error (param i32 i32)))
+  (import "logger" "println" (func $println (param i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "diwep")
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_logs") (param $ptr i32) (param $len i32)
+    (call $debug (i32.const 0) (i32.const 1))
+    (call $info (i32.const 1) (i32.const 1))
+    (call $warn (i32.const 2) (i32.const 1))
+    (call $error (i32.const 3) (i32.const 1))
+    (call $println (i32.const 4) (i32.const 1)))
+)
+`
+
+func TestModule_LeveledLogs(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(leveledLogsWAT)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		minLevel     pbsubstreams.LogLevel
+		expectLogs   []string
+		expectLevels []pbsubstreams.LogLevel
+	}{
+		{
+			name:       "all",
+			minLevel:   pbsubstreams.LogLevel_LOG_LEVEL_DEBUG,
+			expectLogs: []string{"d", "i", "w", "e", "p"},
+			expectLevels: []pbsubstreams.LogLevel{
+				pbsubstreams.LogLevel_LOG_LEVEL_DEBUG,
+				pbsubstreams.LogLevel_LOG_LEVEL_INFO,
+				pbsubstreams.LogLevel_LOG_LEVEL_WARN,
+				pbsubstreams.LogLevel_LOG_LEVEL_ERROR,
+				pbsubstreams.LogLevel_LOG_LEVEL_INFO,
+			},
+		},
+		{
+			name:       "warn",
+			minLevel:   pbsubstreams.LogLevel_LOG_LEVEL_WARN,
+			expectLogs: []string{"w", "e"},
+			expectLevels: []pbsubstreams.LogLevel{
+				pbsubstreams.LogLevel_LOG_LEVEL_WARN,
+				pbsubstreams.LogLevel_LOG_LEVEL_ERROR,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{MinLogLevel: test.minLevel}, code, "logs", "map_logs")
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+			require.NoError(t, err)
+			require.NoError(t, instance.Execute(context.Background()))
+
+			assert.Equal(t, test.expectLogs, instance.Logs)
+			assert.Equal(t, test.expectLevels, instance.LogLevels)
+			// Dropped logs do not count against the limit
+			assert.Equal(t, uint64(len(test.expectLogs)), instance.LogsByteCount)
+		})
+	}
+}
+
+func TestModule_MaxLogByteCount(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(leveledLogsWAT)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		runtimeLimit       uint64
+		requestLimit       uint64
+		expectLogs         []string
+		expectDropped      uint64
+		expectDroppedBytes uint64
+	}{
+		{"default", 0, 0, []string{"d", "i", "w", "e", "p"}, 0, 0},
+		// The log reaching the limit is dropped too
+		{"runtime limit", 2, 0, []string{"d"}, 4, 4},
+		{"lowered by request", 0, 3, []string{"d", "i"}, 3, 3},
+		{"request cannot raise it", 2, 4, []string{"d"}, 4, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var opts []RuntimeOption
+			if test.runtimeLimit != 0 {
+				opts = append(opts, WithMaxLogByteCount(test.runtimeLimit))
+			}
+			request := &pbsubstreams.Request{MaxLogByteCount: test.requestLimit}
+			module, err := NewRuntime(nil, opts...).NewModule(context.Background(), request, code, "logs", "map_logs")
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+			require.NoError(t, err)
+			require.NoError(t, instance.Execute(context.Background()))
+
+			assert.Equal(t, test.expectLogs, instance.Logs)
+			assert.Equal(t, test.expectDropped != 0, instance.ReachedLogsMaxByteCount())
+			assert.Equal(t, test.expectDropped, instance.DroppedLogs)
+			assert.Equal(t, test.expectDroppedBytes, instance.DroppedLogsByteCount)
+		})
+	}
+
+	assert.Equal(t, uint64(MaxLogByteCountCeiling), NewRuntime(nil, WithMaxLogByteCount(1<<40)).maxLogByteCount)
+}
+
+// clockWAT outputs the block number and timestamp then the block id, read
+// through the clock imports.
+const clockWAT = `
+(module
+  (import "env" "clock_number" (func $clock_number (result i64)))
+  (import "env" "clock_id" (func $clock_id (param i32)))
+  (import "env" "clock_timestamp" (func $clock_timestamp (result i64)))
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (global $top (mut i32) (i32.const 1024))
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $top))
+    (global.set $top (i32.add (global.get $top) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_clock") (param $ptr i32) (param $len i32)
+    (i64.store (i32.const 16) (call $clock_number))
+    (i64.store (i32.const 24) (call $clock_timestamp))
+    (call $clock_id (i32.const 8))
+    (memory.copy (i32.const 32) (i32.load (i32.const 8)) (i32.load (i32.const 12)))
+    (call $output (i32.const 16) (i32.add (i32.const 16) (i32.load (i32.const 12)))))
+)
+`
+
+func TestModule_ClockImports(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(clockWAT)
+	require.NoError(t, err)
+	module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "clock", "map_clock")
+	require.NoError(t, err)
+
+	timestamp := time.Date(2022, 9, 1, 12, 0, 0, 42, time.UTC)
+	clock := &pbsubstreams.Clock{Id: "00fa12", Number: 15_000_000, Timestamp: timestamppb.New(timestamp)}
+	instance, err := module.NewInstance(clock, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+	require.NoError(t, err)
+	require.NoError(t, instance.Execute(context.Background()))
+
+	output := instance.Output()
+	require.Len(t, output, 22)
+	assert.Equal(t, uint64(15_000_000), binary.LittleEndian.Uint64(output[0:8]))
+	assert.Equal(t, uint64(timestamp.UnixNano()), binary.LittleEndian.Uint64(output[8:16]))
+	assert.Equal(t, "00fa12", string(output[16:]))
+}
+
+// noopWAT does nothing with its inputs.
+const noopWAT = `
+(module
+  (import "env" "clock_number" (func $clock_number (result i64)))
+  (import "env" "clock_id" (func $clock_id (param i32)))
+  (import "env" "clock_timestamp" (func $clock_timestamp (result i64)))
+  (memory (export "memory") 1)
+  (global $top (mut i32) (i32.const 1024))
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $top))
+    (global.set $top (i32.add (global.get $top) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_noop") (param $ptr i32) (param $len i32))
+  (func (export "map_noop_clock") (param $ptr i32) (param $len i32) (param $clockPtr i32) (param $clockLen i32))
+  (func (export "map_clock_imports") (param $ptr i32) (param $len i32)
+    (drop (call $clock_number))
+    (drop (call $clock_timestamp))
+    (call $clock_id (i32.const 8)))
+)
+`
+
+// BenchmarkClock compares receiving the clock as a serialized input with
+// reading it through the clock imports, for a module doing nothing else. The
+// serialized input is not even decoded by the guest here, which only adds to
+// its cost.
+func BenchmarkClock(b *testing.B) {
+	code, err := wasmtime.Wat2Wasm(noopWAT)
+	require.NoError(b, err)
+	clock := &pbsubstreams.Clock{Id: "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90", Number: 15_000_000, Timestamp: timestamppb.Now()}
+	block := []byte("block")
+
+	run := func(b *testing.B, entrypoint string, inputs func() []*Input) {
+		module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "noop", entrypoint)
+		require.NoError(b, err)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			instance, err := module.NewInstance(clock, inputs())
+			if err == nil {
+				err = instance.Execute(context.Background())
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("no_clock", func(b *testing.B) {
+		run(b, "map_noop", func() []*Input {
+			return []*Input{{Type: InputSource, Name: "in", StreamData: block}}
+		})
+	})
+	b.Run("serialized_input", func(b *testing.B) {
+		run(b, "map_noop_clock", func() []*Input {
+			clockBytes, err := proto.Marshal(clock)
+			require.NoError(b, err)
+			return []*Input{
+				{Type: InputSource, Name: "in", StreamData: block},
+				{Type: InputSource, Name: "sf.substreams.v1.Clock", StreamData: clockBytes},
+			}
+		})
+	})
+	b.Run("imports", func(b *testing.B) {
+		run(b, "map_clock_imports", func() []*Input {
+			return []*Input{{Type: InputSource, Name: "in", StreamData: block}}
+		})
+	})
+}
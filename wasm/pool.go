@@ -0,0 +1,169 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// DefaultMaxIdleInstances is the number of idle modules kept per module hash
+// by a ModulePool.
+const DefaultMaxIdleInstances = 4
+
+// ModulePool shares compiled wasm code and instantiated modules across
+// requests. The code is compiled once per module hash, and modules returned
+// after an execution are handed to the next checkout of the same hash instead
+// of being instantiated again. Each module still gets its own engine, so it
+// can be interrupted alone, loading the compiled code from its serialized
+// form.
+//
+// A module is used by a single execution at a time: it is owned by whoever
+// checked it out until it is returned.
+type ModulePool struct {
+	runtime          *Runtime
+	engine           engine // only compiles the code
+	maxIdleInstances int
+	compilationCache *CompilationCache // nil when the compiled code is not kept on disk
+
+	lock    sync.Mutex
+	entries map[string]*poolEntry
+}
+
+type poolEntry struct {
+	compileOnce sync.Once
+	serialized  []byte // compiled code
+	exports     []externType
+	imports     []externType
+	compileErr  error
+
+	idle []*Module // guarded by the pool lock
+}
+
+type PoolOption func(p *ModulePool)
+
+// WithCompilationCache loads the compiled code of the modules from `cache`
+// when it holds them, and writes it there otherwise.
+func WithCompilationCache(cache *CompilationCache) PoolOption {
+	return func(p *ModulePool) {
+		p.compilationCache = cache
+	}
+}
+
+func NewModulePool(runtime *Runtime, maxIdleInstances int, opts ...PoolOption) *ModulePool {
+	p := &ModulePool{
+		runtime:          runtime,
+		engine:           runtime.newEngine(),
+		maxIdleInstances: maxIdleInstances,
+		entries:          map[string]*poolEntry{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Checkout returns a module ready to execute `entrypoint`, reusing an idle
+// one of the same `moduleHash` when there is one. `wasmCode` is only compiled
+// the first time the hash is seen.
+func (p *ModulePool) Checkout(ctx context.Context, request *pbsubstreams.Request, moduleHash string, wasmCode []byte, name string, entrypoint string) (*Module, error) {
+	entry := p.entry(moduleHash)
+	p.lock.Lock()
+	var m *Module
+	if count := len(entry.idle); count > 0 {
+		m = entry.idle[count-1]
+		entry.idle[count-1] = nil
+		entry.idle = entry.idle[:count-1]
+	}
+	p.lock.Unlock()
+
+	if m != nil {
+		m.ctx = ctx
+		m.request = request
+		m.name = name
+		m.entrypoint = entrypoint
+		return m, nil
+	}
+
+	p.compileEntry(entry, moduleHash, wasmCode)
+	if entry.compileErr != nil {
+		return nil, fmt.Errorf("creating new module: %w", entry.compileErr)
+	}
+
+	e := p.runtime.newEngine()
+	compiled, err := e.deserialize(entry.serialized)
+	if err != nil {
+		return nil, fmt.Errorf("loading compiled module: %w", err)
+	}
+	m, err = p.runtime.newModule(ctx, request, e, compiled, wasmCode, name, entrypoint)
+	if err != nil {
+		return nil, err
+	}
+	m.hash = moduleHash
+	return m, nil
+}
+
+func (p *ModulePool) entry(moduleHash string) *poolEntry {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	entry, found := p.entries[moduleHash]
+	if !found {
+		entry = &poolEntry{}
+		p.entries[moduleHash] = entry
+	}
+	return entry
+}
+
+// compileEntry compiles `wasmCode` the first time it is called for `entry`.
+func (p *ModulePool) compileEntry(entry *poolEntry, moduleHash string, wasmCode []byte) {
+	entry.compileOnce.Do(func() {
+		compiled, err := p.compilationCache.compile(p.engine, moduleHash, wasmCode)
+		if err != nil {
+			entry.compileErr = err
+			return
+		}
+		entry.exports = compiled.exports()
+		entry.imports = compiled.imports()
+		entry.serialized, entry.compileErr = compiled.serialize()
+	})
+}
+
+// Return hands `m` back to the pool once its execution is over. Modules whose
+// last call trapped are dropped, their wasm instance released right away, the
+// others are reset before being kept, as long as the pool holds less than its
+// maximum of idle modules for the hash.
+func (p *ModulePool) Return(m *Module) {
+	m.ctx = nil
+	m.request = nil
+
+	if m.hash == "" {
+		return
+	}
+	if m.instanceFailed {
+		m.release()
+		return
+	}
+	if err := m.resetInstance(); err != nil {
+		m.release()
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	entry, found := p.entries[m.hash]
+	if !found || len(entry.idle) >= p.maxIdleInstances {
+		return
+	}
+	entry.idle = append(entry.idle, m)
+}
+
+// IdleCount returns the number of idle modules kept for `moduleHash`.
+func (p *ModulePool) IdleCount(moduleHash string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if entry, found := p.entries[moduleHash]; found {
+		return len(entry.idle)
+	}
+	return 0
+}
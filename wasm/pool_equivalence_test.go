@@ -0,0 +1,286 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// skipWAT outputs "out", unless its input is a single byte, in which case it
+// skips the block.
+const skipWAT = `
+(module
+  (import "env" "skip_block" (func $skip_block))
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "out")
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_skip") (param $ptr i32) (param $len i32)
+    (if (i32.eq (local.get $len) (i32.const 1)) (then (call $skip_block) unreachable))
+    (call $output (i32.const 0) (i32.const 3)))
+)
+`
+
+// equivalenceFixture is a module executed on a few blocks in a row, by the
+// same module, with the expected result of each block. The expected fields
+// left empty are only compared between the pooled and unpooled modules.
+type equivalenceFixture struct {
+	name          string
+	wat           string
+	entrypoint    string
+	binaryType    string
+	params        string
+	fuelLimit     uint64
+	maxMemorySize uint64
+	inputStore    bool
+	outputStore   bool
+
+	// Each block gets `input` as source input, or `args` when set
+	blocks []equivalenceBlock
+}
+
+type equivalenceBlock struct {
+	input []byte
+	args  []interface{}
+
+	expectOutput  []byte
+	expectSkipped bool
+	expectLogs    []string
+	expectFailure string
+}
+
+// equivalenceResult is what the execution of a block produced, the deltas
+// and the panic, fuel and memory failures serialized. The failures of the
+// other traps are only recorded as such.
+type equivalenceResult struct {
+	Output    []byte
+	Skipped   bool
+	Logs      []string
+	LogLevels []pbsubstreams.LogLevel
+	Deltas    [][]byte
+	Failure   string
+}
+
+var equivalenceClock = &pbsubstreams.Clock{
+	Id:        "00fa12",
+	Number:    15_000_000,
+	Timestamp: timestamppb.New(time.Date(2022, 9, 1, 12, 0, 0, 42, time.UTC)),
+}
+
+func equivalenceFixtures() []equivalenceFixture {
+	clockOutput := make([]byte, 16)
+	binary.LittleEndian.PutUint64(clockOutput[0:8], equivalenceClock.Number)
+	binary.LittleEndian.PutUint64(clockOutput[8:16], uint64(equivalenceClock.Timestamp.AsTime().UnixNano()))
+	clockOutput = append(clockOutput, equivalenceClock.Id...)
+
+	return []equivalenceFixture{
+		{
+			name: "reset between blocks", wat: counterWAT, entrypoint: "map_counter",
+			blocks: []equivalenceBlock{
+				{input: []byte("block"), expectOutput: []byte{1, 0, 0, 0, 1, 0, 0, 0}},
+				{input: []byte("block"), expectOutput: []byte{1, 0, 0, 0, 1, 0, 0, 0}},
+				{input: []byte{0x01}, expectFailure: "trap"},
+				{input: []byte("block"), expectOutput: []byte{1, 0, 0, 0, 1, 0, 0, 0}},
+			},
+		},
+		{
+			name: "clock", wat: clockWAT, entrypoint: "map_clock",
+			blocks: []equivalenceBlock{{input: []byte("block"), expectOutput: clockOutput}},
+		},
+		{
+			name: "params", wat: paramsWAT, entrypoint: "map_params", params: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+			blocks: []equivalenceBlock{{input: []byte("block"), expectOutput: []byte("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")}},
+		},
+		{
+			name: "logs", wat: leveledLogsWAT, entrypoint: "map_logs",
+			blocks: []equivalenceBlock{{input: []byte("block"), expectLogs: []string{"d", "i", "w", "e", "p"}}},
+		},
+		{
+			name: "skip block", wat: skipWAT, entrypoint: "map_skip",
+			blocks: []equivalenceBlock{
+				{input: []byte{0x01}, expectSkipped: true},
+				{input: []byte("block"), expectOutput: []byte("out")},
+			},
+		},
+		{
+			name: "panic", wat: panicWAT, entrypoint: "panic_with",
+			blocks: []equivalenceBlock{
+				{args: []interface{}{int32(0), int32(4), int32(16), int32(10), int32(42), int32(7)}, expectFailure: "panic in panic_with at src/lib.rs:42: boom"},
+			},
+		},
+		{
+			name: "wasi", wat: wasiWAT, entrypoint: "map_wasi", binaryType: WASIBinaryType,
+			blocks: []equivalenceBlock{
+				{input: []byte("block"), expectLogs: []string{"hello"}},
+				{input: []byte{0x01}, expectFailure: "trap"},
+			},
+		},
+		{
+			name: "store reads", wat: readTwiceWAT, entrypoint: "map_read_twice", inputStore: true,
+			blocks: []equivalenceBlock{{}, {}},
+		},
+		{
+			name: "store writes", wat: deletePrefixWAT, entrypoint: "store_write", outputStore: true,
+			blocks: []equivalenceBlock{{}},
+		},
+		{
+			name: "out of bounds", wat: deletePrefixWAT, entrypoint: "write_then_delete_at", outputStore: true,
+			blocks: []equivalenceBlock{{args: []interface{}{int64(6), int32(-8), int32(16)}, expectFailure: "trap"}},
+		},
+		{
+			name: "fuel limit", wat: loopWAT, entrypoint: "map_loop", fuelLimit: 1000,
+			blocks: []equivalenceBlock{
+				{input: make([]byte, 10)},
+				{input: make([]byte, 1000), expectFailure: "execution budget exceeded: 1001 fuel consumed, limit is 1000"},
+			},
+		},
+		{
+			name: "memory limit", wat: allocWAT, entrypoint: "map_alloc", maxMemorySize: 4 * wasmPageSize,
+			blocks: []equivalenceBlock{
+				{input: []byte{}},
+				{input: []byte("block"), expectFailure: "wasm memory limit of 262144 bytes reached"},
+				{input: []byte{}},
+			},
+		},
+	}
+}
+
+// TestModulePoolEquivalence runs the fixtures through a module created on its
+// own and through a ModulePool, which loads the compiled code from its
+// serialized form: both must give the same results.
+func TestModulePoolEquivalence(t *testing.T) {
+	for _, fixture := range equivalenceFixtures() {
+		t.Run(fixture.name, func(t *testing.T) {
+			var reference []equivalenceResult
+			for _, pooled := range []bool{false, true} {
+				results := runEquivalenceFixture(t, fixture, pooled)
+				for i, block := range fixture.blocks {
+					assertEquivalenceBlock(t, block, results[i], "pooled %t, block %d", pooled, i)
+				}
+
+				if reference == nil {
+					reference = results
+					continue
+				}
+				assert.Equal(t, reference, results, "pooled %t", pooled)
+			}
+		})
+	}
+}
+
+func assertEquivalenceBlock(t *testing.T, block equivalenceBlock, result equivalenceResult, msgAndArgs ...interface{}) {
+	t.Helper()
+
+	assert.Equal(t, block.expectFailure, result.Failure, msgAndArgs...)
+	assert.Equal(t, block.expectSkipped, result.Skipped, msgAndArgs...)
+	if block.expectOutput != nil {
+		assert.Equal(t, block.expectOutput, result.Output, msgAndArgs...)
+	}
+	if block.expectLogs != nil {
+		assert.Equal(t, block.expectLogs, result.Logs, msgAndArgs...)
+	}
+}
+
+func runEquivalenceFixture(t *testing.T, fixture equivalenceFixture, pooled bool) []equivalenceResult {
+	t.Helper()
+	ctx := context.Background()
+
+	code, err := wasmtime.Wat2Wasm(fixture.wat)
+	require.NoError(t, err)
+	if fixture.maxMemorySize != 0 {
+		code, err = LimitMemory(code, fixture.maxMemorySize)
+		require.NoError(t, err)
+	}
+
+	binaryType := fixture.binaryType
+	if binaryType == "" {
+		binaryType = "wasm/rust-v1"
+	}
+	request := &pbsubstreams.Request{Modules: &pbsubstreams.Modules{
+		Modules:  []*pbsubstreams.Module{{Name: fixture.entrypoint, Params: fixture.params}},
+		Binaries: []*pbsubstreams.Binary{{Type: binaryType, Content: code}},
+	}}
+
+	runtime := NewRuntime(nil)
+	var module *Module
+	if pooled {
+		module, err = NewModulePool(runtime, 1).Checkout(ctx, request, "hash", code, fixture.entrypoint, fixture.entrypoint)
+	} else {
+		module, err = runtime.NewModule(ctx, request, code, fixture.entrypoint, fixture.entrypoint)
+	}
+	require.NoError(t, err)
+	module.SetFuelLimit(fixture.fuelLimit)
+	module.SetMaxMemorySize(fixture.maxMemorySize)
+
+	var results []equivalenceResult
+	for _, block := range fixture.blocks {
+		var inputs []*Input
+		var outputStore *state.Store
+		switch {
+		case fixture.inputStore:
+			inputs = append(inputs, &Input{Type: InputStore, Name: "store_a", Store: newTestStore(t)})
+		case fixture.outputStore:
+			outputStore, err = state.NewStore("store_a", 10, 0, "hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, state.OutputValueTypeString, dstore.NewMockStore(nil), zap.NewNop())
+			require.NoError(t, err)
+			inputs = append(inputs, &Input{Type: OutputStore, Name: "store_a", Store: outputStore, UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET})
+		default:
+			inputs = append(inputs, &Input{Type: InputSource, Name: "in", StreamData: block.input})
+		}
+
+		instance, err := module.NewInstance(equivalenceClock, inputs)
+		require.NoError(t, err)
+		if block.args != nil {
+			err = instance.ExecuteWithArgs(ctx, block.args...)
+		} else {
+			err = instance.Execute(ctx)
+		}
+
+		result := equivalenceResult{
+			Output:    instance.Output(),
+			Skipped:   instance.Skipped(),
+			Logs:      instance.Logs,
+			LogLevels: instance.LogLevels,
+			Failure:   equivalenceFailure(err),
+		}
+		if outputStore != nil {
+			for _, delta := range outputStore.Deltas {
+				serialized, err := proto.MarshalOptions{Deterministic: true}.Marshal(delta)
+				require.NoError(t, err)
+				result.Deltas = append(result.Deltas, serialized)
+			}
+		}
+		results = append(results, result)
+		require.NoError(t, instance.Close(ctx))
+	}
+	return results
+}
+
+func equivalenceFailure(err error) string {
+	var panicErr *PanicError
+	var budgetErr *ExecutionBudgetExceededError
+	var memoryErr *MemoryLimitError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &panicErr):
+		return panicErr.Error()
+	case errors.As(err, &budgetErr):
+		return budgetErr.Error()
+	case errors.As(err, &memoryErr):
+		return memoryErr.Error()
+	}
+	return "trap"
+}
@@ -0,0 +1,155 @@
+package wasm
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCounterPool(t testing.TB, maxIdle int) (*ModulePool, []byte) {
+	t.Helper()
+
+	code, err := wasmtime.Wat2Wasm(counterWAT)
+	require.NoError(t, err)
+	return NewModulePool(NewRuntime(nil), maxIdle), code
+}
+
+func TestModulePool_ReusesModules(t *testing.T) {
+	pool, code := newCounterPool(t, 2)
+	ctx := context.Background()
+
+	first, err := pool.Checkout(ctx, &pbsubstreams.Request{}, "hash_a", code, "counter", "map_counter")
+	require.NoError(t, err)
+	memoryCounter, globalCounter, err := executeCounter(t, first, []byte("block"))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), memoryCounter)
+	assert.Equal(t, uint32(1), globalCounter)
+	pool.Return(first)
+	assert.Equal(t, 1, pool.IdleCount("hash_a"))
+
+	// Nothing leaks from the previous checkout
+	second, err := pool.Checkout(ctx, &pbsubstreams.Request{}, "hash_a", code, "counter", "map_counter")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Nil(t, second.currentInstance)
+	memoryCounter, globalCounter, err = executeCounter(t, second, []byte("block"))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), memoryCounter)
+	assert.Equal(t, uint32(1), globalCounter)
+
+	// Another hash gets its own modules
+	other, err := pool.Checkout(ctx, &pbsubstreams.Request{}, "hash_b", code, "counter", "map_counter")
+	require.NoError(t, err)
+	assert.NotSame(t, second, other)
+}
+
+func TestModulePool_DropsFailedModules(t *testing.T) {
+	pool, code := newCounterPool(t, 2)
+	ctx := context.Background()
+
+	module, err := pool.Checkout(ctx, &pbsubstreams.Request{}, "hash_a", code, "counter", "map_counter")
+	require.NoError(t, err)
+	_, _, err = executeCounter(t, module, []byte{0x01})
+	require.Error(t, err)
+
+	pool.Return(module)
+	assert.Equal(t, 0, pool.IdleCount("hash_a"))
+
+	next, err := pool.Checkout(ctx, &pbsubstreams.Request{}, "hash_a", code, "counter", "map_counter")
+	require.NoError(t, err)
+	assert.NotSame(t, module, next)
+}
+
+func TestModulePool_MaxIdleInstances(t *testing.T) {
+	pool, code := newCounterPool(t, 2)
+	ctx := context.Background()
+
+	var modules []*Module
+	for i := 0; i < 3; i++ {
+		module, err := pool.Checkout(ctx, &pbsubstreams.Request{}, "hash_a", code, "counter", "map_counter")
+		require.NoError(t, err)
+		modules = append(modules, module)
+	}
+	for _, module := range modules {
+		pool.Return(module)
+	}
+	assert.Equal(t, 2, pool.IdleCount("hash_a"))
+}
+
+func TestModulePool_InvalidCode(t *testing.T) {
+	pool := NewModulePool(NewRuntime(nil), 2)
+
+	_, err := pool.Checkout(context.Background(), &pbsubstreams.Request{}, "hash_a", []byte("garbage"), "counter", "map_counter")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "creating new module")
+}
+
+func TestModulePool_ConcurrentCheckouts(t *testing.T) {
+	pool, code := newCounterPool(t, 4)
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				module, err := pool.Checkout(context.Background(), &pbsubstreams.Request{}, "hash_a", code, "counter", "map_counter")
+				if err != nil {
+					errs <- err
+					return
+				}
+				instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+				if err == nil {
+					err = instance.Execute(context.Background())
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				pool.Return(module)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, pool.IdleCount("hash_a"), 4)
+}
+
+// BenchmarkModulePool compares instantiating a module for each request with
+// checking it out of a pool.
+func BenchmarkModulePool(b *testing.B) {
+	code, err := wasmtime.Wat2Wasm(counterWAT)
+	require.NoError(b, err)
+	ctx := context.Background()
+	request := &pbsubstreams.Request{}
+
+	b.Run("new_module", func(b *testing.B) {
+		runtime := NewRuntime(nil)
+		for i := 0; i < b.N; i++ {
+			if _, err := runtime.NewModule(ctx, request, code, "counter", "map_counter"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		pool := NewModulePool(NewRuntime(nil), DefaultMaxIdleInstances)
+		for i := 0; i < b.N; i++ {
+			module, err := pool.Checkout(ctx, request, "hash_a", code, "counter", "map_counter")
+			if err != nil {
+				b.Fatal(err)
+			}
+			pool.Return(module)
+		}
+	})
+}
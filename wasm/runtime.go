@@ -2,8 +2,52 @@ package wasm
 
 import "fmt"
 
+// DefaultMaxLogByteCount is the size of the logs kept for each execution of
+// a module, unless configured otherwise.
+const DefaultMaxLogByteCount = 128 * 1024 // 128 KiB
+
+// MaxLogByteCountCeiling is the largest size of the logs kept for each
+// execution of a module, whatever the configuration.
+const MaxLogByteCountCeiling = 16 * 1024 * 1024 // 16 MiB
+
 type Runtime struct {
-	extensions map[string]map[string]WASMExtension
+	newEngine       func() engine
+	extensions      map[string]map[string]WASMExtension
+	maxLogByteCount uint64
+	storeReadCache  bool
+	perInputWrites  bool
+}
+
+type RuntimeOption func(r *Runtime)
+
+// WithMaxLogByteCount sets the size of the logs kept for each execution of a
+// module, further logs are dropped. It is capped to MaxLogByteCountCeiling,
+// requests can only lower it.
+func WithMaxLogByteCount(maxByteCount uint64) RuntimeOption {
+	return func(r *Runtime) {
+		if maxByteCount > MaxLogByteCountCeiling {
+			maxByteCount = MaxLogByteCountCeiling
+		}
+		r.maxLogByteCount = maxByteCount
+	}
+}
+
+// WithStoreReadCache remembers the values read from the input stores during
+// each execution of a module, so reading the same key again does not look it
+// up in the store another time. The value is still copied to the guest memory
+// on every read, since the guest owns it.
+func WithStoreReadCache() RuntimeOption {
+	return func(r *Runtime) {
+		r.storeReadCache = true
+	}
+}
+
+// WithPerInputWrites writes each input of a call to the guest memory on its
+// own, instead of writing them all at once in a single region.
+func WithPerInputWrites() RuntimeOption {
+	return func(r *Runtime) {
+		r.perInputWrites = true
+	}
 }
 
 func (r *Runtime) registerWASMExtension(namespace string, importName string, ext WASMExtension) {
@@ -29,8 +73,14 @@ func (r *Runtime) registerWASMExtension(namespace string, importName string, ext
 	r.extensions[namespace][importName] = ext
 }
 
-func NewRuntime(extensions []WASMExtensioner) *Runtime {
-	r := &Runtime{}
+func NewRuntime(extensions []WASMExtensioner, opts ...RuntimeOption) *Runtime {
+	r := &Runtime{
+		newEngine:       newWasmtimeEngine,
+		maxLogByteCount: DefaultMaxLogByteCount,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
 	for _, ext := range extensions {
 		for ns, exts := range ext.WASMExtensions() {
 			for name, ext := range exts {
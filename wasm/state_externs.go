@@ -5,258 +5,509 @@ import (
 	"math/big"
 
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/state"
 )
 
-func returnStateErrorString(cause string) {
-	returnErrorString("state", cause)
-}
+// maxDeletePrefixLength is the longest prefix accepted by `delete_prefix`.
+const maxDeletePrefixLength = 64 * 1024
+
 func returnStateError(cause error) {
 	returnError("state", cause)
 }
 
-func (m *Module) set(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_SET {
-		returnStateErrorString("invalid store operation: 'set' only valid for stores with updatePolicy == 'replace'")
+// checkOutputStore returns a trap when the store operation `call` is called by
+// a module without an output store: only store modules write to a store.
+func (m *Module) checkOutputStore(call string) error {
+	if store := m.currentInstance.outputStore; store != nil && store.UpdatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_UNSET {
+		return nil
+	}
+	return fmt.Errorf("module %q: invalid store operation: '%s' is only valid on the output store of a store module, configured stores: %s", m.name, call, m.currentInstance.describeStores())
+}
+
+// checkInputStore returns a trap when `storeIndex`, given by the guest to the
+// store operation `call`, is not the one of an input store in get mode. The
+// stores in deltas mode and the output store have no index.
+func (m *Module) checkInputStore(call string, storeIndex int32) error {
+	if storeIndex >= 0 && int(storeIndex) < len(m.currentInstance.inputStores) {
+		return nil
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadBytes(valPtr, valLength)
+	return fmt.Errorf("module %q: invalid store operation: '%s': invalid store index %d, configured stores: %s", m.name, call, storeIndex, m.currentInstance.describeStores())
+}
 
-	m.CurrentInstance.outputStore.SetBytes(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.set  %q", m.name, key))
+func (m *Module) set(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set"); trap != nil {
+		return trap
+	}
+	key, value, trap := m.readKeyValue("state.set", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
+
+	m.currentInstance.outputStore.SetBytes(uint64(ord), key, value)
+	m.currentInstance.traceHostCall("state.set", key, int(valLength))
+	return nil
 }
 
-func (m *Module) setIfNotExists(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_IF_NOT_EXISTS {
-		returnStateErrorString("invalid store operation: 'set_if_not_exists' only valid for stores with updatePolicy == 'ignore'")
+func (m *Module) setIfNotExists(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set_if_not_exists"); trap != nil {
+		return trap
+	}
+	key, value, trap := m.readKeyValue("state.set_if_not_exists", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadBytes(valPtr, valLength)
 
-	m.CurrentInstance.outputStore.SetBytesIfNotExists(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setIfNotExists  %q", m.name, key))
+	m.currentInstance.outputStore.SetBytesIfNotExists(uint64(ord), key, value)
+	m.currentInstance.traceHostCall("state.set_if_not_exists", key, int(valLength))
+	return nil
 }
 
-func (m *Module) append(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_APPEND {
-		returnStateErrorString("invalid store operation: 'append' only valid for stores with updatePolicy == 'append'")
+func (m *Module) append(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("append"); trap != nil {
+		return trap
 	}
 
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadBytes(valPtr, valLength)
+	key, value, trap := m.readKeyValue("state.append", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
 
-	m.CurrentInstance.outputStore.Append(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.append  %q", m.name, key))
+	m.currentInstance.outputStore.Append(uint64(ord), key, value)
+	m.currentInstance.traceHostCall("state.append", key, int(valLength))
+	return nil
 }
 
-func (m *Module) deletePrefix(ord int64, keyPtr, keyLength int32) {
-	prefix := m.Heap.ReadString(keyPtr, keyLength)
-	m.CurrentInstance.outputStore.DeletePrefix(uint64(ord), prefix)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.deletePrefix  %s ", m.name, prefix))
+// deletePrefix deletes the keys of the output store starting with the prefix
+// at `ord`, ordered with the other writes of the block. Invalid calls trap, so
+// they fail the execution deterministically.
+func (m *Module) deletePrefix(ord int64, keyPtr, keyLength int32) error {
+	if trap := m.checkOutputStore("delete_prefix"); trap != nil {
+		return trap
+	}
+	outputStore := m.currentInstance.outputStore
+	if ord < 0 || uint64(ord) < outputStore.LastOrdinal() {
+		return fmt.Errorf("module %q: invalid store operation: 'delete_prefix' at ordinal %d, lower than the ordinal %d of the previous write", m.name, ord, outputStore.LastOrdinal())
+	}
+	if uint32(keyLength) > maxDeletePrefixLength {
+		return fmt.Errorf("module %q: invalid store operation: 'delete_prefix' prefix of %d bytes exceeds the maximum of %d bytes", m.name, uint32(keyLength), maxDeletePrefixLength)
+	}
+	prefix, trap := m.readGuestString("state.delete_prefix", keyPtr, keyLength)
+	if trap != nil {
+		return trap
+	}
+
+	outputStore.DeletePrefix(uint64(ord), prefix)
+	m.currentInstance.traceHostCall("state.delete_prefix", prefix, traceNoValue)
+	return nil
 }
 
-func (m *Module) addBigInt(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD && m.CurrentInstance.valueType != "bigint" {
-		returnErrorString("state", "invalid store operation: 'add_bigint' only valid for stores with updatePolicy == 'add' and valueType == 'bigint'")
+func (m *Module) addBigInt(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("add_bigint"); trap != nil {
+		return trap
+	}
+	key, valueBytes, trap := m.readKeyValue("state.add_bigint", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadString(valPtr, valLength)
+	value := string(valueBytes)
 
 	toAdd, _ := new(big.Int).SetString(value, 10)
-	m.CurrentInstance.outputStore.SumBigInt(uint64(ord), key, toAdd)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.addBigInt  %q", m.name, key))
-
-	return
+	m.currentInstance.outputStore.SumBigInt(uint64(ord), key, toAdd)
+	m.currentInstance.traceHostCall("state.add_bigint", key, int(valLength))
+	return nil
 }
 
-func (m *Module) addBigFloat(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD && m.CurrentInstance.valueType != "bigfloat" {
-		returnErrorString("state", "invalid store operation: 'add_bigfloat' only valid for stores with updatePolicy == 'add' and valueType == 'bigfloat'")
+func (m *Module) addBigFloat(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("add_bigfloat"); trap != nil {
+		return trap
 	}
 
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadString(valPtr, valLength)
+	key, valueBytes, trap := m.readKeyValue("state.add_bigfloat", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
+	value := string(valueBytes)
 
 	toAdd, _, err := big.ParseFloat(value, 10, 100, big.ToNearestEven) // corresponds to SumBigFloat's read of the kv value
 	if err != nil {
 		returnStateError(fmt.Errorf("parsing bigfloat: %w", err))
 	}
 
-	m.CurrentInstance.outputStore.SumBigFloat(uint64(ord), key, toAdd)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.addBigFloat  %q", m.name, key))
+	m.currentInstance.outputStore.SumBigFloat(uint64(ord), key, toAdd)
+	m.currentInstance.traceHostCall("state.add_bigfloat", key, int(valLength))
+	return nil
 }
 
-func (m *Module) addInt64(ord int64, keyPtr, keyLength int32, value int64) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD && m.CurrentInstance.valueType != "int64" {
-		returnStateErrorString("invalid store operation: 'add_int64' only valid for stores with updatePolicy == 'add' and valueType == 'int64'")
+func (m *Module) addInt64(ord int64, keyPtr, keyLength int32, value int64) error {
+	if trap := m.checkOutputStore("add_int64"); trap != nil {
+		return trap
+	}
+	key, trap := m.readGuestString("state.add_int64", keyPtr, keyLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
-
-	m.CurrentInstance.outputStore.SumInt64(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.addInt64  %q", m.name, key))
 
+	m.currentInstance.outputStore.SumInt64(uint64(ord), key, value)
+	m.currentInstance.traceHostCall("state.add_int64", key, 8)
+	return nil
 }
 
-func (m *Module) addFloat64(ord int64, keyPtr, keyLength int32, value float64) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD && m.CurrentInstance.valueType != "float64" {
-		returnStateErrorString("invalid store operation: 'add_float64' only valid for stores with updatePolicy == 'add' and valueType == 'float64'")
+func (m *Module) addFloat64(ord int64, keyPtr, keyLength int32, value float64) error {
+	if trap := m.checkOutputStore("add_float64"); trap != nil {
+		return trap
+	}
+	key, trap := m.readGuestString("state.add_float64", keyPtr, keyLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
 
-	m.CurrentInstance.outputStore.SumFloat64(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.addFloat64 %q", m.name, key))
+	if err := m.currentInstance.outputStore.SumFloat64(uint64(ord), key, value); err != nil {
+		return fmt.Errorf("module %q: invalid store operation: 'add_float64': %s", m.name, err)
+	}
+	m.currentInstance.traceHostCall("state.add_float64", key, 8)
+	return nil
 }
 
-func (m *Module) setMinInt64(ord int64, keyPtr, keyLength int32, value int64) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN && m.CurrentInstance.valueType != "int64" {
-		returnStateErrorString("invalid store operation: 'set_min_int64' only valid for stores with updatePolicy == 'min' and valueType == 'int64'")
+func (m *Module) setMinInt64(ord int64, keyPtr, keyLength int32, value int64) error {
+	if trap := m.checkOutputStore("set_min_int64"); trap != nil {
+		return trap
+	}
+	key, trap := m.readGuestString("state.set_min_int64", keyPtr, keyLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
 
-	m.CurrentInstance.outputStore.SetMinInt64(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMinInt64 %q", m.name, key))
+	m.currentInstance.outputStore.SetMinInt64(uint64(ord), key, value)
+	m.currentInstance.traceHostCall("state.set_min_int64", key, 8)
+	return nil
 }
 
-func (m *Module) setMinBigint(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN && m.CurrentInstance.valueType != "bigfloat" {
-		returnStateErrorString("invalid store operation: 'set_min_bigint' only valid for stores with updatePolicy == 'min' and valueType == 'bigint'")
+func (m *Module) setMinBigint(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set_min_bigint"); trap != nil {
+		return trap
 	}
 
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadString(valPtr, valLength)
+	key, valueBytes, trap := m.readKeyValue("state.set_min_bigint", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
+	value := string(valueBytes)
 
 	toSet, _ := new(big.Int).SetString(value, 10)
-	m.CurrentInstance.outputStore.SetMinBigInt(uint64(ord), key, toSet)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMinBigint %q", m.name, key))
+	m.currentInstance.outputStore.SetMinBigInt(uint64(ord), key, toSet)
+	m.currentInstance.traceHostCall("state.set_min_bigint", key, int(valLength))
+	return nil
 }
 
-func (m *Module) setMinfloat64(ord int64, keyPtr, keyLength int32, value float64) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN && m.CurrentInstance.valueType != "float" {
-		returnStateErrorString("invalid store operation: 'set_min_float' only valid for stores with updatePolicy == 'min' and valueType == 'float'")
+func (m *Module) setMinfloat64(ord int64, keyPtr, keyLength int32, value float64) error {
+	if trap := m.checkOutputStore("set_min_float64"); trap != nil {
+		return trap
+	}
+	key, trap := m.readGuestString("state.set_min_float64", keyPtr, keyLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
 
-	m.CurrentInstance.outputStore.SetMinFloat64(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMinfloat64 %q", m.name, key))
+	if err := m.currentInstance.outputStore.SetMinFloat64(uint64(ord), key, value); err != nil {
+		return fmt.Errorf("module %q: invalid store operation: 'set_min_float64': %s", m.name, err)
+	}
+	m.currentInstance.traceHostCall("state.set_min_float64", key, 8)
+	return nil
 }
 
-func (m *Module) setMinBigfloat(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN && m.CurrentInstance.valueType != "bigint" {
-		returnStateErrorString("invalid store operation: 'set_min_bigfloat' only valid for stores with updatePolicy == 'min' and valueType == 'bigfloat'")
+func (m *Module) setMinBigfloat(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set_min_bigfloat"); trap != nil {
+		return trap
 	}
 
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadString(valPtr, valLength)
+	key, valueBytes, trap := m.readKeyValue("state.set_min_bigfloat", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
+	value := string(valueBytes)
 
 	toSet, _, err := big.ParseFloat(value, 10, 100, big.ToNearestEven)
 	if err != nil {
 		returnStateError(fmt.Errorf("parsing bigfloat: %w", err))
 	}
-	m.CurrentInstance.outputStore.SetMinBigFloat(uint64(ord), key, toSet)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMinBigfloat %q", m.name, key))
+	m.currentInstance.outputStore.SetMinBigFloat(uint64(ord), key, toSet)
+	m.currentInstance.traceHostCall("state.set_min_bigfloat", key, int(valLength))
+	return nil
 }
 
-func (m *Module) setMaxInt64(ord int64, keyPtr, keyLength int32, value int64) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX && m.CurrentInstance.valueType != "int64" {
-		returnStateErrorString("invalid store operation: 'set_max_int64' only valid for stores with updatePolicy == 'max' and valueType == 'int64'")
+func (m *Module) setMaxInt64(ord int64, keyPtr, keyLength int32, value int64) error {
+	if trap := m.checkOutputStore("set_max_int64"); trap != nil {
+		return trap
+	}
+	key, trap := m.readGuestString("state.set_max_int64", keyPtr, keyLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
 
-	m.CurrentInstance.outputStore.SetMaxInt64(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMaxInt64 %q", m.name, key))
+	m.currentInstance.outputStore.SetMaxInt64(uint64(ord), key, value)
+	m.currentInstance.traceHostCall("state.set_max_int64", key, 8)
+	return nil
 }
 
-func (m *Module) setMaxBigint(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX && m.CurrentInstance.valueType != "bigint" {
-		returnStateErrorString("invalid store operation: 'set_max_bigint' only valid for stores with updatePolicy == 'max' and valueType == 'bigint'")
+func (m *Module) setMaxBigint(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set_max_bigint"); trap != nil {
+		return trap
+	}
+	key, valueBytes, trap := m.readKeyValue("state.set_max_bigint", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadString(valPtr, valLength)
+	value := string(valueBytes)
 
 	toSet, _ := new(big.Int).SetString(value, 10)
-	m.CurrentInstance.outputStore.SetMaxBigInt(uint64(ord), key, toSet)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMaxBigInt %q", m.name, key))
+	m.currentInstance.outputStore.SetMaxBigInt(uint64(ord), key, toSet)
+	m.currentInstance.traceHostCall("state.set_max_bigint", key, int(valLength))
+	return nil
 }
 
-func (m *Module) setMaxFloat64(ord int64, keyPtr, keyLength int32, value float64) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX && m.CurrentInstance.valueType != "float" {
-		returnStateErrorString("invalid store operation: 'set_max_float' only valid for stores with updatePolicy == 'max' and valueType == 'float'")
+func (m *Module) setMaxFloat64(ord int64, keyPtr, keyLength int32, value float64) error {
+	if trap := m.checkOutputStore("set_max_float64"); trap != nil {
+		return trap
+	}
+	key, trap := m.readGuestString("state.set_max_float64", keyPtr, keyLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
 
-	m.CurrentInstance.outputStore.SetMaxFloat64(uint64(ord), key, value)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMaxFloat64 %q", m.name, key))
+	if err := m.currentInstance.outputStore.SetMaxFloat64(uint64(ord), key, value); err != nil {
+		return fmt.Errorf("module %q: invalid store operation: 'set_max_float64': %s", m.name, err)
+	}
+	m.currentInstance.traceHostCall("state.set_max_float64", key, 8)
+	return nil
 }
 
-func (m *Module) setMaxBigfloat(ord int64, keyPtr, keyLength, valPtr, valLength int32) {
-	if m.CurrentInstance.outputStore == nil && m.CurrentInstance.updatePolicy != pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX && m.CurrentInstance.valueType != "bigint" {
-		returnStateErrorString("invalid store operation: 'set_max_bigfloat' only valid for stores with updatePolicy == 'max' and valueType == 'bigfloat'")
+func (m *Module) setMaxBigfloat(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set_max_bigfloat"); trap != nil {
+		return trap
+	}
+	key, valueBytes, trap := m.readKeyValue("state.set_max_bigfloat", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
 	}
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value := m.Heap.ReadString(valPtr, valLength)
+	value := string(valueBytes)
 
 	toSet, _, err := big.ParseFloat(value, 10, 100, big.ToNearestEven)
 	if err != nil {
 		returnStateError(fmt.Errorf("parsing bigfloat: %w", err))
 	}
-	m.CurrentInstance.outputStore.SetMaxBigFloat(uint64(ord), key, toSet)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.setMaxBigfloat %q", m.name, key))
+	m.currentInstance.outputStore.SetMaxBigFloat(uint64(ord), key, toSet)
+	m.currentInstance.traceHostCall("state.set_max_bigfloat", key, int(valLength))
+	return nil
+}
+
+// addBigIntLE is add_bigint taking the value in its two's complement little
+// endian form, sparing the guest and the host the decimal formatting and
+// parsing. The value is still stored in its decimal form.
+func (m *Module) addBigIntLE(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("add_bigint_le"); trap != nil {
+		return trap
+	}
+	key, value, trap := m.readKeyValue("state.add_bigint_le", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
+
+	m.currentInstance.outputStore.SumBigInt(uint64(ord), key, state.BigIntFromSignedBytesLE(value))
+	m.currentInstance.traceHostCall("state.add_bigint_le", key, int(valLength))
+	return nil
 }
 
-func (m *Module) getAt(storeIndex int32, ord int64, keyPtr, keyLength, outputPtr int32) int32 {
-	if int(storeIndex+1) > len(m.CurrentInstance.inputStores) {
-		returnStateError(fmt.Errorf("'get_at' failed: invalid store index %d, %d stores declared", storeIndex, len(m.CurrentInstance.inputStores)))
+// setMinBigIntLE is set_min_bigint taking the value in its two's complement
+// little endian form, see addBigIntLE.
+func (m *Module) setMinBigIntLE(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set_min_bigint_le"); trap != nil {
+		return trap
 	}
-	readStore := m.CurrentInstance.inputStores[storeIndex]
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value, found := readStore.GetAt(uint64(ord), key)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.getAt %q: found:%t", m.name, key, found))
+	key, value, trap := m.readKeyValue("state.set_min_bigint_le", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
+
+	m.currentInstance.outputStore.SetMinBigInt(uint64(ord), key, state.BigIntFromSignedBytesLE(value))
+	m.currentInstance.traceHostCall("state.set_min_bigint_le", key, int(valLength))
+	return nil
+}
+
+// setMaxBigIntLE is set_max_bigint taking the value in its two's complement
+// little endian form, see addBigIntLE.
+func (m *Module) setMaxBigIntLE(ord int64, keyPtr, keyLength, valPtr, valLength int32) error {
+	if trap := m.checkOutputStore("set_max_bigint_le"); trap != nil {
+		return trap
+	}
+	key, value, trap := m.readKeyValue("state.set_max_bigint_le", keyPtr, keyLength, valPtr, valLength)
+	if trap != nil {
+		return trap
+	}
+
+	m.currentInstance.outputStore.SetMaxBigInt(uint64(ord), key, state.BigIntFromSignedBytesLE(value))
+	m.currentInstance.traceHostCall("state.set_max_bigint_le", key, int(valLength))
+	return nil
+}
+
+func (m *Module) getAt(storeIndex int32, ord int64, keyPtr, keyLength, outputPtr int32) (int32, error) {
+	if trap := m.checkInputStore("get_at", storeIndex); trap != nil {
+		return 0, trap
+	}
+	key, trap := m.readGuestString("state.get_at", keyPtr, keyLength)
+	if trap != nil {
+		return 0, trap
+	}
+	value, found := m.currentInstance.readStore(storeRead{storeIndex: storeIndex, kind: readAt, ord: ord, key: key}, func(store state.Reader) ([]byte, bool) {
+		return store.GetAt(uint64(ord), key)
+	})
 	if !found {
-		return 0
+		m.currentInstance.traceHostCall("state.get_at", key, traceNotFound)
+		return 0, nil
 	}
+	m.currentInstance.traceHostCall("state.get_at", key, len(value))
 
-	err := m.CurrentInstance.WriteOutputToHeap(outputPtr, value, key)
+	err := m.currentInstance.WriteOutputToHeap(outputPtr, value, "state.get_at")
 	if err != nil {
-		returnStateError(fmt.Errorf("writing value to output ptr %d: %w", outputPtr, err))
+		return 0, err
 	}
-	return 1
+	return 1, nil
 }
 
-func (m *Module) getFirst(storeIndex int32, keyPtr, keyLength, outputPtr int32) int32 {
-	if int(storeIndex)+1 > len(m.CurrentInstance.inputStores) {
-		returnStateError(fmt.Errorf("'get_first' failed: invalid store index %d, %d stores declared", storeIndex, len(m.CurrentInstance.inputStores)))
+func (m *Module) getFirst(storeIndex int32, keyPtr, keyLength, outputPtr int32) (int32, error) {
+	if trap := m.checkInputStore("get_first", storeIndex); trap != nil {
+		return 0, trap
+	}
+	key, trap := m.readGuestString("state.get_first", keyPtr, keyLength)
+	if trap != nil {
+		return 0, trap
 	}
-	readStore := m.CurrentInstance.inputStores[storeIndex]
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value, found := readStore.GetFirst(key)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.getFirst %q: found:%t", m.name, key, found))
+	value, found := m.currentInstance.readStore(storeRead{storeIndex: storeIndex, kind: readFirst, key: key}, func(store state.Reader) ([]byte, bool) {
+		return store.GetFirst(key)
+	})
 	if !found {
-		return 0
+		m.currentInstance.traceHostCall("state.get_first", key, traceNotFound)
+		return 0, nil
 	}
-	err := m.CurrentInstance.WriteOutputToHeap(outputPtr, value, key)
+	m.currentInstance.traceHostCall("state.get_first", key, len(value))
+	err := m.currentInstance.WriteOutputToHeap(outputPtr, value, "state.get_first")
 	if err != nil {
-		returnStateError(fmt.Errorf("writing value to output ptr %d: %w", outputPtr, err))
+		return 0, err
 	}
-	return 1
+	return 1, nil
 }
 
-func (m *Module) getLast(storeIndex int32, keyPtr, keyLength, outputPtr int32) int32 {
-	if int(storeIndex)+1 > len(m.CurrentInstance.inputStores) {
-		returnStateError(fmt.Errorf("'get_last' failed: invalid store index %d, %d stores declared", storeIndex, len(m.CurrentInstance.inputStores)))
+func (m *Module) getLast(storeIndex int32, keyPtr, keyLength, outputPtr int32) (int32, error) {
+	if trap := m.checkInputStore("get_last", storeIndex); trap != nil {
+		return 0, trap
 	}
 
-	readStore := m.CurrentInstance.inputStores[storeIndex]
-
-	key := m.Heap.ReadString(keyPtr, keyLength)
-	value, found := readStore.GetLast(key)
-	m.CurrentInstance.PushExecutionStack(fmt.Sprintf("%s.getLast %q: found:%t", m.name, key, found))
+	key, trap := m.readGuestString("state.get_last", keyPtr, keyLength)
+	if trap != nil {
+		return 0, trap
+	}
+	value, found := m.currentInstance.readStore(storeRead{storeIndex: storeIndex, kind: readLast, key: key}, func(store state.Reader) ([]byte, bool) {
+		return store.GetLast(key)
+	})
 	if !found {
-		return 0
+		m.currentInstance.traceHostCall("state.get_last", key, traceNotFound)
+		return 0, nil
 	}
+	m.currentInstance.traceHostCall("state.get_last", key, len(value))
 
-	err := m.CurrentInstance.WriteOutputToHeap(outputPtr, value, key)
+	err := m.currentInstance.WriteOutputToHeap(outputPtr, value, "state.get_last")
 	if err != nil {
-		returnStateError(fmt.Errorf("writing value to output ptr %d: %w", outputPtr, err))
+		return 0, err
+	}
+	return 1, nil
+}
+
+// hasAt tells the guest if `key` is in the input store `storeIndex` at `ord`,
+// without writing its value to the guest memory.
+func (m *Module) hasAt(storeIndex int32, ord int64, keyPtr, keyLength int32) (int32, error) {
+	store, key, trap := m.hasArgs("has_at", storeIndex, keyPtr, keyLength)
+	if trap != nil {
+		return 0, trap
+	}
+	found := store.HasAt(uint64(ord), key)
+	m.currentInstance.traceHostCall("state.has_at", key, foundSize(found))
+	return boolToI32(found), nil
+}
+
+// hasLast tells the guest if `key` is in the input store `storeIndex`, without
+// writing its value to the guest memory.
+func (m *Module) hasLast(storeIndex int32, keyPtr, keyLength int32) (int32, error) {
+	store, key, trap := m.hasArgs("has_last", storeIndex, keyPtr, keyLength)
+	if trap != nil {
+		return 0, trap
+	}
+	found := store.HasLast(key)
+	m.currentInstance.traceHostCall("state.has_last", key, foundSize(found))
+	return boolToI32(found), nil
+}
+
+func (m *Module) hasArgs(call string, storeIndex int32, keyPtr, keyLength int32) (state.Reader, string, error) {
+	if trap := m.checkInputStore(call, storeIndex); trap != nil {
+		return nil, "", trap
+	}
+	key, trap := m.readGuestString("state."+call, keyPtr, keyLength)
+	if trap != nil {
+		return nil, "", trap
+	}
+	return m.currentInstance.inputStores[storeIndex], key, nil
+}
+
+// foundSize is the value size traced for the calls which only tell if a key
+// was found.
+func foundSize(found bool) int {
+	if found {
+		return traceNoValue
+	}
+	return traceNotFound
+}
+
+func boolToI32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type storeReadKind int
+
+const (
+	readAt storeReadKind = iota
+	readFirst
+	readLast
+)
+
+// storeRead identifies a read of an input store, `ord` only matters to
+// `readAt`.
+type storeRead struct {
+	storeIndex int32
+	kind       storeReadKind
+	ord        int64
+	key        string
+}
+
+type storeReadResult struct {
+	value []byte
+	found bool
+}
+
+// readStore reads the input store of `r` with `read`, or returns the result of
+// the same read made earlier during the execution when the reads are cached.
+// An input store which is also the output store of the module is never cached,
+// the module could write to it between two reads.
+func (i *Instance) readStore(r storeRead, read func(store state.Reader) ([]byte, bool)) ([]byte, bool) {
+	store := i.inputStores[r.storeIndex]
+	if i.storeReads == nil || (i.outputStore != nil && store == state.Reader(i.outputStore)) {
+		return read(store)
+	}
 
+	if result, found := i.storeReads[r]; found {
+		return result.value, result.found
 	}
-	return 1
+	value, found := read(store)
+	i.storeReads[r] = storeReadResult{value: value, found: found}
+	return value, found
 }
@@ -0,0 +1,498 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	"github.com/streamingfast/dstore"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// readTwiceWAT reads the key "k" twice from the input store 0, then outputs
+// the two ptr/len pairs it got.
+const readTwiceWAT = `
+(module
+  (import "state" "get_at" (func $get_at (param i32 i64 i32 i32 i32) (result i32)))
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "k")
+  (global $top (mut i32) (i32.const 1024))
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $top))
+    (global.set $top (i32.add (global.get $top) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_read_twice") (param $store i32)
+    (drop (call $get_at (local.get $store) (i64.const 10) (i32.const 0) (i32.const 1) (i32.const 16)))
+    (drop (call $get_at (local.get $store) (i64.const 10) (i32.const 0) (i32.const 1) (i32.const 24)))
+    (call $output (i32.const 16) (i32.const 16)))
+)
+`
+
+func newTestStore(t *testing.T) *state.Store {
+	store, err := state.NewStore("store", 10, 0, "hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, state.OutputValueTypeString, dstore.NewMockStore(nil), zap.NewNop())
+	require.NoError(t, err)
+	store.SetBytes(1, "k", []byte("value"))
+	return store
+}
+
+type countingReader struct {
+	state.Reader
+	reads int
+}
+
+func (r *countingReader) GetAt(ord uint64, key string) ([]byte, bool) {
+	r.reads++
+	return r.Reader.GetAt(ord, key)
+}
+
+func TestModule_StoreReadCache(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(readTwiceWAT)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		opts          []RuntimeOption
+		expectedReads int
+	}{
+		{"not cached", nil, 2},
+		{"cached", []RuntimeOption{WithStoreReadCache()}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil, test.opts...).NewModule(context.Background(), &pbsubstreams.Request{}, code, "reader", "map_read_twice")
+			require.NoError(t, err)
+
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputStore, Name: "store", Store: newTestStore(t)}})
+			require.NoError(t, err)
+			reader := &countingReader{Reader: instance.inputStores[0]}
+			instance.inputStores[0] = reader
+
+			require.NoError(t, instance.Execute(context.Background()))
+			assert.Equal(t, test.expectedReads, reader.reads)
+
+			// Each read gets its own copy of the value, owned by the guest
+			output := instance.Output()
+			require.Len(t, output, 16)
+			first, second := binary.LittleEndian.Uint32(output[0:4]), binary.LittleEndian.Uint32(output[8:12])
+			assert.NotEqual(t, first, second)
+			assert.Equal(t, "value", string(module.Heap.ReadBytes(int32(first), 5)))
+			assert.Equal(t, "value", string(module.Heap.ReadBytes(int32(second), 5)))
+		})
+	}
+}
+
+func TestInstance_StoreReadCacheSkipsOutputStore(t *testing.T) {
+	store := newTestStore(t)
+	instance := &Instance{
+		inputStores: []state.Reader{store},
+		outputStore: store,
+		storeReads:  map[storeRead]storeReadResult{},
+	}
+	read := storeRead{storeIndex: 0, kind: readLast, key: "k"}
+	getLast := func(store state.Reader) ([]byte, bool) { return store.GetLast("k") }
+
+	value, found := instance.readStore(read, getLast)
+	require.True(t, found)
+	assert.Equal(t, "value", string(value))
+
+	// Written by the module between the two reads
+	store.SetBytes(2, "k", []byte("updated"))
+	value, found = instance.readStore(read, getLast)
+	require.True(t, found)
+	assert.Equal(t, "updated", string(value))
+	assert.Empty(t, instance.storeReads)
+}
+
+// deletePrefixWAT writes "a:1", deletes the prefix "a:" and writes "a:2", in
+// this order of ordinals, with `store_write`. `delete_at` deletes the prefix
+// of `len` bytes at `ptr` at ordinal `ord`, after writing at ordinal 5 with
+// `write_then_delete_at`.
+const deletePrefixWAT = `
+(module
+  (import "state" "set" (func $set (param i64 i32 i32 i32 i32)))
+  (import "state" "delete_prefix" (func $delete_prefix (param i64 i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "a:1")
+  (data (i32.const 8) "a:2")
+  (data (i32.const 16) "v")
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "store_write")
+    (call $set (i64.const 1) (i32.const 0) (i32.const 3) (i32.const 16) (i32.const 1))
+    (call $delete_prefix (i64.const 2) (i32.const 0) (i32.const 2))
+    (call $set (i64.const 3) (i32.const 8) (i32.const 3) (i32.const 16) (i32.const 1)))
+  (func $delete_at (export "delete_at") (param $ord i64) (param $ptr i32) (param $len i32)
+    (call $delete_prefix (local.get $ord) (local.get $ptr) (local.get $len)))
+  (func (export "write_then_delete_at") (param $ord i64) (param $ptr i32) (param $len i32)
+    (call $set (i64.const 5) (i32.const 0) (i32.const 3) (i32.const 16) (i32.const 1))
+    (call $delete_at (local.get $ord) (local.get $ptr) (local.get $len)))
+)
+`
+
+func TestModule_DeletePrefix(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(deletePrefixWAT)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	newInstance := func(t *testing.T, entrypoint string, store *state.Store) *Instance {
+		module, err := NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "store_a", entrypoint)
+		require.NoError(t, err)
+		var inputs []*Input
+		if store != nil {
+			inputs = append(inputs, &Input{Type: OutputStore, Name: "store_a", Store: store, UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET})
+		}
+		instance, err := module.NewInstance(&pbsubstreams.Clock{}, inputs)
+		require.NoError(t, err)
+		return instance
+	}
+	newStore := func(t *testing.T) *state.Store {
+		store, err := state.NewStore("store_a", 10, 0, "hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, state.OutputValueTypeString, dstore.NewMockStore(nil), zap.NewNop())
+		require.NoError(t, err)
+		return store
+	}
+
+	t.Run("ordered with the other writes", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, newInstance(t, "store_write", store).Execute(ctx))
+
+		_, found := store.GetLast("a:1")
+		assert.False(t, found)
+		_, found = store.GetLast("a:2")
+		assert.True(t, found)
+		require.Len(t, store.Deltas, 3)
+		assert.Equal(t, pbsubstreams.StoreDelta_DELETE, store.Deltas[1].Operation)
+		assert.Equal(t, uint64(2), store.Deltas[1].Ordinal)
+	})
+
+	tests := []struct {
+		name          string
+		entrypoint    string
+		noStore       bool
+		ord           int64
+		ptr           int32
+		length        int32
+		expectedError string
+	}{
+		{"not a store module", "delete_at", true, 6, 0, 2, `module "store_a": invalid store operation: 'delete_prefix' is only valid on the output store of a store module`},
+		{"lower ordinal", "write_then_delete_at", false, 4, 0, 2, `module "store_a": invalid store operation: 'delete_prefix' at ordinal 4, lower than the ordinal 5 of the previous write`},
+		{"prefix too long", "write_then_delete_at", false, 6, 0, maxDeletePrefixLength + 1, `prefix of 65537 bytes exceeds the maximum of 65536 bytes`},
+		{"prefix out of memory", "write_then_delete_at", false, 6, -8, 16, `module "store_a" passed out-of-bounds pointer to state.delete_prefix: 16 bytes at 4294967288, memory is`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var store *state.Store
+			if !test.noStore {
+				store = newStore(t)
+			}
+			instance := newInstance(t, test.entrypoint, store)
+			err := instance.ExecuteWithArgs(ctx, test.ord, test.ptr, test.length)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestModule_TraceHostCalls(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(deletePrefixWAT)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		request       *pbsubstreams.Request
+		expectedStack []string
+	}{
+		{"production mode", &pbsubstreams.Request{}, nil},
+		{"development mode", &pbsubstreams.Request{DevelopmentMode: true}, []string{
+			`store_a: state.set "a:1" (1 bytes)`,
+			`store_a: state.delete_prefix "a:"`,
+			`store_a: state.set "a:2" (1 bytes)`,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(ctx, test.request, code, "store_a", "store_write")
+			require.NoError(t, err)
+			store, err := state.NewStore("store_a", 10, 0, "hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, state.OutputValueTypeString, dstore.NewMockStore(nil), zap.NewNop())
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: OutputStore, Name: "store_a", Store: store, UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET}})
+			require.NoError(t, err)
+
+			require.NoError(t, instance.Execute(ctx))
+			assert.Equal(t, test.expectedStack, instance.ExecutionStack)
+		})
+	}
+}
+
+func TestInstance_TraceHostCallsCap(t *testing.T) {
+	instance := &Instance{Module: &Module{name: "map_a"}, traceHostCalls: true}
+	for i := 0; i < maxHostCallTraces+10; i++ {
+		instance.traceHostCall("state.get_last", "k", traceNotFound)
+	}
+
+	require.Len(t, instance.ExecutionStack, maxHostCallTraces+1)
+	assert.Equal(t, `map_a: state.get_last "k" not found`, instance.ExecutionStack[0])
+	assert.Equal(t, "map_a: host call traces capped at 1000, the next calls are not recorded", instance.ExecutionStack[maxHostCallTraces])
+}
+
+// addFloat64WAT adds its parameter to the key "k" with `add_float64`.
+const addFloat64WAT = `
+(module
+  (import "state" "add_float64" (func $add_float64 (param i64 i32 i32 f64)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "k")
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "store_add") (param $value f64)
+    (call $add_float64 (i64.const 1) (i32.const 0) (i32.const 1) (local.get $value)))
+)
+`
+
+func TestModule_AddFloat64(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(addFloat64WAT)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		value         float64
+		expectedValue string
+		expectedError string
+	}{
+		{"finite", 1.5, "1.5", ""},
+		{"infinite", math.Inf(1), "+Inf", ""},
+		{"NaN", math.NaN(), "", `module "store_a": invalid store operation: 'add_float64': float64 value of key "k" is NaN`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "store_a", "store_add")
+			require.NoError(t, err)
+			store, err := state.NewStore("store_a", 10, 0, "hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, state.OutputValueTypeFloat64, dstore.NewMockStore(nil), zap.NewNop())
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: OutputStore, Name: "store_a", Store: store, UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, ValueType: state.OutputValueTypeFloat64}})
+			require.NoError(t, err)
+
+			err = instance.ExecuteWithArgs(ctx, test.value)
+			if test.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				assert.Empty(t, store.Deltas)
+				return
+			}
+			require.NoError(t, err)
+			value, found := store.GetLast("k")
+			require.True(t, found)
+			assert.Equal(t, test.expectedValue, string(value))
+		})
+	}
+}
+
+// bigIntWAT exports a function per bigint store operation, each writing the
+// value of `len` bytes at `ptr` to the key "k".
+const bigIntWAT = `
+(module
+  (import "state" "add_bigint" (func $add_bigint (param i64 i32 i32 i32 i32)))
+  (import "state" "add_bigint_le" (func $add_bigint_le (param i64 i32 i32 i32 i32)))
+  (import "state" "set_min_bigint" (func $set_min_bigint (param i64 i32 i32 i32 i32)))
+  (import "state" "set_min_bigint_le" (func $set_min_bigint_le (param i64 i32 i32 i32 i32)))
+  (import "state" "set_max_bigint" (func $set_max_bigint (param i64 i32 i32 i32 i32)))
+  (import "state" "set_max_bigint_le" (func $set_max_bigint_le (param i64 i32 i32 i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "k")
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "add") (param $ptr i32) (param $len i32)
+    (call $add_bigint (i64.const 1) (i32.const 0) (i32.const 1) (local.get $ptr) (local.get $len)))
+  (func (export "add_le") (param $ptr i32) (param $len i32)
+    (call $add_bigint_le (i64.const 1) (i32.const 0) (i32.const 1) (local.get $ptr) (local.get $len)))
+  (func (export "min") (param $ptr i32) (param $len i32)
+    (call $set_min_bigint (i64.const 1) (i32.const 0) (i32.const 1) (local.get $ptr) (local.get $len)))
+  (func (export "min_le") (param $ptr i32) (param $len i32)
+    (call $set_min_bigint_le (i64.const 1) (i32.const 0) (i32.const 1) (local.get $ptr) (local.get $len)))
+  (func (export "max") (param $ptr i32) (param $len i32)
+    (call $set_max_bigint (i64.const 1) (i32.const 0) (i32.const 1) (local.get $ptr) (local.get $len)))
+  (func (export "max_le") (param $ptr i32) (param $len i32)
+    (call $set_max_bigint_le (i64.const 1) (i32.const 0) (i32.const 1) (local.get $ptr) (local.get $len)))
+)
+`
+
+func TestModule_BigIntLE(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(bigIntWAT)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	huge, _ := new(big.Int).SetString("1267650600228229401496703205376", 10)
+	hugeNegative, _ := new(big.Int).SetString("-1180591620717411303424", 10)
+	values := []*big.Int{big.NewInt(5), big.NewInt(-3), huge, hugeNegative, big.NewInt(0), big.NewInt(128)}
+
+	// run writes `values` with the store operation `entrypoint`, encoded by
+	// `encode`, and returns the final value
+	run := func(t *testing.T, entrypoint string, policy pbsubstreams.Module_KindStore_UpdatePolicy, encode func(*big.Int) []byte) string {
+		module, err := NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "store_a", entrypoint)
+		require.NoError(t, err)
+		store, err := state.NewStore("store_a", 10, 0, "hash", policy, state.OutputValueTypeBigInt, dstore.NewMockStore(nil), zap.NewNop())
+		require.NoError(t, err)
+
+		for _, value := range values {
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: OutputStore, Name: "store_a", Store: store, UpdatePolicy: policy, ValueType: state.OutputValueTypeBigInt}})
+			require.NoError(t, err)
+			encoded := encode(value)
+			_, err = module.Heap.WriteAtPtr(encoded, 64, "test")
+			require.NoError(t, err)
+			require.NoError(t, instance.ExecuteWithArgs(ctx, int32(64), int32(len(encoded))))
+		}
+		value, found := store.GetLast("k")
+		require.True(t, found)
+		return string(value)
+	}
+
+	decimal := func(value *big.Int) []byte { return []byte(value.String()) }
+	tests := []struct {
+		name     string
+		policy   pbsubstreams.Module_KindStore_UpdatePolicy
+		expected string
+	}{
+		{"add", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, "1267650599047637780779291902082"},
+		{"min", pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN, "-1180591620717411303424"},
+		{"max", pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX, "1267650600228229401496703205376"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, run(t, test.name, test.policy, decimal))
+			assert.Equal(t, test.expected, run(t, test.name+"_le", test.policy, state.BigIntToSignedBytesLE))
+		})
+	}
+}
+
+// hasWAT outputs the results of `has_at`, `has_last` and `get_at` for the key
+// of `len` bytes at `ptr`, as three little endian i32.
+const hasWAT = `
+(module
+  (import "state" "has_at" (func $has_at (param i32 i64 i32 i32) (result i32)))
+  (import "state" "has_last" (func $has_last (param i32 i32 i32) (result i32)))
+  (import "state" "get_at" (func $get_at (param i32 i64 i32 i32 i32) (result i32)))
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "kept")
+  (data (i32.const 8) "deleted")
+  (data (i32.const 16) "absent")
+  (global $top (mut i32) (i32.const 1024))
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $top))
+    (global.set $top (i32.add (global.get $top) (local.get $size)))
+    (local.get $ptr))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_has") (param $store i32) (param $ord i64) (param $ptr i32) (param $len i32)
+    (i32.store (i32.const 64) (call $has_at (local.get $store) (local.get $ord) (local.get $ptr) (local.get $len)))
+    (i32.store (i32.const 68) (call $has_last (local.get $store) (local.get $ptr) (local.get $len)))
+    (i32.store (i32.const 72) (call $get_at (local.get $store) (local.get $ord) (local.get $ptr) (local.get $len) (i32.const 80)))
+    (call $output (i32.const 64) (i32.const 12)))
+)
+`
+
+func TestModule_Has(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(hasWAT)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	store := newTestStore(t)
+	store.SetBytes(1, "kept", []byte("value"))
+	store.SetBytes(1, "deleted", []byte("value"))
+	store.Flush()
+	store.Del(5, "deleted")
+
+	tests := []struct {
+		name          string
+		storeIndex    int32
+		ord           int64
+		ptr, length   int32
+		expectedAt    bool
+		expectedLast  bool
+		expectedError string
+	}{
+		{"present", 0, 10, 0, 4, true, true, ""},
+		{"absent", 0, 10, 16, 6, false, false, ""},
+		{"deleted later in the block", 0, 3, 8, 7, true, false, ""},
+		{"deleted earlier in the block", 0, 10, 8, 7, false, false, ""},
+		{"invalid store index", 1, 10, 0, 4, false, false, `module "map_has": invalid store operation: 'has_at': invalid store index 1, configured stores: get mode 0 "store"`},
+		{"key out of memory", 0, 10, -8, 16, false, false, `module "map_has" passed out-of-bounds pointer to state.has_at: 16 bytes at 4294967288, memory is`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "map_has", "map_has")
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputStore, Name: "store", Store: store}})
+			require.NoError(t, err)
+
+			err = instance.ExecuteWithArgs(ctx, test.storeIndex, test.ord, test.ptr, test.length)
+			if test.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			output := instance.Output()
+			require.Len(t, output, 12)
+			hasAt, hasLast, getAt := binary.LittleEndian.Uint32(output[0:]), binary.LittleEndian.Uint32(output[4:]), binary.LittleEndian.Uint32(output[8:])
+			assert.Equal(t, test.expectedAt, hasAt == 1)
+			assert.Equal(t, test.expectedLast, hasLast == 1)
+			assert.Equal(t, getAt, hasAt, "same as get_at")
+		})
+	}
+}
+
+func TestModule_StoreIndexAndMode(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(outOfBoundsWAT)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	store := newTestStore(t)
+	mapperInputs := []*Input{
+		{Type: InputStore, Name: "prices", Store: store},
+		{Type: InputStoreDeltas, Name: "volumes", StreamData: []byte{}},
+	}
+
+	tests := []struct {
+		name          string
+		entrypoint    string
+		inputs        []*Input
+		args          []interface{}
+		expectedError string
+	}{
+		{"valid index", "map_get", mapperInputs[:1], []interface{}{int32(0), int32(64)}, ""},
+		{"index out of range", "map_get", mapperInputs[:1], []interface{}{int32(1), int32(64)}, `module "test": invalid store operation: 'get_last': invalid store index 1, configured stores: get mode 0 "prices"`},
+		{"negative index", "map_get", mapperInputs[:1], []interface{}{int32(-1), int32(64)}, `module "test": invalid store operation: 'get_last': invalid store index -1, configured stores: get mode 0 "prices"`},
+		{"no stores", "map_get", nil, []interface{}{int32(0), int32(64)}, `module "test": invalid store operation: 'get_last': invalid store index 0, configured stores: none`},
+		{"write to a store in get mode", "store_set", mapperInputs[:1], []interface{}{int32(0), int32(3)}, `module "test": invalid store operation: 'set' is only valid on the output store of a store module, configured stores: get mode 0 "prices"`},
+		{"write to a store in deltas mode", "store_set", mapperInputs[1:], []interface{}{int32(0), int32(3)}, `module "test": invalid store operation: 'set' is only valid on the output store of a store module, configured stores: deltas mode "volumes"`},
+		{"write to the output store", "store_set", []*Input{{Type: OutputStore, Name: "out", Store: newTestStore(t)}}, []interface{}{int32(0), int32(3)}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(ctx, &pbsubstreams.Request{}, code, "test", test.entrypoint)
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, test.inputs)
+			require.NoError(t, err)
+
+			err = instance.ExecuteWithArgs(ctx, test.args...)
+			if test.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
@@ -2,9 +2,17 @@ package wasm
 
 import (
 	"fmt"
+	"strings"
 )
 
+// maxPanicMessageSize truncates the panic messages of the guests.
+const maxPanicMessageSize = 16 * 1024
+
+// PanicError is a panic of the guest, reported through the `register_panic`
+// import before it aborts. Any part the guest passed garbage for is left
+// empty.
 type PanicError struct {
+	moduleName   string
 	message      string
 	filename     string
 	lineNumber   int
@@ -12,5 +20,50 @@ type PanicError struct {
 }
 
 func (e *PanicError) Error() string {
-	return fmt.Sprintf("panic in the wasm: %q at %s:%d:%d", e.message, e.filename, e.lineNumber, e.columnNumber)
+	message := e.message
+	if message == "" {
+		message = "<no message>"
+	}
+	if e.filename == "" {
+		return fmt.Sprintf("panic in %s: %s", e.moduleName, message)
+	}
+	return fmt.Sprintf("panic in %s at %s:%d: %s", e.moduleName, e.filename, e.lineNumber, message)
+}
+
+func (e *PanicError) ModuleName() string { return e.moduleName }
+func (e *PanicError) Message() string    { return e.message }
+
+// Filename, LineNumber and ColumnNumber locate the panic in the code of the
+// module, they are empty when the guest did not report them.
+func (e *PanicError) Filename() string  { return e.filename }
+func (e *PanicError) LineNumber() int   { return e.lineNumber }
+func (e *PanicError) ColumnNumber() int { return e.columnNumber }
+
+// newPanicError decodes the arguments of the `register_panic` import, with
+// whatever of them point to readable guest memory.
+func newPanicError(heap *Heap, moduleName string, msgPtr, msgLength, filenamePtr, filenameLength, lineNumber, columnNumber int32) *PanicError {
+	out := &PanicError{moduleName: moduleName}
+
+	if message, ok := heap.TryReadBytes(msgPtr, msgLength); ok {
+		if len(message) > maxPanicMessageSize {
+			message = message[:maxPanicMessageSize]
+		}
+		out.message = strings.ToValidUTF8(string(message), "\uFFFD")
+	}
+
+	if filenamePtr != 0 && uint32(filenameLength) <= maxPanicMessageSize {
+		if filename, ok := heap.TryReadBytes(filenamePtr, filenameLength); ok {
+			out.filename = strings.ToValidUTF8(string(filename), "\uFFFD")
+		}
+	}
+	if out.filename != "" {
+		// Unsigned for the guest, garbage when out of range
+		if lineNumber > 0 {
+			out.lineNumber = int(lineNumber)
+		}
+		if columnNumber > 0 {
+			out.columnNumber = int(columnNumber)
+		}
+	}
+	return out
 }
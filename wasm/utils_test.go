@@ -0,0 +1,84 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// panicWAT registers a panic with its arguments then aborts, as the panic
+// hook of the Rust crate does.
+const panicWAT = `
+(module
+  (import "env" "register_panic" (func $register_panic (param i32 i32 i32 i32 i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "boom")
+  (data (i32.const 16) "src/lib.rs")
+  (data (i32.const 32) "\ff\fe")
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "panic_with") (param i32 i32 i32 i32 i32 i32)
+    (call $register_panic (local.get 0) (local.get 1) (local.get 2) (local.get 3) (local.get 4) (local.get 5))
+    unreachable)
+)
+`
+
+func TestPanicError(t *testing.T) {
+	code, err := wasmtime.Wat2Wasm(panicWAT)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		args        []interface{}
+		expectError string
+	}{
+		{
+			name:        "complete",
+			args:        []interface{}{int32(0), int32(4), int32(16), int32(10), int32(42), int32(7)},
+			expectError: "panic in map_panic at src/lib.rs:42: boom",
+		},
+		{
+			name:        "no filename",
+			args:        []interface{}{int32(0), int32(4), int32(0), int32(0), int32(0), int32(0)},
+			expectError: "panic in map_panic: boom",
+		},
+		{
+			name:        "garbage filename pointer",
+			args:        []interface{}{int32(0), int32(4), int32(-16), int32(10), int32(42), int32(7)},
+			expectError: "panic in map_panic: boom",
+		},
+		{
+			name:        "garbage message length",
+			args:        []interface{}{int32(0), int32(1 << 20), int32(16), int32(10), int32(42), int32(7)},
+			expectError: "panic in map_panic at src/lib.rs:42: <no message>",
+		},
+		{
+			name:        "message overflowing the memory",
+			args:        []interface{}{int32(-8), int32(10), int32(16), int32(10), int32(-1), int32(7)},
+			expectError: "panic in map_panic at src/lib.rs:0: <no message>",
+		},
+		{
+			name:        "invalid utf-8",
+			args:        []interface{}{int32(32), int32(2), int32(16), int32(10), int32(42), int32(7)},
+			expectError: "panic in map_panic at src/lib.rs:42: �",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			module, err := NewRuntime(nil).NewModule(context.Background(), &pbsubstreams.Request{}, code, "map_panic", "panic_with")
+			require.NoError(t, err)
+			instance, err := module.NewInstance(&pbsubstreams.Clock{}, nil)
+			require.NoError(t, err)
+
+			err = instance.ExecuteWithArgs(context.Background(), test.args...)
+			var panicErr *PanicError
+			require.ErrorAs(t, err, &panicErr)
+			assert.Equal(t, test.expectError, err.Error())
+		})
+	}
+}
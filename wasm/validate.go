@@ -0,0 +1,174 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// BinaryValidationError lists what the binary of a module lacks to be
+// executed.
+type BinaryValidationError struct {
+	Problems []string
+}
+
+func (e *BinaryValidationError) Error() string {
+	return strings.Join(e.Problems, ", ")
+}
+
+// EntrypointParamCount returns the number of i32 parameters the entrypoint of
+// `module` takes: a pointer and a length for each source, map or store deltas
+// input, a store handle for each store input. The store written by a store
+// module is not passed as a parameter.
+func EntrypointParamCount(module *pbsubstreams.Module) int {
+	count := 0
+	for _, input := range module.Inputs {
+		count += inputParamCount(input)
+	}
+	return count
+}
+
+// Validate compiles `wasmCode` if it was not yet for `moduleHash`, then checks
+// that it exports the memory, `alloc` and `dealloc` functions the runtime
+// needs, and an entrypoint function whose signature matches the inputs of
+// `module`, and that it only imports the functions its inputs allow. It
+// returns a BinaryValidationError listing the problems found.
+func (p *ModulePool) Validate(moduleHash string, wasmCode []byte, module *pbsubstreams.Module) error {
+	entry := p.entry(moduleHash)
+	p.compileEntry(entry, moduleHash, wasmCode)
+	if entry.compileErr != nil {
+		return &BinaryValidationError{Problems: []string{fmt.Sprintf("invalid wasm code: %s", entry.compileErr)}}
+	}
+	problems := append(validateExports(entry.exports, module), validateImports(entry.imports, module)...)
+	if len(problems) != 0 {
+		return &BinaryValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validateExports(exports []externType, module *pbsubstreams.Module) (problems []string) {
+	types := map[string]externType{}
+	for _, export := range exports {
+		types[export.name] = export
+	}
+
+	if ty, found := types["memory"]; !found || ty.kind != externMemory {
+		problems = append(problems, errMemoryNotExported.Error())
+	}
+	for _, name := range []string{"alloc", "dealloc"} {
+		if ty, found := types[name]; !found || ty.kind != externFunc {
+			problems = append(problems, fmt.Sprintf("function %q is not exported", name))
+		}
+	}
+
+	entrypoint := module.BinaryEntrypoint
+	if ty, found := types[entrypoint]; !found || ty.kind != externFunc {
+		problems = append(problems, fmt.Sprintf("entrypoint %q is not an exported function", entrypoint))
+	} else {
+		problems = append(problems, validateSignature(ty, module)...)
+	}
+	return problems
+}
+
+// getModeImports are the imports only usable with a store input in get mode.
+var getModeImports = map[string]bool{"has_at": true, "has_last": true}
+
+func validateImports(imports []externType, module *pbsubstreams.Module) (problems []string) {
+	for _, input := range module.Inputs {
+		if inputParamCount(input) == 1 {
+			// A store in get mode
+			return nil
+		}
+	}
+	for _, imported := range imports {
+		if imported.namespace == "state" && getModeImports[imported.name] {
+			problems = append(problems, fmt.Sprintf("imports \"state.%s\" without any store input in get mode", imported.name))
+		}
+	}
+	return problems
+}
+
+// validateSignature checks the entrypoint takes an i32 parameter for each
+// parameter implied by the inputs of `module`, and returns nothing.
+func validateSignature(fn externType, module *pbsubstreams.Module) (problems []string) {
+	entrypoint := module.BinaryEntrypoint
+	params := fn.params
+	expected := EntrypointParamCount(module)
+	switch {
+	case len(params) != expected:
+		problem := fmt.Sprintf("entrypoint %q takes %d parameters but the module inputs imply %d", entrypoint, len(params), expected)
+		if hint := signatureHint(module, len(params), expected); hint != "" {
+			problem += fmt.Sprintf(" (%s)", hint)
+		}
+		problems = append(problems, problem)
+	case !allI32(params, expected):
+		problems = append(problems, fmt.Sprintf("entrypoint %q takes %s, expected %d i32 parameters for the module inputs", entrypoint, describeParams(params), expected))
+	}
+	if results := fn.results; len(results) != 0 {
+		problems = append(problems, fmt.Sprintf("entrypoint %q returns %s, expected no result", entrypoint, describeParams(results)))
+	}
+	return problems
+}
+
+// signatureHint guesses which inputs of `module` the entrypoint does not take
+// when it takes less parameters than expected.
+func signatureHint(module *pbsubstreams.Module, actual, expected int) string {
+	if actual > expected {
+		return "inputs missing from the manifest?"
+	}
+	var candidates []string
+	for _, input := range module.Inputs {
+		if inputParamCount(input) == expected-actual {
+			candidates = append(candidates, describeInput(input))
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("missing %s?", strings.Join(candidates, " or "))
+}
+
+func inputParamCount(input *pbsubstreams.Module_Input) int {
+	if store := input.GetStore(); store != nil && store.Mode != pbsubstreams.Module_Input_Store_DELTAS {
+		return 1
+	}
+	return 2
+}
+
+func describeInput(input *pbsubstreams.Module_Input) string {
+	switch {
+	case input.GetSource() != nil:
+		return fmt.Sprintf("source %q", input.GetSource().Type)
+	case input.GetMap() != nil:
+		return fmt.Sprintf("map %q", input.GetMap().ModuleName)
+	case input.GetStore() != nil && input.GetStore().Mode == pbsubstreams.Module_Input_Store_DELTAS:
+		return fmt.Sprintf("store %q in deltas mode", input.GetStore().ModuleName)
+	case input.GetStore() != nil:
+		return fmt.Sprintf("store %q in get mode", input.GetStore().ModuleName)
+	}
+	return "unknown input"
+}
+
+func allI32(params []valueKind, count int) bool {
+	if len(params) != count {
+		return false
+	}
+	for _, param := range params {
+		if param != valueI32 {
+			return false
+		}
+	}
+	return true
+}
+
+func describeParams(params []valueKind) string {
+	if len(params) == 0 {
+		return "no parameters"
+	}
+	kinds := make([]string, len(params))
+	for i, param := range params {
+		kinds[i] = param.String()
+	}
+	return fmt.Sprintf("(%s)", strings.Join(kinds, ", "))
+}
@@ -0,0 +1,78 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noAllocWAT lacks the alloc function, exports a mapper taking an i64 and a
+// mapper returning a value.
+const noAllocWAT = `
+(module
+  (memory (export "memory") 1)
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_wide") (param i64))
+  (func (export "map_result") (param i32 i32) (result i32) (i32.const 0))
+)
+`
+
+func testModule(entrypoint string, inputs ...*pbsubstreams.Module_Input) *pbsubstreams.Module {
+	return &pbsubstreams.Module{Name: "map_a", BinaryEntrypoint: entrypoint, Inputs: inputs}
+}
+
+var (
+	sourceInput      = &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.test.Block"}}}
+	mapInput         = &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: "map_a"}}}
+	storeGetInput    = &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Store_{Store: &pbsubstreams.Module_Input_Store{ModuleName: "store_a"}}}
+	storeDeltasInput = &pbsubstreams.Module_Input{Input: &pbsubstreams.Module_Input_Store_{Store: &pbsubstreams.Module_Input_Store{ModuleName: "store_b", Mode: pbsubstreams.Module_Input_Store_DELTAS}}}
+)
+
+func TestModulePool_Validate(t *testing.T) {
+	counterCode, err := wasmtime.Wat2Wasm(counterWAT)
+	require.NoError(t, err)
+	noAllocCode, err := wasmtime.Wat2Wasm(noAllocWAT)
+	require.NoError(t, err)
+	hasCode, err := wasmtime.Wat2Wasm(hasWAT)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		code          []byte
+		module        *pbsubstreams.Module
+		expectedError string
+	}{
+		{"valid", counterCode, testModule("map_counter", sourceInput), ""},
+		{"missing entrypoint", counterCode, testModule("map_missing", sourceInput), `entrypoint "map_missing" is not an exported function`},
+		{"entrypoint is not a function", counterCode, testModule("counter", sourceInput), `entrypoint "counter" is not an exported function`},
+		{"too few params", counterCode, testModule("map_counter", sourceInput, storeDeltasInput), `entrypoint "map_counter" takes 2 parameters but the module inputs imply 4 (missing source "sf.test.Block" or store "store_b" in deltas mode?)`},
+		{"too few params for a store", counterCode, testModule("map_counter", sourceInput, storeGetInput), `entrypoint "map_counter" takes 2 parameters but the module inputs imply 3 (missing store "store_a" in get mode?)`},
+		{"too many params", counterCode, testModule("map_counter", storeGetInput), `entrypoint "map_counter" takes 2 parameters but the module inputs imply 1 (inputs missing from the manifest?)`},
+		{"wrong param type", noAllocCode, testModule("map_wide", storeGetInput), `function "alloc" is not exported, entrypoint "map_wide" takes (i64), expected 1 i32 parameters for the module inputs`},
+		{"returns a value", noAllocCode, testModule("map_result", mapInput), `entrypoint "map_result" returns (i32), expected no result`},
+		{"get mode imports without store in get mode", hasCode, testModule("map_has", storeDeltasInput, mapInput), `imports "state.has_at" without any store input in get mode, imports "state.has_last" without any store input in get mode`},
+		{"invalid code", []byte("garbage"), testModule("map_counter", sourceInput), "invalid wasm code: "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := NewModulePool(NewRuntime(nil), 2)
+			err := pool.Validate("hash_a", test.code, test.module)
+			if test.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			var validationErr *BinaryValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestEntrypointParamCount(t *testing.T) {
+	module := testModule("map_a", sourceInput, mapInput, storeGetInput, storeDeltasInput)
+	assert.Equal(t, 7, EntrypointParamCount(module))
+}
@@ -0,0 +1,190 @@
+package wasm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// WASIBinaryType is the binary type of the modules built for a wasi target,
+// like `wasm32-wasi`. They are linked with a WASI shim whose functions only
+// depend on the block being processed, so their execution stays
+// deterministic:
+//   - `fd_write` logs what is written to stdout at the info level, and to
+//     stderr at the error level,
+//   - `clock_time_get` returns the timestamp of the block, whatever the clock,
+//   - `random_get` returns a sequence derived from the block id,
+//   - `args_*` and `environ_*` report no arguments and no environment.
+//
+// Any other WASI function imported by the module traps when called.
+const WASIBinaryType = "wasm/rust-v1+wasi"
+
+const wasiModuleName = "wasi_snapshot_preview1"
+
+// WASI errno values
+const (
+	wasiErrnoSuccess = 0
+	wasiErrnoBadf    = 8
+	wasiErrnoFault   = 21
+)
+
+const (
+	wasiStdout = 1
+	wasiStderr = 2
+)
+
+// maxWASIVectors caps the number of buffers written at once by `fd_write`.
+const maxWASIVectors = 1024
+
+// binaryType returns the type of the binary of the module in the current
+// request, empty when the module is not part of it.
+func (m *Module) binaryType() string {
+	if m.request == nil || m.request.Modules == nil {
+		return ""
+	}
+	for _, module := range m.request.Modules.Modules {
+		if module.Name == m.name && int(module.BinaryIndex) < len(m.request.Modules.Binaries) {
+			return m.request.Modules.Binaries[module.BinaryIndex].Type
+		}
+	}
+	return ""
+}
+
+func (m *Module) wasiImports() (functions []hostFunction, err error) {
+	implemented := map[string]interface{}{
+		"fd_write": func(fd, iovs, iovsLength, nwrittenPtr int32) int32 {
+			return m.wasiFdWrite(fd, iovs, iovsLength, nwrittenPtr)
+		},
+		"clock_time_get": func(clockID int32, precision int64, timePtr int32) int32 {
+			timestamp := m.currentInstance.clock.GetTimestamp().AsTime().UnixNano()
+			return m.wasiWriteUint64(timePtr, uint64(timestamp))
+		},
+		"random_get": func(bufPtr, bufLength int32) int32 {
+			buf, ok := m.Heap.TryReadBytes(bufPtr, bufLength)
+			if !ok {
+				return wasiErrnoFault
+			}
+			m.currentInstance.fillRandom(buf)
+			return wasiErrnoSuccess
+		},
+		"args_sizes_get": func(countPtr, sizePtr int32) int32 {
+			return m.wasiWriteSizes(countPtr, sizePtr)
+		},
+		"args_get": func(argvPtr, argvBufPtr int32) int32 {
+			return wasiErrnoSuccess
+		},
+		"environ_sizes_get": func(countPtr, sizePtr int32) int32 {
+			return m.wasiWriteSizes(countPtr, sizePtr)
+		},
+		"environ_get": func(environPtr, environBufPtr int32) int32 {
+			return wasiErrnoSuccess
+		},
+	}
+	for name, f := range implemented {
+		functions = append(functions, hostFunction{namespace: wasiModuleName, name: name, fn: f})
+	}
+
+	for _, imported := range m.wasmModule.imports() {
+		if imported.namespace != wasiModuleName || imported.kind != externFunc {
+			continue
+		}
+		if _, found := implemented[imported.name]; found {
+			continue
+		}
+		stub, err := trappingFunction(imported, fmt.Errorf("wasi function %q is not supported, its result would not be deterministic", imported.name))
+		if err != nil {
+			return nil, fmt.Errorf("registering wasi %s stub: %w", imported.name, err)
+		}
+		functions = append(functions, stub)
+	}
+	return functions, nil
+}
+
+func (m *Module) wasiFdWrite(fd, iovs, iovsLength, nwrittenPtr int32) int32 {
+	level := pbsubstreams.LogLevel_LOG_LEVEL_INFO
+	switch fd {
+	case wasiStdout:
+	case wasiStderr:
+		level = pbsubstreams.LogLevel_LOG_LEVEL_ERROR
+	default:
+		return wasiErrnoBadf
+	}
+
+	if uint32(iovsLength) > maxWASIVectors {
+		return wasiErrnoFault
+	}
+	vectors, ok := m.Heap.TryReadBytes(iovs, iovsLength*8)
+	if !ok {
+		return wasiErrnoFault
+	}
+	var chunks [][]byte
+	var written uint64
+	for i := 0; i < len(vectors); i += 8 {
+		ptr := int32(binary.LittleEndian.Uint32(vectors[i:]))
+		length := int32(binary.LittleEndian.Uint32(vectors[i+4:]))
+		chunk, ok := m.Heap.TryReadBytes(ptr, length)
+		if !ok {
+			return wasiErrnoFault
+		}
+		chunks = append(chunks, chunk)
+		written += uint64(len(chunk))
+	}
+
+	logged := written
+	if logged > MaxLogByteCountCeiling {
+		logged = MaxLogByteCountCeiling
+	}
+	if m.shouldLog(level, int32(logged)) {
+		var message strings.Builder
+		for _, chunk := range chunks {
+			message.Write(chunk)
+		}
+		m.appendLog(level, strings.TrimSuffix(message.String(), "\n"))
+	}
+	return m.wasiWriteUint32(nwrittenPtr, uint32(written))
+}
+
+func (m *Module) wasiWriteSizes(countPtr, sizePtr int32) int32 {
+	if errno := m.wasiWriteUint32(countPtr, 0); errno != wasiErrnoSuccess {
+		return errno
+	}
+	return m.wasiWriteUint32(sizePtr, 0)
+}
+
+func (m *Module) wasiWriteUint32(ptr int32, value uint32) int32 {
+	buf, ok := m.Heap.TryReadBytes(ptr, 4)
+	if !ok {
+		return wasiErrnoFault
+	}
+	binary.LittleEndian.PutUint32(buf, value)
+	return wasiErrnoSuccess
+}
+
+func (m *Module) wasiWriteUint64(ptr int32, value uint64) int32 {
+	buf, ok := m.Heap.TryReadBytes(ptr, 8)
+	if !ok {
+		return wasiErrnoFault
+	}
+	binary.LittleEndian.PutUint64(buf, value)
+	return wasiErrnoSuccess
+}
+
+// fillRandom fills `buf` with the next bytes of the random sequence of the
+// block, the same on every execution of the block.
+func (i *Instance) fillRandom(buf []byte) {
+	for len(buf) > 0 {
+		if len(i.randomBuffer) == 0 {
+			counter := make([]byte, 8)
+			binary.LittleEndian.PutUint64(counter, i.randomCounter)
+			i.randomCounter++
+			block := sha256.Sum256(append([]byte(i.clock.GetId()), counter...))
+			i.randomBuffer = block[:]
+		}
+		n := copy(buf, i.randomBuffer)
+		buf = buf[n:]
+		i.randomBuffer = i.randomBuffer[n:]
+	}
+}
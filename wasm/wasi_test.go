@@ -0,0 +1,92 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/bytecodealliance/wasmtime-go"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// wasiWAT writes "hello" to stdout, reads the clock and 40 random bytes, and
+// outputs the number of bytes written, the time and the random bytes. It
+// calls `proc_exit` when its input is a single byte.
+const wasiWAT = `
+(module
+  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "clock_time_get" (func $clock_time_get (param i32 i64 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "random_get" (func $random_get (param i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "proc_exit" (func $proc_exit (param i32)))
+  (import "env" "output" (func $output (param i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "hello\n")
+  (data (i32.const 16) "\00\00\00\00\06\00\00\00") ;; iovec of "hello\n"
+  (func (export "alloc") (param $size i32) (result i32) (i32.const 1024))
+  (func (export "dealloc") (param i32 i32))
+  (func (export "map_wasi") (param $ptr i32) (param $len i32)
+    (if (i32.eq (local.get $len) (i32.const 1)) (then (call $proc_exit (i32.const 0))))
+    (drop (call $fd_write (i32.const 1) (i32.const 16) (i32.const 1) (i32.const 24)))
+    (drop (call $clock_time_get (i32.const 0) (i64.const 1) (i32.const 32)))
+    (drop (call $random_get (i32.const 40) (i32.const 40)))
+    (call $output (i32.const 24) (i32.const 56)))
+)
+`
+
+func newWASIModule(t *testing.T, binaryType string) (*Module, error) {
+	t.Helper()
+
+	code, err := wasmtime.Wat2Wasm(wasiWAT)
+	require.NoError(t, err)
+	request := &pbsubstreams.Request{Modules: &pbsubstreams.Modules{
+		Modules:  []*pbsubstreams.Module{{Name: "wasi"}},
+		Binaries: []*pbsubstreams.Binary{{Type: binaryType, Content: code}},
+	}}
+	return NewRuntime(nil).NewModule(context.Background(), request, code, "wasi", "map_wasi")
+}
+
+func TestModule_WASI(t *testing.T) {
+	module, err := newWASIModule(t, WASIBinaryType)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2022, 9, 1, 12, 0, 0, 42, time.UTC)
+	execute := func(blockID string) []byte {
+		instance, err := module.NewInstance(&pbsubstreams.Clock{Id: blockID, Timestamp: timestamppb.New(timestamp)}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte("block")}})
+		require.NoError(t, err)
+		require.NoError(t, instance.Execute(context.Background()))
+		assert.Equal(t, []string{"hello"}, instance.Logs)
+		return instance.Output()
+	}
+
+	output := execute("block_a")
+	require.Len(t, output, 56)
+	assert.Equal(t, uint32(6), binary.LittleEndian.Uint32(output[0:4]), "bytes written")
+	assert.Equal(t, uint64(timestamp.UnixNano()), binary.LittleEndian.Uint64(output[8:16]), "block timestamp")
+	random := output[16:]
+	assert.NotEqual(t, make([]byte, 40), random)
+
+	// Deterministic for a given block
+	assert.Equal(t, random, execute("block_a")[16:])
+	assert.NotEqual(t, random, execute("block_b")[16:])
+}
+
+func TestModule_WASIUnsupportedFunction(t *testing.T) {
+	module, err := newWASIModule(t, WASIBinaryType)
+	require.NoError(t, err)
+
+	instance, err := module.NewInstance(&pbsubstreams.Clock{}, []*Input{{Type: InputSource, Name: "in", StreamData: []byte{0x01}}})
+	require.NoError(t, err)
+	err = instance.Execute(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `wasi function "proc_exit" is not supported`)
+}
+
+func TestModule_WASIOnlyForWASIBinaries(t *testing.T) {
+	_, err := newWASIModule(t, "wasm/rust-v1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "wasi_snapshot_preview1")
+}